@@ -12,7 +12,9 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/recover"
+	"github.com/gofiber/fiber/v2/utils"
 	"github.com/gofiber/swagger"
+	"github.com/valyala/fasthttp"
 	"github.com/zoomxml/config"
 	"github.com/zoomxml/internal/api/middleware"
 	"github.com/zoomxml/internal/api/routes"
@@ -55,18 +57,44 @@ func main() {
 
 	// Inicializar logger
 	logger.Initialize()
+	defer logger.Close()
 	logger.Printf("Starting %s v%s in %s mode", cfg.App.Name, cfg.App.Version, cfg.App.Env)
 
 	// Conectar ao banco de dados
 	if err := database.Connect(); err != nil {
 		logger.Fatal("Failed to connect to database:", err)
 	}
-	defer database.Close()
 
-	// Executar migrações automáticas
 	ctx := context.Background()
-	if err := database.AutoMigrate(ctx); err != nil {
-		logger.Fatal("Failed to run migrations:", err)
+	if cfg.Database.AutoMigrate {
+		// Executar migrações automáticas
+		if err := database.AutoMigrate(ctx); err != nil {
+			logger.Fatal("Failed to run migrations:", err)
+		}
+
+		// Executar migrações versionadas (alterações de schema em tabelas existentes)
+		if err := database.RunMigrations(ctx); err != nil {
+			logger.Fatal("Failed to run versioned migrations:", err)
+		}
+	} else {
+		// Sem migração automática, um schema mais antigo que o binário impede a subida
+		pending, err := database.PendingMigrations(ctx)
+		if err != nil {
+			logger.Fatal("Failed to check pending migrations:", err)
+		}
+		if len(pending) > 0 {
+			logger.Fatalf("Database schema is behind this binary; pending migrations: %s", strings.Join(pending, ", "))
+		}
+	}
+
+	// Particionar documentos por mês de emissão (opcional)
+	if cfg.Database.PartitionDocuments {
+		if err := database.PartitionDocumentsTable(ctx); err != nil {
+			logger.Fatal("Failed to partition documents table:", err)
+		}
+		if err := database.EnsureDocumentPartitionsAhead(ctx, time.Now()); err != nil {
+			logger.Fatal("Failed to create documents partitions:", err)
+		}
 	}
 
 	// Executar seeders (criar usuário admin automaticamente)
@@ -79,14 +107,63 @@ func main() {
 		logger.Fatal("Failed to initialize storage:", err)
 	}
 
+	// Aquecimento: índices, ciclo de vida do bucket e sondas de escrita (falha rápida)
+	if cfg.Server.StartupWarmup {
+		if err := database.EnsureIndexes(ctx); err != nil {
+			logger.Fatal("Failed to ensure database indexes:", err)
+		}
+		if err := storage.Warmup(ctx); err != nil {
+			logger.Fatal("Storage warmup failed:", err)
+		}
+		if err := database.ProbeRoundtrip(ctx); err != nil {
+			logger.Fatal("Database warmup failed:", err)
+		}
+	}
+
+	// Workers em segundo plano, parados no desligamento depois do servidor HTTP
+	var workers []backgroundWorker
+
 	// Inicializar e iniciar o scheduler NFSe
 	nfseScheduler := services.NewNFSeScheduler()
 	if err := nfseScheduler.Start(); err != nil {
 		logger.Fatal("Failed to start NFSe scheduler:", err)
 	}
-
-	// Graceful shutdown do scheduler
-	defer nfseScheduler.Stop()
+	workers = append(workers, nfseScheduler)
+
+	// Reprocessar XMLs guardados enquanto o storage estava indisponível
+	pendingIngestRetrier := services.NewPendingIngestRetrier()
+	pendingIngestRetrier.Start()
+	workers = append(workers, pendingIngestRetrier)
+
+	// Aplicar as políticas de retenção de documentos
+	retentionScheduler := services.NewRetentionScheduler()
+	retentionScheduler.Start()
+	workers = append(workers, retentionScheduler)
+
+	// Consolidar competências divididas entre duas pastas de ano no storage
+	competenceConsolidator := services.NewCompetenceConsolidator()
+	competenceConsolidator.Start()
+	workers = append(workers, competenceConsolidator)
+
+	// Reprocessar documentos das empresas em lotes
+	reprocessWorker := services.NewReprocessWorker()
+	reprocessWorker.Start()
+	workers = append(workers, reprocessWorker)
+
+	// Copiar os XMLs das empresas para os buckets de exportação dos clientes
+	exportWorker := services.NewExportWorker()
+	exportWorker.Start()
+	workers = append(workers, exportWorker)
+
+	// Comparar periodicamente os documentos de cada empresa com os XMLs no storage
+	integrityChecker := services.NewIntegrityChecker()
+	integrityChecker.Start()
+	workers = append(workers, integrityChecker)
+
+	// Atualizar a situação cadastral das empresas ativas
+	registrationRefresher := services.NewRegistrationRefresher()
+	registrationRefresher.Start()
+	workers = append(workers, registrationRefresher)
 
 	// Criar aplicação Fiber
 	app := fiber.New(fiber.Config{
@@ -94,6 +171,7 @@ func main() {
 		ReadTimeout:  cfg.Server.ReadTimeout,
 		WriteTimeout: cfg.Server.WriteTimeout,
 		IdleTimeout:  cfg.Server.IdleTimeout,
+		BodyLimit:    cfg.Server.BodyLimit,
 		ErrorHandler: errorHandler,
 	})
 
@@ -107,10 +185,13 @@ func main() {
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 
+	// Listen retorna assim que os listeners fecham, por isso main espera shutdownDone
+	shutdownDone := make(chan struct{})
 	go func() {
+		defer close(shutdownDone)
 		<-c
 		logger.Println("Gracefully shutting down...")
-		_ = app.Shutdown()
+		gracefulShutdown(app, cfg.Server.ShutdownTimeout, workers, database.Close)
 	}()
 
 	// Iniciar servidor
@@ -121,7 +202,41 @@ func main() {
 		logger.Fatal("Failed to start server:", err)
 	}
 
-	logger.Println("Server stopped")
+	<-shutdownDone
+}
+
+// httpServer é a parte do servidor HTTP usada no desligamento
+type httpServer interface {
+	ShutdownWithTimeout(timeout time.Duration) error
+}
+
+// backgroundWorker é um worker iniciado por main e parado no desligamento
+type backgroundWorker interface {
+	Stop()
+}
+
+// gracefulShutdown desliga a aplicação em ordem: o servidor para de aceitar conexões e
+// conclui as requisições em andamento (até timeout); só então os workers param, na ordem
+// inversa em que foram iniciados, e por último closeDB fecha o banco. Assim nenhuma
+// requisição enfileira trabalho depois que os workers pararam.
+func gracefulShutdown(server httpServer, timeout time.Duration, workers []backgroundWorker, closeDB func() error) {
+	if err := server.ShutdownWithTimeout(timeout); err != nil {
+		logger.ErrorWithFields("HTTP shutdown did not complete cleanly", err, map[string]any{
+			"operation": "shutdown",
+			"timeout":   timeout.String(),
+		})
+	}
+
+	logger.Println("Server stopped, stopping background workers")
+	for i := len(workers) - 1; i >= 0; i-- {
+		workers[i].Stop()
+	}
+
+	if err := closeDB(); err != nil {
+		logger.ErrorWithFields("Failed to close database", err, map[string]any{
+			"operation": "shutdown",
+		})
+	}
 }
 
 // setupMiddleware configura os middlewares globais
@@ -150,6 +265,16 @@ func setupMiddleware(app *fiber.App, cfg *config.Config) {
 		}))
 	}
 
+	// Compressão das respostas (conteúdo já comprimido não é recomprimido)
+	if cfg.Server.EnableCompression {
+		app.Use(compressResponses(cfg.Server.CompressionLevel))
+	}
+
+	// JSON indentado com ?pretty=true (depois da compressão, para indentar antes de comprimir)
+	if cfg.Server.AllowPrettyJSON {
+		app.Use(middleware.PrettyJSON())
+	}
+
 	// Health check endpoint
 	// @Summary Health Check
 	// @Description Verifica o status da aplicação
@@ -165,20 +290,105 @@ func setupMiddleware(app *fiber.App, cfg *config.Config) {
 		})
 	})
 
+	// Readiness check endpoint
+	app.Get("/health/ready", readinessCheck)
+
 	// Swagger documentation
 	app.Get("/swagger/*", swagger.HandlerDefault)
 }
 
-// errorHandler manipula erros globais
+// readinessCheck verifica se o banco está acessível e sem migrações pendentes
+// @Summary Readiness Check
+// @Description Verifica se o banco está acessível e sem migrações pendentes
+// @Tags health
+// @Produce json
+// @Success 200 {object} map[string]interface{} "Pronto para receber requisições"
+// @Failure 503 {object} map[string]interface{} "Banco indisponível ou migrações pendentes"
+// @Router /health/ready [get]
+func readinessCheck(c *fiber.Ctx) error {
+	pending, err := database.PendingMigrations(c.Context())
+	if err != nil {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"status": "unavailable",
+			"error":  "Database check failed",
+		})
+	}
+	if len(pending) > 0 {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"status":             "migrations_pending",
+			"pending_migrations": pending,
+		})
+	}
+	return c.JSON(fiber.Map{"status": "ready"})
+}
+
+// errorHandler manipula erros globais. Erros 5xx só expõem o detalhe com
+// APP_EXPOSE_ERRORS (padrão fora de produção); caso contrário o cliente recebe uma
+// mensagem genérica e o detalhe vai apenas para o log.
 func errorHandler(c *fiber.Ctx, err error) error {
 	code := fiber.StatusInternalServerError
+	message := err.Error()
 
 	if e, ok := err.(*fiber.Error); ok {
 		code = e.Code
+		message = e.Message
+	}
+
+	if code >= fiber.StatusInternalServerError {
+		logger.ErrorWithFields("Request failed", err, map[string]any{
+			"operation": "http_request",
+			"method":    c.Method(),
+			"path":      c.Path(),
+			"status":    code,
+		})
+
+		if !config.Get().App.ExposeErrors {
+			message = utils.StatusMessage(code)
+		}
 	}
 
 	return c.Status(code).JSON(fiber.Map{
-		"error": err.Error(),
+		"error": message,
 		"code":  code,
 	})
 }
+
+// compressedContentTypes são os tipos de corpo já comprimidos, enviados como estão
+var compressedContentTypes = map[string]bool{
+	"application/zip":              true,
+	"application/x-zip-compressed": true,
+	"application/gzip":             true,
+	"application/x-gzip":           true,
+}
+
+// compressResponses comprime as respostas conforme Accept-Encoding, no nível de
+// SERVER_COMPRESSION_LEVEL (speed, default ou best). Como o middleware compress do Fiber
+// decide antes do handler, sem ver o tipo da resposta, a compressão é aplicada aqui
+// depois dele, pulando os downloads ZIP e gzip.
+func compressResponses(level string) fiber.Handler {
+	brotliLevel, gzipLevel := fasthttp.CompressBrotliDefaultCompression, fasthttp.CompressDefaultCompression
+	switch strings.ToLower(level) {
+	case "speed":
+		brotliLevel, gzipLevel = fasthttp.CompressBrotliBestSpeed, fasthttp.CompressBestSpeed
+	case "best":
+		brotliLevel, gzipLevel = fasthttp.CompressBrotliBestCompression, fasthttp.CompressBestCompression
+	}
+	compressor := fasthttp.CompressHandlerBrotliLevel(func(*fasthttp.RequestCtx) {}, brotliLevel, gzipLevel)
+
+	return func(c *fiber.Ctx) error {
+		if c.Path() == "/health" || strings.HasPrefix(c.Path(), "/swagger") {
+			return c.Next()
+		}
+		if err := c.Next(); err != nil {
+			return err
+		}
+
+		mediaType, _, _ := strings.Cut(string(c.Response().Header.ContentType()), ";")
+		if compressedContentTypes[strings.ToLower(strings.TrimSpace(mediaType))] {
+			return nil
+		}
+
+		compressor(c.Context())
+		return nil
+	}
+}
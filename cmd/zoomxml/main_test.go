@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/zoomxml/config"
+	"github.com/zoomxml/internal/database/databasetest"
+)
+
+func TestErrorHandler(t *testing.T) {
+	tests := []struct {
+		name         string
+		err          error
+		exposeErrors bool
+		wantCode     int
+		wantMessage  string
+	}{
+		{"internal error hidden in production", errors.New("pq: relation \"documents\" does not exist"), false, 500, "Internal Server Error"},
+		{"internal error exposed in development", errors.New("pq: relation \"documents\" does not exist"), true, 500, "pq: relation \"documents\" does not exist"},
+		{"5xx fiber error hidden in production", fiber.NewError(fiber.StatusBadGateway, "dial tcp 10.0.0.1:443"), false, 502, "Bad Gateway"},
+		{"4xx fiber error kept in production", fiber.NewError(fiber.StatusNotFound, "Cannot GET /x"), false, 404, "Cannot GET /x"},
+		{"4xx fiber error kept in development", fiber.NewError(fiber.StatusRequestEntityTooLarge, "Request Entity Too Large"), true, 413, "Request Entity Too Large"},
+	}
+
+	cfg := config.Get()
+	previous := cfg.App.ExposeErrors
+	t.Cleanup(func() { cfg.App.ExposeErrors = previous })
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg.App.ExposeErrors = tt.exposeErrors
+
+			app := fiber.New(fiber.Config{ErrorHandler: errorHandler})
+			app.Get("/", func(c *fiber.Ctx) error { return tt.err })
+
+			resp, err := app.Test(httptest.NewRequest("GET", "/", nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			var body struct {
+				Error string `json:"error"`
+				Code  int    `json:"code"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.wantCode || body.Code != tt.wantCode {
+				t.Errorf("status = %d, code = %d, want %d", resp.StatusCode, body.Code, tt.wantCode)
+			}
+			if body.Error != tt.wantMessage {
+				t.Errorf("error = %q, want %q", body.Error, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestCompressResponses(t *testing.T) {
+	items := make([]fiber.Map, 500)
+	for i := range items {
+		items[i] = fiber.Map{"id": i, "number": fmt.Sprintf("NFSe %d", i)}
+	}
+	zipBody := bytes.Repeat([]byte("PK\x03\x04 already compressed "), 200)
+
+	app := fiber.New()
+	app.Use(compressResponses("default"))
+	app.Get("/documents", func(c *fiber.Ctx) error { return c.JSON(fiber.Map{"documents": items}) })
+	app.Get("/download", func(c *fiber.Ctx) error {
+		c.Set(fiber.HeaderContentType, "application/zip")
+		return c.Send(zipBody)
+	})
+
+	tests := []struct {
+		name           string
+		path           string
+		acceptEncoding string
+		wantEncoding   string
+	}{
+		{"large JSON list", "/documents", "gzip", "gzip"},
+		{"without Accept-Encoding", "/documents", "", ""},
+		{"zip download", "/download", "gzip", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.path, nil)
+			if tt.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			if got := resp.Header.Get("Content-Encoding"); got != tt.wantEncoding {
+				t.Fatalf("Content-Encoding = %q, want %q", got, tt.wantEncoding)
+			}
+			var body io.Reader = resp.Body
+			if tt.wantEncoding == "gzip" {
+				if body, err = gzip.NewReader(resp.Body); err != nil {
+					t.Fatal(err)
+				}
+			}
+			data, err := io.ReadAll(body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.path == "/download" {
+				if !bytes.Equal(data, zipBody) {
+					t.Error("zip download body changed")
+				}
+				return
+			}
+			var list struct {
+				Documents []fiber.Map `json:"documents"`
+			}
+			if err := json.Unmarshal(data, &list); err != nil {
+				t.Fatalf("body is not the JSON list: %v", err)
+			}
+			if len(list.Documents) != len(items) {
+				t.Errorf("documents = %d, want %d", len(list.Documents), len(items))
+			}
+		})
+	}
+}
+
+// shutdownRecorder records the shutdown steps of a fake server, workers and database
+type shutdownRecorder struct {
+	steps       []string
+	shutdownErr error
+}
+
+func (r *shutdownRecorder) ShutdownWithTimeout(timeout time.Duration) error {
+	r.steps = append(r.steps, "http")
+	return r.shutdownErr
+}
+
+func (r *shutdownRecorder) worker(name string) backgroundWorker {
+	return recordedWorker{r, name}
+}
+
+func (r *shutdownRecorder) closeDB() error {
+	r.steps = append(r.steps, "database")
+	return nil
+}
+
+type recordedWorker struct {
+	recorder *shutdownRecorder
+	name     string
+}
+
+func (w recordedWorker) Stop() {
+	w.recorder.steps = append(w.recorder.steps, w.name)
+}
+
+func TestGracefulShutdownOrder(t *testing.T) {
+	want := []string{"http", "registration", "export", "scheduler", "database"}
+
+	for _, shutdownErr := range []error{nil, errors.New("timed out waiting for requests")} {
+		t.Run(fmt.Sprint(shutdownErr), func(t *testing.T) {
+			recorder := &shutdownRecorder{shutdownErr: shutdownErr}
+			workers := []backgroundWorker{recorder.worker("scheduler"), recorder.worker("export"), recorder.worker("registration")}
+
+			gracefulShutdown(recorder, time.Second, workers, recorder.closeDB)
+
+			if !slices.Equal(recorder.steps, want) {
+				t.Errorf("shutdown steps = %v, want %v", recorder.steps, want)
+			}
+		})
+	}
+}
+
+func TestReadinessCheck(t *testing.T) {
+	readiness := func(t *testing.T) (int, map[string]any) {
+		t.Helper()
+		app := fiber.New()
+		app.Get("/health/ready", readinessCheck)
+		resp, err := app.Test(httptest.NewRequest("GET", "/health/ready", nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body := map[string]any{}
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode, body
+	}
+
+	t.Run("database unavailable", func(t *testing.T) {
+		databasetest.UseClosed(t)
+		if status, body := readiness(t); status != fiber.StatusServiceUnavailable || body["status"] != "unavailable" {
+			t.Errorf("readinessCheck() = %d %v, want %d unavailable", status, body, fiber.StatusServiceUnavailable)
+		}
+	})
+
+	t.Run("migrations applied", func(t *testing.T) {
+		databasetest.Require(t)
+		if status, body := readiness(t); status != fiber.StatusOK || body["status"] != "ready" {
+			t.Errorf("readinessCheck() = %d %v, want %d ready", status, body, fiber.StatusOK)
+		}
+	})
+}
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"fmt"
 	"log"
 	"os"
 	"strconv"
@@ -20,6 +21,8 @@ type Config struct {
 	Logger        LoggerConfig
 	RateLimit     RateLimitConfig
 	NFSeScheduler NFSeSchedulerConfig
+	Company       CompanyConfig
+	HTTPClient    HTTPClientConfig
 }
 
 // AppConfig holds application-specific configuration
@@ -28,6 +31,15 @@ type AppConfig struct {
 	Version string
 	Env     string
 	Debug   bool
+
+	// ExposeErrors includes internal error details in 5xx responses; otherwise clients get
+	// a generic message and the detail is only logged
+	ExposeErrors bool
+
+	// JobWorkerConcurrency is how many export jobs or reprocess batches one instance
+	// advances at a time; rows are claimed with FOR UPDATE SKIP LOCKED, so instances
+	// never advance the same row concurrently
+	JobWorkerConcurrency int
 }
 
 // DatabaseConfig holds database configuration
@@ -41,16 +53,75 @@ type DatabaseConfig struct {
 	MaxOpenConns    int
 	MaxIdleConns    int
 	ConnMaxLifetime time.Duration
+
+	// PartitionDocuments converts documents into monthly partitions by issue_date (opt-in).
+	// Partitions are created ahead of time, through PartitionMonthsAhead months from now.
+	PartitionDocuments   bool
+	PartitionMonthsAhead int
+
+	// InsertChunkSize bounds the rows per INSERT statement when storing a batch of
+	// documents; all chunks of a batch run in one transaction
+	InsertChunkSize int
+
+	// AutoMigrate creates tables and applies versioned migrations at startup. When
+	// disabled, the server refuses to start while migrations are pending.
+	AutoMigrate bool
 }
 
 // StorageConfig holds MinIO/S3 storage configuration
 type StorageConfig struct {
+	// Backend selects the storage implementation: minio (default) or filesystem
+	Backend   string
+	LocalPath string // Root directory of the filesystem backend
+
 	Endpoint  string
 	AccessKey string
 	SecretKey string
 	Bucket    string
 	UseSSL    bool
 	Region    string
+
+	// Raw provider response capture for companies flagged with debug_capture.
+	// Captures are stored under debug/ in Bucket and expire after DebugCaptureTTLDays.
+	DebugCaptureEnabled bool
+	DebugCaptureTTLDays int
+
+	// Fetched XMLs that fail to upload are buffered in pending_ingests and retried
+	// every PendingIngestRetryInterval instead of being dropped
+	PendingIngestEnabled       bool
+	PendingIngestRetryInterval time.Duration
+
+	// Buffered XMLs are retried at most PendingIngestMaxAttempts times; after that they
+	// stay in pending_ingests for inspection but are no longer retried
+	PendingIngestMaxAttempts int
+
+	// Documents past the retention period of their company are soft-deleted every
+	// RetentionCleanupInterval
+	RetentionCleanupEnabled  bool
+	RetentionCleanupInterval time.Duration
+
+	// XMLs that permanently fail parsing or validation are kept under dead-letter/ in
+	// the NFSe bucket, with a dead_letters row, instead of being dropped after logging
+	DeadLetterEnabled bool
+
+	// Stored XMLs filed under a year folder other than their competência year are moved
+	// to the competência year folder every ConsolidateCompetencesInterval
+	ConsolidateCompetencesEnabled  bool
+	ConsolidateCompetencesInterval time.Duration
+
+	// Unfinished exports of company XMLs to their own S3 bucket advance one chunk every
+	// ExportInterval
+	ExportInterval time.Duration
+	// ExportAllowedHosts restricts the endpoints of export targets; "*.example.com" matches
+	// subdomains
+	ExportAllowedHosts []string
+
+	// Every IntegrityCheckInterval the document rows of each active company are compared
+	// with its stored XML objects; more than IntegrityDriftThreshold missing or orphan
+	// objects is recorded as drift and logged as a warning
+	IntegrityCheckEnabled   bool
+	IntegrityCheckInterval  time.Duration
+	IntegrityDriftThreshold int
 }
 
 // AuthConfig holds authentication configuration
@@ -61,6 +132,20 @@ type AuthConfig struct {
 	PasswordMinLength   int
 	EnableRefreshTokens bool
 	AdminToken          string
+	AdminEmail          string
+	// AdminPassword is the password of the first admin; outside development the admin
+	// is not created without it (see database.SeedAdminUser)
+	AdminPassword string
+	// UserScopes are the scopes non-admin users hold on companies (documents:read,
+	// documents:write, credentials:manage): read on the companies they can access, the
+	// write scopes only where they are members; admins hold every scope
+	UserScopes []string
+
+	// AllowAnonymousListing lets unauthenticated requests list and read public companies
+	AllowAnonymousListing bool
+
+	// AccessTokenExpiry is the lifetime of the access tokens handed out with refresh tokens
+	AccessTokenExpiry time.Duration
 }
 
 // ServerConfig holds server configuration
@@ -74,18 +159,37 @@ type ServerConfig struct {
 	AllowedOrigins []string
 	AllowedMethods []string
 	AllowedHeaders []string
+	// ResponseEnvelope wraps list/detail responses in models.APIResponse
+	ResponseEnvelope bool
+	// EnableCompression compresses responses per Accept-Encoding; CompressionLevel is
+	// speed, default or best
+	EnableCompression bool
+	CompressionLevel  string
+	// AllowPrettyJSON lets clients ask for indented JSON with ?pretty=true
+	AllowPrettyJSON bool
+	// StartupWarmup ensures indexes, applies the bucket lifecycle and probes storage and
+	// database writes at startup, aborting when any of them fails
+	StartupWarmup bool
+	// ShutdownTimeout bounds how long in-flight requests may run after a shutdown signal
+	// before their connections are closed
+	ShutdownTimeout time.Duration
+	// BodyLimit is the largest request body accepted, in bytes; larger ones get 413
+	BodyLimit int
 }
 
 // LoggerConfig holds logging configuration
 type LoggerConfig struct {
 	Level      string
-	Format     string
-	Output     string
+	Format     string // json or console; empty picks console in development
+	Output     string // stdout or stderr
 	EnableFile bool
 	FilePath   string
 	MaxSize    int
 	MaxBackups int
 	MaxAge     int
+
+	// StoreProcessingLogs persists a processing_logs row per ingest batch
+	StoreProcessingLogs bool
 }
 
 // RateLimitConfig holds rate limiting configuration
@@ -104,6 +208,128 @@ type NFSeSchedulerConfig struct {
 	FetchDaysBack   int
 	MaxPagesPerRun  int
 	APIDelaySeconds int
+
+	// Per-run budgets (0 = unlimited). When exhausted the run yields and
+	// resumes from the recorded checkpoint on the next tick.
+	MaxDocumentsPerRun int
+	MaxRunDuration     time.Duration
+
+	// DefaultBaseURL is the provider endpoint used when a company has no override
+	DefaultBaseURL string
+	// AllowedHosts restricts company base URL overrides; "*.example.com" matches subdomains
+	AllowedHosts []string
+
+	// MaxInFlightDocuments bounds how many extracted XMLs of a page are held in memory
+	// before being stored during scheduled fetches
+	MaxInFlightDocuments int
+
+	// Circuit breaker per provider host: after BreakerFailureThreshold consecutive
+	// failures (0 = disabled) calls are skipped for BreakerCooldown
+	BreakerFailureThreshold int
+	BreakerCooldown         time.Duration
+
+	// CredentialFailureThreshold deactivates a credential after that many consecutive
+	// rejections by the provider (0 = never deactivate)
+	CredentialFailureThreshold int
+
+	// Company-wide reprocess batches are advanced one chunk per company every
+	// ReprocessInterval
+	ReprocessInterval time.Duration
+
+	// Limits applied when extracting ZIPs, from the provider or uploaded (0 = unlimited)
+	ZipMaxEntries    int
+	ZipMaxEntryBytes int
+	ZipMaxTotalBytes int
+
+	// DuplicateStatsCacheTTL is how long duplicate statistics are cached per company and
+	// window (0 = no cache). Ingests for a company drop its cached statistics.
+	DuplicateStatsCacheTTL time.Duration
+
+	// DedupContentHash matches notes by the SHA-256 of their raw XML before the
+	// field-based duplicate checks
+	DedupContentHash bool
+
+	// FlagLateArrivals marks notes ingested for a competência the company already closed
+	FlagLateArrivals bool
+
+	// DefaultMunicipality is the IBGE code whose provider serves companies without a
+	// municipality code
+	DefaultMunicipality string
+}
+
+// Validate checks the scheduler settings and reports every invalid one at once
+func (c NFSeSchedulerConfig) Validate() error {
+	var problems []string
+
+	if interval, err := time.ParseDuration(c.Interval); err != nil {
+		problems = append(problems, fmt.Sprintf("NFSE_SCHEDULER_INTERVAL %q is not a valid duration", c.Interval))
+	} else if interval <= 0 {
+		problems = append(problems, "NFSE_SCHEDULER_INTERVAL must be positive")
+	}
+	if c.FetchDaysBack <= 0 {
+		problems = append(problems, "NFSE_FETCH_DAYS_BACK must be positive")
+	}
+	if c.MaxPagesPerRun <= 0 {
+		problems = append(problems, "NFSE_MAX_PAGES_PER_RUN must be positive")
+	}
+	if c.APIDelaySeconds < 0 {
+		problems = append(problems, "NFSE_API_DELAY_SECONDS must not be negative")
+	}
+	if c.MaxDocumentsPerRun < 0 {
+		problems = append(problems, "NFSE_MAX_DOCUMENTS_PER_RUN must not be negative")
+	}
+	if c.MaxRunDuration < 0 {
+		problems = append(problems, "NFSE_MAX_RUN_DURATION must not be negative")
+	}
+	if c.MaxInFlightDocuments < 0 {
+		problems = append(problems, "NFSE_MAX_IN_FLIGHT_DOCUMENTS must not be negative")
+	}
+	if c.BreakerFailureThreshold < 0 {
+		problems = append(problems, "NFSE_BREAKER_FAILURE_THRESHOLD must not be negative")
+	}
+	if c.CredentialFailureThreshold < 0 {
+		problems = append(problems, "NFSE_CREDENTIAL_FAILURE_THRESHOLD must not be negative")
+	}
+	if c.ZipMaxEntries < 0 || c.ZipMaxEntryBytes < 0 || c.ZipMaxTotalBytes < 0 {
+		problems = append(problems, "NFSE_ZIP_MAX_* limits must not be negative")
+	}
+	if c.DuplicateStatsCacheTTL < 0 {
+		problems = append(problems, "NFSE_DUPLICATE_STATS_CACHE_TTL must not be negative")
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid NFSe scheduler configuration: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// HTTPClientConfig holds the transport settings shared by clients of external providers
+type HTTPClientConfig struct {
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	MaxConnsPerHost     int // 0 = unlimited
+	IdleConnTimeout     time.Duration
+	TLSMinVersion       string   // "1.2" or "1.3"; lower versions are never negotiated
+	TLSCipherSuites     []string // TLS 1.2 cipher suite names; empty = secure ECDHE/AEAD defaults
+}
+
+// CompanyConfig holds company onboarding configuration
+type CompanyConfig struct {
+	// RequiredFields lists extra CreateCompanyRequest JSON fields (e.g. email, phone, city)
+	// that must be filled in, on top of name and cnpj
+	RequiredFields []string
+
+	// Registration data (situação cadastral) of active companies is re-queried every
+	// RegistrationRefreshInterval, waiting RegistrationRefreshDelay between lookups.
+	// With RegistrationPauseAutoSync, companies no longer active stop auto-syncing.
+	RegistrationRefreshEnabled  bool
+	RegistrationRefreshInterval time.Duration
+	RegistrationRefreshDelay    time.Duration
+	RegistrationPauseAutoSync   bool
+
+	// EnvironmentMismatch is what happens when a fetch would use a credential whose
+	// environment differs from the company default: "warn" logs it, "block" refuses it
+	EnvironmentMismatch string
 }
 
 var appConfig *Config
@@ -121,6 +347,10 @@ func Load() *Config {
 			Version: getEnv("APP_VERSION", "1.0.0"),
 			Env:     getEnv("APP_ENV", "development"),
 			Debug:   getEnvBool("APP_DEBUG", false),
+
+			ExposeErrors: getEnvBool("APP_EXPOSE_ERRORS", getEnv("APP_ENV", "development") != "production"),
+
+			JobWorkerConcurrency: getEnvInt("JOB_WORKER_CONCURRENCY", 2),
 		},
 		Database: DatabaseConfig{
 			Host:            getEnv("DB_HOST", "localhost"),
@@ -132,14 +362,44 @@ func Load() *Config {
 			MaxOpenConns:    getEnvInt("DB_MAX_OPEN_CONNS", 25),
 			MaxIdleConns:    getEnvInt("DB_MAX_IDLE_CONNS", 5),
 			ConnMaxLifetime: getEnvDuration("DB_CONN_MAX_LIFETIME", 5*time.Minute),
+
+			PartitionDocuments:   getEnvBool("DB_PARTITION_DOCUMENTS", false),
+			PartitionMonthsAhead: getEnvInt("DB_PARTITION_MONTHS_AHEAD", 3),
+			InsertChunkSize:      getEnvInt("DB_INSERT_CHUNK_SIZE", 500),
+			AutoMigrate:          getEnvBool("DB_AUTO_MIGRATE", true),
 		},
 		Storage: StorageConfig{
+			Backend:   getEnv("STORAGE_BACKEND", "minio"),
+			LocalPath: getEnv("STORAGE_LOCAL_PATH", "data/storage"),
+
 			Endpoint:  getEnv("MINIO_ENDPOINT", "localhost:9000"),
 			AccessKey: getEnv("MINIO_ACCESS_KEY", "admin"),
 			SecretKey: getEnv("MINIO_SECRET_KEY", "password123"),
 			Bucket:    getEnv("MINIO_BUCKET", "nfse-storage"),
 			UseSSL:    getEnvBool("MINIO_USE_SSL", false),
 			Region:    getEnv("MINIO_REGION", "us-east-1"),
+
+			DebugCaptureEnabled: getEnvBool("STORAGE_DEBUG_CAPTURE_ENABLED", false),
+			DebugCaptureTTLDays: getEnvInt("STORAGE_DEBUG_CAPTURE_TTL_DAYS", 7),
+
+			PendingIngestEnabled:       getEnvBool("STORAGE_PENDING_INGEST_ENABLED", true),
+			PendingIngestRetryInterval: getEnvDuration("STORAGE_PENDING_INGEST_RETRY_INTERVAL", 5*time.Minute),
+			PendingIngestMaxAttempts:   getEnvInt("STORAGE_PENDING_INGEST_MAX_ATTEMPTS", 20),
+
+			RetentionCleanupEnabled:  getEnvBool("RETENTION_CLEANUP_ENABLED", true),
+			RetentionCleanupInterval: getEnvDuration("RETENTION_CLEANUP_INTERVAL", 24*time.Hour),
+
+			DeadLetterEnabled: getEnvBool("STORAGE_DEAD_LETTER_ENABLED", true),
+
+			ConsolidateCompetencesEnabled:  getEnvBool("STORAGE_CONSOLIDATE_COMPETENCES_ENABLED", false),
+			ConsolidateCompetencesInterval: getEnvDuration("STORAGE_CONSOLIDATE_COMPETENCES_INTERVAL", 24*time.Hour),
+
+			ExportInterval:     getEnvDuration("STORAGE_EXPORT_INTERVAL", 10*time.Second),
+			ExportAllowedHosts: getEnvSlice("STORAGE_EXPORT_ALLOWED_HOSTS", []string{"*.amazonaws.com"}),
+
+			IntegrityCheckEnabled:   getEnvBool("STORAGE_INTEGRITY_CHECK_ENABLED", true),
+			IntegrityCheckInterval:  getEnvDuration("STORAGE_INTEGRITY_CHECK_INTERVAL", 24*time.Hour),
+			IntegrityDriftThreshold: getEnvInt("STORAGE_INTEGRITY_DRIFT_THRESHOLD", 0),
 		},
 		Auth: AuthConfig{
 			JWTSecret:           getEnv("JWT_SECRET", "your-secret-key-change-in-production"),
@@ -148,27 +408,43 @@ func Load() *Config {
 			PasswordMinLength:   getEnvInt("PASSWORD_MIN_LENGTH", 8),
 			EnableRefreshTokens: getEnvBool("ENABLE_REFRESH_TOKENS", true),
 			AdminToken:          getEnv("ADMIN_TOKEN", "admin-secret-token"),
+			UserScopes:          getEnvSlice("AUTH_USER_SCOPES", []string{"documents:read", "documents:write", "credentials:manage"}),
+			AdminEmail:          getEnv("ADMIN_EMAIL", "admin@zoomxml.com"),
+			AdminPassword:       getEnv("ADMIN_PASSWORD", ""),
+
+			AllowAnonymousListing: getEnvBool("ALLOW_ANONYMOUS_LISTING", true),
+
+			AccessTokenExpiry: getEnvDuration("ACCESS_TOKEN_EXPIRY", 15*time.Minute),
 		},
 		Server: ServerConfig{
-			Host:           getEnv("SERVER_HOST", "0.0.0.0"),
-			Port:           getEnvInt("PORT", 3000),
-			ReadTimeout:    getEnvDuration("SERVER_READ_TIMEOUT", 30*time.Second),
-			WriteTimeout:   getEnvDuration("SERVER_WRITE_TIMEOUT", 30*time.Second),
-			IdleTimeout:    getEnvDuration("SERVER_IDLE_TIMEOUT", 120*time.Second),
-			EnableCORS:     getEnvBool("ENABLE_CORS", true),
-			AllowedOrigins: getEnvSlice("ALLOWED_ORIGINS", []string{"*"}),
-			AllowedMethods: getEnvSlice("ALLOWED_METHODS", []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}),
-			AllowedHeaders: getEnvSlice("ALLOWED_HEADERS", []string{"*"}),
+			Host:              getEnv("SERVER_HOST", "0.0.0.0"),
+			Port:              getEnvInt("PORT", 3000),
+			ReadTimeout:       getEnvDuration("SERVER_READ_TIMEOUT", 30*time.Second),
+			WriteTimeout:      getEnvDuration("SERVER_WRITE_TIMEOUT", 30*time.Second),
+			IdleTimeout:       getEnvDuration("SERVER_IDLE_TIMEOUT", 120*time.Second),
+			ShutdownTimeout:   getEnvDuration("SERVER_SHUTDOWN_TIMEOUT", 30*time.Second),
+			BodyLimit:         getEnvInt("SERVER_BODY_LIMIT", 50<<20),
+			EnableCORS:        getEnvBool("ENABLE_CORS", true),
+			AllowedOrigins:    getEnvSlice("ALLOWED_ORIGINS", []string{"*"}),
+			AllowedMethods:    getEnvSlice("ALLOWED_METHODS", []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}),
+			AllowedHeaders:    getEnvSlice("ALLOWED_HEADERS", []string{"*"}),
+			ResponseEnvelope:  getEnvBool("API_RESPONSE_ENVELOPE", false),
+			EnableCompression: getEnvBool("SERVER_ENABLE_COMPRESSION", true),
+			CompressionLevel:  getEnv("SERVER_COMPRESSION_LEVEL", "default"),
+			AllowPrettyJSON:   getEnvBool("SERVER_ALLOW_PRETTY_JSON", false),
+			StartupWarmup:     getEnvBool("SERVER_STARTUP_WARMUP", true),
 		},
 		Logger: LoggerConfig{
 			Level:      getEnv("LOG_LEVEL", "info"),
-			Format:     getEnv("LOG_FORMAT", "json"),
+			Format:     getEnv("LOG_FORMAT", ""),
 			Output:     getEnv("LOG_OUTPUT", "stdout"),
 			EnableFile: getEnvBool("LOG_ENABLE_FILE", false),
 			FilePath:   getEnv("LOG_FILE_PATH", "logs/zoomxml.log"),
 			MaxSize:    getEnvInt("LOG_MAX_SIZE", 100),
 			MaxBackups: getEnvInt("LOG_MAX_BACKUPS", 3),
 			MaxAge:     getEnvInt("LOG_MAX_AGE", 28),
+
+			StoreProcessingLogs: getEnvBool("LOG_STORE_PROCESSING_LOGS", true),
 		},
 		RateLimit: RateLimitConfig{
 			Enable:             getEnvBool("ENABLE_RATE_LIMIT", true),
@@ -183,6 +459,50 @@ func Load() *Config {
 			FetchDaysBack:   getEnvInt("NFSE_FETCH_DAYS_BACK", 90),
 			MaxPagesPerRun:  getEnvInt("NFSE_MAX_PAGES_PER_RUN", 10),
 			APIDelaySeconds: getEnvInt("NFSE_API_DELAY_SECONDS", 2),
+
+			MaxDocumentsPerRun: getEnvInt("NFSE_MAX_DOCUMENTS_PER_RUN", 0),
+			MaxRunDuration:     getEnvDuration("NFSE_MAX_RUN_DURATION", 0),
+
+			DefaultBaseURL: getEnv("NFSE_PROVIDER_BASE_URL", "https://api-nfse-imperatriz-ma.prefeituramoderna.com.br/ws/services/xmlnfse"),
+			AllowedHosts:   getEnvSlice("NFSE_PROVIDER_ALLOWED_HOSTS", []string{"*.prefeituramoderna.com.br"}),
+
+			MaxInFlightDocuments: getEnvInt("NFSE_MAX_IN_FLIGHT_DOCUMENTS", 50),
+
+			BreakerFailureThreshold:    getEnvInt("NFSE_BREAKER_FAILURE_THRESHOLD", 5),
+			BreakerCooldown:            getEnvDuration("NFSE_BREAKER_COOLDOWN", 5*time.Minute),
+			CredentialFailureThreshold: getEnvInt("NFSE_CREDENTIAL_FAILURE_THRESHOLD", 3),
+
+			ReprocessInterval: getEnvDuration("NFSE_REPROCESS_INTERVAL", 10*time.Second),
+
+			ZipMaxEntries:    getEnvInt("NFSE_ZIP_MAX_ENTRIES", 1000),
+			ZipMaxEntryBytes: getEnvInt("NFSE_ZIP_MAX_ENTRY_BYTES", 10<<20),
+			ZipMaxTotalBytes: getEnvInt("NFSE_ZIP_MAX_TOTAL_BYTES", 200<<20),
+
+			DuplicateStatsCacheTTL: getEnvDuration("NFSE_DUPLICATE_STATS_CACHE_TTL", time.Minute),
+
+			DedupContentHash: getEnvBool("NFSE_DEDUP_CONTENT_HASH", true),
+
+			FlagLateArrivals: getEnvBool("NFSE_FLAG_LATE_ARRIVALS", true),
+
+			DefaultMunicipality: getEnv("NFSE_DEFAULT_MUNICIPALITY", "2105302"),
+		},
+		Company: CompanyConfig{
+			RequiredFields: getEnvSlice("COMPANY_REQUIRED_FIELDS", nil),
+
+			RegistrationRefreshEnabled:  getEnvBool("COMPANY_REGISTRATION_REFRESH_ENABLED", true),
+			RegistrationRefreshInterval: getEnvDuration("COMPANY_REGISTRATION_REFRESH_INTERVAL", 7*24*time.Hour),
+			RegistrationRefreshDelay:    getEnvDuration("COMPANY_REGISTRATION_REFRESH_DELAY", 2*time.Second),
+			RegistrationPauseAutoSync:   getEnvBool("COMPANY_REGISTRATION_PAUSE_AUTO_SYNC", true),
+
+			EnvironmentMismatch: getEnv("COMPANY_ENVIRONMENT_MISMATCH", "warn"),
+		},
+		HTTPClient: HTTPClientConfig{
+			MaxIdleConns:        getEnvInt("HTTP_MAX_IDLE_CONNS", 100),
+			MaxIdleConnsPerHost: getEnvInt("HTTP_MAX_IDLE_CONNS_PER_HOST", 10),
+			MaxConnsPerHost:     getEnvInt("HTTP_MAX_CONNS_PER_HOST", 20),
+			IdleConnTimeout:     getEnvDuration("HTTP_IDLE_CONN_TIMEOUT", 90*time.Second),
+			TLSMinVersion:       getEnv("HTTP_TLS_MIN_VERSION", "1.2"),
+			TLSCipherSuites:     getEnvSlice("HTTP_TLS_CIPHER_SUITES", nil),
 		},
 	}
 
@@ -0,0 +1,118 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// load reads the configuration from the environment, restoring the global one when the
+// test ends
+func load(t *testing.T) *Config {
+	t.Helper()
+	previous := appConfig
+	t.Cleanup(func() { appConfig = previous })
+	return Load()
+}
+
+func TestLoadNFSeScheduler(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		got := load(t).NFSeScheduler
+		if !got.Enabled || got.Interval != "24h" || got.FetchDaysBack != 90 || got.MaxPagesPerRun != 10 || got.APIDelaySeconds != 2 {
+			t.Errorf("NFSeScheduler = %+v, want enabled every 24h, 90 days back, 10 pages, 2s delay", got)
+		}
+		if err := got.Validate(); err != nil {
+			t.Errorf("Validate() of the defaults error = %v", err)
+		}
+	})
+
+	t.Run("environment", func(t *testing.T) {
+		t.Setenv("NFSE_SCHEDULER_ENABLED", "false")
+		t.Setenv("NFSE_SCHEDULER_INTERVAL", "6h")
+		t.Setenv("NFSE_FETCH_DAYS_BACK", "30")
+		t.Setenv("NFSE_MAX_PAGES_PER_RUN", "3")
+		t.Setenv("NFSE_API_DELAY_SECONDS", "0")
+		t.Setenv("NFSE_MAX_RUN_DURATION", "15m")
+
+		got := load(t).NFSeScheduler
+		if got.Enabled || got.Interval != "6h" || got.FetchDaysBack != 30 || got.MaxPagesPerRun != 3 || got.APIDelaySeconds != 0 || got.MaxRunDuration != 15*time.Minute {
+			t.Errorf("NFSeScheduler = %+v, want the environment values", got)
+		}
+	})
+
+	t.Run("unparsable values keep the defaults", func(t *testing.T) {
+		t.Setenv("NFSE_SCHEDULER_ENABLED", "sometimes")
+		t.Setenv("NFSE_FETCH_DAYS_BACK", "ninety")
+
+		got := load(t).NFSeScheduler
+		if !got.Enabled || got.FetchDaysBack != 90 {
+			t.Errorf("NFSeScheduler enabled %v, %d days back, want the defaults", got.Enabled, got.FetchDaysBack)
+		}
+	})
+}
+
+func TestNFSeSchedulerConfigValidate(t *testing.T) {
+	valid := NFSeSchedulerConfig{Interval: "24h", FetchDaysBack: 90, MaxPagesPerRun: 10, APIDelaySeconds: 2}
+
+	tests := []struct {
+		name string
+		edit func(*NFSeSchedulerConfig)
+		want []string // problems reported, none when valid
+	}{
+		{"valid", func(c *NFSeSchedulerConfig) {}, nil},
+		{"no API delay", func(c *NFSeSchedulerConfig) { c.APIDelaySeconds = 0 }, nil},
+		{"invalid interval", func(c *NFSeSchedulerConfig) { c.Interval = "daily" }, []string{`NFSE_SCHEDULER_INTERVAL "daily" is not a valid duration`}},
+		{"zero interval", func(c *NFSeSchedulerConfig) { c.Interval = "0s" }, []string{"NFSE_SCHEDULER_INTERVAL must be positive"}},
+		{"zero days back", func(c *NFSeSchedulerConfig) { c.FetchDaysBack = 0 }, []string{"NFSE_FETCH_DAYS_BACK must be positive"}},
+		{"negative pages", func(c *NFSeSchedulerConfig) { c.MaxPagesPerRun = -1 }, []string{"NFSE_MAX_PAGES_PER_RUN must be positive"}},
+		{"negative API delay", func(c *NFSeSchedulerConfig) { c.APIDelaySeconds = -2 }, []string{"NFSE_API_DELAY_SECONDS must not be negative"}},
+		{"every problem at once", func(c *NFSeSchedulerConfig) {
+			c.Interval, c.FetchDaysBack, c.MaxPagesPerRun = "", 0, 0
+		}, []string{"NFSE_SCHEDULER_INTERVAL", "NFSE_FETCH_DAYS_BACK", "NFSE_MAX_PAGES_PER_RUN"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid
+			tt.edit(&cfg)
+			err := cfg.Validate()
+			if tt.want == nil {
+				if err != nil {
+					t.Errorf("Validate() error = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Validate() error = nil, want %v", tt.want)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Validate() error = %q, want it to report %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadAllowAnonymousListing(t *testing.T) {
+	tests := []struct {
+		name string
+		env  string
+		want bool
+	}{
+		{"default keeps anonymous listing", "", true},
+		{"disabled", "false", false},
+		{"enabled", "true", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.env != "" {
+				t.Setenv("ALLOW_ANONYMOUS_LISTING", tt.env)
+			}
+			if got := load(t).Auth.AllowAnonymousListing; got != tt.want {
+				t.Errorf("AllowAnonymousListing = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -24,64 +24,95 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/companies": {
+        "/admin/audit-logs/export": {
             "get": {
-                "description": "Lista empresas conforme regras de visibilidade (públicas para todos, restritas apenas para membros/admin)",
+                "security": [
+                    {
+                        "UserToken": []
+                    }
+                ],
+                "description": "Exporta os logs de auditoria em CSV, com filtros por período, autor e entidade. Valores sensíveis dos detalhes são ocultados. A exportação também é auditada.",
                 "produces": [
-                    "application/json"
+                    "text/csv"
                 ],
                 "tags": [
-                    "companies"
+                    "audit"
                 ],
-                "summary": "Listar empresas",
+                "summary": "Exportar auditoria em CSV",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Filtrar por status (true/false) - apenas admin",
-                        "name": "active",
+                        "description": "Data inicial (YYYY-MM-DD)",
+                        "name": "from",
                         "in": "query"
                     },
                     {
                         "type": "string",
-                        "description": "Filtrar por tipo (true/false) - apenas admin",
-                        "name": "restricted",
+                        "description": "Data final, inclusiva (YYYY-MM-DD)",
+                        "name": "to",
                         "in": "query"
                     },
                     {
                         "type": "integer",
-                        "description": "Página (padrão: 1)",
-                        "name": "page",
+                        "description": "ID do usuário autor",
+                        "name": "actor_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Entidade (ex: Document, Company)",
+                        "name": "entity",
                         "in": "query"
                     },
                     {
                         "type": "integer",
-                        "description": "Itens por página (padrão: 20)",
-                        "name": "limit",
+                        "description": "ID da entidade",
+                        "name": "entity_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Ação (CREATE, UPDATE, DELETE)",
+                        "name": "action",
                         "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Lista de empresas com paginação",
+                        "description": "CSV",
                         "schema": {
-                            "$ref": "#/definitions/handlers.SwaggerCompaniesResponse"
+                            "type": "string"
                         }
                     },
-                    "500": {
-                        "description": "Erro interno",
+                    "400": {
+                        "description": "Filtro inválido",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "401": {
+                        "description": "Autenticação necessária",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "403": {
+                        "description": "Apenas administradores",
                         "schema": {
                             "$ref": "#/definitions/handlers.SwaggerError"
                         }
                     }
                 }
-            },
+            }
+        },
+        "/admin/auto-sync": {
             "post": {
                 "security": [
                     {
                         "UserToken": []
                     }
                 ],
-                "description": "Cria uma nova empresa no sistema (requer autenticação)",
+                "description": "Altera auto_fetch das empresas informadas, ou de todas quando company_ids é omitido, em uma única transação. Útil para pausar as sincronizações durante manutenção do provedor. A retomada sem company_ids religa apenas as empresas desligadas pela pausa em massa, exceto as com situação cadastral inativa. Uma execução do agendador em andamento deixa de buscar as empresas pausadas.",
                 "consumes": [
                     "application/json"
                 ],
@@ -89,25 +120,25 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "companies"
+                    "admin"
                 ],
-                "summary": "Criar empresa",
+                "summary": "Ativar ou pausar busca automática em massa",
                 "parameters": [
                     {
-                        "description": "Dados da empresa",
-                        "name": "company",
+                        "description": "Estado da busca automática",
+                        "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/handlers.CreateCompanyRequest"
+                            "$ref": "#/definitions/handlers.AutoSyncRequest"
                         }
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Created",
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/handlers.SwaggerCompany"
+                            "$ref": "#/definitions/fiber.Map"
                         }
                     },
                     "400": {
@@ -122,8 +153,8 @@ const docTemplate = `{
                             "$ref": "#/definitions/handlers.SwaggerError"
                         }
                     },
-                    "409": {
-                        "description": "CNPJ já existe",
+                    "403": {
+                        "description": "Apenas administradores",
                         "schema": {
                             "$ref": "#/definitions/handlers.SwaggerError"
                         }
@@ -137,75 +168,243 @@ const docTemplate = `{
                 }
             }
         },
-        "/users": {
+        "/api/companies/{company_id}/documents/search": {
             "get": {
-                "security": [
-                    {
-                        "AdminToken": []
-                    }
-                ],
-                "description": "Lista todos os usuários do sistema com paginação e filtros",
+                "description": "Searches stored NFSe documents (or, with type, NF-e and CT-e) by number, verification code, provider/taker CNPJ, service value and issue date ranges,\nstatus, cancelled/substituted flags and free text on the service description (discriminação). The filters of the\ndocument listing (service_code, natureza_operacao, tag, rps_number, rps_series, late_arrival) are accepted as well.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "users"
+                    "nfse"
                 ],
-                "summary": "Listar usuários",
+                "summary": "Search NFSe documents",
                 "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Company ID",
+                        "name": "company_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "default": 1,
+                        "description": "Page number",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 20,
+                        "description": "Items per page, at most 100 (larger values are reduced to 100)",
+                        "name": "limit",
+                        "in": "query"
+                    },
                     {
                         "type": "string",
-                        "description": "Filtrar por papel (admin/user)",
-                        "name": "role",
+                        "default": "nfse",
+                        "description": "Document type: nfse, nfe or cte",
+                        "name": "type",
                         "in": "query"
                     },
                     {
                         "type": "string",
-                        "description": "Filtrar por status (true/false)",
-                        "name": "active",
+                        "description": "Document number",
+                        "name": "number",
                         "in": "query"
                     },
                     {
-                        "type": "integer",
-                        "description": "Página (padrão: 1)",
-                        "name": "page",
+                        "type": "string",
+                        "description": "Verification code (punctuation and case are ignored)",
+                        "name": "verification_code",
                         "in": "query"
                     },
                     {
-                        "type": "integer",
-                        "description": "Itens por página (padrão: 20)",
-                        "name": "limit",
+                        "type": "string",
+                        "description": "Provider CNPJ",
+                        "name": "provider_cnpj",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Taker CNPJ",
+                        "name": "taker_cnpj",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Minimum service value",
+                        "name": "min_value",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Maximum service value",
+                        "name": "max_value",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Start issue date (YYYY-MM-DD)",
+                        "name": "start_date",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "End issue date (YYYY-MM-DD)",
+                        "name": "end_date",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Document status",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Cancelled notes",
+                        "name": "is_cancelled",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Substituted notes",
+                        "name": "is_substituted",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Free text on the service description",
+                        "name": "q",
                         "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Lista de usuários com paginação",
+                        "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/handlers.SwaggerUsersResponse"
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
                         }
                     },
                     "401": {
-                        "description": "Token de admin necessário",
+                        "description": "Unauthorized",
                         "schema": {
-                            "$ref": "#/definitions/handlers.SwaggerError"
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
                         }
                     },
                     "500": {
-                        "description": "Erro interno",
+                        "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/handlers.SwaggerError"
+                            "$ref": "#/definitions/fiber.Map"
                         }
                     }
                 }
-            },
+            }
+        },
+        "/api/companies/{company_id}/documents/upload": {
             "post": {
-                "security": [
+                "description": "Registers NFSe, NF-e and CT-e XML files sent by the user, e.g. received by e-mail. Each file in 'files' may be an XML or a ZIP archive, whose .xml entries are extracted (limited by NFSE_ZIP_MAX_*). The document type is detected from each XML. The XMLs of one request, loose or extracted, may add up to at most NFSE_ZIP_MAX_TOTAL_BYTES. Results are listed per file; ZIP entries are named archive.zip/entry.xml. Duplicates are skipped unless overwrite=true.",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "nfse"
+                ],
+                "summary": "Upload XML documents",
+                "parameters": [
                     {
-                        "AdminToken": []
+                        "type": "integer",
+                        "description": "Company ID",
+                        "name": "company_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "file",
+                        "description": "XML files or ZIP archives of XML files",
+                        "name": "files",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "default": false,
+                        "description": "Replace existing documents",
+                        "name": "overwrite",
+                        "in": "query"
                     }
                 ],
-                "description": "Cria um novo usuário no sistema (apenas admin com token especial)",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/companies/{company_id}/nfse": {
+            "get": {
+                "description": "Lists stored NFSe documents for a specific company",
                 "consumes": [
                     "application/json"
                 ],
@@ -213,419 +412,6514 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "users"
+                    "nfse"
                 ],
-                "summary": "Criar usuário",
+                "summary": "List NFSe documents",
                 "parameters": [
                     {
-                        "description": "Dados do usuário",
-                        "name": "user",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/handlers.CreateUserRequest"
-                        }
+                        "type": "integer",
+                        "description": "Company ID",
+                        "name": "company_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "default": 1,
+                        "description": "Page number",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 20,
+                        "description": "Items per page, at most 100 (larger values are reduced to 100)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Service list item (ItemListaServico)",
+                        "name": "service_code",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Operation nature (NaturezaOperacao)",
+                        "name": "natureza_operacao",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Document tag",
+                        "name": "tag",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Originating RPS number",
+                        "name": "rps_number",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Originating RPS series",
+                        "name": "rps_series",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only notes that arrived after their competência was closed",
+                        "name": "late_arrival",
+                        "in": "query"
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Created",
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/handlers.SwaggerUserWithToken"
+                            "$ref": "#/definitions/fiber.Map"
                         }
                     },
                     "400": {
-                        "description": "Erro de validação",
+                        "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/handlers.SwaggerValidationError"
+                            "$ref": "#/definitions/fiber.Map"
                         }
                     },
                     "401": {
-                        "description": "Token de admin necessário",
+                        "description": "Unauthorized",
                         "schema": {
-                            "$ref": "#/definitions/handlers.SwaggerError"
+                            "$ref": "#/definitions/fiber.Map"
                         }
                     },
-                    "409": {
-                        "description": "Email já existe",
+                    "403": {
+                        "description": "Forbidden",
                         "schema": {
-                            "$ref": "#/definitions/handlers.SwaggerError"
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
                         }
                     },
                     "500": {
-                        "description": "Erro interno",
+                        "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/handlers.SwaggerError"
+                            "$ref": "#/definitions/fiber.Map"
                         }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "handlers.CreateCompanyRequest": {
-            "type": "object",
-            "required": [
-                "cnpj",
-                "name"
-            ],
-            "properties": {
-                "address": {
+        },
+        "/api/companies/{company_id}/nfse/by-verification/{code}": {
+            "get": {
+                "description": "Returns the company's NFSe with the given verification code. Spaces, dashes, dots and letter case are ignored.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "nfse"
+                ],
+                "summary": "Find NFSe by verification code",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Company ID",
+                        "name": "company_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Verification code",
+                        "name": "code",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Document"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/companies/{company_id}/nfse/competence": {
+            "get": {
+                "description": "Joins the NFSe documents of a competência with the XML objects stored for it. Rows whose object is missing\nare flagged object_missing and objects stored under the company CNPJ that no document references are flagged row_missing.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "nfse"
+                ],
+                "summary": "List a competência across database and storage",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Company ID",
+                        "name": "company_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Competência (YYYY-MM)",
+                        "name": "competencia",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "default": false,
+                        "description": "Return only flagged entries",
+                        "name": "only_drift",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.CompetenceListing"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/companies/{company_id}/nfse/competence/close": {
+            "post": {
+                "description": "Marks the competência as closed: no more notes are expected. Notes ingested for it afterwards are flagged late_arrival (NFSE_FLAG_LATE_ARRIVALS). Closing a closed competência is a no-op.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "nfse"
+                ],
+                "summary": "Close a competência",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Company ID",
+                        "name": "company_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Competência (YYYY-MM)",
+                        "name": "competencia",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.CompetenceStatus"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/companies/{company_id}/nfse/competence/reopen": {
+            "post": {
+                "description": "Reopens a closed competência, so new notes are no longer flagged. Notes already flagged late_arrival keep the flag.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "nfse"
+                ],
+                "summary": "Reopen a competência",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Company ID",
+                        "name": "company_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Competência (YYYY-MM)",
+                        "name": "competencia",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.CompetenceStatus"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/companies/{company_id}/nfse/competence/status": {
+            "get": {
+                "description": "Returns whether the competência is open or closed, how many notes arrived after it was closed and its numbering gaps",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "nfse"
+                ],
+                "summary": "Competência completeness status",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Company ID",
+                        "name": "company_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Competência (YYYY-MM)",
+                        "name": "competencia",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.CompetenceStatus"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/companies/{company_id}/nfse/consolidate-competences": {
+            "post": {
+                "description": "Finds stored XMLs filed under a year folder other than their competência year, moves them to the\ncompetência year folder and updates storage_key in the same step. Use dry_run to only report.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "nfse"
+                ],
+                "summary": "Consolidate split NFSe competências in storage",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Company ID",
+                        "name": "company_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "default": true,
+                        "description": "Only report misplaced objects",
+                        "name": "dry_run",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.CompetenceConsolidationReport"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/companies/{company_id}/nfse/consult": {
+            "post": {
+                "description": "Fetches every provider page for the days of the competência, stores the documents and returns the outcome of each one (details capped at 200)",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "nfse"
+                ],
+                "summary": "Consult a competência synchronously",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Company ID",
+                        "name": "company_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Competência (YYYY-MM)",
+                        "name": "competencia",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.ConsultationResult"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "429": {
+                        "description": "Too many provider requests",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "502": {
+                        "description": "Bad Gateway",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "504": {
+                        "description": "Gateway Timeout",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/companies/{company_id}/nfse/dead-letters": {
+            "get": {
+                "description": "Lists fetched XMLs that failed parsing or validation and were kept for inspection, newest first",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "nfse"
+                ],
+                "summary": "List dead-lettered NFSe XMLs",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Company ID",
+                        "name": "company_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Status (pending, reprocessed)",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 1,
+                        "description": "Page number",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 20,
+                        "description": "Items per page, at most 100 (larger values are reduced to 100)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/companies/{company_id}/nfse/dead-letters/{dead_letter_id}/reprocess": {
+            "post": {
+                "description": "Ingests the stored XML again. On success (stored or already present) the item is marked reprocessed;\non failure its attempts and error are updated and the error is returned with status 422.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "nfse"
+                ],
+                "summary": "Reprocess a dead-lettered NFSe XML",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Company ID",
+                        "name": "company_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Dead letter ID",
+                        "name": "dead_letter_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.DeadLetterReprocessResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.DeadLetterReprocessResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/companies/{company_id}/nfse/dedup-check": {
+            "post": {
+                "description": "Parses the XML and runs the same duplicate check as the ingest, returning the decision, the check method and the existing document. Nothing is stored. Accepts JSON {\"xml_content\"} or a raw XML body.",
+                "consumes": [
+                    "application/json",
+                    "text/xml"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "nfse"
+                ],
+                "summary": "Preview NFSe deduplication",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Company ID",
+                        "name": "company_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "XML to check",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.DedupCheckRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.DuplicatePreview"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/companies/{company_id}/nfse/download": {
+            "get": {
+                "description": "Streams the stored XML of the documents issued between start_date and end_date (inclusive, up to 366 days\nand 500 documents) into a ZIP archive with a manifest.csv listing each note's number, value and status.\nDocuments whose stored object is missing, without stored XML or past the 100 MB limit are left out of the manifest and listed in skipped.json.",
+                "produces": [
+                    "application/zip"
+                ],
+                "tags": [
+                    "nfse"
+                ],
+                "summary": "Download NFSe documents of a date range as a ZIP with a manifest",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Company ID",
+                        "name": "company_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Start date (YYYY-MM-DD)",
+                        "name": "start_date",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "End date (YYYY-MM-DD)",
+                        "name": "end_date",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Streams the stored XML of the given documents into a ZIP archive, up to 500 documents and 100 MB of XML.\nDocuments of other companies, documents whose stored object is missing, documents without stored XML\n(metadata-only companies) and documents past the size limit are skipped and listed in a skipped.json entry\nat the end of the archive.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/zip"
+                ],
+                "tags": [
+                    "nfse"
+                ],
+                "summary": "Download selected NFSe documents as a ZIP",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Company ID",
+                        "name": "company_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Documents to download",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.DownloadNFSeRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/companies/{company_id}/nfse/duplicate-stats": {
+            "get": {
+                "description": "Counts documents, distinct verification codes, cancelled and substituted notes created in a window: start_date and end_date (inclusive), or the last days (default 30). Results are cached for NFSE_DUPLICATE_STATS_CACHE_TTL and refreshed when new documents are ingested.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "nfse"
+                ],
+                "summary": "Get NFSe duplicate statistics",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Company ID",
+                        "name": "company_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "default": 30,
+                        "description": "Last days, ignored with start_date/end_date",
+                        "name": "days",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Start date (YYYY-MM-DD)",
+                        "name": "start_date",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "End date (YYYY-MM-DD)",
+                        "name": "end_date",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/companies/{company_id}/nfse/export": {
+            "get": {
+                "description": "Streams number, dates, competência, provider and taker, service value, ISS and status of every stored NFSe document,\noptionally narrowed to an issue date range and/or a competência. Rows are read in batches, so exports of any size are streamed.",
+                "produces": [
+                    "text/csv",
+                    "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+                ],
+                "tags": [
+                    "nfse"
+                ],
+                "summary": "Export NFSe document metadata as CSV or XLSX",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Company ID",
+                        "name": "company_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Export format: csv (default) or xlsx",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Start issue date (YYYY-MM-DD)",
+                        "name": "start_date",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "End issue date (YYYY-MM-DD)",
+                        "name": "end_date",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Competência (YYYY-MM)",
+                        "name": "competencia",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/companies/{company_id}/nfse/fetch": {
+            "post": {
+                "description": "Fetches NFSe documents from the municipal API for a specific company. With mode \"since_last\"\nthe dates are ignored and every page from the last stored document up to today is fetched and\nstored page by page; the response then carries the count but not the documents",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "nfse"
+                ],
+                "summary": "Fetch NFSe documents",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Company ID",
+                        "name": "company_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Fetch request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.FetchNFSeRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.FetchNFSeResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "429": {
+                        "description": "Too many provider requests",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/companies/{company_id}/nfse/gaps": {
+            "get": {
+                "description": "For each provider CNPJ, lists the NFSe numbers missing from the observed min–max range of the competência",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "nfse"
+                ],
+                "summary": "List NFSe numbering gaps",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Company ID",
+                        "name": "company_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Competência (YYYY-MM)",
+                        "name": "competencia",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/companies/{company_id}/nfse/mark-reviewed": {
+            "post": {
+                "description": "Sets the status of the given documents (by ID list or filter) to reviewed. Only processed documents can be reviewed.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "nfse"
+                ],
+                "summary": "Mark NFSe documents as reviewed",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Company ID",
+                        "name": "company_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Documents to mark",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.MarkReviewedRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/companies/{company_id}/nfse/merge-duplicates": {
+            "post": {
+                "description": "Keeps the oldest document of each duplicate group (by verification code or provider CNPJ + number) and soft-deletes the rest. Use dry_run to only report.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "nfse"
+                ],
+                "summary": "Merge duplicate NFSe documents",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Company ID",
+                        "name": "company_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "default": true,
+                        "description": "Only report what would be merged",
+                        "name": "dry_run",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "default": false,
+                        "description": "Delete stored XML objects left orphaned",
+                        "name": "remove_objects",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.DuplicateMergeReport"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/companies/{company_id}/nfse/restore-objects": {
+            "post": {
+                "description": "Re-uploads the XML of documents whose row exists but whose storage object is missing, using the XML kept in the document metadata. A dry run reports the missing objects; otherwise the restore runs in the background and its outcome is logged.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "nfse"
+                ],
+                "summary": "Restore missing NFSe XML objects",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Company ID",
+                        "name": "company_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "default": true,
+                        "description": "Only report missing objects",
+                        "name": "dry_run",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Dry run report",
+                        "schema": {
+                            "$ref": "#/definitions/services.ObjectRestoreReport"
+                        }
+                    },
+                    "202": {
+                        "description": "Restore started",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "409": {
+                        "description": "Restore already running",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/companies/{company_id}/nfse/upload": {
+            "post": {
+                "description": "Registers NFSe XML files sent by the user. Duplicates are skipped unless overwrite=true, which replaces the stored XML and parsed fields of the existing document.",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "nfse"
+                ],
+                "summary": "Upload NFSe XML files",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Company ID",
+                        "name": "company_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "file",
+                        "description": "NFSe XML files",
+                        "name": "files",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "default": false,
+                        "description": "Replace existing documents",
+                        "name": "overwrite",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/companies/{company_id}/nfse/upload-zip": {
+            "post": {
+                "description": "Extracts the .xml files of a ZIP archive and registers them like /upload. Results are listed per entry, named by its path inside the archive. The archive is rejected when it has too many entries or expands past the configured sizes (NFSE_ZIP_MAX_*). Duplicates are skipped unless overwrite=true.",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "nfse"
+                ],
+                "summary": "Upload a ZIP of NFSe XML files",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Company ID",
+                        "name": "company_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "file",
+                        "description": "ZIP archive of NFSe XML files",
+                        "name": "file",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "default": false,
+                        "description": "Replace existing documents",
+                        "name": "overwrite",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/companies/{company_id}/nfse/{competencia}/download-zip": {
+            "get": {
+                "description": "Streams the stored XML of every NFSe document of the competência into a ZIP archive, one object at a time,\nso competências with thousands of notes are never held in memory. Documents without stored XML or whose object is missing are listed in skipped.json.",
+                "produces": [
+                    "application/zip"
+                ],
+                "tags": [
+                    "nfse"
+                ],
+                "summary": "Download all NFSe XML of a competência as a ZIP",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Company ID",
+                        "name": "company_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Competência (YYYY-MM)",
+                        "name": "competencia",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/companies/{company_id}/nfse/{document_id}/legal-hold": {
+            "put": {
+                "description": "Documents under legal hold are never expired by the retention policy",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "nfse"
+                ],
+                "summary": "Set NFSe legal hold",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Company ID",
+                        "name": "company_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Document ID",
+                        "name": "document_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Legal hold",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.LegalHoldRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/companies/{company_id}/nfse/{document_id}/tags": {
+            "post": {
+                "description": "Adds user tags (e.g. contestado, conciliado) to a document. Tags are lowercased; at most 20 tags of up to 50 characters per document.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "nfse"
+                ],
+                "summary": "Add tags to an NFSe document",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Company ID",
+                        "name": "company_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Document ID",
+                        "name": "document_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Tags to add",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.DocumentTagsRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Removes the given tags from a document; unknown tags are ignored",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "nfse"
+                ],
+                "summary": "Remove tags from an NFSe document",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Company ID",
+                        "name": "company_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Document ID",
+                        "name": "document_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Tags to remove",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.DocumentTagsRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/companies/{company_id}/nfse/{document_id}/xml": {
+            "get": {
+                "description": "Returns the stored XML of the document, decompressed when it was stored gzipped",
+                "produces": [
+                    "application/xml"
+                ],
+                "tags": [
+                    "nfse"
+                ],
+                "summary": "Download the XML of an NFSe document",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Company ID",
+                        "name": "company_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Document ID",
+                        "name": "document_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "404": {
+                        "description": "Document not found, XML not stored or stored object missing",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/companies/{company_id}/nfse/{number}/pdf": {
+            "get": {
+                "description": "Renders the DANFSE (human-readable PDF) of the NFSe with the given number from its stored XML: provider, taker,\nservice description, values and verification code, with a CANCELADA watermark on cancelled notes. The PDF is stored\nnext to the XML and served with an ETag, so unchanged notes are not rendered again and clients can revalidate.",
+                "produces": [
+                    "application/pdf"
+                ],
+                "tags": [
+                    "nfse"
+                ],
+                "summary": "Download the DANFSE PDF of an NFSe",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Company ID",
+                        "name": "company_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "NFSe number",
+                        "name": "number",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Verification code, when several providers share the number",
+                        "name": "verification_code",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "304": {
+                        "description": "Not modified"
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/companies/{company_id}/nfse/{number}/verify": {
+            "post": {
+                "description": "Queries the provider on the note's issue date and compares number, verification code, CNPJs, service code, value and status flags with the stored document",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "nfse"
+                ],
+                "summary": "Verify NFSe against the provider",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Company ID",
+                        "name": "company_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "NFSe number",
+                        "name": "number",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Verification code, to disambiguate notes of different providers",
+                        "name": "verification_code",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.VerificationReport"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "502": {
+                        "description": "Bad Gateway",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/companies/{company_id}/processing-logs": {
+            "get": {
+                "description": "Lists ingest batch processing logs for a company, optionally filtered by batch",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "processing-logs"
+                ],
+                "summary": "List processing logs",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Company ID",
+                        "name": "company_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Batch ID",
+                        "name": "batch_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Status (success, partial, failed)",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 1,
+                        "description": "Page number",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 20,
+                        "description": "Items per page, at most 100 (larger values are reduced to 100)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    }
+                }
+            }
+        },
+        "/companies": {
+            "get": {
+                "description": "Lista empresas conforme regras de visibilidade (públicas para todos, restritas apenas para membros/admin)",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "companies"
+                ],
+                "summary": "Listar empresas",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Filtrar por status (true/false) - apenas admin",
+                        "name": "active",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filtrar por tipo (true/false) - apenas admin",
+                        "name": "restricted",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filtrar por município (cidade)",
+                        "name": "municipio",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filtrar por busca automática (true/false)",
+                        "name": "auto_sync",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filtrar por empresas com credenciais ativas (true/false) - apenas admin ou, entre as empresas de que é membro, usuário autenticado",
+                        "name": "has_credentials",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Página (padrão: 1)",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Itens por página (padrão: 20; valores acima de 100 são reduzidos a 100)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Lista de empresas com paginação",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerCompaniesResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Autenticação necessária (ALLOW_ANONYMOUS_LISTING=false)",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "500": {
+                        "description": "Erro interno",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "UserToken": []
+                    }
+                ],
+                "description": "Cria uma nova empresa no sistema (requer autenticação)",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "companies"
+                ],
+                "summary": "Criar empresa",
+                "parameters": [
+                    {
+                        "description": "Dados da empresa",
+                        "name": "company",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.CreateCompanyRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerCompany"
+                        }
+                    },
+                    "400": {
+                        "description": "Erro de validação",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerValidationError"
+                        }
+                    },
+                    "401": {
+                        "description": "Autenticação necessária",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "409": {
+                        "description": "CNPJ já existe",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "500": {
+                        "description": "Erro interno",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    }
+                }
+            }
+        },
+        "/companies/{company_id}/compare": {
+            "get": {
+                "security": [
+                    {
+                        "UserToken": []
+                    }
+                ],
+                "description": "Returns document counts and total values for two competências plus deltas and percentage change",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "companies"
+                ],
+                "summary": "Compare competências",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Company ID",
+                        "name": "company_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Base competência (YYYY-MM)",
+                        "name": "from",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Compared competência (YYYY-MM)",
+                        "name": "to",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.CompetenceComparison"
+                        }
+                    },
+                    "400": {
+                        "description": "Parâmetros inválidos",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "401": {
+                        "description": "Autenticação necessária",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "403": {
+                        "description": "Acesso negado",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "404": {
+                        "description": "Empresa não encontrada",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "500": {
+                        "description": "Erro interno",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    }
+                }
+            }
+        },
+        "/companies/{company_id}/credentials": {
+            "get": {
+                "security": [
+                    {
+                        "UserToken": []
+                    }
+                ],
+                "description": "Lista todas as credenciais de uma empresa (requer autenticação)",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "credentials"
+                ],
+                "summary": "Listar credenciais",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID da empresa",
+                        "name": "company_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.CompanyCredential"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Autenticação necessária",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "403": {
+                        "description": "Sem permissão para esta empresa",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "404": {
+                        "description": "Empresa não encontrada",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "500": {
+                        "description": "Erro interno",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "UserToken": []
+                    }
+                ],
+                "description": "Cria uma nova credencial para uma empresa (requer autenticação)",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "credentials"
+                ],
+                "summary": "Criar credencial",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID da empresa",
+                        "name": "company_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Dados da credencial",
+                        "name": "credential",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.CreateCredentialRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/models.CompanyCredential"
+                        }
+                    },
+                    "400": {
+                        "description": "Erro de validação",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerValidationError"
+                        }
+                    },
+                    "401": {
+                        "description": "Autenticação necessária",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "403": {
+                        "description": "Sem permissão para esta empresa",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "404": {
+                        "description": "Empresa não encontrada",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "500": {
+                        "description": "Erro interno",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    }
+                }
+            }
+        },
+        "/companies/{company_id}/credentials/{credential_id}": {
+            "delete": {
+                "security": [
+                    {
+                        "UserToken": []
+                    }
+                ],
+                "description": "Remove uma credencial (requer autenticação)",
+                "tags": [
+                    "credentials"
+                ],
+                "summary": "Deletar credencial",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID da empresa",
+                        "name": "company_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "ID da credencial",
+                        "name": "credential_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Credencial removida com sucesso"
+                    },
+                    "401": {
+                        "description": "Autenticação necessária",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "403": {
+                        "description": "Sem permissão para esta empresa",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "404": {
+                        "description": "Credencial não encontrada",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "500": {
+                        "description": "Erro interno",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "security": [
+                    {
+                        "UserToken": []
+                    }
+                ],
+                "description": "Atualiza uma credencial existente (requer autenticação)",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "credentials"
+                ],
+                "summary": "Atualizar credencial",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID da empresa",
+                        "name": "company_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "ID da credencial",
+                        "name": "credential_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Dados para atualização",
+                        "name": "credential",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.UpdateCredentialRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.CompanyCredential"
+                        }
+                    },
+                    "400": {
+                        "description": "Erro de validação",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerValidationError"
+                        }
+                    },
+                    "401": {
+                        "description": "Autenticação necessária",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "403": {
+                        "description": "Sem permissão para esta empresa",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "404": {
+                        "description": "Credencial não encontrada",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "500": {
+                        "description": "Erro interno",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    }
+                }
+            }
+        },
+        "/companies/{company_id}/credentials/{credential_id}/info": {
+            "get": {
+                "security": [
+                    {
+                        "UserToken": []
+                    }
+                ],
+                "description": "Descriptografa a credencial e informa tipo, ambiente, presença de token/login, status e último uso (requer autenticação)",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "credentials"
+                ],
+                "summary": "Capacidades da credencial",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID da empresa",
+                        "name": "company_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "ID da credencial",
+                        "name": "credential_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.CredentialInfo"
+                        }
+                    },
+                    "400": {
+                        "description": "ID inválido",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "401": {
+                        "description": "Autenticação necessária",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "403": {
+                        "description": "Sem permissão para esta empresa",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "404": {
+                        "description": "Credencial não encontrada",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "500": {
+                        "description": "Erro interno",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    }
+                }
+            }
+        },
+        "/companies/{id}/export-target": {
+            "get": {
+                "security": [
+                    {
+                        "UserToken": []
+                    }
+                ],
+                "description": "Retorna o bucket S3 do cliente configurado para a empresa, sem a chave secreta",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "companies"
+                ],
+                "summary": "Obter destino de exportação",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID da empresa",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.CompanyExportTarget"
+                        }
+                    },
+                    "400": {
+                        "description": "ID inválido",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "401": {
+                        "description": "Autenticação necessária",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "403": {
+                        "description": "Apenas administradores",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "404": {
+                        "description": "Destino não configurado",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "500": {
+                        "description": "Erro interno",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "UserToken": []
+                    }
+                ],
+                "description": "Define o bucket S3 do cliente para onde os XMLs da empresa são copiados. O endpoint deve estar em STORAGE_EXPORT_ALLOWED_HOSTS, as credenciais são testadas antes de salvar e a chave secreta é armazenada criptografada.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "companies"
+                ],
+                "summary": "Configurar destino de exportação",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID da empresa",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Bucket de destino",
+                        "name": "target",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ExportTargetRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.CompanyExportTarget"
+                        }
+                    },
+                    "400": {
+                        "description": "Erro de validação",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerValidationError"
+                        }
+                    },
+                    "401": {
+                        "description": "Autenticação necessária",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "403": {
+                        "description": "Apenas administradores",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "404": {
+                        "description": "Empresa não encontrada",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "422": {
+                        "description": "Bucket inacessível com as credenciais informadas",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "500": {
+                        "description": "Erro interno",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    }
+                }
+            }
+        },
+        "/companies/{id}/exports": {
+            "post": {
+                "security": [
+                    {
+                        "UserToken": []
+                    }
+                ],
+                "description": "Testa as credenciais do destino e copia em segundo plano, em lotes, os XMLs armazenados da empresa. Objetos já presentes no destino são pulados, então repetir a exportação só copia o que falta. Se já houver uma exportação em andamento, ela é retornada.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "companies"
+                ],
+                "summary": "Exportar XMLs para o bucket do cliente",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID da empresa",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Exportação já em andamento",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ExportStatusResponse"
+                        }
+                    },
+                    "202": {
+                        "description": "Exportação criada",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ExportStatusResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "ID inválido",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "401": {
+                        "description": "Autenticação necessária",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "403": {
+                        "description": "Apenas administradores",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "404": {
+                        "description": "Destino não configurado",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "422": {
+                        "description": "Bucket inacessível",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "500": {
+                        "description": "Erro interno",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    }
+                }
+            }
+        },
+        "/companies/{id}/exports/{job_id}": {
+            "get": {
+                "security": [
+                    {
+                        "UserToken": []
+                    }
+                ],
+                "description": "Retorna os XMLs copiados, pulados (já no destino), com falha e restantes de uma exportação da empresa",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "companies"
+                ],
+                "summary": "Progresso da exportação",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID da empresa",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "ID da exportação",
+                        "name": "job_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ExportStatusResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "ID inválido",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "401": {
+                        "description": "Autenticação necessária",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "403": {
+                        "description": "Apenas administradores",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "404": {
+                        "description": "Exportação não encontrada",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "500": {
+                        "description": "Erro interno",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    }
+                }
+            }
+        },
+        "/companies/{id}/integrity": {
+            "get": {
+                "security": [
+                    {
+                        "UserToken": []
+                    }
+                ],
+                "description": "Retorna a última comparação periódica entre os documentos da empresa no banco e os XMLs no storage: XMLs ausentes, XMLs órfãos e se a divergência passou do limite STORAGE_INTEGRITY_DRIFT_THRESHOLD",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "companies"
+                ],
+                "summary": "Última verificação de integridade",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID da empresa",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.IntegrityCheck"
+                        }
+                    },
+                    "400": {
+                        "description": "ID inválido",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "401": {
+                        "description": "Autenticação necessária",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "403": {
+                        "description": "Apenas administradores",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "404": {
+                        "description": "Empresa ainda não verificada",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "500": {
+                        "description": "Erro interno",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    }
+                }
+            }
+        },
+        "/companies/{id}/overview": {
+            "get": {
+                "security": [
+                    {
+                        "UserToken": []
+                    }
+                ],
+                "description": "Retorna em uma chamada os dados da empresa, as credenciais por tipo e situação, a última sincronização e a próxima execução prevista, os totais de documentos e o trabalho pendente em segundo plano",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "companies"
+                ],
+                "summary": "Visão geral da empresa",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID da empresa",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.CompanyOverview"
+                        }
+                    },
+                    "400": {
+                        "description": "ID inválido",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "401": {
+                        "description": "Autenticação necessária",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "403": {
+                        "description": "Acesso negado",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "404": {
+                        "description": "Empresa não encontrada",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "500": {
+                        "description": "Erro interno",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    }
+                }
+            }
+        },
+        "/companies/{id}/reprocess": {
+            "post": {
+                "security": [
+                    {
+                        "UserToken": []
+                    }
+                ],
+                "description": "Relê o XML armazenado de todos os documentos NFSe da empresa com o parser atual, em lotes, em segundo plano. Se já houver um reprocessamento em andamento, ele é retornado em vez de criar outro.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "companies"
+                ],
+                "summary": "Reprocessar documentos da empresa",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID da empresa",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Reprocessamento já em andamento",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ReprocessStatusResponse"
+                        }
+                    },
+                    "202": {
+                        "description": "Reprocessamento criado",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ReprocessStatusResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "ID inválido",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "401": {
+                        "description": "Autenticação necessária",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "403": {
+                        "description": "Apenas administradores",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "404": {
+                        "description": "Empresa não encontrada",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "500": {
+                        "description": "Erro interno",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    }
+                }
+            }
+        },
+        "/companies/{id}/reprocess/{batch_id}": {
+            "get": {
+                "security": [
+                    {
+                        "UserToken": []
+                    }
+                ],
+                "description": "Retorna os documentos processados, com falha e restantes de um reprocessamento da empresa",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "companies"
+                ],
+                "summary": "Progresso do reprocessamento",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID da empresa",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "ID do reprocessamento",
+                        "name": "batch_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ReprocessStatusResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "ID inválido",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "401": {
+                        "description": "Autenticação necessária",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "403": {
+                        "description": "Apenas administradores",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "404": {
+                        "description": "Reprocessamento não encontrado",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "500": {
+                        "description": "Erro interno",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    }
+                }
+            }
+        },
+        "/companies/{id}/reset-watermark": {
+            "post": {
+                "security": [
+                    {
+                        "UserToken": []
+                    }
+                ],
+                "description": "Limpa a última sincronização da empresa e faz a próxima busca \"desde o último documento\" refazer a janela de carga inicial (NFSE_FETCH_DAYS_BACK). Documentos já armazenados são descartados pela deduplicação.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "companies"
+                ],
+                "summary": "Redefinir marca de sincronização",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID da empresa",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ResetWatermarkResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "ID inválido",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "401": {
+                        "description": "Autenticação necessária",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "403": {
+                        "description": "Apenas administradores e membros",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "404": {
+                        "description": "Empresa não encontrada",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "500": {
+                        "description": "Erro interno",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    }
+                }
+            }
+        },
+        "/companies/{id}/retention": {
+            "get": {
+                "security": [
+                    {
+                        "UserToken": []
+                    }
+                ],
+                "description": "Retorna o período de retenção dos documentos e a retenção legal da empresa",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "companies"
+                ],
+                "summary": "Obter política de retenção",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID da empresa",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.RetentionPolicyResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "ID inválido",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "401": {
+                        "description": "Autenticação necessária",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "403": {
+                        "description": "Acesso negado",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "404": {
+                        "description": "Empresa não encontrada",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "500": {
+                        "description": "Erro interno",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "UserToken": []
+                    }
+                ],
+                "description": "Define o período de retenção em meses (0 = sem limite) e a retenção legal da empresa. Documentos além do período são excluídos logicamente pela rotina de retenção.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "companies"
+                ],
+                "summary": "Atualizar política de retenção",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID da empresa",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Política de retenção",
+                        "name": "policy",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.RetentionPolicyRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.RetentionPolicyResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Erro de validação",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerValidationError"
+                        }
+                    },
+                    "401": {
+                        "description": "Autenticação necessária",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "403": {
+                        "description": "Apenas administradores",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "404": {
+                        "description": "Empresa não encontrada",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "500": {
+                        "description": "Erro interno",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    }
+                }
+            }
+        },
+        "/companies/{id}/sync-schedule": {
+            "get": {
+                "security": [
+                    {
+                        "UserToken": []
+                    }
+                ],
+                "description": "Retorna a expressão cron e a janela diária da busca automática da empresa e o próximo horário previsto",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "companies"
+                ],
+                "summary": "Obter agendamento de sincronização",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID da empresa",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SyncScheduleResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "ID inválido",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "401": {
+                        "description": "Autenticação necessária",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "403": {
+                        "description": "Acesso negado",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "404": {
+                        "description": "Empresa não encontrada",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "500": {
+                        "description": "Erro interno",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "UserToken": []
+                    }
+                ],
+                "description": "Define uma expressão cron (minuto hora dia mês dia-da-semana) e/ou uma janela diária (HH:MM, horário do servidor) para a busca automática da empresa. Com cron, a empresa é buscada quando um horário previsto passou desde a última busca agendada; com janela, só dentro dela. O agendador verifica as empresas a cada NFSE_SCHEDULER_INTERVAL, então o intervalo global limita a precisão. Campos vazios removem a configuração.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "companies"
+                ],
+                "summary": "Atualizar agendamento de sincronização",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID da empresa",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Agendamento",
+                        "name": "schedule",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SyncScheduleRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SyncScheduleResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Erro de validação",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerValidationError"
+                        }
+                    },
+                    "401": {
+                        "description": "Autenticação necessária",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "403": {
+                        "description": "Apenas administradores e membros",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "404": {
+                        "description": "Empresa não encontrada",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "500": {
+                        "description": "Erro interno",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    }
+                }
+            }
+        },
+        "/health/ready": {
+            "get": {
+                "description": "Verifica se o banco está acessível e sem migrações pendentes",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Readiness Check",
+                "responses": {
+                    "200": {
+                        "description": "Pronto para receber requisições",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "503": {
+                        "description": "Banco indisponível ou migrações pendentes",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/me/companies": {
+            "get": {
+                "security": [
+                    {
+                        "UserToken": []
+                    }
+                ],
+                "description": "Lista as empresas públicas e as empresas de que o usuário é membro (todas, para admin), indicando a forma de acesso",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "companies"
+                ],
+                "summary": "Listar minhas empresas",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Página (padrão: 1)",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Itens por página (padrão: 20; valores acima de 100 são reduzidos a 100)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Lista de empresas com paginação",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerCompaniesResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Paginação inválida",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "401": {
+                        "description": "Autenticação necessária",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "500": {
+                        "description": "Erro interno",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    }
+                }
+            }
+        },
+        "/onboard": {
+            "post": {
+                "security": [
+                    {
+                        "UserToken": []
+                    }
+                ],
+                "description": "Cria a empresa (opcionalmente completada pela consulta de CNPJ), a credencial criptografada e habilita a busca automática em uma única transação; qualquer falha desfaz tudo",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "companies"
+                ],
+                "summary": "Onboarding de empresa",
+                "parameters": [
+                    {
+                        "description": "Empresa, credencial e busca automática",
+                        "name": "onboarding",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.OnboardRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.OnboardResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Erro de validação",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerValidationError"
+                        }
+                    },
+                    "401": {
+                        "description": "Autenticação necessária",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "409": {
+                        "description": "CNPJ já existe",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "422": {
+                        "description": "Credencial inválida",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "500": {
+                        "description": "Erro interno",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    }
+                }
+            }
+        },
+        "/stats/companies/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Retorna estatísticas detalhadas de uma empresa específica",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "stats"
+                ],
+                "summary": "Estatísticas de empresa",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID da empresa",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Estatísticas da empresa",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "401": {
+                        "description": "Token inválido",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "404": {
+                        "description": "Empresa não encontrada",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "500": {
+                        "description": "Erro interno",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    }
+                }
+            }
+        },
+        "/stats/dashboard": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Retorna estatísticas gerais do sistema para o dashboard",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "stats"
+                ],
+                "summary": "Estatísticas do dashboard",
+                "responses": {
+                    "200": {
+                        "description": "Estatísticas do dashboard",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.DashboardStatsResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Token inválido",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "500": {
+                        "description": "Erro interno",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    }
+                }
+            }
+        },
+        "/stats/providers": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Retorna o estado do circuit breaker (closed, open, half_open) de cada provedor já consultado (apenas admin)",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "stats"
+                ],
+                "summary": "Estado dos provedores NFSe",
+                "responses": {
+                    "200": {
+                        "description": "Estado dos provedores",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/services.CircuitBreakerState"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Token inválido",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "403": {
+                        "description": "Apenas administradores",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    }
+                }
+            }
+        },
+        "/users": {
+            "get": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Lista todos os usuários do sistema com paginação e filtros",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Listar usuários",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Filtrar por papel (admin/user)",
+                        "name": "role",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filtrar por status (true/false)",
+                        "name": "active",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Página (padrão: 1)",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Itens por página (padrão: 20; valores acima de 100 são reduzidos a 100)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Lista de usuários com paginação",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerUsersResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Token de admin necessário",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "500": {
+                        "description": "Erro interno",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "AdminToken": []
+                    }
+                ],
+                "description": "Cria um novo usuário no sistema (apenas admin com token especial)",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Criar usuário",
+                "parameters": [
+                    {
+                        "description": "Dados do usuário",
+                        "name": "user",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.CreateUserRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerUserWithToken"
+                        }
+                    },
+                    "400": {
+                        "description": "Erro de validação",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerValidationError"
+                        }
+                    },
+                    "401": {
+                        "description": "Token de admin necessário",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "409": {
+                        "description": "Email já existe",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    },
+                    "500": {
+                        "description": "Erro interno",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SwaggerError"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "fiber.Map": {
+            "type": "object",
+            "additionalProperties": true
+        },
+        "handlers.AutoSyncRequest": {
+            "type": "object",
+            "required": [
+                "enabled"
+            ],
+            "properties": {
+                "company_ids": {
+                    "description": "Vazio = todas as empresas",
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "enabled": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "handlers.CreateCompanyRequest": {
+            "type": "object",
+            "required": [
+                "cnpj",
+                "name"
+            ],
+            "properties": {
+                "address": {
+                    "description": "Endereço completo",
+                    "type": "string"
+                },
+                "auto_fetch": {
+                    "type": "boolean"
+                },
+                "city": {
+                    "type": "string"
+                },
+                "cnpj": {
+                    "type": "string",
+                    "maxLength": 18,
+                    "minLength": 14
+                },
+                "cnpj_match_policy": {
+                    "description": "Notas de outro CNPJ (padrão: off)",
+                    "type": "string",
+                    "enum": [
+                        "off",
+                        "flag",
+                        "reject"
+                    ]
+                },
+                "company_size": {
+                    "description": "Dados empresariais",
+                    "type": "string"
+                },
+                "complement": {
+                    "type": "string"
+                },
+                "default_environment": {
+                    "description": "Ambiente esperado das credenciais (padrão: production)",
+                    "type": "string",
+                    "enum": [
+                        "production",
+                        "staging",
+                        "development"
+                    ]
+                },
+                "district": {
+                    "type": "string"
+                },
+                "document_limit": {
+                    "description": "Limite de NFSe da empresa, ex.: contas de teste (0 = sem limite) e o que fazer ao atingi-lo (padrão: reject)",
+                    "type": "integer",
+                    "minimum": 0
+                },
+                "document_limit_policy": {
+                    "type": "string",
+                    "enum": [
+                        "reject",
+                        "evict_oldest"
+                    ]
+                },
+                "email": {
+                    "type": "string"
+                },
+                "legal_nature": {
+                    "description": "Natureza jurídica",
+                    "type": "string"
+                },
+                "main_activity": {
+                    "description": "Atividade principal",
+                    "type": "string"
+                },
+                "metadata_only": {
+                    "description": "Guarda só os campos extraídos das notas, sem o XML no storage nem no banco",
+                    "type": "boolean"
+                },
+                "municipality_code": {
+                    "description": "Código IBGE do município, que escolhe o provedor NFSe (padrão: NFSE_DEFAULT_MUNICIPALITY)",
+                    "type": "string"
+                },
+                "name": {
+                    "description": "Dados básicos obrigatórios",
+                    "type": "string",
+                    "maxLength": 255,
+                    "minLength": 2
+                },
+                "number": {
+                    "type": "string"
+                },
+                "opening_date": {
+                    "description": "Data de abertura",
+                    "type": "string"
+                },
+                "phone": {
+                    "description": "Contato",
+                    "type": "string"
+                },
+                "provider_base_url": {
+                    "description": "URL do provedor NFSe (deve estar na allowlist)",
+                    "type": "string"
+                },
+                "registration_status": {
+                    "description": "Situação cadastral",
+                    "type": "string"
+                },
+                "restricted": {
+                    "description": "Configurações do sistema",
+                    "type": "boolean"
+                },
+                "secondary_activity": {
+                    "description": "Atividades secundárias",
+                    "type": "string"
+                },
+                "state": {
+                    "type": "string"
+                },
+                "trade_name": {
+                    "description": "Nome fantasia",
+                    "type": "string"
+                },
+                "validation_rules": {
+                    "description": "Regras de negócio aplicadas na ingestão (ex.: {\"validator\": \"min_service_value\", \"params\": {\"min\": 100}})",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ValidationRule"
+                    }
+                },
+                "zero_value_policy": {
+                    "description": "Notas com valor zero (padrão: flag)",
+                    "type": "string",
+                    "enum": [
+                        "accept",
+                        "flag",
+                        "reject"
+                    ]
+                },
+                "zip_code": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.CreateCredentialRequest": {
+            "type": "object",
+            "required": [
+                "name",
+                "type"
+            ],
+            "properties": {
+                "description": {
+                    "description": "Descrição opcional da credencial",
+                    "type": "string"
+                },
+                "environment": {
+                    "description": "Ambiente",
+                    "type": "string",
+                    "enum": [
+                        "production",
+                        "staging",
+                        "development"
+                    ]
+                },
+                "login": {
+                    "description": "Para user/pass e mixed",
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string",
+                    "maxLength": 255,
+                    "minLength": 2
+                },
+                "password": {
+                    "description": "Para user/pass e mixed",
+                    "type": "string"
+                },
+                "token": {
+                    "description": "Para token e mixed",
+                    "type": "string"
+                },
+                "type": {
+                    "type": "string",
+                    "enum": [
+                        "prefeitura_user_pass",
+                        "prefeitura_token",
+                        "prefeitura_mixed"
+                    ]
+                }
+            }
+        },
+        "handlers.CreateUserRequest": {
+            "type": "object",
+            "required": [
+                "email",
+                "name",
+                "password",
+                "role"
+            ],
+            "properties": {
+                "email": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string",
+                    "maxLength": 255,
+                    "minLength": 2
+                },
+                "password": {
+                    "description": "Senha obrigatória para frontend",
+                    "type": "string",
+                    "minLength": 8
+                },
+                "role": {
+                    "type": "string",
+                    "enum": [
+                        "admin",
+                        "user"
+                    ]
+                },
+                "token": {
+                    "description": "Token opcional - se não fornecido, será gerado automaticamente",
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.DashboardStatsResponse": {
+            "type": "object",
+            "properties": {
+                "companies": {
+                    "type": "object",
+                    "properties": {
+                        "active": {
+                            "type": "integer"
+                        },
+                        "auto_fetch": {
+                            "type": "integer"
+                        },
+                        "restricted": {
+                            "type": "integer"
+                        },
+                        "this_week": {
+                            "type": "integer"
+                        },
+                        "total": {
+                            "type": "integer"
+                        }
+                    }
+                },
+                "documents": {
+                    "type": "object",
+                    "properties": {
+                        "errors": {
+                            "type": "integer"
+                        },
+                        "pending": {
+                            "type": "integer"
+                        },
+                        "processed": {
+                            "type": "integer"
+                        },
+                        "today": {
+                            "type": "integer"
+                        },
+                        "total": {
+                            "type": "integer"
+                        }
+                    }
+                },
+                "recent_activity": {
+                    "type": "object",
+                    "properties": {
+                        "companies_this_week": {
+                            "type": "integer"
+                        },
+                        "documents_today": {
+                            "type": "integer"
+                        },
+                        "last_sync_time": {
+                            "type": "string"
+                        }
+                    }
+                },
+                "users": {
+                    "type": "object",
+                    "properties": {
+                        "active": {
+                            "type": "integer"
+                        },
+                        "admins": {
+                            "type": "integer"
+                        },
+                        "total": {
+                            "type": "integer"
+                        }
+                    }
+                }
+            }
+        },
+        "handlers.DeadLetterReprocessResponse": {
+            "type": "object",
+            "properties": {
+                "dead_letter": {
+                    "$ref": "#/definitions/models.DeadLetter"
+                },
+                "document_id": {
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "is_duplicate": {
+                    "type": "boolean"
+                },
+                "success": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "handlers.DedupCheckRequest": {
+            "type": "object",
+            "required": [
+                "xml_content"
+            ],
+            "properties": {
+                "xml_content": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.DocumentFilter": {
+            "type": "object",
+            "properties": {
+                "late_arrival": {
+                    "description": "Só notas chegadas após o fechamento da competência (?late_arrival=true)",
+                    "type": "boolean"
+                },
+                "natureza_operacao": {
+                    "description": "Natureza da operação (?natureza_operacao=)",
+                    "type": "string"
+                },
+                "rps_number": {
+                    "description": "Número do RPS de origem (?rps_number=)",
+                    "type": "string"
+                },
+                "rps_series": {
+                    "description": "Série do RPS de origem (?rps_series=)",
+                    "type": "string"
+                },
+                "service_code": {
+                    "description": "Item da lista de serviço (?service_code=)",
+                    "type": "string"
+                },
+                "tag": {
+                    "description": "Etiqueta do documento (?tag=)",
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.DocumentTagsRequest": {
+            "type": "object",
+            "required": [
+                "tags"
+            ],
+            "properties": {
+                "tags": {
+                    "type": "array",
+                    "minItems": 1,
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "handlers.DownloadNFSeRequest": {
+            "type": "object",
+            "required": [
+                "document_ids"
+            ],
+            "properties": {
+                "document_ids": {
+                    "description": "services.MaxArchiveDocuments",
+                    "type": "array",
+                    "maxItems": 500,
+                    "minItems": 1,
+                    "items": {
+                        "type": "integer"
+                    }
+                }
+            }
+        },
+        "handlers.ExportStatusResponse": {
+            "type": "object",
+            "properties": {
+                "company_id": {
+                    "type": "integer"
+                },
+                "copied": {
+                    "type": "integer"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "failed": {
+                    "type": "integer"
+                },
+                "finished_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "last_document_id": {
+                    "type": "integer"
+                },
+                "last_error": {
+                    "type": "string"
+                },
+                "percent": {
+                    "type": "number"
+                },
+                "remaining": {
+                    "type": "integer"
+                },
+                "requested_by": {
+                    "type": "integer"
+                },
+                "skipped": {
+                    "description": "Já presentes no destino",
+                    "type": "integer"
+                },
+                "status": {
+                    "description": "pending, running, completed, failed",
+                    "type": "string"
+                },
+                "total": {
+                    "type": "integer"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.ExportTargetRequest": {
+            "type": "object",
+            "required": [
+                "access_key",
+                "bucket",
+                "endpoint",
+                "secret_key"
+            ],
+            "properties": {
+                "access_key": {
+                    "type": "string"
+                },
+                "bucket": {
+                    "type": "string"
+                },
+                "endpoint": {
+                    "description": "ex.: s3.amazonaws.com",
+                    "type": "string"
+                },
+                "prefix": {
+                    "description": "Prefixo das chaves no bucket de destino",
+                    "type": "string"
+                },
+                "region": {
+                    "type": "string"
+                },
+                "secret_key": {
+                    "type": "string"
+                },
+                "use_ssl": {
+                    "description": "Padrão: true",
+                    "type": "boolean"
+                }
+            }
+        },
+        "handlers.FetchNFSeRequest": {
+            "type": "object",
+            "properties": {
+                "end_date": {
+                    "description": "Format: 2006-01-02",
+                    "type": "string"
+                },
+                "mode": {
+                    "description": "range (default) or since_last",
+                    "type": "string",
+                    "enum": [
+                        "range",
+                        "since_last"
+                    ]
+                },
+                "page": {
+                    "description": "Page number (default: 1)",
+                    "type": "integer"
+                },
+                "start_date": {
+                    "description": "Format: 2006-01-02",
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.FetchNFSeResponse": {
+            "type": "object",
+            "properties": {
+                "documents": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.NFSeDocument"
+                    }
+                },
+                "documents_count": {
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                },
+                "success": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "handlers.LegalHoldRequest": {
+            "type": "object",
+            "properties": {
+                "legal_hold": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "handlers.MarkReviewedRequest": {
+            "type": "object",
+            "properties": {
+                "document_ids": {
+                    "type": "array",
+                    "maxItems": 1000,
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "filter": {
+                    "$ref": "#/definitions/handlers.DocumentFilter"
+                }
+            }
+        },
+        "handlers.OnboardRequest": {
+            "type": "object",
+            "properties": {
+                "auto_sync": {
+                    "description": "Busca automática de NFSe (padrão: true)",
+                    "type": "boolean"
+                },
+                "company": {
+                    "$ref": "#/definitions/handlers.CreateCompanyRequest"
+                },
+                "credential": {
+                    "$ref": "#/definitions/handlers.CreateCredentialRequest"
+                },
+                "enrich_cnpj": {
+                    "description": "Completa os dados vazios da empresa pela consulta de CNPJ",
+                    "type": "boolean"
+                }
+            }
+        },
+        "handlers.OnboardResponse": {
+            "type": "object",
+            "properties": {
+                "auto_sync": {
+                    "type": "boolean"
+                },
+                "company_id": {
+                    "type": "integer"
+                },
+                "credential_id": {
+                    "type": "integer"
+                },
+                "enriched": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "handlers.ReprocessStatusResponse": {
+            "type": "object",
+            "properties": {
+                "company": {
+                    "description": "Relacionamentos",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.Company"
+                        }
+                    ]
+                },
+                "company_id": {
+                    "type": "integer"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "failed": {
+                    "type": "integer"
+                },
+                "finished_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "last_document_id": {
+                    "type": "integer"
+                },
+                "last_error": {
+                    "type": "string"
+                },
+                "percent": {
+                    "type": "number"
+                },
+                "processed": {
+                    "type": "integer"
+                },
+                "remaining": {
+                    "type": "integer"
+                },
+                "requested_by": {
+                    "type": "integer"
+                },
+                "status": {
+                    "description": "pending, running, completed",
+                    "type": "string"
+                },
+                "total": {
+                    "type": "integer"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.ResetWatermarkResponse": {
+            "type": "object",
+            "properties": {
+                "company_id": {
+                    "type": "integer"
+                },
+                "message": {
+                    "type": "string"
+                },
+                "resync_pending": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "handlers.RetentionPolicyRequest": {
+            "type": "object",
+            "properties": {
+                "legal_hold": {
+                    "type": "boolean"
+                },
+                "retention_months": {
+                    "description": "0 = sem limite",
+                    "type": "integer",
+                    "maximum": 1200,
+                    "minimum": 0
+                }
+            }
+        },
+        "handlers.RetentionPolicyResponse": {
+            "type": "object",
+            "properties": {
+                "company_id": {
+                    "type": "integer"
+                },
+                "held_documents": {
+                    "description": "Documentos com retenção legal individual",
+                    "type": "integer"
+                },
+                "legal_hold": {
+                    "type": "boolean"
+                },
+                "retention_months": {
+                    "type": "integer"
+                }
+            }
+        },
+        "handlers.SwaggerCompaniesResponse": {
+            "type": "object",
+            "properties": {
+                "companies": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handlers.SwaggerCompany"
+                    }
+                },
+                "pagination": {
+                    "$ref": "#/definitions/handlers.SwaggerPagination"
+                }
+            }
+        },
+        "handlers.SwaggerCompany": {
+            "type": "object",
+            "properties": {
+                "active": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "address": {
+                    "description": "Endereço",
+                    "type": "string",
+                    "example": "R PERNAMBUCO"
+                },
+                "auto_fetch": {
+                    "type": "boolean",
+                    "example": false
+                },
+                "city": {
+                    "type": "string",
+                    "example": "IMPERATRIZ"
+                },
+                "cnpj": {
+                    "type": "string",
+                    "example": "34.194.865/0001-58"
+                },
+                "company_size": {
+                    "description": "Dados empresariais",
+                    "type": "string",
+                    "example": "ME"
+                },
+                "complement": {
+                    "type": "string",
+                    "example": "LETRA A"
+                },
+                "created_at": {
+                    "type": "string",
+                    "example": "2025-08-17T19:01:44Z"
+                },
+                "district": {
+                    "type": "string",
+                    "example": "CENTRO"
+                },
+                "email": {
+                    "type": "string",
+                    "example": "contato@multicar.com"
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "legal_nature": {
+                    "type": "string",
+                    "example": "213-5 - Empresário (Individual)"
+                },
+                "main_activity": {
+                    "type": "string",
+                    "example": "45.12-9-02 - Comércio sob consignação de veículos automotores"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "S. E. L. DE SOUZA SUARES VEICULOS"
+                },
+                "number": {
+                    "type": "string",
+                    "example": "757"
+                },
+                "opening_date": {
+                    "type": "string",
+                    "example": "12/07/2019"
+                },
+                "phone": {
+                    "description": "Contato",
+                    "type": "string",
+                    "example": "(99) 9177-7746"
+                },
+                "registration_status": {
+                    "type": "string",
+                    "example": "ATIVA"
+                },
+                "restricted": {
+                    "description": "Configurações",
+                    "type": "boolean",
+                    "example": false
+                },
+                "secondary_activity": {
+                    "type": "string",
+                    "example": "45.11-1-02 - Comércio a varejo de automóveis, camionetas e utilitários usados"
+                },
+                "state": {
+                    "type": "string",
+                    "example": "MA"
+                },
+                "trade_name": {
+                    "type": "string",
+                    "example": "MULTICAR VEICULOS"
+                },
+                "updated_at": {
+                    "type": "string",
+                    "example": "2025-08-17T19:01:44Z"
+                },
+                "zip_code": {
+                    "type": "string",
+                    "example": "65.903-320"
+                }
+            }
+        },
+        "handlers.SwaggerError": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string",
+                    "example": "Mensagem de erro"
+                }
+            }
+        },
+        "handlers.SwaggerPagination": {
+            "type": "object",
+            "properties": {
+                "limit": {
+                    "type": "integer",
+                    "example": 20
+                },
+                "page": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "total": {
+                    "type": "integer",
+                    "example": 100
+                }
+            }
+        },
+        "handlers.SwaggerUser": {
+            "type": "object",
+            "properties": {
+                "active": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "created_at": {
+                    "type": "string",
+                    "example": "2025-08-17T19:01:44Z"
+                },
+                "email": {
+                    "type": "string",
+                    "example": "joao@exemplo.com"
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "name": {
+                    "type": "string",
+                    "example": "João Silva"
+                },
+                "role": {
+                    "type": "string",
+                    "example": "user"
+                },
+                "updated_at": {
+                    "type": "string",
+                    "example": "2025-08-17T19:01:44Z"
+                }
+            }
+        },
+        "handlers.SwaggerUserWithToken": {
+            "type": "object",
+            "properties": {
+                "active": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "created_at": {
+                    "type": "string",
+                    "example": "2025-08-17T19:01:44Z"
+                },
+                "email": {
+                    "type": "string",
+                    "example": "joao@exemplo.com"
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "name": {
+                    "type": "string",
+                    "example": "João Silva"
+                },
+                "role": {
+                    "type": "string",
+                    "example": "user"
+                },
+                "token": {
+                    "type": "string",
+                    "example": "U6HGHy4SDK"
+                },
+                "updated_at": {
+                    "type": "string",
+                    "example": "2025-08-17T19:01:44Z"
+                }
+            }
+        },
+        "handlers.SwaggerUsersResponse": {
+            "type": "object",
+            "properties": {
+                "pagination": {
+                    "$ref": "#/definitions/handlers.SwaggerPagination"
+                },
+                "users": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handlers.SwaggerUser"
+                    }
+                }
+            }
+        },
+        "handlers.SwaggerValidationError": {
+            "type": "object",
+            "properties": {
+                "details": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    },
+                    "example": {
+                        "{\"email\"": "\"email is required\"}"
+                    }
+                },
+                "error": {
+                    "type": "string",
+                    "example": "Validation failed"
+                }
+            }
+        },
+        "handlers.SyncScheduleRequest": {
+            "type": "object",
+            "properties": {
+                "cron": {
+                    "description": "Expressão cron de 5 campos, ex.: \"0 3 * * *\"",
+                    "type": "string"
+                },
+                "window_end": {
+                    "description": "Fim da janela diária (HH:MM, exclusivo)",
+                    "type": "string"
+                },
+                "window_start": {
+                    "description": "Início da janela diária (HH:MM)",
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.SyncScheduleResponse": {
+            "type": "object",
+            "properties": {
+                "auto_fetch": {
+                    "type": "boolean"
+                },
+                "company_id": {
+                    "type": "integer"
+                },
+                "cron": {
+                    "type": "string"
+                },
+                "global_interval": {
+                    "description": "Intervalo do agendador; a busca ocorre no primeiro ciclo após o horário previsto",
+                    "type": "string"
+                },
+                "next_sync_at": {
+                    "description": "Próximo horário previsto (ausente sem auto_fetch)",
+                    "type": "string"
+                },
+                "window_end": {
+                    "type": "string"
+                },
+                "window_start": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.UpdateCredentialRequest": {
+            "type": "object",
+            "properties": {
+                "active": {
+                    "type": "boolean"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "environment": {
+                    "type": "string",
+                    "enum": [
+                        "production",
+                        "staging",
+                        "development"
+                    ]
+                },
+                "login": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string",
+                    "maxLength": 255,
+                    "minLength": 2
+                },
+                "password": {
+                    "type": "string"
+                },
+                "token": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.Address": {
+            "type": "object",
+            "properties": {
+                "city_code": {
+                    "description": "Código IBGE do município (7 dígitos)",
+                    "type": "string"
+                },
+                "complement": {
+                    "type": "string"
+                },
+                "district": {
+                    "type": "string"
+                },
+                "number": {
+                    "type": "string"
+                },
+                "postal_code": {
+                    "type": "string"
+                },
+                "street": {
+                    "type": "string"
+                },
+                "uf": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.AuditLog": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "description": "ex: 'CREATE', 'UPDATE', 'DELETE'",
+                    "type": "string"
+                },
+                "actor": {
+                    "description": "Relacionamentos",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.User"
+                        }
+                    ]
+                },
+                "actor_id": {
+                    "description": "ID do usuário que executou a ação",
+                    "type": "integer"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "details": {
+                    "description": "Detalhes da ação em JSON",
+                    "type": "string"
+                },
+                "entity": {
+                    "description": "ex: 'User', 'Company', 'Document'",
+                    "type": "string"
+                },
+                "entity_id": {
+                    "description": "ID da entidade afetada",
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "ip_address": {
+                    "type": "string"
+                },
+                "user_agent": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.Company": {
+            "type": "object",
+            "properties": {
+                "active": {
+                    "type": "boolean"
+                },
+                "address": {
                     "description": "Endereço completo",
                     "type": "string"
                 },
-                "auto_fetch": {
-                    "type": "boolean"
+                "auto_fetch": {
+                    "type": "boolean"
+                },
+                "auto_fetch_paused": {
+                    "description": "Desligada pela pausa em massa; a retomada religa só estas (migração 046)",
+                    "type": "boolean"
+                },
+                "city": {
+                    "type": "string"
+                },
+                "cnpj": {
+                    "type": "string"
+                },
+                "cnpj_match_policy": {
+                    "description": "off, flag ou reject",
+                    "type": "string"
+                },
+                "company_size": {
+                    "description": "Dados empresariais",
+                    "type": "string"
+                },
+                "complement": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "credentials": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.CompanyCredential"
+                    }
+                },
+                "debug_capture": {
+                    "description": "Guarda respostas brutas do provedor",
+                    "type": "boolean"
+                },
+                "default_environment": {
+                    "description": "Ambiente esperado das credenciais",
+                    "type": "string"
+                },
+                "district": {
+                    "type": "string"
+                },
+                "document_limit": {
+                    "description": "Máximo de NFSe da empresa, ex.: contas de teste (0 = sem limite)",
+                    "type": "integer"
+                },
+                "document_limit_policy": {
+                    "description": "reject ou evict_oldest",
+                    "type": "string"
+                },
+                "documents": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Document"
+                    }
+                },
+                "email": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "last_sync_at": {
+                    "description": "Última sincronização automática",
+                    "type": "string"
+                },
+                "last_sync_error": {
+                    "type": "string"
+                },
+                "last_sync_status": {
+                    "description": "success, partial, failed, no_credentials",
+                    "type": "string"
+                },
+                "legal_hold": {
+                    "description": "Retenção legal: nenhum documento expira",
+                    "type": "boolean"
+                },
+                "legal_nature": {
+                    "description": "Natureza jurídica",
+                    "type": "string"
+                },
+                "main_activity": {
+                    "description": "Atividade principal",
+                    "type": "string"
+                },
+                "members": {
+                    "description": "Relacionamentos",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.CompanyMember"
+                    }
+                },
+                "metadata_only": {
+                    "description": "Guarda só os campos extraídos, sem o XML",
+                    "type": "boolean"
+                },
+                "municipality_code": {
+                    "description": "Código IBGE que escolhe o provedor NFSe (migração 039)",
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "number": {
+                    "type": "string"
+                },
+                "opening_date": {
+                    "description": "Data de abertura",
+                    "type": "string"
+                },
+                "phone": {
+                    "description": "Contato",
+                    "type": "string"
+                },
+                "provider_base_url": {
+                    "description": "Substitui a URL padrão do provedor NFSe",
+                    "type": "string"
+                },
+                "registration_checked_at": {
+                    "description": "Última consulta do CNPJ",
+                    "type": "string"
+                },
+                "registration_inactive": {
+                    "description": "Situação cadastral diferente de ativa",
+                    "type": "boolean"
+                },
+                "registration_status": {
+                    "description": "Situação cadastral",
+                    "type": "string"
+                },
+                "restricted": {
+                    "type": "boolean"
+                },
+                "resync_pending": {
+                    "description": "Próxima busca \"desde o último\" refaz a janela de carga inicial",
+                    "type": "boolean"
+                },
+                "retention_months": {
+                    "description": "Meses de retenção dos documentos (0 = sem limite)",
+                    "type": "integer"
+                },
+                "secondary_activity": {
+                    "description": "Atividades secundárias",
+                    "type": "string"
+                },
+                "state": {
+                    "type": "string"
+                },
+                "sync_cron": {
+                    "description": "Expressão cron da busca automática (substitui o intervalo global)",
+                    "type": "string"
+                },
+                "sync_window_end": {
+                    "description": "Fim da janela de busca (HH:MM, exclusivo)",
+                    "type": "string"
+                },
+                "sync_window_start": {
+                    "description": "Início da janela de busca (HH:MM, horário do servidor)",
+                    "type": "string"
+                },
+                "trade_name": {
+                    "description": "Nome fantasia",
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "validation_rules": {
+                    "description": "Regras de negócio aplicadas na ingestão",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ValidationRule"
+                    }
+                },
+                "zero_value_policy": {
+                    "description": "accept, flag ou reject",
+                    "type": "string"
+                },
+                "zip_code": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.CompanyCredential": {
+            "type": "object",
+            "properties": {
+                "active": {
+                    "type": "boolean"
+                },
+                "company": {
+                    "description": "Relacionamentos",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.Company"
+                        }
+                    ]
+                },
+                "company_id": {
+                    "type": "integer"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "disabled_reason": {
+                    "description": "Motivo da desativação automática (migração 037)",
+                    "type": "string"
+                },
+                "environment": {
+                    "description": "production, staging, development",
+                    "type": "string"
+                },
+                "failure_count": {
+                    "description": "Recusas consecutivas pelo provedor",
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "last_used_at": {
+                    "description": "Último uso em uma busca no provedor",
+                    "type": "string"
+                },
+                "login": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "type": {
+                    "description": "ex: 'prefeitura_user_pass', 'prefeitura_token', 'prefeitura_mixed'",
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.CompanyExportTarget": {
+            "type": "object",
+            "properties": {
+                "access_key": {
+                    "type": "string"
+                },
+                "bucket": {
+                    "type": "string"
+                },
+                "company_id": {
+                    "type": "integer"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "endpoint": {
+                    "description": "ex.: s3.amazonaws.com",
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "prefix": {
+                    "description": "Prefixo das chaves no bucket de destino",
+                    "type": "string"
+                },
+                "region": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "use_ssl": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "models.CompanyMember": {
+            "type": "object",
+            "properties": {
+                "company": {
+                    "$ref": "#/definitions/models.Company"
+                },
+                "company_id": {
+                    "type": "integer"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "user": {
+                    "description": "Relacionamentos",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.User"
+                        }
+                    ]
+                },
+                "user_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.CredentialInfo": {
+            "type": "object",
+            "properties": {
+                "active": {
+                    "type": "boolean"
+                },
+                "environment": {
+                    "type": "string"
+                },
+                "has_login": {
+                    "type": "boolean"
+                },
+                "has_token": {
+                    "type": "boolean"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "last_used": {
+                    "type": "string"
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.DeadLetter": {
+            "type": "object",
+            "properties": {
+                "attempts": {
+                    "description": "Reprocessamentos tentados",
+                    "type": "integer"
+                },
+                "company": {
+                    "description": "Relacionamentos",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.Company"
+                        }
+                    ]
+                },
+                "company_id": {
+                    "type": "integer"
+                },
+                "competence": {
+                    "description": "Competência informada pelo provedor",
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "file_name": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "reprocessed_document_id": {
+                    "type": "integer"
+                },
+                "source": {
+                    "description": "Origem do documento",
+                    "type": "string"
+                },
+                "status": {
+                    "description": "pending, reprocessed",
+                    "type": "string"
+                },
+                "storage_key": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.Document": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "number"
+                },
+                "cnpj_mismatch_flagged": {
+                    "description": "Nem prestador nem tomador é a empresa (migração 021)",
+                    "type": "boolean"
+                },
+                "company": {
+                    "description": "Relacionamentos",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.Company"
+                        }
+                    ]
+                },
+                "company_id": {
+                    "type": "integer"
+                },
+                "competence": {
+                    "description": "Additional important NFSe fields",
+                    "type": "string"
+                },
+                "competence_month": {
+                    "description": "Competência normalizada (YYYY-MM), usada nos filtros (migração 045)",
+                    "type": "string"
+                },
+                "content_hash": {
+                    "description": "SHA-256 do XML recebido (migração 032)",
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "deleted_at": {
+                    "description": "Exclusão lógica (migração 013)",
+                    "type": "string"
+                },
+                "discriminacao": {
+                    "description": "Descrição do serviço, com busca textual (migração 040)",
+                    "type": "string"
+                },
+                "document_hash": {
+                    "type": "string"
+                },
+                "due_date": {
+                    "type": "string"
+                },
+                "hash": {
+                    "description": "Hash do arquivo para verificação de integridade",
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "is_cancelled": {
+                    "type": "boolean"
+                },
+                "is_substituted": {
+                    "type": "boolean"
+                },
+                "iss_value": {
+                    "description": "Valor do ISS (migração 038)",
+                    "type": "number"
+                },
+                "issue_date": {
+                    "type": "string"
+                },
+                "key": {
+                    "description": "Chave de acesso do documento",
+                    "type": "string"
+                },
+                "late_arrival": {
+                    "description": "Chegou após a competência ser fechada (migração 034)",
+                    "type": "boolean"
+                },
+                "legal_hold": {
+                    "description": "Isento da política de retenção (migração 019)",
+                    "type": "boolean"
+                },
+                "metadata": {
+                    "description": "Metadados adicionais em JSON",
+                    "type": "string"
+                },
+                "municipal_registration": {
+                    "type": "string"
+                },
+                "natureza_operacao": {
+                    "description": "Natureza da operação (migração 011)",
+                    "type": "string"
+                },
+                "number": {
+                    "type": "string"
+                },
+                "original_file_name": {
+                    "description": "Nome do arquivo recebido; não faz parte da storage_key (migração 051)",
+                    "type": "string"
+                },
+                "processing_date": {
+                    "type": "string"
+                },
+                "provider_address": {
+                    "$ref": "#/definitions/models.Address"
+                },
+                "provider_cnpj": {
+                    "type": "string"
+                },
+                "provider_name": {
+                    "type": "string"
+                },
+                "provider_trade_name": {
+                    "type": "string"
+                },
+                "rps_issue_date": {
+                    "type": "string"
+                },
+                "rps_number": {
+                    "description": "Identificação do RPS de origem (migração 020)",
+                    "type": "string"
+                },
+                "rps_series": {
+                    "type": "string"
+                },
+                "rps_type": {
+                    "type": "string"
+                },
+                "series": {
+                    "type": "string"
+                },
+                "service_code": {
+                    "type": "string"
+                },
+                "service_value": {
+                    "type": "number"
+                },
+                "status": {
+                    "description": "'pending', 'processed', 'reviewed', 'error'",
+                    "type": "string"
+                },
+                "storage_key": {
+                    "description": "Chave no MinIO/S3",
+                    "type": "string"
+                },
+                "tags": {
+                    "description": "Etiquetas livres do usuário (migração 015)",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "taker_address": {
+                    "description": "Endereço do tomador (migração 035)",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.Address"
+                        }
+                    ]
+                },
+                "taker_cnpj": {
+                    "type": "string"
+                },
+                "taker_name": {
+                    "type": "string"
+                },
+                "type": {
+                    "description": "nfse, nfe ou cte (DocumentType*)",
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "verification_code": {
+                    "description": "NFSe specific fields for intelligent deduplication\n(colunas adicionadas pela migração 008_add_nfse_document_columns)",
+                    "type": "string"
+                },
+                "zero_value_flagged": {
+                    "description": "Valor de serviço zero a conferir (migração 016)",
+                    "type": "boolean"
+                }
+            }
+        },
+        "models.IntegrityCheck": {
+            "type": "object",
+            "properties": {
+                "checked_at": {
+                    "type": "string"
+                },
+                "company": {
+                    "description": "Relacionamentos",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.Company"
+                        }
+                    ]
+                },
+                "company_id": {
+                    "type": "integer"
+                },
+                "documents": {
+                    "description": "Documentos NFSe com XML armazenado",
+                    "type": "integer"
+                },
+                "drift": {
+                    "type": "integer"
+                },
+                "drift_detected": {
+                    "type": "boolean"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "missing_objects": {
+                    "description": "Documentos cujo XML não está no storage",
+                    "type": "integer"
+                },
+                "objects": {
+                    "description": "XMLs da empresa encontrados no storage",
+                    "type": "integer"
+                },
+                "orphan_objects": {
+                    "description": "XMLs sob o CNPJ da empresa sem documento",
+                    "type": "integer"
+                },
+                "threshold": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.User": {
+            "type": "object",
+            "properties": {
+                "active": {
+                    "type": "boolean"
+                },
+                "audit_logs": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.AuditLog"
+                    }
+                },
+                "company_members": {
+                    "description": "Relacionamentos",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.CompanyMember"
+                    }
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "email": {
+                    "type": "string"
                 },
-                "city": {
+                "id": {
+                    "type": "integer"
+                },
+                "name": {
                     "type": "string"
                 },
-                "cnpj": {
-                    "type": "string",
-                    "maxLength": 18,
-                    "minLength": 14
+                "role": {
+                    "description": "'admin' ou 'user'",
+                    "type": "string"
                 },
-                "company_size": {
-                    "description": "Dados empresariais",
+                "updated_at": {
                     "type": "string"
+                }
+            }
+        },
+        "models.ValidationRule": {
+            "type": "object",
+            "properties": {
+                "params": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
                 },
-                "complement": {
+                "validator": {
                     "type": "string"
+                }
+            }
+        },
+        "services.CircuitBreakerState": {
+            "type": "object",
+            "properties": {
+                "consecutive_failures": {
+                    "type": "integer"
                 },
-                "district": {
+                "opened_at": {
                     "type": "string"
                 },
-                "email": {
+                "provider": {
                     "type": "string"
                 },
-                "legal_nature": {
-                    "description": "Natureza jurídica",
+                "retry_at": {
                     "type": "string"
                 },
-                "main_activity": {
-                    "description": "Atividade principal",
+                "state": {
                     "type": "string"
+                }
+            }
+        },
+        "services.CompanyOverview": {
+            "type": "object",
+            "properties": {
+                "company": {
+                    "$ref": "#/definitions/models.Company"
                 },
-                "name": {
-                    "description": "Dados básicos obrigatórios",
-                    "type": "string",
-                    "maxLength": 255,
-                    "minLength": 2
+                "credentials": {
+                    "$ref": "#/definitions/services.CredentialsOverview"
+                },
+                "documents": {
+                    "$ref": "#/definitions/services.DocumentsOverview"
+                },
+                "pending_work": {
+                    "$ref": "#/definitions/services.PendingWorkOverview"
+                },
+                "sync": {
+                    "$ref": "#/definitions/services.SyncOverview"
+                },
+                "usage": {
+                    "$ref": "#/definitions/services.DocumentUsage"
+                }
+            }
+        },
+        "services.CompetenceComparison": {
+            "type": "object",
+            "properties": {
+                "documents_change_pct": {
+                    "type": "number"
+                },
+                "documents_delta": {
+                    "type": "integer"
+                },
+                "from": {
+                    "$ref": "#/definitions/services.CompetenceSummary"
+                },
+                "to": {
+                    "$ref": "#/definitions/services.CompetenceSummary"
+                },
+                "total_value_change_pct": {
+                    "type": "number"
+                },
+                "total_value_delta": {
+                    "type": "number"
+                }
+            }
+        },
+        "services.CompetenceConsolidationReport": {
+            "type": "object",
+            "properties": {
+                "checked": {
+                    "type": "integer"
+                },
+                "company_id": {
+                    "type": "integer"
+                },
+                "dry_run": {
+                    "type": "boolean"
+                },
+                "failed": {
+                    "type": "integer"
+                },
+                "misplaced": {
+                    "type": "integer"
+                },
+                "moved": {
+                    "type": "integer"
+                },
+                "objects": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.ConsolidatedObject"
+                    }
+                }
+            }
+        },
+        "services.CompetenceListing": {
+            "type": "object",
+            "properties": {
+                "company_id": {
+                    "type": "integer"
+                },
+                "competence": {
+                    "type": "string"
+                },
+                "documents": {
+                    "type": "integer"
+                },
+                "entries": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.CompetenceListingEntry"
+                    }
+                },
+                "missing_objects": {
+                    "type": "integer"
+                },
+                "objects": {
+                    "type": "integer"
+                },
+                "orphan_objects": {
+                    "type": "integer"
+                }
+            }
+        },
+        "services.CompetenceListingEntry": {
+            "type": "object",
+            "properties": {
+                "document_id": {
+                    "type": "integer"
+                },
+                "issue_date": {
+                    "type": "string"
                 },
                 "number": {
                     "type": "string"
                 },
-                "opening_date": {
-                    "description": "Data de abertura",
+                "object_missing": {
+                    "description": "the row exists but its storage object does not",
+                    "type": "boolean"
+                },
+                "row_missing": {
+                    "description": "the object exists but no document row points to it",
+                    "type": "boolean"
+                },
+                "service_value": {
+                    "type": "number"
+                },
+                "status": {
                     "type": "string"
                 },
-                "phone": {
-                    "description": "Contato",
+                "storage_key": {
                     "type": "string"
                 },
-                "registration_status": {
-                    "description": "Situação cadastral",
+                "xml_not_stored": {
+                    "description": "the row has no storage key (metadata-only company)",
+                    "type": "boolean"
+                }
+            }
+        },
+        "services.CompetenceStatus": {
+            "type": "object",
+            "properties": {
+                "closed_at": {
                     "type": "string"
                 },
-                "restricted": {
-                    "description": "Configurações do sistema",
+                "closed_by": {
+                    "type": "integer"
+                },
+                "competence": {
+                    "type": "string"
+                },
+                "gaps": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.ProviderNumberingGaps"
+                    }
+                },
+                "late_arrivals": {
+                    "type": "integer"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "services.CompetenceSummary": {
+            "type": "object",
+            "properties": {
+                "competence": {
+                    "type": "string"
+                },
+                "documents": {
+                    "type": "integer"
+                },
+                "total_value": {
+                    "type": "number"
+                }
+            }
+        },
+        "services.ConsolidatedObject": {
+            "type": "object",
+            "properties": {
+                "document_id": {
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "from": {
+                    "type": "string"
+                },
+                "moved": {
                     "type": "boolean"
                 },
-                "secondary_activity": {
-                    "description": "Atividades secundárias",
+                "to": {
                     "type": "string"
+                }
+            }
+        },
+        "services.ConsultationDetail": {
+            "type": "object",
+            "properties": {
+                "document_id": {
+                    "type": "integer"
                 },
-                "state": {
+                "duplicate_reason": {
                     "type": "string"
                 },
-                "trade_name": {
-                    "description": "Nome fantasia",
+                "error": {
                     "type": "string"
                 },
-                "zip_code": {
+                "file_name": {
+                    "type": "string"
+                },
+                "status": {
+                    "description": "stored, duplicate, overwritten or error",
                     "type": "string"
                 }
             }
         },
-        "handlers.CreateUserRequest": {
+        "services.ConsultationResult": {
             "type": "object",
-            "required": [
-                "email",
-                "name",
-                "password",
-                "role"
-            ],
             "properties": {
-                "email": {
+                "competence": {
                     "type": "string"
                 },
-                "name": {
-                    "type": "string",
-                    "maxLength": 255,
-                    "minLength": 2
+                "details": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.ConsultationDetail"
+                    }
                 },
-                "password": {
-                    "description": "Senha obrigatória para frontend",
-                    "type": "string",
-                    "minLength": 8
+                "details_truncated": {
+                    "type": "boolean"
                 },
-                "role": {
-                    "type": "string",
-                    "enum": [
-                        "admin",
-                        "user"
-                    ]
+                "documents_found": {
+                    "type": "integer"
                 },
-                "token": {
-                    "description": "Token opcional - se não fornecido, será gerado automaticamente",
+                "duplicate_documents": {
+                    "type": "integer"
+                },
+                "duration_ms": {
+                    "type": "integer"
+                },
+                "end_date": {
+                    "type": "string"
+                },
+                "error_documents": {
+                    "type": "integer"
+                },
+                "pages": {
+                    "type": "integer"
+                },
+                "start_date": {
                     "type": "string"
+                },
+                "stored_documents": {
+                    "type": "integer"
                 }
             }
         },
-        "handlers.SwaggerCompaniesResponse": {
+        "services.CredentialTypeSummary": {
+            "type": "object",
+            "properties": {
+                "active": {
+                    "type": "integer"
+                },
+                "total": {
+                    "type": "integer"
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "services.CredentialsOverview": {
+            "type": "object",
+            "properties": {
+                "active": {
+                    "type": "integer"
+                },
+                "by_type": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.CredentialTypeSummary"
+                    }
+                },
+                "last_used_at": {
+                    "type": "string"
+                },
+                "total": {
+                    "type": "integer"
+                },
+                "usable": {
+                    "type": "integer"
+                }
+            }
+        },
+        "services.DocumentUsage": {
+            "type": "object",
+            "properties": {
+                "documents": {
+                    "type": "integer"
+                },
+                "limit": {
+                    "description": "0 = no limit",
+                    "type": "integer"
+                },
+                "policy": {
+                    "type": "string"
+                },
+                "remaining": {
+                    "description": "room left below the limit; 0 without a limit",
+                    "type": "integer"
+                }
+            }
+        },
+        "services.DocumentsOverview": {
+            "type": "object",
+            "properties": {
+                "cancelled": {
+                    "type": "integer"
+                },
+                "last_issue_date": {
+                    "type": "string"
+                },
+                "total": {
+                    "type": "integer"
+                },
+                "total_value": {
+                    "type": "number"
+                }
+            }
+        },
+        "services.DuplicateMergeReport": {
             "type": "object",
             "properties": {
-                "companies": {
+                "company_id": {
+                    "type": "integer"
+                },
+                "dry_run": {
+                    "type": "boolean"
+                },
+                "groups": {
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/handlers.SwaggerCompany"
+                        "$ref": "#/definitions/services.MergedDuplicateGroup"
                     }
                 },
-                "pagination": {
-                    "$ref": "#/definitions/handlers.SwaggerPagination"
+                "removed_documents": {
+                    "type": "integer"
+                },
+                "removed_objects": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
                 }
             }
         },
-        "handlers.SwaggerCompany": {
+        "services.DuplicatePreview": {
             "type": "object",
             "properties": {
-                "active": {
-                    "type": "boolean",
-                    "example": true
-                },
-                "address": {
-                    "description": "Endereço",
-                    "type": "string",
-                    "example": "R PERNAMBUCO"
+                "access_key": {
+                    "type": "string"
                 },
-                "auto_fetch": {
-                    "type": "boolean",
-                    "example": false
+                "check_method": {
+                    "type": "string"
                 },
-                "city": {
-                    "type": "string",
-                    "example": "IMPERATRIZ"
+                "document_type": {
+                    "type": "string"
                 },
-                "cnpj": {
-                    "type": "string",
-                    "example": "34.194.865/0001-58"
+                "existing_document_id": {
+                    "type": "integer"
                 },
-                "company_size": {
-                    "description": "Dados empresariais",
-                    "type": "string",
-                    "example": "ME"
+                "existing_storage_key": {
+                    "type": "string"
                 },
-                "complement": {
-                    "type": "string",
-                    "example": "LETRA A"
+                "is_duplicate": {
+                    "type": "boolean"
                 },
-                "created_at": {
-                    "type": "string",
-                    "example": "2025-08-17T19:01:44Z"
+                "number": {
+                    "type": "string"
                 },
-                "district": {
-                    "type": "string",
-                    "example": "CENTRO"
+                "provider_cnpj": {
+                    "type": "string"
                 },
-                "email": {
-                    "type": "string",
-                    "example": "contato@multicar.com"
+                "reason": {
+                    "type": "string"
                 },
-                "id": {
-                    "type": "integer",
-                    "example": 1
+                "verification_code": {
+                    "type": "string"
+                }
+            }
+        },
+        "services.FieldMismatch": {
+            "type": "object",
+            "properties": {
+                "field": {
+                    "type": "string"
                 },
-                "legal_nature": {
-                    "type": "string",
-                    "example": "213-5 - Empresário (Individual)"
+                "provider": {
+                    "type": "string"
                 },
-                "main_activity": {
-                    "type": "string",
-                    "example": "45.12-9-02 - Comércio sob consignação de veículos automotores"
+                "stored": {
+                    "type": "string"
+                }
+            }
+        },
+        "services.MergedDuplicateGroup": {
+            "type": "object",
+            "properties": {
+                "kept_id": {
+                    "type": "integer"
                 },
-                "name": {
-                    "type": "string",
-                    "example": "S. E. L. DE SOUZA SUARES VEICULOS"
+                "matched_by": {
+                    "description": "verification_code or provider_number",
+                    "type": "string"
                 },
-                "number": {
-                    "type": "string",
-                    "example": "757"
+                "removed_ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                }
+            }
+        },
+        "services.NFSeDocument": {
+            "type": "object",
+            "properties": {
+                "competence": {
+                    "description": "Competência informada pelo provedor (YYYY-MM)",
+                    "type": "string"
                 },
-                "opening_date": {
-                    "type": "string",
-                    "example": "12/07/2019"
+                "file_name": {
+                    "description": "Nome do arquivo XML",
+                    "type": "string"
                 },
-                "phone": {
-                    "description": "Contato",
-                    "type": "string",
-                    "example": "(99) 9177-7746"
+                "processed_at": {
+                    "type": "string"
                 },
-                "registration_status": {
-                    "type": "string",
-                    "example": "ATIVA"
+                "xml_content": {
+                    "description": "Conteúdo XML",
+                    "type": "string"
+                }
+            }
+        },
+        "services.ObjectRestoreReport": {
+            "type": "object",
+            "properties": {
+                "checked": {
+                    "type": "integer"
                 },
-                "restricted": {
-                    "description": "Configurações",
-                    "type": "boolean",
-                    "example": false
+                "company_id": {
+                    "type": "integer"
                 },
-                "secondary_activity": {
-                    "type": "string",
-                    "example": "45.11-1-02 - Comércio a varejo de automóveis, camionetas e utilitários usados"
+                "dry_run": {
+                    "type": "boolean"
                 },
-                "state": {
-                    "type": "string",
-                    "example": "MA"
+                "failed": {
+                    "type": "integer"
                 },
-                "trade_name": {
-                    "type": "string",
-                    "example": "MULTICAR VEICULOS"
+                "missing": {
+                    "type": "integer"
                 },
-                "updated_at": {
-                    "type": "string",
-                    "example": "2025-08-17T19:01:44Z"
+                "objects": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.RestoredObject"
+                    }
                 },
-                "zip_code": {
-                    "type": "string",
-                    "example": "65.903-320"
-                }
-            }
-        },
-        "handlers.SwaggerError": {
-            "type": "object",
-            "properties": {
-                "error": {
-                    "type": "string",
-                    "example": "Mensagem de erro"
+                "restored": {
+                    "type": "integer"
                 }
             }
         },
-        "handlers.SwaggerPagination": {
+        "services.PendingWorkOverview": {
             "type": "object",
             "properties": {
-                "limit": {
-                    "type": "integer",
-                    "example": 20
+                "dead_letters": {
+                    "type": "integer"
                 },
-                "page": {
-                    "type": "integer",
-                    "example": 1
+                "pending_ingests": {
+                    "type": "integer"
+                },
+                "reprocess_batch_id": {
+                    "description": "unfinished reprocess batch, if any",
+                    "type": "integer"
                 },
                 "total": {
-                    "type": "integer",
-                    "example": 100
+                    "type": "integer"
                 }
             }
         },
-        "handlers.SwaggerUser": {
+        "services.ProviderNumberingGaps": {
             "type": "object",
             "properties": {
-                "active": {
-                    "type": "boolean",
-                    "example": true
+                "max_number": {
+                    "type": "integer"
                 },
-                "created_at": {
-                    "type": "string",
-                    "example": "2025-08-17T19:01:44Z"
+                "min_number": {
+                    "type": "integer"
                 },
-                "email": {
-                    "type": "string",
-                    "example": "joao@exemplo.com"
+                "missing": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
                 },
-                "id": {
-                    "type": "integer",
-                    "example": 1
+                "missing_count": {
+                    "type": "integer"
                 },
-                "name": {
-                    "type": "string",
-                    "example": "João Silva"
+                "non_numeric_numbers": {
+                    "type": "integer"
                 },
-                "role": {
-                    "type": "string",
-                    "example": "user"
+                "observed_documents": {
+                    "type": "integer"
                 },
-                "updated_at": {
-                    "type": "string",
-                    "example": "2025-08-17T19:01:44Z"
+                "provider_cnpj": {
+                    "type": "string"
+                },
+                "truncated": {
+                    "type": "boolean"
                 }
             }
         },
-        "handlers.SwaggerUserWithToken": {
+        "services.RestoredObject": {
             "type": "object",
             "properties": {
-                "active": {
-                    "type": "boolean",
-                    "example": true
+                "document_id": {
+                    "type": "integer"
                 },
-                "created_at": {
-                    "type": "string",
-                    "example": "2025-08-17T19:01:44Z"
+                "error": {
+                    "type": "string"
                 },
-                "email": {
-                    "type": "string",
-                    "example": "joao@exemplo.com"
+                "restored": {
+                    "type": "boolean"
                 },
-                "id": {
-                    "type": "integer",
-                    "example": 1
+                "storage_key": {
+                    "type": "string"
+                }
+            }
+        },
+        "services.SyncOverview": {
+            "type": "object",
+            "properties": {
+                "auto_fetch": {
+                    "type": "boolean"
                 },
-                "name": {
-                    "type": "string",
-                    "example": "João Silva"
+                "interval": {
+                    "type": "string"
                 },
-                "role": {
-                    "type": "string",
-                    "example": "user"
+                "last_sync_at": {
+                    "type": "string"
                 },
-                "token": {
-                    "type": "string",
-                    "example": "U6HGHy4SDK"
+                "last_sync_error": {
+                    "type": "string"
                 },
-                "updated_at": {
-                    "type": "string",
-                    "example": "2025-08-17T19:01:44Z"
+                "last_sync_status": {
+                    "type": "string"
+                },
+                "next_run_at": {
+                    "type": "string"
+                },
+                "scheduler_enabled": {
+                    "type": "boolean"
                 }
             }
         },
-        "handlers.SwaggerUsersResponse": {
+        "services.VerificationReport": {
             "type": "object",
             "properties": {
-                "pagination": {
-                    "$ref": "#/definitions/handlers.SwaggerPagination"
+                "checked_at": {
+                    "type": "string"
                 },
-                "users": {
+                "document_id": {
+                    "type": "integer"
+                },
+                "mismatches": {
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/handlers.SwaggerUser"
-                    }
-                }
-            }
-        },
-        "handlers.SwaggerValidationError": {
-            "type": "object",
-            "properties": {
-                "details": {
-                    "type": "object",
-                    "additionalProperties": {
-                        "type": "string"
-                    },
-                    "example": {
-                        "{\"email\"": "\"email is required\"}"
+                        "$ref": "#/definitions/services.FieldMismatch"
                     }
                 },
-                "error": {
-                    "type": "string",
-                    "example": "Validation failed"
+                "number": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "verification_code": {
+                    "type": "string"
                 }
             }
         }
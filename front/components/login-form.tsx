@@ -89,7 +89,7 @@ export function LoginForm({
       const loginResponse = await login(formData as LoginCredentials)
 
       // Store token and user data
-      setAuthToken(loginResponse.token)
+      setAuthToken(loginResponse.access_token ?? loginResponse.token ?? "")
       setCurrentUser({
         id: loginResponse.id,
         name: loginResponse.name,
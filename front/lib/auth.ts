@@ -16,9 +16,13 @@ export interface LoginResponse {
   email: string
   role: string
   active: boolean
-  token: string
+  token?: string // permanent token, only returned when refresh tokens are disabled
   created_at: string
   updated_at: string
+  access_token?: string
+  access_token_expires_at?: string
+  refresh_token?: string
+  refresh_token_expires_at?: string
 }
 
 export interface ApiError {
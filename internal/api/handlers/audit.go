@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"encoding/json"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/logger"
+	"github.com/zoomxml/internal/models"
+)
+
+// recordAudit registra uma ação do usuário na tabela de auditoria.
+// Falhas são apenas logadas para não interromper a requisição.
+func recordAudit(c *fiber.Ctx, user *models.User, action, entity string, entityID int64, details map[string]any) {
+	auditLog := &models.AuditLog{
+		ActorID:   user.ID,
+		Action:    action,
+		Entity:    entity,
+		EntityID:  entityID,
+		IPAddress: c.IP(),
+		UserAgent: c.Get(fiber.HeaderUserAgent),
+	}
+
+	if details != nil {
+		data, err := json.Marshal(details)
+		if err == nil {
+			auditLog.Details = string(data)
+		}
+	}
+
+	if _, err := database.DB.NewInsert().Model(auditLog).Exec(c.Context()); err != nil {
+		logger.ErrorWithFields("Failed to record audit log", err, map[string]any{
+			"operation": "record_audit",
+			"user_id":   user.ID,
+			"action":    action,
+			"entity":    entity,
+		})
+	}
+}
@@ -0,0 +1,252 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/uptrace/bun"
+	"github.com/zoomxml/internal/api/middleware"
+	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/logger"
+	"github.com/zoomxml/internal/models"
+	"github.com/zoomxml/internal/services"
+)
+
+// auditExportBatchSize é o número de registros lidos por consulta durante a exportação
+const auditExportBatchSize = 1000
+
+// auditSensitiveKeys são trechos de chaves de details cujos valores são ocultados na exportação
+var auditSensitiveKeys = []string{"password", "senha", "token", "secret", "authorization", "api_key", "xml_content"}
+
+// AuditHandler gerencia as rotas de auditoria
+type AuditHandler struct{}
+
+// NewAuditHandler cria uma nova instância do handler de auditoria
+func NewAuditHandler() *AuditHandler {
+	return &AuditHandler{}
+}
+
+// auditExportFilter são os filtros da exportação de auditoria
+type auditExportFilter struct {
+	From     time.Time
+	To       time.Time
+	ActorID  int64
+	Entity   string
+	Action   string
+	EntityID int64
+}
+
+// apply aplica os filtros à consulta de audit_logs
+func (f auditExportFilter) apply(query *bun.SelectQuery) *bun.SelectQuery {
+	if !f.From.IsZero() {
+		query = query.Where("created_at >= ?", f.From)
+	}
+	if !f.To.IsZero() {
+		query = query.Where("created_at < ?", f.To)
+	}
+	if f.ActorID > 0 {
+		query = query.Where("actor_id = ?", f.ActorID)
+	}
+	if f.Entity != "" {
+		query = query.Where("entity = ?", f.Entity)
+	}
+	if f.Action != "" {
+		query = query.Where("action = ?", strings.ToUpper(f.Action))
+	}
+	if f.EntityID > 0 {
+		query = query.Where("entity_id = ?", f.EntityID)
+	}
+	return query
+}
+
+// ExportAuditLogs exporta os logs de auditoria em CSV (apenas admin)
+// @Summary Exportar auditoria em CSV
+// @Description Exporta os logs de auditoria em CSV, com filtros por período, autor e entidade. Valores sensíveis dos detalhes são ocultados. A exportação também é auditada.
+// @Tags audit
+// @Produce text/csv
+// @Param from query string false "Data inicial (YYYY-MM-DD)"
+// @Param to query string false "Data final, inclusiva (YYYY-MM-DD)"
+// @Param actor_id query int false "ID do usuário autor"
+// @Param entity query string false "Entidade (ex: Document, Company)"
+// @Param entity_id query int false "ID da entidade"
+// @Param action query string false "Ação (CREATE, UPDATE, DELETE)"
+// @Success 200 {string} string "CSV"
+// @Failure 400 {object} SwaggerError "Filtro inválido"
+// @Failure 401 {object} SwaggerError "Autenticação necessária"
+// @Failure 403 {object} SwaggerError "Apenas administradores"
+// @Security UserToken
+// @Router /admin/audit-logs/export [get]
+func (h *AuditHandler) ExportAuditLogs(c *fiber.Ctx) error {
+	user := middleware.GetUserFromContext(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Authentication required",
+		})
+	}
+
+	filter := auditExportFilter{
+		Entity: c.Query("entity"),
+		Action: c.Query("action"),
+	}
+
+	if from := c.Query("from"); from != "" {
+		parsed, err := time.Parse("2006-01-02", from)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid from date, expected YYYY-MM-DD",
+			})
+		}
+		filter.From = parsed
+	}
+
+	if to := c.Query("to"); to != "" {
+		parsed, err := time.Parse("2006-01-02", to)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid to date, expected YYYY-MM-DD",
+			})
+		}
+		filter.To = parsed.AddDate(0, 0, 1)
+	}
+
+	for param, target := range map[string]*int64{"actor_id": &filter.ActorID, "entity_id": &filter.EntityID} {
+		if value := c.Query(param); value != "" {
+			parsed, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || parsed < 1 {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "Invalid " + param,
+				})
+			}
+			*target = parsed
+		}
+	}
+
+	// A própria exportação fica registrada na auditoria
+	recordAudit(c, user, "EXPORT", "AuditLog", 0, map[string]any{
+		"from":      c.Query("from"),
+		"to":        c.Query("to"),
+		"actor_id":  filter.ActorID,
+		"entity":    filter.Entity,
+		"entity_id": filter.EntityID,
+		"action":    filter.Action,
+	})
+
+	c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="audit_logs_`+time.Now().Format("20060102150405")+`.csv"`)
+
+	// O corpo é gerado depois que o handler retorna, então não usa o contexto da requisição
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		writeAuditLogsCSV(context.Background(), w, filter, user.ID)
+	})
+
+	return nil
+}
+
+// writeAuditLogsCSV escreve os logs filtrados em CSV, lendo em lotes por ID
+func writeAuditLogsCSV(ctx context.Context, w *bufio.Writer, filter auditExportFilter, userID int64) {
+	writer := csv.NewWriter(w)
+	_ = writer.Write([]string{"id", "created_at", "actor_id", "action", "entity", "entity_id", "ip_address", "user_agent", "details"})
+
+	lastID := int64(0)
+	rows := 0
+	for {
+		logs := []models.AuditLog{}
+		err := filter.apply(database.DB.NewSelect().Model(&logs)).
+			Where("id > ?", lastID).
+			Order("id ASC").
+			Limit(auditExportBatchSize).
+			Scan(ctx)
+
+		if err != nil {
+			logger.ErrorWithFields("Failed to export audit logs", err, map[string]any{
+				"operation": "export_audit_logs",
+				"user_id":   userID,
+				"rows":      rows,
+			})
+			break
+		}
+
+		for _, log := range logs {
+			_ = writer.Write(services.CSVSafeRecord([]string{
+				strconv.FormatInt(log.ID, 10),
+				log.CreatedAt.UTC().Format(time.RFC3339),
+				strconv.FormatInt(log.ActorID, 10),
+				log.Action,
+				log.Entity,
+				strconv.FormatInt(log.EntityID, 10),
+				log.IPAddress,
+				log.UserAgent,
+				redactAuditDetails(log.Details),
+			}))
+			lastID = log.ID
+		}
+		rows += len(logs)
+
+		writer.Flush()
+		if err := w.Flush(); err != nil || len(logs) < auditExportBatchSize {
+			break
+		}
+	}
+
+	logger.InfoWithFields("Audit logs exported", map[string]any{
+		"operation": "export_audit_logs",
+		"user_id":   userID,
+		"rows":      rows,
+	})
+}
+
+// redactAuditDetails oculta os valores sensíveis do JSON de detalhes; detalhes inválidos
+// são substituídos por um marcador
+func redactAuditDetails(details string) string {
+	if details == "" {
+		return ""
+	}
+
+	var data any
+	if err := json.Unmarshal([]byte(details), &data); err != nil {
+		// Detalhes que não são JSON não podem ser inspecionados, então não são exportados
+		return "[UNREADABLE]"
+	}
+
+	redacted, err := json.Marshal(redactValue(data))
+	if err != nil {
+		return ""
+	}
+	return string(redacted)
+}
+
+// redactValue percorre o JSON ocultando os valores de chaves sensíveis
+func redactValue(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			if isSensitiveAuditKey(key) {
+				v[key] = "[REDACTED]"
+			} else {
+				v[key] = redactValue(item)
+			}
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = redactValue(item)
+		}
+	}
+	return value
+}
+
+// isSensitiveAuditKey informa se a chave contém algum trecho sensível
+func isSensitiveAuditKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, sensitive := range auditSensitiveKeys {
+		if strings.Contains(key, sensitive) {
+			return true
+		}
+	}
+	return false
+}
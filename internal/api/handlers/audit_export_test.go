@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/zoomxml/internal/api/middleware"
+	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/database/databasetest"
+	"github.com/zoomxml/internal/models"
+)
+
+func TestRedactAuditDetails(t *testing.T) {
+	tests := []struct {
+		name    string
+		details string
+		want    string
+	}{
+		{"empty", "", ""},
+		{"nothing sensitive", `{"company_id":1,"action":"create"}`, `{"action":"create","company_id":1}`},
+		{"sensitive keys", `{"password":"x","Access_Token":"y","name":"z"}`, `{"Access_Token":"[REDACTED]","name":"z","password":"[REDACTED]"}`},
+		{"nested objects", `{"credential":{"client_secret":"x","type":"token"}}`, `{"credential":{"client_secret":"[REDACTED]","type":"token"}}`},
+		{"arrays", `{"items":[{"senha":"x"},{"id":2}]}`, `{"items":[{"senha":"[REDACTED]"},{"id":2}]}`},
+		{"sensitive object", `{"authorization":{"scheme":"Bearer"}}`, `{"authorization":"[REDACTED]"}`},
+		{"not JSON", "password=x", "[UNREADABLE]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := redactAuditDetails(tt.details); got != tt.want {
+				t.Errorf("redactAuditDetails(%q) = %s, want %s", tt.details, got, tt.want)
+			}
+		})
+	}
+}
+
+// exportCSV runs writeAuditLogsCSV with filter and returns the parsed records
+func exportCSV(t *testing.T, filter auditExportFilter) [][]string {
+	t.Helper()
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+	writeAuditLogsCSV(context.Background(), w, filter, 1)
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("export is not valid CSV: %v", err)
+	}
+	return records
+}
+
+var auditCSVHeader = []string{"id", "created_at", "actor_id", "action", "entity", "entity_id", "ip_address", "user_agent", "details"}
+
+// TestWriteAuditLogsCSVHeader checks that the header is written even when the query fails
+func TestWriteAuditLogsCSVHeader(t *testing.T) {
+	databasetest.UseClosed(t)
+
+	records := exportCSV(t, auditExportFilter{})
+	if len(records) != 1 {
+		t.Fatalf("export = %d records, want only the header", len(records))
+	}
+	if !reflect.DeepEqual(records[0], auditCSVHeader) {
+		t.Errorf("header = %v, want %v", records[0], auditCSVHeader)
+	}
+}
+
+func TestExportAuditLogsInvalidFilters(t *testing.T) {
+	databasetest.UseClosed(t)
+	admin := &models.User{ID: 1, Role: "admin", Active: true}
+
+	for _, query := range []string{"from=2025-13-01", "to=yesterday", "actor_id=abc", "entity_id=0"} {
+		t.Run(query, func(t *testing.T) {
+			app := fiber.New()
+			app.Get("/export", func(c *fiber.Ctx) error {
+				c.Locals(string(middleware.UserKey), admin)
+				return c.Next()
+			}, NewAuditHandler().ExportAuditLogs)
+
+			resp, err := app.Test(httptest.NewRequest("GET", "/export?"+query, nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != fiber.StatusBadRequest {
+				t.Errorf("ExportAuditLogs(%s) status = %d, want %d", query, resp.StatusCode, fiber.StatusBadRequest)
+			}
+		})
+	}
+}
+
+func TestWriteAuditLogsCSVFilters(t *testing.T) {
+	databasetest.Require(t)
+	ctx := context.Background()
+
+	// A per-run entity keeps rows written by other tests out of the export
+	entity := fmt.Sprintf("ExportTest%d", time.Now().UnixNano())
+	alice := databasetest.CreateUser(t, "user")
+	bob := databasetest.CreateUser(t, "user")
+	day := func(d int) time.Time { return time.Date(2025, 3, d, 12, 0, 0, 0, time.UTC) }
+
+	logs := []struct {
+		actor    *models.User
+		action   string
+		entityID int64
+		at       time.Time
+	}{
+		{alice, "CREATE", 1, day(1)},
+		{alice, "UPDATE", 1, day(10)},
+		{bob, "UPDATE", 2, day(10)},
+		{bob, "DELETE", 2, day(20)},
+	}
+	for _, l := range logs {
+		log := &models.AuditLog{ActorID: l.actor.ID, Action: l.action, Entity: entity, EntityID: l.entityID, Details: `{"password":"secret"}`}
+		if _, err := database.DB.NewInsert().Model(log).Exec(ctx); err != nil {
+			t.Fatalf("failed to create audit log: %v", err)
+		}
+		// The insert hook stamps the current time
+		if _, err := database.DB.NewUpdate().Model(log).Set("created_at = ?", l.at).WherePK().Exec(ctx); err != nil {
+			t.Fatalf("failed to date audit log: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		filter   auditExportFilter
+		wantRows int
+	}{
+		{"entity only", auditExportFilter{}, 4},
+		{"actor", auditExportFilter{ActorID: alice.ID}, 2},
+		{"action", auditExportFilter{Action: "update"}, 2},
+		{"entity id", auditExportFilter{EntityID: 2}, 2},
+		{"from", auditExportFilter{From: day(10)}, 3},
+		{"to is exclusive", auditExportFilter{To: day(10)}, 1},
+		{"period and actor", auditExportFilter{From: day(5), To: day(15), ActorID: bob.ID}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.filter.Entity = entity
+			records := exportCSV(t, tt.filter)
+			if len(records) == 0 || !reflect.DeepEqual(records[0], auditCSVHeader) {
+				t.Fatalf("export does not start with the header: %v", records)
+			}
+			if rows := len(records) - 1; rows != tt.wantRows {
+				t.Errorf("export = %d rows, want %d", rows, tt.wantRows)
+			}
+			for _, record := range records[1:] {
+				if len(record) != len(auditCSVHeader) {
+					t.Errorf("row %v has %d columns, want %d", record, len(record), len(auditCSVHeader))
+				}
+				if record[4] != entity {
+					t.Errorf("row entity = %s, want %s", record[4], entity)
+				}
+				if record[8] != `{"password":"[REDACTED]"}` {
+					t.Errorf("row details = %s, want the password redacted", record[8])
+				}
+			}
+		})
+	}
+}
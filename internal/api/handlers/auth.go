@@ -1,9 +1,14 @@
 package handlers
 
 import (
+	"errors"
+
 	"github.com/gofiber/fiber/v2"
+	"github.com/zoomxml/config"
 	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/logger"
 	"github.com/zoomxml/internal/models"
+	"github.com/zoomxml/internal/services"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -28,9 +33,30 @@ type LoginResponse struct {
 	Email     string `json:"email"`
 	Role      string `json:"role"`
 	Active    bool   `json:"active"`
-	Token     string `json:"token"`
+	Token     string `json:"token,omitempty"` // token permanente; omitido com ENABLE_REFRESH_TOKENS
 	CreatedAt string `json:"created_at"`
 	UpdatedAt string `json:"updated_at"`
+
+	// Refresh token e token de acesso de curta duração, emitidos no lugar do token
+	// permanente quando ENABLE_REFRESH_TOKENS está ativo
+	RefreshToken          string `json:"refresh_token,omitempty"`
+	RefreshTokenExpiresAt string `json:"refresh_token_expires_at,omitempty"`
+	AccessToken           string `json:"access_token,omitempty"`
+	AccessTokenExpiresAt  string `json:"access_token_expires_at,omitempty"`
+}
+
+// RefreshRequest representa a requisição de troca de refresh token
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+// RefreshResponse representa a resposta da troca de refresh token. O token permanente do
+// usuário não é devolvido: a sessão segue com o token de acesso de curta duração.
+type RefreshResponse struct {
+	AccessToken           string `json:"access_token"`
+	AccessTokenExpiresAt  string `json:"access_token_expires_at"`
+	RefreshToken          string `json:"refresh_token"`
+	RefreshTokenExpiresAt string `json:"refresh_token_expires_at"`
 }
 
 // checkPassword verifica se a senha fornecida corresponde ao hash
@@ -77,7 +103,83 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 	}
 
 	// Retornar dados do usuário com token
-	response := LoginResponse{
+	response := newLoginResponse(user)
+
+	if config.Get().Auth.EnableRefreshTokens {
+		issued, err := services.IssueRefreshToken(c.Context(), database.DB, user.ID)
+		if err != nil {
+			logger.ErrorWithFields("Failed to issue refresh token", err, map[string]any{
+				"operation": "login",
+				"user_id":   user.ID,
+			})
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to issue refresh token",
+			})
+		}
+		// O token permanente não é devolvido: uma resposta de login vazada não pode valer
+		// para sempre, ou rotação e revogação não protegeriam nada
+		response.Token = ""
+		setRefreshToken(&response, issued)
+	}
+
+	return respondData(c, fiber.StatusOK, response)
+}
+
+// Refresh troca um refresh token válido por um novo (o usado é revogado, e com ele o seu
+// token de acesso) e devolve um token de acesso de curta duração. Reapresentar um token já
+// trocado encerra todas as sessões do usuário, inclusive o token permanente.
+func (h *AuthHandler) Refresh(c *fiber.Ctx) error {
+	if !config.Get().Auth.EnableRefreshTokens {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Refresh tokens are disabled",
+		})
+	}
+
+	var req RefreshRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	// Validar entrada
+	if err := validateStruct(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": err,
+		})
+	}
+
+	_, issued, err := services.RotateRefreshToken(c.Context(), req.RefreshToken)
+	if err != nil {
+		if errors.Is(err, services.ErrRefreshTokenReused) {
+			logger.LogSecurityEvent(c.Context(), nil, "refresh_token_reused", "a rotated refresh token was presented again; the user's refresh tokens and API token were revoked")
+		}
+		if errors.Is(err, services.ErrInvalidRefreshToken) || errors.Is(err, services.ErrRefreshTokenReused) {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "Invalid or expired refresh token",
+			})
+		}
+
+		logger.ErrorWithFields("Failed to rotate refresh token", err, map[string]any{
+			"operation": "refresh_token",
+		})
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to refresh session",
+		})
+	}
+
+	return respondData(c, fiber.StatusOK, RefreshResponse{
+		AccessToken:           issued.AccessToken,
+		AccessTokenExpiresAt:  issued.AccessExpiresAt.Format("2006-01-02T15:04:05Z07:00"),
+		RefreshToken:          issued.Token,
+		RefreshTokenExpiresAt: issued.ExpiresAt.Format("2006-01-02T15:04:05Z07:00"),
+	})
+}
+
+// newLoginResponse monta a resposta de login com os dados e o token de acesso do usuário
+func newLoginResponse(user *models.User) LoginResponse {
+	return LoginResponse{
 		ID:        user.ID,
 		Name:      user.Name,
 		Email:     user.Email,
@@ -87,8 +189,14 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 		CreatedAt: user.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
 		UpdatedAt: user.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
 	}
+}
 
-	return c.JSON(response)
+// setRefreshToken inclui o refresh token emitido e seu token de acesso na resposta
+func setRefreshToken(response *LoginResponse, issued *services.IssuedRefreshToken) {
+	response.RefreshToken = issued.Token
+	response.RefreshTokenExpiresAt = issued.ExpiresAt.Format("2006-01-02T15:04:05Z07:00")
+	response.AccessToken = issued.AccessToken
+	response.AccessTokenExpiresAt = issued.AccessExpiresAt.Format("2006-01-02T15:04:05Z07:00")
 }
 
 // Logout invalida o token do usuário (opcional - regenera o token)
@@ -117,7 +225,14 @@ func (h *AuthHandler) Logout(c *fiber.Ctx) error {
 		})
 	}
 
-	return c.JSON(fiber.Map{
+	// Sessões abertas por refresh token também são encerradas
+	if err := services.RevokeUserRefreshTokens(c.Context(), user.ID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to logout",
+		})
+	}
+
+	return respondData(c, fiber.StatusOK, fiber.Map{
 		"message": "Logout successful",
 	})
 }
@@ -132,5 +247,5 @@ func (h *AuthHandler) GetProfile(c *fiber.Ctx) error {
 		})
 	}
 
-	return c.JSON(user)
+	return respondData(c, fiber.StatusOK, user)
 }
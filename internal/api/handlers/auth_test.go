@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/zoomxml/config"
+	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/database/databasetest"
+	"github.com/zoomxml/internal/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// setRefreshTokens turns ENABLE_REFRESH_TOKENS on or off while the test runs
+func setRefreshTokens(t *testing.T, enabled bool) {
+	t.Helper()
+	cfg := &config.Get().Auth
+	previous := cfg.EnableRefreshTokens
+	cfg.EnableRefreshTokens = enabled
+	t.Cleanup(func() { cfg.EnableRefreshTokens = previous })
+}
+
+// postJSON sends body to path of app and decodes a successful JSON response into out,
+// returning the status
+func postJSON(t *testing.T, app *fiber.App, path, body string, out any) int {
+	t.Helper()
+	req := httptest.NewRequest("POST", path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if out != nil && resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return resp.StatusCode
+}
+
+// createLoginUser inserts an active user that logs in with password
+func createLoginUser(t *testing.T, password string) *models.User {
+	t.Helper()
+	user := databasetest.CreateUser(t, "user")
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = database.DB.NewUpdate().Model((*models.User)(nil)).Set("password = ?", string(hash)).Where("id = ?", user.ID).Exec(context.Background())
+	if err != nil {
+		t.Fatalf("failed to set password: %v", err)
+	}
+	return user
+}
+
+func TestRefreshRequestValidation(t *testing.T) {
+	app := fiber.New()
+	app.Post("/refresh", NewAuthHandler().Refresh)
+
+	setRefreshTokens(t, false)
+	if status := postJSON(t, app, "/refresh", `{"refresh_token":"x"}`, nil); status != fiber.StatusNotFound {
+		t.Errorf("refresh with refresh tokens disabled status = %d, want %d", status, fiber.StatusNotFound)
+	}
+
+	setRefreshTokens(t, true)
+	if status := postJSON(t, app, "/refresh", `{}`, nil); status != fiber.StatusBadRequest {
+		t.Errorf("refresh without token status = %d, want %d", status, fiber.StatusBadRequest)
+	}
+}
+
+func TestLoginTokens(t *testing.T) {
+	databasetest.Require(t)
+	const password = "login-secret"
+
+	tests := []struct {
+		name          string
+		refreshTokens bool
+	}{
+		{"refresh tokens enabled", true},
+		{"refresh tokens disabled", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setRefreshTokens(t, tt.refreshTokens)
+			user := createLoginUser(t, password)
+			app := fiber.New()
+			app.Post("/login", NewAuthHandler().Login)
+
+			var response LoginResponse
+			body := `{"email":"` + user.Email + `","password":"` + password + `"}`
+			if status := postJSON(t, app, "/login", body, &response); status != fiber.StatusOK {
+				t.Fatalf("login status = %d, want %d", status, fiber.StatusOK)
+			}
+
+			// The permanent token is only handed out without refresh tokens
+			if tt.refreshTokens {
+				if response.Token != "" {
+					t.Errorf("login returned the permanent token %q with refresh tokens enabled", response.Token)
+				}
+				if response.AccessToken == "" || response.RefreshToken == "" {
+					t.Errorf("login = %+v, want an access and a refresh token", response)
+				}
+			} else {
+				if response.Token != user.Token {
+					t.Errorf("login token = %q, want %q", response.Token, user.Token)
+				}
+				if response.AccessToken != "" || response.RefreshToken != "" {
+					t.Errorf("login = %+v, want no access or refresh token", response)
+				}
+			}
+
+			if status := postJSON(t, app, "/login", `{"email":"`+user.Email+`","password":"wrong"}`, nil); status != fiber.StatusUnauthorized {
+				t.Errorf("login with a wrong password status = %d, want %d", status, fiber.StatusUnauthorized)
+			}
+		})
+	}
+}
+
+func TestRefreshRotationAndRevocation(t *testing.T) {
+	databasetest.Require(t)
+	setRefreshTokens(t, true)
+	const password = "refresh-secret"
+	user := createLoginUser(t, password)
+
+	app := fiber.New()
+	handler := NewAuthHandler()
+	app.Post("/login", handler.Login)
+	app.Post("/refresh", handler.Refresh)
+	app.Post("/logout", func(c *fiber.Ctx) error {
+		c.Locals("user", user)
+		return c.Next()
+	}, handler.Logout)
+
+	login := func() LoginResponse {
+		t.Helper()
+		var response LoginResponse
+		body := `{"email":"` + user.Email + `","password":"` + password + `"}`
+		if status := postJSON(t, app, "/login", body, &response); status != fiber.StatusOK {
+			t.Fatalf("login status = %d, want %d", status, fiber.StatusOK)
+		}
+		return response
+	}
+	refresh := func(token string) (RefreshResponse, int) {
+		t.Helper()
+		var response RefreshResponse
+		status := postJSON(t, app, "/refresh", `{"refresh_token":"`+token+`"}`, &response)
+		return response, status
+	}
+
+	// Each refresh token is exchanged for a new one once
+	first := login()
+	second, status := refresh(first.RefreshToken)
+	if status != fiber.StatusOK {
+		t.Fatalf("refresh status = %d, want %d", status, fiber.StatusOK)
+	}
+	if second.RefreshToken == first.RefreshToken || second.AccessToken == first.AccessToken {
+		t.Error("refresh returned the tokens of the login")
+	}
+
+	// Presenting the rotated token again ends the session opened with it
+	if _, status := refresh(first.RefreshToken); status != fiber.StatusUnauthorized {
+		t.Errorf("reused refresh token status = %d, want %d", status, fiber.StatusUnauthorized)
+	}
+	if _, status := refresh(second.RefreshToken); status != fiber.StatusUnauthorized {
+		t.Errorf("refresh after reuse status = %d, want %d", status, fiber.StatusUnauthorized)
+	}
+
+	// Logout revokes the refresh tokens of a new session
+	third := login()
+	if status := postJSON(t, app, "/logout", ``, nil); status != fiber.StatusOK {
+		t.Fatalf("logout status = %d, want %d", status, fiber.StatusOK)
+	}
+	if _, status := refresh(third.RefreshToken); status != fiber.StatusUnauthorized {
+		t.Errorf("refresh after logout status = %d, want %d", status, fiber.StatusUnauthorized)
+	}
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/zoomxml/internal/api/middleware"
+	"github.com/zoomxml/internal/logger"
+	"github.com/zoomxml/internal/services"
+)
+
+// AutoSyncRequest representa a ativação ou pausa em massa da busca automática
+type AutoSyncRequest struct {
+	Enabled    *bool   `json:"enabled" validate:"required"`
+	CompanyIDs []int64 `json:"company_ids,omitempty" validate:"omitempty,dive,min=1"` // Vazio = todas as empresas
+}
+
+// SetAutoSync ativa ou pausa a busca automática de várias empresas (apenas admin)
+// @Summary Ativar ou pausar busca automática em massa
+// @Description Altera auto_fetch das empresas informadas, ou de todas quando company_ids é omitido, em uma única transação. Útil para pausar as sincronizações durante manutenção do provedor. A retomada sem company_ids religa apenas as empresas desligadas pela pausa em massa, exceto as com situação cadastral inativa. Uma execução do agendador em andamento deixa de buscar as empresas pausadas.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param request body AutoSyncRequest true "Estado da busca automática"
+// @Success 200 {object} fiber.Map
+// @Failure 400 {object} SwaggerValidationError "Erro de validação"
+// @Failure 401 {object} SwaggerError "Autenticação necessária"
+// @Failure 403 {object} SwaggerError "Apenas administradores"
+// @Failure 500 {object} SwaggerError "Erro interno"
+// @Security UserToken
+// @Router /admin/auto-sync [post]
+func (h *CompanyHandler) SetAutoSync(c *fiber.Ctx) error {
+	user := middleware.GetUserFromContext(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Authentication required",
+		})
+	}
+
+	var req AutoSyncRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := validateStruct(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": err,
+		})
+	}
+
+	affected, err := services.SetCompaniesAutoFetch(c.Context(), *req.Enabled, req.CompanyIDs)
+	if err != nil {
+		logger.ErrorWithFields("Failed to update auto sync in bulk", err, map[string]any{
+			"operation": "bulk_auto_sync",
+			"user_id":   user.ID,
+			"enabled":   *req.Enabled,
+		})
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update auto sync",
+		})
+	}
+
+	recordAudit(c, user, "UPDATE", "Company", 0, map[string]any{
+		"action":      "bulk_auto_sync",
+		"enabled":     *req.Enabled,
+		"company_ids": req.CompanyIDs,
+		"affected":    affected,
+	})
+
+	return respondData(c, fiber.StatusOK, fiber.Map{
+		"enabled":  *req.Enabled,
+		"affected": affected,
+	})
+}
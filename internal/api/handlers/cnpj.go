@@ -15,7 +15,7 @@ type CNPJHandler struct {
 
 func NewCNPJHandler() *CNPJHandler {
 	return &CNPJHandler{
-		cnpjService: services.NewCNPJService(),
+		cnpjService: services.NewCNPJService(services.SharedTransport()),
 	}
 }
 
@@ -65,5 +65,5 @@ func (h *CNPJHandler) ConsultarCNPJ(c *fiber.Ctx) error {
 		Str("operation", "consultar_cnpj").
 		Msg("CNPJ consultado com sucesso")
 
-	return c.JSON(cnpjData)
+	return respondData(c, fiber.StatusOK, cnpjData)
 }
@@ -1,12 +1,19 @@
 package handlers
 
 import (
+	"encoding/json"
 	"strconv"
+	"strings"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/uptrace/bun"
+	"github.com/zoomxml/config"
 	"github.com/zoomxml/internal/api/middleware"
 	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/logger"
 	"github.com/zoomxml/internal/models"
+	"github.com/zoomxml/internal/permissions"
+	"github.com/zoomxml/internal/services"
 )
 
 // CompanyHandler gerencia as rotas de empresas
@@ -48,8 +55,22 @@ type CreateCompanyRequest struct {
 	RegistrationStatus string `json:"registration_status,omitempty"` // Situação cadastral
 
 	// Configurações do sistema
-	Restricted bool `json:"restricted"`
-	AutoFetch  bool `json:"auto_fetch"`
+	Restricted      bool   `json:"restricted"`
+	AutoFetch       bool   `json:"auto_fetch"`
+	ProviderBaseURL string `json:"provider_base_url,omitempty"`                                               // URL do provedor NFSe (deve estar na allowlist)
+	ZeroValuePolicy string `json:"zero_value_policy,omitempty" validate:"omitempty,oneof=accept flag reject"` // Notas com valor zero (padrão: flag)
+	CNPJMatchPolicy string `json:"cnpj_match_policy,omitempty" validate:"omitempty,oneof=off flag reject"`    // Notas de outro CNPJ (padrão: off)
+	// Ambiente esperado das credenciais (padrão: production)
+	DefaultEnvironment string `json:"default_environment,omitempty" validate:"omitempty,oneof=production staging development"`
+	// Código IBGE do município, que escolhe o provedor NFSe (padrão: NFSE_DEFAULT_MUNICIPALITY)
+	MunicipalityCode string `json:"municipality_code,omitempty" validate:"omitempty,len=7,numeric"`
+	// Regras de negócio aplicadas na ingestão (ex.: {"validator": "min_service_value", "params": {"min": 100}})
+	ValidationRules []models.ValidationRule `json:"validation_rules,omitempty"`
+	// Limite de NFSe da empresa, ex.: contas de teste (0 = sem limite) e o que fazer ao atingi-lo (padrão: reject)
+	DocumentLimit       int    `json:"document_limit,omitempty" validate:"omitempty,min=0"`
+	DocumentLimitPolicy string `json:"document_limit_policy,omitempty" validate:"omitempty,oneof=reject evict_oldest"`
+	// Guarda só os campos extraídos das notas, sem o XML no storage nem no banco
+	MetadataOnly bool `json:"metadata_only"`
 }
 
 // UpdateCompanyRequest representa a requisição para atualizar empresa
@@ -81,9 +102,28 @@ type UpdateCompanyRequest struct {
 	RegistrationStatus *string `json:"registration_status,omitempty"`
 
 	// Configurações
-	Restricted *bool `json:"restricted,omitempty"`
-	AutoFetch  *bool `json:"auto_fetch,omitempty"`
-	Active     *bool `json:"active,omitempty"`
+	Restricted   *bool `json:"restricted,omitempty"`
+	AutoFetch    *bool `json:"auto_fetch,omitempty"`
+	Active       *bool `json:"active,omitempty"`
+	DebugCapture *bool `json:"debug_capture,omitempty"` // Apenas admin
+
+	// URL do provedor NFSe (deve estar na allowlist; vazio volta ao padrão)
+	ProviderBaseURL *string `json:"provider_base_url,omitempty"`
+	// Código IBGE do município que escolhe o provedor NFSe (vazio volta ao padrão)
+	MunicipalityCode *string `json:"municipality_code,omitempty"`
+	// Notas com valor zero: accept, flag ou reject
+	ZeroValuePolicy *string `json:"zero_value_policy,omitempty" validate:"omitempty,oneof=accept flag reject"`
+	// Notas em que a empresa não é prestadora nem tomadora: off, flag ou reject
+	CNPJMatchPolicy *string `json:"cnpj_match_policy,omitempty" validate:"omitempty,oneof=off flag reject"`
+	// Ambiente esperado das credenciais: production, staging ou development
+	DefaultEnvironment *string `json:"default_environment,omitempty" validate:"omitempty,oneof=production staging development"`
+	// Regras de negócio aplicadas na ingestão (lista vazia remove todas)
+	ValidationRules *[]models.ValidationRule `json:"validation_rules,omitempty"`
+	// Limite de NFSe da empresa (0 = sem limite) e política ao atingi-lo: reject ou evict_oldest (apenas admin)
+	DocumentLimit       *int    `json:"document_limit,omitempty" validate:"omitempty,min=0"`
+	DocumentLimitPolicy *string `json:"document_limit_policy,omitempty" validate:"omitempty,oneof=reject evict_oldest"`
+	// Guarda só os campos extraídos das novas notas, sem o XML (não afeta as já armazenadas)
+	MetadataOnly *bool `json:"metadata_only,omitempty"`
 }
 
 // CreateCompany cria uma nova empresa
@@ -108,12 +148,8 @@ func (h *CompanyHandler) CreateCompany(c *fiber.Ctx) error {
 		})
 	}
 
-	// Validar entrada
-	if err := validateStruct(req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error":   "Validation failed",
-			"details": err,
-		})
+	if body := prepareCompanyRequest(&req); body != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(body)
 	}
 
 	// Verificar se CNPJ já existe
@@ -135,7 +171,67 @@ func (h *CompanyHandler) CreateCompany(c *fiber.Ctx) error {
 	}
 
 	// Criar empresa
-	company := &models.Company{
+	company := companyFromRequest(req)
+
+	_, err = database.DB.NewInsert().Model(company).Exec(c.Context())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create company",
+		})
+	}
+
+	return respondData(c, fiber.StatusCreated, company)
+}
+
+// prepareCompanyRequest valida a requisição de criação de empresa e preenche os padrões
+// das políticas. Retorna o corpo da resposta 400 quando a requisição é inválida.
+func prepareCompanyRequest(req *CreateCompanyRequest) fiber.Map {
+	if err := validateStruct(*req); err != nil {
+		return fiber.Map{"error": "Validation failed", "details": err}
+	}
+
+	// Validar campos obrigatórios configurados (COMPANY_REQUIRED_FIELDS)
+	if err := validateRequiredFields(*req, config.Get().Company.RequiredFields); err != nil {
+		return fiber.Map{"error": "Validation failed", "details": err}
+	}
+
+	// Validar URL do provedor contra a allowlist
+	if req.ProviderBaseURL != "" {
+		if err := services.ValidateProviderBaseURL(req.ProviderBaseURL); err != nil {
+			return fiber.Map{"error": err.Error()}
+		}
+	}
+
+	// Validar o município contra os provedores registrados
+	if req.MunicipalityCode != "" {
+		if err := services.ValidateMunicipalityCode(req.MunicipalityCode); err != nil {
+			return fiber.Map{"error": err.Error()}
+		}
+	}
+
+	// Validar regras de negócio contra os validadores registrados
+	if _, err := services.BuildDocumentValidators(req.ValidationRules); err != nil {
+		return fiber.Map{"error": err.Error(), "validators": services.DocumentValidatorNames()}
+	}
+
+	if req.ZeroValuePolicy == "" {
+		req.ZeroValuePolicy = models.ZeroValuePolicyFlag
+	}
+	if req.CNPJMatchPolicy == "" {
+		req.CNPJMatchPolicy = models.CNPJMatchPolicyOff
+	}
+	if req.DefaultEnvironment == "" {
+		req.DefaultEnvironment = models.EnvironmentProduction
+	}
+	if req.DocumentLimitPolicy == "" {
+		req.DocumentLimitPolicy = models.DocumentLimitPolicyReject
+	}
+	return nil
+}
+
+// companyFromRequest monta uma nova empresa ativa a partir da requisição de criação
+func companyFromRequest(req CreateCompanyRequest) *models.Company {
+	return &models.Company{
 		Name:      req.Name,
 		CNPJ:      req.CNPJ,
 		TradeName: req.TradeName,
@@ -162,19 +258,21 @@ func (h *CompanyHandler) CreateCompany(c *fiber.Ctx) error {
 		RegistrationStatus: req.RegistrationStatus,
 
 		// Configurações
-		Restricted: req.Restricted,
-		AutoFetch:  req.AutoFetch,
-		Active:     true,
-	}
+		Restricted:      req.Restricted,
+		AutoFetch:       req.AutoFetch,
+		ProviderBaseURL: req.ProviderBaseURL,
+		ZeroValuePolicy: req.ZeroValuePolicy,
+		CNPJMatchPolicy: req.CNPJMatchPolicy,
+		Active:          true,
 
-	_, err = database.DB.NewInsert().Model(company).Exec(c.Context())
-	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to create company",
-		})
-	}
+		DefaultEnvironment: req.DefaultEnvironment,
+		ValidationRules:    req.ValidationRules,
+		MunicipalityCode:   req.MunicipalityCode,
 
-	return c.Status(fiber.StatusCreated).JSON(company)
+		DocumentLimit:       req.DocumentLimit,
+		DocumentLimitPolicy: req.DocumentLimitPolicy,
+		MetadataOnly:        req.MetadataOnly,
+	}
 }
 
 // GetCompanies lista empresas com base nas regras de visibilidade
@@ -184,18 +282,130 @@ func (h *CompanyHandler) CreateCompany(c *fiber.Ctx) error {
 // @Produce json
 // @Param active query string false "Filtrar por status (true/false) - apenas admin"
 // @Param restricted query string false "Filtrar por tipo (true/false) - apenas admin"
+// @Param municipio query string false "Filtrar por município (cidade)"
+// @Param auto_sync query string false "Filtrar por busca automática (true/false)"
+// @Param has_credentials query string false "Filtrar por empresas com credenciais ativas (true/false) - apenas admin ou, entre as empresas de que é membro, usuário autenticado"
 // @Param page query int false "Página (padrão: 1)"
-// @Param limit query int false "Itens por página (padrão: 20)"
+// @Param limit query int false "Itens por página (padrão: 20; valores acima de 100 são reduzidos a 100)"
 // @Success 200 {object} SwaggerCompaniesResponse "Lista de empresas com paginação"
+// @Failure 401 {object} SwaggerError "Autenticação necessária (ALLOW_ANONYMOUS_LISTING=false)"
 // @Failure 500 {object} SwaggerError "Erro interno"
 // @Router /companies [get]
 func (h *CompanyHandler) GetCompanies(c *fiber.Ctx) error {
 	user := middleware.GetUserFromContext(c)
+	if anonymousListingDenied(user) {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Authentication required",
+		})
+	}
 
 	var companies []models.Company
-	query := database.DB.NewSelect().Model(&companies)
+	query := applyCompanyListFilters(database.DB.NewSelect().Model(&companies), c, user)
 
-	// Aplicar regras de visibilidade
+	// Paginação
+	page, limit, err := parsePagination(c)
+	if err != nil {
+		return paginationError(c, err)
+	}
+	offset := (page - 1) * limit
+
+	query = query.Limit(limit).Offset(offset).Order("id ASC")
+
+	err = query.Scan(c.Context())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch companies",
+		})
+	}
+
+	// Contar total (aplicando os mesmos filtros)
+	countQuery := applyCompanyListFilters(database.DB.NewSelect().Model((*models.Company)(nil)), c, user)
+
+	total, err := countQuery.Count(c.Context())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to count companies",
+		})
+	}
+
+	return respondList(c, "companies", companies, page, limit, total)
+}
+
+// Forma de acesso do usuário a uma empresa
+const (
+	CompanyAccessMember = "member" // membro da empresa
+	CompanyAccessPublic = "public" // empresa não restrita
+	CompanyAccessAdmin  = "admin"  // empresa restrita acessada como administrador
+)
+
+// AccessibleCompany é uma empresa em que o usuário pode atuar e a forma de acesso
+type AccessibleCompany struct {
+	ID         int64  `bun:"id" json:"id"`
+	Name       string `bun:"name" json:"name"`
+	TradeName  string `bun:"trade_name" json:"trade_name,omitempty"`
+	CNPJ       string `bun:"cnpj" json:"cnpj"`
+	Restricted bool   `bun:"restricted" json:"restricted"`
+	Active     bool   `bun:"active" json:"active"`
+	Access     string `bun:"access" json:"access"` // member, public ou admin
+}
+
+// GetMyCompanies lista as empresas em que o usuário autenticado pode atuar
+// @Summary Listar minhas empresas
+// @Description Lista as empresas públicas e as empresas de que o usuário é membro (todas, para admin), indicando a forma de acesso
+// @Tags companies
+// @Produce json
+// @Param page query int false "Página (padrão: 1)"
+// @Param limit query int false "Itens por página (padrão: 20; valores acima de 100 são reduzidos a 100)"
+// @Success 200 {object} SwaggerCompaniesResponse "Lista de empresas com paginação"
+// @Failure 400 {object} SwaggerError "Paginação inválida"
+// @Failure 401 {object} SwaggerError "Autenticação necessária"
+// @Failure 500 {object} SwaggerError "Erro interno"
+// @Security UserToken
+// @Router /me/companies [get]
+func (h *CompanyHandler) GetMyCompanies(c *fiber.Ctx) error {
+	user := middleware.GetUserFromContext(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Authentication required",
+		})
+	}
+
+	page, limit, err := parsePagination(c)
+	if err != nil {
+		return paginationError(c, err)
+	}
+
+	companies := []AccessibleCompany{}
+	err = applyCompanyVisibility(database.DB.NewSelect().Model((*models.Company)(nil)), user).
+		Column("id", "name", "trade_name", "cnpj", "restricted", "active").
+		ColumnExpr(`CASE
+			WHEN EXISTS (SELECT 1 FROM company_members cm WHERE cm.company_id = c.id AND cm.user_id = ?) THEN ?
+			WHEN c.restricted = false THEN ?
+			ELSE ?
+		END AS access`, user.ID, CompanyAccessMember, CompanyAccessPublic, CompanyAccessAdmin).
+		Order("id ASC").
+		Limit(limit).
+		Offset((page-1)*limit).
+		Scan(c.Context(), &companies)
+
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch companies",
+		})
+	}
+
+	total, err := applyCompanyVisibility(database.DB.NewSelect().Model((*models.Company)(nil)), user).Count(c.Context())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to count companies",
+		})
+	}
+
+	return respondList(c, "companies", companies, page, limit, total)
+}
+
+// applyCompanyVisibility restringe a consulta às empresas que o usuário pode ver
+func applyCompanyVisibility(query *bun.SelectQuery, user *models.User) *bun.SelectQuery {
 	if user == nil {
 		// Usuário não autenticado - apenas empresas não restritas
 		query = query.Where("restricted = false AND active = true")
@@ -211,6 +421,13 @@ func (h *CompanyHandler) GetCompanies(c *fiber.Ctx) error {
 		`, user.ID)
 	}
 	// Admin vê todas as empresas (sem filtro adicional)
+	return query
+}
+
+// applyCompanyListFilters aplica as regras de visibilidade e os filtros opcionais da
+// listagem de empresas
+func applyCompanyListFilters(query *bun.SelectQuery, c *fiber.Ctx, user *models.User) *bun.SelectQuery {
+	query = applyCompanyVisibility(query, user)
 
 	// Filtros opcionais
 	if active := c.Query("active"); active != "" && user != nil && user.IsAdmin() {
@@ -231,49 +448,38 @@ func (h *CompanyHandler) GetCompanies(c *fiber.Ctx) error {
 		}
 	}
 
-	// Paginação
-	page, _ := strconv.Atoi(c.Query("page", "1"))
-	limit, _ := strconv.Atoi(c.Query("limit", "20"))
-	offset := (page - 1) * limit
-
-	query = query.Limit(limit).Offset(offset).Order("id ASC")
-
-	err := query.Scan(c.Context())
-	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to fetch companies",
-		})
+	if municipio := strings.TrimSpace(c.Query("municipio")); municipio != "" {
+		query = query.Where("LOWER(city) = LOWER(?)", municipio)
 	}
 
-	// Contar total (aplicando os mesmos filtros)
-	countQuery := database.DB.NewSelect().Model((*models.Company)(nil))
-	if user == nil {
-		countQuery = countQuery.Where("restricted = false AND active = true")
-	} else if !user.IsAdmin() {
-		countQuery = countQuery.Where(`
-			(restricted = false AND active = true) OR 
-			(id IN (
-				SELECT company_id FROM company_members 
-				WHERE user_id = ?
-			))
-		`, user.ID)
+	switch c.Query("auto_sync") {
+	case "true":
+		query = query.Where("auto_fetch = true")
+	case "false":
+		query = query.Where("auto_fetch = false")
 	}
 
-	total, err := countQuery.Count(c.Context())
-	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to count companies",
-		})
+	// Credenciais ativas cadastradas para a empresa. Só admins e membros podem saber se uma
+	// empresa tem credenciais: sem usuário o filtro é ignorado e, para os demais, ele limita
+	// a listagem às empresas de que o usuário é membro
+	hasCredentials := "EXISTS (SELECT 1 FROM company_credentials cc WHERE cc.company_id = c.id AND cc.active = true)"
+	if filter := c.Query("has_credentials"); user != nil && (filter == "true" || filter == "false") {
+		if filter == "false" {
+			hasCredentials = "NOT " + hasCredentials
+		}
+		query = query.Where(hasCredentials)
+		if !user.IsAdmin() {
+			query = query.Where("EXISTS (SELECT 1 FROM company_members cm WHERE cm.company_id = c.id AND cm.user_id = ?)", user.ID)
+		}
 	}
 
-	return c.JSON(fiber.Map{
-		"companies": companies,
-		"pagination": fiber.Map{
-			"page":  page,
-			"limit": limit,
-			"total": total,
-		},
-	})
+	return query
+}
+
+// anonymousListingDenied indica se uma requisição sem usuário deve ser recusada por
+// ALLOW_ANONYMOUS_LISTING=false
+func anonymousListingDenied(user *models.User) bool {
+	return user == nil && !config.Get().Auth.AllowAnonymousListing
 }
 
 // GetCompany obtém uma empresa específica
@@ -286,6 +492,11 @@ func (h *CompanyHandler) GetCompany(c *fiber.Ctx) error {
 	}
 
 	user := middleware.GetUserFromContext(c)
+	if anonymousListingDenied(user) {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Authentication required",
+		})
+	}
 
 	company := &models.Company{}
 	query := database.DB.NewSelect().Model(company).Where("id = ?", id)
@@ -311,7 +522,7 @@ func (h *CompanyHandler) GetCompany(c *fiber.Ctx) error {
 		})
 	}
 
-	return c.JSON(company)
+	return respondData(c, fiber.StatusOK, company)
 }
 
 // UpdateCompany atualiza uma empresa
@@ -367,12 +578,16 @@ func (h *CompanyHandler) UpdateCompany(c *fiber.Ctx) error {
 		})
 	}
 
-	// Atualizar campos
-	query := database.DB.NewUpdate().Model(company).Where("id = ?", id)
+	// Atualizar campos; as colunas alteradas são conferidas contra o papel do usuário
+	query := database.DB.NewUpdate().Model((*models.Company)(nil)).Where("id = ?", id)
+	columns := []string{}
+	set := func(column string, value any) {
+		query = query.Set(column+" = ?", value)
+		columns = append(columns, column)
+	}
 
 	if req.Name != nil {
-		query = query.Set("name = ?", *req.Name)
-		company.Name = *req.Name
+		set("name", *req.Name)
 	}
 
 	if req.CNPJ != nil {
@@ -394,117 +609,177 @@ func (h *CompanyHandler) UpdateCompany(c *fiber.Ctx) error {
 			})
 		}
 
-		query = query.Set("cnpj = ?", *req.CNPJ)
-		company.CNPJ = *req.CNPJ
+		set("cnpj", *req.CNPJ)
 	}
 
-	if req.Address != nil {
-		query = query.Set("address = ?", *req.Address)
-		company.Address = *req.Address
+	if req.TradeName != nil {
+		set("trade_name", *req.TradeName)
 	}
 
+	// Endereço
+	if req.Address != nil {
+		set("address", *req.Address)
+	}
+	if req.Number != nil {
+		set("number", *req.Number)
+	}
+	if req.Complement != nil {
+		set("complement", *req.Complement)
+	}
+	if req.District != nil {
+		set("district", *req.District)
+	}
 	if req.City != nil {
-		query = query.Set("city = ?", *req.City)
-		company.City = *req.City
+		set("city", *req.City)
 	}
-
 	if req.State != nil {
-		query = query.Set("state = ?", *req.State)
-		company.State = *req.State
+		set("state", *req.State)
 	}
-
 	if req.ZipCode != nil {
-		query = query.Set("zip_code = ?", *req.ZipCode)
-		company.ZipCode = *req.ZipCode
+		set("zip_code", *req.ZipCode)
 	}
 
-	if req.TradeName != nil {
-		query = query.Set("trade_name = ?", *req.TradeName)
-		company.TradeName = *req.TradeName
+	// Contato
+	if req.Phone != nil {
+		set("phone", *req.Phone)
 	}
-
-	if req.Number != nil {
-		query = query.Set("number = ?", *req.Number)
-		company.Number = *req.Number
+	if req.Email != nil {
+		set("email", *req.Email)
 	}
 
-	if req.Complement != nil {
-		query = query.Set("complement = ?", *req.Complement)
-		company.Complement = *req.Complement
+	// Dados empresariais
+	if req.CompanySize != nil {
+		set("company_size", *req.CompanySize)
+	}
+	if req.MainActivity != nil {
+		set("main_activity", *req.MainActivity)
+	}
+	if req.SecondaryActivity != nil {
+		set("secondary_activity", *req.SecondaryActivity)
+	}
+	if req.LegalNature != nil {
+		set("legal_nature", *req.LegalNature)
+	}
+	if req.OpeningDate != nil {
+		set("opening_date", *req.OpeningDate)
+	}
+	if req.RegistrationStatus != nil {
+		set("registration_status", *req.RegistrationStatus)
 	}
 
-	if req.District != nil {
-		query = query.Set("district = ?", *req.District)
-		company.District = *req.District
+	// Configurações (restricted, active e debug_capture apenas admin)
+	if req.Restricted != nil {
+		set("restricted", *req.Restricted)
+	}
+	if req.Active != nil {
+		set("active", *req.Active)
+	}
+	if req.DebugCapture != nil {
+		set("debug_capture", *req.DebugCapture)
+	}
+	if req.AutoFetch != nil {
+		set("auto_fetch", *req.AutoFetch)
+		query = query.Set("auto_fetch_paused = false") // A alteração manual encerra a pausa em massa
 	}
 
-	if req.Phone != nil {
-		query = query.Set("phone = ?", *req.Phone)
-		company.Phone = *req.Phone
+	if req.ProviderBaseURL != nil {
+		if *req.ProviderBaseURL != "" {
+			if err := services.ValidateProviderBaseURL(*req.ProviderBaseURL); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": err.Error(),
+				})
+			}
+		}
+		set("provider_base_url", *req.ProviderBaseURL)
 	}
 
-	if req.Email != nil {
-		query = query.Set("email = ?", *req.Email)
-		company.Email = *req.Email
+	if req.MunicipalityCode != nil {
+		if *req.MunicipalityCode != "" {
+			if err := services.ValidateMunicipalityCode(*req.MunicipalityCode); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": err.Error(),
+				})
+			}
+		}
+		set("municipality_code", *req.MunicipalityCode)
 	}
 
-	// Dados empresariais
-	if req.CompanySize != nil {
-		query = query.Set("company_size = ?", *req.CompanySize)
-		company.CompanySize = *req.CompanySize
+	if req.ZeroValuePolicy != nil {
+		set("zero_value_policy", *req.ZeroValuePolicy)
 	}
 
-	if req.MainActivity != nil {
-		query = query.Set("main_activity = ?", *req.MainActivity)
-		company.MainActivity = *req.MainActivity
+	if req.CNPJMatchPolicy != nil {
+		set("cnpj_match_policy", *req.CNPJMatchPolicy)
 	}
 
-	if req.SecondaryActivity != nil {
-		query = query.Set("secondary_activity = ?", *req.SecondaryActivity)
-		company.SecondaryActivity = *req.SecondaryActivity
+	if req.DefaultEnvironment != nil {
+		set("default_environment", *req.DefaultEnvironment)
 	}
 
-	if req.LegalNature != nil {
-		query = query.Set("legal_nature = ?", *req.LegalNature)
-		company.LegalNature = *req.LegalNature
+	if req.ValidationRules != nil {
+		if _, err := services.BuildDocumentValidators(*req.ValidationRules); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":      err.Error(),
+				"validators": services.DocumentValidatorNames(),
+			})
+		}
+		// Lista vazia grava NULL; a coluna jsonb recebe as regras serializadas
+		var rules any
+		if len(*req.ValidationRules) > 0 {
+			encoded, err := json.Marshal(*req.ValidationRules)
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "Invalid validation rules",
+				})
+			}
+			rules = string(encoded)
+		}
+		set("validation_rules", rules)
 	}
 
-	if req.OpeningDate != nil {
-		query = query.Set("opening_date = ?", *req.OpeningDate)
-		company.OpeningDate = *req.OpeningDate
+	if req.DocumentLimit != nil {
+		set("document_limit", *req.DocumentLimit)
+	}
+	if req.DocumentLimitPolicy != nil {
+		set("document_limit_policy", *req.DocumentLimitPolicy)
 	}
 
-	if req.RegistrationStatus != nil {
-		query = query.Set("registration_status = ?", *req.RegistrationStatus)
-		company.RegistrationStatus = *req.RegistrationStatus
+	if req.MetadataOnly != nil {
+		set("metadata_only", *req.MetadataOnly)
 	}
 
-	// Apenas admin pode alterar restricted e active
-	if user.IsAdmin() {
-		if req.Restricted != nil {
-			query = query.Set("restricted = ?", *req.Restricted)
-			company.Restricted = *req.Restricted
-		}
+	if len(columns) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Nothing to update",
+		})
+	}
 
-		if req.Active != nil {
-			query = query.Set("active = ?", *req.Active)
-			company.Active = *req.Active
-		}
+	denied, err := permissions.DeniedCompanyFields(c.Context(), user, id, columns)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to validate permissions",
+		})
 	}
 
-	if req.AutoFetch != nil {
-		query = query.Set("auto_fetch = ?", *req.AutoFetch)
-		company.AutoFetch = *req.AutoFetch
+	if len(denied) > 0 {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error":  "Not allowed to update these fields",
+			"fields": denied,
+		})
 	}
 
-	_, err = query.Exec(c.Context())
+	// A resposta vem do que foi efetivamente gravado, não da requisição
+	_, err = query.
+		Set("updated_at = current_timestamp").
+		Returning("*").
+		Exec(c.Context(), company)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to update company",
 		})
 	}
 
-	return c.JSON(company)
+	return respondData(c, fiber.StatusOK, company)
 }
 
 // DeleteCompany remove uma empresa (apenas admin)
@@ -547,3 +822,47 @@ func (h *CompanyHandler) DeleteCompany(c *fiber.Ctx) error {
 
 	return c.Status(fiber.StatusNoContent).Send(nil)
 }
+
+// CompareCompetences compara os totais de duas competências de uma empresa
+// @Summary Compare competências
+// @Description Returns document counts and total values for two competências plus deltas and percentage change
+// @Tags companies
+// @Produce json
+// @Param company_id path int true "Company ID"
+// @Param from query string true "Base competência (YYYY-MM)"
+// @Param to query string true "Compared competência (YYYY-MM)"
+// @Success 200 {object} services.CompetenceComparison
+// @Failure 400 {object} SwaggerError "Parâmetros inválidos"
+// @Failure 401 {object} SwaggerError "Autenticação necessária"
+// @Failure 403 {object} SwaggerError "Acesso negado"
+// @Failure 404 {object} SwaggerError "Empresa não encontrada"
+// @Failure 500 {object} SwaggerError "Erro interno"
+// @Security UserToken
+// @Router /companies/{company_id}/compare [get]
+func (h *CompanyHandler) CompareCompetences(c *fiber.Ctx) error {
+	// Company resolved (and access checked) by CompanyMiddleware
+	id := middleware.GetCompanyFromContext(c).ID
+
+	from, okFrom := services.NormalizeCompetence(c.Query("from"))
+	to, okTo := services.NormalizeCompetence(c.Query("to"))
+	if !okFrom || !okTo {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Query parameters 'from' and 'to' must be competências in YYYY-MM format",
+		})
+	}
+
+	comparison, err := services.CompareCompetences(c.Context(), id, from, to)
+	if err != nil {
+		logger.ErrorWithFields("Failed to compare competences", err, map[string]any{
+			"operation":  "compare_competences",
+			"company_id": id,
+			"from":       from,
+			"to":         to,
+		})
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to compare competences",
+		})
+	}
+
+	return respondData(c, fiber.StatusOK, comparison)
+}
@@ -0,0 +1,419 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/pgdialect"
+	"github.com/valyala/fasthttp"
+	"github.com/zoomxml/config"
+	"github.com/zoomxml/internal/api/middleware"
+	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/database/databasetest"
+	"github.com/zoomxml/internal/models"
+)
+
+func TestPrepareCompanyRequestRequiredFields(t *testing.T) {
+	complete := CreateCompanyRequest{Name: "Empresa Teste", CNPJ: "12345678000190", Email: "fiscal@example.com", Phone: "99 3524-0000", City: "Imperatriz"}
+	minimal := CreateCompanyRequest{Name: "Empresa Teste", CNPJ: "12345678000190"}
+
+	tests := []struct {
+		name     string
+		required []string
+		request  CreateCompanyRequest
+		want     map[string]string // validation details, nil when the request is accepted
+	}{
+		{"defaults accept name and CNPJ", nil, minimal, nil},
+		{"configured fields present", []string{"email", "phone", "city"}, complete, nil},
+		{"configured fields missing", []string{"email", "phone", "city"}, minimal, map[string]string{
+			"email": "email is required",
+			"phone": "phone is required",
+			"city":  "city is required",
+		}},
+		{"blank value counts as missing", []string{"city"}, CreateCompanyRequest{Name: "Empresa Teste", CNPJ: "12345678000190", City: "  "}, map[string]string{
+			"city": "city is required",
+		}},
+		{"unknown fields are ignored", []string{"fax", " email "}, complete, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Get()
+			required := cfg.Company.RequiredFields
+			cfg.Company.RequiredFields = tt.required
+			t.Cleanup(func() { cfg.Company.RequiredFields = required })
+
+			request := tt.request
+			body := prepareCompanyRequest(&request)
+			if tt.want == nil {
+				if body != nil {
+					t.Fatalf("prepareCompanyRequest() = %v, want the request accepted", body)
+				}
+				return
+			}
+
+			details, _ := body["details"].(map[string]string)
+			if !maps.Equal(details, tt.want) {
+				t.Errorf("prepareCompanyRequest() details = %v, want %v", body["details"], tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyCompanyListFilters(t *testing.T) {
+	db := bun.NewDB(nil, pgdialect.New())
+	user := &models.User{ID: 7, Role: "user"}
+	admin := &models.User{ID: 1, Role: "admin"}
+
+	tests := []struct {
+		name    string
+		user    *models.User
+		query   string
+		want    []string
+		notWant []string
+	}{
+		{"municipality is case-insensitive", nil, "municipio=%20Imperatriz%20", []string{"LOWER(city) = LOWER('Imperatriz')"}, nil},
+		{"auto sync on", nil, "auto_sync=true", []string{"auto_fetch = true"}, nil},
+		{"auto sync off", nil, "auto_sync=false", []string{"auto_fetch = false"}, nil},
+		{"unknown auto sync value is ignored", nil, "auto_sync=maybe", nil, []string{"auto_fetch ="}},
+		{"anonymous cannot filter by credentials", nil, "has_credentials=true", []string{"restricted = false AND active = true"}, []string{"company_credentials"}},
+		{"user filters by credentials of member companies only", user, "has_credentials=false", []string{"NOT EXISTS (SELECT 1 FROM company_credentials", "cm.user_id = 7"}, nil},
+		{"admin filters by credentials of every company", admin, "has_credentials=true", []string{"EXISTS (SELECT 1 FROM company_credentials"}, []string{"NOT EXISTS", "company_members"}},
+		{"combined", admin, "municipio=Imperatriz&auto_sync=true&has_credentials=false", []string{"LOWER(city) = LOWER('Imperatriz')", "auto_fetch = true", "NOT EXISTS (SELECT 1 FROM company_credentials"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			c := app.AcquireCtx(&fasthttp.RequestCtx{})
+			defer app.ReleaseCtx(c)
+			c.Request().SetRequestURI("/companies?" + tt.query)
+
+			query := applyCompanyListFilters(db.NewSelect().Model((*models.Company)(nil)), c, tt.user).String()
+			for _, want := range tt.want {
+				if !strings.Contains(query, want) {
+					t.Errorf("query %q does not contain %q", query, want)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(query, notWant) {
+					t.Errorf("query %q contains %q", query, notWant)
+				}
+			}
+		})
+	}
+}
+
+func TestGetCompaniesCombinedFilters(t *testing.T) {
+	databasetest.Require(t)
+	city := fmt.Sprintf("Cidade %d", time.Now().UnixNano())
+	inCity := func(autoFetch, restricted bool) func(*models.Company) {
+		return func(c *models.Company) {
+			c.City, c.AutoFetch, c.Restricted = city, autoFetch, restricted
+		}
+	}
+	withCredentials := databasetest.CreateCompany(t, inCity(true, false))
+	databasetest.CreateCredential(t, &models.CompanyCredential{CompanyID: withCredentials.ID})
+	withoutCredentials := databasetest.CreateCompany(t, inCity(true, false))
+	manual := databasetest.CreateCompany(t, inCity(false, false))
+	restricted := databasetest.CreateCompany(t, inCity(true, true))
+	databasetest.CreateCompany(t, func(c *models.Company) { c.City, c.AutoFetch = city+" Norte", true })
+
+	member := databasetest.CreateUser(t, "user", withoutCredentials)
+	admin := databasetest.CreateUser(t, "admin")
+
+	filter := "municipio=" + url.QueryEscape(strings.ToUpper(city)) + "&auto_sync=true"
+	tests := []struct {
+		name  string
+		user  *models.User
+		query string
+		want  []int64
+	}{
+		{"anonymous sees public auto-sync companies of the city", nil, filter, []int64{withCredentials.ID, withoutCredentials.ID}},
+		{"anonymous ignores the credentials filter", nil, filter + "&has_credentials=false", []int64{withCredentials.ID, withoutCredentials.ID}},
+		{"admin finds companies lacking credentials", admin, filter + "&has_credentials=false", []int64{withoutCredentials.ID, restricted.ID}},
+		{"admin finds companies with credentials", admin, filter + "&has_credentials=true", []int64{withCredentials.ID}},
+		{"member only learns about its companies", member, filter + "&has_credentials=false", []int64{withoutCredentials.ID}},
+		{"manual companies", admin, "municipio=" + url.QueryEscape(city) + "&auto_sync=false", []int64{manual.ID}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			app.Get("/companies", func(c *fiber.Ctx) error {
+				if tt.user != nil {
+					c.Locals(string(middleware.UserKey), tt.user)
+				}
+				return c.Next()
+			}, NewCompanyHandler().GetCompanies)
+
+			resp, err := app.Test(httptest.NewRequest("GET", "/companies?"+tt.query, nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != fiber.StatusOK {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, fiber.StatusOK)
+			}
+
+			var body struct {
+				Companies []models.Company `json:"companies"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			ids := []int64{}
+			for _, company := range body.Companies {
+				ids = append(ids, company.ID)
+			}
+			if !slices.Equal(ids, tt.want) {
+				t.Errorf("GetCompanies(%s) = %v, want %v", tt.query, ids, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateCompanyFieldPermissions(t *testing.T) {
+	databasetest.Require(t)
+	company := databasetest.CreateCompany(t, nil)
+	restricted := databasetest.CreateCompany(t, func(c *models.Company) { c.Restricted = true })
+	admin := databasetest.CreateUser(t, "admin")
+	member := databasetest.CreateUser(t, "user", company)
+	outsider := databasetest.CreateUser(t, "user")
+
+	tests := []struct {
+		name       string
+		user       *models.User
+		company    *models.Company
+		body       string
+		wantStatus int
+	}{
+		{"admin changes admin fields", admin, company, `{"active":true,"restricted":false}`, fiber.StatusOK},
+		{"member changes contact fields", member, company, `{"phone":"99 3524-0000","email":"fiscal@example.com"}`, fiber.StatusOK},
+		{"member changes auto fetch", member, company, `{"auto_fetch":true}`, fiber.StatusOK},
+		{"member cannot change admin fields", member, company, `{"phone":"99 3524-0001","active":false}`, fiber.StatusForbidden},
+		{"outsider cannot change a public company", outsider, company, `{"phone":"99 3524-0002"}`, fiber.StatusForbidden},
+		{"outsider cannot change auto fetch", outsider, company, `{"auto_fetch":false}`, fiber.StatusForbidden},
+		{"outsider does not see a restricted company", outsider, restricted, `{"phone":"99 3524-0003"}`, fiber.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			app.Put("/companies/:id", func(c *fiber.Ctx) error {
+				c.Locals(string(middleware.UserKey), tt.user)
+				return c.Next()
+			}, NewCompanyHandler().UpdateCompany)
+
+			req := httptest.NewRequest("PUT", fmt.Sprintf("/companies/%d", tt.company.ID), strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("UpdateCompany(%s) status = %d, want %d", tt.body, resp.StatusCode, tt.wantStatus)
+			}
+			if resp.StatusCode != fiber.StatusOK {
+				return
+			}
+
+			// The response is the persisted row, with the sent fields applied
+			var got, want map[string]any
+			if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(tt.body), &want); err != nil {
+				t.Fatal(err)
+			}
+			for field, value := range want {
+				if got[field] != value {
+					t.Errorf("response %s = %v, want %v", field, got[field], value)
+				}
+			}
+			if got["id"] != float64(tt.company.ID) {
+				t.Errorf("response id = %v, want %d", got["id"], tt.company.ID)
+			}
+		})
+	}
+}
+
+func TestGetMyCompanies(t *testing.T) {
+	databasetest.Require(t)
+	public := databasetest.CreateCompany(t, nil)
+	memberOf := databasetest.CreateCompany(t, func(c *models.Company) { c.Restricted = true })
+	publicMemberOf := databasetest.CreateCompany(t, nil)
+	restricted := databasetest.CreateCompany(t, func(c *models.Company) { c.Restricted = true })
+	// active has a database default, so it is only turned off after the insert
+	inactive := databasetest.CreateCompany(t, nil)
+	if _, err := database.DB.NewUpdate().Model(inactive).Set("active = false").WherePK().Exec(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	created := []int64{public.ID, memberOf.ID, publicMemberOf.ID, restricted.ID, inactive.ID}
+
+	tests := []struct {
+		name string
+		user *models.User
+		want map[int64]string // access by company, among the ones created here
+	}{
+		{"user without memberships sees public companies", databasetest.CreateUser(t, "user"), map[int64]string{
+			public.ID:         CompanyAccessPublic,
+			publicMemberOf.ID: CompanyAccessPublic,
+		}},
+		{"member also sees its companies", databasetest.CreateUser(t, "user", memberOf, publicMemberOf), map[int64]string{
+			public.ID:         CompanyAccessPublic,
+			memberOf.ID:       CompanyAccessMember,
+			publicMemberOf.ID: CompanyAccessMember,
+		}},
+		{"admin sees every company", databasetest.CreateUser(t, "admin", memberOf), map[int64]string{
+			public.ID:         CompanyAccessPublic,
+			memberOf.ID:       CompanyAccessMember,
+			publicMemberOf.ID: CompanyAccessPublic,
+			restricted.ID:     CompanyAccessAdmin,
+			inactive.ID:       CompanyAccessPublic,
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			app.Get("/me/companies", func(c *fiber.Ctx) error {
+				c.Locals(string(middleware.UserKey), tt.user)
+				return c.Next()
+			}, NewCompanyHandler().GetMyCompanies)
+
+			// Other tests may leave companies behind, so every page is read
+			got := map[int64]string{}
+			for page := 1; ; page++ {
+				resp, err := app.Test(httptest.NewRequest("GET", fmt.Sprintf("/me/companies?limit=%d&page=%d", maxPageLimit, page), nil))
+				if err != nil {
+					t.Fatal(err)
+				}
+				var body struct {
+					Companies []AccessibleCompany `json:"companies"`
+				}
+				err = json.NewDecoder(resp.Body).Decode(&body)
+				resp.Body.Close()
+				if resp.StatusCode != fiber.StatusOK || err != nil {
+					t.Fatalf("GetMyCompanies() status = %d, %v, want %d", resp.StatusCode, err, fiber.StatusOK)
+				}
+				for _, company := range body.Companies {
+					if slices.Contains(created, company.ID) {
+						got[company.ID] = company.Access
+					}
+				}
+				if len(body.Companies) < maxPageLimit {
+					break
+				}
+			}
+			if !maps.Equal(got, tt.want) {
+				t.Errorf("GetMyCompanies() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetMyCompaniesRequiresUser(t *testing.T) {
+	databasetest.UseClosed(t)
+	app := fiber.New()
+	app.Get("/me/companies", NewCompanyHandler().GetMyCompanies)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/me/companies", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != fiber.StatusUnauthorized {
+		t.Errorf("GetMyCompanies() without a user status = %d, want %d", resp.StatusCode, fiber.StatusUnauthorized)
+	}
+}
+
+// useAnonymousListing sets ALLOW_ANONYMOUS_LISTING for the test
+func useAnonymousListing(t *testing.T, allowed bool) {
+	t.Helper()
+	cfg := &config.Get().Auth
+	previous := cfg.AllowAnonymousListing
+	cfg.AllowAnonymousListing = allowed
+	t.Cleanup(func() { cfg.AllowAnonymousListing = previous })
+}
+
+// companyReadApp serves GetCompanies and GetCompany, as user when one is given
+func companyReadApp(user *models.User) *fiber.App {
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		if user != nil {
+			c.Locals(string(middleware.UserKey), user)
+		}
+		return c.Next()
+	})
+	handler := NewCompanyHandler()
+	app.Get("/companies", handler.GetCompanies)
+	app.Get("/companies/:id", handler.GetCompany)
+	return app
+}
+
+// TestAnonymousListingDenied checks the 401 answered before any query when anonymous
+// listing is off
+func TestAnonymousListingDenied(t *testing.T) {
+	databasetest.UseClosed(t)
+	useAnonymousListing(t, false)
+	app := companyReadApp(nil)
+
+	for _, path := range []string{"/companies", "/companies/1"} {
+		resp, err := app.Test(httptest.NewRequest("GET", path, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != fiber.StatusUnauthorized {
+			t.Errorf("GET %s without a user status = %d, want %d", path, resp.StatusCode, fiber.StatusUnauthorized)
+		}
+	}
+}
+
+func TestAnonymousListing(t *testing.T) {
+	databasetest.Require(t)
+	company := databasetest.CreateCompany(t, nil)
+	admin := databasetest.CreateUser(t, "admin")
+
+	tests := []struct {
+		name       string
+		allowed    bool
+		user       *models.User
+		wantStatus int
+	}{
+		{"anonymous allowed", true, nil, fiber.StatusOK},
+		{"anonymous denied", false, nil, fiber.StatusUnauthorized},
+		{"authenticated while anonymous is denied", false, admin, fiber.StatusOK},
+		{"authenticated while anonymous is allowed", true, admin, fiber.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useAnonymousListing(t, tt.allowed)
+			app := companyReadApp(tt.user)
+
+			for _, path := range []string{"/companies", fmt.Sprintf("/companies/%d", company.ID)} {
+				resp, err := app.Test(httptest.NewRequest("GET", path, nil))
+				if err != nil {
+					t.Fatal(err)
+				}
+				resp.Body.Close()
+				if resp.StatusCode != tt.wantStatus {
+					t.Errorf("GET %s status = %d, want %d", path, resp.StatusCode, tt.wantStatus)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/zoomxml/internal/api/middleware"
+	"github.com/zoomxml/internal/logger"
+	"github.com/zoomxml/internal/permissions"
+	"github.com/zoomxml/internal/services"
+)
+
+// GetCompanyOverview obtém a visão consolidada de uma empresa
+// @Summary Visão geral da empresa
+// @Description Retorna em uma chamada os dados da empresa, as credenciais por tipo e situação, a última sincronização e a próxima execução prevista, os totais de documentos e o trabalho pendente em segundo plano
+// @Tags companies
+// @Produce json
+// @Param id path int true "ID da empresa"
+// @Success 200 {object} services.CompanyOverview
+// @Failure 400 {object} SwaggerError "ID inválido"
+// @Failure 401 {object} SwaggerError "Autenticação necessária"
+// @Failure 403 {object} SwaggerError "Acesso negado"
+// @Failure 404 {object} SwaggerError "Empresa não encontrada"
+// @Failure 500 {object} SwaggerError "Erro interno"
+// @Security UserToken
+// @Router /companies/{id}/overview [get]
+func (h *CompanyHandler) GetCompanyOverview(c *fiber.Ctx) error {
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid company ID",
+		})
+	}
+
+	user := middleware.GetUserFromContext(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Authentication required",
+		})
+	}
+
+	// Verificar acesso à empresa
+	err = permissions.CanAccessCompany(c.Context(), user, id)
+	if err != nil {
+		if err == permissions.ErrCompanyNotFound {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Company not found",
+			})
+		}
+		if err == permissions.ErrAccessDenied {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "Access denied to this company",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to validate permissions",
+		})
+	}
+
+	overview, err := services.GetCompanyOverview(c.Context(), id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Company not found",
+			})
+		}
+		logger.ErrorWithFields("Failed to build company overview", err, map[string]any{
+			"operation":  "get_company_overview",
+			"company_id": id,
+			"user_id":    user.ID,
+		})
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to build company overview",
+		})
+	}
+
+	return respondData(c, fiber.StatusOK, overview)
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/zoomxml/internal/api/middleware"
+	"github.com/zoomxml/internal/database/databasetest"
+	"github.com/zoomxml/internal/models"
+)
+
+// getCompanyOverview requests the overview of companyID as user and returns the status
+// and the decoded body
+func getCompanyOverview(t *testing.T, user *models.User, companyID string) (int, map[string]json.RawMessage) {
+	t.Helper()
+	app := fiber.New()
+	app.Get("/companies/:id/overview", func(c *fiber.Ctx) error {
+		if user != nil {
+			c.Locals(string(middleware.UserKey), user)
+		}
+		return c.Next()
+	}, NewCompanyHandler().GetCompanyOverview)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/companies/"+companyID+"/overview", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body := map[string]json.RawMessage{}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, body
+}
+
+// TestGetCompanyOverviewValidation checks the requests refused before the company is read
+func TestGetCompanyOverviewValidation(t *testing.T) {
+	databasetest.UseClosed(t)
+
+	if status, _ := getCompanyOverview(t, &models.User{ID: 1, Role: "admin"}, "abc"); status != fiber.StatusBadRequest {
+		t.Errorf("GetCompanyOverview(abc) status = %d, want %d", status, fiber.StatusBadRequest)
+	}
+	if status, _ := getCompanyOverview(t, nil, "1"); status != fiber.StatusUnauthorized {
+		t.Errorf("GetCompanyOverview() without a user status = %d, want %d", status, fiber.StatusUnauthorized)
+	}
+}
+
+func TestGetCompanyOverview(t *testing.T) {
+	databasetest.Require(t)
+	restricted := databasetest.CreateCompany(t, func(c *models.Company) { c.Restricted = true })
+	member := databasetest.CreateUser(t, "user", restricted)
+	outsider := databasetest.CreateUser(t, "user")
+	admin := databasetest.CreateUser(t, "admin")
+
+	tests := []struct {
+		name       string
+		user       *models.User
+		companyID  int64
+		wantStatus int
+	}{
+		{"member", member, restricted.ID, fiber.StatusOK},
+		{"admin", admin, restricted.ID, fiber.StatusOK},
+		{"outsider of a restricted company", outsider, restricted.ID, fiber.StatusForbidden},
+		{"unknown company", admin, 1 << 40, fiber.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := getCompanyOverview(t, tt.user, fmt.Sprint(tt.companyID))
+			if status != tt.wantStatus {
+				t.Fatalf("GetCompanyOverview() status = %d, want %d", status, tt.wantStatus)
+			}
+			if status != fiber.StatusOK {
+				return
+			}
+			for _, section := range []string{"company", "credentials", "sync", "documents", "usage", "pending_work"} {
+				if _, ok := body[section]; !ok {
+					t.Errorf("overview has no %s section", section)
+				}
+			}
+		})
+	}
+}
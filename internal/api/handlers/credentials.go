@@ -1,13 +1,14 @@
 package handlers
 
 import (
+	"database/sql"
+	"errors"
 	"strconv"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/zoomxml/internal/api/middleware"
 	"github.com/zoomxml/internal/database"
 	"github.com/zoomxml/internal/models"
-	"github.com/zoomxml/internal/permissions"
 )
 
 // CredentialHandler gerencia as operações de credenciais
@@ -57,40 +58,8 @@ type UpdateCredentialRequest struct {
 // @Security UserToken
 // @Router /companies/{company_id}/credentials [post]
 func (h *CredentialHandler) CreateCredential(c *fiber.Ctx) error {
-	// Obter ID da empresa
-	companyIDStr := c.Params("company_id")
-	companyID, err := strconv.ParseInt(companyIDStr, 10, 64)
-	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid company ID",
-		})
-	}
-
-	// Obter usuário do contexto
-	user := middleware.GetUserFromContext(c)
-	if user == nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "Authentication required",
-		})
-	}
-
-	// Verificar permissões do usuário para esta empresa
-	err = permissions.CanCreateCredentials(c.Context(), user, companyID)
-	if err != nil {
-		if err == permissions.ErrCompanyNotFound {
-			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-				"error": "Company not found",
-			})
-		}
-		if err == permissions.ErrAccessDenied {
-			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
-				"error": "Access denied to this company",
-			})
-		}
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to validate permissions",
-		})
-	}
+	// Empresa resolvida (e acesso verificado) pelo CompanyMiddleware
+	company := middleware.GetCompanyFromContext(c)
 
 	// Parse do request
 	var req CreateCredentialRequest
@@ -108,9 +77,14 @@ func (h *CredentialHandler) CreateCredential(c *fiber.Ctx) error {
 		})
 	}
 
+	// Sem ambiente informado, a credencial assume o ambiente padrão da empresa
+	if req.Environment == "" {
+		req.Environment = company.DefaultEnvironment
+	}
+
 	// Criar credencial
 	credential := &models.CompanyCredential{
-		CompanyID:   companyID,
+		CompanyID:   company.ID,
 		Type:        req.Type,
 		Name:        req.Name,
 		Description: req.Description,
@@ -120,7 +94,7 @@ func (h *CredentialHandler) CreateCredential(c *fiber.Ctx) error {
 	}
 
 	// Criptografar dados da credencial
-	err = credential.SetCredentialData(req.Login, req.Password, req.Token)
+	err := credential.SetCredentialData(req.Login, req.Password, req.Token)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to encrypt credential data",
@@ -134,7 +108,7 @@ func (h *CredentialHandler) CreateCredential(c *fiber.Ctx) error {
 		})
 	}
 
-	return c.Status(fiber.StatusCreated).JSON(credential)
+	return respondData(c, fiber.StatusCreated, credential)
 }
 
 // GetCredentials lista as credenciais de uma empresa
@@ -151,44 +125,12 @@ func (h *CredentialHandler) CreateCredential(c *fiber.Ctx) error {
 // @Security UserToken
 // @Router /companies/{company_id}/credentials [get]
 func (h *CredentialHandler) GetCredentials(c *fiber.Ctx) error {
-	// Obter ID da empresa
-	companyIDStr := c.Params("company_id")
-	companyID, err := strconv.ParseInt(companyIDStr, 10, 64)
-	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid company ID",
-		})
-	}
-
-	// Obter usuário do contexto
-	user := middleware.GetUserFromContext(c)
-	if user == nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "Authentication required",
-		})
-	}
-
-	// Verificar permissões do usuário para esta empresa
-	err = permissions.CanViewCredentials(c.Context(), user, companyID)
-	if err != nil {
-		if err == permissions.ErrCompanyNotFound {
-			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-				"error": "Company not found",
-			})
-		}
-		if err == permissions.ErrAccessDenied {
-			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
-				"error": "Access denied to this company",
-			})
-		}
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to validate permissions",
-		})
-	}
+	// Empresa resolvida (e acesso verificado) pelo CompanyMiddleware
+	companyID := middleware.GetCompanyFromContext(c).ID
 
 	// Buscar credenciais
 	var credentials []models.CompanyCredential
-	err = database.DB.NewSelect().
+	err := database.DB.NewSelect().
 		Model(&credentials).
 		Where("company_id = ?", companyID).
 		Order("created_at DESC").
@@ -200,7 +142,7 @@ func (h *CredentialHandler) GetCredentials(c *fiber.Ctx) error {
 		})
 	}
 
-	return c.JSON(credentials)
+	return respondData(c, fiber.StatusOK, credentials)
 }
 
 // UpdateCredential atualiza uma credencial
@@ -221,50 +163,16 @@ func (h *CredentialHandler) GetCredentials(c *fiber.Ctx) error {
 // @Security UserToken
 // @Router /companies/{company_id}/credentials/{credential_id} [patch]
 func (h *CredentialHandler) UpdateCredential(c *fiber.Ctx) error {
-	// Obter IDs
-	companyIDStr := c.Params("company_id")
-	credentialIDStr := c.Params("credential_id")
-
-	companyID, err := strconv.ParseInt(companyIDStr, 10, 64)
-	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid company ID",
-		})
-	}
+	// Empresa resolvida (e acesso verificado) pelo CompanyMiddleware
+	companyID := middleware.GetCompanyFromContext(c).ID
 
-	credentialID, err := strconv.ParseInt(credentialIDStr, 10, 64)
+	credentialID, err := strconv.ParseInt(c.Params("credential_id"), 10, 64)
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": "Invalid credential ID",
 		})
 	}
 
-	// Obter usuário do contexto
-	user := middleware.GetUserFromContext(c)
-	if user == nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "Authentication required",
-		})
-	}
-
-	// Verificar permissões do usuário para esta credencial
-	err = permissions.ValidateCredentialAccess(c.Context(), user, credentialID, companyID)
-	if err != nil {
-		if err == permissions.ErrCompanyNotFound {
-			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-				"error": "Company not found",
-			})
-		}
-		if err == permissions.ErrAccessDenied {
-			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
-				"error": "Access denied to this company",
-			})
-		}
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"error": "Credential not found",
-		})
-	}
-
 	// Buscar credencial
 	credential := &models.CompanyCredential{}
 	err = database.DB.NewSelect().
@@ -272,11 +180,16 @@ func (h *CredentialHandler) UpdateCredential(c *fiber.Ctx) error {
 		Where("id = ? AND company_id = ?", credentialID, companyID).
 		Scan(c.Context())
 
-	if err != nil {
+	if errors.Is(err, sql.ErrNoRows) {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "Credential not found",
 		})
 	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch credential",
+		})
+	}
 
 	// Parse do request
 	var req UpdateCredentialRequest
@@ -358,6 +271,18 @@ func (h *CredentialHandler) UpdateCredential(c *fiber.Ctx) error {
 		credential.Active = *req.Active
 	}
 
+	// Um novo segredo ou a reativação recomeça a contagem de recusas do provedor
+	if req.Token != nil || req.Password != nil || (req.Active != nil && *req.Active) {
+		query = query.Set("failure_count = 0")
+		credential.FailureCount = 0
+	}
+
+	// Só a reativação explícita limpa o motivo da desativação automática
+	if req.Active != nil && *req.Active {
+		query = query.Set("disabled_reason = NULL")
+		credential.DisabledReason = ""
+	}
+
 	// Atualizar timestamp
 	query = query.Set("updated_at = CURRENT_TIMESTAMP")
 
@@ -368,69 +293,88 @@ func (h *CredentialHandler) UpdateCredential(c *fiber.Ctx) error {
 		})
 	}
 
-	return c.JSON(credential)
+	return respondData(c, fiber.StatusOK, credential)
 }
 
-// DeleteCredential remove uma credencial
-// @Summary Deletar credencial
-// @Description Remove uma credencial (requer autenticação)
+// GetCredentialInfo informa o que uma credencial permite fazer, sem expor segredos
+// @Summary Capacidades da credencial
+// @Description Descriptografa a credencial e informa tipo, ambiente, presença de token/login, status e último uso (requer autenticação)
 // @Tags credentials
+// @Produce json
 // @Param company_id path int true "ID da empresa"
 // @Param credential_id path int true "ID da credencial"
-// @Success 204 "Credencial removida com sucesso"
+// @Success 200 {object} models.CredentialInfo
+// @Failure 400 {object} SwaggerError "ID inválido"
 // @Failure 401 {object} SwaggerError "Autenticação necessária"
 // @Failure 403 {object} SwaggerError "Sem permissão para esta empresa"
 // @Failure 404 {object} SwaggerError "Credencial não encontrada"
 // @Failure 500 {object} SwaggerError "Erro interno"
 // @Security UserToken
-// @Router /companies/{company_id}/credentials/{credential_id} [delete]
-func (h *CredentialHandler) DeleteCredential(c *fiber.Ctx) error {
-	// Obter IDs
-	companyIDStr := c.Params("company_id")
-	credentialIDStr := c.Params("credential_id")
+// @Router /companies/{company_id}/credentials/{credential_id}/info [get]
+func (h *CredentialHandler) GetCredentialInfo(c *fiber.Ctx) error {
+	// Empresa resolvida (e acesso verificado) pelo CompanyMiddleware
+	companyID := middleware.GetCompanyFromContext(c).ID
 
-	companyID, err := strconv.ParseInt(companyIDStr, 10, 64)
+	credentialID, err := strconv.ParseInt(c.Params("credential_id"), 10, 64)
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid company ID",
+			"error": "Invalid credential ID",
 		})
 	}
 
-	credentialID, err := strconv.ParseInt(credentialIDStr, 10, 64)
+	credential := new(models.CompanyCredential)
+	err = database.DB.NewSelect().
+		Model(credential).
+		Where("id = ? AND company_id = ?", credentialID, companyID).
+		Scan(c.Context())
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Credential not found",
+		})
+	}
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid credential ID",
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch credential",
 		})
 	}
 
-	// Obter usuário do contexto
-	user := middleware.GetUserFromContext(c)
-	if user == nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "Authentication required",
+	info, err := credential.Info()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to decrypt credential",
 		})
 	}
 
-	// Verificar permissões do usuário para esta credencial
-	err = permissions.ValidateCredentialAccess(c.Context(), user, credentialID, companyID)
+	return respondData(c, fiber.StatusOK, info)
+}
+
+// DeleteCredential remove uma credencial
+// @Summary Deletar credencial
+// @Description Remove uma credencial (requer autenticação)
+// @Tags credentials
+// @Param company_id path int true "ID da empresa"
+// @Param credential_id path int true "ID da credencial"
+// @Success 204 "Credencial removida com sucesso"
+// @Failure 401 {object} SwaggerError "Autenticação necessária"
+// @Failure 403 {object} SwaggerError "Sem permissão para esta empresa"
+// @Failure 404 {object} SwaggerError "Credencial não encontrada"
+// @Failure 500 {object} SwaggerError "Erro interno"
+// @Security UserToken
+// @Router /companies/{company_id}/credentials/{credential_id} [delete]
+func (h *CredentialHandler) DeleteCredential(c *fiber.Ctx) error {
+	// Empresa resolvida (e acesso verificado) pelo CompanyMiddleware
+	companyID := middleware.GetCompanyFromContext(c).ID
+
+	credentialID, err := strconv.ParseInt(c.Params("credential_id"), 10, 64)
 	if err != nil {
-		if err == permissions.ErrCompanyNotFound {
-			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-				"error": "Company not found",
-			})
-		}
-		if err == permissions.ErrAccessDenied {
-			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
-				"error": "Access denied to this company",
-			})
-		}
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"error": "Credential not found",
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid credential ID",
 		})
 	}
 
 	// Deletar credencial
-	_, err = database.DB.NewDelete().
+	res, err := database.DB.NewDelete().
 		Model((*models.CompanyCredential)(nil)).
 		Where("id = ? AND company_id = ?", credentialID, companyID).
 		Exec(c.Context())
@@ -441,5 +385,11 @@ func (h *CredentialHandler) DeleteCredential(c *fiber.Ctx) error {
 		})
 	}
 
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Credential not found",
+		})
+	}
+
 	return c.SendStatus(fiber.StatusNoContent)
 }
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/zoomxml/internal/database/databasetest"
+	"github.com/zoomxml/internal/models"
+)
+
+func TestGetCredentialInfo(t *testing.T) {
+	databasetest.Require(t)
+	company := databasetest.CreateCompany(t, nil)
+	other := databasetest.CreateCompany(t, nil)
+
+	const login, password, token = "fiscal-login", "pass-secret-456", "tok-secret-123"
+	newCredential := func(companyID int64, credType, login, password, token string) *models.CompanyCredential {
+		credential := &models.CompanyCredential{CompanyID: companyID, Type: credType, Environment: models.EnvironmentProduction}
+		if err := credential.SetCredentialData(login, password, token); err != nil {
+			t.Fatal(err)
+		}
+		return databasetest.CreateCredential(t, credential)
+	}
+	tokenOnly := newCredential(company.ID, "prefeitura_token", "", "", token)
+	mixed := newCredential(company.ID, "prefeitura_mixed", login, password, token)
+	userPass := newCredential(company.ID, "prefeitura_user_pass", login, password, "")
+	foreign := newCredential(other.ID, "prefeitura_token", "", "", token)
+
+	tests := []struct {
+		name         string
+		credentialID string
+		wantStatus   int
+		wantHasToken bool
+		wantHasLogin bool
+	}{
+		{"token", fmt.Sprint(tokenOnly.ID), fiber.StatusOK, true, false},
+		{"mixed", fmt.Sprint(mixed.ID), fiber.StatusOK, true, true},
+		{"login and password", fmt.Sprint(userPass.ID), fiber.StatusOK, false, true},
+		{"credential of another company", fmt.Sprint(foreign.ID), fiber.StatusNotFound, false, false},
+		{"invalid id", "abc", fiber.StatusBadRequest, false, false},
+	}
+
+	app := companyApp(&models.User{ID: 1}, company, fiber.MethodGet, "/credentials/:credential_id/info", NewCredentialHandler().GetCredentialInfo)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := app.Test(httptest.NewRequest("GET", "/credentials/"+tt.credentialID+"/info", nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+
+			raw, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, secret := range []string{login, password, token, "encrypted_secret"} {
+				if strings.Contains(string(raw), secret) {
+					t.Errorf("response %s exposes %q", raw, secret)
+				}
+			}
+			if tt.wantStatus != fiber.StatusOK {
+				return
+			}
+
+			var info models.CredentialInfo
+			if err := json.Unmarshal(raw, &info); err != nil {
+				t.Fatal(err)
+			}
+			if info.HasToken != tt.wantHasToken || info.HasLogin != tt.wantHasLogin || info.Environment != models.EnvironmentProduction || !info.Active {
+				t.Errorf("GetCredentialInfo() = %+v, want has_token %v has_login %v", info, tt.wantHasToken, tt.wantHasLogin)
+			}
+		})
+	}
+}
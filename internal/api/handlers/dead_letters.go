@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/uptrace/bun"
+	"github.com/zoomxml/internal/api/middleware"
+	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/logger"
+	"github.com/zoomxml/internal/models"
+	"github.com/zoomxml/internal/services"
+)
+
+// DeadLetterReprocessResponse is the outcome of reprocessing a dead-lettered XML
+type DeadLetterReprocessResponse struct {
+	DeadLetter  *models.DeadLetter `json:"dead_letter"`
+	Success     bool               `json:"success"`
+	DocumentID  int64              `json:"document_id,omitempty"`
+	IsDuplicate bool               `json:"is_duplicate"`
+	Error       string             `json:"error,omitempty"`
+}
+
+// GetNFSeDeadLetters lists the XMLs of a company that permanently failed parsing or validation
+// @Summary List dead-lettered NFSe XMLs
+// @Description Lists fetched XMLs that failed parsing or validation and were kept for inspection, newest first
+// @Tags nfse
+// @Produce json
+// @Param company_id path int true "Company ID"
+// @Param status query string false "Status (pending, reprocessed)"
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page, at most 100 (larger values are reduced to 100)" default(20)
+// @Success 200 {object} fiber.Map
+// @Failure 400 {object} fiber.Map
+// @Failure 401 {object} fiber.Map
+// @Failure 403 {object} fiber.Map
+// @Failure 404 {object} fiber.Map
+// @Failure 500 {object} fiber.Map
+// @Router /api/companies/{company_id}/nfse/dead-letters [get]
+func (h *NFSeHandler) GetNFSeDeadLetters(c *fiber.Ctx) error {
+	// Company resolved (and access checked) by CompanyMiddleware
+	companyID := middleware.GetCompanyFromContext(c).ID
+
+	page, limit, err := parsePagination(c)
+	if err != nil {
+		return paginationError(c, err)
+	}
+	offset := (page - 1) * limit
+
+	status := c.Query("status")
+	filter := func(q *bun.SelectQuery) *bun.SelectQuery {
+		q = q.Where("company_id = ?", companyID)
+		if status != "" {
+			q = q.Where("status = ?", status)
+		}
+		return q
+	}
+
+	deadLetters := []models.DeadLetter{}
+	total, err := database.DB.NewSelect().
+		Model(&deadLetters).
+		Apply(filter).
+		Order("id DESC").
+		Limit(limit).
+		Offset(offset).
+		ScanAndCount(c.Context())
+
+	if err != nil {
+		logger.ErrorWithFields("Failed to fetch dead letters", err, map[string]any{
+			"operation":  "get_dead_letters",
+			"company_id": companyID,
+		})
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch dead letters",
+		})
+	}
+
+	return respondList(c, "dead_letters", deadLetters, page, limit, total)
+}
+
+// ReprocessNFSeDeadLetter ingests a dead-lettered XML again with the current parser and policies
+// @Summary Reprocess a dead-lettered NFSe XML
+// @Description Ingests the stored XML again. On success (stored or already present) the item is marked reprocessed;
+// @Description on failure its attempts and error are updated and the error is returned with status 422.
+// @Tags nfse
+// @Produce json
+// @Param company_id path int true "Company ID"
+// @Param dead_letter_id path int true "Dead letter ID"
+// @Success 200 {object} DeadLetterReprocessResponse
+// @Failure 400 {object} fiber.Map
+// @Failure 401 {object} fiber.Map
+// @Failure 403 {object} fiber.Map
+// @Failure 404 {object} fiber.Map
+// @Failure 409 {object} fiber.Map
+// @Failure 422 {object} DeadLetterReprocessResponse
+// @Failure 500 {object} fiber.Map
+// @Router /api/companies/{company_id}/nfse/dead-letters/{dead_letter_id}/reprocess [post]
+func (h *NFSeHandler) ReprocessNFSeDeadLetter(c *fiber.Ctx) error {
+	// Company resolved (and access checked) by CompanyMiddleware
+	companyID := middleware.GetCompanyFromContext(c).ID
+
+	deadLetterID, err := strconv.ParseInt(c.Params("dead_letter_id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid dead letter ID",
+		})
+	}
+
+	user := middleware.GetUserFromContext(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Authentication required",
+		})
+	}
+
+	deadLetter, err := services.GetDeadLetter(c.Context(), companyID, deadLetterID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Dead letter not found",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load dead letter",
+		})
+	}
+
+	result, err := h.nfseService.ReprocessDeadLetter(c.Context(), deadLetter)
+	if errors.Is(err, services.ErrDeadLetterReprocessed) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Dead letter already reprocessed",
+		})
+	}
+	if err != nil {
+		logger.ErrorWithFields("Failed to reprocess dead letter", err, map[string]any{
+			"operation":      "reprocess_dead_letter",
+			"company_id":     companyID,
+			"dead_letter_id": deadLetterID,
+		})
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to reprocess dead letter",
+		})
+	}
+
+	recordAudit(c, user, "UPDATE", "DeadLetter", deadLetter.ID, map[string]any{
+		"action":      "reprocess",
+		"company_id":  companyID,
+		"status":      deadLetter.Status,
+		"document_id": result.DocumentID,
+	})
+
+	response := DeadLetterReprocessResponse{
+		DeadLetter:  deadLetter,
+		Success:     result.Error == nil,
+		DocumentID:  result.DocumentID,
+		IsDuplicate: result.IsDuplicate,
+	}
+	if result.Error != nil {
+		response.Error = result.Error.Error()
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(response)
+	}
+
+	return respondData(c, fiber.StatusOK, response)
+}
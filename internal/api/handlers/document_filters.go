@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/uptrace/bun"
+)
+
+// DocumentFilter representa os filtros de busca de documentos aceitos via query string
+type DocumentFilter struct {
+	ServiceCode      string `json:"service_code,omitempty"`      // Item da lista de serviço (?service_code=)
+	NaturezaOperacao string `json:"natureza_operacao,omitempty"` // Natureza da operação (?natureza_operacao=)
+	Tag              string `json:"tag,omitempty"`               // Etiqueta do documento (?tag=)
+	RpsNumber        string `json:"rps_number,omitempty"`        // Número do RPS de origem (?rps_number=)
+	RpsSeries        string `json:"rps_series,omitempty"`        // Série do RPS de origem (?rps_series=)
+	LateArrival      bool   `json:"late_arrival,omitempty"`      // Só notas chegadas após o fechamento da competência (?late_arrival=true)
+}
+
+// ParseDocumentFilter lê os filtros de documentos da query string
+func ParseDocumentFilter(c *fiber.Ctx) DocumentFilter {
+	return DocumentFilter{
+		ServiceCode:      c.Query("service_code"),
+		NaturezaOperacao: c.Query("natureza_operacao"),
+		Tag:              c.Query("tag"),
+		RpsNumber:        strings.TrimSpace(c.Query("rps_number")),
+		RpsSeries:        strings.TrimSpace(c.Query("rps_series")),
+		LateArrival:      c.QueryBool("late_arrival", false),
+	}
+}
+
+// IsEmpty indica se nenhum filtro foi informado
+func (f DocumentFilter) IsEmpty() bool {
+	return f == DocumentFilter{}
+}
+
+// Apply aplica os filtros preenchidos à consulta de documentos (select, update ou delete)
+func (f DocumentFilter) Apply(q bun.QueryBuilder) bun.QueryBuilder {
+	if f.ServiceCode != "" {
+		q = q.Where("service_code = ?", f.ServiceCode)
+	}
+	if f.NaturezaOperacao != "" {
+		q = q.Where("natureza_operacao = ?", f.NaturezaOperacao)
+	}
+	if tag := normalizeTag(f.Tag); tag != "" {
+		q = q.Where("? = ANY(tags)", tag)
+	}
+	if f.RpsNumber != "" {
+		q = q.Where("rps_number = ?", f.RpsNumber)
+	}
+	if f.RpsSeries != "" {
+		q = q.Where("rps_series = ?", f.RpsSeries)
+	}
+	if f.LateArrival {
+		q = q.Where("late_arrival = true")
+	}
+	return q
+}
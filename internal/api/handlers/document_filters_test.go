@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/pgdialect"
+	"github.com/valyala/fasthttp"
+	"github.com/zoomxml/internal/database/databasetest"
+	"github.com/zoomxml/internal/models"
+)
+
+func TestDocumentFilterApply(t *testing.T) {
+	db := bun.NewDB(nil, pgdialect.New())
+
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+		empty bool
+	}{
+		{"no filter", "", nil, true},
+		{"service code", "service_code=01.07", []string{"service_code = '01.07'"}, false},
+		{"natureza da operação", "natureza_operacao=1", []string{"natureza_operacao = '1'"}, false},
+		{"service code and natureza", "service_code=17.01&natureza_operacao=2", []string{"service_code = '17.01'", "natureza_operacao = '2'"}, false},
+		{"tag is normalized", "tag=%20Contestado%20", []string{"'contestado' = ANY(tags)"}, false},
+		{"rps number and series", "rps_number=%2077%20&rps_series=RPS1", []string{"rps_number = '77'", "rps_series = 'RPS1'"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			c := app.AcquireCtx(&fasthttp.RequestCtx{})
+			defer app.ReleaseCtx(c)
+			c.Request().SetRequestURI("/documents?" + tt.query)
+
+			filter := ParseDocumentFilter(c)
+			if filter.IsEmpty() != tt.empty {
+				t.Errorf("IsEmpty() = %v, want %v", filter.IsEmpty(), tt.empty)
+			}
+
+			query := db.NewSelect().Model((*models.Document)(nil)).ApplyQueryBuilder(filter.Apply).String()
+			for _, want := range tt.want {
+				if !strings.Contains(query, want) {
+					t.Errorf("query %q does not filter by %q", query, want)
+				}
+			}
+			if tt.empty && !strings.HasSuffix(query, `WHERE "d"."deleted_at" IS NULL`) {
+				t.Errorf("query %q filters without a filter", query)
+			}
+		})
+	}
+}
+
+func TestGetNFSeDocumentsByServiceCode(t *testing.T) {
+	databasetest.Require(t)
+	company := databasetest.CreateCompany(t, nil)
+	databasetest.CreateDocument(t, &models.Document{CompanyID: company.ID, Number: "1", ServiceCode: "01.07"})
+	databasetest.CreateDocument(t, &models.Document{CompanyID: company.ID, Number: "2", ServiceCode: "17.01"})
+	databasetest.CreateDocument(t, &models.Document{CompanyID: company.ID, Number: "3", ServiceCode: "01.07"})
+
+	tests := []struct {
+		name        string
+		query       string
+		wantNumbers []string
+	}{
+		{"without filter", "", []string{"1", "2", "3"}},
+		{"matching service code", "?service_code=01.07", []string{"1", "3"}},
+		{"other service code", "?service_code=17.01", []string{"2"}},
+		{"unknown service code", "?service_code=99.99", []string{}},
+	}
+
+	app := companyApp(&models.User{ID: 1}, company, fiber.MethodGet, "/documents", NewNFSeHandler().GetNFSeDocuments)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertDocumentNumbers(t, app, tt.query, tt.wantNumbers)
+		})
+	}
+}
+
+func TestGetNFSeDocumentsByRps(t *testing.T) {
+	databasetest.Require(t)
+	company := databasetest.CreateCompany(t, nil)
+	databasetest.CreateDocument(t, &models.Document{CompanyID: company.ID, Number: "1", RpsNumber: "77", RpsSeries: "A"})
+	databasetest.CreateDocument(t, &models.Document{CompanyID: company.ID, Number: "2", RpsNumber: "77", RpsSeries: "B"})
+	databasetest.CreateDocument(t, &models.Document{CompanyID: company.ID, Number: "3", RpsNumber: "78", RpsSeries: "A"})
+
+	tests := []struct {
+		name        string
+		query       string
+		wantNumbers []string
+	}{
+		{"rps number", "?rps_number=77", []string{"1", "2"}},
+		{"rps number and series", "?rps_number=77&rps_series=B", []string{"2"}},
+		{"rps series", "?rps_series=A", []string{"1", "3"}},
+		{"unknown rps", "?rps_number=99", []string{}},
+	}
+
+	app := companyApp(&models.User{ID: 1}, company, fiber.MethodGet, "/documents", NewNFSeHandler().GetNFSeDocuments)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertDocumentNumbers(t, app, tt.query, tt.wantNumbers)
+		})
+	}
+}
+
+// assertDocumentNumbers lists the documents of app with query and checks their numbers
+func assertDocumentNumbers(t *testing.T, app *fiber.App, query string, wantNumbers []string) {
+	t.Helper()
+	resp, err := app.Test(httptest.NewRequest("GET", "/documents"+query, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, fiber.StatusOK)
+	}
+
+	var body struct {
+		Documents []models.Document `json:"documents"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	numbers := map[string]bool{}
+	for _, document := range body.Documents {
+		numbers[document.Number] = true
+	}
+	if len(numbers) != len(wantNumbers) {
+		t.Errorf("documents = %v, want %v", numbers, wantNumbers)
+	}
+	for _, number := range wantNumbers {
+		if !numbers[number] {
+			t.Errorf("document %s missing from %v", number, numbers)
+		}
+	}
+}
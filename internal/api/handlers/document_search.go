@@ -0,0 +1,271 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/uptrace/bun"
+	"github.com/zoomxml/internal/api/middleware"
+	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/logger"
+	"github.com/zoomxml/internal/models"
+	"github.com/zoomxml/internal/services"
+)
+
+// DocumentSearch representa os filtros da busca de documentos: os filtros da listagem
+// mais campos estruturados e busca textual na discriminação do serviço
+type DocumentSearch struct {
+	DocumentFilter
+
+	Type             string    // Tipo do documento: nfse (padrão), nfe ou cte (?type=)
+	Number           string    // Número da nota (?number=)
+	VerificationCode string    // Código de verificação, ignorando pontuação e caixa (?verification_code=)
+	ProviderCNPJ     string    // CNPJ do prestador, com ou sem máscara (?provider_cnpj=)
+	TakerCNPJ        string    // CNPJ do tomador, com ou sem máscara (?taker_cnpj=)
+	MinValue         *float64  // Valor de serviço mínimo (?min_value=)
+	MaxValue         *float64  // Valor de serviço máximo (?max_value=)
+	StartDate        time.Time // Emissão a partir de (?start_date=YYYY-MM-DD)
+	EndDate          time.Time // Emissão até, inclusive (?end_date=YYYY-MM-DD)
+	Status           string    // Status do documento (?status=)
+	IsCancelled      *bool     // Notas canceladas ou não (?is_cancelled=)
+	IsSubstituted    *bool     // Notas substituídas ou não (?is_substituted=)
+	Text             string    // Busca textual na discriminação (?q=)
+}
+
+// ParseDocumentSearch lê os filtros da busca de documentos da query string
+func ParseDocumentSearch(c *fiber.Ctx) (DocumentSearch, error) {
+	search := DocumentSearch{
+		DocumentFilter:   ParseDocumentFilter(c),
+		Type:             strings.ToLower(strings.TrimSpace(c.Query("type", models.DocumentTypeNFSe))),
+		Number:           strings.TrimSpace(c.Query("number")),
+		VerificationCode: strings.TrimSpace(c.Query("verification_code")),
+		ProviderCNPJ:     strings.TrimSpace(c.Query("provider_cnpj")),
+		TakerCNPJ:        strings.TrimSpace(c.Query("taker_cnpj")),
+		Status:           strings.TrimSpace(c.Query("status")),
+		Text:             strings.TrimSpace(c.Query("q")),
+	}
+
+	switch search.Type {
+	case models.DocumentTypeNFSe, models.DocumentTypeNFe, models.DocumentTypeCTe:
+	default:
+		return search, fmt.Errorf("invalid type, use nfse, nfe or cte")
+	}
+
+	var err error
+	if search.MinValue, err = parseOptionalFloat(c, "min_value"); err != nil {
+		return search, err
+	}
+	if search.MaxValue, err = parseOptionalFloat(c, "max_value"); err != nil {
+		return search, err
+	}
+	if search.MinValue != nil && search.MaxValue != nil && *search.MaxValue < *search.MinValue {
+		return search, fmt.Errorf("max_value must not be less than min_value")
+	}
+
+	if raw := c.Query("start_date"); raw != "" {
+		if search.StartDate, err = time.Parse("2006-01-02", raw); err != nil {
+			return search, fmt.Errorf("invalid start_date format, use YYYY-MM-DD")
+		}
+	}
+	if raw := c.Query("end_date"); raw != "" {
+		if search.EndDate, err = time.Parse("2006-01-02", raw); err != nil {
+			return search, fmt.Errorf("invalid end_date format, use YYYY-MM-DD")
+		}
+	}
+	if !search.StartDate.IsZero() && !search.EndDate.IsZero() && search.EndDate.Before(search.StartDate) {
+		return search, fmt.Errorf("end_date must be after start_date")
+	}
+
+	if search.IsCancelled, err = parseOptionalBool(c, "is_cancelled"); err != nil {
+		return search, err
+	}
+	if search.IsSubstituted, err = parseOptionalBool(c, "is_substituted"); err != nil {
+		return search, err
+	}
+
+	return search, nil
+}
+
+// Apply aplica os filtros preenchidos à consulta de documentos. O código de verificação é
+// comparado normalizado dos dois lados; CNPJs, como informados e normalizados, o que usa
+// os índices existentes.
+func (s DocumentSearch) Apply(q bun.QueryBuilder) bun.QueryBuilder {
+	q = s.DocumentFilter.Apply(q)
+	q = q.Where("type = ?", s.Type)
+
+	if s.Number != "" {
+		q = q.Where("number = ?", s.Number)
+	}
+	if s.VerificationCode != "" {
+		if normalized := services.NormalizeVerificationCode(s.VerificationCode); normalized != "" {
+			q = q.Where(services.VerificationCodeSQL+" = ?", normalized)
+		} else {
+			q = q.Where("verification_code = ?", s.VerificationCode)
+		}
+	}
+	if s.ProviderCNPJ != "" {
+		q = q.Where("provider_cnpj IN (?)", bun.In(searchCandidates(s.ProviderCNPJ, services.NormalizeCNPJ)))
+	}
+	if s.TakerCNPJ != "" {
+		q = q.Where("taker_cnpj IN (?)", bun.In(searchCandidates(s.TakerCNPJ, services.NormalizeCNPJ)))
+	}
+	if s.MinValue != nil {
+		q = q.Where("service_value >= ?", *s.MinValue)
+	}
+	if s.MaxValue != nil {
+		q = q.Where("service_value <= ?", *s.MaxValue)
+	}
+	if !s.StartDate.IsZero() {
+		q = q.Where("issue_date >= ?", s.StartDate)
+	}
+	if !s.EndDate.IsZero() {
+		q = q.Where("issue_date < ?", s.EndDate.AddDate(0, 0, 1))
+	}
+	if s.Status != "" {
+		q = q.Where("status = ?", s.Status)
+	}
+	if s.IsCancelled != nil {
+		q = q.Where("is_cancelled = ?", *s.IsCancelled)
+	}
+	if s.IsSubstituted != nil {
+		q = q.Where("is_substituted = ?", *s.IsSubstituted)
+	}
+	if s.Text != "" {
+		// Mesma expressão de idx_documents_discriminacao_fts
+		q = q.Where("to_tsvector('portuguese', COALESCE(discriminacao, '')) @@ plainto_tsquery('portuguese', ?)", s.Text)
+	}
+	return q
+}
+
+// searchCandidates devolve o valor informado e sua forma normalizada, sem repetição
+func searchCandidates(raw string, normalize func(string) string) []string {
+	candidates := []string{raw}
+	if normalized := normalize(raw); normalized != "" && normalized != raw {
+		candidates = append(candidates, normalized)
+	}
+	return candidates
+}
+
+// parseOptionalFloat lê um número opcional da query string
+func parseOptionalFloat(c *fiber.Ctx, key string) (*float64, error) {
+	raw := c.Query(key)
+	if raw == "" {
+		return nil, nil
+	}
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s, expected a number", key)
+	}
+	return &value, nil
+}
+
+// parseOptionalBool lê um booleano opcional da query string
+func parseOptionalBool(c *fiber.Ctx, key string) (*bool, error) {
+	raw := c.Query(key)
+	if raw == "" {
+		return nil, nil
+	}
+	value, err := strconv.ParseBool(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s, expected true or false", key)
+	}
+	return &value, nil
+}
+
+// SearchDocuments searches the stored documents of a company, NFSe unless another type is asked for
+// @Summary Search NFSe documents
+// @Description Searches stored NFSe documents (or, with type, NF-e and CT-e) by number, verification code, provider/taker CNPJ, service value and issue date ranges,
+// @Description status, cancelled/substituted flags and free text on the service description (discriminação). The filters of the
+// @Description document listing (service_code, natureza_operacao, tag, rps_number, rps_series, late_arrival) are accepted as well.
+// @Tags nfse
+// @Produce json
+// @Param company_id path int true "Company ID"
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page, at most 100 (larger values are reduced to 100)" default(20)
+// @Param type query string false "Document type: nfse, nfe or cte" default(nfse)
+// @Param number query string false "Document number"
+// @Param verification_code query string false "Verification code (punctuation and case are ignored)"
+// @Param provider_cnpj query string false "Provider CNPJ"
+// @Param taker_cnpj query string false "Taker CNPJ"
+// @Param min_value query number false "Minimum service value"
+// @Param max_value query number false "Maximum service value"
+// @Param start_date query string false "Start issue date (YYYY-MM-DD)"
+// @Param end_date query string false "End issue date (YYYY-MM-DD)"
+// @Param status query string false "Document status"
+// @Param is_cancelled query bool false "Cancelled notes"
+// @Param is_substituted query bool false "Substituted notes"
+// @Param q query string false "Free text on the service description"
+// @Success 200 {object} fiber.Map
+// @Failure 400 {object} fiber.Map
+// @Failure 401 {object} fiber.Map
+// @Failure 403 {object} fiber.Map
+// @Failure 404 {object} fiber.Map
+// @Failure 500 {object} fiber.Map
+// @Router /api/companies/{company_id}/documents/search [get]
+func (h *NFSeHandler) SearchDocuments(c *fiber.Ctx) error {
+	// Company resolved (and access checked) by CompanyMiddleware
+	companyID := middleware.GetCompanyFromContext(c).ID
+
+	user := middleware.GetUserFromContext(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Authentication required",
+		})
+	}
+
+	page, limit, err := parsePagination(c)
+	if err != nil {
+		return paginationError(c, err)
+	}
+	offset := (page - 1) * limit
+
+	search, err := ParseDocumentSearch(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	documents := []models.Document{}
+	err = database.DB.NewSelect().
+		Model(&documents).
+		Where("company_id = ?", companyID).
+		ApplyQueryBuilder(search.Apply).
+		Order("issue_date DESC", "id DESC").
+		Limit(limit).
+		Offset(offset).
+		Scan(c.Context())
+
+	if err != nil {
+		logger.ErrorWithFields("Failed to search NFSe documents", err, map[string]any{
+			"operation":  "search_documents",
+			"company_id": companyID,
+			"user_id":    user.ID,
+		})
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to search documents",
+		})
+	}
+
+	total, err := database.DB.NewSelect().
+		Model((*models.Document)(nil)).
+		Where("company_id = ?", companyID).
+		ApplyQueryBuilder(search.Apply).
+		Count(c.Context())
+
+	if err != nil {
+		logger.ErrorWithFields("Failed to count NFSe documents", err, map[string]any{
+			"operation":  "search_documents",
+			"company_id": companyID,
+			"user_id":    user.ID,
+		})
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to count documents",
+		})
+	}
+
+	return respondList(c, "documents", documents, page, limit, total)
+}
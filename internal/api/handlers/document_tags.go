@@ -0,0 +1,209 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/pgdialect"
+	"github.com/zoomxml/internal/api/middleware"
+	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/logger"
+	"github.com/zoomxml/internal/models"
+)
+
+// Limites das etiquetas de documentos
+const (
+	maxDocumentTags = 20
+	maxTagLength    = 50
+)
+
+// DocumentTagsRequest representa as etiquetas a adicionar ou remover de um documento
+type DocumentTagsRequest struct {
+	Tags []string `json:"tags" validate:"required,min=1"`
+}
+
+// normalizeTag remove espaços e padroniza a etiqueta em minúsculas
+func normalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
+
+// normalizeTags normaliza e remove etiquetas vazias ou repetidas, validando o tamanho
+func normalizeTags(tags []string) ([]string, error) {
+	normalized := []string{}
+	for _, tag := range tags {
+		tag = normalizeTag(tag)
+		if tag == "" || slices.Contains(normalized, tag) {
+			continue
+		}
+		if utf8.RuneCountInString(tag) > maxTagLength {
+			return nil, fmt.Errorf("tag %q exceeds %d characters", tag, maxTagLength)
+		}
+		normalized = append(normalized, tag)
+	}
+
+	if len(normalized) == 0 {
+		return nil, errors.New("at least one non-empty tag is required")
+	}
+
+	return normalized, nil
+}
+
+// AddNFSeDocumentTags adiciona etiquetas a um documento
+// @Summary Add tags to an NFSe document
+// @Description Adds user tags (e.g. contestado, conciliado) to a document. Tags are lowercased; at most 20 tags of up to 50 characters per document.
+// @Tags nfse
+// @Accept json
+// @Produce json
+// @Param company_id path int true "Company ID"
+// @Param document_id path int true "Document ID"
+// @Param request body DocumentTagsRequest true "Tags to add"
+// @Success 200 {object} fiber.Map
+// @Failure 400 {object} fiber.Map
+// @Failure 401 {object} fiber.Map
+// @Failure 403 {object} fiber.Map
+// @Failure 404 {object} fiber.Map
+// @Failure 500 {object} fiber.Map
+// @Router /api/companies/{company_id}/nfse/{document_id}/tags [post]
+func (h *NFSeHandler) AddNFSeDocumentTags(c *fiber.Ctx) error {
+	return h.updateDocumentTags(c, func(current, tags []string) ([]string, error) {
+		for _, tag := range tags {
+			if !slices.Contains(current, tag) {
+				current = append(current, tag)
+			}
+		}
+		if len(current) > maxDocumentTags {
+			return nil, fmt.Errorf("a document can have at most %d tags", maxDocumentTags)
+		}
+		return current, nil
+	})
+}
+
+// RemoveNFSeDocumentTags remove etiquetas de um documento
+// @Summary Remove tags from an NFSe document
+// @Description Removes the given tags from a document; unknown tags are ignored
+// @Tags nfse
+// @Accept json
+// @Produce json
+// @Param company_id path int true "Company ID"
+// @Param document_id path int true "Document ID"
+// @Param request body DocumentTagsRequest true "Tags to remove"
+// @Success 200 {object} fiber.Map
+// @Failure 400 {object} fiber.Map
+// @Failure 401 {object} fiber.Map
+// @Failure 403 {object} fiber.Map
+// @Failure 404 {object} fiber.Map
+// @Failure 500 {object} fiber.Map
+// @Router /api/companies/{company_id}/nfse/{document_id}/tags [delete]
+func (h *NFSeHandler) RemoveNFSeDocumentTags(c *fiber.Ctx) error {
+	return h.updateDocumentTags(c, func(current, tags []string) ([]string, error) {
+		return slices.DeleteFunc(current, func(tag string) bool {
+			return slices.Contains(tags, tag)
+		}), nil
+	})
+}
+
+// updateDocumentTags carrega o documento, aplica a alteração nas etiquetas e grava o
+// resultado na mesma transação
+func (h *NFSeHandler) updateDocumentTags(c *fiber.Ctx, change func(current, tags []string) ([]string, error)) error {
+	// Company resolved (and access checked) by CompanyMiddleware
+	companyID := middleware.GetCompanyFromContext(c).ID
+
+	documentID, err := strconv.ParseInt(c.Params("document_id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid document ID",
+		})
+	}
+
+	user := middleware.GetUserFromContext(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Authentication required",
+		})
+	}
+
+	var req DocumentTagsRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := validateStruct(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": err,
+		})
+	}
+
+	tags, err := normalizeTags(req.Tags)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	// A linha fica bloqueada até a gravação, para que alterações simultâneas não percam
+	// etiquetas nem ultrapassem o limite
+	var updated []string
+	var changeErr error
+	err = database.DB.RunInTx(c.Context(), &sql.TxOptions{}, func(ctx context.Context, tx bun.Tx) error {
+		document := &models.Document{}
+		err := tx.NewSelect().
+			Model(document).
+			Column("id", "tags").
+			Where("id = ? AND company_id = ?", documentID, companyID).
+			For("UPDATE").
+			Scan(ctx)
+		if err != nil {
+			return err
+		}
+
+		if updated, changeErr = change(slices.Clone(document.Tags), tags); changeErr != nil {
+			return changeErr
+		}
+
+		_, err = tx.NewUpdate().
+			Model((*models.Document)(nil)).
+			Set("tags = ?", pgdialect.Array(updated)).
+			Set("updated_at = current_timestamp").
+			Where("id = ? AND company_id = ?", documentID, companyID).
+			Exec(ctx)
+		return err
+	})
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Document not found",
+		})
+	}
+	if changeErr != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": changeErr.Error(),
+		})
+	}
+	if err != nil {
+		logger.ErrorWithFields("Failed to update document tags", err, map[string]any{
+			"operation":   "update_document_tags",
+			"company_id":  companyID,
+			"document_id": documentID,
+			"user_id":     user.ID,
+		})
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update tags",
+		})
+	}
+
+	return respondData(c, fiber.StatusOK, fiber.Map{
+		"document_id": documentID,
+		"tags":        updated,
+	})
+}
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/zoomxml/internal/database/databasetest"
+	"github.com/zoomxml/internal/models"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	tests := []struct {
+		name    string
+		tags    []string
+		want    []string
+		wantErr bool
+	}{
+		{"lowercased and trimmed", []string{" Contestado ", "CONCILIADO"}, []string{"contestado", "conciliado"}, false},
+		{"duplicates removed", []string{"conciliado", "Conciliado", "conciliado "}, []string{"conciliado"}, false},
+		{"blank tags skipped", []string{"", "  ", "revisar"}, []string{"revisar"}, false},
+		{"only blank tags", []string{"", " "}, nil, true},
+		{"longest allowed tag", []string{strings.Repeat("é", maxTagLength)}, []string{strings.Repeat("é", maxTagLength)}, false},
+		{"tag too long", []string{strings.Repeat("a", maxTagLength+1)}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := normalizeTags(tt.tags)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("normalizeTags() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("normalizeTags() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// tagsRequest sends a tag change for documentID through app and decodes the response
+func tagsRequest(t *testing.T, app *fiber.App, method string, documentID int64, tags ...string) (int, []string) {
+	t.Helper()
+	body, err := json.Marshal(DocumentTagsRequest{Tags: tags})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(method, fmt.Sprintf("/documents/%d/tags", documentID), strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Tags []string `json:"tags"`
+	}
+	if resp.StatusCode == fiber.StatusOK {
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return resp.StatusCode, result.Tags
+}
+
+func TestNFSeDocumentTags(t *testing.T) {
+	databasetest.Require(t)
+	company := databasetest.CreateCompany(t, nil)
+	other := databasetest.CreateCompany(t, nil)
+	document := databasetest.CreateDocument(t, &models.Document{CompanyID: company.ID, Number: "1"})
+	full := make([]string, maxDocumentTags)
+	for i := range full {
+		full[i] = fmt.Sprintf("tag-%d", i)
+	}
+	crowded := databasetest.CreateDocument(t, &models.Document{CompanyID: company.ID, Number: "2", Tags: full})
+	foreign := databasetest.CreateDocument(t, &models.Document{CompanyID: other.ID, Number: "3"})
+
+	user := &models.User{ID: 1}
+	handler := NewNFSeHandler()
+	add := companyApp(user, company, fiber.MethodPost, "/documents/:document_id/tags", handler.AddNFSeDocumentTags)
+	remove := companyApp(user, company, fiber.MethodDelete, "/documents/:document_id/tags", handler.RemoveNFSeDocumentTags)
+
+	tests := []struct {
+		name       string
+		app        *fiber.App
+		method     string
+		documentID int64
+		tags       []string
+		wantStatus int
+		wantTags   []string
+	}{
+		{"add", add, fiber.MethodPost, document.ID, []string{"Contestado", "conciliado"}, fiber.StatusOK, []string{"contestado", "conciliado"}},
+		{"add existing tag", add, fiber.MethodPost, document.ID, []string{"contestado"}, fiber.StatusOK, []string{"contestado", "conciliado"}},
+		{"remove", remove, fiber.MethodDelete, document.ID, []string{"contestado", "unknown"}, fiber.StatusOK, []string{"conciliado"}},
+		{"too long", add, fiber.MethodPost, document.ID, []string{strings.Repeat("a", maxTagLength+1)}, fiber.StatusBadRequest, nil},
+		{"over the count limit", add, fiber.MethodPost, crowded.ID, []string{"extra"}, fiber.StatusBadRequest, nil},
+		{"existing tag at the count limit", add, fiber.MethodPost, crowded.ID, []string{"tag-0"}, fiber.StatusOK, full},
+		{"document of another company", add, fiber.MethodPost, foreign.ID, []string{"contestado"}, fiber.StatusNotFound, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, tags := tagsRequest(t, tt.app, tt.method, tt.documentID, tt.tags...)
+			if status != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", status, tt.wantStatus)
+			}
+			if tt.wantStatus == fiber.StatusOK && !slices.Equal(tags, tt.wantTags) {
+				t.Errorf("tags = %v, want %v", tags, tt.wantTags)
+			}
+		})
+	}
+
+	// The filter sees the tags stored above
+	list := companyApp(user, company, fiber.MethodGet, "/documents", handler.GetNFSeDocuments)
+	filters := []struct {
+		tag         string
+		wantNumbers []string
+	}{
+		{"Conciliado", []string{"1"}},
+		{"tag-0", []string{"2"}},
+		{"contestado", []string{}},
+	}
+	for _, tt := range filters {
+		t.Run("filter "+tt.tag, func(t *testing.T) {
+			resp, err := list.Test(httptest.NewRequest("GET", "/documents?tag="+tt.tag, nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			var body struct {
+				Documents []models.Document `json:"documents"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			numbers := []string{}
+			for _, document := range body.Documents {
+				numbers = append(numbers, document.Number)
+			}
+			slices.Sort(numbers)
+			if !slices.Equal(numbers, tt.wantNumbers) {
+				t.Errorf("documents tagged %q = %v, want %v", tt.tag, numbers, tt.wantNumbers)
+			}
+		})
+	}
+}
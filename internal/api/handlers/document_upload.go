@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/zoomxml/internal/api/middleware"
+	"github.com/zoomxml/internal/services"
+)
+
+// zipSignature starts every ZIP archive (local file header)
+var zipSignature = []byte("PK\x03\x04")
+
+// UploadDocuments registers XML files uploaded manually, loose or inside ZIP archives
+// @Summary Upload XML documents
+// @Description Registers NFSe, NF-e and CT-e XML files sent by the user, e.g. received by e-mail. Each file in 'files' may be an XML or a ZIP archive, whose .xml entries are extracted (limited by NFSE_ZIP_MAX_*). The document type is detected from each XML. The XMLs of one request, loose or extracted, may add up to at most NFSE_ZIP_MAX_TOTAL_BYTES. Results are listed per file; ZIP entries are named archive.zip/entry.xml. Duplicates are skipped unless overwrite=true.
+// @Tags nfse
+// @Accept multipart/form-data
+// @Produce json
+// @Param company_id path int true "Company ID"
+// @Param files formData file true "XML files or ZIP archives of XML files"
+// @Param overwrite query bool false "Replace existing documents" default(false)
+// @Success 200 {object} fiber.Map
+// @Failure 400 {object} fiber.Map
+// @Failure 401 {object} fiber.Map
+// @Failure 403 {object} fiber.Map
+// @Failure 404 {object} fiber.Map
+// @Failure 413 {object} fiber.Map
+// @Failure 500 {object} fiber.Map
+// @Router /api/companies/{company_id}/documents/upload [post]
+func (h *NFSeHandler) UploadDocuments(c *fiber.Ctx) error {
+	// Company resolved (and access checked) by CompanyMiddleware
+	companyID := middleware.GetCompanyFromContext(c).ID
+
+	user := middleware.GetUserFromContext(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Authentication required",
+		})
+	}
+
+	form, err := c.MultipartForm()
+	if err != nil || len(form.File["files"]) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "At least one XML or ZIP file is required in the 'files' field",
+		})
+	}
+
+	// The total limit applies to the whole request, not to each archive
+	limits := services.DefaultZipLimits()
+	var total int64
+	tooLarge := func() error {
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+			"error": fmt.Sprintf("The uploaded XMLs expand past %d bytes", limits.MaxTotalBytes),
+		})
+	}
+
+	documents := []services.NFSeDocument{}
+	// Names reported per file; ZIP entries are prefixed with their archive only here, not
+	// in the name handed to ingest
+	names := []string{}
+	for _, fileHeader := range form.File["files"] {
+		file, err := fileHeader.Open()
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Failed to read file " + fileHeader.Filename,
+			})
+		}
+		content, err := io.ReadAll(file)
+		file.Close()
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Failed to read file " + fileHeader.Filename,
+			})
+		}
+
+		fileName := filepath.Base(fileHeader.Filename)
+		if !bytes.HasPrefix(content, zipSignature) && !strings.EqualFold(filepath.Ext(fileName), ".zip") {
+			total += int64(len(content))
+			if limits.MaxTotalBytes > 0 && total > limits.MaxTotalBytes {
+				return tooLarge()
+			}
+			documents = append(documents, services.NFSeDocument{
+				FileName:    fileName,
+				XMLContent:  string(content),
+				ProcessedAt: time.Now(),
+			})
+			names = append(names, fileName)
+			continue
+		}
+
+		archiveLimits := limits
+		if limits.MaxTotalBytes > 0 {
+			if total >= limits.MaxTotalBytes {
+				return tooLarge()
+			}
+			archiveLimits.MaxTotalBytes = limits.MaxTotalBytes - total
+		}
+
+		entries, err := services.ExtractZipDocuments(content, archiveLimits, true)
+		if errors.Is(err, services.ErrZipLimitExceeded) {
+			return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+				"error": fileName + ": " + err.Error(),
+			})
+		}
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": fileName + " is not a valid ZIP archive",
+			})
+		}
+		if len(entries) == 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "The ZIP archive " + fileName + " has no XML files",
+			})
+		}
+
+		for _, entry := range entries {
+			total += int64(len(entry.XMLContent))
+			documents = append(documents, entry)
+			names = append(names, fileName+"/"+entry.FileName)
+		}
+	}
+
+	return h.importUploads(c, user, companyID, documents, names)
+}
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/zoomxml/internal/models"
+	"github.com/zoomxml/internal/repository/repositorytest"
+	"github.com/zoomxml/internal/services"
+)
+
+// buildZip writes an archive with the given entries, in order
+func buildZip(t *testing.T, entries ...[2]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	writer := zip.NewWriter(&buf)
+	for _, entry := range entries {
+		w, err := writer.Create(entry[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(entry[1]))
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// multipartRequest builds a POST to target with one part per [name, content] file of field
+func multipartRequest(t *testing.T, target, field string, files ...[2]string) *http.Request {
+	t.Helper()
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	for _, file := range files {
+		part, err := writer.CreateFormFile(field, file[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		part.Write([]byte(file[1]))
+	}
+	writer.Close()
+
+	req := httptest.NewRequest("POST", target, body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+// uploadApp serves handler on POST path for company 1, storing documents in the returned
+// fake repository
+func uploadApp(t *testing.T, path string, handler func(*NFSeHandler) fiber.Handler) (*fiber.App, *repositorytest.DocumentRepository) {
+	t.Helper()
+	useFakeIngest(t)
+	company := &models.Company{ID: 1, CNPJ: "12345678000190", ZeroValuePolicy: models.ZeroValuePolicyFlag}
+	documents := &repositorytest.DocumentRepository{}
+	companies := &repositorytest.CompanyRepository{Companies: map[int64]*models.Company{company.ID: company}}
+	nfseHandler := NewNFSeHandlerWithService(services.NewNFSeServiceWithRepositories(nil, documents, companies))
+	return companyApp(&models.User{ID: 1}, company, fiber.MethodPost, path, handler(nfseHandler)), documents
+}
+
+// uploadResults posts req to app and returns the per-file results
+func uploadResults(t *testing.T, app *fiber.App, req *http.Request) []UploadNFSeResult {
+	t.Helper()
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, fiber.StatusOK)
+	}
+	var body struct {
+		Results []UploadNFSeResult `json:"results"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	return body.Results
+}
+
+// TestUploadNFSeZipSameFileNames uploads an archive with a nota.xml in two folders and
+// checks that both notes are stored apart and reported under their own path
+func TestUploadNFSeZipSameFileNames(t *testing.T) {
+	useResponseEnvelope(t, false)
+	app, documents := uploadApp(t, "/upload-zip", func(h *NFSeHandler) fiber.Handler { return h.UploadNFSeZip })
+
+	archive := buildZip(t,
+		[2]string{"janeiro/nota.xml", testNFSeXML("1", "AAA")},
+		[2]string{"fevereiro/nota.xml", testNFSeXML("2", "BBB")},
+	)
+	results := uploadResults(t, app, multipartRequest(t, "/upload-zip", "file", [2]string{"notas.zip", string(archive)}))
+
+	wantNames := []string{"janeiro/nota.xml", "fevereiro/nota.xml"}
+	if len(results) != len(wantNames) {
+		t.Fatalf("results = %d, want %d", len(results), len(wantNames))
+	}
+	for i, result := range results {
+		if !result.Success || result.FileName != wantNames[i] {
+			t.Errorf("result %d = %+v, want %s stored", i, result, wantNames[i])
+		}
+	}
+
+	if len(documents.Documents) != 2 || documents.Documents[0].StorageKey == documents.Documents[1].StorageKey {
+		t.Fatalf("stored %d documents, want 2 with an object each", len(documents.Documents))
+	}
+	for i, document := range documents.Documents {
+		if document.OriginalFileName != wantNames[i] {
+			t.Errorf("OriginalFileName = %q, want %q", document.OriginalFileName, wantNames[i])
+		}
+	}
+}
+
+// TestUploadDocumentsArchiveNames checks that ZIP entries are reported under their archive
+// while ingest records, and keys the storage by, the entry alone
+func TestUploadDocumentsArchiveNames(t *testing.T) {
+	useResponseEnvelope(t, false)
+	app, documents := uploadApp(t, "/upload", func(h *NFSeHandler) fiber.Handler { return h.UploadDocuments })
+
+	archive := buildZip(t, [2]string{"janeiro/nota.xml", testNFSeXML("2", "BBB")})
+	results := uploadResults(t, app, multipartRequest(t, "/upload", "files",
+		[2]string{"nota.xml", testNFSeXML("1", "AAA")},
+		[2]string{"archive.zip", string(archive)},
+	))
+
+	wantResults := []string{"nota.xml", "archive.zip/janeiro/nota.xml"}
+	wantRecorded := []string{"nota.xml", "janeiro/nota.xml"}
+	if len(results) != len(wantResults) || len(documents.Documents) != len(wantRecorded) {
+		t.Fatalf("results = %d, documents = %d, want %d", len(results), len(documents.Documents), len(wantResults))
+	}
+	for i, result := range results {
+		if !result.Success || result.FileName != wantResults[i] {
+			t.Errorf("result %d = %+v, want %s stored", i, result, wantResults[i])
+		}
+	}
+	for i, document := range documents.Documents {
+		if document.OriginalFileName != wantRecorded[i] {
+			t.Errorf("OriginalFileName = %q, want %q", document.OriginalFileName, wantRecorded[i])
+		}
+		if strings.Contains(document.StorageKey, "archive.zip") {
+			t.Errorf("storage key %s contains the archive name", document.StorageKey)
+		}
+	}
+}
@@ -0,0 +1,300 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/zoomxml/internal/api/middleware"
+	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/logger"
+	"github.com/zoomxml/internal/models"
+	"github.com/zoomxml/internal/services"
+)
+
+// ExportTargetRequest representa o bucket S3 do cliente para exportação dos XMLs
+type ExportTargetRequest struct {
+	Endpoint  string `json:"endpoint" validate:"required"` // ex.: s3.amazonaws.com
+	Region    string `json:"region,omitempty"`
+	Bucket    string `json:"bucket" validate:"required"`
+	Prefix    string `json:"prefix,omitempty"`  // Prefixo das chaves no bucket de destino
+	UseSSL    *bool  `json:"use_ssl,omitempty"` // Padrão: true
+	AccessKey string `json:"access_key" validate:"required"`
+	SecretKey string `json:"secret_key" validate:"required"`
+}
+
+// ExportStatusResponse é o progresso de uma exportação de empresa
+type ExportStatusResponse struct {
+	*models.ExportJob
+	Remaining int     `json:"remaining"`
+	Percent   float64 `json:"percent"`
+}
+
+// newExportStatusResponse calcula o progresso de uma exportação
+func newExportStatusResponse(job *models.ExportJob) ExportStatusResponse {
+	response := ExportStatusResponse{ExportJob: job, Percent: 100}
+	if job.Status != models.ExportStatusCompleted {
+		done := job.Copied + job.Skipped + job.Failed
+		response.Remaining = max(job.Total-done, 0)
+		if job.Total > 0 {
+			response.Percent = float64(done) * 100 / float64(job.Total)
+		}
+	}
+	return response
+}
+
+// GetExportTarget obtém o bucket de exportação de uma empresa (apenas admin)
+// @Summary Obter destino de exportação
+// @Description Retorna o bucket S3 do cliente configurado para a empresa, sem a chave secreta
+// @Tags companies
+// @Produce json
+// @Param id path int true "ID da empresa"
+// @Success 200 {object} models.CompanyExportTarget
+// @Failure 400 {object} SwaggerError "ID inválido"
+// @Failure 401 {object} SwaggerError "Autenticação necessária"
+// @Failure 403 {object} SwaggerError "Apenas administradores"
+// @Failure 404 {object} SwaggerError "Destino não configurado"
+// @Failure 500 {object} SwaggerError "Erro interno"
+// @Security UserToken
+// @Router /companies/{id}/export-target [get]
+func (h *CompanyHandler) GetExportTarget(c *fiber.Ctx) error {
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid company ID",
+		})
+	}
+
+	target, err := services.GetExportTarget(c.Context(), id)
+	if err != nil {
+		if errors.Is(err, services.ErrExportTargetNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Export target not configured",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load export target",
+		})
+	}
+
+	return respondData(c, fiber.StatusOK, target)
+}
+
+// UpdateExportTarget configura o bucket de exportação de uma empresa (apenas admin)
+// @Summary Configurar destino de exportação
+// @Description Define o bucket S3 do cliente para onde os XMLs da empresa são copiados. O endpoint deve estar em STORAGE_EXPORT_ALLOWED_HOSTS, as credenciais são testadas antes de salvar e a chave secreta é armazenada criptografada.
+// @Tags companies
+// @Accept json
+// @Produce json
+// @Param id path int true "ID da empresa"
+// @Param target body ExportTargetRequest true "Bucket de destino"
+// @Success 200 {object} models.CompanyExportTarget
+// @Failure 400 {object} SwaggerValidationError "Erro de validação"
+// @Failure 401 {object} SwaggerError "Autenticação necessária"
+// @Failure 403 {object} SwaggerError "Apenas administradores"
+// @Failure 404 {object} SwaggerError "Empresa não encontrada"
+// @Failure 422 {object} SwaggerError "Bucket inacessível com as credenciais informadas"
+// @Failure 500 {object} SwaggerError "Erro interno"
+// @Security UserToken
+// @Router /companies/{id}/export-target [put]
+func (h *CompanyHandler) UpdateExportTarget(c *fiber.Ctx) error {
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid company ID",
+		})
+	}
+
+	user := middleware.GetUserFromContext(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Authentication required",
+		})
+	}
+
+	var req ExportTargetRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := validateStruct(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": err,
+		})
+	}
+
+	// Validar o endpoint contra a allowlist
+	if err := services.ValidateExportEndpoint(req.Endpoint); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	exists, err := database.DB.NewSelect().
+		Model((*models.Company)(nil)).
+		Where("id = ?", id).
+		Exists(c.Context())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load company",
+		})
+	}
+	if !exists {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Company not found",
+		})
+	}
+
+	target := &models.CompanyExportTarget{
+		CompanyID: id,
+		Endpoint:  req.Endpoint,
+		Region:    req.Region,
+		Bucket:    req.Bucket,
+		Prefix:    req.Prefix,
+		UseSSL:    req.UseSSL == nil || *req.UseSSL,
+		AccessKey: req.AccessKey,
+	}
+	if err := target.SetSecretKey(req.SecretKey); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to encrypt secret key",
+		})
+	}
+
+	if err := services.SaveExportTarget(c.Context(), target); err != nil {
+		if errors.Is(err, services.ErrExportTargetInvalid) {
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		logger.ErrorWithFields("Failed to save export target", err, map[string]any{
+			"operation":  "update_export_target",
+			"company_id": id,
+			"user_id":    user.ID,
+		})
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to save export target",
+		})
+	}
+
+	recordAudit(c, user, "UPDATE", "CompanyExportTarget", target.ID, map[string]any{
+		"company_id": id,
+		"endpoint":   target.Endpoint,
+		"bucket":     target.Bucket,
+	})
+
+	return respondData(c, fiber.StatusOK, target)
+}
+
+// StartExport inicia a cópia dos XMLs da empresa para o bucket de destino (apenas admin)
+// @Summary Exportar XMLs para o bucket do cliente
+// @Description Testa as credenciais do destino e copia em segundo plano, em lotes, os XMLs armazenados da empresa. Objetos já presentes no destino são pulados, então repetir a exportação só copia o que falta. Se já houver uma exportação em andamento, ela é retornada.
+// @Tags companies
+// @Produce json
+// @Param id path int true "ID da empresa"
+// @Success 202 {object} ExportStatusResponse "Exportação criada"
+// @Success 200 {object} ExportStatusResponse "Exportação já em andamento"
+// @Failure 400 {object} SwaggerError "ID inválido"
+// @Failure 401 {object} SwaggerError "Autenticação necessária"
+// @Failure 403 {object} SwaggerError "Apenas administradores"
+// @Failure 404 {object} SwaggerError "Destino não configurado"
+// @Failure 422 {object} SwaggerError "Bucket inacessível"
+// @Failure 500 {object} SwaggerError "Erro interno"
+// @Security UserToken
+// @Router /companies/{id}/exports [post]
+func (h *CompanyHandler) StartExport(c *fiber.Ctx) error {
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid company ID",
+		})
+	}
+
+	user := middleware.GetUserFromContext(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Authentication required",
+		})
+	}
+
+	job, created, err := services.StartExport(c.Context(), id, user.ID)
+	if err != nil {
+		if errors.Is(err, services.ErrExportTargetNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Export target not configured",
+			})
+		}
+		if errors.Is(err, services.ErrExportTargetInvalid) {
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		logger.ErrorWithFields("Failed to start company export", err, map[string]any{
+			"operation":  "export_company",
+			"company_id": id,
+			"user_id":    user.ID,
+		})
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to start export",
+		})
+	}
+
+	if !created {
+		return respondData(c, fiber.StatusOK, newExportStatusResponse(job))
+	}
+
+	recordAudit(c, user, "CREATE", "ExportJob", job.ID, map[string]any{
+		"company_id": id,
+		"total":      job.Total,
+	})
+
+	return respondData(c, fiber.StatusAccepted, newExportStatusResponse(job))
+}
+
+// GetExportJobStatus obtém o progresso de uma exportação (apenas admin)
+// @Summary Progresso da exportação
+// @Description Retorna os XMLs copiados, pulados (já no destino), com falha e restantes de uma exportação da empresa
+// @Tags companies
+// @Produce json
+// @Param id path int true "ID da empresa"
+// @Param job_id path int true "ID da exportação"
+// @Success 200 {object} ExportStatusResponse
+// @Failure 400 {object} SwaggerError "ID inválido"
+// @Failure 401 {object} SwaggerError "Autenticação necessária"
+// @Failure 403 {object} SwaggerError "Apenas administradores"
+// @Failure 404 {object} SwaggerError "Exportação não encontrada"
+// @Failure 500 {object} SwaggerError "Erro interno"
+// @Security UserToken
+// @Router /companies/{id}/exports/{job_id} [get]
+func (h *CompanyHandler) GetExportJobStatus(c *fiber.Ctx) error {
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid company ID",
+		})
+	}
+
+	jobID, err := strconv.ParseInt(c.Params("job_id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid job ID",
+		})
+	}
+
+	job, err := services.GetExportJob(c.Context(), id, jobID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Export job not found",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load export job",
+		})
+	}
+
+	return respondData(c, fiber.StatusOK, newExportStatusResponse(job))
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/zoomxml/internal/models"
+	"github.com/zoomxml/internal/services"
+)
+
+// GetIntegrityCheck obtém a última verificação de integridade do storage de uma empresa (apenas admin)
+// @Summary Última verificação de integridade
+// @Description Retorna a última comparação periódica entre os documentos da empresa no banco e os XMLs no storage: XMLs ausentes, XMLs órfãos e se a divergência passou do limite STORAGE_INTEGRITY_DRIFT_THRESHOLD
+// @Tags companies
+// @Produce json
+// @Param id path int true "ID da empresa"
+// @Success 200 {object} models.IntegrityCheck
+// @Failure 400 {object} SwaggerError "ID inválido"
+// @Failure 401 {object} SwaggerError "Autenticação necessária"
+// @Failure 403 {object} SwaggerError "Apenas administradores"
+// @Failure 404 {object} SwaggerError "Empresa ainda não verificada"
+// @Failure 500 {object} SwaggerError "Erro interno"
+// @Security UserToken
+// @Router /companies/{id}/integrity [get]
+func (h *CompanyHandler) GetIntegrityCheck(c *fiber.Ctx) error {
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid company ID",
+		})
+	}
+
+	var check *models.IntegrityCheck
+	check, err = services.LatestIntegrityCheck(c.Context(), id)
+	if err != nil {
+		if errors.Is(err, services.ErrNoIntegrityCheck) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Company has not been checked yet",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load integrity check",
+		})
+	}
+
+	return respondData(c, fiber.StatusOK, check)
+}
@@ -1,15 +1,23 @@
 package handlers
 
 import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/uptrace/bun"
 	"github.com/zoomxml/internal/api/middleware"
 	"github.com/zoomxml/internal/database"
 	"github.com/zoomxml/internal/logger"
 	"github.com/zoomxml/internal/models"
-	"github.com/zoomxml/internal/permissions"
 	"github.com/zoomxml/internal/services"
 )
 
@@ -20,18 +28,28 @@ type NFSeHandler struct {
 
 // NewNFSeHandler creates a new NFSe handler
 func NewNFSeHandler() *NFSeHandler {
+	return NewNFSeHandlerWithService(services.NewNFSeService(services.SharedTransport()))
+}
+
+// NewNFSeHandlerWithService creates an NFSe handler on top of the given service
+func NewNFSeHandlerWithService(nfseService *services.NFSeService) *NFSeHandler {
 	return &NFSeHandler{
-		nfseService: services.NewNFSeService(),
+		nfseService: nfseService,
 	}
 }
 
 // FetchNFSeRequest represents the request to fetch NFSe documents
 type FetchNFSeRequest struct {
-	StartDate string `json:"start_date" validate:"required"` // Format: 2006-01-02
-	EndDate   string `json:"end_date" validate:"required"`   // Format: 2006-01-02
-	Page      int    `json:"page,omitempty"`                 // Page number (default: 1)
+	Mode      string `json:"mode,omitempty" validate:"omitempty,oneof=range since_last"` // range (default) or since_last
+	StartDate string `json:"start_date" validate:"required_unless=Mode since_last"`      // Format: 2006-01-02
+	EndDate   string `json:"end_date" validate:"required_unless=Mode since_last"`        // Format: 2006-01-02
+	Page      int    `json:"page,omitempty"`                                             // Page number (default: 1)
 }
 
+// FetchModeSinceLast fetches from the last stored document up to today instead of
+// the given dates
+const FetchModeSinceLast = "since_last"
+
 // FetchNFSeResponse represents the response from fetching NFSe documents
 type FetchNFSeResponse struct {
 	Success        bool                    `json:"success"`
@@ -43,7 +61,9 @@ type FetchNFSeResponse struct {
 
 // FetchNFSeDocuments fetches NFSe documents for a company
 // @Summary Fetch NFSe documents
-// @Description Fetches NFSe documents from the municipal API for a specific company
+// @Description Fetches NFSe documents from the municipal API for a specific company. With mode "since_last"
+// @Description the dates are ignored and every page from the last stored document up to today is fetched and
+// @Description stored page by page; the response then carries the count but not the documents
 // @Tags nfse
 // @Accept json
 // @Produce json
@@ -54,17 +74,13 @@ type FetchNFSeResponse struct {
 // @Failure 401 {object} fiber.Map
 // @Failure 403 {object} fiber.Map
 // @Failure 404 {object} fiber.Map
+// @Failure 422 {object} fiber.Map
+// @Failure 429 {object} fiber.Map "Too many provider requests"
 // @Failure 500 {object} fiber.Map
 // @Router /api/companies/{company_id}/nfse/fetch [post]
 func (h *NFSeHandler) FetchNFSeDocuments(c *fiber.Ctx) error {
-	// Parse company ID
-	companyIDStr := c.Params("company_id")
-	companyID, err := strconv.ParseInt(companyIDStr, 10, 64)
-	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid company ID",
-		})
-	}
+	// Company resolved (and access checked) by CompanyMiddleware
+	companyID := middleware.GetCompanyFromContext(c).ID
 
 	// Get user from context
 	user := middleware.GetUserFromContext(c)
@@ -74,24 +90,6 @@ func (h *NFSeHandler) FetchNFSeDocuments(c *fiber.Ctx) error {
 		})
 	}
 
-	// Check permissions
-	err = permissions.CanAccessCompany(c.Context(), user, companyID)
-	if err != nil {
-		if err == permissions.ErrCompanyNotFound {
-			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-				"error": "Company not found",
-			})
-		}
-		if err == permissions.ErrAccessDenied {
-			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
-				"error": "Access denied to this company",
-			})
-		}
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to validate permissions",
-		})
-	}
-
 	// Parse request body
 	var req FetchNFSeRequest
 	if err := c.BodyParser(&req); err != nil {
@@ -113,67 +111,62 @@ func (h *NFSeHandler) FetchNFSeDocuments(c *fiber.Ctx) error {
 		})
 	}
 
+	sinceLast := req.Mode == FetchModeSinceLast
+
 	// Parse dates
-	startDate, err := time.Parse("2006-01-02", req.StartDate)
-	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid start_date format. Use YYYY-MM-DD",
-		})
-	}
+	var startDate, endDate time.Time
+	var err error
+	if !sinceLast {
+		startDate, err = time.Parse("2006-01-02", req.StartDate)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid start_date format. Use YYYY-MM-DD",
+			})
+		}
 
-	endDate, err := time.Parse("2006-01-02", req.EndDate)
-	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid end_date format. Use YYYY-MM-DD",
-		})
-	}
+		endDate, err = time.Parse("2006-01-02", req.EndDate)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid end_date format. Use YYYY-MM-DD",
+			})
+		}
 
-	// Validate date range
-	if endDate.Before(startDate) {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "End date must be after start date",
-		})
+		// Validate date range
+		if endDate.Before(startDate) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "End date must be after start date",
+			})
+		}
 	}
 
 	// Find company credentials for NFSe
-	credentials := []models.CompanyCredential{}
-	err = database.DB.NewSelect().
-		Model(&credentials).
-		Where("company_id = ? AND active = true", companyID).
-		Where("type IN ('prefeitura_token', 'prefeitura_mixed')").
-		Scan(c.Context())
-
+	credentials, err := services.GetFetchCredentials(c.Context(), companyID)
 	if err != nil {
-		logger.ErrorWithFields("Failed to fetch company credentials", err, map[string]any{
-			"operation":  "fetch_nfse",
-			"company_id": companyID,
-			"user_id":    user.ID,
-		})
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to fetch company credentials",
-		})
+		return respondCredentialsError(c, "fetch_nfse", companyID, err)
 	}
 
-	if len(credentials) == 0 {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"error": "No NFSe credentials found for this company",
-		})
-	}
-
-	// Use the first available credential
-	credential := &credentials[0]
-
 	logger.InfoWithFields("Starting NFSe fetch", map[string]any{
-		"operation":     "fetch_nfse",
-		"company_id":    companyID,
-		"user_id":       user.ID,
-		"credential_id": credential.ID,
-		"start_date":    req.StartDate,
-		"end_date":      req.EndDate,
+		"operation":         "fetch_nfse",
+		"company_id":        companyID,
+		"user_id":           user.ID,
+		"credentials_count": len(credentials),
+		"mode":              req.Mode,
+		"start_date":        req.StartDate,
+		"end_date":          req.EndDate,
 	})
 
-	// Fetch NFSe documents
-	nfseResponse, err := h.nfseService.FetchNFSeDocuments(c.Context(), credential, startDate, endDate, req.Page)
+	// Fetch NFSe documents, moving on to the next credential when the provider rejects one
+	var nfseResponse *services.NFSeProcessResult
+	var window services.FetchWindow
+	credential, err := services.WithCredentialFailover(c.Context(), credentials, func(credential *models.CompanyCredential) error {
+		var err error
+		if sinceLast {
+			nfseResponse, window, err = h.nfseService.FetchSinceLastDocument(c.Context(), credential)
+		} else {
+			nfseResponse, err = h.nfseService.FetchNFSeDocuments(c.Context(), credential, startDate, endDate, req.Page)
+		}
+		return err
+	})
 	if err != nil {
 		logger.ErrorWithFields("Failed to fetch NFSe documents", err, map[string]any{
 			"operation":     "fetch_nfse",
@@ -188,10 +181,13 @@ func (h *NFSeHandler) FetchNFSeDocuments(c *fiber.Ctx) error {
 		})
 	}
 
-	// Store documents if successful
-	if nfseResponse.Success && len(nfseResponse.Documents) > 0 {
+	// Documents of a "since last document" fetch are stored page by page as they arrive;
+	// those of a single page are stored here
+	stored := nfseResponse.Success && nfseResponse.FailedCount == 0
+	if !sinceLast && nfseResponse.Success && len(nfseResponse.Documents) > 0 {
 		err = h.nfseService.StoreNFSeDocuments(c.Context(), companyID, nfseResponse.Documents)
 		if err != nil {
+			stored = false
 			logger.ErrorWithFields("Failed to store NFSe documents", err, map[string]any{
 				"operation":  "fetch_nfse",
 				"company_id": companyID,
@@ -201,23 +197,62 @@ func (h *NFSeHandler) FetchNFSeDocuments(c *fiber.Ctx) error {
 		}
 	}
 
+	// The backfill is done only once its documents are stored
+	if sinceLast && stored {
+		if err := services.FinishBackfill(c.Context(), companyID, window); err != nil {
+			logger.ErrorWithFields("Failed to clear pending backfill", err, map[string]any{
+				"operation":  "fetch_nfse",
+				"company_id": companyID,
+				"user_id":    user.ID,
+			})
+		}
+	}
+
 	logger.InfoWithFields("NFSe fetch completed", map[string]any{
 		"operation":       "fetch_nfse",
 		"company_id":      companyID,
 		"user_id":         user.ID,
-		"documents_count": len(nfseResponse.Documents),
+		"documents_count": nfseResponse.DocumentsCount,
 		"success":         nfseResponse.Success,
 	})
 
-	return c.Status(fiber.StatusOK).JSON(FetchNFSeResponse{
+	return respondData(c, fiber.StatusOK, FetchNFSeResponse{
 		Success:        nfseResponse.Success,
 		Message:        nfseResponse.Message,
-		DocumentsCount: len(nfseResponse.Documents),
+		DocumentsCount: nfseResponse.DocumentsCount,
 		Documents:      nfseResponse.Documents,
 		Error:          nfseResponse.Error,
 	})
 }
 
+// respondCredentialsError responds to a failure loading the credentials the provider is
+// queried with: 422 when the company has none or no provider serves its municipality,
+// 500 otherwise
+func respondCredentialsError(c *fiber.Ctx, operation string, companyID int64, err error) error {
+	if errors.Is(err, services.ErrNoCredentials) {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+			"error":   "No NFSe credentials found for this company",
+			"code":    "NO_CREDENTIALS",
+			"message": services.NoCredentialsMessage,
+		})
+	}
+
+	if errors.Is(err, services.ErrUnsupportedMunicipality) {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+			"error": "No NFSe provider serves the municipality of this company",
+			"code":  "UNSUPPORTED_MUNICIPALITY",
+		})
+	}
+
+	logger.ErrorWithFields("Failed to fetch company credentials", err, map[string]any{
+		"operation":  operation,
+		"company_id": companyID,
+	})
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error": "Failed to fetch company credentials",
+	})
+}
+
 // GetNFSeDocuments lists stored NFSe documents for a company
 // @Summary List NFSe documents
 // @Description Lists stored NFSe documents for a specific company
@@ -226,7 +261,13 @@ func (h *NFSeHandler) FetchNFSeDocuments(c *fiber.Ctx) error {
 // @Produce json
 // @Param company_id path int true "Company ID"
 // @Param page query int false "Page number" default(1)
-// @Param limit query int false "Items per page" default(20)
+// @Param limit query int false "Items per page, at most 100 (larger values are reduced to 100)" default(20)
+// @Param service_code query string false "Service list item (ItemListaServico)"
+// @Param natureza_operacao query string false "Operation nature (NaturezaOperacao)"
+// @Param tag query string false "Document tag"
+// @Param rps_number query string false "Originating RPS number"
+// @Param rps_series query string false "Originating RPS series"
+// @Param late_arrival query bool false "Only notes that arrived after their competência was closed"
 // @Success 200 {object} fiber.Map
 // @Failure 400 {object} fiber.Map
 // @Failure 401 {object} fiber.Map
@@ -235,14 +276,8 @@ func (h *NFSeHandler) FetchNFSeDocuments(c *fiber.Ctx) error {
 // @Failure 500 {object} fiber.Map
 // @Router /api/companies/{company_id}/nfse [get]
 func (h *NFSeHandler) GetNFSeDocuments(c *fiber.Ctx) error {
-	// Parse company ID
-	companyIDStr := c.Params("company_id")
-	companyID, err := strconv.ParseInt(companyIDStr, 10, 64)
-	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid company ID",
-		})
-	}
+	// Company resolved (and access checked) by CompanyMiddleware
+	companyID := middleware.GetCompanyFromContext(c).ID
 
 	// Get user from context
 	user := middleware.GetUserFromContext(c)
@@ -252,34 +287,20 @@ func (h *NFSeHandler) GetNFSeDocuments(c *fiber.Ctx) error {
 		})
 	}
 
-	// Check permissions
-	err = permissions.CanAccessCompany(c.Context(), user, companyID)
+	// Parse pagination parameters
+	page, limit, err := parsePagination(c)
 	if err != nil {
-		if err == permissions.ErrCompanyNotFound {
-			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-				"error": "Company not found",
-			})
-		}
-		if err == permissions.ErrAccessDenied {
-			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
-				"error": "Access denied to this company",
-			})
-		}
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to validate permissions",
-		})
+		return paginationError(c, err)
 	}
-
-	// Parse pagination parameters
-	page := c.QueryInt("page", 1)
-	limit := c.QueryInt("limit", 20)
 	offset := (page - 1) * limit
+	filter := ParseDocumentFilter(c)
 
 	// Fetch documents
 	documents := []models.Document{}
 	err = database.DB.NewSelect().
 		Model(&documents).
 		Where("company_id = ? AND type = 'nfse'", companyID).
+		ApplyQueryBuilder(filter.Apply).
 		Order("created_at DESC").
 		Limit(limit).
 		Offset(offset).
@@ -300,6 +321,7 @@ func (h *NFSeHandler) GetNFSeDocuments(c *fiber.Ctx) error {
 	total, err := database.DB.NewSelect().
 		Model((*models.Document)(nil)).
 		Where("company_id = ? AND type = 'nfse'", companyID).
+		ApplyQueryBuilder(filter.Apply).
 		Count(c.Context())
 
 	if err != nil {
@@ -313,12 +335,1512 @@ func (h *NFSeHandler) GetNFSeDocuments(c *fiber.Ctx) error {
 		})
 	}
 
-	return c.Status(fiber.StatusOK).JSON(fiber.Map{
-		"documents": documents,
-		"pagination": fiber.Map{
-			"page":  page,
-			"limit": limit,
-			"total": total,
-		},
+	return respondList(c, "documents", documents, page, limit, total)
+}
+
+// MarkReviewedRequest represents the request to mark documents as reviewed.
+// Either DocumentIDs or a non-empty Filter must be provided.
+type MarkReviewedRequest struct {
+	DocumentIDs []int64         `json:"document_ids,omitempty" validate:"omitempty,max=1000"`
+	Filter      *DocumentFilter `json:"filter,omitempty"`
+}
+
+// MarkNFSeDocumentsReviewed marks a set of NFSe documents as reviewed
+// @Summary Mark NFSe documents as reviewed
+// @Description Sets the status of the given documents (by ID list or filter) to reviewed. Only processed documents can be reviewed.
+// @Tags nfse
+// @Accept json
+// @Produce json
+// @Param company_id path int true "Company ID"
+// @Param request body MarkReviewedRequest true "Documents to mark"
+// @Success 200 {object} fiber.Map
+// @Failure 400 {object} fiber.Map
+// @Failure 401 {object} fiber.Map
+// @Failure 403 {object} fiber.Map
+// @Failure 404 {object} fiber.Map
+// @Failure 500 {object} fiber.Map
+// @Router /api/companies/{company_id}/nfse/mark-reviewed [post]
+func (h *NFSeHandler) MarkNFSeDocumentsReviewed(c *fiber.Ctx) error {
+	// Company resolved (and access checked) by CompanyMiddleware
+	companyID := middleware.GetCompanyFromContext(c).ID
+
+	// Get user from context
+	user := middleware.GetUserFromContext(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Authentication required",
+		})
+	}
+
+	// Parse request body
+	var req MarkReviewedRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if errs := validateStruct(req); errs != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": errs,
+		})
+	}
+
+	if len(req.DocumentIDs) == 0 && (req.Filter == nil || req.Filter.IsEmpty()) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Either document_ids or a non-empty filter is required",
+		})
+	}
+
+	// Update all matching documents in one statement; only valid transitions are applied
+	query := database.DB.NewUpdate().
+		Model((*models.Document)(nil)).
+		Set("status = ?", models.DocumentStatusReviewed).
+		Set("updated_at = ?", time.Now()).
+		Where("company_id = ? AND type = 'nfse'", companyID).
+		Where("status IN (?)", bun.In(models.ReviewableStatuses))
+
+	if len(req.DocumentIDs) > 0 {
+		query = query.Where("id IN (?)", bun.In(req.DocumentIDs))
+	}
+	if req.Filter != nil {
+		query = query.ApplyQueryBuilder(req.Filter.Apply)
+	}
+
+	res, err := query.Exec(c.Context())
+	if err != nil {
+		logger.ErrorWithFields("Failed to mark NFSe documents as reviewed", err, map[string]any{
+			"operation":  "mark_nfse_reviewed",
+			"company_id": companyID,
+			"user_id":    user.ID,
+		})
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to mark documents as reviewed",
+		})
+	}
+
+	updated, _ := res.RowsAffected()
+
+	recordAudit(c, user, "UPDATE", "Document", 0, map[string]any{
+		"action":       "mark_reviewed",
+		"company_id":   companyID,
+		"document_ids": req.DocumentIDs,
+		"filter":       req.Filter,
+		"updated":      updated,
+	})
+
+	logger.InfoWithFields("Marked NFSe documents as reviewed", map[string]any{
+		"operation":  "mark_nfse_reviewed",
+		"company_id": companyID,
+		"user_id":    user.ID,
+		"updated":    updated,
+	})
+
+	return respondData(c, fiber.StatusOK, fiber.Map{
+		"updated": updated,
+	})
+}
+
+// UploadNFSeResult represents the outcome of one uploaded file
+type UploadNFSeResult struct {
+	FileName        string `json:"file_name"`
+	Success         bool   `json:"success"`
+	DocumentID      int64  `json:"document_id,omitempty"`
+	Duplicate       bool   `json:"duplicate"`
+	DuplicateReason string `json:"duplicate_reason,omitempty"`
+	Overwritten     bool   `json:"overwritten"`
+	LimitReached    bool   `json:"limit_reached,omitempty"` // rejected by the company document limit
+	Error           string `json:"error,omitempty"`
+}
+
+// UploadNFSeDocuments registers NFSe XML files uploaded manually
+// @Summary Upload NFSe XML files
+// @Description Registers NFSe XML files sent by the user. Duplicates are skipped unless overwrite=true, which replaces the stored XML and parsed fields of the existing document.
+// @Tags nfse
+// @Accept multipart/form-data
+// @Produce json
+// @Param company_id path int true "Company ID"
+// @Param files formData file true "NFSe XML files"
+// @Param overwrite query bool false "Replace existing documents" default(false)
+// @Success 200 {object} fiber.Map
+// @Failure 400 {object} fiber.Map
+// @Failure 401 {object} fiber.Map
+// @Failure 403 {object} fiber.Map
+// @Failure 404 {object} fiber.Map
+// @Failure 500 {object} fiber.Map
+// @Router /api/companies/{company_id}/nfse/upload [post]
+func (h *NFSeHandler) UploadNFSeDocuments(c *fiber.Ctx) error {
+	// Company resolved (and access checked) by CompanyMiddleware
+	companyID := middleware.GetCompanyFromContext(c).ID
+
+	// Get user from context
+	user := middleware.GetUserFromContext(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Authentication required",
+		})
+	}
+
+	form, err := c.MultipartForm()
+	if err != nil || len(form.File["files"]) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "At least one XML file is required in the 'files' field",
+		})
+	}
+
+	// Read uploaded files
+	documents := make([]services.NFSeDocument, 0, len(form.File["files"]))
+	for _, fileHeader := range form.File["files"] {
+		file, err := fileHeader.Open()
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Failed to read file " + fileHeader.Filename,
+			})
+		}
+		content, err := io.ReadAll(file)
+		file.Close()
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Failed to read file " + fileHeader.Filename,
+			})
+		}
+
+		documents = append(documents, services.NFSeDocument{
+			FileName:    filepath.Base(fileHeader.Filename),
+			XMLContent:  string(content),
+			ProcessedAt: time.Now(),
+		})
+	}
+
+	return h.importUploads(c, user, companyID, documents, nil)
+}
+
+// UploadNFSeZip registers the NFSe XML files of an uploaded ZIP archive
+// @Summary Upload a ZIP of NFSe XML files
+// @Description Extracts the .xml files of a ZIP archive and registers them like /upload. Results are listed per entry, named by its path inside the archive. The archive is rejected when it has too many entries or expands past the configured sizes (NFSE_ZIP_MAX_*). Duplicates are skipped unless overwrite=true.
+// @Tags nfse
+// @Accept multipart/form-data
+// @Produce json
+// @Param company_id path int true "Company ID"
+// @Param file formData file true "ZIP archive of NFSe XML files"
+// @Param overwrite query bool false "Replace existing documents" default(false)
+// @Success 200 {object} fiber.Map
+// @Failure 400 {object} fiber.Map
+// @Failure 401 {object} fiber.Map
+// @Failure 403 {object} fiber.Map
+// @Failure 404 {object} fiber.Map
+// @Failure 413 {object} fiber.Map
+// @Failure 500 {object} fiber.Map
+// @Router /api/companies/{company_id}/nfse/upload-zip [post]
+func (h *NFSeHandler) UploadNFSeZip(c *fiber.Ctx) error {
+	// Company resolved (and access checked) by CompanyMiddleware
+	companyID := middleware.GetCompanyFromContext(c).ID
+
+	// Get user from context
+	user := middleware.GetUserFromContext(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Authentication required",
+		})
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "A ZIP file is required in the 'file' field",
+		})
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Failed to read file " + fileHeader.Filename,
+		})
+	}
+	data, err := io.ReadAll(file)
+	file.Close()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Failed to read file " + fileHeader.Filename,
+		})
+	}
+
+	documents, err := services.ExtractZipDocuments(data, services.DefaultZipLimits(), true)
+	if errors.Is(err, services.ErrZipLimitExceeded) {
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "The uploaded file is not a valid ZIP archive",
+		})
+	}
+	if len(documents) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "The ZIP archive has no XML files",
+		})
+	}
+
+	return h.importUploads(c, user, companyID, documents, nil)
+}
+
+// importUploads stores uploaded documents (?overwrite=true replaces existing ones) and
+// responds with the batch result, reporting each document under names[i] when names is set
+// and under its file name otherwise
+func (h *NFSeHandler) importUploads(c *fiber.Ctx, user *models.User, companyID int64, documents []services.NFSeDocument, names []string) error {
+	overwrite := c.QueryBool("overwrite", false)
+
+	result, err := h.nfseService.ImportUploadedDocuments(c.Context(), companyID, documents, overwrite)
+	if err != nil {
+		logger.ErrorWithFields("Failed to import uploaded NFSe documents", err, map[string]any{
+			"operation":  "upload_nfse",
+			"company_id": companyID,
+			"user_id":    user.ID,
+		})
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to import documents",
+		})
+	}
+
+	results := make([]UploadNFSeResult, len(result.Results))
+	for i, docResult := range result.Results {
+		name := documents[i].FileName
+		if names != nil {
+			name = names[i]
+		}
+		results[i] = UploadNFSeResult{
+			FileName:        name,
+			Success:         docResult.Success,
+			DocumentID:      docResult.DocumentID,
+			Duplicate:       docResult.IsDuplicate,
+			DuplicateReason: docResult.DuplicateReason,
+			Overwritten:     docResult.Overwritten,
+			LimitReached:    docResult.LimitReached,
+		}
+		if docResult.Error != nil {
+			results[i].Error = docResult.Error.Error()
+		}
+	}
+
+	if result.OverwrittenDocuments > 0 {
+		recordAudit(c, user, "UPDATE", "Document", 0, map[string]any{
+			"action":      "overwrite_upload",
+			"company_id":  companyID,
+			"batch_id":    result.BatchID,
+			"overwritten": result.OverwrittenDocuments,
+		})
+	}
+
+	return respondData(c, fiber.StatusOK, fiber.Map{
+		"batch_id":          result.BatchID,
+		"total":             result.TotalDocuments,
+		"processed":         result.ProcessedDocuments,
+		"duplicates":        result.DuplicateDocuments,
+		"overwritten":       result.OverwrittenDocuments,
+		"errors":            result.ErrorDocuments,
+		"rejected_by_limit": result.LimitRejected,
+		"evicted":           result.EvictedDocuments,
+		"results":           results,
 	})
 }
+
+// GetNFSeNumberingGaps lists missing NFSe numbers per provider in a competência
+// @Summary List NFSe numbering gaps
+// @Description For each provider CNPJ, lists the NFSe numbers missing from the observed min–max range of the competência
+// @Tags nfse
+// @Produce json
+// @Param company_id path int true "Company ID"
+// @Param competencia query string true "Competência (YYYY-MM)"
+// @Success 200 {object} fiber.Map
+// @Failure 400 {object} fiber.Map
+// @Failure 401 {object} fiber.Map
+// @Failure 403 {object} fiber.Map
+// @Failure 404 {object} fiber.Map
+// @Failure 500 {object} fiber.Map
+// @Router /api/companies/{company_id}/nfse/gaps [get]
+func (h *NFSeHandler) GetNFSeNumberingGaps(c *fiber.Ctx) error {
+	// Company resolved (and access checked) by CompanyMiddleware
+	companyID := middleware.GetCompanyFromContext(c).ID
+
+	// Get user from context
+	user := middleware.GetUserFromContext(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Authentication required",
+		})
+	}
+
+	competence, ok := services.NormalizeCompetence(c.Query("competencia"))
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Query parameter 'competencia' must be in YYYY-MM format",
+		})
+	}
+
+	providers, err := services.FindNumberingGaps(c.Context(), companyID, competence)
+	if err != nil {
+		logger.ErrorWithFields("Failed to find NFSe numbering gaps", err, map[string]any{
+			"operation":   "get_nfse_gaps",
+			"company_id":  companyID,
+			"competencia": competence,
+		})
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to find numbering gaps",
+		})
+	}
+
+	return respondData(c, fiber.StatusOK, fiber.Map{
+		"competencia": competence,
+		"providers":   providers,
+	})
+}
+
+// MergeDuplicateNFSeDocuments merges pre-existing duplicate documents of a company (admin only)
+// @Summary Merge duplicate NFSe documents
+// @Description Keeps the oldest document of each duplicate group (by verification code or provider CNPJ + number) and soft-deletes the rest. Use dry_run to only report.
+// @Tags nfse
+// @Produce json
+// @Param company_id path int true "Company ID"
+// @Param dry_run query bool false "Only report what would be merged" default(true)
+// @Param remove_objects query bool false "Delete stored XML objects left orphaned" default(false)
+// @Success 200 {object} services.DuplicateMergeReport
+// @Failure 400 {object} fiber.Map
+// @Failure 401 {object} fiber.Map
+// @Failure 403 {object} fiber.Map
+// @Failure 404 {object} fiber.Map
+// @Failure 500 {object} fiber.Map
+// @Router /api/companies/{company_id}/nfse/merge-duplicates [post]
+func (h *NFSeHandler) MergeDuplicateNFSeDocuments(c *fiber.Ctx) error {
+	// Company resolved (and access checked) by CompanyMiddleware
+	companyID := middleware.GetCompanyFromContext(c).ID
+
+	// Get user from context
+	user := middleware.GetUserFromContext(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Authentication required",
+		})
+	}
+
+	dryRun := c.QueryBool("dry_run", true)
+	removeObjects := c.QueryBool("remove_objects", false)
+
+	report, err := services.MergeDuplicateDocuments(c.Context(), companyID, dryRun, removeObjects)
+	if err != nil {
+		logger.ErrorWithFields("Failed to merge duplicate NFSe documents", err, map[string]any{
+			"operation":  "merge_duplicates",
+			"company_id": companyID,
+			"user_id":    user.ID,
+		})
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to merge duplicate documents",
+		})
+	}
+
+	if !dryRun && report.RemovedDocuments > 0 {
+		recordAudit(c, user, "DELETE", "Document", 0, map[string]any{
+			"action":            "merge_duplicates",
+			"company_id":        companyID,
+			"groups":            report.Groups,
+			"removed_documents": report.RemovedDocuments,
+			"removed_objects":   report.RemovedObjects,
+		})
+	}
+
+	return respondData(c, fiber.StatusOK, report)
+}
+
+// VerifyNFSeDocument re-queries the provider for a stored NFSe and compares key fields
+// @Summary Verify NFSe against the provider
+// @Description Queries the provider on the note's issue date and compares number, verification code, CNPJs, service code, value and status flags with the stored document
+// @Tags nfse
+// @Produce json
+// @Param company_id path int true "Company ID"
+// @Param number path string true "NFSe number"
+// @Param verification_code query string false "Verification code, to disambiguate notes of different providers"
+// @Success 200 {object} services.VerificationReport
+// @Failure 400 {object} fiber.Map
+// @Failure 401 {object} fiber.Map
+// @Failure 403 {object} fiber.Map
+// @Failure 404 {object} fiber.Map
+// @Failure 422 {object} fiber.Map
+// @Failure 502 {object} fiber.Map
+// @Failure 500 {object} fiber.Map
+// @Router /api/companies/{company_id}/nfse/{number}/verify [post]
+func (h *NFSeHandler) VerifyNFSeDocument(c *fiber.Ctx) error {
+	// Company resolved (and access checked) by CompanyMiddleware
+	companyID := middleware.GetCompanyFromContext(c).ID
+
+	// Get user from context
+	user := middleware.GetUserFromContext(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Authentication required",
+		})
+	}
+
+	// Find the stored document
+	document := &models.Document{}
+	query := database.DB.NewSelect().
+		Model(document).
+		Where("company_id = ? AND type = 'nfse'", companyID).
+		Where("number = ?", c.Params("number")).
+		Order("id ASC").
+		Limit(1)
+
+	if code := c.Query("verification_code"); code != "" {
+		query = query.Where("verification_code = ?", code)
+	}
+
+	if err := query.Scan(c.Context()); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Document not found",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load document",
+		})
+	}
+
+	credentials, err := services.GetFetchCredentials(c.Context(), companyID)
+	if err != nil {
+		return respondCredentialsError(c, "verify_nfse", companyID, err)
+	}
+
+	// Verify against the provider, moving on to the next credential when it rejects one
+	var report *services.VerificationReport
+	_, err = services.WithCredentialFailover(c.Context(), credentials, func(credential *models.CompanyCredential) error {
+		var err error
+		report, err = h.nfseService.VerifyDocument(c.Context(), credential, document)
+		return err
+	})
+	if err != nil {
+		logger.ErrorWithFields("Failed to verify NFSe document", err, map[string]any{
+			"operation":   "verify_nfse",
+			"company_id":  companyID,
+			"document_id": document.ID,
+			"user_id":     user.ID,
+		})
+		if errors.Is(err, services.ErrProviderUnavailable) {
+			return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+				"error": "NFSe provider unavailable, try again later",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to verify document",
+		})
+	}
+
+	return respondData(c, fiber.StatusOK, report)
+}
+
+// ConsultNFSeCompetence consults the provider for one competência synchronously
+// @Summary Consult a competência synchronously
+// @Description Fetches every provider page for the days of the competência, stores the documents and returns the outcome of each one (details capped at 200)
+// @Tags nfse
+// @Produce json
+// @Param company_id path int true "Company ID"
+// @Param competencia query string true "Competência (YYYY-MM)"
+// @Success 200 {object} services.ConsultationResult
+// @Failure 400 {object} fiber.Map
+// @Failure 401 {object} fiber.Map
+// @Failure 403 {object} fiber.Map
+// @Failure 404 {object} fiber.Map
+// @Failure 422 {object} fiber.Map
+// @Failure 429 {object} fiber.Map "Too many provider requests"
+// @Failure 502 {object} fiber.Map
+// @Failure 504 {object} fiber.Map
+// @Router /api/companies/{company_id}/nfse/consult [post]
+func (h *NFSeHandler) ConsultNFSeCompetence(c *fiber.Ctx) error {
+	// Company resolved (and access checked) by CompanyMiddleware
+	companyID := middleware.GetCompanyFromContext(c).ID
+
+	// Get user from context
+	user := middleware.GetUserFromContext(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Authentication required",
+		})
+	}
+
+	competence, ok := services.NormalizeCompetence(c.Query("competencia"))
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid competencia, expected YYYY-MM",
+		})
+	}
+
+	credentials, err := services.GetFetchCredentials(c.Context(), companyID)
+	if err != nil {
+		return respondCredentialsError(c, "consult_competence", companyID, err)
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), services.ConsultationTimeout)
+	defer cancel()
+
+	var result *services.ConsultationResult
+	_, err = services.WithCredentialFailover(ctx, credentials, func(credential *models.CompanyCredential) error {
+		var err error
+		result, err = h.nfseService.ConsultCompetence(ctx, credential, competence)
+		return err
+	})
+	if err != nil {
+		logger.ErrorWithFields("Failed to consult competência", err, map[string]any{
+			"operation":  "consult_competence",
+			"company_id": companyID,
+			"competence": competence,
+			"user_id":    user.ID,
+		})
+		if errors.Is(err, context.DeadlineExceeded) {
+			return c.Status(fiber.StatusGatewayTimeout).JSON(fiber.Map{
+				"error": "Consultation timed out",
+			})
+		}
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+			"error": "Failed to consult NFSe provider",
+		})
+	}
+
+	return respondData(c, fiber.StatusOK, result)
+}
+
+// RestoreMissingNFSeObjects re-stores XML objects missing from storage (admin only)
+// @Summary Restore missing NFSe XML objects
+// @Description Re-uploads the XML of documents whose row exists but whose storage object is missing, using the XML kept in the document metadata. A dry run reports the missing objects; otherwise the restore runs in the background and its outcome is logged.
+// @Tags nfse
+// @Produce json
+// @Param company_id path int true "Company ID"
+// @Param dry_run query bool false "Only report missing objects" default(true)
+// @Success 200 {object} services.ObjectRestoreReport "Dry run report"
+// @Success 202 {object} fiber.Map "Restore started"
+// @Failure 400 {object} fiber.Map
+// @Failure 401 {object} fiber.Map
+// @Failure 403 {object} fiber.Map
+// @Failure 404 {object} fiber.Map
+// @Failure 409 {object} fiber.Map "Restore already running"
+// @Failure 500 {object} fiber.Map
+// @Router /api/companies/{company_id}/nfse/restore-objects [post]
+func (h *NFSeHandler) RestoreMissingNFSeObjects(c *fiber.Ctx) error {
+	// Company resolved (and access checked) by CompanyMiddleware
+	companyID := middleware.GetCompanyFromContext(c).ID
+
+	// Get user from context
+	user := middleware.GetUserFromContext(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Authentication required",
+		})
+	}
+
+	if !c.QueryBool("dry_run", true) {
+		if !services.StartObjectRestore(companyID) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "A restore is already running for this company",
+			})
+		}
+
+		recordAudit(c, user, "UPDATE", "Document", 0, map[string]any{
+			"action":     "restore_missing_objects",
+			"company_id": companyID,
+		})
+
+		return respondData(c, fiber.StatusAccepted, fiber.Map{
+			"company_id": companyID,
+			"message":    "Restore started",
+		})
+	}
+
+	report, err := services.RestoreMissingObjects(c.Context(), companyID, true)
+	if err != nil {
+		logger.ErrorWithFields("Failed to check NFSe storage objects", err, map[string]any{
+			"operation":  "restore_missing_objects",
+			"company_id": companyID,
+			"user_id":    user.ID,
+		})
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to check storage objects",
+		})
+	}
+
+	return respondData(c, fiber.StatusOK, report)
+}
+
+// ConsolidateNFSeFolders moves stored XMLs to the folder of their competência year (admin only)
+// @Summary Consolidate split NFSe competências in storage
+// @Description Finds stored XMLs filed under a year folder other than their competência year, moves them to the
+// @Description competência year folder and updates storage_key in the same step. Use dry_run to only report.
+// @Tags nfse
+// @Produce json
+// @Param company_id path int true "Company ID"
+// @Param dry_run query bool false "Only report misplaced objects" default(true)
+// @Success 200 {object} services.CompetenceConsolidationReport
+// @Failure 401 {object} fiber.Map
+// @Failure 403 {object} fiber.Map
+// @Failure 404 {object} fiber.Map
+// @Failure 500 {object} fiber.Map
+// @Router /api/companies/{company_id}/nfse/consolidate-competences [post]
+func (h *NFSeHandler) ConsolidateNFSeFolders(c *fiber.Ctx) error {
+	// Company resolved (and access checked) by CompanyMiddleware
+	companyID := middleware.GetCompanyFromContext(c).ID
+
+	user := middleware.GetUserFromContext(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Authentication required",
+		})
+	}
+
+	dryRun := c.QueryBool("dry_run", true)
+
+	report, err := services.ConsolidateCompetences(c.Context(), companyID, dryRun)
+	if err != nil {
+		logger.ErrorWithFields("Failed to consolidate NFSe competências", err, map[string]any{
+			"operation":  "consolidate_competences",
+			"company_id": companyID,
+			"user_id":    user.ID,
+		})
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to consolidate competences",
+		})
+	}
+
+	if !dryRun && report.Moved > 0 {
+		recordAudit(c, user, "UPDATE", "Document", 0, map[string]any{
+			"action":     "consolidate_competences",
+			"company_id": companyID,
+			"moved":      report.Moved,
+			"failed":     report.Failed,
+		})
+	}
+
+	return respondData(c, fiber.StatusOK, report)
+}
+
+// DedupCheckRequest represents an XML to check against the stored documents
+type DedupCheckRequest struct {
+	XMLContent string `json:"xml_content" validate:"required"`
+}
+
+// PreviewNFSeDedup reports whether an XML would be a duplicate, without storing it
+// @Summary Preview NFSe deduplication
+// @Description Parses the XML and runs the same duplicate check as the ingest, returning the decision, the check method and the existing document. Nothing is stored. Accepts JSON {"xml_content"} or a raw XML body.
+// @Tags nfse
+// @Accept json
+// @Accept xml
+// @Produce json
+// @Param company_id path int true "Company ID"
+// @Param request body DedupCheckRequest true "XML to check"
+// @Success 200 {object} services.DuplicatePreview
+// @Failure 400 {object} fiber.Map
+// @Failure 401 {object} fiber.Map
+// @Failure 403 {object} fiber.Map
+// @Failure 404 {object} fiber.Map
+// @Failure 422 {object} fiber.Map
+// @Failure 500 {object} fiber.Map
+// @Router /api/companies/{company_id}/nfse/dedup-check [post]
+func (h *NFSeHandler) PreviewNFSeDedup(c *fiber.Ctx) error {
+	companyID := middleware.GetCompanyFromContext(c).ID
+
+	var req DedupCheckRequest
+	if strings.Contains(c.Get(fiber.HeaderContentType), "xml") {
+		req.XMLContent = string(c.Body())
+	} else if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := validateStruct(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": err,
+		})
+	}
+
+	preview, err := h.nfseService.PreviewDuplicateCheck(c.Context(), companyID, req.XMLContent)
+	if err != nil {
+		if errors.Is(err, services.ErrDuplicateCheck) {
+			logger.ErrorWithFields("Failed to preview NFSe dedup", err, map[string]any{
+				"operation":  "dedup_check",
+				"company_id": companyID,
+			})
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to check duplicates",
+			})
+		}
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+			"error": "Invalid NFSe XML: " + err.Error(),
+		})
+	}
+
+	return respondData(c, fiber.StatusOK, preview)
+}
+
+// GetNFSeDuplicateStatistics returns duplicate detection statistics over a window
+// @Summary Get NFSe duplicate statistics
+// @Description Counts documents, distinct verification codes, cancelled and substituted notes created in a window: start_date and end_date (inclusive), or the last days (default 30). Results are cached for NFSE_DUPLICATE_STATS_CACHE_TTL and refreshed when new documents are ingested.
+// @Tags nfse
+// @Produce json
+// @Param company_id path int true "Company ID"
+// @Param days query int false "Last days, ignored with start_date/end_date" default(30)
+// @Param start_date query string false "Start date (YYYY-MM-DD)"
+// @Param end_date query string false "End date (YYYY-MM-DD)"
+// @Success 200 {object} fiber.Map
+// @Failure 400 {object} fiber.Map
+// @Failure 401 {object} fiber.Map
+// @Failure 403 {object} fiber.Map
+// @Failure 404 {object} fiber.Map
+// @Failure 500 {object} fiber.Map
+// @Router /api/companies/{company_id}/nfse/duplicate-stats [get]
+func (h *NFSeHandler) GetNFSeDuplicateStatistics(c *fiber.Ctx) error {
+	// Company resolved (and access checked) by CompanyMiddleware
+	companyID := middleware.GetCompanyFromContext(c).ID
+
+	var window services.DuplicateStatsWindow
+	if c.Query("start_date") != "" || c.Query("end_date") != "" {
+		startDate, err := time.Parse("2006-01-02", c.Query("start_date"))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid start_date format. Use YYYY-MM-DD",
+			})
+		}
+
+		endDate, err := time.Parse("2006-01-02", c.Query("end_date"))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid end_date format. Use YYYY-MM-DD",
+			})
+		}
+
+		if endDate.Before(startDate) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "End date must be after start date",
+			})
+		}
+
+		window = services.DuplicateStatsWindow{Start: startDate, End: endDate}
+	} else {
+		days := c.QueryInt("days", 30)
+		if days <= 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "days must be positive",
+			})
+		}
+		window = services.DuplicateStatsWindowDays(days)
+	}
+
+	stats, err := h.nfseService.GetDuplicateStatistics(c.Context(), companyID, window)
+	if err != nil {
+		logger.ErrorWithFields("Failed to get NFSe duplicate statistics", err, map[string]any{
+			"operation":  "duplicate_stats",
+			"company_id": companyID,
+		})
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get duplicate statistics",
+		})
+	}
+
+	return respondData(c, fiber.StatusOK, stats)
+}
+
+// DownloadNFSeRequest represents the request to download specific documents as a ZIP
+type DownloadNFSeRequest struct {
+	DocumentIDs []int64 `json:"document_ids" validate:"required,min=1,max=500"` // services.MaxArchiveDocuments
+}
+
+// DownloadNFSeDocuments streams the XML of the selected documents as a ZIP
+// @Summary Download selected NFSe documents as a ZIP
+// @Description Streams the stored XML of the given documents into a ZIP archive, up to 500 documents and 100 MB of XML.
+// @Description Documents of other companies, documents whose stored object is missing, documents without stored XML
+// @Description (metadata-only companies) and documents past the size limit are skipped and listed in a skipped.json entry
+// @Description at the end of the archive.
+// @Tags nfse
+// @Accept json
+// @Produce application/zip
+// @Param company_id path int true "Company ID"
+// @Param request body DownloadNFSeRequest true "Documents to download"
+// @Success 200 {file} file
+// @Failure 400 {object} fiber.Map
+// @Failure 401 {object} fiber.Map
+// @Failure 403 {object} fiber.Map
+// @Failure 404 {object} fiber.Map
+// @Router /api/companies/{company_id}/nfse/download [post]
+func (h *NFSeHandler) DownloadNFSeDocuments(c *fiber.Ctx) error {
+	// Company resolved (and access checked) by CompanyMiddleware
+	companyID := middleware.GetCompanyFromContext(c).ID
+
+	user := middleware.GetUserFromContext(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Authentication required",
+		})
+	}
+
+	var req DownloadNFSeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if errs := validateStruct(req); errs != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": errs,
+		})
+	}
+
+	recordAudit(c, user, "EXPORT", "Document", 0, map[string]any{
+		"action":       "download_zip",
+		"company_id":   companyID,
+		"document_ids": req.DocumentIDs,
+	})
+
+	c.Set(fiber.HeaderContentType, "application/zip")
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="nfse_`+time.Now().Format("20060102150405")+`.zip"`)
+
+	// The body is produced after the handler returns, so it cannot use the request context
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		report, err := services.WriteDocumentArchive(context.Background(), w, companyID, req.DocumentIDs, services.MaxArchiveBytes, false)
+		if err != nil {
+			logger.ErrorWithFields("Failed to write NFSe document archive", err, map[string]any{
+				"operation":  "download_nfse_zip",
+				"company_id": companyID,
+				"user_id":    user.ID,
+			})
+			return
+		}
+
+		logger.InfoWithFields("NFSe document archive written", map[string]any{
+			"operation":  "download_nfse_zip",
+			"company_id": companyID,
+			"user_id":    user.ID,
+			"requested":  report.Requested,
+			"written":    report.Written,
+			"skipped":    len(report.Skipped),
+			"bytes":      report.Bytes,
+		})
+	})
+
+	return nil
+}
+
+// DownloadNFSeRange streams the XML of the documents issued in a date range as a ZIP with a manifest
+// @Summary Download NFSe documents of a date range as a ZIP with a manifest
+// @Description Streams the stored XML of the documents issued between start_date and end_date (inclusive, up to 366 days
+// @Description and 500 documents) into a ZIP archive with a manifest.csv listing each note's number, value and status.
+// @Description Documents whose stored object is missing, without stored XML or past the 100 MB limit are left out of the manifest and listed in skipped.json.
+// @Tags nfse
+// @Produce application/zip
+// @Param company_id path int true "Company ID"
+// @Param start_date query string true "Start date (YYYY-MM-DD)"
+// @Param end_date query string true "End date (YYYY-MM-DD)"
+// @Success 200 {file} file
+// @Failure 400 {object} fiber.Map
+// @Failure 401 {object} fiber.Map
+// @Failure 403 {object} fiber.Map
+// @Failure 404 {object} fiber.Map
+// @Failure 413 {object} fiber.Map
+// @Failure 500 {object} fiber.Map
+// @Router /api/companies/{company_id}/nfse/download [get]
+func (h *NFSeHandler) DownloadNFSeRange(c *fiber.Ctx) error {
+	// Company resolved (and access checked) by CompanyMiddleware
+	companyID := middleware.GetCompanyFromContext(c).ID
+
+	user := middleware.GetUserFromContext(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Authentication required",
+		})
+	}
+
+	startDate, err := time.Parse("2006-01-02", c.Query("start_date"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid start_date format. Use YYYY-MM-DD",
+		})
+	}
+
+	endDate, err := time.Parse("2006-01-02", c.Query("end_date"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid end_date format. Use YYYY-MM-DD",
+		})
+	}
+
+	if endDate.Before(startDate) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "End date must be after start date",
+		})
+	}
+
+	if endDate.Sub(startDate) >= services.MaxArchiveRangeDays*24*time.Hour {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Date range cannot exceed %d days", services.MaxArchiveRangeDays),
+		})
+	}
+
+	documentIDs, err := services.ArchiveRangeDocumentIDs(c.Context(), companyID, startDate, endDate)
+	if errors.Is(err, services.ErrArchiveTooManyDocuments) {
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+			"error": fmt.Sprintf("Date range has more than %d documents, narrow it down", services.MaxArchiveDocuments),
+		})
+	}
+	if err != nil {
+		logger.ErrorWithFields("Failed to load NFSe documents for archive", err, map[string]any{
+			"operation":  "download_nfse_range",
+			"company_id": companyID,
+		})
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load documents",
+		})
+	}
+
+	recordAudit(c, user, "EXPORT", "Document", 0, map[string]any{
+		"action":     "download_range_zip",
+		"company_id": companyID,
+		"start_date": c.Query("start_date"),
+		"end_date":   c.Query("end_date"),
+		"documents":  len(documentIDs),
+	})
+
+	c.Set(fiber.HeaderContentType, "application/zip")
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="nfse_`+startDate.Format("20060102")+`_`+endDate.Format("20060102")+`.zip"`)
+
+	// The body is produced after the handler returns, so it cannot use the request context
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		report, err := services.WriteDocumentArchive(context.Background(), w, companyID, documentIDs, services.MaxArchiveBytes, true)
+		if err != nil {
+			logger.ErrorWithFields("Failed to write NFSe document archive", err, map[string]any{
+				"operation":  "download_nfse_range",
+				"company_id": companyID,
+				"user_id":    user.ID,
+			})
+			return
+		}
+
+		logger.InfoWithFields("NFSe document archive written", map[string]any{
+			"operation":  "download_nfse_range",
+			"company_id": companyID,
+			"user_id":    user.ID,
+			"requested":  report.Requested,
+			"written":    report.Written,
+			"skipped":    len(report.Skipped),
+			"bytes":      report.Bytes,
+		})
+	})
+
+	return nil
+}
+
+// DownloadNFSeCompetenceZip streams the XML of every document of a competência as a ZIP
+// @Summary Download all NFSe XML of a competência as a ZIP
+// @Description Streams the stored XML of every NFSe document of the competência into a ZIP archive, one object at a time,
+// @Description so competências with thousands of notes are never held in memory. Documents without stored XML or whose object is missing are listed in skipped.json.
+// @Tags nfse
+// @Produce application/zip
+// @Param company_id path int true "Company ID"
+// @Param competencia path string true "Competência (YYYY-MM)"
+// @Success 200 {file} file
+// @Failure 400 {object} fiber.Map
+// @Failure 401 {object} fiber.Map
+// @Failure 403 {object} fiber.Map
+// @Failure 404 {object} fiber.Map
+// @Router /api/companies/{company_id}/nfse/{competencia}/download-zip [get]
+func (h *NFSeHandler) DownloadNFSeCompetenceZip(c *fiber.Ctx) error {
+	// Company resolved (and access checked) by CompanyMiddleware
+	companyID := middleware.GetCompanyFromContext(c).ID
+
+	user := middleware.GetUserFromContext(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Authentication required",
+		})
+	}
+
+	competence, ok := services.NormalizeCompetence(c.Params("competencia"))
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid competencia, expected YYYY-MM",
+		})
+	}
+
+	recordAudit(c, user, "EXPORT", "Document", 0, map[string]any{
+		"action":     "download_competence_zip",
+		"company_id": companyID,
+		"competence": competence,
+	})
+
+	c.Set(fiber.HeaderContentType, "application/zip")
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="nfse_`+strings.ReplaceAll(competence, "-", "")+`.zip"`)
+
+	// The body is produced after the handler returns, so it cannot use the request context
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		report, err := services.WriteCompetenceArchive(context.Background(), w, companyID, competence)
+		if err != nil {
+			logger.ErrorWithFields("Failed to write NFSe competence archive", err, map[string]any{
+				"operation":  "download_nfse_competence",
+				"company_id": companyID,
+				"competence": competence,
+				"user_id":    user.ID,
+			})
+			return
+		}
+
+		logger.InfoWithFields("NFSe competence archive written", map[string]any{
+			"operation":  "download_nfse_competence",
+			"company_id": companyID,
+			"competence": competence,
+			"user_id":    user.ID,
+			"documents":  report.Requested,
+			"written":    report.Written,
+			"skipped":    len(report.Skipped),
+			"bytes":      report.Bytes,
+		})
+	})
+
+	return nil
+}
+
+// ExportNFSeDocuments streams the metadata of the stored documents as CSV or XLSX
+// @Summary Export NFSe document metadata as CSV or XLSX
+// @Description Streams number, dates, competência, provider and taker, service value, ISS and status of every stored NFSe document,
+// @Description optionally narrowed to an issue date range and/or a competência. Rows are read in batches, so exports of any size are streamed.
+// @Tags nfse
+// @Produce text/csv
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param company_id path int true "Company ID"
+// @Param format query string false "Export format: csv (default) or xlsx"
+// @Param start_date query string false "Start issue date (YYYY-MM-DD)"
+// @Param end_date query string false "End issue date (YYYY-MM-DD)"
+// @Param competencia query string false "Competência (YYYY-MM)"
+// @Success 200 {file} file
+// @Failure 400 {object} fiber.Map
+// @Failure 401 {object} fiber.Map
+// @Failure 403 {object} fiber.Map
+// @Failure 404 {object} fiber.Map
+// @Router /api/companies/{company_id}/nfse/export [get]
+func (h *NFSeHandler) ExportNFSeDocuments(c *fiber.Ctx) error {
+	// Company resolved (and access checked) by CompanyMiddleware
+	companyID := middleware.GetCompanyFromContext(c).ID
+
+	user := middleware.GetUserFromContext(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Authentication required",
+		})
+	}
+
+	format := strings.ToLower(c.Query("format", services.ExportFormatCSV))
+	if format != services.ExportFormatCSV && format != services.ExportFormatXLSX {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid format. Use csv or xlsx",
+		})
+	}
+
+	var filter services.DocumentExportFilter
+	if raw := c.Query("start_date"); raw != "" {
+		startDate, err := time.Parse("2006-01-02", raw)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid start_date format. Use YYYY-MM-DD",
+			})
+		}
+		filter.StartDate = startDate
+	}
+	if raw := c.Query("end_date"); raw != "" {
+		endDate, err := time.Parse("2006-01-02", raw)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid end_date format. Use YYYY-MM-DD",
+			})
+		}
+		filter.EndDate = endDate
+	}
+	if !filter.StartDate.IsZero() && !filter.EndDate.IsZero() && filter.EndDate.Before(filter.StartDate) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "End date must be after start date",
+		})
+	}
+	if raw := c.Query("competencia"); raw != "" {
+		competence, ok := services.NormalizeCompetence(raw)
+		if !ok {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid competencia format. Use YYYY-MM",
+			})
+		}
+		filter.Competence = competence
+	}
+
+	recordAudit(c, user, "EXPORT", "Document", 0, map[string]any{
+		"action":      "export_metadata",
+		"company_id":  companyID,
+		"format":      format,
+		"start_date":  c.Query("start_date"),
+		"end_date":    c.Query("end_date"),
+		"competencia": filter.Competence,
+	})
+
+	filename := fmt.Sprintf("nfse_%d_%s.%s", companyID, time.Now().Format("20060102"), format)
+	if format == services.ExportFormatXLSX {
+		c.Set(fiber.HeaderContentType, "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	} else {
+		c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	}
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="`+filename+`"`)
+
+	// The body is produced after the handler returns, so it cannot use the request context
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		rows, err := services.WriteDocumentExport(context.Background(), w, companyID, filter, format)
+		if err != nil {
+			logger.ErrorWithFields("Failed to write NFSe document export", err, map[string]any{
+				"operation":  "export_nfse_documents",
+				"company_id": companyID,
+				"user_id":    user.ID,
+				"rows":       rows,
+			})
+			return
+		}
+
+		logger.InfoWithFields("NFSe document export written", map[string]any{
+			"operation":  "export_nfse_documents",
+			"company_id": companyID,
+			"user_id":    user.ID,
+			"format":     format,
+			"rows":       rows,
+		})
+	})
+
+	return nil
+}
+
+// GetNFSeCompetenceListing lists a competência reconciling database rows and stored objects
+// @Summary List a competência across database and storage
+// @Description Joins the NFSe documents of a competência with the XML objects stored for it. Rows whose object is missing
+// @Description are flagged object_missing and objects stored under the company CNPJ that no document references are flagged row_missing.
+// @Tags nfse
+// @Produce json
+// @Param company_id path int true "Company ID"
+// @Param competencia query string true "Competência (YYYY-MM)"
+// @Param only_drift query bool false "Return only flagged entries" default(false)
+// @Success 200 {object} services.CompetenceListing
+// @Failure 400 {object} fiber.Map
+// @Failure 401 {object} fiber.Map
+// @Failure 403 {object} fiber.Map
+// @Failure 404 {object} fiber.Map
+// @Failure 500 {object} fiber.Map
+// @Router /api/companies/{company_id}/nfse/competence [get]
+func (h *NFSeHandler) GetNFSeCompetenceListing(c *fiber.Ctx) error {
+	// Company resolved (and access checked) by CompanyMiddleware
+	company := middleware.GetCompanyFromContext(c)
+
+	competence, ok := services.NormalizeCompetence(c.Query("competencia"))
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid competencia, expected YYYY-MM",
+		})
+	}
+
+	listing, err := services.ListCompetenceDocuments(c.Context(), company, competence, c.QueryBool("only_drift", false))
+	if err != nil {
+		logger.ErrorWithFields("Failed to list competência", err, map[string]any{
+			"operation":  "list_competence",
+			"company_id": company.ID,
+			"competence": competence,
+		})
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to list competência",
+		})
+	}
+
+	return respondData(c, fiber.StatusOK, listing)
+}
+
+// GetNFSeByVerificationCode finds a stored NFSe by its verification code
+// @Summary Find NFSe by verification code
+// @Description Returns the company's NFSe with the given verification code. Spaces, dashes, dots and letter case are ignored.
+// @Tags nfse
+// @Produce json
+// @Param company_id path int true "Company ID"
+// @Param code path string true "Verification code"
+// @Success 200 {object} models.Document
+// @Failure 401 {object} fiber.Map
+// @Failure 403 {object} fiber.Map
+// @Failure 404 {object} fiber.Map
+// @Failure 500 {object} fiber.Map
+// @Router /api/companies/{company_id}/nfse/by-verification/{code} [get]
+func (h *NFSeHandler) GetNFSeByVerificationCode(c *fiber.Ctx) error {
+	// Company resolved (and access checked) by CompanyMiddleware
+	companyID := middleware.GetCompanyFromContext(c).ID
+
+	document, err := services.FindDocumentByVerificationCode(c.Context(), companyID, c.Params("code"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Document not found",
+			})
+		}
+		logger.ErrorWithFields("Failed to find NFSe by verification code", err, map[string]any{
+			"operation":  "find_nfse_by_verification",
+			"company_id": companyID,
+		})
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load document",
+		})
+	}
+
+	return respondData(c, fiber.StatusOK, document)
+}
+
+// GetNFSeCompetenceStatus reports whether a competência is closed
+// @Summary Competência completeness status
+// @Description Returns whether the competência is open or closed, how many notes arrived after it was closed and its numbering gaps
+// @Tags nfse
+// @Produce json
+// @Param company_id path int true "Company ID"
+// @Param competencia query string true "Competência (YYYY-MM)"
+// @Success 200 {object} services.CompetenceStatus
+// @Failure 400 {object} fiber.Map
+// @Failure 401 {object} fiber.Map
+// @Failure 403 {object} fiber.Map
+// @Failure 404 {object} fiber.Map
+// @Failure 500 {object} fiber.Map
+// @Router /api/companies/{company_id}/nfse/competence/status [get]
+func (h *NFSeHandler) GetNFSeCompetenceStatus(c *fiber.Ctx) error {
+	// Company resolved (and access checked) by CompanyMiddleware
+	companyID := middleware.GetCompanyFromContext(c).ID
+
+	competence, ok := services.NormalizeCompetence(c.Query("competencia"))
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Query parameter 'competencia' must be in YYYY-MM format",
+		})
+	}
+
+	status, err := services.GetCompetenceStatus(c.Context(), companyID, competence)
+	if err != nil {
+		logger.ErrorWithFields("Failed to load competência status", err, map[string]any{
+			"operation":   "competence_status",
+			"company_id":  companyID,
+			"competencia": competence,
+		})
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load competência status",
+		})
+	}
+
+	return respondData(c, fiber.StatusOK, status)
+}
+
+// CloseNFSeCompetence marks a competência as closed
+// @Summary Close a competência
+// @Description Marks the competência as closed: no more notes are expected. Notes ingested for it afterwards are flagged late_arrival (NFSE_FLAG_LATE_ARRIVALS). Closing a closed competência is a no-op.
+// @Tags nfse
+// @Produce json
+// @Param company_id path int true "Company ID"
+// @Param competencia query string true "Competência (YYYY-MM)"
+// @Success 200 {object} services.CompetenceStatus
+// @Failure 400 {object} fiber.Map
+// @Failure 401 {object} fiber.Map
+// @Failure 403 {object} fiber.Map
+// @Failure 404 {object} fiber.Map
+// @Failure 500 {object} fiber.Map
+// @Router /api/companies/{company_id}/nfse/competence/close [post]
+func (h *NFSeHandler) CloseNFSeCompetence(c *fiber.Ctx) error {
+	return h.setCompetenceClosed(c, true)
+}
+
+// ReopenNFSeCompetence reopens a closed competência
+// @Summary Reopen a competência
+// @Description Reopens a closed competência, so new notes are no longer flagged. Notes already flagged late_arrival keep the flag.
+// @Tags nfse
+// @Produce json
+// @Param company_id path int true "Company ID"
+// @Param competencia query string true "Competência (YYYY-MM)"
+// @Success 200 {object} services.CompetenceStatus
+// @Failure 400 {object} fiber.Map
+// @Failure 401 {object} fiber.Map
+// @Failure 403 {object} fiber.Map
+// @Failure 404 {object} fiber.Map
+// @Failure 500 {object} fiber.Map
+// @Router /api/companies/{company_id}/nfse/competence/reopen [post]
+func (h *NFSeHandler) ReopenNFSeCompetence(c *fiber.Ctx) error {
+	return h.setCompetenceClosed(c, false)
+}
+
+// setCompetenceClosed closes or reopens the competência of the request and returns its status
+func (h *NFSeHandler) setCompetenceClosed(c *fiber.Ctx, closed bool) error {
+	// Company resolved (and access checked) by CompanyMiddleware
+	companyID := middleware.GetCompanyFromContext(c).ID
+
+	// Get user from context
+	user := middleware.GetUserFromContext(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Authentication required",
+		})
+	}
+
+	competence, ok := services.NormalizeCompetence(c.Query("competencia"))
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Query parameter 'competencia' must be in YYYY-MM format",
+		})
+	}
+
+	var changed bool
+	var err error
+	if closed {
+		_, changed, err = services.CloseCompetence(c.Context(), companyID, competence, user.ID)
+	} else {
+		changed, err = services.ReopenCompetence(c.Context(), companyID, competence)
+	}
+	if err != nil {
+		logger.ErrorWithFields("Failed to update competência status", err, map[string]any{
+			"operation":   "competence_status",
+			"company_id":  companyID,
+			"competencia": competence,
+			"closed":      closed,
+			"user_id":     user.ID,
+		})
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update competência status",
+		})
+	}
+
+	if changed {
+		action := "close_competence"
+		if !closed {
+			action = "reopen_competence"
+		}
+		recordAudit(c, user, "UPDATE", "CompetenceClosure", 0, map[string]any{
+			"action":      action,
+			"company_id":  companyID,
+			"competencia": competence,
+		})
+	}
+
+	status, err := services.GetCompetenceStatus(c.Context(), companyID, competence)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load competência status",
+		})
+	}
+
+	return respondData(c, fiber.StatusOK, status)
+}
+
+// DownloadNFSeXML streams the stored XML of one NFSe document
+// @Summary Download the XML of an NFSe document
+// @Description Returns the stored XML of the document, decompressed when it was stored gzipped
+// @Tags nfse
+// @Produce application/xml
+// @Param company_id path int true "Company ID"
+// @Param document_id path int true "Document ID"
+// @Success 200 {file} file
+// @Failure 400 {object} fiber.Map
+// @Failure 401 {object} fiber.Map
+// @Failure 403 {object} fiber.Map
+// @Failure 404 {object} fiber.Map "Document not found, XML not stored or stored object missing"
+// @Failure 500 {object} fiber.Map
+// @Router /api/companies/{company_id}/nfse/{document_id}/xml [get]
+func (h *NFSeHandler) DownloadNFSeXML(c *fiber.Ctx) error {
+	// Company resolved (and access checked) by CompanyMiddleware
+	companyID := middleware.GetCompanyFromContext(c).ID
+
+	documentID, err := strconv.ParseInt(c.Params("document_id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid document ID",
+		})
+	}
+
+	data, fileName, err := services.ReadDocumentXML(c.Context(), companyID, documentID)
+	switch {
+	case errors.Is(err, services.ErrDocumentNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Document not found",
+		})
+	case errors.Is(err, services.ErrXMLNotStored):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Document XML is not stored (metadata-only company)",
+		})
+	case errors.Is(err, services.ErrObjectMissing):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Stored XML object is missing",
+		})
+	case err != nil:
+		logger.ErrorWithFields("Failed to read NFSe XML", err, map[string]any{
+			"operation":   "download_nfse_xml",
+			"company_id":  companyID,
+			"document_id": documentID,
+		})
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to read document XML",
+		})
+	}
+
+	c.Set(fiber.HeaderContentType, "application/xml")
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="`+fileName+`"`)
+	return c.Send(data)
+}
+
+// DownloadNFSePDF renders the DANFSE PDF of a stored document
+// @Summary Download the DANFSE PDF of an NFSe
+// @Description Renders the DANFSE (human-readable PDF) of the NFSe with the given number from its stored XML: provider, taker,
+// @Description service description, values and verification code, with a CANCELADA watermark on cancelled notes. The PDF is stored
+// @Description next to the XML and served with an ETag, so unchanged notes are not rendered again and clients can revalidate.
+// @Tags nfse
+// @Produce application/pdf
+// @Param company_id path int true "Company ID"
+// @Param number path string true "NFSe number"
+// @Param verification_code query string false "Verification code, when several providers share the number"
+// @Success 200 {file} file
+// @Success 304 "Not modified"
+// @Failure 401 {object} fiber.Map
+// @Failure 403 {object} fiber.Map
+// @Failure 404 {object} fiber.Map
+// @Failure 500 {object} fiber.Map
+// @Router /api/companies/{company_id}/nfse/{number}/pdf [get]
+func (h *NFSeHandler) DownloadNFSePDF(c *fiber.Ctx) error {
+	// Company resolved (and access checked) by CompanyMiddleware
+	companyID := middleware.GetCompanyFromContext(c).ID
+
+	pdf, err := services.GetDocumentDANFSE(c.Context(), companyID, c.Params("number"), c.Query("verification_code"))
+	switch {
+	case errors.Is(err, services.ErrDocumentNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Document not found",
+		})
+	case errors.Is(err, services.ErrXMLNotStored):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Document XML is not stored (metadata-only company)",
+		})
+	case errors.Is(err, services.ErrObjectMissing):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Stored XML object is missing",
+		})
+	case err != nil:
+		logger.ErrorWithFields("Failed to render NFSe DANFSE", err, map[string]any{
+			"operation":  "download_nfse_pdf",
+			"company_id": companyID,
+			"number":     c.Params("number"),
+		})
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to render document PDF",
+		})
+	}
+
+	c.Set(fiber.HeaderETag, pdf.ETag)
+	c.Set(fiber.HeaderCacheControl, "private, max-age=3600")
+	if c.Get(fiber.HeaderIfNoneMatch) == pdf.ETag {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
+	c.Set(fiber.HeaderContentType, "application/pdf")
+	c.Set(fiber.HeaderContentDisposition, `inline; filename="`+pdf.FileName+`"`)
+	return c.Send(pdf.Data)
+}
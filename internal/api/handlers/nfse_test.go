@@ -0,0 +1,528 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/zoomxml/config"
+	"github.com/zoomxml/internal/api/middleware"
+	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/database/databasetest"
+	"github.com/zoomxml/internal/models"
+	"github.com/zoomxml/internal/repository/repositorytest"
+	"github.com/zoomxml/internal/services"
+	"github.com/zoomxml/internal/storage"
+)
+
+// useFakeIngest stores objects under a temporary directory and switches off the parts of
+// ingest that still write to Postgres directly, so uploads run against the fake repositories
+func useFakeIngest(t *testing.T) {
+	t.Helper()
+	cfg := config.Get()
+	previousStorage := storage.Storage
+	localPath, storeLogs, lateArrivals := cfg.Storage.LocalPath, cfg.Logger.StoreProcessingLogs, cfg.NFSeScheduler.FlagLateArrivals
+	cfg.Storage.LocalPath = t.TempDir()
+	cfg.Logger.StoreProcessingLogs = false
+	cfg.NFSeScheduler.FlagLateArrivals = false
+	storage.Storage = storage.NewFilesystemService()
+	t.Cleanup(func() {
+		storage.Storage = previousStorage
+		cfg.Storage.LocalPath = localPath
+		cfg.Logger.StoreProcessingLogs = storeLogs
+		cfg.NFSeScheduler.FlagLateArrivals = lateArrivals
+	})
+}
+
+// uploadRequest builds a multipart upload with one "files" part per XML
+func uploadRequest(t *testing.T, xmls ...string) *http.Request {
+	t.Helper()
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	for i, content := range xmls {
+		part, err := writer.CreateFormFile("files", fmt.Sprintf("%d.xml", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		part.Write([]byte(content))
+	}
+	writer.Close()
+
+	req := httptest.NewRequest("POST", "/upload", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+// companyApp serves handler on method and path for user, with company resolved as
+// CompanyMiddleware would
+func companyApp(user *models.User, company *models.Company, method, path string, handler fiber.Handler) *fiber.App {
+	app := fiber.New()
+	app.Add(method, path, func(c *fiber.Ctx) error {
+		c.Locals(string(middleware.UserKey), user)
+		c.Locals(string(middleware.CompanyKey), company)
+		return c.Next()
+	}, handler)
+	return app
+}
+
+// testNFSeXML builds a minimal NFSe of company 12345678000190 the parser accepts
+func testNFSeXML(number, verificationCode string) string {
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<consultarNotaResponse><ListaNfse><ComplNfse><Nfse><InfNfse>
+<Numero>%s</Numero><CodigoVerificacao>%s</CodigoVerificacao>
+<DataEmissao>2025-03-14 10:00:00</DataEmissao><Competencia>03/2025</Competencia>
+<Servico><Valores><ValorServicos>100.00</ValorServicos></Valores></Servico>
+<PrestadorServico><IdentificacaoPrestador><Cnpj>12345678000190</Cnpj></IdentificacaoPrestador></PrestadorServico>
+</InfNfse></Nfse></ComplNfse></ListaNfse></consultarNotaResponse>`, number, verificationCode)
+}
+
+func TestUploadNFSeDocuments(t *testing.T) {
+	tests := []struct {
+		name          string
+		xmls          []string
+		insertErr     error
+		wantStatus    int
+		wantProcessed int
+		wantDuplicate int
+		wantErrors    int
+	}{
+		{"no files", nil, nil, fiber.StatusBadRequest, 0, 0, 0},
+		{"new and duplicate notes", []string{testNFSeXML("1", "AAA"), testNFSeXML("2", "EXISTING")}, nil, fiber.StatusOK, 1, 1, 0},
+		{"invalid XML", []string{"<consultarNotaResponse>"}, nil, fiber.StatusOK, 0, 0, 1},
+		{"database failure", []string{testNFSeXML("1", "AAA")}, errors.New("connection reset"), fiber.StatusOK, 0, 0, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useFakeIngest(t)
+
+			company := &models.Company{ID: 1, CNPJ: "12345678000190", ZeroValuePolicy: models.ZeroValuePolicyFlag}
+			documents := &repositorytest.DocumentRepository{InsertErr: tt.insertErr}
+			documents.Add(&models.Document{CompanyID: company.ID, Type: models.DocumentTypeNFSe, Number: "9", VerificationCode: "EXISTING"})
+			companies := &repositorytest.CompanyRepository{Companies: map[int64]*models.Company{company.ID: company}}
+			handler := NewNFSeHandlerWithService(services.NewNFSeServiceWithRepositories(nil, documents, companies))
+
+			app := fiber.New()
+			app.Post("/upload", func(c *fiber.Ctx) error {
+				c.Locals(string(middleware.UserKey), &models.User{ID: 1})
+				c.Locals(string(middleware.CompanyKey), company)
+				return c.Next()
+			}, handler.UploadNFSeDocuments)
+
+			resp, err := app.Test(uploadRequest(t, tt.xmls...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if tt.wantStatus != fiber.StatusOK {
+				return
+			}
+
+			var result struct {
+				Processed  int                `json:"processed"`
+				Duplicates int                `json:"duplicates"`
+				Errors     int                `json:"errors"`
+				Results    []UploadNFSeResult `json:"results"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+				t.Fatal(err)
+			}
+			if result.Processed != tt.wantProcessed || result.Duplicates != tt.wantDuplicate || result.Errors != tt.wantErrors {
+				t.Errorf("processed/duplicates/errors = %d/%d/%d, want %d/%d/%d",
+					result.Processed, result.Duplicates, result.Errors, tt.wantProcessed, tt.wantDuplicate, tt.wantErrors)
+			}
+			if len(result.Results) != len(tt.xmls) {
+				t.Fatalf("results = %d, want one per file (%d)", len(result.Results), len(tt.xmls))
+			}
+			for i, fileResult := range result.Results {
+				if want := fmt.Sprintf("%d.xml", i); fileResult.FileName != want {
+					t.Errorf("result %d file = %q, want %q", i, fileResult.FileName, want)
+				}
+			}
+		})
+	}
+}
+
+func TestFetchNFSeDocumentsWithoutCredentials(t *testing.T) {
+	databasetest.Require(t)
+	company := databasetest.CreateCompany(t, nil)
+
+	app := fiber.New()
+	app.Post("/fetch", func(c *fiber.Ctx) error {
+		c.Locals(string(middleware.UserKey), &models.User{ID: 1})
+		c.Locals(string(middleware.CompanyKey), company)
+		return c.Next()
+	}, NewNFSeHandler().FetchNFSeDocuments)
+
+	req := httptest.NewRequest("POST", "/fetch", strings.NewReader(`{"start_date":"2025-03-01","end_date":"2025-03-31"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != fiber.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, fiber.StatusUnprocessableEntity)
+	}
+	var body struct {
+		Error   string `json:"error"`
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Code != "NO_CREDENTIALS" || body.Message != services.NoCredentialsMessage || body.Error == "" {
+		t.Errorf("body = %+v, want code NO_CREDENTIALS with the credentials message", body)
+	}
+}
+
+func TestMarkNFSeDocumentsReviewed(t *testing.T) {
+	databasetest.Require(t)
+
+	tests := []struct {
+		name         string
+		documents    []int  // indexes of the seeded documents to send as document_ids
+		filter       string // JSON filter, if any
+		wantStatus   int
+		wantUpdated  int64
+		wantReviewed []int // indexes of the documents reviewed afterwards
+	}{
+		{"document list", []int{0, 1}, "", fiber.StatusOK, 2, []int{0, 1}},
+		{"list skips non-reviewable documents", []int{0, 2}, "", fiber.StatusOK, 1, []int{0}},
+		{"filter", nil, `{"service_code":"01.07"}`, fiber.StatusOK, 1, []int{0}},
+		{"list and filter", []int{0, 1}, `{"service_code":"17.01"}`, fiber.StatusOK, 1, []int{1}},
+		{"nothing selected", nil, "", fiber.StatusBadRequest, 0, nil},
+		{"empty filter", nil, `{}`, fiber.StatusBadRequest, 0, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			company := databasetest.CreateCompany(t, nil)
+			seeded := []*models.Document{
+				databasetest.CreateDocument(t, &models.Document{CompanyID: company.ID, Number: "1", ServiceCode: "01.07", Status: models.DocumentStatusProcessed}),
+				databasetest.CreateDocument(t, &models.Document{CompanyID: company.ID, Number: "2", ServiceCode: "17.01", Status: models.DocumentStatusProcessed}),
+				databasetest.CreateDocument(t, &models.Document{CompanyID: company.ID, Number: "3", ServiceCode: "01.07", Status: models.DocumentStatusError}),
+			}
+
+			request := map[string]any{}
+			if tt.documents != nil {
+				ids := []int64{}
+				for _, i := range tt.documents {
+					ids = append(ids, seeded[i].ID)
+				}
+				request["document_ids"] = ids
+			}
+			if tt.filter != "" {
+				request["filter"] = json.RawMessage(tt.filter)
+			}
+			body, _ := json.Marshal(request)
+
+			app := companyApp(&models.User{ID: 1}, company, fiber.MethodPost, "/mark-reviewed", NewNFSeHandler().MarkNFSeDocumentsReviewed)
+			req := httptest.NewRequest("POST", "/mark-reviewed", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if tt.wantStatus != fiber.StatusOK {
+				return
+			}
+			var result struct {
+				Updated int64 `json:"updated"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+				t.Fatal(err)
+			}
+			if result.Updated != tt.wantUpdated {
+				t.Errorf("updated = %d, want %d", result.Updated, tt.wantUpdated)
+			}
+
+			for i, document := range seeded {
+				stored := &models.Document{}
+				if err := database.DB.NewSelect().Model(stored).Where("id = ?", document.ID).Scan(context.Background()); err != nil {
+					t.Fatal(err)
+				}
+				if reviewed := stored.Status == models.DocumentStatusReviewed; reviewed != slices.Contains(tt.wantReviewed, i) {
+					t.Errorf("document %d status = %q, want reviewed = %v", i, stored.Status, !reviewed)
+				}
+			}
+		})
+	}
+}
+
+// consultMunicipalityCode is served by consultProvider in the handler tests
+const consultMunicipalityCode = "9999997"
+
+// consultProvider serves its XMLs on the first page of any query
+type consultProvider struct {
+	xmls []string
+}
+
+func (p *consultProvider) Capabilities() services.ProviderCapabilities {
+	return services.ProviderCapabilities{Name: "consult", CredentialTypes: []string{"prefeitura_token"}, Paginated: true}
+}
+
+func (p *consultProvider) Authenticate(ctx context.Context, credential *models.CompanyCredential) (string, error) {
+	return "token", nil
+}
+
+func (p *consultProvider) FetchDocuments(ctx context.Context, request services.ProviderFetchRequest, handle func([]services.NFSeDocument) error) (*services.NFSeProcessResult, error) {
+	if request.Page > 1 {
+		return &services.NFSeProcessResult{Success: true}, nil
+	}
+	documents := make([]services.NFSeDocument, len(p.xmls))
+	for i, content := range p.xmls {
+		documents[i] = services.NFSeDocument{FileName: fmt.Sprintf("%d.xml", i), XMLContent: content}
+	}
+	if err := handle(documents); err != nil {
+		return nil, err
+	}
+	return &services.NFSeProcessResult{Success: true, DocumentsCount: len(documents)}, nil
+}
+
+func TestConsultNFSeCompetence(t *testing.T) {
+	databasetest.Require(t)
+	useFakeIngest(t)
+
+	// The code is only served by this test, so the registration is left in place
+	services.RegisterMunicipalityProvider(consultMunicipalityCode, func(*http.Client) services.MunicipalityProvider {
+		return &consultProvider{xmls: []string{testNFSeXML("1", "AAA"), testNFSeXML("2", "BBB")}}
+	})
+
+	withCredentials := databasetest.CreateCompany(t, func(c *models.Company) { c.MunicipalityCode = consultMunicipalityCode })
+	databasetest.CreateCredential(t, &models.CompanyCredential{CompanyID: withCredentials.ID, Type: "prefeitura_token"})
+	withoutCredentials := databasetest.CreateCompany(t, func(c *models.Company) { c.MunicipalityCode = consultMunicipalityCode })
+
+	resultKeys := []string{"competence", "details", "details_truncated", "documents_found", "duplicate_documents", "duration_ms", "end_date", "error_documents", "pages", "start_date", "stored_documents"}
+	tests := []struct {
+		name       string
+		company    *models.Company
+		query      string
+		wantStatus int
+	}{
+		{"synchronous result", withCredentials, "?competencia=2025-03&sync=true", fiber.StatusOK},
+		{"invalid competência", withCredentials, "?competencia=março", fiber.StatusBadRequest},
+		{"no credentials", withoutCredentials, "?competencia=2025-03", fiber.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			company := &models.Company{ID: tt.company.ID, CNPJ: "12345678000190", MunicipalityCode: consultMunicipalityCode}
+			companies := &repositorytest.CompanyRepository{Companies: map[int64]*models.Company{company.ID: company}}
+			handler := NewNFSeHandlerWithService(services.NewNFSeServiceWithRepositories(nil, &repositorytest.DocumentRepository{}, companies))
+			app := companyApp(&models.User{ID: 1}, tt.company, fiber.MethodPost, "/consult", handler.ConsultNFSeCompetence)
+
+			resp, err := app.Test(httptest.NewRequest("POST", "/consult"+tt.query, nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if tt.wantStatus != fiber.StatusOK {
+				return
+			}
+
+			var body map[string]json.RawMessage
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			keys := slices.Sorted(maps.Keys(body))
+			if !slices.Equal(keys, resultKeys) {
+				t.Errorf("result keys = %v, want %v", keys, resultKeys)
+			}
+
+			var result services.ConsultationResult
+			raw, _ := json.Marshal(body)
+			if err := json.Unmarshal(raw, &result); err != nil {
+				t.Fatal(err)
+			}
+			if result.Competence != "2025-03" || result.StartDate != "2025-03-01" || result.EndDate != "2025-03-31" ||
+				result.DocumentsFound != 2 || result.StoredDocuments != 2 || len(result.Details) != 2 || result.DetailsTruncated {
+				t.Errorf("result = %+v, want both notes of 2025-03 stored with details", result)
+			}
+			for _, detail := range result.Details {
+				if detail.Status != "stored" || detail.DocumentID == 0 || detail.FileName == "" {
+					t.Errorf("detail = %+v, want a stored document", detail)
+				}
+			}
+		})
+	}
+}
+
+// TestDownloadNFSeDocumentsValidation checks the ID lists refused before any document is read
+func TestDownloadNFSeDocumentsValidation(t *testing.T) {
+	databasetest.UseClosed(t)
+	app := companyApp(&models.User{ID: 1}, &models.Company{ID: 1}, fiber.MethodPost, "/download", NewNFSeHandler().DownloadNFSeDocuments)
+
+	tooMany := make([]string, services.MaxArchiveDocuments+1)
+	for i := range tooMany {
+		tooMany[i] = fmt.Sprint(i + 1)
+	}
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"invalid body", `{"document_ids":`},
+		{"no IDs", `{"document_ids":[]}`},
+		{"more than the maximum", `{"document_ids":[` + strings.Join(tooMany, ",") + `]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status := postJSON(t, app, "/download", tt.body, nil); status != fiber.StatusBadRequest {
+				t.Errorf("DownloadNFSeDocuments() status = %d, want %d", status, fiber.StatusBadRequest)
+			}
+		})
+	}
+}
+
+// TestDownloadNFSeRangeValidation checks the date ranges refused before any document is read
+func TestDownloadNFSeRangeValidation(t *testing.T) {
+	databasetest.UseClosed(t)
+	app := companyApp(&models.User{ID: 1}, &models.Company{ID: 1}, fiber.MethodGet, "/download", NewNFSeHandler().DownloadNFSeRange)
+
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{"missing start date", "end_date=2025-03-31"},
+		{"invalid end date", "start_date=2025-03-01&end_date=31/03/2025"},
+		{"end before start", "start_date=2025-03-31&end_date=2025-03-01"},
+		{"range longer than the maximum", "start_date=2024-01-01&end_date=2025-01-01"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/download?"+tt.query, nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != fiber.StatusBadRequest {
+				t.Errorf("DownloadNFSeRange(%s) status = %d, want %d", tt.query, resp.StatusCode, fiber.StatusBadRequest)
+			}
+		})
+	}
+}
+
+// TestGetNFSeByVerificationCodeWithoutCode checks that a code with nothing to compare
+// is not found without querying
+func TestGetNFSeByVerificationCodeWithoutCode(t *testing.T) {
+	databasetest.UseClosed(t)
+	app := companyApp(&models.User{ID: 1}, &models.Company{ID: 1}, fiber.MethodGet, "/by-verification/:code", NewNFSeHandler().GetNFSeByVerificationCode)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/by-verification/-.-", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != fiber.StatusNotFound {
+		t.Errorf("GET /by-verification/-.- status = %d, want %d", resp.StatusCode, fiber.StatusNotFound)
+	}
+}
+
+func TestGetNFSeByVerificationCode(t *testing.T) {
+	databasetest.Require(t)
+	useResponseEnvelope(t, false)
+	company := databasetest.CreateCompany(t, nil)
+	stored := databasetest.CreateDocument(t, &models.Document{CompanyID: company.ID, Number: "4521", VerificationCode: "AB12-CD34"})
+	app := companyApp(&models.User{ID: 1}, company, fiber.MethodGet, "/by-verification/:code", NewNFSeHandler().GetNFSeByVerificationCode)
+
+	tests := []struct {
+		name       string
+		code       string
+		wantStatus int
+	}{
+		{"found", "AB12-CD34", fiber.StatusOK},
+		{"found by the normalized form", "ab12cd34", fiber.StatusOK},
+		{"not found", "AB12-CD35", fiber.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := app.Test(httptest.NewRequest("GET", "/by-verification/"+tt.code, nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("GET /by-verification/%s status = %d, want %d", tt.code, resp.StatusCode, tt.wantStatus)
+			}
+			if tt.wantStatus != fiber.StatusOK {
+				return
+			}
+			document := models.Document{}
+			if err := json.NewDecoder(resp.Body).Decode(&document); err != nil {
+				t.Fatal(err)
+			}
+			if document.ID != stored.ID {
+				t.Errorf("GET /by-verification/%s returned document %d, want %d", tt.code, document.ID, stored.ID)
+			}
+		})
+	}
+}
+
+func TestCloseNFSeCompetence(t *testing.T) {
+	databasetest.Require(t)
+	useResponseEnvelope(t, false)
+	company := databasetest.CreateCompany(t, nil)
+	t.Cleanup(func() { services.ReopenCompetence(context.Background(), company.ID, "2025-03") })
+	app := companyApp(&models.User{ID: 1}, company, fiber.MethodPost, "/competence/close", NewNFSeHandler().CloseNFSeCompetence)
+
+	tests := []struct {
+		name       string
+		competence string
+		wantStatus int
+	}{
+		{"invalid competência", "2025-13", fiber.StatusBadRequest},
+		{"close", "2025-03", fiber.StatusOK},
+		{"close again", "03/2025", fiber.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := app.Test(httptest.NewRequest("POST", "/competence/close?competencia="+tt.competence, nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("POST /competence/close status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if tt.wantStatus != fiber.StatusOK {
+				return
+			}
+			status := services.CompetenceStatus{}
+			if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+				t.Fatal(err)
+			}
+			if status.Competence != "2025-03" || status.Status != services.CompetenceStatusClosed {
+				t.Errorf("status = %+v, want 2025-03 closed", status)
+			}
+		})
+	}
+}
@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/uptrace/bun"
+	"github.com/zoomxml/internal/api/middleware"
+	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/logger"
+	"github.com/zoomxml/internal/models"
+	"github.com/zoomxml/internal/services"
+)
+
+// OnboardingHandler gerencia o cadastro completo de empresas em uma única chamada
+type OnboardingHandler struct {
+	cnpjService *services.CNPJService
+}
+
+// NewOnboardingHandler cria uma nova instância do handler de onboarding
+func NewOnboardingHandler() *OnboardingHandler {
+	return &OnboardingHandler{
+		cnpjService: services.NewCNPJService(services.SharedTransport()),
+	}
+}
+
+// OnboardRequest representa o cadastro de empresa, credencial e busca automática
+type OnboardRequest struct {
+	Company    CreateCompanyRequest    `json:"company"`
+	Credential CreateCredentialRequest `json:"credential"`
+	EnrichCNPJ bool                    `json:"enrich_cnpj"`         // Completa os dados vazios da empresa pela consulta de CNPJ
+	AutoSync   *bool                   `json:"auto_sync,omitempty"` // Busca automática de NFSe (padrão: true)
+}
+
+// OnboardResponse contém os IDs criados pelo onboarding
+type OnboardResponse struct {
+	CompanyID    int64 `json:"company_id"`
+	CredentialID int64 `json:"credential_id"`
+	AutoSync     bool  `json:"auto_sync"`
+	Enriched     bool  `json:"enriched"`
+}
+
+// onboardingError é uma falha do onboarding com o status HTTP correspondente
+type onboardingError struct {
+	status  int
+	message string
+}
+
+func (e *onboardingError) Error() string {
+	return e.message
+}
+
+// Onboard cria empresa, credencial e busca automática em uma única transação
+// @Summary Onboarding de empresa
+// @Description Cria a empresa (opcionalmente completada pela consulta de CNPJ), a credencial criptografada e habilita a busca automática em uma única transação; qualquer falha desfaz tudo
+// @Tags companies
+// @Accept json
+// @Produce json
+// @Param onboarding body OnboardRequest true "Empresa, credencial e busca automática"
+// @Success 201 {object} OnboardResponse
+// @Failure 400 {object} SwaggerValidationError "Erro de validação"
+// @Failure 401 {object} SwaggerError "Autenticação necessária"
+// @Failure 409 {object} SwaggerError "CNPJ já existe"
+// @Failure 422 {object} SwaggerError "Credencial inválida"
+// @Failure 500 {object} SwaggerError "Erro interno"
+// @Security UserToken
+// @Router /onboard [post]
+func (h *OnboardingHandler) Onboard(c *fiber.Ctx) error {
+	user := middleware.GetUserFromContext(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Authentication required",
+		})
+	}
+
+	var req OnboardRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	enriched := false
+	if req.EnrichCNPJ {
+		enriched = h.enrichCompany(c.Context(), &req.Company)
+	}
+
+	// Validar empresa e credencial
+	if body := prepareCompanyRequest(&req.Company); body != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(body)
+	}
+
+	if err := validateStruct(req.Credential); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": err,
+		})
+	}
+
+	autoSync := true
+	if req.AutoSync != nil {
+		autoSync = *req.AutoSync
+	}
+
+	company := companyFromRequest(req.Company)
+	company.AutoFetch = autoSync
+
+	if req.Credential.Environment == "" {
+		req.Credential.Environment = company.DefaultEnvironment
+	}
+
+	credential := &models.CompanyCredential{
+		Type:        req.Credential.Type,
+		Name:        req.Credential.Name,
+		Description: req.Credential.Description,
+		Login:       req.Credential.Login,
+		Environment: req.Credential.Environment,
+		Active:      true,
+	}
+
+	err := database.DB.RunInTx(c.Context(), &sql.TxOptions{}, func(ctx context.Context, tx bun.Tx) error {
+		exists, err := tx.NewSelect().
+			Model((*models.Company)(nil)).
+			Where("cnpj = ?", company.CNPJ).
+			Exists(ctx)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return &onboardingError{status: fiber.StatusConflict, message: "CNPJ already exists"}
+		}
+
+		if _, err := tx.NewInsert().Model(company).Exec(ctx); err != nil {
+			return err
+		}
+
+		credential.CompanyID = company.ID
+		if err := credential.SetCredentialData(req.Credential.Login, req.Credential.Password, req.Credential.Token); err != nil {
+			return &onboardingError{status: fiber.StatusUnprocessableEntity, message: "Invalid credential: " + err.Error()}
+		}
+
+		_, err = tx.NewInsert().Model(credential).Exec(ctx)
+		return err
+	})
+
+	if err != nil {
+		var onboardErr *onboardingError
+		if errors.As(err, &onboardErr) {
+			return c.Status(onboardErr.status).JSON(fiber.Map{
+				"error": onboardErr.message,
+			})
+		}
+
+		logger.ErrorWithFields("Failed to onboard company", err, map[string]any{
+			"operation": "onboard_company",
+			"cnpj":      company.CNPJ,
+			"user_id":   user.ID,
+		})
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to onboard company",
+		})
+	}
+
+	recordAudit(c, user, "CREATE", "Company", company.ID, map[string]any{
+		"onboarding":    true,
+		"credential_id": credential.ID,
+		"auto_sync":     autoSync,
+	})
+
+	return respondData(c, fiber.StatusCreated, OnboardResponse{
+		CompanyID:    company.ID,
+		CredentialID: credential.ID,
+		AutoSync:     autoSync,
+		Enriched:     enriched,
+	})
+}
+
+// enrichCompany completa os campos vazios da empresa com a consulta de CNPJ. Falhas da
+// consulta são apenas logadas; os dados informados continuam valendo.
+func (h *OnboardingHandler) enrichCompany(ctx context.Context, req *CreateCompanyRequest) bool {
+	data, err := h.cnpjService.ConsultarCNPJ(ctx, req.CNPJ)
+	if err != nil {
+		logger.WarnWithFields("CNPJ enrichment failed during onboarding", map[string]any{
+			"operation": "onboard_company",
+			"cnpj":      req.CNPJ,
+			"error":     err.Error(),
+		})
+		return false
+	}
+
+	fill := func(field *string, value string) {
+		if *field == "" {
+			*field = value
+		}
+	}
+
+	fill(&req.Name, data.Name)
+	fill(&req.TradeName, data.TradeName)
+	fill(&req.Address, data.Address)
+	fill(&req.Number, data.Number)
+	fill(&req.Complement, data.Complement)
+	fill(&req.District, data.District)
+	fill(&req.City, data.City)
+	fill(&req.State, data.State)
+	fill(&req.ZipCode, data.ZipCode)
+	fill(&req.Phone, data.Phone)
+	fill(&req.Email, data.Email)
+	fill(&req.CompanySize, data.CompanySize)
+	fill(&req.MainActivity, data.MainActivity)
+	fill(&req.SecondaryActivity, strings.Join(data.SecondaryActivities, "; "))
+	fill(&req.LegalNature, data.LegalNature)
+	fill(&req.OpeningDate, data.OpeningDate)
+	fill(&req.RegistrationStatus, data.RegistrationStatus)
+
+	return true
+}
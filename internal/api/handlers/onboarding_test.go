@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/zoomxml/internal/api/middleware"
+	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/database/databasetest"
+	"github.com/zoomxml/internal/models"
+)
+
+// onboardingApp serves Onboard at /onboard as user
+func onboardingApp(user *models.User) *fiber.App {
+	app := fiber.New()
+	app.Post("/onboard", func(c *fiber.Ctx) error {
+		c.Locals(string(middleware.UserKey), user)
+		return c.Next()
+	}, NewOnboardingHandler().Onboard)
+	return app
+}
+
+// onboardBody builds an onboarding request for cnpj with a token credential
+func onboardBody(cnpj, token string) string {
+	return fmt.Sprintf(`{"company":{"name":"Empresa Onboarding","cnpj":"%s"},
+		"credential":{"type":"prefeitura_token","name":"Token da prefeitura","token":"%s"}}`, cnpj, token)
+}
+
+// TestOnboardValidation checks the requests rejected before the transaction starts
+func TestOnboardValidation(t *testing.T) {
+	databasetest.UseClosed(t)
+	app := onboardingApp(&models.User{ID: 1, Role: "admin"})
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"invalid body", `{"company":`},
+		{"company without name", `{"company":{"cnpj":"12345678000190"},"credential":{"type":"prefeitura_token","name":"Token","token":"x"}}`},
+		{"unknown credential type", `{"company":{"name":"Empresa","cnpj":"12345678000190"},"credential":{"type":"certificate","name":"Token"}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status := postJSON(t, app, "/onboard", tt.body, nil); status != fiber.StatusBadRequest {
+				t.Errorf("Onboard() status = %d, want %d", status, fiber.StatusBadRequest)
+			}
+		})
+	}
+}
+
+func TestOnboard(t *testing.T) {
+	databasetest.Require(t)
+	ctx := context.Background()
+	user := databasetest.CreateUser(t, "admin")
+	app := onboardingApp(user)
+
+	cnpj := fmt.Sprintf("%014d", time.Now().UnixNano()%100000000000000)
+	t.Cleanup(func() {
+		database.DB.NewDelete().Model((*models.CompanyCredential)(nil)).
+			Where("company_id IN (SELECT id FROM companies WHERE cnpj = ?)", cnpj).Exec(ctx)
+		database.DB.NewDelete().Model((*models.Company)(nil)).Where("cnpj = ?", cnpj).Exec(ctx)
+	})
+	companyExists := func() bool {
+		t.Helper()
+		exists, err := database.DB.NewSelect().Model((*models.Company)(nil)).Where("cnpj = ?", cnpj).Exists(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return exists
+	}
+
+	// A credential that cannot be stored rolls the company back
+	if status := postJSON(t, app, "/onboard", onboardBody(cnpj, ""), nil); status != fiber.StatusUnprocessableEntity {
+		t.Fatalf("Onboard() with an invalid credential status = %d, want %d", status, fiber.StatusUnprocessableEntity)
+	}
+	if companyExists() {
+		t.Fatal("company was kept after the credential failed")
+	}
+
+	var response OnboardResponse
+	if status := postJSON(t, app, "/onboard", onboardBody(cnpj, "prefeitura-token"), &response); status != fiber.StatusCreated {
+		t.Fatalf("Onboard() status = %d, want %d", status, fiber.StatusCreated)
+	}
+
+	company := &models.Company{}
+	if err := database.DB.NewSelect().Model(company).Where("id = ?", response.CompanyID).Scan(ctx); err != nil {
+		t.Fatalf("onboarded company not found: %v", err)
+	}
+	if company.CNPJ != cnpj || !company.AutoFetch || !response.AutoSync {
+		t.Errorf("company = %s auto_fetch %v, want %s with auto sync", company.CNPJ, company.AutoFetch, cnpj)
+	}
+
+	credential := &models.CompanyCredential{}
+	if err := database.DB.NewSelect().Model(credential).Where("id = ?", response.CredentialID).Scan(ctx); err != nil {
+		t.Fatalf("onboarded credential not found: %v", err)
+	}
+	if credential.CompanyID != company.ID {
+		t.Errorf("credential company = %d, want %d", credential.CompanyID, company.ID)
+	}
+	if _, _, token, err := credential.GetCredentialData(); err != nil || token != "prefeitura-token" {
+		t.Errorf("credential token = %q, %v, want the encrypted token", token, err)
+	}
+
+	if status := postJSON(t, app, "/onboard", onboardBody(cnpj, "prefeitura-token"), nil); status != fiber.StatusConflict {
+		t.Errorf("Onboard() of an existing CNPJ status = %d, want %d", status, fiber.StatusConflict)
+	}
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Paginação padrão das listagens
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+// parsePagination lê page e limit da query string. Valores ausentes usam o padrão,
+// limit acima do máximo é reduzido a maxPageLimit, e valores não numéricos ou
+// menores que 1 retornam erro (para responder 400 em vez de assumir o padrão).
+func parsePagination(c *fiber.Ctx) (page, limit int, err error) {
+	page, err = parsePositiveQuery(c, "page", 1)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	limit, err = parsePositiveQuery(c, "limit", defaultPageLimit)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if limit > maxPageLimit {
+		limit = maxPageLimit
+	}
+
+	return page, limit, nil
+}
+
+// parsePositiveQuery lê um inteiro positivo da query string
+func parsePositiveQuery(c *fiber.Ctx, key string, defaultValue int) (int, error) {
+	raw := c.Query(key)
+	if raw == "" {
+		return defaultValue, nil
+	}
+
+	value, err := strconv.Atoi(raw)
+	if err != nil || value < 1 {
+		return 0, fmt.Errorf("query parameter '%s' must be a positive integer", key)
+	}
+
+	return value, nil
+}
+
+// paginationError responde 400 para parâmetros de paginação inválidos
+func paginationError(c *fiber.Ctx, err error) error {
+	return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+		"error": err.Error(),
+	})
+}
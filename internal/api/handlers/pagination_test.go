@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/zoomxml/internal/api/middleware"
+	"github.com/zoomxml/internal/database/databasetest"
+	"github.com/zoomxml/internal/models"
+)
+
+func TestParsePagination(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		wantPage  int
+		wantLimit int
+		wantErr   bool
+	}{
+		{"defaults", "", 1, defaultPageLimit, false},
+		{"explicit", "page=3&limit=50", 3, 50, false},
+		{"limit above the maximum is reduced", "limit=500", 1, maxPageLimit, false},
+		{"non-numeric page", "page=abc", 0, 0, true},
+		{"negative limit", "limit=-5", 0, 0, true},
+		{"zero page", "page=0", 0, 0, true},
+		{"non-numeric limit", "limit=ten", 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			app.Get("/", func(c *fiber.Ctx) error {
+				page, limit, err := parsePagination(c)
+				if (err != nil) != tt.wantErr {
+					t.Errorf("parsePagination() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				if page != tt.wantPage || limit != tt.wantLimit {
+					t.Errorf("parsePagination() = %d, %d, want %d, %d", page, limit, tt.wantPage, tt.wantLimit)
+				}
+				return nil
+			})
+			if _, err := app.Test(httptest.NewRequest("GET", "/?"+tt.query, nil)); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+// TestListPaginationBadRequest checks that the list endpoints answer 400 to invalid
+// pagination before querying the database
+func TestListPaginationBadRequest(t *testing.T) {
+	databasetest.UseClosed(t)
+	admin := &models.User{ID: 1, Role: "admin", Active: true}
+
+	for _, query := range []string{"page=abc", "limit=-5"} {
+		t.Run(query, func(t *testing.T) {
+			app := fiber.New()
+			app.Get("/companies", func(c *fiber.Ctx) error {
+				c.Locals(string(middleware.UserKey), admin)
+				return c.Next()
+			}, NewCompanyHandler().GetCompanies)
+
+			resp, err := app.Test(httptest.NewRequest("GET", "/companies?"+query, nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != fiber.StatusBadRequest {
+				t.Errorf("GetCompanies(%s) status = %d, want %d", query, resp.StatusCode, fiber.StatusBadRequest)
+			}
+		})
+	}
+}
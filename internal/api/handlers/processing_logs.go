@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/uptrace/bun"
+	"github.com/zoomxml/internal/api/middleware"
+	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/logger"
+	"github.com/zoomxml/internal/models"
+)
+
+// ProcessingLogHandler gerencia as rotas de logs de processamento
+type ProcessingLogHandler struct{}
+
+// NewProcessingLogHandler cria uma nova instância do handler de logs de processamento
+func NewProcessingLogHandler() *ProcessingLogHandler {
+	return &ProcessingLogHandler{}
+}
+
+// GetProcessingLogs lists the processing logs of a company
+// @Summary List processing logs
+// @Description Lists ingest batch processing logs for a company, optionally filtered by batch
+// @Tags processing-logs
+// @Accept json
+// @Produce json
+// @Param company_id path int true "Company ID"
+// @Param batch_id query string false "Batch ID"
+// @Param status query string false "Status (success, partial, failed)"
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page, at most 100 (larger values are reduced to 100)" default(20)
+// @Success 200 {object} fiber.Map
+// @Failure 400 {object} fiber.Map
+// @Failure 401 {object} fiber.Map
+// @Failure 403 {object} fiber.Map
+// @Failure 404 {object} fiber.Map
+// @Failure 500 {object} fiber.Map
+// @Router /api/companies/{company_id}/processing-logs [get]
+func (h *ProcessingLogHandler) GetProcessingLogs(c *fiber.Ctx) error {
+	// Company resolved (and access checked) by CompanyMiddleware
+	companyID := middleware.GetCompanyFromContext(c).ID
+
+	// Get user from context
+	user := middleware.GetUserFromContext(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Authentication required",
+		})
+	}
+
+	// Parse pagination parameters
+	page, limit, err := parsePagination(c)
+	if err != nil {
+		return paginationError(c, err)
+	}
+	offset := (page - 1) * limit
+
+	batchID := c.Query("batch_id")
+	status := c.Query("status")
+	filter := func(q *bun.SelectQuery) *bun.SelectQuery {
+		q = q.Where("company_id = ?", companyID)
+		if batchID != "" {
+			q = q.Where("batch_id = ?", batchID)
+		}
+		if status != "" {
+			q = q.Where("status = ?", status)
+		}
+		return q
+	}
+
+	logs := []models.ProcessingLog{}
+	total, err := database.DB.NewSelect().
+		Model(&logs).
+		Apply(filter).
+		Order("created_at DESC").
+		Limit(limit).
+		Offset(offset).
+		ScanAndCount(c.Context())
+
+	if err != nil {
+		logger.ErrorWithFields("Failed to fetch processing logs", err, map[string]any{
+			"operation":  "get_processing_logs",
+			"company_id": companyID,
+			"user_id":    user.ID,
+		})
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch processing logs",
+		})
+	}
+
+	return respondList(c, "logs", logs, page, limit, total)
+}
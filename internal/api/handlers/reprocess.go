@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/zoomxml/internal/api/middleware"
+	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/logger"
+	"github.com/zoomxml/internal/models"
+	"github.com/zoomxml/internal/services"
+)
+
+// ReprocessStatusResponse é o progresso de um reprocessamento de empresa
+type ReprocessStatusResponse struct {
+	*models.ReprocessBatch
+	Remaining int     `json:"remaining"`
+	Percent   float64 `json:"percent"`
+}
+
+// newReprocessStatusResponse calcula o progresso de um lote de reprocessamento
+func newReprocessStatusResponse(batch *models.ReprocessBatch) ReprocessStatusResponse {
+	response := ReprocessStatusResponse{ReprocessBatch: batch, Percent: 100}
+	if batch.Status != models.ReprocessStatusCompleted {
+		response.Remaining = max(batch.Total-batch.Processed, 0)
+		if batch.Total > 0 {
+			response.Percent = float64(batch.Processed) * 100 / float64(batch.Total)
+		}
+	}
+	return response
+}
+
+// ReprocessCompany inicia o reprocessamento de todos os documentos de uma empresa (apenas admin)
+// @Summary Reprocessar documentos da empresa
+// @Description Relê o XML armazenado de todos os documentos NFSe da empresa com o parser atual, em lotes, em segundo plano. Se já houver um reprocessamento em andamento, ele é retornado em vez de criar outro.
+// @Tags companies
+// @Produce json
+// @Param id path int true "ID da empresa"
+// @Success 202 {object} ReprocessStatusResponse "Reprocessamento criado"
+// @Success 200 {object} ReprocessStatusResponse "Reprocessamento já em andamento"
+// @Failure 400 {object} SwaggerError "ID inválido"
+// @Failure 401 {object} SwaggerError "Autenticação necessária"
+// @Failure 403 {object} SwaggerError "Apenas administradores"
+// @Failure 404 {object} SwaggerError "Empresa não encontrada"
+// @Failure 500 {object} SwaggerError "Erro interno"
+// @Security UserToken
+// @Router /companies/{id}/reprocess [post]
+func (h *CompanyHandler) ReprocessCompany(c *fiber.Ctx) error {
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid company ID",
+		})
+	}
+
+	user := middleware.GetUserFromContext(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Authentication required",
+		})
+	}
+
+	exists, err := database.DB.NewSelect().
+		Model((*models.Company)(nil)).
+		Where("id = ?", id).
+		Exists(c.Context())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load company",
+		})
+	}
+	if !exists {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Company not found",
+		})
+	}
+
+	batch, created, err := services.StartReprocess(c.Context(), id, user.ID)
+	if err != nil {
+		logger.ErrorWithFields("Failed to start company reprocess", err, map[string]any{
+			"operation":  "reprocess_company",
+			"company_id": id,
+			"user_id":    user.ID,
+		})
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to start reprocess",
+		})
+	}
+
+	if !created {
+		return respondData(c, fiber.StatusOK, newReprocessStatusResponse(batch))
+	}
+
+	recordAudit(c, user, "CREATE", "ReprocessBatch", batch.ID, map[string]any{
+		"company_id": id,
+		"total":      batch.Total,
+	})
+
+	return respondData(c, fiber.StatusAccepted, newReprocessStatusResponse(batch))
+}
+
+// GetReprocessStatus obtém o progresso de um reprocessamento (apenas admin)
+// @Summary Progresso do reprocessamento
+// @Description Retorna os documentos processados, com falha e restantes de um reprocessamento da empresa
+// @Tags companies
+// @Produce json
+// @Param id path int true "ID da empresa"
+// @Param batch_id path int true "ID do reprocessamento"
+// @Success 200 {object} ReprocessStatusResponse
+// @Failure 400 {object} SwaggerError "ID inválido"
+// @Failure 401 {object} SwaggerError "Autenticação necessária"
+// @Failure 403 {object} SwaggerError "Apenas administradores"
+// @Failure 404 {object} SwaggerError "Reprocessamento não encontrado"
+// @Failure 500 {object} SwaggerError "Erro interno"
+// @Security UserToken
+// @Router /companies/{id}/reprocess/{batch_id} [get]
+func (h *CompanyHandler) GetReprocessStatus(c *fiber.Ctx) error {
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid company ID",
+		})
+	}
+
+	batchID, err := strconv.ParseInt(c.Params("batch_id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid batch ID",
+		})
+	}
+
+	batch, err := services.GetReprocessBatch(c.Context(), id, batchID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Reprocess batch not found",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load reprocess batch",
+		})
+	}
+
+	return respondData(c, fiber.StatusOK, newReprocessStatusResponse(batch))
+}
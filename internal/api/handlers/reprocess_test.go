@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/zoomxml/internal/models"
+)
+
+func TestNewReprocessStatusResponse(t *testing.T) {
+	tests := []struct {
+		name          string
+		batch         models.ReprocessBatch
+		wantRemaining int
+		wantPercent   float64
+	}{
+		{"pending", models.ReprocessBatch{Status: models.ReprocessStatusPending, Total: 200}, 200, 0},
+		{"running", models.ReprocessBatch{Status: models.ReprocessStatusRunning, Total: 200, Processed: 50}, 150, 25},
+		{"documents added while running", models.ReprocessBatch{Status: models.ReprocessStatusRunning, Total: 200, Processed: 210}, 0, 105},
+		{"empty company has nothing left", models.ReprocessBatch{Status: models.ReprocessStatusPending}, 0, 100},
+		{"completed", models.ReprocessBatch{Status: models.ReprocessStatusCompleted, Total: 200, Processed: 200}, 0, 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := newReprocessStatusResponse(&tt.batch)
+			if got.Remaining != tt.wantRemaining || got.Percent != tt.wantPercent {
+				t.Errorf("newReprocessStatusResponse() = %d remaining, %.1f%%, want %d, %.1f%%",
+					got.Remaining, got.Percent, tt.wantRemaining, tt.wantPercent)
+			}
+		})
+	}
+}
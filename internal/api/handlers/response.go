@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/zoomxml/config"
+	"github.com/zoomxml/internal/models"
+)
+
+// respondList envia uma listagem paginada. Com API_RESPONSE_ENVELOPE habilitado usa
+// models.APIResponse com a paginação em meta; caso contrário mantém o formato
+// legado {"<key>": items, "pagination": {...}}
+func respondList(c *fiber.Ctx, key string, items any, page, limit, total int) error {
+	if config.Get().Server.ResponseEnvelope {
+		return c.Status(fiber.StatusOK).JSON(models.APIResponse{
+			Success: true,
+			Data:    items,
+			Meta: &models.MetaData{
+				Page:  page,
+				Limit: limit,
+				Total: total,
+			},
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		key: items,
+		"pagination": fiber.Map{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+		},
+	})
+}
+
+// respondData envia o recurso (detalhe ou listagem sem paginação) com o status
+// informado, dentro de models.APIResponse quando o envelope está habilitado
+func respondData(c *fiber.Ctx, status int, data any) error {
+	if config.Get().Server.ResponseEnvelope {
+		return c.Status(status).JSON(models.APIResponse{
+			Success: true,
+			Data:    data,
+		})
+	}
+
+	return c.Status(status).JSON(data)
+}
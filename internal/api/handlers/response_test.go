@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/zoomxml/config"
+	"github.com/zoomxml/internal/database/databasetest"
+	"github.com/zoomxml/internal/models"
+)
+
+// useResponseEnvelope sets API_RESPONSE_ENVELOPE for the test
+func useResponseEnvelope(t *testing.T, enabled bool) {
+	t.Helper()
+	cfg := config.Get()
+	previous := cfg.Server.ResponseEnvelope
+	cfg.Server.ResponseEnvelope = enabled
+	t.Cleanup(func() { cfg.Server.ResponseEnvelope = previous })
+}
+
+// responseKeys returns the sorted top-level keys of a JSON object response
+func responseKeys(t *testing.T, app *fiber.App, path string) (int, []string, map[string]json.RawMessage) {
+	t.Helper()
+	resp, err := app.Test(httptest.NewRequest("GET", path, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body := map[string]json.RawMessage{}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("response is not a JSON object: %v", err)
+	}
+	return resp.StatusCode, slices.Sorted(maps.Keys(body)), body
+}
+
+func TestResponseEnvelope(t *testing.T) {
+	user := &models.User{ID: 7, Email: "user@example.com"}
+
+	tests := []struct {
+		name     string
+		envelope bool
+		path     string
+		wantKeys []string // top-level keys that must be present
+	}{
+		{"list without envelope", false, "/list", []string{"items", "pagination"}},
+		{"list with envelope", true, "/list", []string{"data", "meta", "success"}},
+		{"detail without envelope", false, "/profile", []string{"email", "id"}},
+		{"detail with envelope", true, "/profile", []string{"data", "success"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useResponseEnvelope(t, tt.envelope)
+
+			app := fiber.New()
+			app.Use(func(c *fiber.Ctx) error {
+				c.Locals("user", user)
+				return c.Next()
+			})
+			app.Get("/list", func(c *fiber.Ctx) error {
+				return respondList(c, "items", []string{"a", "b"}, 2, 10, 12)
+			})
+			app.Get("/profile", NewAuthHandler().GetProfile)
+
+			status, keys, body := responseKeys(t, app, tt.path)
+			if status != fiber.StatusOK {
+				t.Fatalf("status = %d, want %d", status, fiber.StatusOK)
+			}
+			for _, key := range tt.wantKeys {
+				if _, ok := body[key]; !ok {
+					t.Errorf("key %q missing from %v", key, keys)
+				}
+			}
+			if tt.envelope && (!slices.Equal(keys, tt.wantKeys) || string(body["success"]) != "true") {
+				t.Errorf("envelope = %v (success %s), want exactly %v with success true", keys, body["success"], tt.wantKeys)
+			}
+			if _, ok := body["success"]; !tt.envelope && ok {
+				t.Errorf("keys = %v, want no envelope", keys)
+			}
+
+			if tt.path == "/list" {
+				var meta models.MetaData
+				raw := body["pagination"]
+				if tt.envelope {
+					raw = body["meta"]
+				}
+				if err := json.Unmarshal(raw, &meta); err != nil || meta != (models.MetaData{Page: 2, Limit: 10, Total: 12}) {
+					t.Errorf("pagination = %s, want page 2, limit 10, total 12", raw)
+				}
+			}
+		})
+	}
+}
+
+func TestResponseEnvelopeAcrossEndpoints(t *testing.T) {
+	databasetest.Require(t)
+	company := databasetest.CreateCompany(t, nil)
+	databasetest.CreateDocument(t, &models.Document{CompanyID: company.ID, Number: "1"})
+	user := &models.User{ID: 1}
+
+	endpoints := []struct {
+		path      string
+		handler   fiber.Handler
+		legacyKey string // key of the items without the envelope; "" for a bare array
+	}{
+		{"/documents", NewNFSeHandler().GetNFSeDocuments, "documents"},
+		{"/processing-logs", NewProcessingLogHandler().GetProcessingLogs, "logs"},
+		{"/credentials", NewCredentialHandler().GetCredentials, ""},
+	}
+
+	for _, envelope := range []bool{false, true} {
+		for _, endpoint := range endpoints {
+			t.Run(fmt.Sprintf("%s envelope=%v", endpoint.path, envelope), func(t *testing.T) {
+				useResponseEnvelope(t, envelope)
+				app := companyApp(user, company, fiber.MethodGet, endpoint.path, endpoint.handler)
+
+				if !envelope && endpoint.legacyKey == "" {
+					resp, err := app.Test(httptest.NewRequest("GET", endpoint.path, nil))
+					if err != nil {
+						t.Fatal(err)
+					}
+					defer resp.Body.Close()
+					var items []json.RawMessage
+					if err := json.NewDecoder(resp.Body).Decode(&items); err != nil {
+						t.Errorf("response is not a bare array: %v", err)
+					}
+					return
+				}
+
+				status, keys, _ := responseKeys(t, app, endpoint.path)
+				if status != fiber.StatusOK {
+					t.Fatalf("status = %d, want %d", status, fiber.StatusOK)
+				}
+				want := []string{endpoint.legacyKey, "pagination"}
+				if envelope {
+					want = []string{"data", "meta", "success"}
+					if endpoint.legacyKey == "" {
+						want = []string{"data", "success"}
+					}
+				}
+				slices.Sort(want)
+				if !slices.Equal(keys, want) {
+					t.Errorf("keys = %v, want %v", keys, want)
+				}
+			})
+		}
+	}
+}
+
+func TestResponseEnvelopeOnDetailEndpoints(t *testing.T) {
+	databasetest.Require(t)
+	company := databasetest.CreateCompany(t, nil)
+	user := &models.User{ID: 1}
+
+	endpoints := []struct {
+		path    string
+		handler fiber.Handler
+	}{
+		{"/gaps?competencia=2024-01", NewNFSeHandler().GetNFSeNumberingGaps},
+		{"/duplicates/stats", NewNFSeHandler().GetNFSeDuplicateStatistics},
+	}
+
+	for _, endpoint := range endpoints {
+		t.Run(endpoint.path, func(t *testing.T) {
+			useResponseEnvelope(t, true)
+			route, _, _ := strings.Cut(endpoint.path, "?")
+			app := companyApp(user, company, fiber.MethodGet, route, endpoint.handler)
+
+			status, keys, body := responseKeys(t, app, endpoint.path)
+			if status != fiber.StatusOK {
+				t.Fatalf("status = %d, want %d", status, fiber.StatusOK)
+			}
+			if !slices.Equal(keys, []string{"data", "success"}) || string(body["success"]) != "true" {
+				t.Errorf("keys = %v (success %s), want data and success true", keys, body["success"])
+			}
+		})
+	}
+}
@@ -0,0 +1,261 @@
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/zoomxml/internal/api/middleware"
+	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/models"
+	"github.com/zoomxml/internal/permissions"
+)
+
+// RetentionPolicyRequest representa a atualização da política de retenção de uma empresa
+type RetentionPolicyRequest struct {
+	RetentionMonths *int  `json:"retention_months,omitempty" validate:"omitempty,min=0,max=1200"` // 0 = sem limite
+	LegalHold       *bool `json:"legal_hold,omitempty"`
+}
+
+// RetentionPolicyResponse é a política de retenção de uma empresa
+type RetentionPolicyResponse struct {
+	CompanyID       int64 `json:"company_id"`
+	RetentionMonths int   `json:"retention_months"`
+	LegalHold       bool  `json:"legal_hold"`
+	HeldDocuments   int   `json:"held_documents"` // Documentos com retenção legal individual
+}
+
+// LegalHoldRequest representa a marcação de retenção legal de um documento
+type LegalHoldRequest struct {
+	LegalHold bool `json:"legal_hold"`
+}
+
+// GetRetentionPolicy obtém a política de retenção de uma empresa
+// @Summary Obter política de retenção
+// @Description Retorna o período de retenção dos documentos e a retenção legal da empresa
+// @Tags companies
+// @Produce json
+// @Param id path int true "ID da empresa"
+// @Success 200 {object} RetentionPolicyResponse
+// @Failure 400 {object} SwaggerError "ID inválido"
+// @Failure 401 {object} SwaggerError "Autenticação necessária"
+// @Failure 403 {object} SwaggerError "Acesso negado"
+// @Failure 404 {object} SwaggerError "Empresa não encontrada"
+// @Failure 500 {object} SwaggerError "Erro interno"
+// @Security UserToken
+// @Router /companies/{id}/retention [get]
+func (h *CompanyHandler) GetRetentionPolicy(c *fiber.Ctx) error {
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid company ID",
+		})
+	}
+
+	user := middleware.GetUserFromContext(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Authentication required",
+		})
+	}
+
+	// Verificar acesso à empresa
+	err = permissions.CanAccessCompany(c.Context(), user, id)
+	if err != nil {
+		if err == permissions.ErrCompanyNotFound {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Company not found",
+			})
+		}
+		if err == permissions.ErrAccessDenied {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "Access denied to this company",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to validate permissions",
+		})
+	}
+
+	return h.respondRetentionPolicy(c, id)
+}
+
+// UpdateRetentionPolicy atualiza a política de retenção de uma empresa (apenas admin)
+// @Summary Atualizar política de retenção
+// @Description Define o período de retenção em meses (0 = sem limite) e a retenção legal da empresa. Documentos além do período são excluídos logicamente pela rotina de retenção.
+// @Tags companies
+// @Accept json
+// @Produce json
+// @Param id path int true "ID da empresa"
+// @Param policy body RetentionPolicyRequest true "Política de retenção"
+// @Success 200 {object} RetentionPolicyResponse
+// @Failure 400 {object} SwaggerValidationError "Erro de validação"
+// @Failure 401 {object} SwaggerError "Autenticação necessária"
+// @Failure 403 {object} SwaggerError "Apenas administradores"
+// @Failure 404 {object} SwaggerError "Empresa não encontrada"
+// @Failure 500 {object} SwaggerError "Erro interno"
+// @Security UserToken
+// @Router /companies/{id}/retention [put]
+func (h *CompanyHandler) UpdateRetentionPolicy(c *fiber.Ctx) error {
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid company ID",
+		})
+	}
+
+	var req RetentionPolicyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := validateStruct(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": err,
+		})
+	}
+
+	if req.RetentionMonths == nil && req.LegalHold == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Nothing to update",
+		})
+	}
+
+	query := database.DB.NewUpdate().
+		Model((*models.Company)(nil)).
+		Set("updated_at = current_timestamp").
+		Where("id = ?", id)
+
+	if req.RetentionMonths != nil {
+		query = query.Set("retention_months = ?", *req.RetentionMonths)
+	}
+	if req.LegalHold != nil {
+		query = query.Set("legal_hold = ?", *req.LegalHold)
+	}
+
+	res, err := query.Exec(c.Context())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update retention policy",
+		})
+	}
+
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Company not found",
+		})
+	}
+
+	recordAudit(c, middleware.GetUserFromContext(c), "UPDATE", "Company", id, map[string]any{
+		"retention_months": req.RetentionMonths,
+		"legal_hold":       req.LegalHold,
+	})
+
+	return h.respondRetentionPolicy(c, id)
+}
+
+// respondRetentionPolicy responde com a política de retenção atual da empresa
+func (h *CompanyHandler) respondRetentionPolicy(c *fiber.Ctx, companyID int64) error {
+	company := &models.Company{}
+	err := database.DB.NewSelect().
+		Model(company).
+		Column("id", "retention_months", "legal_hold").
+		Where("id = ?", companyID).
+		Scan(c.Context())
+
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Company not found",
+		})
+	}
+
+	held, err := database.DB.NewSelect().
+		Model((*models.Document)(nil)).
+		Where("company_id = ? AND legal_hold = true", companyID).
+		Count(c.Context())
+
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to count held documents",
+		})
+	}
+
+	return respondData(c, fiber.StatusOK, RetentionPolicyResponse{
+		CompanyID:       company.ID,
+		RetentionMonths: company.RetentionMonths,
+		LegalHold:       company.LegalHold,
+		HeldDocuments:   held,
+	})
+}
+
+// SetNFSeDocumentLegalHold marks or unmarks a document as under legal hold (admin only)
+// @Summary Set NFSe legal hold
+// @Description Documents under legal hold are never expired by the retention policy
+// @Tags nfse
+// @Accept json
+// @Produce json
+// @Param company_id path int true "Company ID"
+// @Param document_id path int true "Document ID"
+// @Param request body LegalHoldRequest true "Legal hold"
+// @Success 200 {object} fiber.Map
+// @Failure 400 {object} fiber.Map
+// @Failure 401 {object} fiber.Map
+// @Failure 403 {object} fiber.Map
+// @Failure 404 {object} fiber.Map
+// @Failure 500 {object} fiber.Map
+// @Router /api/companies/{company_id}/nfse/{document_id}/legal-hold [put]
+func (h *NFSeHandler) SetNFSeDocumentLegalHold(c *fiber.Ctx) error {
+	// Company resolved (and access checked) by CompanyMiddleware
+	companyID := middleware.GetCompanyFromContext(c).ID
+
+	documentID, err := strconv.ParseInt(c.Params("document_id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid document ID",
+		})
+	}
+
+	user := middleware.GetUserFromContext(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Authentication required",
+		})
+	}
+
+	var req LegalHoldRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	res, err := database.DB.NewUpdate().
+		Model((*models.Document)(nil)).
+		Set("legal_hold = ?", req.LegalHold).
+		Where("id = ? AND company_id = ?", documentID, companyID).
+		Exec(c.Context())
+
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update legal hold",
+		})
+	}
+
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Document not found",
+		})
+	}
+
+	recordAudit(c, user, "UPDATE", "Document", documentID, map[string]any{
+		"company_id": companyID,
+		"legal_hold": req.LegalHold,
+	})
+
+	return respondData(c, fiber.StatusOK, fiber.Map{
+		"document_id": documentID,
+		"legal_hold":  req.LegalHold,
+	})
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/zoomxml/internal/database"
 	"github.com/zoomxml/internal/models"
+	"github.com/zoomxml/internal/services"
 )
 
 // StatsHandler gerencia as rotas de estatísticas
@@ -153,7 +154,7 @@ func (h *StatsHandler) GetDashboardStats(c *fiber.Ctx) error {
 		}
 	}
 
-	return c.JSON(stats)
+	return respondData(c, fiber.StatusOK, stats)
 }
 
 // GetCompanyStats retorna estatísticas de uma empresa específica
@@ -230,5 +231,21 @@ func (h *StatsHandler) GetCompanyStats(c *fiber.Ctx) error {
 		}
 	}
 
-	return c.JSON(stats)
+	return respondData(c, fiber.StatusOK, stats)
+}
+
+// GetProviderStatus retorna o estado do circuit breaker de cada provedor NFSe
+// @Summary Estado dos provedores NFSe
+// @Description Retorna o estado do circuit breaker (closed, open, half_open) de cada provedor já consultado (apenas admin)
+// @Tags stats
+// @Produce json
+// @Success 200 {array} services.CircuitBreakerState "Estado dos provedores"
+// @Failure 401 {object} SwaggerError "Token inválido"
+// @Failure 403 {object} SwaggerError "Apenas administradores"
+// @Security BearerAuth
+// @Router /stats/providers [get]
+func (h *StatsHandler) GetProviderStatus(c *fiber.Ctx) error {
+	return respondData(c, fiber.StatusOK, fiber.Map{
+		"providers": services.ProviderBreakerStates(),
+	})
 }
@@ -0,0 +1,224 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/zoomxml/config"
+	"github.com/zoomxml/internal/api/middleware"
+	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/logger"
+	"github.com/zoomxml/internal/models"
+	"github.com/zoomxml/internal/permissions"
+	"github.com/zoomxml/internal/services"
+)
+
+// SyncScheduleRequest representa o agendamento da busca automática de uma empresa.
+// Campos vazios removem a configuração; sem cron nem janela, vale o intervalo global.
+type SyncScheduleRequest struct {
+	Cron        string `json:"cron"`         // Expressão cron de 5 campos, ex.: "0 3 * * *"
+	WindowStart string `json:"window_start"` // Início da janela diária (HH:MM)
+	WindowEnd   string `json:"window_end"`   // Fim da janela diária (HH:MM, exclusivo)
+}
+
+// SyncScheduleResponse é o agendamento da busca automática de uma empresa
+type SyncScheduleResponse struct {
+	CompanyID      int64      `json:"company_id"`
+	AutoFetch      bool       `json:"auto_fetch"`
+	Cron           string     `json:"cron,omitempty"`
+	WindowStart    string     `json:"window_start,omitempty"`
+	WindowEnd      string     `json:"window_end,omitempty"`
+	GlobalInterval string     `json:"global_interval"`        // Intervalo do agendador; a busca ocorre no primeiro ciclo após o horário previsto
+	NextSyncAt     *time.Time `json:"next_sync_at,omitempty"` // Próximo horário previsto (ausente sem auto_fetch)
+}
+
+// GetSyncSchedule obtém o agendamento da busca automática de uma empresa
+// @Summary Obter agendamento de sincronização
+// @Description Retorna a expressão cron e a janela diária da busca automática da empresa e o próximo horário previsto
+// @Tags companies
+// @Produce json
+// @Param id path int true "ID da empresa"
+// @Success 200 {object} SyncScheduleResponse
+// @Failure 400 {object} SwaggerError "ID inválido"
+// @Failure 401 {object} SwaggerError "Autenticação necessária"
+// @Failure 403 {object} SwaggerError "Acesso negado"
+// @Failure 404 {object} SwaggerError "Empresa não encontrada"
+// @Failure 500 {object} SwaggerError "Erro interno"
+// @Security UserToken
+// @Router /companies/{id}/sync-schedule [get]
+func (h *CompanyHandler) GetSyncSchedule(c *fiber.Ctx) error {
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid company ID",
+		})
+	}
+
+	user := middleware.GetUserFromContext(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Authentication required",
+		})
+	}
+
+	// Verificar acesso à empresa
+	err = permissions.CanAccessCompany(c.Context(), user, id)
+	if err != nil {
+		if err == permissions.ErrCompanyNotFound {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Company not found",
+			})
+		}
+		if err == permissions.ErrAccessDenied {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "Access denied to this company",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to validate permissions",
+		})
+	}
+
+	return h.respondSyncSchedule(c, id)
+}
+
+// UpdateSyncSchedule define o agendamento da busca automática de uma empresa (admin ou membro)
+// @Summary Atualizar agendamento de sincronização
+// @Description Define uma expressão cron (minuto hora dia mês dia-da-semana) e/ou uma janela diária (HH:MM, horário do servidor) para a busca automática da empresa. Com cron, a empresa é buscada quando um horário previsto passou desde a última busca agendada; com janela, só dentro dela. O agendador verifica as empresas a cada NFSE_SCHEDULER_INTERVAL, então o intervalo global limita a precisão. Campos vazios removem a configuração.
+// @Tags companies
+// @Accept json
+// @Produce json
+// @Param id path int true "ID da empresa"
+// @Param schedule body SyncScheduleRequest true "Agendamento"
+// @Success 200 {object} SyncScheduleResponse
+// @Failure 400 {object} SwaggerValidationError "Erro de validação"
+// @Failure 401 {object} SwaggerError "Autenticação necessária"
+// @Failure 403 {object} SwaggerError "Apenas administradores e membros"
+// @Failure 404 {object} SwaggerError "Empresa não encontrada"
+// @Failure 500 {object} SwaggerError "Erro interno"
+// @Security UserToken
+// @Router /companies/{id}/sync-schedule [put]
+func (h *CompanyHandler) UpdateSyncSchedule(c *fiber.Ctx) error {
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid company ID",
+		})
+	}
+
+	user := middleware.GetUserFromContext(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Authentication required",
+		})
+	}
+
+	var req SyncScheduleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	req.Cron = strings.Join(strings.Fields(req.Cron), " ")
+	req.WindowStart = strings.TrimSpace(req.WindowStart)
+	req.WindowEnd = strings.TrimSpace(req.WindowEnd)
+
+	if err := services.ValidateSyncSchedule(req.Cron, req.WindowStart, req.WindowEnd); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": err.Error(),
+		})
+	}
+
+	// Verificar acesso à empresa
+	err = permissions.CanAccessCompany(c.Context(), user, id)
+	if err != nil {
+		if err == permissions.ErrCompanyNotFound {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Company not found",
+			})
+		}
+		if err == permissions.ErrAccessDenied {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "Access denied to this company",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to validate permissions",
+		})
+	}
+
+	// Empresas não restritas são visíveis a todos, mas só admins e membros alteram a sincronização
+	denied, err := permissions.DeniedCompanyFields(c.Context(), user, id, []string{"sync_cron", "sync_window_start", "sync_window_end"})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to validate permissions",
+		})
+	}
+	if len(denied) > 0 {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Only admins and company members can change the sync schedule",
+		})
+	}
+
+	if err := services.UpdateCompanySyncSchedule(c.Context(), id, req.Cron, req.WindowStart, req.WindowEnd); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Company not found",
+			})
+		}
+		logger.ErrorWithFields("Failed to update sync schedule", err, map[string]any{
+			"operation":  "update_sync_schedule",
+			"company_id": id,
+			"user_id":    user.ID,
+		})
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update sync schedule",
+		})
+	}
+
+	recordAudit(c, user, "UPDATE", "Company", id, map[string]any{
+		"sync_cron":         req.Cron,
+		"sync_window_start": req.WindowStart,
+		"sync_window_end":   req.WindowEnd,
+	})
+
+	return h.respondSyncSchedule(c, id)
+}
+
+// respondSyncSchedule responde com o agendamento atual da empresa
+func (h *CompanyHandler) respondSyncSchedule(c *fiber.Ctx, companyID int64) error {
+	company := &models.Company{}
+	err := database.DB.NewSelect().
+		Model(company).
+		Column("id", "auto_fetch", "sync_cron", "sync_window_start", "sync_window_end", "last_scheduled_fetch_at").
+		Where("id = ?", companyID).
+		Scan(c.Context())
+
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Company not found",
+		})
+	}
+
+	response := SyncScheduleResponse{
+		CompanyID:      company.ID,
+		AutoFetch:      company.AutoFetch,
+		Cron:           company.SyncCron,
+		WindowStart:    company.SyncWindowStart,
+		WindowEnd:      company.SyncWindowEnd,
+		GlobalInterval: config.Get().NFSeScheduler.Interval,
+	}
+	if company.AutoFetch {
+		if next := services.NextCompanySync(company, time.Now()); !next.IsZero() {
+			response.NextSyncAt = &next
+		}
+	}
+
+	return respondData(c, fiber.StatusOK, response)
+}
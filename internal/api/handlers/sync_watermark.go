@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/zoomxml/internal/api/middleware"
+	"github.com/zoomxml/internal/logger"
+	"github.com/zoomxml/internal/permissions"
+	"github.com/zoomxml/internal/services"
+)
+
+// ResetWatermarkResponse confirma a redefinição da marca de sincronização
+type ResetWatermarkResponse struct {
+	CompanyID     int64  `json:"company_id"`
+	ResyncPending bool   `json:"resync_pending"`
+	Message       string `json:"message"`
+}
+
+// ResetSyncWatermark redefine a marca de sincronização de uma empresa (admin ou membro)
+// @Summary Redefinir marca de sincronização
+// @Description Limpa a última sincronização da empresa e faz a próxima busca "desde o último documento" refazer a janela de carga inicial (NFSE_FETCH_DAYS_BACK). Documentos já armazenados são descartados pela deduplicação.
+// @Tags companies
+// @Produce json
+// @Param id path int true "ID da empresa"
+// @Success 200 {object} ResetWatermarkResponse
+// @Failure 400 {object} SwaggerError "ID inválido"
+// @Failure 401 {object} SwaggerError "Autenticação necessária"
+// @Failure 403 {object} SwaggerError "Apenas administradores e membros"
+// @Failure 404 {object} SwaggerError "Empresa não encontrada"
+// @Failure 500 {object} SwaggerError "Erro interno"
+// @Security UserToken
+// @Router /companies/{id}/reset-watermark [post]
+func (h *CompanyHandler) ResetSyncWatermark(c *fiber.Ctx) error {
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid company ID",
+		})
+	}
+
+	user := middleware.GetUserFromContext(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Authentication required",
+		})
+	}
+
+	// Verificar acesso à empresa
+	err = permissions.CanAccessCompany(c.Context(), user, id)
+	if err != nil {
+		if err == permissions.ErrCompanyNotFound {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Company not found",
+			})
+		}
+		if err == permissions.ErrAccessDenied {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "Access denied to this company",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to validate permissions",
+		})
+	}
+
+	// Empresas não restritas são visíveis a todos, mas só admins e membros alteram a sincronização
+	denied, err := permissions.DeniedCompanyFields(c.Context(), user, id, []string{"last_sync_at"})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to validate permissions",
+		})
+	}
+	if len(denied) > 0 {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Only admins and company members can reset the sync watermark",
+		})
+	}
+
+	if err := services.ResetSyncWatermark(c.Context(), id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Company not found",
+			})
+		}
+		logger.ErrorWithFields("Failed to reset sync watermark", err, map[string]any{
+			"operation":  "reset_sync_watermark",
+			"company_id": id,
+			"user_id":    user.ID,
+		})
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to reset sync watermark",
+		})
+	}
+
+	recordAudit(c, user, "UPDATE", "Company", id, map[string]any{
+		"action": "reset_sync_watermark",
+	})
+
+	return respondData(c, fiber.StatusOK, ResetWatermarkResponse{
+		CompanyID:     id,
+		ResyncPending: true,
+		Message:       "Sync watermark reset; the next fetch since the last document backfills from scratch",
+	})
+}
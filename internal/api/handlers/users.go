@@ -142,7 +142,7 @@ func (h *UserHandler) CreateUser(c *fiber.Ctx) error {
 		"updated_at": user.UpdatedAt,
 	}
 
-	return c.Status(fiber.StatusCreated).JSON(response)
+	return respondData(c, fiber.StatusCreated, response)
 }
 
 // GetUsers lista todos os usuários (apenas admin)
@@ -153,7 +153,7 @@ func (h *UserHandler) CreateUser(c *fiber.Ctx) error {
 // @Param role query string false "Filtrar por papel (admin/user)"
 // @Param active query string false "Filtrar por status (true/false)"
 // @Param page query int false "Página (padrão: 1)"
-// @Param limit query int false "Itens por página (padrão: 20)"
+// @Param limit query int false "Itens por página (padrão: 20; valores acima de 100 são reduzidos a 100)"
 // @Success 200 {object} SwaggerUsersResponse "Lista de usuários com paginação"
 // @Failure 401 {object} SwaggerError "Token de admin necessário"
 // @Failure 500 {object} SwaggerError "Erro interno"
@@ -179,13 +179,15 @@ func (h *UserHandler) GetUsers(c *fiber.Ctx) error {
 	}
 
 	// Paginação
-	page, _ := strconv.Atoi(c.Query("page", "1"))
-	limit, _ := strconv.Atoi(c.Query("limit", "20"))
+	page, limit, err := parsePagination(c)
+	if err != nil {
+		return paginationError(c, err)
+	}
 	offset := (page - 1) * limit
 
 	query = query.Limit(limit).Offset(offset).Order("id ASC")
 
-	err := query.Scan(c.Context())
+	err = query.Scan(c.Context())
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to fetch users",
@@ -200,14 +202,7 @@ func (h *UserHandler) GetUsers(c *fiber.Ctx) error {
 		})
 	}
 
-	return c.JSON(fiber.Map{
-		"users": users,
-		"pagination": fiber.Map{
-			"page":  page,
-			"limit": limit,
-			"total": total,
-		},
-	})
+	return respondList(c, "users", users, page, limit, total)
 }
 
 // GetUser obtém um usuário específico (apenas admin)
@@ -231,7 +226,7 @@ func (h *UserHandler) GetUser(c *fiber.Ctx) error {
 		})
 	}
 
-	return c.JSON(user)
+	return respondData(c, fiber.StatusOK, user)
 }
 
 // UpdateUser atualiza um usuário (apenas admin)
@@ -335,7 +330,7 @@ func (h *UserHandler) UpdateUser(c *fiber.Ctx) error {
 		})
 	}
 
-	return c.JSON(user)
+	return respondData(c, fiber.StatusOK, user)
 }
 
 // DeleteUser remove um usuário (apenas admin)
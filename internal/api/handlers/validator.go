@@ -52,3 +52,37 @@ func validateStruct(s interface{}) map[string]string {
 
 	return errors
 }
+
+// validateRequiredFields verifica se os campos JSON listados estão preenchidos.
+// Campos desconhecidos ou que não são texto são ignorados.
+func validateRequiredFields(s interface{}, fields []string) map[string]string {
+	value := reflect.Indirect(reflect.ValueOf(s))
+	if value.Kind() != reflect.Struct {
+		return nil
+	}
+
+	byJSONName := make(map[string]reflect.Value, value.NumField())
+	for i := 0; i < value.NumField(); i++ {
+		name := strings.SplitN(value.Type().Field(i).Tag.Get("json"), ",", 2)[0]
+		if name != "" && name != "-" {
+			byJSONName[name] = value.Field(i)
+		}
+	}
+
+	errors := make(map[string]string)
+	for _, field := range fields {
+		field = strings.TrimSpace(field)
+		fieldValue, ok := byJSONName[field]
+		if !ok || fieldValue.Kind() != reflect.String {
+			continue
+		}
+		if strings.TrimSpace(fieldValue.String()) == "" {
+			errors[field] = field + " is required"
+		}
+	}
+
+	if len(errors) == 0 {
+		return nil
+	}
+	return errors
+}
@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/zoomxml/internal/models"
+)
+
+// authenticatedApp serves path with user set as the authenticated user (none when nil)
+// in front of handlers, and a final handler answering 200 with the resolved company id
+func authenticatedApp(user *models.User, method, path string, handlers ...fiber.Handler) *fiber.App {
+	app := fiber.New()
+	chain := []fiber.Handler{func(c *fiber.Ctx) error {
+		if user != nil {
+			c.Locals(string(UserKey), user)
+		}
+		return c.Next()
+	}}
+	chain = append(chain, handlers...)
+	chain = append(chain, func(c *fiber.Ctx) error {
+		if company := GetCompanyFromContext(c); company != nil {
+			return c.JSON(fiber.Map{"company_id": company.ID})
+		}
+		return c.SendStatus(fiber.StatusOK)
+	})
+	app.Add(method, path, chain...)
+	return app
+}
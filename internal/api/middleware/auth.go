@@ -2,12 +2,16 @@ package middleware
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/zoomxml/config"
 	"github.com/zoomxml/internal/database"
 	"github.com/zoomxml/internal/models"
+	"github.com/zoomxml/internal/permissions"
+	"github.com/zoomxml/internal/services"
 )
 
 // UserContextKey é a chave para armazenar o usuário no contexto
@@ -43,11 +47,7 @@ func AuthMiddleware() fiber.Handler {
 		}
 
 		// Buscar usuário pelo token no banco de dados
-		user := &models.User{}
-		err := database.DB.NewSelect().
-			Model(user).
-			Where("token = ? AND active = true", tokenString).
-			Scan(c.Context())
+		user, err := findUserByToken(c.Context(), tokenString)
 
 		if err != nil {
 			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
@@ -102,24 +102,9 @@ func AdminTokenMiddleware() fiber.Handler {
 	}
 }
 
-// AdminOnlyMiddleware middleware que permite apenas usuários admin
+// AdminOnlyMiddleware middleware que permite apenas usuários admin (escopo admin)
 func AdminOnlyMiddleware() fiber.Handler {
-	return func(c *fiber.Ctx) error {
-		user := GetUserFromContext(c)
-		if user == nil {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "Authentication required",
-			})
-		}
-
-		if !user.IsAdmin() {
-			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
-				"error": "Admin access required",
-			})
-		}
-
-		return c.Next()
-	}
+	return RequireScope(permissions.ScopeAdmin)
 }
 
 // OptionalAuthMiddleware middleware de autenticação opcional
@@ -148,11 +133,7 @@ func OptionalAuthMiddleware() fiber.Handler {
 		}
 
 		// Buscar usuário pelo token no banco de dados
-		user := &models.User{}
-		err := database.DB.NewSelect().
-			Model(user).
-			Where("token = ? AND active = true", tokenString).
-			Scan(c.Context())
+		user, err := findUserByToken(c.Context(), tokenString)
 
 		if err != nil {
 			// Token inválido ou usuário não encontrado, continuar sem usuário
@@ -183,3 +164,20 @@ func GetUserFromGoContext(ctx context.Context) *models.User {
 	}
 	return user
 }
+
+// findUserByToken busca o usuário ativo dono do token: o token permanente do usuário ou um
+// token de acesso de curta duração emitido com um refresh token
+func findUserByToken(ctx context.Context, token string) (*models.User, error) {
+	user := &models.User{}
+	err := database.DB.NewSelect().
+		Model(user).
+		Where("token = ? AND active = true", token).
+		Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return services.UserForAccessToken(ctx, token)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/models"
+	"github.com/zoomxml/internal/permissions"
+)
+
+// CompanyKey é a chave para armazenar a empresa da rota no contexto
+const CompanyKey UserContextKey = "company"
+
+// CompanyMiddleware resolve a empresa das rotas /companies/:company_id/...: valida o ID,
+// verifica o acesso do usuário autenticado e carrega a empresa no contexto.
+// Deve ser usado depois do AuthMiddleware.
+func CompanyMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		companyID, err := strconv.ParseInt(c.Params("company_id"), 10, 64)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid company ID",
+			})
+		}
+
+		user := GetUserFromContext(c)
+		if user == nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "Authentication required",
+			})
+		}
+
+		err = permissions.CanAccessCompany(c.Context(), user, companyID)
+		if err != nil {
+			if errors.Is(err, permissions.ErrCompanyNotFound) {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+					"error": "Company not found",
+				})
+			}
+			if errors.Is(err, permissions.ErrAccessDenied) {
+				return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+					"error": "Access denied to this company",
+				})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to validate permissions",
+			})
+		}
+
+		company := &models.Company{}
+		err = database.DB.NewSelect().
+			Model(company).
+			Where("id = ?", companyID).
+			Scan(c.Context())
+
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Company not found",
+			})
+		}
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to load company",
+			})
+		}
+
+		c.Locals(string(CompanyKey), company)
+
+		return c.Next()
+	}
+}
+
+// GetCompanyFromContext extrai a empresa resolvida pelo CompanyMiddleware
+func GetCompanyFromContext(c *fiber.Ctx) *models.Company {
+	company, ok := c.Locals(string(CompanyKey)).(*models.Company)
+	if !ok {
+		return nil
+	}
+	return company
+}
@@ -0,0 +1,102 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/database/databasetest"
+	"github.com/zoomxml/internal/models"
+)
+
+// companyRequest calls CompanyMiddleware for companyID and returns the status, the error
+// message and the id of the company the next handler received
+func companyRequest(t *testing.T, user *models.User, companyID string) (int, string, int64) {
+	t.Helper()
+	app := authenticatedApp(user, fiber.MethodGet, "/companies/:company_id", CompanyMiddleware())
+	resp, err := app.Test(httptest.NewRequest("GET", "/companies/"+companyID, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		Error     string `json:"error"`
+		CompanyID int64  `json:"company_id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, body.Error, body.CompanyID
+}
+
+func TestCompanyMiddlewareWithoutDatabase(t *testing.T) {
+	databasetest.UseClosed(t)
+
+	tests := []struct {
+		name       string
+		user       *models.User
+		companyID  string
+		wantStatus int
+		wantError  string
+	}{
+		{"invalid id", &models.User{ID: 1, Role: "user"}, "abc", fiber.StatusBadRequest, "Invalid company ID"},
+		{"anonymous", nil, "1", fiber.StatusUnauthorized, "Authentication required"},
+		{"permission check fails", &models.User{ID: 1, Role: "user"}, "1", fiber.StatusInternalServerError, "Failed to validate permissions"},
+		{"company load fails", &models.User{ID: 1, Role: "admin"}, "1", fiber.StatusInternalServerError, "Failed to load company"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, message, _ := companyRequest(t, tt.user, tt.companyID)
+			if status != tt.wantStatus || message != tt.wantError {
+				t.Errorf("CompanyMiddleware() = %d %q, want %d %q", status, message, tt.wantStatus, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestCompanyMiddleware(t *testing.T) {
+	databasetest.Require(t)
+	open := databasetest.CreateCompany(t, nil)
+	restricted := databasetest.CreateCompany(t, func(c *models.Company) { c.Restricted = true })
+	inactive := databasetest.CreateCompany(t, nil)
+	// active has a database default, so false is only kept by an update
+	if _, err := database.DB.NewUpdate().Model(inactive).Set("active = false").WherePK().Exec(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	member := databasetest.CreateUser(t, "user", restricted)
+	outsider := databasetest.CreateUser(t, "user")
+	admin := databasetest.CreateUser(t, "admin")
+
+	tests := []struct {
+		name       string
+		user       *models.User
+		companyID  int64
+		wantStatus int
+	}{
+		{"member of a restricted company", member, restricted.ID, fiber.StatusOK},
+		{"non-member of a restricted company", outsider, restricted.ID, fiber.StatusForbidden},
+		{"admin on a restricted company", admin, restricted.ID, fiber.StatusOK},
+		{"non-member of an unrestricted company", outsider, open.ID, fiber.StatusOK},
+		{"inactive company", outsider, inactive.ID, fiber.StatusNotFound},
+		{"unknown company as user", outsider, -1, fiber.StatusNotFound},
+		{"unknown company as admin", admin, -1, fiber.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, message, companyID := companyRequest(t, tt.user, fmt.Sprint(tt.companyID))
+			if status != tt.wantStatus {
+				t.Fatalf("CompanyMiddleware() = %d %q, want %d", status, message, tt.wantStatus)
+			}
+			if status == fiber.StatusOK && companyID != tt.companyID {
+				t.Errorf("company in context = %d, want %d", companyID, tt.companyID)
+			}
+		})
+	}
+}
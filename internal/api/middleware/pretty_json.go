@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// PrettyJSON indenta as respostas JSON das requisições com ?pretty=true, para depuração.
+// Sem o parâmetro, a resposta segue compacta. Deve vir depois da compressão, para que o
+// corpo seja indentado antes de ser comprimido.
+func PrettyJSON() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !c.QueryBool("pretty", false) {
+			return c.Next()
+		}
+
+		if err := c.Next(); err != nil {
+			return err
+		}
+
+		contentType := string(c.Response().Header.ContentType())
+		if !strings.HasPrefix(contentType, fiber.MIMEApplicationJSON) {
+			return nil
+		}
+
+		var indented bytes.Buffer
+		if err := json.Indent(&indented, c.Response().Body(), "", "  "); err != nil {
+			return nil // corpo não é JSON válido; segue como está
+		}
+		c.Response().SetBody(indented.Bytes())
+
+		return nil
+	}
+}
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestPrettyJSON(t *testing.T) {
+	app := fiber.New()
+	app.Use(PrettyJSON())
+	app.Get("/json", func(c *fiber.Ctx) error {
+		return c.JSON(fiber.Map{"id": 1, "tags": []string{"a"}})
+	})
+	app.Get("/text", func(c *fiber.Ctx) error {
+		return c.SendString(`{"id":1}`)
+	})
+	app.Get("/invalid", func(c *fiber.Ctx) error {
+		c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		return c.SendString(`{"id":`)
+	})
+
+	const compact = `{"id":1,"tags":["a"]}`
+	const pretty = "{\n  \"id\": 1,\n  \"tags\": [\n    \"a\"\n  ]\n}"
+
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{"pretty", "/json?pretty=true", pretty},
+		{"compact by default", "/json", compact},
+		{"pretty off", "/json?pretty=false", compact},
+		{"other content types are left alone", "/text?pretty=true", `{"id":1}`},
+		{"invalid JSON is left alone", "/invalid?pretty=true", `{"id":`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := app.Test(httptest.NewRequest("GET", tt.path, nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(body) != tt.want {
+				t.Errorf("GET %s body = %q, want %q", tt.path, body, tt.want)
+			}
+		})
+	}
+}
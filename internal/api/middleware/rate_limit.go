@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/limiter"
+	"github.com/zoomxml/config"
+)
+
+// HeavyOperationLimiter limita as operações que consultam o provedor (busca e consulta
+// de competência) a HEAVY_OPERATIONS_RPM por minuto, por usuário e empresa. Deve ser
+// usado depois do AuthMiddleware e do CompanyMiddleware.
+func HeavyOperationLimiter() fiber.Handler {
+	cfg := config.Get().RateLimit
+	if !cfg.Enable || cfg.HeavyOperationsRPM <= 0 {
+		return func(c *fiber.Ctx) error {
+			return c.Next()
+		}
+	}
+
+	return limiter.New(limiter.Config{
+		Max:        cfg.HeavyOperationsRPM,
+		Expiration: time.Minute,
+		KeyGenerator: func(c *fiber.Ctx) string {
+			key := "ip:" + c.IP()
+			if user := GetUserFromContext(c); user != nil {
+				key = "user:" + strconv.FormatInt(user.ID, 10)
+			}
+			if company := GetCompanyFromContext(c); company != nil {
+				key += ":company:" + strconv.FormatInt(company.ID, 10)
+			}
+			return key
+		},
+		LimitReached: func(c *fiber.Ctx) error {
+			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+				"error": "Too many provider requests, try again later",
+			})
+		},
+	})
+}
@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/zoomxml/internal/permissions"
+)
+
+// RequireScope exige que o usuário autenticado tenha o escopo na empresa da rota (a
+// resolvida pelo CompanyMiddleware ou o parâmetro :company_id / :id). Rotas sem empresa
+// só aceitam escopos de admin. Deve ser usado depois do AuthMiddleware.
+func RequireScope(scope permissions.Scope) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		return checkScope(c, scope)
+	}
+}
+
+// RequireMethodScope exige o escopo de leitura em GET e HEAD e o de escrita nos demais
+// métodos, para grupos de rotas que misturam consultas e alterações
+func RequireMethodScope(read, write permissions.Scope) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if c.Method() == fiber.MethodGet || c.Method() == fiber.MethodHead {
+			return checkScope(c, read)
+		}
+		return checkScope(c, write)
+	}
+}
+
+// checkScope responde 401, 403 ou 404 quando o usuário não tem o escopo
+func checkScope(c *fiber.Ctx, scope permissions.Scope) error {
+	user := GetUserFromContext(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Authentication required",
+		})
+	}
+
+	err := permissions.HasScope(c.Context(), user, routeCompanyID(c), scope)
+	if err != nil {
+		if err == permissions.ErrAccessDenied {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "Missing required scope",
+				"scope": scope,
+			})
+		}
+		if err == permissions.ErrCompanyNotFound {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Company not found",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to validate permissions",
+		})
+	}
+
+	return c.Next()
+}
+
+// routeCompanyID retorna a empresa da rota, ou 0 quando a rota não tem empresa
+func routeCompanyID(c *fiber.Ctx) int64 {
+	if company := GetCompanyFromContext(c); company != nil {
+		return company.ID
+	}
+	for _, param := range []string{"company_id", "id"} {
+		if id, err := strconv.ParseInt(c.Params(param), 10, 64); err == nil {
+			return id
+		}
+	}
+	return 0
+}
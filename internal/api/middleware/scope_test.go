@@ -0,0 +1,125 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/zoomxml/config"
+	"github.com/zoomxml/internal/database/databasetest"
+	"github.com/zoomxml/internal/models"
+	"github.com/zoomxml/internal/permissions"
+)
+
+// setUserScopes sets AUTH_USER_SCOPES while the test runs
+func setUserScopes(t *testing.T, scopes ...string) {
+	t.Helper()
+	cfg := &config.Get().Auth
+	previous := cfg.UserScopes
+	cfg.UserScopes = scopes
+	t.Cleanup(func() { cfg.UserScopes = previous })
+}
+
+// scopeStatus calls path with method through handler as user and returns the status
+func scopeStatus(t *testing.T, user *models.User, method, path string, handler fiber.Handler) int {
+	t.Helper()
+	app := authenticatedApp(user, method, "/companies/:id", handler)
+	resp, err := app.Test(httptest.NewRequest(method, path, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	return resp.StatusCode
+}
+
+// TestRequireScopeWithoutDatabase covers the checks decided before any company lookup
+func TestRequireScopeWithoutDatabase(t *testing.T) {
+	databasetest.UseClosed(t)
+	setUserScopes(t, string(permissions.ScopeDocumentsRead))
+	user := &models.User{ID: 1, Role: "user"}
+
+	tests := []struct {
+		name       string
+		user       *models.User
+		scope      permissions.Scope
+		wantStatus int
+	}{
+		{"anonymous", nil, permissions.ScopeDocumentsRead, fiber.StatusUnauthorized},
+		{"admin holds every scope", &models.User{ID: 2, Role: "admin"}, permissions.ScopeAdmin, fiber.StatusOK},
+		{"scope not granted to users", user, permissions.ScopeCredentialsManage, fiber.StatusForbidden},
+		{"admin scope never granted to users", user, permissions.ScopeAdmin, fiber.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status := scopeStatus(t, tt.user, fiber.MethodGet, "/companies/1", RequireScope(tt.scope)); status != tt.wantStatus {
+				t.Errorf("RequireScope(%s) status = %d, want %d", tt.scope, status, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestRequireScope(t *testing.T) {
+	databasetest.Require(t)
+	setUserScopes(t, string(permissions.ScopeDocumentsRead), string(permissions.ScopeDocumentsWrite))
+
+	public := databasetest.CreateCompany(t, nil)
+	restricted := databasetest.CreateCompany(t, func(c *models.Company) { c.Restricted = true })
+	member := databasetest.CreateUser(t, "user", public, restricted)
+	outsider := databasetest.CreateUser(t, "user")
+
+	tests := []struct {
+		name       string
+		user       *models.User
+		company    *models.Company
+		scope      permissions.Scope
+		wantStatus int
+	}{
+		{"member reads", member, public, permissions.ScopeDocumentsRead, fiber.StatusOK},
+		{"member writes", member, restricted, permissions.ScopeDocumentsWrite, fiber.StatusOK},
+		{"member lacks an ungranted scope", member, public, permissions.ScopeCredentialsManage, fiber.StatusForbidden},
+		{"outsider reads a public company", outsider, public, permissions.ScopeDocumentsRead, fiber.StatusOK},
+		{"outsider cannot write", outsider, public, permissions.ScopeDocumentsWrite, fiber.StatusForbidden},
+		{"outsider cannot read a restricted company", outsider, restricted, permissions.ScopeDocumentsRead, fiber.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := fmt.Sprintf("/companies/%d", tt.company.ID)
+			if status := scopeStatus(t, tt.user, fiber.MethodGet, path, RequireScope(tt.scope)); status != tt.wantStatus {
+				t.Errorf("RequireScope(%s) status = %d, want %d", tt.scope, status, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestRequireMethodScope(t *testing.T) {
+	databasetest.Require(t)
+	setUserScopes(t, string(permissions.ScopeDocumentsRead), string(permissions.ScopeDocumentsWrite))
+
+	company := databasetest.CreateCompany(t, nil)
+	member := databasetest.CreateUser(t, "user", company)
+	outsider := databasetest.CreateUser(t, "user")
+	handler := RequireMethodScope(permissions.ScopeDocumentsRead, permissions.ScopeDocumentsWrite)
+	path := fmt.Sprintf("/companies/%d", company.ID)
+
+	tests := []struct {
+		name       string
+		user       *models.User
+		method     string
+		wantStatus int
+	}{
+		{"outsider reads", outsider, fiber.MethodGet, fiber.StatusOK},
+		{"outsider cannot write", outsider, fiber.MethodPost, fiber.StatusForbidden},
+		{"member writes", member, fiber.MethodPost, fiber.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status := scopeStatus(t, tt.user, tt.method, path, handler); status != tt.wantStatus {
+				t.Errorf("RequireMethodScope() %s status = %d, want %d", tt.method, status, tt.wantStatus)
+			}
+		})
+	}
+}
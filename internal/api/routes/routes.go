@@ -4,6 +4,7 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/zoomxml/internal/api/handlers"
 	"github.com/zoomxml/internal/api/middleware"
+	"github.com/zoomxml/internal/permissions"
 )
 
 // SetupRoutes configura todas as rotas da aplicação
@@ -34,6 +35,16 @@ func SetupRoutes(app *fiber.App) {
 
 	// Configurar rotas de estatísticas
 	setupStatsRoutes(api)
+
+	// Configurar rotas administrativas
+	setupAdminRoutes(api)
+
+	// Empresas em que o usuário autenticado pode atuar
+	api.Get("/me/companies", middleware.AuthMiddleware(), companyHandler.GetMyCompanies)
+
+	// Onboarding de empresa (empresa + credencial + busca automática)
+	onboardingHandler := handlers.NewOnboardingHandler()
+	api.Post("/onboard", middleware.AuthMiddleware(), onboardingHandler.Onboard)
 }
 
 // setupUserRoutes configura as rotas de gerenciamento de usuários
@@ -59,11 +70,27 @@ func setupCompanyRoutes(api fiber.Router, handler *handlers.CompanyHandler) {
 	companies.Use(middleware.OptionalAuthMiddleware())
 
 	// CRUD de empresas
-	companies.Post("/", middleware.AuthMiddleware(), handler.CreateCompany)                                        // Criar requer autenticação
-	companies.Get("/", handler.GetCompanies)                                                                       // Listar (com regras de visibilidade)
-	companies.Get("/:id", handler.GetCompany)                                                                      // Obter (com regras de visibilidade)
-	companies.Patch("/:id", middleware.AuthMiddleware(), handler.UpdateCompany)                                    // Atualizar requer autenticação
-	companies.Delete("/:id", middleware.AuthMiddleware(), middleware.AdminOnlyMiddleware(), handler.DeleteCompany) // Deletar apenas admin
+	companies.Post("/", middleware.AuthMiddleware(), handler.CreateCompany)                                                                                   // Criar requer autenticação
+	companies.Get("/", handler.GetCompanies)                                                                                                                  // Listar (com regras de visibilidade)
+	companies.Get("/:id", handler.GetCompany)                                                                                                                 // Obter (com regras de visibilidade)
+	companies.Get("/:id/overview", middleware.AuthMiddleware(), middleware.RequireScope(permissions.ScopeDocumentsRead), handler.GetCompanyOverview)          // Visão geral: credenciais, sincronização, documentos e pendências
+	companies.Post("/:id/reset-watermark", middleware.AuthMiddleware(), middleware.RequireScope(permissions.ScopeDocumentsWrite), handler.ResetSyncWatermark) // Redefinir marca de sincronização (admin ou membro)
+	companies.Get("/:id/sync-schedule", middleware.AuthMiddleware(), middleware.RequireScope(permissions.ScopeDocumentsRead), handler.GetSyncSchedule)        // Agendamento da busca automática (cron e janela diária)
+	companies.Put("/:id/sync-schedule", middleware.AuthMiddleware(), middleware.RequireScope(permissions.ScopeDocumentsWrite), handler.UpdateSyncSchedule)    // Definir agendamento da busca automática (admin ou membro)
+	companies.Get("/:id/retention", middleware.AuthMiddleware(), middleware.RequireScope(permissions.ScopeDocumentsRead), handler.GetRetentionPolicy)         // Política de retenção
+	companies.Put("/:id/retention", middleware.AuthMiddleware(), middleware.AdminOnlyMiddleware(), handler.UpdateRetentionPolicy)                             // Atualizar retenção (apenas admin)
+	companies.Post("/:id/reprocess", middleware.AuthMiddleware(), middleware.AdminOnlyMiddleware(), handler.ReprocessCompany)                                 // Reprocessar todos os documentos (apenas admin)
+	companies.Get("/:id/reprocess/:batch_id", middleware.AuthMiddleware(), middleware.AdminOnlyMiddleware(), handler.GetReprocessStatus)                      // Progresso do reprocessamento (apenas admin)
+	companies.Get("/:id/export-target", middleware.AuthMiddleware(), middleware.AdminOnlyMiddleware(), handler.GetExportTarget)                               // Bucket S3 de exportação (apenas admin)
+	companies.Put("/:id/export-target", middleware.AuthMiddleware(), middleware.AdminOnlyMiddleware(), handler.UpdateExportTarget)                            // Configurar bucket S3 de exportação (apenas admin)
+	companies.Post("/:id/exports", middleware.AuthMiddleware(), middleware.AdminOnlyMiddleware(), handler.StartExport)                                        // Copiar XMLs para o bucket de exportação (apenas admin)
+	companies.Get("/:id/exports/:job_id", middleware.AuthMiddleware(), middleware.AdminOnlyMiddleware(), handler.GetExportJobStatus)                          // Progresso da exportação (apenas admin)
+	companies.Get("/:id/integrity", middleware.AuthMiddleware(), middleware.AdminOnlyMiddleware(), handler.GetIntegrityCheck)                                 // Última verificação de integridade banco x storage (apenas admin)
+	companies.Patch("/:id", middleware.AuthMiddleware(), handler.UpdateCompany)                                                                               // Atualizar requer autenticação
+	companies.Delete("/:id", middleware.AuthMiddleware(), middleware.AdminOnlyMiddleware(), handler.DeleteCompany)                                            // Deletar apenas admin
+
+	// Rotas com a empresa resolvida (e o acesso verificado) pelo CompanyMiddleware
+	companies.Get("/:company_id/compare", middleware.AuthMiddleware(), middleware.RequireScope(permissions.ScopeDocumentsRead), middleware.CompanyMiddleware(), handler.CompareCompetences) // Comparar competências (?from=YYYY-MM&to=YYYY-MM)
 
 	// Rotas para gerenciar membros de empresas restritas
 	setupCompanyMemberRoutes(companies)
@@ -73,6 +100,12 @@ func setupCompanyRoutes(api fiber.Router, handler *handlers.CompanyHandler) {
 
 	// Rotas para NFSe
 	setupNFSeRoutes(companies)
+
+	// Rotas para busca de documentos
+	setupDocumentRoutes(companies)
+
+	// Rotas para logs de processamento
+	setupProcessingLogRoutes(companies)
 }
 
 // setupCompanyMemberRoutes configura as rotas de membros de empresas
@@ -98,26 +131,86 @@ func setupCredentialRoutes(api fiber.Router, handler *handlers.CredentialHandler
 func setupCompanyCredentialRoutes(companies fiber.Router) {
 	// Rotas para gerenciar credenciais
 	credentials := companies.Group("/:company_id/credentials")
-	credentials.Use(middleware.AuthMiddleware()) // Requer autenticação
+	credentials.Use(middleware.AuthMiddleware())    // Requer autenticação
+	credentials.Use(middleware.CompanyMiddleware()) // Resolve a empresa e verifica o acesso
+	credentials.Use(middleware.RequireScope(permissions.ScopeCredentialsManage))
 
 	// Implementar handlers de credenciais
 	credentialHandler := handlers.NewCredentialHandler()
-	credentials.Post("/", credentialHandler.CreateCredential)      // Criar credencial
-	credentials.Get("/", credentialHandler.GetCredentials)         // Listar credenciais
-	credentials.Patch("/:id", credentialHandler.UpdateCredential)  // Atualizar credencial
-	credentials.Delete("/:id", credentialHandler.DeleteCredential) // Deletar credencial
+	credentials.Post("/", credentialHandler.CreateCredential)                    // Criar credencial
+	credentials.Get("/", credentialHandler.GetCredentials)                       // Listar credenciais
+	credentials.Get("/:credential_id/info", credentialHandler.GetCredentialInfo) // Capacidades da credencial
+	credentials.Patch("/:credential_id", credentialHandler.UpdateCredential)     // Atualizar credencial
+	credentials.Delete("/:credential_id", credentialHandler.DeleteCredential)    // Deletar credencial
 }
 
 // setupNFSeRoutes configura as rotas de NFSe
 func setupNFSeRoutes(companies fiber.Router) {
 	// Rotas para NFSe
 	nfse := companies.Group("/:company_id/nfse")
-	nfse.Use(middleware.AuthMiddleware()) // Requer autenticação
+	nfse.Use(middleware.AuthMiddleware())    // Requer autenticação
+	nfse.Use(middleware.CompanyMiddleware()) // Resolve a empresa e verifica o acesso
+
+	// Escopos: documents:read nas consultas (GET), documents:write nas alterações
+	nfse.Use(middleware.RequireMethodScope(permissions.ScopeDocumentsRead, permissions.ScopeDocumentsWrite))
 
 	// Implementar handlers de NFSe
 	nfseHandler := handlers.NewNFSeHandler()
-	nfse.Post("/fetch", nfseHandler.FetchNFSeDocuments) // Buscar documentos NFSe
-	nfse.Get("/", nfseHandler.GetNFSeDocuments)         // Listar documentos NFSe armazenados
+	heavy := middleware.HeavyOperationLimiter()                                                                  // Limita as chamadas ao provedor (HEAVY_OPERATIONS_RPM)
+	nfse.Post("/fetch", heavy, nfseHandler.FetchNFSeDocuments)                                                   // Buscar documentos NFSe
+	nfse.Get("/", nfseHandler.GetNFSeDocuments)                                                                  // Listar documentos NFSe armazenados
+	nfse.Get("/gaps", nfseHandler.GetNFSeNumberingGaps)                                                          // Lacunas na numeração por competência
+	nfse.Get("/competence", nfseHandler.GetNFSeCompetenceListing)                                                // Competência conciliada entre banco e storage (?competencia=YYYY-MM)
+	nfse.Get("/competence/status", nfseHandler.GetNFSeCompetenceStatus)                                          // Competência aberta ou fechada, chegadas tardias e lacunas (?competencia=YYYY-MM)
+	nfse.Post("/competence/close", nfseHandler.CloseNFSeCompetence)                                              // Fechar competência; notas novas dela são marcadas late_arrival
+	nfse.Post("/competence/reopen", nfseHandler.ReopenNFSeCompetence)                                            // Reabrir competência fechada
+	nfse.Post("/merge-duplicates", middleware.AdminOnlyMiddleware(), nfseHandler.MergeDuplicateNFSeDocuments)    // Mesclar duplicatas (apenas admin, ?dry_run=false aplica)
+	nfse.Post("/restore-objects", middleware.AdminOnlyMiddleware(), nfseHandler.RestoreMissingNFSeObjects)       // Reenviar XMLs ausentes do storage (apenas admin, ?dry_run=false aplica)
+	nfse.Post("/consolidate-competences", middleware.AdminOnlyMiddleware(), nfseHandler.ConsolidateNFSeFolders)  // Unificar competências divididas entre pastas de ano (apenas admin, ?dry_run=false aplica)
+	nfse.Post("/mark-reviewed", nfseHandler.MarkNFSeDocumentsReviewed)                                           // Marcar documentos como revisados
+	nfse.Post("/consult", heavy, nfseHandler.ConsultNFSeCompetence)                                              // Consultar uma competência de forma síncrona (?competencia=YYYY-MM)
+	nfse.Post("/download", nfseHandler.DownloadNFSeDocuments)                                                    // Baixar documentos selecionados em ZIP
+	nfse.Get("/download", nfseHandler.DownloadNFSeRange)                                                         // Baixar documentos de um período em ZIP com manifesto (?start_date=&end_date=)
+	nfse.Get("/export", nfseHandler.ExportNFSeDocuments)                                                         // Exportar metadados dos documentos em CSV ou XLSX (?format=&start_date=&end_date=&competencia=)
+	nfse.Post("/dedup-check", nfseHandler.PreviewNFSeDedup)                                                      // Simular deduplicação de um XML sem armazenar
+	nfse.Get("/duplicate-stats", nfseHandler.GetNFSeDuplicateStatistics)                                         // Estatísticas de duplicidade (?days= ou ?start_date=&end_date=)
+	nfse.Post("/upload", nfseHandler.UploadNFSeDocuments)                                                        // Enviar XMLs manualmente (?overwrite=true substitui)
+	nfse.Post("/upload-zip", nfseHandler.UploadNFSeZip)                                                          // Enviar um ZIP de XMLs (?overwrite=true substitui)
+	nfse.Get("/dead-letters", nfseHandler.GetNFSeDeadLetters)                                                    // XMLs que falharam na leitura ou validação (?status=)
+	nfse.Post("/dead-letters/:dead_letter_id/reprocess", nfseHandler.ReprocessNFSeDeadLetter)                    // Reprocessar XML da fila de mensagens mortas
+	nfse.Get("/by-verification/:code", nfseHandler.GetNFSeByVerificationCode)                                    // Documento pelo código de verificação (ignora espaços, traços e caixa)
+	nfse.Post("/:number/verify", nfseHandler.VerifyNFSeDocument)                                                 // Conferir documento com o provedor
+	nfse.Get("/:document_id/xml", nfseHandler.DownloadNFSeXML)                                                   // Baixar o XML armazenado do documento
+	nfse.Get("/:number/pdf", nfseHandler.DownloadNFSePDF)                                                        // DANFSE em PDF gerado do XML armazenado (?verification_code=)
+	nfse.Get("/:competencia/download-zip", nfseHandler.DownloadNFSeCompetenceZip)                                // Baixar todos os XMLs da competência em ZIP (YYYY-MM)
+	nfse.Post("/:document_id/tags", nfseHandler.AddNFSeDocumentTags)                                             // Adicionar etiquetas ao documento
+	nfse.Put("/:document_id/legal-hold", middleware.AdminOnlyMiddleware(), nfseHandler.SetNFSeDocumentLegalHold) // Retenção legal do documento (apenas admin)
+	nfse.Delete("/:document_id/tags", nfseHandler.RemoveNFSeDocumentTags)                                        // Remover etiquetas do documento
+}
+
+// setupDocumentRoutes configura as rotas de busca e envio de documentos
+func setupDocumentRoutes(companies fiber.Router) {
+	documents := companies.Group("/:company_id/documents")
+	documents.Use(middleware.AuthMiddleware())    // Requer autenticação
+	documents.Use(middleware.CompanyMiddleware()) // Resolve a empresa e verifica o acesso
+
+	// Escopos: documents:read nas consultas (GET), documents:write nas alterações
+	documents.Use(middleware.RequireMethodScope(permissions.ScopeDocumentsRead, permissions.ScopeDocumentsWrite))
+
+	nfseHandler := handlers.NewNFSeHandler()
+	documents.Get("/search", nfseHandler.SearchDocuments)  // Busca estruturada e textual na discriminação (?q=&number=&provider_cnpj=...)
+	documents.Post("/upload", nfseHandler.UploadDocuments) // Enviar XMLs de NFSe, NF-e e CT-e, avulsos ou em ZIP (?overwrite=true substitui)
+}
+
+// setupProcessingLogRoutes configura as rotas de logs de processamento
+func setupProcessingLogRoutes(companies fiber.Router) {
+	logs := companies.Group("/:company_id/processing-logs")
+	logs.Use(middleware.AuthMiddleware())    // Requer autenticação
+	logs.Use(middleware.CompanyMiddleware()) // Resolve a empresa e verifica o acesso
+	logs.Use(middleware.RequireScope(permissions.ScopeDocumentsRead))
+
+	processingLogHandler := handlers.NewProcessingLogHandler()
+	logs.Get("/", processingLogHandler.GetProcessingLogs) // Listar logs por empresa ou lote (?batch_id=)
 }
 
 // setupCNPJRoutes configura as rotas de consulta de CNPJ
@@ -133,6 +226,7 @@ func setupAuthRoutes(api fiber.Router) {
 
 	// Rotas de autenticação
 	auth.Post("/login", authHandler.Login)                                // Login de usuários
+	auth.Post("/refresh", authHandler.Refresh)                            // Trocar refresh token (rotação a cada uso)
 	auth.Post("/logout", middleware.AuthMiddleware(), authHandler.Logout) // Logout (requer autenticação)
 	auth.Get("/me", middleware.AuthMiddleware(), authHandler.GetProfile)  // Perfil do usuário logado
 }
@@ -144,6 +238,19 @@ func setupStatsRoutes(api fiber.Router) {
 
 	// Rotas de estatísticas (requer autenticação)
 	stats.Use(middleware.AuthMiddleware())
-	stats.Get("/dashboard", statsHandler.GetDashboardStats)   // Estatísticas do dashboard
-	stats.Get("/companies/:id", statsHandler.GetCompanyStats) // Estatísticas de empresa específica
+	stats.Get("/dashboard", statsHandler.GetDashboardStats)                                                            // Estatísticas do dashboard
+	stats.Get("/companies/:id", middleware.RequireScope(permissions.ScopeDocumentsRead), statsHandler.GetCompanyStats) // Estatísticas de empresa específica
+	stats.Get("/providers", middleware.AdminOnlyMiddleware(), statsHandler.GetProviderStatus)                          // Circuit breaker dos provedores (apenas admin)
+}
+
+// setupAdminRoutes configura as rotas administrativas
+func setupAdminRoutes(api fiber.Router) {
+	admin := api.Group("/admin")
+	auditHandler := handlers.NewAuditHandler()
+	companyHandler := handlers.NewCompanyHandler()
+
+	// Rotas administrativas (apenas admin)
+	admin.Use(middleware.AuthMiddleware(), middleware.AdminOnlyMiddleware())
+	admin.Get("/audit-logs/export", auditHandler.ExportAuditLogs) // Exportar auditoria em CSV
+	admin.Post("/auto-sync", companyHandler.SetAutoSync)          // Pausar ou retomar a busca automática em massa
 }
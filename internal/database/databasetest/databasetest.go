@@ -0,0 +1,152 @@
+// Package databasetest connects tests to a disposable PostgreSQL database and creates
+// the companies, users, documents and credentials they work on.
+package databasetest
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/pgdialect"
+	"github.com/uptrace/bun/driver/pgdriver"
+	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/models"
+)
+
+var (
+	connectOnce sync.Once
+	connectErr  error
+)
+
+// Require connects to the PostgreSQL database given by the DB_* variables and applies
+// the migrations. Tests that need it are skipped unless TEST_DATABASE=1, since they
+// write to the database and change its schema: point DB_NAME at a disposable one.
+func Require(t *testing.T) {
+	t.Helper()
+	if os.Getenv("TEST_DATABASE") != "1" {
+		t.Skip("set TEST_DATABASE=1 and DB_* to a disposable PostgreSQL database to run")
+	}
+
+	connectOnce.Do(func() {
+		if connectErr = database.Connect(); connectErr != nil {
+			return
+		}
+		connectErr = database.RunMigrations(context.Background())
+	})
+	if connectErr != nil {
+		t.Fatalf("test database unavailable: %v", connectErr)
+	}
+}
+
+// UseClosed points database.DB at a closed connection pool, so every query fails
+// without a server
+func UseClosed(t *testing.T) {
+	t.Helper()
+	sqldb := sql.OpenDB(pgdriver.NewConnector())
+	sqldb.Close()
+
+	previous := database.DB
+	database.DB = bun.NewDB(sqldb, pgdialect.New())
+	t.Cleanup(func() { database.DB = previous })
+}
+
+// CreateCompany inserts an active company, changed by edit before the insert, and
+// removes it with its documents and members when the test ends
+func CreateCompany(t *testing.T, edit func(*models.Company)) *models.Company {
+	t.Helper()
+	ctx := context.Background()
+
+	cnpj := fmt.Sprintf("%014d", time.Now().UnixNano()%100000000000000)
+	company := &models.Company{Name: "Test " + cnpj, CNPJ: cnpj, Active: true}
+	if edit != nil {
+		edit(company)
+	}
+	if _, err := database.DB.NewInsert().Model(company).Exec(ctx); err != nil {
+		t.Fatalf("failed to create company: %v", err)
+	}
+	t.Cleanup(func() {
+		database.DB.NewDelete().Model((*models.Document)(nil)).Where("company_id = ?", company.ID).ForceDelete().Exec(ctx)
+		database.DB.NewDelete().Model((*models.CompanyMember)(nil)).Where("company_id = ?", company.ID).Exec(ctx)
+		database.DB.NewDelete().Model((*models.Company)(nil)).Where("id = ?", company.ID).Exec(ctx)
+	})
+	return company
+}
+
+// CreateDocument inserts a document of a company, an NFSe issued now unless set, and
+// removes it when the test ends
+func CreateDocument(t *testing.T, document *models.Document) *models.Document {
+	t.Helper()
+	ctx := context.Background()
+
+	if document.Type == "" {
+		document.Type = models.DocumentTypeNFSe
+	}
+	if document.IssueDate.IsZero() {
+		document.IssueDate = time.Now()
+	}
+	if _, err := database.DB.NewInsert().Model(document).Exec(ctx); err != nil {
+		t.Fatalf("failed to create document: %v", err)
+	}
+	t.Cleanup(func() {
+		database.DB.NewDelete().Model((*models.Document)(nil)).Where("id = ?", document.ID).ForceDelete().Exec(ctx)
+	})
+	return document
+}
+
+// CreateUser inserts an active user with the given role, a member of companies, and
+// removes it with its memberships when the test ends
+func CreateUser(t *testing.T, role string, companies ...*models.Company) *models.User {
+	t.Helper()
+	ctx := context.Background()
+
+	suffix := fmt.Sprintf("%d", time.Now().UnixNano())
+	user := &models.User{
+		Name:     "Test " + suffix,
+		Email:    suffix + "@test.local",
+		Password: "-",
+		Token:    suffix,
+		Role:     role,
+		Active:   true,
+	}
+	if _, err := database.DB.NewInsert().Model(user).Exec(ctx); err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	t.Cleanup(func() {
+		database.DB.NewDelete().Model((*models.CompanyMember)(nil)).Where("user_id = ?", user.ID).Exec(ctx)
+		database.DB.NewDelete().Model((*models.User)(nil)).Where("id = ?", user.ID).Exec(ctx)
+	})
+
+	for _, company := range companies {
+		member := &models.CompanyMember{UserID: user.ID, CompanyID: company.ID}
+		if _, err := database.DB.NewInsert().Model(member).Exec(ctx); err != nil {
+			t.Fatalf("failed to add member: %v", err)
+		}
+	}
+	return user
+}
+
+// CreateCredential inserts a credential of a company, a prefeitura_token unless set,
+// and removes it when the test ends
+func CreateCredential(t *testing.T, credential *models.CompanyCredential) *models.CompanyCredential {
+	t.Helper()
+	ctx := context.Background()
+
+	if credential.Type == "" {
+		credential.Type = "prefeitura_token"
+	}
+	if credential.Name == "" {
+		credential.Name = "Test credential"
+	}
+	if _, err := database.DB.NewInsert().Model(credential).Exec(ctx); err != nil {
+		t.Fatalf("failed to create credential: %v", err)
+	}
+	t.Cleanup(func() {
+		database.DB.NewDelete().Model((*models.CompanyCredential)(nil)).Where("id = ?", credential.ID).Exec(ctx)
+	})
+	return credential
+}
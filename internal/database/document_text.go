@@ -0,0 +1,184 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/uptrace/bun"
+	"github.com/zoomxml/internal/models"
+)
+
+// documentTextBatchSize is the number of documents re-read per batch by rebackfillDocumentText
+const documentTextBatchSize = 500
+
+// escapedTextPattern matches the XML entities and JSON escapes left in the description
+// and addresses by the SQL backfills of migrations 035 and 040
+const escapedTextPattern = `&(#[0-9]+|#x[0-9a-fA-F]+|[a-zA-Z]+);|\\[nrt"\\/bfu]`
+
+// documentText is the text of an NFSe stored in columns of its own: the service
+// description and the taker and provider addresses
+type documentText struct {
+	Discriminacao string
+	Taker         *models.Address
+	Provider      *models.Address
+}
+
+// rebackfillDocumentText extracts again, decoding it with the XML parser, the text of the
+// NFSe documents whose description or addresses were backfilled with escapes still in
+// them. Documents are read in batches by id, soft-deleted ones included.
+func rebackfillDocumentText(ctx context.Context, db *bun.DB) error {
+	var lastID int64
+	for {
+		var rows []struct {
+			ID  int64  `bun:"id"`
+			XML string `bun:"xml"`
+		}
+		err := db.NewRaw(`SELECT id, metadata #>> '{}' AS xml FROM documents
+			WHERE type = 'nfse' AND metadata IS NOT NULL AND id > ?
+			AND (discriminacao ~ ? OR taker_address::text ~ ? OR provider_address::text ~ ?)
+			ORDER BY id LIMIT ?`,
+			lastID, escapedTextPattern, escapedTextPattern, escapedTextPattern, documentTextBatchSize).
+			Scan(ctx, &rows)
+		if err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+
+		for _, row := range rows {
+			lastID = row.ID
+			text, err := extractDocumentText(row.XML)
+			if err != nil {
+				// An unreadable XML keeps the values of the SQL backfill
+				continue
+			}
+
+			taker, err := addressJSON(text.Taker)
+			if err != nil {
+				return err
+			}
+			provider, err := addressJSON(text.Provider)
+			if err != nil {
+				return err
+			}
+
+			_, err = db.ExecContext(ctx, `UPDATE documents SET discriminacao = NULLIF(?, ''),
+				taker_address = ?::jsonb, provider_address = ?::jsonb WHERE id = ?`,
+				text.Discriminacao, taker, provider, row.ID)
+			if err != nil {
+				return fmt.Errorf("failed to update document %d: %w", row.ID, err)
+			}
+		}
+	}
+}
+
+// addressJSON encodes an address for a jsonb column, nil when there is none
+func addressJSON(address *models.Address) (*string, error) {
+	if address == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(address)
+	if err != nil {
+		return nil, err
+	}
+	encoded := string(data)
+	return &encoded, nil
+}
+
+// extractDocumentText reads the description and the addresses of an NFSe XML, with
+// entities decoded. The stored XML is already UTF-8 whatever its declaration says.
+func extractDocumentText(xmlContent string) (documentText, error) {
+	decoder := xml.NewDecoder(strings.NewReader(xmlContent))
+	decoder.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+
+	var text documentText
+	var taker, provider models.Address
+	var path []string
+	var value strings.Builder
+	foundDescription := false
+
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return documentText{}, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			path = append(path, t.Name.Local)
+			value.Reset()
+		case xml.CharData:
+			value.Write(t)
+		case xml.EndElement:
+			field := strings.TrimSpace(value.String())
+			value.Reset()
+
+			if t.Name.Local == "Discriminacao" && !foundDescription {
+				text.Discriminacao, foundDescription = field, true
+			}
+			if n := len(path); n >= 3 && path[n-2] == "Endereco" {
+				switch path[n-3] {
+				case "TomadorServico":
+					setAddressField(&taker, t.Name.Local, field)
+				case "PrestadorServico":
+					setAddressField(&provider, t.Name.Local, field)
+				}
+			}
+			path = path[:len(path)-1]
+		}
+	}
+
+	text.Taker = finishAddress(taker)
+	text.Provider = finishAddress(provider)
+	return text, nil
+}
+
+// setAddressField stores one element of an Endereco block, the city from CodigoMunicipio
+// or, when missing, IBGE
+func setAddressField(address *models.Address, element, value string) {
+	if value == "" {
+		return
+	}
+	switch element {
+	case "Endereco":
+		address.Street = value
+	case "Numero":
+		address.Number = value
+	case "Complemento":
+		address.Complement = value
+	case "Bairro":
+		address.District = value
+	case "CodigoMunicipio":
+		address.CityCode = value
+	case "IBGE":
+		if address.CityCode == "" {
+			address.CityCode = value
+		}
+	case "Uf":
+		address.UF = strings.ToUpper(value)
+	case "Cep":
+		address.PostalCode = value
+	}
+}
+
+// finishAddress fills the UF from the city code and returns nil for an empty address
+func finishAddress(address models.Address) *models.Address {
+	if address.UF == "" {
+		address.UF = models.UFFromCityCode(address.CityCode)
+	}
+	if address.IsEmpty() {
+		return nil
+	}
+	return &address
+}
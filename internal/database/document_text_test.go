@@ -0,0 +1,44 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/zoomxml/internal/models"
+)
+
+func TestExtractDocumentText(t *testing.T) {
+	xmlContent := `<?xml version="1.0" encoding="ISO-8859-1"?>
+<ConsultarNfseResposta><ListaNfse><CompNfse><Nfse><InfNfse>
+<Servico><Discriminacao>Manutenção &amp; suporte
+"mensal"</Discriminacao></Servico>
+<PrestadorServico><Endereco><Endereco>Rua A &amp; B</Endereco><Numero>10</Numero><CodigoMunicipio>3550308</CodigoMunicipio></Endereco></PrestadorServico>
+<TomadorServico><Endereco><Endereco>Av. &#201;den</Endereco><IBGE>4106902</IBGE><Uf>pr</Uf><Cep>80000000</Cep></Endereco></TomadorServico>
+</InfNfse></Nfse></CompNfse></ListaNfse></ConsultarNfseResposta>`
+
+	text, err := extractDocumentText(xmlContent)
+	if err != nil {
+		t.Fatalf("extractDocumentText() error = %v", err)
+	}
+
+	if want := "Manutenção & suporte\n\"mensal\""; text.Discriminacao != want {
+		t.Errorf("Discriminacao = %q, want %q", text.Discriminacao, want)
+	}
+	wantProvider := models.Address{Street: "Rua A & B", Number: "10", CityCode: "3550308", UF: "SP"}
+	if text.Provider == nil || *text.Provider != wantProvider {
+		t.Errorf("Provider = %+v, want %+v", text.Provider, wantProvider)
+	}
+	wantTaker := models.Address{Street: "Av. Éden", CityCode: "4106902", UF: "PR", PostalCode: "80000000"}
+	if text.Taker == nil || *text.Taker != wantTaker {
+		t.Errorf("Taker = %+v, want %+v", text.Taker, wantTaker)
+	}
+}
+
+func TestExtractDocumentTextWithoutAddresses(t *testing.T) {
+	text, err := extractDocumentText(`<Nfse><Servico><Discriminacao> Consultoria </Discriminacao></Servico></Nfse>`)
+	if err != nil {
+		t.Fatalf("extractDocumentText() error = %v", err)
+	}
+	if text.Discriminacao != "Consultoria" || text.Taker != nil || text.Provider != nil {
+		t.Errorf("extractDocumentText() = %+v, want only the description", text)
+	}
+}
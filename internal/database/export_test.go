@@ -0,0 +1,14 @@
+package database
+
+// Unexported identifiers used by the tests of package database_test, which need
+// databasetest and so cannot live in package database
+var (
+	DocumentPartitionName    = documentPartitionName
+	MonthBounds              = monthBounds
+	PartitionableDate        = partitionableDate
+	QuoteIdentifiers         = quoteIdentifiers
+	SeedAdminUserWith        = seedAdminUser
+	DevelopmentAdminPassword = developmentAdminPassword
+	PendingMigrationNames    = pendingMigrationNames
+	ExpectedIndexes          = expectedIndexes
+)
@@ -3,7 +3,11 @@ package database
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strings"
+
 	"github.com/zoomxml/internal/logger"
+	"github.com/zoomxml/internal/models"
 
 	"github.com/uptrace/bun"
 )
@@ -20,10 +24,12 @@ type Migration struct {
 // MigrationFunc represents a migration function
 type MigrationFunc func(ctx context.Context, db *bun.DB) error
 
-// MigrationItem represents a migration with its function
+// MigrationItem represents a migration with its function. Indexes are the idempotent
+// CREATE INDEX statements run after Up; EnsureIndexes recreates them when they go missing.
 type MigrationItem struct {
-	Name string
-	Up   MigrationFunc
+	Name    string
+	Up      MigrationFunc
+	Indexes []string
 }
 
 // GetMigrations returns all available migrations
@@ -55,9 +61,304 @@ func GetMigrations() []MigrationItem {
 		},
 		{
 			Name: "007_create_indexes",
-			Up:   createIndexes,
+			Indexes: []string{
+				"CREATE INDEX IF NOT EXISTS idx_users_email ON users(email)",
+				"CREATE INDEX IF NOT EXISTS idx_users_role ON users(role)",
+				"CREATE INDEX IF NOT EXISTS idx_users_active ON users(active)",
+				"CREATE INDEX IF NOT EXISTS idx_companies_cnpj ON companies(cnpj)",
+				"CREATE INDEX IF NOT EXISTS idx_companies_restricted ON companies(restricted)",
+				"CREATE INDEX IF NOT EXISTS idx_companies_active ON companies(active)",
+				"CREATE INDEX IF NOT EXISTS idx_company_members_user_id ON company_members(user_id)",
+				"CREATE INDEX IF NOT EXISTS idx_company_members_company_id ON company_members(company_id)",
+				"CREATE INDEX IF NOT EXISTS idx_company_credentials_company_id ON company_credentials(company_id)",
+				"CREATE INDEX IF NOT EXISTS idx_company_credentials_type ON company_credentials(type)",
+				"CREATE INDEX IF NOT EXISTS idx_documents_company_id ON documents(company_id)",
+				"CREATE INDEX IF NOT EXISTS idx_documents_type ON documents(type)",
+				"CREATE INDEX IF NOT EXISTS idx_documents_status ON documents(status)",
+				"CREATE INDEX IF NOT EXISTS idx_documents_key ON documents(key)",
+				"CREATE INDEX IF NOT EXISTS idx_documents_issue_date ON documents(issue_date)",
+				"CREATE INDEX IF NOT EXISTS idx_audit_logs_actor_id ON audit_logs(actor_id)",
+				"CREATE INDEX IF NOT EXISTS idx_audit_logs_entity ON audit_logs(entity)",
+				"CREATE INDEX IF NOT EXISTS idx_audit_logs_created_at ON audit_logs(created_at)",
+			},
+		},
+		{
+			Name: "008_add_nfse_document_columns",
+			Up:   addNFSeDocumentColumns,
+			Indexes: []string{
+				"CREATE INDEX IF NOT EXISTS idx_documents_verification_code ON documents(company_id, verification_code)",
+				"CREATE INDEX IF NOT EXISTS idx_documents_provider_number ON documents(company_id, provider_cnpj, number)",
+				"CREATE INDEX IF NOT EXISTS idx_documents_document_hash ON documents(company_id, document_hash)",
+			},
+		},
+		{
+			Name: "009_add_company_sync_status",
+			Up:   addCompanySyncStatus,
+		},
+		{
+			Name: "010_create_processing_logs_table",
+			Up:   createProcessingLogsTable,
+			Indexes: []string{
+				"CREATE INDEX IF NOT EXISTS idx_processing_logs_company_id ON processing_logs(company_id, created_at)",
+				"CREATE INDEX IF NOT EXISTS idx_processing_logs_batch_id ON processing_logs(batch_id)",
+			},
+		},
+		{
+			Name: "011_add_document_service_filters",
+			Up:   addDocumentServiceFilters,
+			Indexes: []string{
+				"CREATE INDEX IF NOT EXISTS idx_documents_service_code ON documents(company_id, service_code)",
+				"CREATE INDEX IF NOT EXISTS idx_documents_natureza_operacao ON documents(company_id, natureza_operacao)",
+			},
+		},
+		{
+			Name: "012_add_company_debug_capture",
+			Up:   addCompanyDebugCapture,
+		},
+		{
+			Name: "013_add_document_soft_delete",
+			Up:   addDocumentSoftDelete,
+			Indexes: []string{
+				"CREATE INDEX IF NOT EXISTS idx_documents_deleted_at ON documents(deleted_at)",
+			},
+		},
+		{
+			Name: "014_add_company_provider_base_url",
+			Up:   addCompanyProviderBaseURL,
+		},
+		{
+			Name: "015_add_document_tags",
+			Up:   addDocumentTags,
+			Indexes: []string{
+				"CREATE INDEX IF NOT EXISTS idx_documents_tags ON documents USING GIN (tags)",
+			},
+		},
+		{
+			Name: "016_add_zero_value_policy",
+			Up:   addZeroValuePolicy,
+		},
+		{
+			Name: "017_create_pending_ingests_table",
+			Up:   createPendingIngestsTable,
+			Indexes: []string{
+				"CREATE INDEX IF NOT EXISTS idx_pending_ingests_company_id ON pending_ingests(company_id, id)",
+			},
+		},
+		{
+			Name: "018_add_credential_last_used",
+			Up:   addCredentialLastUsed,
+		},
+		{
+			Name: "019_add_retention_policy",
+			Up:   addRetentionPolicy,
+		},
+		{
+			Name: "020_add_document_rps",
+			Up:   addDocumentRps,
+			Indexes: []string{
+				"CREATE INDEX IF NOT EXISTS idx_documents_rps ON documents(company_id, rps_number, rps_series)",
+			},
+		},
+		{
+			Name: "021_add_cnpj_match_policy",
+			Up:   addCNPJMatchPolicy,
 		},
+		{
+			Name: "022_create_reprocess_batches_table",
+			Up:   createReprocessBatchesTable,
+			Indexes: []string{
+				"CREATE UNIQUE INDEX IF NOT EXISTS idx_reprocess_batches_active ON reprocess_batches(company_id) WHERE status IN ('pending', 'running')",
+			},
+		},
+		{
+			Name: "023_add_company_registration_check",
+			Up:   addCompanyRegistrationCheck,
+		},
+		{
+			Name: "024_add_company_default_environment",
+			Up:   addCompanyDefaultEnvironment,
+		},
+		{
+			Name: "025_backfill_document_series",
+			Up:   backfillDocumentSeries,
+		},
+		{
+			Name: "026_create_dead_letters_table",
+			Up:   createDeadLettersTable,
+			Indexes: []string{
+				"CREATE INDEX IF NOT EXISTS idx_dead_letters_company_id ON dead_letters(company_id, status, id)",
+			},
+		},
+		{
+			Name: "027_add_company_resync_pending",
+			Up:   addCompanyResyncPending,
+		},
+		{
+			Name: "028_add_company_validation_rules",
+			Up:   addCompanyValidationRules,
+		},
+		{
+			Name: "029_add_credential_failure_count",
+			Up:   addCredentialFailureCount,
+		},
+		{
+			Name: "030_add_company_document_limit",
+			Up:   addCompanyDocumentLimit,
+		},
+		{
+			Name: "031_add_company_metadata_only",
+			Up:   addCompanyMetadataOnly,
+		},
+		{
+			Name: "032_add_document_content_hash",
+			Up:   addDocumentContentHash,
+			Indexes: []string{
+				"CREATE INDEX IF NOT EXISTS idx_documents_content_hash ON documents(company_id, content_hash)",
+			},
+		},
+		{
+			Name: "033_create_export_tables",
+			Up:   createExportTables,
+			Indexes: []string{
+				"CREATE UNIQUE INDEX IF NOT EXISTS idx_export_jobs_active ON export_jobs(company_id) WHERE status IN ('pending', 'running')",
+			},
+		},
+		{
+			Name: "034_create_competence_closures",
+			Up:   createCompetenceClosures,
+			Indexes: []string{
+				"CREATE INDEX IF NOT EXISTS idx_documents_late_arrival ON documents(company_id) WHERE late_arrival = true",
+			},
+		},
+		{
+			Name: "035_add_document_addresses",
+			Up:   addDocumentAddresses,
+		},
+		{
+			Name: "036_create_integrity_checks",
+			Up:   createIntegrityChecks,
+			Indexes: []string{
+				"CREATE INDEX IF NOT EXISTS idx_integrity_checks_company_id ON integrity_checks(company_id, id)",
+			},
+		},
+		{
+			Name: "037_add_credential_disabled_reason",
+			Up:   addCredentialDisabledReason,
+		},
+		{
+			Name: "038_add_document_iss_value",
+			Up:   addDocumentIssValue,
+		},
+		{
+			Name: "039_add_company_municipality_code",
+			Up:   addCompanyMunicipalityCode,
+		},
+		{
+			Name: "040_add_document_search",
+			Up:   addDocumentSearch,
+			Indexes: []string{
+				"CREATE INDEX IF NOT EXISTS idx_documents_discriminacao_fts ON documents USING GIN (to_tsvector('portuguese', COALESCE(discriminacao, '')))",
+				"CREATE INDEX IF NOT EXISTS idx_documents_number ON documents(company_id, number)",
+				"CREATE INDEX IF NOT EXISTS idx_documents_taker_cnpj ON documents(company_id, taker_cnpj)",
+				"CREATE INDEX IF NOT EXISTS idx_documents_company_issue_date ON documents(company_id, issue_date)",
+			},
+		},
+		{
+			Name: "041_create_refresh_tokens",
+			Up:   createRefreshTokens,
+			Indexes: []string{
+				"CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user_id ON refresh_tokens(user_id) WHERE revoked_at IS NULL",
+			},
+		},
+		{
+			Name: "042_add_company_sync_schedule",
+			Up:   addCompanySyncSchedule,
+		},
+		{
+			Name: "043_add_pending_ingest_competence",
+			Up:   addPendingIngestCompetence,
+		},
+		{
+			Name: "044_add_refresh_token_access_tokens",
+			Up:   addRefreshTokenAccessTokens,
+			Indexes: []string{
+				"CREATE UNIQUE INDEX IF NOT EXISTS idx_refresh_tokens_access_token_hash ON refresh_tokens(access_token_hash)",
+			},
+		},
+		{
+			Name: "045_add_document_competence_month",
+			Up:   addDocumentCompetenceMonth,
+			Indexes: []string{
+				"CREATE INDEX IF NOT EXISTS idx_documents_competence_month ON documents(company_id, competence_month)",
+			},
+		},
+		{
+			Name: "046_add_company_auto_fetch_paused",
+			Up:   addCompanyAutoFetchPaused,
+		},
+		{
+			Name: "047_rebackfill_document_text",
+			Up:   rebackfillDocumentText,
+		},
+		{
+			Name: "048_add_company_registration_failures",
+			Up:   addCompanyRegistrationFailures,
+		},
+		{
+			Name: "049_add_job_leases",
+			Up:   addJobLeases,
+		},
+		{
+			Name: "050_add_documents_verification_code_normalized_index",
+			Indexes: []string{
+				"CREATE INDEX IF NOT EXISTS idx_documents_verification_code_normalized ON documents(company_id, (upper(regexp_replace(verification_code, '[^[:alnum:]]', '', 'g'))))",
+			},
+		},
+		{
+			Name: "051_add_document_original_file_name",
+			Up:   addDocumentOriginalFileName,
+		},
+	}
+}
+
+// PendingMigrations returns, in order, the names of the migrations of this binary not yet
+// applied to the database. A database without the migrations table has all of them pending.
+func PendingMigrations(ctx context.Context) ([]string, error) {
+	var tableExists bool
+	err := DB.NewRaw("SELECT to_regclass('migrations') IS NOT NULL").Scan(ctx, &tableExists)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check migrations table: %w", err)
+	}
+
+	var applied []string
+	if tableExists {
+		err := DB.NewSelect().
+			Model((*Migration)(nil)).
+			Column("name").
+			Scan(ctx, &applied)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load applied migrations: %w", err)
+		}
+	}
+
+	return pendingMigrationNames(GetMigrations(), applied), nil
+}
+
+// pendingMigrationNames returns, in order, the names of migrations missing from applied.
+// Applied names unknown to this binary, from a newer one, are ignored.
+func pendingMigrationNames(migrations []MigrationItem, applied []string) []string {
+	done := make(map[string]bool, len(applied))
+	for _, name := range applied {
+		done[name] = true
 	}
+
+	pending := []string{}
+	for _, migration := range migrations {
+		if !done[migration.Name] {
+			pending = append(pending, migration.Name)
+		}
+	}
+	return pending
 }
 
 // RunMigrations executes all pending migrations
@@ -86,8 +387,16 @@ func RunMigrations(ctx context.Context) error {
 
 		// Run migration
 		logger.Printf("Running migration: %s", migration.Name)
-		if err := migration.Up(ctx, DB); err != nil {
-			return fmt.Errorf("failed to run migration %s: %w", migration.Name, err)
+		if migration.Up != nil {
+			if err := migration.Up(ctx, DB); err != nil {
+				return fmt.Errorf("failed to run migration %s: %w", migration.Name, err)
+			}
+		}
+
+		for _, statement := range migration.Indexes {
+			if _, err := DB.ExecContext(ctx, statement); err != nil {
+				return fmt.Errorf("failed to run migration %s: %w", migration.Name, err)
+			}
 		}
 
 		// Record migration as applied
@@ -225,30 +534,761 @@ func createAuditLogsTable(ctx context.Context, db *bun.DB) error {
 	return err
 }
 
-func createIndexes(ctx context.Context, db *bun.DB) error {
-	indexes := []string{
-		"CREATE INDEX IF NOT EXISTS idx_users_email ON users(email)",
-		"CREATE INDEX IF NOT EXISTS idx_users_role ON users(role)",
-		"CREATE INDEX IF NOT EXISTS idx_users_active ON users(active)",
-		"CREATE INDEX IF NOT EXISTS idx_companies_cnpj ON companies(cnpj)",
-		"CREATE INDEX IF NOT EXISTS idx_companies_restricted ON companies(restricted)",
-		"CREATE INDEX IF NOT EXISTS idx_companies_active ON companies(active)",
-		"CREATE INDEX IF NOT EXISTS idx_company_members_user_id ON company_members(user_id)",
-		"CREATE INDEX IF NOT EXISTS idx_company_members_company_id ON company_members(company_id)",
-		"CREATE INDEX IF NOT EXISTS idx_company_credentials_company_id ON company_credentials(company_id)",
-		"CREATE INDEX IF NOT EXISTS idx_company_credentials_type ON company_credentials(type)",
-		"CREATE INDEX IF NOT EXISTS idx_documents_company_id ON documents(company_id)",
-		"CREATE INDEX IF NOT EXISTS idx_documents_type ON documents(type)",
-		"CREATE INDEX IF NOT EXISTS idx_documents_status ON documents(status)",
-		"CREATE INDEX IF NOT EXISTS idx_documents_key ON documents(key)",
-		"CREATE INDEX IF NOT EXISTS idx_documents_issue_date ON documents(issue_date)",
-		"CREATE INDEX IF NOT EXISTS idx_audit_logs_actor_id ON audit_logs(actor_id)",
-		"CREATE INDEX IF NOT EXISTS idx_audit_logs_entity ON audit_logs(entity)",
-		"CREATE INDEX IF NOT EXISTS idx_audit_logs_created_at ON audit_logs(created_at)",
-	}
-
-	for _, indexSQL := range indexes {
-		if _, err := db.ExecContext(ctx, indexSQL); err != nil {
+// addNFSeDocumentColumns adds the NFSe columns written by the parser that were missing
+// from the original documents table
+func addNFSeDocumentColumns(ctx context.Context, db *bun.DB) error {
+	statements := []string{
+		"ALTER TABLE documents ADD COLUMN IF NOT EXISTS verification_code VARCHAR(255)",
+		"ALTER TABLE documents ADD COLUMN IF NOT EXISTS provider_cnpj VARCHAR(18)",
+		"ALTER TABLE documents ADD COLUMN IF NOT EXISTS taker_cnpj VARCHAR(18)",
+		"ALTER TABLE documents ADD COLUMN IF NOT EXISTS service_value DECIMAL(15,2)",
+		"ALTER TABLE documents ADD COLUMN IF NOT EXISTS service_code VARCHAR(50)",
+		"ALTER TABLE documents ADD COLUMN IF NOT EXISTS municipal_registration VARCHAR(50)",
+		"ALTER TABLE documents ADD COLUMN IF NOT EXISTS document_hash VARCHAR(64)",
+		"ALTER TABLE documents ADD COLUMN IF NOT EXISTS is_cancelled BOOLEAN DEFAULT false",
+		"ALTER TABLE documents ADD COLUMN IF NOT EXISTS is_substituted BOOLEAN DEFAULT false",
+		"ALTER TABLE documents ADD COLUMN IF NOT EXISTS processing_date TIMESTAMP",
+		"ALTER TABLE documents ADD COLUMN IF NOT EXISTS competence VARCHAR(50)",
+		"ALTER TABLE documents ADD COLUMN IF NOT EXISTS rps_issue_date TIMESTAMP",
+		"ALTER TABLE documents ADD COLUMN IF NOT EXISTS taker_name VARCHAR(255)",
+		"ALTER TABLE documents ADD COLUMN IF NOT EXISTS provider_name VARCHAR(255)",
+		"ALTER TABLE documents ADD COLUMN IF NOT EXISTS provider_trade_name VARCHAR(255)",
+	}
+
+	for _, statement := range statements {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addCompanySyncStatus adds the last sync tracking columns to companies
+func addCompanySyncStatus(ctx context.Context, db *bun.DB) error {
+	statements := []string{
+		"ALTER TABLE companies ADD COLUMN IF NOT EXISTS last_sync_at TIMESTAMP",
+		"ALTER TABLE companies ADD COLUMN IF NOT EXISTS last_sync_status VARCHAR(50)",
+		"ALTER TABLE companies ADD COLUMN IF NOT EXISTS last_sync_error TEXT",
+	}
+
+	for _, statement := range statements {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// createProcessingLogsTable creates the table that stores one row per ingest batch
+func createProcessingLogsTable(ctx context.Context, db *bun.DB) error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS processing_logs (
+			id SERIAL PRIMARY KEY,
+			company_id INTEGER NOT NULL REFERENCES companies(id) ON DELETE CASCADE,
+			batch_id VARCHAR(36) NOT NULL,
+			operation VARCHAR(100) NOT NULL,
+			source VARCHAR(100),
+			target VARCHAR(255),
+			status VARCHAR(50) NOT NULL,
+			message TEXT,
+			total_documents INTEGER DEFAULT 0,
+			processed_documents INTEGER DEFAULT 0,
+			duplicate_documents INTEGER DEFAULT 0,
+			error_documents INTEGER DEFAULT 0,
+			duration_ms BIGINT DEFAULT 0,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+	}
+
+	for _, statement := range statements {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addDocumentServiceFilters adds natureza_operacao and indexes the columns used by the
+// service type filters of the document search
+func addDocumentServiceFilters(ctx context.Context, db *bun.DB) error {
+	statements := []string{
+		"ALTER TABLE documents ADD COLUMN IF NOT EXISTS natureza_operacao VARCHAR(10)",
+	}
+
+	for _, statement := range statements {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addCompanyDebugCapture adds the per-company raw provider response capture flag
+func addCompanyDebugCapture(ctx context.Context, db *bun.DB) error {
+	_, err := db.ExecContext(ctx, "ALTER TABLE companies ADD COLUMN IF NOT EXISTS debug_capture BOOLEAN NOT NULL DEFAULT false")
+	return err
+}
+
+// addDocumentSoftDelete adds deleted_at so documents can be soft-deleted
+func addDocumentSoftDelete(ctx context.Context, db *bun.DB) error {
+	statements := []string{
+		"ALTER TABLE documents ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP",
+	}
+
+	for _, statement := range statements {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addCompanyProviderBaseURL adds the per-company NFSe provider base URL override
+func addCompanyProviderBaseURL(ctx context.Context, db *bun.DB) error {
+	_, err := db.ExecContext(ctx, "ALTER TABLE companies ADD COLUMN IF NOT EXISTS provider_base_url VARCHAR(255)")
+	return err
+}
+
+// addDocumentTags adds user tags to documents, indexed for tag filtering
+func addDocumentTags(ctx context.Context, db *bun.DB) error {
+	statements := []string{
+		"ALTER TABLE documents ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}'",
+	}
+
+	for _, statement := range statements {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addZeroValuePolicy adds the per-company zero-value note policy and the document flag
+func addZeroValuePolicy(ctx context.Context, db *bun.DB) error {
+	statements := []string{
+		"ALTER TABLE companies ADD COLUMN IF NOT EXISTS zero_value_policy VARCHAR(10) NOT NULL DEFAULT 'flag'",
+		"ALTER TABLE documents ADD COLUMN IF NOT EXISTS zero_value_flagged BOOLEAN NOT NULL DEFAULT false",
+	}
+
+	for _, statement := range statements {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// createPendingIngestsTable creates the buffer of fetched XMLs awaiting storage
+func createPendingIngestsTable(ctx context.Context, db *bun.DB) error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS pending_ingests (
+			id SERIAL PRIMARY KEY,
+			company_id INTEGER NOT NULL REFERENCES companies(id) ON DELETE CASCADE,
+			file_name VARCHAR(255) NOT NULL,
+			xml_content TEXT NOT NULL,
+			source VARCHAR(100),
+			attempts INTEGER NOT NULL DEFAULT 0,
+			last_error TEXT,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+	}
+
+	for _, statement := range statements {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addCredentialLastUsed records when a credential was last used to fetch from the provider
+func addCredentialLastUsed(ctx context.Context, db *bun.DB) error {
+	_, err := db.ExecContext(ctx, "ALTER TABLE company_credentials ADD COLUMN IF NOT EXISTS last_used_at TIMESTAMP")
+	return err
+}
+
+// addRetentionPolicy adds the per-company retention period and the legal hold flags
+func addRetentionPolicy(ctx context.Context, db *bun.DB) error {
+	statements := []string{
+		"ALTER TABLE companies ADD COLUMN IF NOT EXISTS retention_months INTEGER NOT NULL DEFAULT 0",
+		"ALTER TABLE companies ADD COLUMN IF NOT EXISTS legal_hold BOOLEAN NOT NULL DEFAULT false",
+		"ALTER TABLE documents ADD COLUMN IF NOT EXISTS legal_hold BOOLEAN NOT NULL DEFAULT false",
+	}
+
+	for _, statement := range statements {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addDocumentRps stores the RPS (número/série/tipo) that originated each NFSe
+func addDocumentRps(ctx context.Context, db *bun.DB) error {
+	statements := []string{
+		"ALTER TABLE documents ADD COLUMN IF NOT EXISTS rps_number VARCHAR(50)",
+		"ALTER TABLE documents ADD COLUMN IF NOT EXISTS rps_series VARCHAR(20)",
+		"ALTER TABLE documents ADD COLUMN IF NOT EXISTS rps_type VARCHAR(10)",
+	}
+
+	for _, statement := range statements {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addCNPJMatchPolicy adds the per-company check that ingested notes belong to the company
+func addCNPJMatchPolicy(ctx context.Context, db *bun.DB) error {
+	statements := []string{
+		"ALTER TABLE companies ADD COLUMN IF NOT EXISTS cnpj_match_policy VARCHAR(10) NOT NULL DEFAULT 'off'",
+		"ALTER TABLE documents ADD COLUMN IF NOT EXISTS cnpj_mismatch_flagged BOOLEAN NOT NULL DEFAULT false",
+	}
+
+	for _, statement := range statements {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// createReprocessBatchesTable creates the progress table of company-wide reprocessing.
+// The partial unique index keeps at most one unfinished batch per company.
+func createReprocessBatchesTable(ctx context.Context, db *bun.DB) error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS reprocess_batches (
+			id SERIAL PRIMARY KEY,
+			company_id INTEGER NOT NULL REFERENCES companies(id) ON DELETE CASCADE,
+			requested_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+			status VARCHAR(20) NOT NULL DEFAULT 'pending',
+			total INTEGER NOT NULL DEFAULT 0,
+			processed INTEGER NOT NULL DEFAULT 0,
+			failed INTEGER NOT NULL DEFAULT 0,
+			last_document_id BIGINT NOT NULL DEFAULT 0,
+			last_error TEXT,
+			finished_at TIMESTAMP,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+	}
+
+	for _, statement := range statements {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addCompanyRegistrationCheck records the periodic re-query of company registration data
+func addCompanyRegistrationCheck(ctx context.Context, db *bun.DB) error {
+	statements := []string{
+		"ALTER TABLE companies ADD COLUMN IF NOT EXISTS registration_inactive BOOLEAN NOT NULL DEFAULT false",
+		"ALTER TABLE companies ADD COLUMN IF NOT EXISTS registration_checked_at TIMESTAMP",
+	}
+
+	for _, statement := range statements {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addCompanyDefaultEnvironment adds the environment expected of a company's credentials
+func addCompanyDefaultEnvironment(ctx context.Context, db *bun.DB) error {
+	_, err := db.ExecContext(ctx, "ALTER TABLE companies ADD COLUMN IF NOT EXISTS default_environment VARCHAR(20) NOT NULL DEFAULT 'production'")
+	return err
+}
+
+// backfillDocumentSeries fills the series of existing NFSe documents, now part of the
+// deduplication composite key. The RPS series is used when it was parsed; older rows
+// fall back to the first Serie element of the stored XML.
+func backfillDocumentSeries(ctx context.Context, db *bun.DB) error {
+	statements := []string{
+		`UPDATE documents SET series = rps_series
+		WHERE type = 'nfse' AND COALESCE(series, '') = '' AND COALESCE(rps_series, '') <> ''`,
+		`UPDATE documents SET series = btrim(substring((metadata #>> '{}') FROM '<(?:[A-Za-z0-9_]+:)?Serie>([^<]+)</'))
+		WHERE type = 'nfse' AND COALESCE(series, '') = '' AND metadata IS NOT NULL`,
+	}
+
+	for _, statement := range statements {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// createDeadLettersTable records XMLs that permanently failed parsing or validation
+func createDeadLettersTable(ctx context.Context, db *bun.DB) error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS dead_letters (
+			id SERIAL PRIMARY KEY,
+			company_id INTEGER NOT NULL REFERENCES companies(id) ON DELETE CASCADE,
+			file_name VARCHAR(255) NOT NULL,
+			storage_key VARCHAR(500) NOT NULL,
+			source VARCHAR(100),
+			competence VARCHAR(20),
+			error TEXT,
+			status VARCHAR(20) NOT NULL DEFAULT 'pending',
+			attempts INTEGER NOT NULL DEFAULT 0,
+			reprocessed_document_id BIGINT,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+	}
+
+	for _, statement := range statements {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addCompanyResyncPending lets operators reset the sync watermark of a company
+func addCompanyResyncPending(ctx context.Context, db *bun.DB) error {
+	_, err := db.ExecContext(ctx, "ALTER TABLE companies ADD COLUMN IF NOT EXISTS resync_pending BOOLEAN NOT NULL DEFAULT false")
+	return err
+}
+
+// addCompanyValidationRules stores the business rules applied to the documents of a company
+func addCompanyValidationRules(ctx context.Context, db *bun.DB) error {
+	_, err := db.ExecContext(ctx, "ALTER TABLE companies ADD COLUMN IF NOT EXISTS validation_rules JSONB")
+	return err
+}
+
+// addCredentialFailureCount counts consecutive provider rejections of a credential
+func addCredentialFailureCount(ctx context.Context, db *bun.DB) error {
+	_, err := db.ExecContext(ctx, "ALTER TABLE company_credentials ADD COLUMN IF NOT EXISTS failure_count INTEGER NOT NULL DEFAULT 0")
+	return err
+}
+
+// addCompanyDocumentLimit caps the documents of a company (e.g. trials)
+func addCompanyDocumentLimit(ctx context.Context, db *bun.DB) error {
+	statements := []string{
+		"ALTER TABLE companies ADD COLUMN IF NOT EXISTS document_limit INTEGER NOT NULL DEFAULT 0",
+		"ALTER TABLE companies ADD COLUMN IF NOT EXISTS document_limit_policy VARCHAR(20) NOT NULL DEFAULT 'reject'",
+	}
+
+	for _, statement := range statements {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addCompanyMetadataOnly lets companies keep only the parsed fields of their notes
+func addCompanyMetadataOnly(ctx context.Context, db *bun.DB) error {
+	_, err := db.ExecContext(ctx, "ALTER TABLE companies ADD COLUMN IF NOT EXISTS metadata_only BOOLEAN NOT NULL DEFAULT false")
+	return err
+}
+
+// addDocumentContentHash stores the SHA-256 of the raw XML for exact duplicate checks
+func addDocumentContentHash(ctx context.Context, db *bun.DB) error {
+	statements := []string{
+		"ALTER TABLE documents ADD COLUMN IF NOT EXISTS content_hash VARCHAR(64)",
+	}
+
+	for _, statement := range statements {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// createExportTables stores the S3 buckets companies mirror their XMLs to and the copy jobs.
+// The partial unique index keeps at most one unfinished job per company.
+func createExportTables(ctx context.Context, db *bun.DB) error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS company_export_targets (
+			id SERIAL PRIMARY KEY,
+			company_id INTEGER NOT NULL UNIQUE REFERENCES companies(id) ON DELETE CASCADE,
+			endpoint VARCHAR(255) NOT NULL,
+			region VARCHAR(50),
+			bucket VARCHAR(255) NOT NULL,
+			prefix VARCHAR(255),
+			use_ssl BOOLEAN NOT NULL DEFAULT true,
+			access_key VARCHAR(255) NOT NULL,
+			encrypted_secret_key TEXT NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS export_jobs (
+			id SERIAL PRIMARY KEY,
+			company_id INTEGER NOT NULL REFERENCES companies(id) ON DELETE CASCADE,
+			requested_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+			status VARCHAR(20) NOT NULL DEFAULT 'pending',
+			total INTEGER NOT NULL DEFAULT 0,
+			copied INTEGER NOT NULL DEFAULT 0,
+			skipped INTEGER NOT NULL DEFAULT 0,
+			failed INTEGER NOT NULL DEFAULT 0,
+			last_document_id BIGINT NOT NULL DEFAULT 0,
+			last_error TEXT,
+			finished_at TIMESTAMP,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+	}
+
+	for _, statement := range statements {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// createCompetenceClosures stores the competências companies marked as closed and flags the
+// notes that arrive for them afterwards
+func createCompetenceClosures(ctx context.Context, db *bun.DB) error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS competence_closures (
+			id SERIAL PRIMARY KEY,
+			company_id INTEGER NOT NULL REFERENCES companies(id) ON DELETE CASCADE,
+			competence VARCHAR(7) NOT NULL,
+			closed_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (company_id, competence)
+		)`,
+		"ALTER TABLE documents ADD COLUMN IF NOT EXISTS late_arrival BOOLEAN NOT NULL DEFAULT false",
+	}
+
+	for _, statement := range statements {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addDocumentAddresses stores the taker and provider addresses of NFSe documents as jsonb
+// and backfills them from the XML kept in the metadata column. The UF falls back to the
+// one of the IBGE city code, as the parser does.
+func addDocumentAddresses(ctx context.Context, db *bun.DB) error {
+	statements := []string{
+		"ALTER TABLE documents ADD COLUMN IF NOT EXISTS taker_address JSONB",
+		"ALTER TABLE documents ADD COLUMN IF NOT EXISTS provider_address JSONB",
+		backfillAddressSQL("taker_address", "TomadorServico"),
+		backfillAddressSQL("provider_address", "PrestadorServico"),
+	}
+
+	for _, statement := range statements {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// backfillAddressSQL builds the update that extracts the Endereco block of a party
+// element from the stored XML into an address column
+func backfillAddressSQL(column, party string) string {
+	element := func(name string) string {
+		return fmt.Sprintf("NULLIF(btrim(substring(block FROM '<(?:[A-Za-z0-9_]+:)?%s>([^<]*)</')), '')", name)
+	}
+
+	prefixes := make([]string, 0, len(models.UFByIBGEPrefix))
+	for prefix := range models.UFByIBGEPrefix {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+
+	var ufByCity strings.Builder
+	ufByCity.WriteString("CASE left(f.city_code, 2)")
+	for _, prefix := range prefixes {
+		fmt.Fprintf(&ufByCity, " WHEN '%s' THEN '%s'", prefix, models.UFByIBGEPrefix[prefix])
+	}
+	ufByCity.WriteString(" END")
+
+	return fmt.Sprintf(`WITH blocks AS (
+		SELECT id, substring(
+			substring((metadata #>> '{}') FROM '<(?:[A-Za-z0-9_]+:)?%[2]s>(.*)</(?:[A-Za-z0-9_]+:)?%[2]s>')
+			FROM '<(?:[A-Za-z0-9_]+:)?Endereco>(.*)</(?:[A-Za-z0-9_]+:)?Endereco>') AS block
+		FROM documents
+		WHERE type = 'nfse' AND %[1]s IS NULL AND metadata IS NOT NULL
+	), fields AS (
+		SELECT id, %[3]s AS street, %[4]s AS number, %[5]s AS complement, %[6]s AS district,
+			COALESCE(%[7]s, %[8]s) AS city_code, upper(%[9]s) AS uf, %[10]s AS postal_code
+		FROM blocks
+		WHERE block IS NOT NULL
+	)
+	UPDATE documents d SET %[1]s = NULLIF(jsonb_strip_nulls(jsonb_build_object(
+		'street', f.street, 'number', f.number, 'complement', f.complement, 'district', f.district,
+		'city_code', f.city_code, 'uf', COALESCE(f.uf, %[11]s), 'postal_code', f.postal_code
+	)), '{}'::jsonb)
+	FROM fields f
+	WHERE d.id = f.id`,
+		column, party,
+		element("Endereco"), element("Numero"), element("Complemento"), element("Bairro"),
+		element("CodigoMunicipio"), element("IBGE"), element("Uf"), element("Cep"),
+		ufByCity.String(),
+	)
+}
+
+// createIntegrityChecks records the periodic comparisons of document rows against stored
+// XML objects per company
+func createIntegrityChecks(ctx context.Context, db *bun.DB) error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS integrity_checks (
+			id SERIAL PRIMARY KEY,
+			company_id INTEGER NOT NULL REFERENCES companies(id) ON DELETE CASCADE,
+			documents INTEGER NOT NULL DEFAULT 0,
+			objects INTEGER NOT NULL DEFAULT 0,
+			missing_objects INTEGER NOT NULL DEFAULT 0,
+			orphan_objects INTEGER NOT NULL DEFAULT 0,
+			drift INTEGER NOT NULL DEFAULT 0,
+			threshold INTEGER NOT NULL DEFAULT 0,
+			drift_detected BOOLEAN NOT NULL DEFAULT false,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+	}
+
+	for _, statement := range statements {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addCredentialDisabledReason records why a credential was deactivated automatically
+func addCredentialDisabledReason(ctx context.Context, db *bun.DB) error {
+	_, err := db.ExecContext(ctx, "ALTER TABLE company_credentials ADD COLUMN IF NOT EXISTS disabled_reason VARCHAR(255)")
+	return err
+}
+
+// addDocumentIssValue stores the ISS value of NFSe documents and backfills it from the
+// ValorIss element of the XML kept in the metadata column
+func addDocumentIssValue(ctx context.Context, db *bun.DB) error {
+	statements := []string{
+		"ALTER TABLE documents ADD COLUMN IF NOT EXISTS iss_value DECIMAL(15,2)",
+		`UPDATE documents SET iss_value = substring((metadata #>> '{}') FROM '<(?:[A-Za-z0-9_]+:)?ValorIss>\s*([0-9]+(?:\.[0-9]+)?)\s*</')::numeric
+		WHERE type = 'nfse' AND iss_value IS NULL AND metadata IS NOT NULL
+		AND (metadata #>> '{}') ~ '<(?:[A-Za-z0-9_]+:)?ValorIss>\s*[0-9]+(?:\.[0-9]+)?\s*</'`,
+	}
+
+	for _, statement := range statements {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addCompanyMunicipalityCode adds the IBGE code that selects the NFSe provider of a company
+func addCompanyMunicipalityCode(ctx context.Context, db *bun.DB) error {
+	_, err := db.ExecContext(ctx, "ALTER TABLE companies ADD COLUMN IF NOT EXISTS municipality_code VARCHAR(7)")
+	return err
+}
+
+// addDocumentSearch stores the service description (Discriminacao) of NFSe documents,
+// backfilled from the stored XML, and indexes the fields of the document search: a
+// Portuguese full-text index on the description and company-scoped indexes on number,
+// taker CNPJ and issue date
+func addDocumentSearch(ctx context.Context, db *bun.DB) error {
+	statements := []string{
+		"ALTER TABLE documents ADD COLUMN IF NOT EXISTS discriminacao TEXT",
+		`UPDATE documents SET discriminacao = NULLIF(btrim(substring((metadata #>> '{}') FROM '<(?:[A-Za-z0-9_]+:)?Discriminacao>([^<]*)</')), '')
+		WHERE type = 'nfse' AND discriminacao IS NULL AND metadata IS NOT NULL`,
+	}
+
+	for _, statement := range statements {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// createRefreshTokens stores the refresh tokens issued on login, by hash, with the token
+// each one was rotated into
+func createRefreshTokens(ctx context.Context, db *bun.DB) error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS refresh_tokens (
+			id SERIAL PRIMARY KEY,
+			user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			token_hash VARCHAR(64) UNIQUE NOT NULL,
+			expires_at TIMESTAMP NOT NULL,
+			revoked_at TIMESTAMP,
+			replaced_by_id INTEGER,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+	}
+
+	for _, statement := range statements {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addCompanySyncSchedule adds the per-company sync schedule: a cron expression, a daily
+// time window and when the scheduler last started a fetch for the company
+func addCompanySyncSchedule(ctx context.Context, db *bun.DB) error {
+	statements := []string{
+		"ALTER TABLE companies ADD COLUMN IF NOT EXISTS sync_cron VARCHAR(100)",
+		"ALTER TABLE companies ADD COLUMN IF NOT EXISTS sync_window_start VARCHAR(5)",
+		"ALTER TABLE companies ADD COLUMN IF NOT EXISTS sync_window_end VARCHAR(5)",
+		"ALTER TABLE companies ADD COLUMN IF NOT EXISTS last_scheduled_fetch_at TIMESTAMP",
+	}
+
+	for _, statement := range statements {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addPendingIngestCompetence keeps the provider competence of buffered XMLs, the fallback
+// used when the XML itself has none
+func addPendingIngestCompetence(ctx context.Context, db *bun.DB) error {
+	statements := []string{
+		"ALTER TABLE pending_ingests ADD COLUMN IF NOT EXISTS competence VARCHAR(7)",
+	}
+
+	for _, statement := range statements {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addRefreshTokenAccessTokens stores the short-lived access token issued with each refresh
+// token; revoking the refresh token also ends its access token
+func addRefreshTokenAccessTokens(ctx context.Context, db *bun.DB) error {
+	statements := []string{
+		"ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS access_token_hash VARCHAR(64)",
+		"ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS access_expires_at TIMESTAMP",
+	}
+
+	for _, statement := range statements {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addDocumentCompetenceMonth persists the normalized competência (YYYY-MM) of each document so
+// listings, reports and archives filter on the same value that names the storage folder. The
+// backfill mirrors NormalizeCompetence and falls back to the issue date, skipping zero dates.
+func addDocumentCompetenceMonth(ctx context.Context, db *bun.DB) error {
+	statements := []string{
+		"ALTER TABLE documents ADD COLUMN IF NOT EXISTS competence_month VARCHAR(7)",
+		`UPDATE documents SET competence_month = CASE
+			WHEN btrim(competence) ~ '^\d{1,2}/\d{1,2}/\d{4}' THEN substring(btrim(competence) FROM '^\d{1,2}/\d{1,2}/(\d{4})') || '-' || lpad(substring(btrim(competence) FROM '^\d{1,2}/(\d{1,2})/'), 2, '0')
+			WHEN btrim(competence) ~ '^\d{1,2}/\d{4}$' THEN substring(btrim(competence) FROM '/(\d{4})$') || '-' || lpad(substring(btrim(competence) FROM '^(\d{1,2})/'), 2, '0')
+			WHEN btrim(competence) ~ '^\d{4}-\d{2}' THEN substr(btrim(competence), 1, 7)
+			WHEN btrim(competence) ~ '^\d{6}$' THEN substr(btrim(competence), 1, 4) || '-' || substr(btrim(competence), 5, 2)
+		END WHERE competence_month IS NULL`,
+		"UPDATE documents SET competence_month = NULL WHERE substr(competence_month, 6, 2) NOT BETWEEN '01' AND '12'",
+		"UPDATE documents SET competence_month = to_char(issue_date, 'YYYY-MM') WHERE competence_month IS NULL AND issue_date > '0001-01-01'",
+	}
+
+	for _, statement := range statements {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addCompanyAutoFetchPaused records the companies turned off by a bulk auto-fetch pause, so
+// resuming restores only those
+func addCompanyAutoFetchPaused(ctx context.Context, db *bun.DB) error {
+	statements := []string{
+		"ALTER TABLE companies ADD COLUMN IF NOT EXISTS auto_fetch_paused BOOLEAN NOT NULL DEFAULT false",
+	}
+
+	for _, statement := range statements {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addCompanyRegistrationFailures counts the consecutive failed CNPJ lookups of a company, which
+// space its next registration refresh attempts
+func addCompanyRegistrationFailures(ctx context.Context, db *bun.DB) error {
+	statements := []string{
+		"ALTER TABLE companies ADD COLUMN IF NOT EXISTS registration_failures INTEGER NOT NULL DEFAULT 0",
+	}
+
+	for _, statement := range statements {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addJobLeases adds the lease of the worker advancing an export job or reprocess batch, so
+// the row is claimed in a short transaction instead of staying locked during its I/O
+func addJobLeases(ctx context.Context, db *bun.DB) error {
+	statements := []string{
+		"ALTER TABLE export_jobs ADD COLUMN IF NOT EXISTS locked_until TIMESTAMP",
+		"ALTER TABLE reprocess_batches ADD COLUMN IF NOT EXISTS locked_until TIMESTAMP",
+	}
+
+	for _, statement := range statements {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addDocumentOriginalFileName keeps the name a document's XML was received under, now that
+// the storage key is built from the note itself
+func addDocumentOriginalFileName(ctx context.Context, db *bun.DB) error {
+	statements := []string{
+		"ALTER TABLE documents ADD COLUMN IF NOT EXISTS original_file_name VARCHAR(500)",
+	}
+
+	for _, statement := range statements {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
 			return err
 		}
 	}
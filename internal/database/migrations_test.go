@@ -0,0 +1,174 @@
+package database_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/database/databasetest"
+	"github.com/zoomxml/internal/models"
+)
+
+// TestDocumentRoundTrip inserts a Document with every field set and reads it back, so a
+// field whose column the migrations do not create, or whose bun tag names another one,
+// fails instead of being silently dropped
+func TestDocumentRoundTrip(t *testing.T) {
+	databasetest.Require(t)
+	ctx := context.Background()
+	company := databasetest.CreateCompany(t, nil)
+
+	// Timestamps are stored without time zone, to the microsecond
+	at := func(day int) time.Time { return time.Date(2025, 3, day, 10, 30, 15, 123456000, time.UTC) }
+	address := func(street string) *models.Address {
+		return &models.Address{Street: street, Number: "100", Complement: "Sala 2", District: "Centro", CityCode: "3550308", UF: "SP", PostalCode: "01001000"}
+	}
+	document := &models.Document{
+		CompanyID:             company.ID,
+		Type:                  models.DocumentTypeNFSe,
+		Key:                   "35250312345678000190",
+		Number:                "4521",
+		Series:                "A1",
+		IssueDate:             at(14),
+		DueDate:               at(28),
+		Amount:                1500.25,
+		Status:                models.DocumentStatusProcessed,
+		StorageKey:            "12345678000190/2025/03/4521.xml",
+		Hash:                  "file-hash",
+		Metadata:              `{"source": "round-trip"}`,
+		VerificationCode:      "ABC123",
+		ProviderCNPJ:          "11111111000111",
+		TakerCNPJ:             "12345678000190",
+		ServiceValue:          1500.25,
+		IssValue:              75.01,
+		ServiceCode:           "1.07",
+		NaturezaOperacao:      "1",
+		MunicipalRegistration: "998877",
+		DocumentHash:          "document-hash",
+		ContentHash:           "content-hash",
+		IsCancelled:           true,
+		IsSubstituted:         true,
+		ProcessingDate:        at(15),
+		Tags:                  []string{"audit", "q1"},
+		ZeroValueFlagged:      true,
+		CNPJMismatchFlagged:   true,
+		LegalHold:             true,
+		LateArrival:           true,
+		Competence:            "03/2025",
+		CompetenceMonth:       "2025-03",
+		RpsIssueDate:          at(13),
+		RpsNumber:             "77",
+		RpsSeries:             "RPS1",
+		RpsType:               "1",
+		TakerName:             "Tomador Ltda",
+		ProviderName:          "Prestador Ltda",
+		ProviderTradeName:     "Prestador",
+		TakerAddress:          address("Rua do Tomador"),
+		ProviderAddress:       address("Rua do Prestador"),
+		Discriminacao:         "Serviços de desenvolvimento",
+		DeletedAt:             at(20),
+	}
+
+	// Every field but the relation has to be set, or it is not checked
+	value := reflect.ValueOf(document).Elem()
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		if field.Name == "BaseModel" || field.Name == "Company" || field.Name == "ID" ||
+			field.Name == "CreatedAt" || field.Name == "UpdatedAt" {
+			continue
+		}
+		if value.Field(i).IsZero() {
+			t.Errorf("field %s is not set in the round-trip document", field.Name)
+		}
+	}
+
+	databasetest.CreateDocument(t, document)
+	// The insert hook sets the timestamps to the nanosecond; the database keeps microseconds
+	document.CreatedAt = document.CreatedAt.Truncate(time.Microsecond)
+	document.UpdatedAt = document.UpdatedAt.Truncate(time.Microsecond)
+
+	stored := &models.Document{}
+	err := database.DB.NewSelect().Model(stored).Where("d.id = ?", document.ID).WhereAllWithDeleted().Scan(ctx)
+	if err != nil {
+		t.Fatalf("failed to read document back: %v", err)
+	}
+
+	got, want := reflect.ValueOf(stored).Elem(), value
+	for i := 0; i < want.NumField(); i++ {
+		name := want.Type().Field(i).Name
+		if name == "BaseModel" || name == "Company" {
+			continue
+		}
+		if wantTime, ok := want.Field(i).Interface().(time.Time); ok {
+			if gotTime := got.Field(i).Interface().(time.Time); !gotTime.Equal(wantTime) {
+				t.Errorf("%s = %v, want %v", name, gotTime, wantTime)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(got.Field(i).Interface(), want.Field(i).Interface()) {
+			t.Errorf("%s = %v, want %v", name, got.Field(i).Interface(), want.Field(i).Interface())
+		}
+	}
+}
+
+func TestPendingMigrationNames(t *testing.T) {
+	migrations := []database.MigrationItem{{Name: "001_a"}, {Name: "002_b"}, {Name: "003_c"}}
+
+	tests := []struct {
+		name    string
+		applied []string
+		want    []string
+	}{
+		{"fresh database", nil, []string{"001_a", "002_b", "003_c"}},
+		{"schema behind the binary", []string{"001_a"}, []string{"002_b", "003_c"}},
+		{"gap in the applied migrations", []string{"001_a", "003_c"}, []string{"002_b"}},
+		{"up to date", []string{"003_c", "002_b", "001_a"}, []string{}},
+		{"schema ahead of the binary", []string{"001_a", "002_b", "003_c", "004_d"}, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := database.PendingMigrationNames(migrations, tt.applied); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("pendingMigrationNames(%v) = %v, want %v", tt.applied, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPendingMigrations(t *testing.T) {
+	databasetest.Require(t)
+	ctx := context.Background()
+
+	pending, err := database.PendingMigrations(ctx)
+	if err != nil {
+		t.Fatalf("PendingMigrations() error = %v", err)
+	}
+	if len(pending) != 0 {
+		t.Fatalf("PendingMigrations() after RunMigrations = %v, want none", pending)
+	}
+
+	// Forgetting the last migration makes the schema look one migration behind
+	migrations := database.GetMigrations()
+	last := migrations[len(migrations)-1].Name
+	record := &database.Migration{}
+	if err := database.DB.NewSelect().Model(record).Where("name = ?", last).Scan(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := database.DB.NewDelete().Model(record).WherePK().Exec(ctx); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if _, err := database.DB.NewInsert().Model(record).Exec(ctx); err != nil {
+			t.Errorf("failed to restore migration %s: %v", last, err)
+		}
+	})
+
+	pending, err = database.PendingMigrations(ctx)
+	if err != nil {
+		t.Fatalf("PendingMigrations() error = %v", err)
+	}
+	if !reflect.DeepEqual(pending, []string{last}) {
+		t.Errorf("PendingMigrations() = %v, want [%s]", pending, last)
+	}
+}
@@ -0,0 +1,264 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/zoomxml/config"
+	"github.com/zoomxml/internal/logger"
+)
+
+// documentPartitions caches the monthly partitions already known to exist
+var documentPartitions sync.Map
+
+// documentPartitionName returns the partition of documents holding the month of t,
+// e.g. documents_p202501
+func documentPartitionName(t time.Time) string {
+	return "documents_p" + t.Format("200601")
+}
+
+// monthBounds returns the first instant of the month of t and of the following month
+func monthBounds(t time.Time) (time.Time, time.Time) {
+	start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return start, start.AddDate(0, 1, 0)
+}
+
+// IsDocumentsPartitioned reports whether the documents table is partitioned
+func IsDocumentsPartitioned(ctx context.Context, db bun.IDB) (bool, error) {
+	var partitioned bool
+	err := db.NewRaw(`
+		SELECT EXISTS (
+			SELECT 1 FROM pg_partitioned_table pt
+			JOIN pg_class c ON c.oid = pt.partrelid
+			WHERE c.relname = 'documents' AND c.relnamespace = 'public'::regnamespace
+		)`).Scan(ctx, &partitioned)
+	return partitioned, err
+}
+
+// partitionableDate reports whether documents issued at t get a monthly partition. Notes
+// without an issue date are stored with the zero date and stay in the default partition.
+func partitionableDate(t time.Time) bool {
+	return t.Year() > 1
+}
+
+// quoteIdentifiers quotes column names for use in a SQL column list
+func quoteIdentifiers(names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// PartitionDocumentsTable converts documents into a table partitioned by month of
+// issue_date (the competência of NFSe), with one partition per month already present
+// and a default partition for documents without issue date. It runs in a single
+// transaction and does nothing when the table is already partitioned.
+//
+// A partitioned table's primary key must include the partition key, so the primary key
+// becomes (id, issue_date) and issue_date becomes NOT NULL; missing dates are stored as
+// the zero date, as the application already writes them.
+func PartitionDocumentsTable(ctx context.Context) error {
+	partitioned, err := IsDocumentsPartitioned(ctx, DB)
+	if err != nil {
+		return fmt.Errorf("failed to check documents partitioning: %w", err)
+	}
+	if partitioned {
+		return nil
+	}
+
+	logger.Println("Partitioning documents table by issue_date month...")
+
+	err = DB.RunInTx(ctx, &sql.TxOptions{}, func(ctx context.Context, tx bun.Tx) error {
+		// Index and foreign key definitions to recreate on the partitioned table
+		var indexDefs []string
+		err := tx.NewRaw(`
+			SELECT indexdef FROM pg_indexes
+			WHERE schemaname = 'public' AND tablename = 'documents' AND indexname != 'documents_pkey'`).
+			Scan(ctx, &indexDefs)
+		if err != nil {
+			return fmt.Errorf("failed to load document indexes: %w", err)
+		}
+
+		var foreignKeys []string
+		err = tx.NewRaw(`
+			SELECT pg_get_constraintdef(oid) FROM pg_constraint
+			WHERE conrelid = 'public.documents'::regclass AND contype = 'f'`).
+			Scan(ctx, &foreignKeys)
+		if err != nil {
+			return fmt.Errorf("failed to load document foreign keys: %w", err)
+		}
+
+		var columns []string
+		err = tx.NewRaw(`
+			SELECT column_name FROM information_schema.columns
+			WHERE table_schema = 'public' AND table_name = 'documents'
+			ORDER BY ordinal_position`).
+			Scan(ctx, &columns)
+		if err != nil {
+			return fmt.Errorf("failed to load document columns: %w", err)
+		}
+
+		var months []time.Time
+		err = tx.NewRaw(`
+			SELECT DISTINCT date_trunc('month', issue_date) FROM documents
+			WHERE issue_date >= '0002-01-01'`).
+			Scan(ctx, &months)
+		if err != nil {
+			return fmt.Errorf("failed to load document months: %w", err)
+		}
+
+		var sequence sql.NullString
+		if err := tx.NewRaw("SELECT pg_get_serial_sequence('documents', 'id')").Scan(ctx, &sequence); err != nil {
+			return fmt.Errorf("failed to find documents id sequence: %w", err)
+		}
+
+		// Rows are copied by column name, with missing issue dates as the zero date
+		selectList := make([]string, len(columns))
+		for i, column := range columns {
+			selectList[i] = quoteIdentifiers([]string{column})
+			if column == "issue_date" {
+				selectList[i] = "COALESCE(issue_date, '0001-01-01')"
+			}
+		}
+
+		statements := []string{
+			"ALTER TABLE documents RENAME TO documents_unpartitioned",
+			"CREATE TABLE documents (LIKE documents_unpartitioned INCLUDING DEFAULTS) PARTITION BY RANGE (issue_date)",
+			"ALTER TABLE documents ALTER COLUMN issue_date SET NOT NULL",
+			"ALTER TABLE documents ADD PRIMARY KEY (id, issue_date)",
+			"CREATE TABLE documents_default PARTITION OF documents DEFAULT",
+		}
+		for _, month := range months {
+			start, end := monthBounds(month)
+			statements = append(statements, fmt.Sprintf(
+				"CREATE TABLE %s PARTITION OF documents FOR VALUES FROM ('%s') TO ('%s')",
+				documentPartitionName(start), start.Format(time.DateOnly), end.Format(time.DateOnly),
+			))
+		}
+		statements = append(statements, fmt.Sprintf(
+			"INSERT INTO documents (%s) SELECT %s FROM documents_unpartitioned",
+			quoteIdentifiers(columns), strings.Join(selectList, ", "),
+		))
+		if sequence.Valid {
+			statements = append(statements, fmt.Sprintf("ALTER SEQUENCE %s OWNED BY documents.id", sequence.String))
+		}
+		statements = append(statements, "DROP TABLE documents_unpartitioned")
+		for _, def := range indexDefs {
+			statements = append(statements, strings.Replace(def, " ON public.documents ", " ON documents ", 1))
+		}
+		for _, fk := range foreignKeys {
+			statements = append(statements, "ALTER TABLE documents ADD "+fk)
+		}
+
+		for _, statement := range statements {
+			if _, err := tx.ExecContext(ctx, statement); err != nil {
+				return fmt.Errorf("failed to partition documents (%s): %w", statement, err)
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	logger.Println("Documents table partitioned successfully")
+	return nil
+}
+
+// EnsureDocumentPartition creates the monthly partition of documents for the month of
+// t if it does not exist yet; documents must already be partitioned (see
+// PartitionDocumentsTable). Rows of that month already in the default partition are
+// moved into the new partition. It is meant for partition maintenance, ahead of the
+// months being ingested, not for the ingest path.
+func EnsureDocumentPartition(ctx context.Context, t time.Time) error {
+	if !partitionableDate(t) {
+		return nil
+	}
+
+	start, end := monthBounds(t)
+	name := documentPartitionName(start)
+	if _, ok := documentPartitions.Load(name); ok {
+		return nil
+	}
+
+	err := DB.RunInTx(ctx, &sql.TxOptions{}, func(ctx context.Context, tx bun.Tx) error {
+		// Serialize partition creation across instances
+		if _, err := tx.ExecContext(ctx, "LOCK TABLE documents_default IN SHARE ROW EXCLUSIVE MODE"); err != nil {
+			return err
+		}
+
+		var exists bool
+		if err := tx.NewRaw("SELECT to_regclass(?) IS NOT NULL", name).Scan(ctx, &exists); err != nil {
+			return err
+		}
+		if exists {
+			return nil
+		}
+
+		var columns []string
+		err := tx.NewRaw(`
+			SELECT column_name FROM information_schema.columns
+			WHERE table_schema = 'public' AND table_name = 'documents'
+			ORDER BY ordinal_position`).
+			Scan(ctx, &columns)
+		if err != nil {
+			return err
+		}
+		columnList := quoteIdentifiers(columns)
+
+		from, to := start.Format(time.DateOnly), end.Format(time.DateOnly)
+		statements := []struct {
+			query string
+			args  []any
+		}{
+			{"CREATE TABLE ? (LIKE documents INCLUDING DEFAULTS)", []any{bun.Ident(name)}},
+			{"INSERT INTO ? (" + columnList + ") SELECT " + columnList + " FROM documents_default WHERE issue_date >= ? AND issue_date < ?", []any{bun.Ident(name), from, to}},
+			{"DELETE FROM documents_default WHERE issue_date >= ? AND issue_date < ?", []any{from, to}},
+			{"ALTER TABLE documents ATTACH PARTITION ? FOR VALUES FROM (?) TO (?)", []any{bun.Ident(name), from, to}},
+		}
+
+		for _, statement := range statements {
+			if _, err := tx.ExecContext(ctx, statement.query, statement.args...); err != nil {
+				return fmt.Errorf("failed to create partition %s: %w", name, err)
+			}
+		}
+
+		logger.Printf("Created documents partition %s", name)
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	documentPartitions.Store(name, true)
+	return nil
+}
+
+// EnsureDocumentPartitionsAhead creates the monthly partitions of documents from the month
+// before now through DB_PARTITION_MONTHS_AHEAD months after it, so ingest finds them in
+// place. Notes of other months go to the default partition. It does nothing unless
+// DB_PARTITION_DOCUMENTS is enabled.
+func EnsureDocumentPartitionsAhead(ctx context.Context, now time.Time) error {
+	cfg := config.Get().Database
+	if !cfg.PartitionDocuments {
+		return nil
+	}
+
+	start, _ := monthBounds(now)
+	for offset := -1; offset <= cfg.PartitionMonthsAhead; offset++ {
+		if err := EnsureDocumentPartition(ctx, start.AddDate(0, offset, 0)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
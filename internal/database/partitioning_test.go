@@ -0,0 +1,242 @@
+package database_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/database/databasetest"
+)
+
+func TestDocumentPartitionName(t *testing.T) {
+	tests := []struct {
+		date time.Time
+		want string
+	}{
+		{time.Date(2025, 1, 31, 23, 59, 0, 0, time.UTC), "documents_p202501"},
+		{time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC), "documents_p202412"},
+	}
+
+	for _, tt := range tests {
+		if got := database.DocumentPartitionName(tt.date); got != tt.want {
+			t.Errorf("database.DocumentPartitionName(%v) = %q, want %q", tt.date, got, tt.want)
+		}
+	}
+}
+
+func TestMonthBounds(t *testing.T) {
+	start, end := database.MonthBounds(time.Date(2024, 12, 15, 10, 30, 0, 0, time.UTC))
+	if want := time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC); !start.Equal(want) {
+		t.Errorf("start = %v, want %v", start, want)
+	}
+	if want := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC); !end.Equal(want) {
+		t.Errorf("end = %v, want %v", end, want)
+	}
+}
+
+func TestPartitionableDate(t *testing.T) {
+	tests := []struct {
+		name string
+		date time.Time
+		want bool
+	}{
+		{"zero date", time.Time{}, false},
+		{"stored zero date", time.Date(1, 1, 1, 0, 0, 0, 0, time.UTC), false},
+		{"issue date", time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := database.PartitionableDate(tt.date); got != tt.want {
+				t.Errorf("database.PartitionableDate(%v) = %v, want %v", tt.date, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuoteIdentifiers(t *testing.T) {
+	got := database.QuoteIdentifiers([]string{"id", "issue_date", `odd"name`})
+	want := `"id", "issue_date", "odd""name"`
+	if got != want {
+		t.Errorf("database.QuoteIdentifiers() = %s, want %s", got, want)
+	}
+}
+
+// documentPartitionsOf returns the partition of each document of a company by id
+func documentPartitionsOf(t *testing.T, ctx context.Context, companyID int64) map[int64]string {
+	t.Helper()
+	var rows []struct {
+		ID        int64  `bun:"id"`
+		Partition string `bun:"partition"`
+	}
+	err := database.DB.NewRaw("SELECT id, tableoid::regclass::text AS partition FROM documents WHERE company_id = ?", companyID).Scan(ctx, &rows)
+	if err != nil {
+		t.Fatalf("failed to load document partitions: %v", err)
+	}
+	partitions := make(map[int64]string, len(rows))
+	for _, row := range rows {
+		partitions[row.ID] = row.Partition
+	}
+	return partitions
+}
+
+// TestPartitionDocumentsTable converts a seeded, unpartitioned documents table and checks
+// that every row keeps its id and lands in the partition of its month, that new ids still
+// come from the sequence, and that database.EnsureDocumentPartition moves rows of a new month out
+// of the default partition. It needs a database whose documents table is not partitioned
+// yet, so it runs before TestDocumentPartitionRouting.
+func TestPartitionDocumentsTable(t *testing.T) {
+	databasetest.Require(t)
+	ctx := context.Background()
+
+	partitioned, err := database.IsDocumentsPartitioned(ctx, database.DB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if partitioned {
+		t.Skip("documents is already partitioned; run against a freshly migrated database")
+	}
+
+	var companyID int64
+	err = database.DB.NewRaw(`INSERT INTO companies (name, cnpj) VALUES ('Partition conversion test', '99999999000272') RETURNING id`).Scan(ctx, &companyID)
+	if err != nil {
+		t.Fatalf("failed to create company: %v", err)
+	}
+	t.Cleanup(func() {
+		database.DB.ExecContext(ctx, "DELETE FROM documents WHERE company_id = ?", companyID)
+		database.DB.ExecContext(ctx, "DELETE FROM companies WHERE id = ?", companyID)
+	})
+
+	insert := func(number string, issueDate any) int64 {
+		t.Helper()
+		var id int64
+		err := database.DB.NewRaw(`
+			INSERT INTO documents (company_id, type, number, issue_date) VALUES (?, 'nfse', ?, ?)
+			RETURNING id`, companyID, number, issueDate).Scan(ctx, &id)
+		if err != nil {
+			t.Fatalf("failed to insert document: %v", err)
+		}
+		return id
+	}
+
+	want := map[int64]string{
+		insert("1", time.Date(2030, 1, 5, 10, 0, 0, 0, time.UTC)):  "documents_p203001",
+		insert("2", time.Date(2030, 1, 31, 23, 0, 0, 0, time.UTC)): "documents_p203001",
+		insert("3", time.Date(2030, 2, 1, 0, 0, 0, 0, time.UTC)):   "documents_p203002",
+		insert("4", nil): "documents_default",
+	}
+
+	var totalBefore int
+	if err := database.DB.NewRaw("SELECT count(*) FROM documents").Scan(ctx, &totalBefore); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := database.PartitionDocumentsTable(ctx); err != nil {
+		t.Fatalf("database.PartitionDocumentsTable() error = %v", err)
+	}
+
+	var totalAfter int
+	if err := database.DB.NewRaw("SELECT count(*) FROM documents").Scan(ctx, &totalAfter); err != nil {
+		t.Fatal(err)
+	}
+	if totalAfter != totalBefore {
+		t.Errorf("documents after partitioning = %d, want %d", totalAfter, totalBefore)
+	}
+
+	got := documentPartitionsOf(t, ctx, companyID)
+	if len(got) != len(want) {
+		t.Errorf("company documents after partitioning = %v, want %v", got, want)
+	}
+	for id, partition := range want {
+		if got[id] != partition {
+			t.Errorf("document %d in %q, want %q", id, got[id], partition)
+		}
+	}
+
+	// New rows keep drawing ids from the original sequence
+	var maxSeeded int64
+	for id := range want {
+		maxSeeded = max(maxSeeded, id)
+	}
+	late := insert("5", time.Date(2030, 7, 10, 0, 0, 0, 0, time.UTC))
+	if late <= maxSeeded {
+		t.Errorf("id after partitioning = %d, want above %d", late, maxSeeded)
+	}
+	if got := documentPartitionsOf(t, ctx, companyID)[late]; got != "documents_default" {
+		t.Errorf("note of a month without partition stored in %q, want documents_default", got)
+	}
+
+	if err := database.EnsureDocumentPartition(ctx, time.Date(2030, 7, 1, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("database.EnsureDocumentPartition() error = %v", err)
+	}
+	if got := documentPartitionsOf(t, ctx, companyID)[late]; got != "documents_p203007" {
+		t.Errorf("note of 2030-07 in %q after database.EnsureDocumentPartition, want documents_p203007", got)
+	}
+	var leftInDefault int
+	err = database.DB.NewRaw(`
+		SELECT count(*) FROM documents_default
+		WHERE issue_date >= '2030-07-01' AND issue_date < '2030-08-01'`).Scan(ctx, &leftInDefault)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if leftInDefault != 0 {
+		t.Errorf("documents_default still holds %d notes of 2030-07", leftInDefault)
+	}
+}
+
+// TestDocumentPartitionRouting partitions the documents table of the test database and
+// checks that notes land in the partition of their month, notes without issue date in
+// the default partition, and that a query on one month only scans its partition.
+func TestDocumentPartitionRouting(t *testing.T) {
+	databasetest.Require(t)
+	ctx := context.Background()
+
+	if err := database.PartitionDocumentsTable(ctx); err != nil {
+		t.Fatalf("database.PartitionDocumentsTable() error = %v", err)
+	}
+	month := time.Date(2031, 5, 1, 0, 0, 0, 0, time.UTC)
+	if err := database.EnsureDocumentPartition(ctx, month); err != nil {
+		t.Fatalf("database.EnsureDocumentPartition() error = %v", err)
+	}
+
+	var companyID int64
+	err := database.DB.NewRaw(`INSERT INTO companies (name, cnpj) VALUES ('Partition test', '99999999000191') RETURNING id`).Scan(ctx, &companyID)
+	if err != nil {
+		t.Fatalf("failed to create company: %v", err)
+	}
+	t.Cleanup(func() {
+		database.DB.ExecContext(ctx, "DELETE FROM documents WHERE company_id = ?", companyID)
+		database.DB.ExecContext(ctx, "DELETE FROM companies WHERE id = ?", companyID)
+	})
+
+	partitionOf := func(issueDate time.Time) string {
+		t.Helper()
+		var partition string
+		err := database.DB.NewRaw(`
+			INSERT INTO documents (company_id, type, number, issue_date) VALUES (?, 'nfse', '1', ?)
+			RETURNING tableoid::regclass::text`, companyID, issueDate).Scan(ctx, &partition)
+		if err != nil {
+			t.Fatalf("failed to insert document: %v", err)
+		}
+		return partition
+	}
+
+	if got := partitionOf(month.AddDate(0, 0, 14)); got != "documents_p203105" {
+		t.Errorf("note of 2031-05 stored in %s, want documents_p203105", got)
+	}
+	if got := partitionOf(time.Date(1, 1, 1, 0, 0, 0, 0, time.UTC)); got != "documents_default" {
+		t.Errorf("note without issue date stored in %s, want documents_default", got)
+	}
+
+	var plan []string
+	err = database.DB.NewRaw(`EXPLAIN SELECT id FROM documents WHERE issue_date >= '2031-05-01' AND issue_date < '2031-06-01'`).Scan(ctx, &plan)
+	if err != nil {
+		t.Fatalf("EXPLAIN failed: %v", err)
+	}
+	joined := strings.Join(plan, "\n")
+	if !strings.Contains(joined, "documents_p203105") || strings.Contains(joined, "documents_default") {
+		t.Errorf("query on 2031-05 does not prune to its partition:\n%s", joined)
+	}
+}
@@ -2,21 +2,35 @@ package database
 
 import (
 	"context"
+	"errors"
 
+	"github.com/uptrace/bun"
 	"github.com/zoomxml/config"
 	"github.com/zoomxml/internal/logger"
 	"github.com/zoomxml/internal/models"
 	"golang.org/x/crypto/bcrypt"
 )
 
-// SeedAdminUser cria o usuário admin usando as configurações do .env
+// developmentAdminPassword é a senha do primeiro admin em desenvolvimento quando
+// ADMIN_PASSWORD não está definida
+const developmentAdminPassword = "admin123"
+
+// ErrAdminPasswordRequired é retornado ao criar o primeiro admin fora de desenvolvimento
+// sem ADMIN_PASSWORD
+var ErrAdminPasswordRequired = errors.New("ADMIN_PASSWORD must be set to create the first admin outside development")
+
+// SeedAdminUser cria o primeiro usuário admin usando ADMIN_EMAIL, ADMIN_PASSWORD e
+// ADMIN_TOKEN do .env. Só executa com a tabela de usuários vazia. Fora de
+// desenvolvimento recusa criar o admin sem ADMIN_PASSWORD, em vez de usar uma senha padrão.
 func SeedAdminUser(ctx context.Context) error {
-	cfg := config.Get()
-	
-	// Verificar se já existe um usuário admin
-	exists, err := DB.NewSelect().
+	return seedAdminUser(ctx, DB, config.Get())
+}
+
+// seedAdminUser é o SeedAdminUser sobre uma conexão e configuração explícitas
+func seedAdminUser(ctx context.Context, db bun.IDB, cfg *config.Config) error {
+	// Verificar se já existe algum usuário
+	exists, err := db.NewSelect().
 		Model((*models.User)(nil)).
-		Where("role = 'admin'").
 		Exists(ctx)
 
 	if err != nil {
@@ -24,14 +38,25 @@ func SeedAdminUser(ctx context.Context) error {
 	}
 
 	if exists {
-		logger.InfoWithFields("Admin user already exists, skipping seed", map[string]any{
+		logger.InfoWithFields("Users already exist, skipping admin bootstrap", map[string]any{
 			"operation": "seed_admin_user",
 		})
 		return nil
 	}
 
-	// Hash da senha padrão
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte("admin123"), bcrypt.DefaultCost)
+	password := cfg.Auth.AdminPassword
+	if password == "" {
+		if !cfg.IsDevelopment() {
+			return ErrAdminPasswordRequired
+		}
+		password = developmentAdminPassword
+		logger.WarnWithFields("ADMIN_PASSWORD not set, using the development default", map[string]any{
+			"operation": "seed_admin_user",
+		})
+	}
+
+	// Hash da senha configurada
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
 		return err
 	}
@@ -39,22 +64,21 @@ func SeedAdminUser(ctx context.Context) error {
 	// Criar usuário admin usando o token do .env
 	adminUser := &models.User{
 		Name:     "Admin User",
-		Email:    "admin@zoomxml.com",
+		Email:    cfg.Auth.AdminEmail,
 		Password: string(hashedPassword),
 		Token:    cfg.Auth.AdminToken, // Usar o token do .env
 		Role:     "admin",
 		Active:   true,
 	}
 
-	_, err = DB.NewInsert().Model(adminUser).Exec(ctx)
+	_, err = db.NewInsert().Model(adminUser).Exec(ctx)
 	if err != nil {
 		return err
 	}
 
-	logger.InfoWithFields("Admin user created successfully", map[string]any{
+	logger.WarnWithFields("Bootstrap admin user created, change its password and token", map[string]any{
 		"operation": "seed_admin_user",
 		"email":     adminUser.Email,
-		"token":     adminUser.Token,
 	})
 
 	return nil
@@ -0,0 +1,81 @@
+package database_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/zoomxml/config"
+	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/database/databasetest"
+	"github.com/zoomxml/internal/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// TestSeedAdminUser runs each case in a transaction whose users table starts empty, and
+// rolls it back, so the users of the test database are left as they were
+func TestSeedAdminUser(t *testing.T) {
+	databasetest.Require(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name         string
+		env          string
+		password     string
+		existingUser bool
+		wantErr      error
+		wantCreated  bool
+		wantPassword string
+	}{
+		{"empty database", "production", "s3cret-password", false, nil, true, "s3cret-password"},
+		{"development default password", "development", "", false, nil, true, database.DevelopmentAdminPassword},
+		{"no password outside development", "production", "", false, database.ErrAdminPasswordRequired, false, ""},
+		{"users exist", "production", "", true, nil, false, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx, err := database.DB.BeginTx(ctx, &sql.TxOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer tx.Rollback()
+
+			if _, err := tx.ExecContext(ctx, "TRUNCATE users CASCADE"); err != nil {
+				t.Fatalf("failed to empty users: %v", err)
+			}
+			if tt.existingUser {
+				user := &models.User{Name: "Existing", Email: "existing@test.local", Password: "-", Token: "existing-token", Role: "user", Active: true}
+				if _, err := tx.NewInsert().Model(user).Exec(ctx); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			cfg := *config.Get()
+			cfg.App.Env = tt.env
+			cfg.Auth.AdminEmail = "admin@test.local"
+			cfg.Auth.AdminPassword = tt.password
+			cfg.Auth.AdminToken = "bootstrap-token"
+
+			if err := database.SeedAdminUserWith(ctx, tx, &cfg); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("database.SeedAdminUserWith() error = %v, want %v", err, tt.wantErr)
+			}
+
+			admin := &models.User{}
+			err = tx.NewSelect().Model(admin).Where("email = ?", cfg.Auth.AdminEmail).Scan(ctx)
+			if created := err == nil; created != tt.wantCreated {
+				t.Fatalf("admin created = %v (%v), want %v", created, err, tt.wantCreated)
+			}
+			if !tt.wantCreated {
+				return
+			}
+			if admin.Role != "admin" || admin.Token != cfg.Auth.AdminToken || !admin.Active {
+				t.Errorf("admin = role %q token %q active %v, want an active admin with the configured token", admin.Role, admin.Token, admin.Active)
+			}
+			if err := bcrypt.CompareHashAndPassword([]byte(admin.Password), []byte(tt.wantPassword)); err != nil {
+				t.Errorf("admin password does not match %q: %v", tt.wantPassword, err)
+			}
+		})
+	}
+}
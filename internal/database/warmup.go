@@ -0,0 +1,107 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/zoomxml/internal/logger"
+)
+
+// expectedIndex is an index the application relies on, with the idempotent statement
+// that creates it
+type expectedIndex struct {
+	Name      string
+	Statement string
+}
+
+// indexNamePattern captures the name of an index from its CREATE INDEX statement
+var indexNamePattern = regexp.MustCompile(`^CREATE (?:UNIQUE )?INDEX IF NOT EXISTS (\w+) `)
+
+// expectedIndexes lists the indexes created by the versioned migrations. Indexes dropped
+// by hand (or lost in a restore) are recreated by EnsureIndexes at startup.
+func expectedIndexes() ([]expectedIndex, error) {
+	var indexes []expectedIndex
+	for _, migration := range GetMigrations() {
+		for _, statement := range migration.Indexes {
+			match := indexNamePattern.FindStringSubmatch(statement)
+			if match == nil {
+				return nil, fmt.Errorf("migration %s: unrecognized index statement %q", migration.Name, statement)
+			}
+			indexes = append(indexes, expectedIndex{Name: match[1], Statement: statement})
+		}
+	}
+	return indexes, nil
+}
+
+// EnsureIndexes creates the expected indexes that are missing from the database
+func EnsureIndexes(ctx context.Context) error {
+	indexes, err := expectedIndexes()
+	if err != nil {
+		return err
+	}
+
+	var existing []string
+	err = DB.NewRaw("SELECT indexname FROM pg_indexes WHERE schemaname = current_schema()").Scan(ctx, &existing)
+	if err != nil {
+		return fmt.Errorf("failed to list indexes: %w", err)
+	}
+
+	present := make(map[string]bool, len(existing))
+	for _, name := range existing {
+		present[name] = true
+	}
+
+	for _, index := range indexes {
+		if present[index.Name] {
+			continue
+		}
+
+		logger.Printf("Creating missing index %s", index.Name)
+		if _, err := DB.ExecContext(ctx, index.Statement); err != nil {
+			return fmt.Errorf("failed to create index %s: %w", index.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// ProbeRoundtrip verifies the database accepts writes by inserting, reading back and
+// deleting a row in a temporary table dropped with the transaction. A read-only or
+// misconfigured connection fails here instead of on the first ingest.
+func ProbeRoundtrip(ctx context.Context) error {
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("database probe failed to begin: %w", err)
+	}
+	defer tx.Rollback()
+
+	statements := []string{
+		"CREATE TEMPORARY TABLE warmup_probe (token TEXT NOT NULL) ON COMMIT DROP",
+		"INSERT INTO warmup_probe (token) VALUES ('probe')",
+	}
+	for _, statement := range statements {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("database probe write failed: %w", err)
+		}
+	}
+
+	var token string
+	if err := tx.QueryRowContext(ctx, "SELECT token FROM warmup_probe").Scan(&token); err != nil {
+		return fmt.Errorf("database probe read failed: %w", err)
+	}
+	if token != "probe" {
+		return fmt.Errorf("database probe read back %q", token)
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM warmup_probe"); err != nil {
+		return fmt.Errorf("database probe delete failed: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("database probe failed to commit: %w", err)
+	}
+
+	logger.Println("Database probe succeeded")
+	return nil
+}
@@ -0,0 +1,28 @@
+package database_test
+
+import (
+	"testing"
+
+	"github.com/zoomxml/internal/database"
+)
+
+func TestExpectedIndexes(t *testing.T) {
+	indexes, err := database.ExpectedIndexes()
+	if err != nil {
+		t.Fatalf("ExpectedIndexes() error = %v", err)
+	}
+
+	names := make(map[string]bool, len(indexes))
+	for _, index := range indexes {
+		if names[index.Name] {
+			t.Errorf("index %s is created by more than one migration", index.Name)
+		}
+		names[index.Name] = true
+	}
+
+	for _, name := range []string{"idx_users_email", "idx_reprocess_batches_active", "idx_documents_verification_code_normalized"} {
+		if !names[name] {
+			t.Errorf("index %s missing from %d expected indexes", name, len(indexes))
+		}
+	}
+}
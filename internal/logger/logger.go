@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/rs/zerolog"
@@ -15,34 +16,38 @@ import (
 
 var Logger zerolog.Logger
 
+// fileWriter is the rotating log file, set when file logging is enabled
+var fileWriter *RotatingFileWriter
+
 // Initialize configures the global logger
 func Initialize() {
 	cfg := config.Get()
 
 	// Configure output
 	var output io.Writer = os.Stdout
-	if cfg.IsDevelopment() {
-		// Pretty logging for development
+	if cfg.Logger.Output == "stderr" {
+		output = os.Stderr
+	}
+
+	if logFormat(cfg.Logger.Format, cfg.IsDevelopment()) == "console" {
 		output = zerolog.ConsoleWriter{
-			Out:        os.Stdout,
+			Out:        output,
 			TimeFormat: time.RFC3339,
 		}
 	}
 
-	// Set global log level
-	level := zerolog.InfoLevel
-	switch cfg.Logger.Level {
-	case "debug":
-		level = zerolog.DebugLevel
-	case "info":
-		level = zerolog.InfoLevel
-	case "warn":
-		level = zerolog.WarnLevel
-	case "error":
-		level = zerolog.ErrorLevel
+	// Also write JSON logs to a rotating file when enabled
+	if cfg.Logger.EnableFile {
+		fileWriter = NewRotatingFileWriter(
+			cfg.Logger.FilePath,
+			cfg.Logger.MaxSize,
+			cfg.Logger.MaxBackups,
+			cfg.Logger.MaxAge,
+		)
+		output = zerolog.MultiLevelWriter(output, fileWriter)
 	}
 
-	zerolog.SetGlobalLevel(level)
+	zerolog.SetGlobalLevel(logLevel(cfg.Logger.Level))
 
 	// Configure global logger
 	Logger = zerolog.New(output).
@@ -56,6 +61,38 @@ func Initialize() {
 	log.Logger = Logger
 }
 
+// logFormat returns the output format, json or console. LOG_FORMAT wins; without it,
+// development uses pretty console logs.
+func logFormat(format string, development bool) string {
+	switch strings.ToLower(format) {
+	case "console":
+		return "console"
+	case "":
+		if development {
+			return "console"
+		}
+	}
+	return "json"
+}
+
+// logLevel parses the global log level (trace, debug, info, warn, error, fatal, panic),
+// falling back to info when it is empty or invalid
+func logLevel(raw string) zerolog.Level {
+	level, err := zerolog.ParseLevel(strings.ToLower(raw))
+	if err != nil || level == zerolog.NoLevel {
+		return zerolog.InfoLevel
+	}
+	return level
+}
+
+// Close flushes and closes the log file, if any
+func Close() error {
+	if fileWriter == nil {
+		return nil
+	}
+	return fileWriter.Close()
+}
+
 // CredentialOperation represents the type of operation performed on credentials
 type CredentialOperation string
 
@@ -0,0 +1,54 @@
+package logger
+
+import (
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func TestLogFormat(t *testing.T) {
+	tests := []struct {
+		name        string
+		format      string
+		development bool
+		want        string
+	}{
+		{"json", "json", false, "json"},
+		{"json in development", "json", true, "json"},
+		{"console", "console", false, "console"},
+		{"console in upper case", "CONSOLE", false, "console"},
+		{"empty in production", "", false, "json"},
+		{"empty in development", "", true, "console"},
+		{"unknown format", "xml", true, "json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := logFormat(tt.format, tt.development); got != tt.want {
+				t.Errorf("logFormat(%q, %v) = %q, want %q", tt.format, tt.development, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLogLevel(t *testing.T) {
+	tests := []struct {
+		name  string
+		level string
+		want  zerolog.Level
+	}{
+		{"debug", "debug", zerolog.DebugLevel},
+		{"upper case", "WARN", zerolog.WarnLevel},
+		{"error", "error", zerolog.ErrorLevel},
+		{"empty", "", zerolog.InfoLevel},
+		{"invalid", "verbose", zerolog.InfoLevel},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := logLevel(tt.level); got != tt.want {
+				t.Errorf("logLevel(%q) = %v, want %v", tt.level, got, tt.want)
+			}
+		})
+	}
+}
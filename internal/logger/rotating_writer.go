@@ -0,0 +1,172 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backupTimeFormat is the timestamp appended to rotated log files
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// RotatingFileWriter is an io.Writer that writes to a file and rotates it when it
+// reaches MaxSize megabytes, keeping at most MaxBackups rotated files no older
+// than MaxAge days (zero disables the respective limit)
+type RotatingFileWriter struct {
+	Filename   string
+	MaxSize    int
+	MaxBackups int
+	MaxAge     int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// NewRotatingFileWriter creates a rotating writer for the given file
+func NewRotatingFileWriter(filename string, maxSize, maxBackups, maxAge int) *RotatingFileWriter {
+	return &RotatingFileWriter{
+		Filename:   filename,
+		MaxSize:    maxSize,
+		MaxBackups: maxBackups,
+		MaxAge:     maxAge,
+	}
+}
+
+// Write writes p to the current file, rotating it first if p would exceed MaxSize
+func (w *RotatingFileWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		if err := w.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	if max := w.maxBytes(); max > 0 && w.size > 0 && w.size+int64(len(p)) > max {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Close closes the current file
+func (w *RotatingFileWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+// maxBytes returns the size limit in bytes
+func (w *RotatingFileWriter) maxBytes() int64 {
+	return int64(w.MaxSize) * 1024 * 1024
+}
+
+// open opens (or creates) the log file in append mode
+func (w *RotatingFileWriter) open() error {
+	if err := os.MkdirAll(filepath.Dir(w.Filename), 0755); err != nil {
+		return fmt.Errorf("failed to create log directory: %w", err)
+	}
+
+	file, err := os.OpenFile(w.Filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat log file: %w", err)
+	}
+
+	w.file = file
+	w.size = info.Size()
+	return nil
+}
+
+// rotate moves the current file to a timestamped backup and opens a new one
+func (w *RotatingFileWriter) rotate() error {
+	if err := w.file.Close(); err != nil {
+		return fmt.Errorf("failed to close log file: %w", err)
+	}
+	w.file = nil
+
+	if err := os.Rename(w.Filename, w.backupName(time.Now())); err != nil {
+		return fmt.Errorf("failed to rotate log file: %w", err)
+	}
+
+	if err := w.open(); err != nil {
+		return err
+	}
+
+	w.cleanup()
+	return nil
+}
+
+// backupName builds the rotated file name, e.g. logs/zoomxml-2025-01-02T15-04-05.000.log
+func (w *RotatingFileWriter) backupName(t time.Time) string {
+	ext := filepath.Ext(w.Filename)
+	prefix := strings.TrimSuffix(w.Filename, ext)
+	return fmt.Sprintf("%s-%s%s", prefix, t.Format(backupTimeFormat), ext)
+}
+
+// cleanup removes backups beyond MaxBackups or older than MaxAge
+func (w *RotatingFileWriter) cleanup() {
+	if w.MaxBackups <= 0 && w.MaxAge <= 0 {
+		return
+	}
+
+	ext := filepath.Ext(w.Filename)
+	prefix := filepath.Base(strings.TrimSuffix(w.Filename, ext)) + "-"
+	dir := filepath.Dir(w.Filename)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+
+	type backup struct {
+		path      string
+		timestamp time.Time
+	}
+
+	backups := []backup{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ext) {
+			continue
+		}
+		timestamp, err := time.ParseInLocation(backupTimeFormat, strings.TrimSuffix(strings.TrimPrefix(name, prefix), ext), time.Local)
+		if err != nil {
+			continue
+		}
+		backups = append(backups, backup{path: filepath.Join(dir, name), timestamp: timestamp})
+	}
+
+	// Newest first
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].timestamp.After(backups[j].timestamp)
+	})
+
+	cutoff := time.Now().AddDate(0, 0, -w.MaxAge)
+	for i, b := range backups {
+		if (w.MaxBackups > 0 && i >= w.MaxBackups) || (w.MaxAge > 0 && b.timestamp.Before(cutoff)) {
+			os.Remove(b.path)
+		}
+	}
+}
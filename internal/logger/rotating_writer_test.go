@@ -0,0 +1,72 @@
+package logger
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRotatingFileWriter(t *testing.T) {
+	chunk := bytes.Repeat([]byte("x"), 600*1024) // two chunks exceed a 1 MB file
+
+	tests := []struct {
+		name        string
+		maxBackups  int
+		maxAge      int
+		writes      int
+		staleBackup bool // a backup older than maxAge exists before the writes
+		wantBackups int
+	}{
+		{"below the size limit", 0, 0, 1, false, 0},
+		{"rotates at the size limit", 0, 0, 2, false, 1},
+		{"keeps every backup without limits", 0, 0, 4, false, 3},
+		{"keeps at most max backups", 2, 0, 5, false, 2},
+		{"removes backups past max age", 0, 1, 2, true, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			filename := filepath.Join(dir, "zoomxml.log")
+			writer := NewRotatingFileWriter(filename, 1, tt.maxBackups, tt.maxAge)
+			defer writer.Close()
+
+			if tt.staleBackup {
+				stale := writer.backupName(time.Now().AddDate(0, 0, -10))
+				if err := os.WriteFile(stale, []byte("old"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			for range tt.writes {
+				if _, err := writer.Write(chunk); err != nil {
+					t.Fatalf("Write() error = %v", err)
+				}
+				time.Sleep(2 * time.Millisecond) // backups are named by millisecond
+			}
+
+			info, err := os.Stat(filename)
+			if err != nil {
+				t.Fatalf("log file not created: %v", err)
+			}
+			if info.Size() != int64(len(chunk)) {
+				t.Errorf("current file size = %d, want %d", info.Size(), len(chunk))
+			}
+
+			backups, err := filepath.Glob(filepath.Join(dir, "zoomxml-*.log"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(backups) != tt.wantBackups {
+				t.Errorf("backups = %v, want %d", backups, tt.wantBackups)
+			}
+			for _, backup := range backups {
+				if info, err := os.Stat(backup); err == nil && info.Size() != int64(len(chunk)) {
+					t.Errorf("backup %s size = %d, want a full rotated file", backup, info.Size())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package models
+
+// Address é o endereço de uma parte da NFS-e (prestador ou tomador), gravado como jsonb
+type Address struct {
+	Street     string `json:"street,omitempty"`
+	Number     string `json:"number,omitempty"`
+	Complement string `json:"complement,omitempty"`
+	District   string `json:"district,omitempty"`
+	CityCode   string `json:"city_code,omitempty"` // Código IBGE do município (7 dígitos)
+	UF         string `json:"uf,omitempty"`
+	PostalCode string `json:"postal_code,omitempty"`
+}
+
+// IsEmpty indica se nenhum campo do endereço foi informado
+func (a Address) IsEmpty() bool {
+	return a == Address{}
+}
+
+// UFByIBGEPrefix mapeia os dois primeiros dígitos do código IBGE do município para a UF
+var UFByIBGEPrefix = map[string]string{
+	"11": "RO", "12": "AC", "13": "AM", "14": "RR", "15": "PA", "16": "AP", "17": "TO",
+	"21": "MA", "22": "PI", "23": "CE", "24": "RN", "25": "PB", "26": "PE", "27": "AL", "28": "SE", "29": "BA",
+	"31": "MG", "32": "ES", "33": "RJ", "35": "SP",
+	"41": "PR", "42": "SC", "43": "RS",
+	"50": "MS", "51": "MT", "52": "GO", "53": "DF",
+}
+
+// UFFromCityCode retorna a UF de um código IBGE de município, ou "" quando desconhecido
+func UFFromCityCode(cityCode string) string {
+	if len(cityCode) != 7 {
+		return ""
+	}
+	return UFByIBGEPrefix[cityCode[:2]]
+}
@@ -0,0 +1,26 @@
+package models
+
+import "testing"
+
+func TestUFFromCityCode(t *testing.T) {
+	tests := []struct {
+		name     string
+		cityCode string
+		want     string
+	}{
+		{"São Paulo", "3550308", "SP"},
+		{"Curitiba", "4106902", "PR"},
+		{"Brasília", "5300108", "DF"},
+		{"unknown prefix", "9900000", ""},
+		{"short code", "35503", ""},
+		{"empty", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := UFFromCityCode(tt.cityCode); got != tt.want {
+				t.Errorf("UFFromCityCode(%q) = %q, want %q", tt.cityCode, got, tt.want)
+			}
+		})
+	}
+}
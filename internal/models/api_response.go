@@ -0,0 +1,15 @@
+package models
+
+// APIResponse é o envelope padrão das respostas de listagem e detalhe
+type APIResponse struct {
+	Success bool      `json:"success"`
+	Data    any       `json:"data"`
+	Meta    *MetaData `json:"meta,omitempty"`
+}
+
+// MetaData contém os dados de paginação das listagens
+type MetaData struct {
+	Page  int `json:"page"`
+	Limit int `json:"limit"`
+	Total int `json:"total"`
+}
@@ -2,6 +2,7 @@ package models
 
 import (
 	"context"
+	"encoding/json"
 	"time"
 
 	"github.com/uptrace/bun"
@@ -32,17 +33,41 @@ type Company struct {
 	Email string `bun:"email" json:"email,omitempty"`
 
 	// Dados empresariais
-	CompanySize        string    `bun:"company_size" json:"company_size,omitempty"`               // ME, EPP, etc
-	MainActivity       string    `bun:"main_activity" json:"main_activity,omitempty"`             // Atividade principal
-	SecondaryActivity  string    `bun:"secondary_activity" json:"secondary_activity,omitempty"`   // Atividades secundárias
-	LegalNature        string    `bun:"legal_nature" json:"legal_nature,omitempty"`               // Natureza jurídica
-	OpeningDate        string    `bun:"opening_date" json:"opening_date,omitempty"`               // Data de abertura
-	RegistrationStatus string    `bun:"registration_status" json:"registration_status,omitempty"` // Situação cadastral
-	Restricted         bool      `bun:"restricted,notnull,default:false" json:"restricted"`
-	AutoFetch          bool      `bun:"auto_fetch,notnull,default:false" json:"auto_fetch"`
-	Active             bool      `bun:"active,notnull,default:true" json:"active"`
-	CreatedAt          time.Time `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
-	UpdatedAt          time.Time `bun:"updated_at,nullzero,notnull,default:current_timestamp" json:"updated_at"`
+	CompanySize           string           `bun:"company_size" json:"company_size,omitempty"`                                // ME, EPP, etc
+	MainActivity          string           `bun:"main_activity" json:"main_activity,omitempty"`                              // Atividade principal
+	SecondaryActivity     string           `bun:"secondary_activity" json:"secondary_activity,omitempty"`                    // Atividades secundárias
+	LegalNature           string           `bun:"legal_nature" json:"legal_nature,omitempty"`                                // Natureza jurídica
+	OpeningDate           string           `bun:"opening_date" json:"opening_date,omitempty"`                                // Data de abertura
+	RegistrationStatus    string           `bun:"registration_status" json:"registration_status,omitempty"`                  // Situação cadastral
+	RegistrationInactive  bool             `bun:"registration_inactive,notnull,default:false" json:"registration_inactive"`  // Situação cadastral diferente de ativa
+	RegistrationCheckedAt time.Time        `bun:"registration_checked_at,nullzero" json:"registration_checked_at,omitempty"` // Última consulta do CNPJ
+	RegistrationFailures  int              `bun:"registration_failures,notnull,default:0" json:"-"`                          // Consultas do CNPJ com falha seguidas (migração 048)
+	Restricted            bool             `bun:"restricted,notnull,default:false" json:"restricted"`
+	AutoFetch             bool             `bun:"auto_fetch,notnull,default:false" json:"auto_fetch"`
+	AutoFetchPaused       bool             `bun:"auto_fetch_paused,notnull,default:false" json:"auto_fetch_paused"`            // Desligada pela pausa em massa; a retomada religa só estas (migração 046)
+	DebugCapture          bool             `bun:"debug_capture,notnull,default:false" json:"debug_capture"`                    // Guarda respostas brutas do provedor
+	ProviderBaseURL       string           `bun:"provider_base_url" json:"provider_base_url,omitempty"`                        // Substitui a URL padrão do provedor NFSe
+	MunicipalityCode      string           `bun:"municipality_code" json:"municipality_code,omitempty"`                        // Código IBGE que escolhe o provedor NFSe (migração 039)
+	ZeroValuePolicy       string           `bun:"zero_value_policy,notnull,default:'flag'" json:"zero_value_policy"`           // accept, flag ou reject
+	CNPJMatchPolicy       string           `bun:"cnpj_match_policy,notnull,default:'off'" json:"cnpj_match_policy"`            // off, flag ou reject
+	DefaultEnvironment    string           `bun:"default_environment,notnull,default:'production'" json:"default_environment"` // Ambiente esperado das credenciais
+	ValidationRules       []ValidationRule `bun:"validation_rules,type:jsonb,nullzero" json:"validation_rules,omitempty"`      // Regras de negócio aplicadas na ingestão
+	RetentionMonths       int              `bun:"retention_months,notnull,default:0" json:"retention_months"`                  // Meses de retenção dos documentos (0 = sem limite)
+	LegalHold             bool             `bun:"legal_hold,notnull,default:false" json:"legal_hold"`                          // Retenção legal: nenhum documento expira
+	DocumentLimit         int              `bun:"document_limit,notnull,default:0" json:"document_limit"`                      // Máximo de NFSe da empresa, ex.: contas de teste (0 = sem limite)
+	DocumentLimitPolicy   string           `bun:"document_limit_policy,notnull,default:'reject'" json:"document_limit_policy"` // reject ou evict_oldest
+	MetadataOnly          bool             `bun:"metadata_only,notnull,default:false" json:"metadata_only"`                    // Guarda só os campos extraídos, sem o XML
+	Active                bool             `bun:"active,notnull,default:true" json:"active"`
+	LastSyncAt            time.Time        `bun:"last_sync_at,nullzero" json:"last_sync_at,omitempty"` // Última sincronização automática
+	LastSyncStatus        string           `bun:"last_sync_status" json:"last_sync_status,omitempty"`  // success, partial, failed, no_credentials
+	LastSyncError         string           `bun:"last_sync_error" json:"last_sync_error,omitempty"`
+	ResyncPending         bool             `bun:"resync_pending,notnull,default:false" json:"resync_pending"` // Próxima busca "desde o último" refaz a janela de carga inicial
+	SyncCron              string           `bun:"sync_cron" json:"sync_cron,omitempty"`                       // Expressão cron da busca automática (substitui o intervalo global)
+	SyncWindowStart       string           `bun:"sync_window_start" json:"sync_window_start,omitempty"`       // Início da janela de busca (HH:MM, horário do servidor)
+	SyncWindowEnd         string           `bun:"sync_window_end" json:"sync_window_end,omitempty"`           // Fim da janela de busca (HH:MM, exclusivo)
+	LastScheduledFetchAt  time.Time        `bun:"last_scheduled_fetch_at,nullzero" json:"-"`                  // Início da última busca agendada, base da expressão cron
+	CreatedAt             time.Time        `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
+	UpdatedAt             time.Time        `bun:"updated_at,nullzero,notnull,default:current_timestamp" json:"updated_at"`
 
 	// Relacionamentos
 	Members     []CompanyMember     `bun:"rel:has-many,join:id=company_id" json:"members,omitempty"`
@@ -50,6 +75,47 @@ type Company struct {
 	Documents   []Document          `bun:"rel:has-many,join:id=company_id" json:"documents,omitempty"`
 }
 
+// Tratamento de notas com valor de serviço zero
+const (
+	ZeroValuePolicyAccept = "accept" // aceita sem marcação
+	ZeroValuePolicyFlag   = "flag"   // aceita e marca o documento para conferência
+	ZeroValuePolicyReject = "reject" // rejeita a nota
+)
+
+// Conferência do CNPJ das notas com o CNPJ da empresa (prestador ou tomador)
+const (
+	CNPJMatchPolicyOff    = "off"    // sem conferência
+	CNPJMatchPolicyFlag   = "flag"   // aceita e marca a nota de outra empresa para conferência
+	CNPJMatchPolicyReject = "reject" // rejeita a nota de outra empresa
+)
+
+// O que acontece com novas notas quando a empresa atinge o limite de documentos
+const (
+	DocumentLimitPolicyReject      = "reject"       // rejeita as notas novas
+	DocumentLimitPolicyEvictOldest = "evict_oldest" // aceita e remove as notas mais antigas
+)
+
+// Ambientes das credenciais
+const (
+	EnvironmentProduction  = "production"
+	EnvironmentStaging     = "staging"
+	EnvironmentDevelopment = "development"
+)
+
+// Política para credenciais de ambiente diferente do padrão da empresa
+const (
+	EnvironmentMismatchWarn  = "warn"  // usa a credencial e registra um aviso
+	EnvironmentMismatchBlock = "block" // recusa a credencial
+)
+
+// Status da última sincronização
+const (
+	SyncStatusSuccess       = "success"
+	SyncStatusPartial       = "partial" // busca interrompida antes da última página ou com documentos não gravados
+	SyncStatusFailed        = "failed"
+	SyncStatusNoCredentials = "no_credentials"
+)
+
 // IsAccessibleByUser verifica se a empresa é acessível por um usuário
 func (c *Company) IsAccessibleByUser(user *User) bool {
 	// Admins sempre podem acessar todas as empresas
@@ -72,6 +138,13 @@ func (c *Company) IsAccessibleByUser(user *User) bool {
 	return false
 }
 
+// ValidationRule é uma regra de negócio aplicada aos documentos ingeridos da empresa.
+// Validator é o nome de um validador registrado e Params, os parâmetros dele em JSON.
+type ValidationRule struct {
+	Validator string          `json:"validator"`
+	Params    json.RawMessage `json:"params,omitempty"`
+}
+
 // BeforeAppendModel hook para atualizar timestamps
 func (c *Company) BeforeAppendModel(ctx context.Context, query bun.Query) error {
 	switch query.(type) {
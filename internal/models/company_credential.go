@@ -12,17 +12,20 @@ import (
 type CompanyCredential struct {
 	bun.BaseModel `bun:"table:company_credentials,alias:cc"`
 
-	ID              int64     `bun:"id,pk,autoincrement" json:"id"`
-	CompanyID       int64     `bun:"company_id,notnull" json:"company_id"`
-	Type            string    `bun:"type,notnull" json:"type"` // ex: 'prefeitura_user_pass', 'prefeitura_token', 'prefeitura_mixed'
-	Name            string    `bun:"name,notnull" json:"name"`
-	Description     string    `bun:"description" json:"description,omitempty"`
-	Login           string    `bun:"login" json:"login,omitempty"`
-	Environment     string    `bun:"environment" json:"environment,omitempty"` // production, staging, development
-	EncryptedSecret string    `bun:"encrypted_secret" json:"-"`                // Token/senha criptografada - não expor no JSON
-	Active          bool      `bun:"active,notnull,default:true" json:"active"`
-	CreatedAt       time.Time `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
-	UpdatedAt       time.Time `bun:"updated_at,nullzero,notnull,default:current_timestamp" json:"updated_at"`
+	ID              int64      `bun:"id,pk,autoincrement" json:"id"`
+	CompanyID       int64      `bun:"company_id,notnull" json:"company_id"`
+	Type            string     `bun:"type,notnull" json:"type"` // ex: 'prefeitura_user_pass', 'prefeitura_token', 'prefeitura_mixed'
+	Name            string     `bun:"name,notnull" json:"name"`
+	Description     string     `bun:"description" json:"description,omitempty"`
+	Login           string     `bun:"login" json:"login,omitempty"`
+	Environment     string     `bun:"environment" json:"environment,omitempty"` // production, staging, development
+	EncryptedSecret string     `bun:"encrypted_secret" json:"-"`                // Token/senha criptografada - não expor no JSON
+	Active          bool       `bun:"active,notnull,default:true" json:"active"`
+	LastUsedAt      *time.Time `bun:"last_used_at" json:"last_used_at,omitempty"`           // Último uso em uma busca no provedor
+	FailureCount    int        `bun:"failure_count,notnull,default:0" json:"failure_count"` // Recusas consecutivas pelo provedor
+	DisabledReason  string     `bun:"disabled_reason" json:"disabled_reason,omitempty"`     // Motivo da desativação automática (migração 037)
+	CreatedAt       time.Time  `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
+	UpdatedAt       time.Time  `bun:"updated_at,nullzero,notnull,default:current_timestamp" json:"updated_at"`
 
 	// Relacionamentos
 	Company *Company `bun:"rel:belongs-to,join:company_id=id" json:"company,omitempty"`
@@ -58,6 +61,35 @@ func (cc *CompanyCredential) GetCredentialData() (login, password, token string,
 	return crypto.DecryptCredentialData(cc.Type, cc.EncryptedSecret)
 }
 
+// CredentialInfo descreve o que uma credencial permite fazer, sem expor segredos
+type CredentialInfo struct {
+	ID          int64      `json:"id"`
+	Type        string     `json:"type"`
+	Environment string     `json:"environment,omitempty"`
+	HasToken    bool       `json:"has_token"`
+	HasLogin    bool       `json:"has_login"`
+	Active      bool       `json:"active"`
+	LastUsed    *time.Time `json:"last_used"`
+}
+
+// Info descriptografa a credencial e informa quais dados estão presentes
+func (cc *CompanyCredential) Info() (*CredentialInfo, error) {
+	login, password, token, err := cc.GetCredentialData()
+	if err != nil {
+		return nil, err
+	}
+
+	return &CredentialInfo{
+		ID:          cc.ID,
+		Type:        cc.Type,
+		Environment: cc.Environment,
+		HasToken:    token != "",
+		HasLogin:    login != "" && password != "",
+		Active:      cc.Active,
+		LastUsed:    cc.LastUsedAt,
+	}, nil
+}
+
 // BeforeAppendModel hook para atualizar timestamps
 func (cc *CompanyCredential) BeforeAppendModel(ctx context.Context, query bun.Query) error {
 	switch query.(type) {
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCredentialInfo(t *testing.T) {
+	lastUsed := time.Date(2025, 3, 14, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		credType     string
+		login        string
+		password     string
+		token        string
+		wantHasToken bool
+		wantHasLogin bool
+	}{
+		{"token", "prefeitura_token", "", "", "tok-secret-123", true, false},
+		{"login and password", "prefeitura_user_pass", "fiscal-login", "pass-secret-456", "", false, true},
+		{"mixed with both", "prefeitura_mixed", "fiscal-login", "pass-secret-456", "tok-secret-123", true, true},
+		{"mixed with token only", "prefeitura_mixed", "", "", "tok-secret-123", true, false},
+		{"mixed with login only", "prefeitura_mixed", "fiscal-login", "pass-secret-456", "", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			credential := &CompanyCredential{ID: 3, Type: tt.credType, Environment: EnvironmentStaging, Active: true, LastUsedAt: &lastUsed}
+			if err := credential.SetCredentialData(tt.login, tt.password, tt.token); err != nil {
+				t.Fatal(err)
+			}
+
+			info, err := credential.Info()
+			if err != nil {
+				t.Fatalf("Info() error = %v", err)
+			}
+			if info.HasToken != tt.wantHasToken || info.HasLogin != tt.wantHasLogin {
+				t.Errorf("Info() has_token %v has_login %v, want %v %v", info.HasToken, info.HasLogin, tt.wantHasToken, tt.wantHasLogin)
+			}
+			if info.Type != tt.credType || info.Environment != EnvironmentStaging || !info.Active || info.LastUsed == nil || !info.LastUsed.Equal(lastUsed) {
+				t.Errorf("Info() = %+v, want the credential type, environment, status and last use", info)
+			}
+
+			encoded, err := json.Marshal(info)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, secret := range []string{tt.login, tt.password, tt.token, credential.EncryptedSecret} {
+				if secret != "" && strings.Contains(string(encoded), secret) {
+					t.Errorf("Info() JSON %s exposes %q", encoded, secret)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// CompetenceClosure marca uma competência (YYYY-MM) de uma empresa como fechada: nenhuma
+// nota nova é esperada. Notas que chegarem depois são marcadas com LateArrival.
+// Reabrir a competência remove o registro.
+type CompetenceClosure struct {
+	bun.BaseModel `bun:"table:competence_closures,alias:cc"`
+
+	ID         int64     `bun:"id,pk,autoincrement" json:"id"`
+	CompanyID  int64     `bun:"company_id,notnull" json:"company_id"`
+	Competence string    `bun:"competence,notnull,type:varchar(7)" json:"competence"`
+	ClosedBy   int64     `bun:"closed_by" json:"closed_by,omitempty"`
+	CreatedAt  time.Time `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"closed_at"`
+
+	// Relacionamentos
+	Company *Company `bun:"rel:belongs-to,join:company_id=id" json:"company,omitempty"`
+}
+
+// BeforeAppendModel hook para definir timestamps
+func (cc *CompetenceClosure) BeforeAppendModel(ctx context.Context, query bun.Query) error {
+	switch query.(type) {
+	case *bun.InsertQuery:
+		cc.CreatedAt = time.Now()
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// DeadLetter registra um XML que falhou de forma permanente na leitura ou validação.
+// O conteúdo original fica no storage em StorageKey para ser inspecionado e reprocessado.
+type DeadLetter struct {
+	bun.BaseModel `bun:"table:dead_letters,alias:dl"`
+
+	ID                    int64     `bun:"id,pk,autoincrement" json:"id"`
+	CompanyID             int64     `bun:"company_id,notnull" json:"company_id"`
+	FileName              string    `bun:"file_name,notnull" json:"file_name"`
+	StorageKey            string    `bun:"storage_key,notnull" json:"storage_key"`
+	Source                string    `bun:"source" json:"source,omitempty"`         // Origem do documento
+	Competence            string    `bun:"competence" json:"competence,omitempty"` // Competência informada pelo provedor
+	Error                 string    `bun:"error" json:"error"`
+	Status                string    `bun:"status,notnull,default:'pending'" json:"status"` // pending, reprocessed
+	Attempts              int       `bun:"attempts,notnull,default:0" json:"attempts"`     // Reprocessamentos tentados
+	ReprocessedDocumentID int64     `bun:"reprocessed_document_id,nullzero" json:"reprocessed_document_id,omitempty"`
+	CreatedAt             time.Time `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
+	UpdatedAt             time.Time `bun:"updated_at,nullzero,notnull,default:current_timestamp" json:"updated_at"`
+
+	// Relacionamentos
+	Company *Company `bun:"rel:belongs-to,join:company_id=id" json:"company,omitempty"`
+}
+
+// Status do item na fila de mensagens mortas
+const (
+	DeadLetterStatusPending     = "pending"
+	DeadLetterStatusReprocessed = "reprocessed"
+)
+
+// BeforeAppendModel hook para definir timestamps
+func (dl *DeadLetter) BeforeAppendModel(ctx context.Context, query bun.Query) error {
+	switch query.(type) {
+	case *bun.InsertQuery:
+		dl.CreatedAt = time.Now()
+		dl.UpdatedAt = time.Now()
+	case *bun.UpdateQuery:
+		dl.UpdatedAt = time.Now()
+	}
+	return nil
+}
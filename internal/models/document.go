@@ -13,44 +13,81 @@ type Document struct {
 
 	ID         int64     `bun:"id,pk,autoincrement" json:"id"`
 	CompanyID  int64     `bun:"company_id,notnull" json:"company_id"`
-	Type       string    `bun:"type,notnull" json:"type"` // ex: 'NFSe', 'NFe', 'CTe'
+	Type       string    `bun:"type,notnull" json:"type"` // nfse, nfe ou cte (DocumentType*)
 	Key        string    `bun:"key" json:"key,omitempty"` // Chave de acesso do documento
 	Number     string    `bun:"number" json:"number,omitempty"`
 	Series     string    `bun:"series" json:"series,omitempty"`
 	IssueDate  time.Time `bun:"issue_date" json:"issue_date,omitempty"`
 	DueDate    time.Time `bun:"due_date" json:"due_date,omitempty"`
 	Amount     float64   `bun:"amount" json:"amount,omitempty"`
-	Status     string    `bun:"status,notnull,default:'pending'" json:"status"` // 'pending', 'processed', 'error'
+	Status     string    `bun:"status,notnull,default:'pending'" json:"status"` // 'pending', 'processed', 'reviewed', 'error'
 	StorageKey string    `bun:"storage_key" json:"storage_key,omitempty"`       // Chave no MinIO/S3
 	Hash       string    `bun:"hash" json:"hash,omitempty"`                     // Hash do arquivo para verificação de integridade
 	Metadata   string    `bun:"metadata,type:jsonb" json:"metadata,omitempty"`  // Metadados adicionais em JSON
 
+	OriginalFileName string `bun:"original_file_name,type:varchar(500)" json:"original_file_name,omitempty"` // Nome do arquivo recebido; não faz parte da storage_key (migração 051)
+
 	// NFSe specific fields for intelligent deduplication
-	VerificationCode      string    `bun:"verification_code" json:"verification_code,omitempty"`
-	ProviderCNPJ          string    `bun:"provider_cnpj" json:"provider_cnpj,omitempty"`
-	TakerCNPJ             string    `bun:"taker_cnpj" json:"taker_cnpj,omitempty"`
-	ServiceValue          float64   `bun:"service_value" json:"service_value,omitempty"`
-	ServiceCode           string    `bun:"service_code" json:"service_code,omitempty"`
-	MunicipalRegistration string    `bun:"municipal_registration" json:"municipal_registration,omitempty"`
-	DocumentHash          string    `bun:"document_hash" json:"document_hash,omitempty"`
+	// (colunas adicionadas pela migração 008_add_nfse_document_columns)
+	VerificationCode      string    `bun:"verification_code,type:varchar(255)" json:"verification_code,omitempty"`
+	ProviderCNPJ          string    `bun:"provider_cnpj,type:varchar(18)" json:"provider_cnpj,omitempty"`
+	TakerCNPJ             string    `bun:"taker_cnpj,type:varchar(18)" json:"taker_cnpj,omitempty"`
+	ServiceValue          float64   `bun:"service_value,type:decimal(15,2)" json:"service_value,omitempty"`
+	IssValue              float64   `bun:"iss_value,type:decimal(15,2)" json:"iss_value,omitempty"` // Valor do ISS (migração 038)
+	ServiceCode           string    `bun:"service_code,type:varchar(50)" json:"service_code,omitempty"`
+	NaturezaOperacao      string    `bun:"natureza_operacao,type:varchar(10)" json:"natureza_operacao,omitempty"` // Natureza da operação (migração 011)
+	MunicipalRegistration string    `bun:"municipal_registration,type:varchar(50)" json:"municipal_registration,omitempty"`
+	DocumentHash          string    `bun:"document_hash,type:varchar(64)" json:"document_hash,omitempty"`
+	ContentHash           string    `bun:"content_hash,type:varchar(64)" json:"content_hash,omitempty"` // SHA-256 do XML recebido (migração 032)
 	IsCancelled           bool      `bun:"is_cancelled,default:false" json:"is_cancelled"`
 	IsSubstituted         bool      `bun:"is_substituted,default:false" json:"is_substituted"`
-	ProcessingDate        time.Time `bun:"processing_date" json:"processing_date,omitempty"`
+	ProcessingDate        time.Time `bun:"processing_date,type:timestamp" json:"processing_date,omitempty"`
+	Tags                  []string  `bun:"tags,array,nullzero" json:"tags,omitempty"`                                // Etiquetas livres do usuário (migração 015)
+	ZeroValueFlagged      bool      `bun:"zero_value_flagged,notnull,default:false" json:"zero_value_flagged"`       // Valor de serviço zero a conferir (migração 016)
+	CNPJMismatchFlagged   bool      `bun:"cnpj_mismatch_flagged,notnull,default:false" json:"cnpj_mismatch_flagged"` // Nem prestador nem tomador é a empresa (migração 021)
+	LegalHold             bool      `bun:"legal_hold,notnull,default:false" json:"legal_hold"`                       // Isento da política de retenção (migração 019)
+	LateArrival           bool      `bun:"late_arrival,notnull,default:false" json:"late_arrival"`                   // Chegou após a competência ser fechada (migração 034)
 
 	// Additional important NFSe fields
-	Competence        string    `bun:"competence" json:"competence,omitempty"`
-	RpsIssueDate      time.Time `bun:"rps_issue_date" json:"rps_issue_date,omitempty"`
-	TakerName         string    `bun:"taker_name" json:"taker_name,omitempty"`
-	ProviderName      string    `bun:"provider_name" json:"provider_name,omitempty"`
-	ProviderTradeName string    `bun:"provider_trade_name" json:"provider_trade_name,omitempty"`
+	Competence        string    `bun:"competence,type:varchar(50)" json:"competence,omitempty"`
+	CompetenceMonth   string    `bun:"competence_month,type:varchar(7),nullzero" json:"competence_month,omitempty"` // Competência normalizada (YYYY-MM), usada nos filtros (migração 045)
+	RpsIssueDate      time.Time `bun:"rps_issue_date,type:timestamp" json:"rps_issue_date,omitempty"`
+	RpsNumber         string    `bun:"rps_number,type:varchar(50)" json:"rps_number,omitempty"` // Identificação do RPS de origem (migração 020)
+	RpsSeries         string    `bun:"rps_series,type:varchar(20)" json:"rps_series,omitempty"`
+	RpsType           string    `bun:"rps_type,type:varchar(10)" json:"rps_type,omitempty"`
+	TakerName         string    `bun:"taker_name,type:varchar(255)" json:"taker_name,omitempty"`
+	ProviderName      string    `bun:"provider_name,type:varchar(255)" json:"provider_name,omitempty"`
+	ProviderTradeName string    `bun:"provider_trade_name,type:varchar(255)" json:"provider_trade_name,omitempty"`
+	TakerAddress      *Address  `bun:"taker_address,type:jsonb" json:"taker_address,omitempty"` // Endereço do tomador (migração 035)
+	ProviderAddress   *Address  `bun:"provider_address,type:jsonb" json:"provider_address,omitempty"`
+	Discriminacao     string    `bun:"discriminacao,type:text" json:"discriminacao,omitempty"` // Descrição do serviço, com busca textual (migração 040)
 
 	CreatedAt time.Time `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
 	UpdatedAt time.Time `bun:"updated_at,nullzero,notnull,default:current_timestamp" json:"updated_at"`
+	DeletedAt time.Time `bun:"deleted_at,soft_delete,nullzero" json:"deleted_at,omitempty"` // Exclusão lógica (migração 013)
 
 	// Relacionamentos
 	Company *Company `bun:"rel:belongs-to,join:company_id=id" json:"company,omitempty"`
 }
 
+// Tipos de documento, detectados pelo elemento raiz do XML
+const (
+	DocumentTypeNFSe = "nfse"
+	DocumentTypeNFe  = "nfe" // NF-e modelo 55
+	DocumentTypeCTe  = "cte" // CT-e modelo 57
+)
+
+// Status de documentos
+const (
+	DocumentStatusPending   = "pending"
+	DocumentStatusProcessed = "processed"
+	DocumentStatusReviewed  = "reviewed"
+	DocumentStatusError     = "error"
+)
+
+// ReviewableStatuses lista os status a partir dos quais um documento pode ser revisado
+var ReviewableStatuses = []string{DocumentStatusProcessed}
+
 // IsProcessed verifica se o documento foi processado
 func (d *Document) IsProcessed() bool {
 	return d.Status == "processed"
@@ -0,0 +1,101 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/zoomxml/internal/crypto"
+)
+
+// CompanyExportTarget é o bucket S3 do cliente para onde os XMLs da empresa são copiados.
+// A chave secreta fica criptografada, como as credenciais do provedor.
+type CompanyExportTarget struct {
+	bun.BaseModel `bun:"table:company_export_targets,alias:cet"`
+
+	ID                 int64     `bun:"id,pk,autoincrement" json:"id"`
+	CompanyID          int64     `bun:"company_id,notnull,unique" json:"company_id"`
+	Endpoint           string    `bun:"endpoint,notnull" json:"endpoint"` // ex.: s3.amazonaws.com
+	Region             string    `bun:"region" json:"region,omitempty"`
+	Bucket             string    `bun:"bucket,notnull" json:"bucket"`
+	Prefix             string    `bun:"prefix" json:"prefix,omitempty"` // Prefixo das chaves no bucket de destino
+	UseSSL             bool      `bun:"use_ssl,notnull,default:true" json:"use_ssl"`
+	AccessKey          string    `bun:"access_key,notnull" json:"access_key"`
+	EncryptedSecretKey string    `bun:"encrypted_secret_key,notnull" json:"-"` // Chave secreta criptografada - não expor no JSON
+	CreatedAt          time.Time `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
+	UpdatedAt          time.Time `bun:"updated_at,nullzero,notnull,default:current_timestamp" json:"updated_at"`
+}
+
+// SetSecretKey define a chave secreta criptografada
+func (t *CompanyExportTarget) SetSecretKey(secretKey string) error {
+	encrypted, err := crypto.Encrypt(secretKey)
+	if err != nil {
+		return err
+	}
+	t.EncryptedSecretKey = encrypted
+	return nil
+}
+
+// GetSecretKey retorna a chave secreta descriptografada
+func (t *CompanyExportTarget) GetSecretKey() (string, error) {
+	return crypto.Decrypt(t.EncryptedSecretKey)
+}
+
+// BeforeAppendModel hook para definir timestamps
+func (t *CompanyExportTarget) BeforeAppendModel(ctx context.Context, query bun.Query) error {
+	switch query.(type) {
+	case *bun.InsertQuery:
+		t.CreatedAt = time.Now()
+		t.UpdatedAt = time.Now()
+	case *bun.UpdateQuery:
+		t.UpdatedAt = time.Now()
+	}
+	return nil
+}
+
+// ExportJob acompanha a cópia dos XMLs de uma empresa para o bucket de destino. O
+// progresso é gravado a cada lote, então uma cópia interrompida continua de
+// LastDocumentID, e objetos já presentes no destino são pulados.
+type ExportJob struct {
+	bun.BaseModel `bun:"table:export_jobs,alias:ej"`
+
+	ID             int64     `bun:"id,pk,autoincrement" json:"id"`
+	CompanyID      int64     `bun:"company_id,notnull" json:"company_id"`
+	RequestedBy    int64     `bun:"requested_by" json:"requested_by,omitempty"`
+	Status         string    `bun:"status,notnull,default:'pending'" json:"status"` // pending, running, completed, failed
+	Total          int       `bun:"total,notnull,default:0" json:"total"`
+	Copied         int       `bun:"copied,notnull,default:0" json:"copied"`
+	Skipped        int       `bun:"skipped,notnull,default:0" json:"skipped"` // Já presentes no destino
+	Failed         int       `bun:"failed,notnull,default:0" json:"failed"`
+	LastDocumentID int64     `bun:"last_document_id,notnull,default:0" json:"last_document_id"`
+	LastError      string    `bun:"last_error" json:"last_error,omitempty"`
+	FinishedAt     time.Time `bun:"finished_at,nullzero" json:"finished_at,omitempty"`
+	LockedUntil    time.Time `bun:"locked_until,nullzero" json:"-"` // Fim da reserva do worker que avança o job (migração 049)
+	CreatedAt      time.Time `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
+	UpdatedAt      time.Time `bun:"updated_at,nullzero,notnull,default:current_timestamp" json:"updated_at"`
+}
+
+// Status da exportação
+const (
+	ExportStatusPending   = "pending"
+	ExportStatusRunning   = "running"
+	ExportStatusCompleted = "completed"
+	ExportStatusFailed    = "failed" // o destino ficou inacessível; uma nova exportação retoma sem copiar de novo
+)
+
+// JobID identifica a exportação para os workers que a reivindicam
+func (j *ExportJob) JobID() int64 {
+	return j.ID
+}
+
+// BeforeAppendModel hook para definir timestamps
+func (j *ExportJob) BeforeAppendModel(ctx context.Context, query bun.Query) error {
+	switch query.(type) {
+	case *bun.InsertQuery:
+		j.CreatedAt = time.Now()
+		j.UpdatedAt = time.Now()
+	case *bun.UpdateQuery:
+		j.UpdatedAt = time.Now()
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// IntegrityCheck registra uma comparação entre os documentos de uma empresa no banco e
+// os XMLs no storage. Drift é a soma de objetos ausentes e órfãos; acima do limite
+// configurado, DriftDetected é marcado.
+type IntegrityCheck struct {
+	bun.BaseModel `bun:"table:integrity_checks,alias:ic"`
+
+	ID             int64     `bun:"id,pk,autoincrement" json:"id"`
+	CompanyID      int64     `bun:"company_id,notnull" json:"company_id"`
+	Documents      int       `bun:"documents,notnull,default:0" json:"documents"`             // Documentos NFSe com XML armazenado
+	Objects        int       `bun:"objects,notnull,default:0" json:"objects"`                 // XMLs da empresa encontrados no storage
+	MissingObjects int       `bun:"missing_objects,notnull,default:0" json:"missing_objects"` // Documentos cujo XML não está no storage
+	OrphanObjects  int       `bun:"orphan_objects,notnull,default:0" json:"orphan_objects"`   // XMLs sob o CNPJ da empresa sem documento
+	Drift          int       `bun:"drift,notnull,default:0" json:"drift"`
+	Threshold      int       `bun:"threshold,notnull,default:0" json:"threshold"`
+	DriftDetected  bool      `bun:"drift_detected,notnull,default:false" json:"drift_detected"`
+	CreatedAt      time.Time `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"checked_at"`
+
+	// Relacionamentos
+	Company *Company `bun:"rel:belongs-to,join:company_id=id" json:"company,omitempty"`
+}
+
+// BeforeAppendModel hook para definir timestamps
+func (ic *IntegrityCheck) BeforeAppendModel(ctx context.Context, query bun.Query) error {
+	switch query.(type) {
+	case *bun.InsertQuery:
+		ic.CreatedAt = time.Now()
+	}
+	return nil
+}
@@ -13,6 +13,15 @@ func RegisterModels(db *bun.DB) {
 		(*CompanyCredential)(nil),
 		(*Document)(nil),
 		(*AuditLog)(nil),
+		(*ProcessingLog)(nil),
+		(*PendingIngest)(nil),
+		(*ReprocessBatch)(nil),
+		(*DeadLetter)(nil),
+		(*CompanyExportTarget)(nil),
+		(*ExportJob)(nil),
+		(*CompetenceClosure)(nil),
+		(*IntegrityCheck)(nil),
+		(*RefreshToken)(nil),
 	)
 }
 
@@ -25,5 +34,14 @@ func GetAllModels() []interface{} {
 		(*CompanyCredential)(nil),
 		(*Document)(nil),
 		(*AuditLog)(nil),
+		(*ProcessingLog)(nil),
+		(*PendingIngest)(nil),
+		(*ReprocessBatch)(nil),
+		(*DeadLetter)(nil),
+		(*CompanyExportTarget)(nil),
+		(*ExportJob)(nil),
+		(*CompetenceClosure)(nil),
+		(*IntegrityCheck)(nil),
+		(*RefreshToken)(nil),
 	}
 }
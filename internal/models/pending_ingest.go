@@ -0,0 +1,40 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// PendingIngest guarda um XML obtido do provedor que não pôde ser armazenado
+// (storage indisponível), até que a nova tentativa de ingestão tenha sucesso
+type PendingIngest struct {
+	bun.BaseModel `bun:"table:pending_ingests,alias:pi"`
+
+	ID         int64     `bun:"id,pk,autoincrement" json:"id"`
+	CompanyID  int64     `bun:"company_id,notnull" json:"company_id"`
+	FileName   string    `bun:"file_name,notnull" json:"file_name"`
+	XMLContent string    `bun:"xml_content,notnull" json:"-"`
+	Source     string    `bun:"source" json:"source,omitempty"`         // Origem dos documentos
+	Competence string    `bun:"competence" json:"competence,omitempty"` // Competência informada pelo provedor (YYYY-MM), usada se o XML não tiver
+	Attempts   int       `bun:"attempts,notnull,default:0" json:"attempts"`
+	LastError  string    `bun:"last_error" json:"last_error,omitempty"`
+	CreatedAt  time.Time `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
+	UpdatedAt  time.Time `bun:"updated_at,nullzero,notnull,default:current_timestamp" json:"updated_at"`
+
+	// Relacionamentos
+	Company *Company `bun:"rel:belongs-to,join:company_id=id" json:"company,omitempty"`
+}
+
+// BeforeAppendModel hook para definir timestamps
+func (pi *PendingIngest) BeforeAppendModel(ctx context.Context, query bun.Query) error {
+	switch query.(type) {
+	case *bun.InsertQuery:
+		pi.CreatedAt = time.Now()
+		pi.UpdatedAt = time.Now()
+	case *bun.UpdateQuery:
+		pi.UpdatedAt = time.Now()
+	}
+	return nil
+}
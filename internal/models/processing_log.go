@@ -0,0 +1,47 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// ProcessingLog representa o registro de processamento de um lote de documentos
+type ProcessingLog struct {
+	bun.BaseModel `bun:"table:processing_logs,alias:pl"`
+
+	ID                 int64     `bun:"id,pk,autoincrement" json:"id"`
+	CompanyID          int64     `bun:"company_id,notnull" json:"company_id"`
+	BatchID            string    `bun:"batch_id,notnull" json:"batch_id"`
+	Operation          string    `bun:"operation,notnull" json:"operation"` // ex: 'process_batch_xml'
+	Source             string    `bun:"source" json:"source,omitempty"`     // Origem dos documentos
+	Target             string    `bun:"target" json:"target,omitempty"`     // Destino dos arquivos
+	Status             string    `bun:"status,notnull" json:"status"`       // success, partial, failed
+	Message            string    `bun:"message" json:"message,omitempty"`
+	TotalDocuments     int       `bun:"total_documents" json:"total_documents"`
+	ProcessedDocuments int       `bun:"processed_documents" json:"processed_documents"`
+	DuplicateDocuments int       `bun:"duplicate_documents" json:"duplicate_documents"`
+	ErrorDocuments     int       `bun:"error_documents" json:"error_documents"`
+	DurationMs         int64     `bun:"duration_ms" json:"duration_ms"`
+	CreatedAt          time.Time `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
+
+	// Relacionamentos
+	Company *Company `bun:"rel:belongs-to,join:company_id=id" json:"company,omitempty"`
+}
+
+// Status do processamento de um lote
+const (
+	ProcessingStatusSuccess = "success"
+	ProcessingStatusPartial = "partial"
+	ProcessingStatusFailed  = "failed"
+)
+
+// BeforeAppendModel hook para definir timestamp
+func (pl *ProcessingLog) BeforeAppendModel(ctx context.Context, query bun.Query) error {
+	switch query.(type) {
+	case *bun.InsertQuery:
+		pl.CreatedAt = time.Now()
+	}
+	return nil
+}
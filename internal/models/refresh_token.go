@@ -0,0 +1,45 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// RefreshToken registra um refresh token emitido no login. Só o hash SHA-256 do token é
+// guardado; cada uso revoga o registro e aponta para o token emitido no lugar dele.
+// Cada refresh token leva um token de acesso de curta duração, válido enquanto o
+// registro não for revogado.
+type RefreshToken struct {
+	bun.BaseModel `bun:"table:refresh_tokens,alias:rt"`
+
+	ID           int64     `bun:"id,pk,autoincrement" json:"id"`
+	UserID       int64     `bun:"user_id,notnull" json:"user_id"`
+	TokenHash    string    `bun:"token_hash,unique,notnull" json:"-"`
+	ExpiresAt    time.Time `bun:"expires_at,notnull" json:"expires_at"`
+	RevokedAt    time.Time `bun:"revoked_at,nullzero" json:"revoked_at,omitempty"`
+	ReplacedByID int64     `bun:"replaced_by_id,nullzero" json:"replaced_by_id,omitempty"` // Token emitido na rotação
+	CreatedAt    time.Time `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
+
+	// Token de acesso de curta duração emitido junto com o refresh token
+	AccessTokenHash string    `bun:"access_token_hash,nullzero" json:"-"`
+	AccessExpiresAt time.Time `bun:"access_expires_at,nullzero" json:"access_expires_at,omitempty"`
+
+	// Relacionamentos
+	User *User `bun:"rel:belongs-to,join:user_id=id" json:"user,omitempty"`
+}
+
+// IsActive indica se o token ainda pode ser usado
+func (rt *RefreshToken) IsActive(now time.Time) bool {
+	return rt.RevokedAt.IsZero() && now.Before(rt.ExpiresAt)
+}
+
+// BeforeAppendModel hook para definir timestamps
+func (rt *RefreshToken) BeforeAppendModel(ctx context.Context, query bun.Query) error {
+	switch query.(type) {
+	case *bun.InsertQuery:
+		rt.CreatedAt = time.Now()
+	}
+	return nil
+}
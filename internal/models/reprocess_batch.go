@@ -0,0 +1,56 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// ReprocessBatch acompanha o reprocessamento de todos os documentos de uma empresa
+// com o parser atual. O progresso é gravado a cada lote, então um lote interrompido
+// continua de LastDocumentID no próximo ciclo.
+type ReprocessBatch struct {
+	bun.BaseModel `bun:"table:reprocess_batches,alias:rb"`
+
+	ID             int64     `bun:"id,pk,autoincrement" json:"id"`
+	CompanyID      int64     `bun:"company_id,notnull" json:"company_id"`
+	RequestedBy    int64     `bun:"requested_by" json:"requested_by,omitempty"`
+	Status         string    `bun:"status,notnull,default:'pending'" json:"status"` // pending, running, completed
+	Total          int       `bun:"total,notnull,default:0" json:"total"`
+	Processed      int       `bun:"processed,notnull,default:0" json:"processed"`
+	Failed         int       `bun:"failed,notnull,default:0" json:"failed"`
+	LastDocumentID int64     `bun:"last_document_id,notnull,default:0" json:"last_document_id"`
+	LastError      string    `bun:"last_error" json:"last_error,omitempty"`
+	FinishedAt     time.Time `bun:"finished_at,nullzero" json:"finished_at,omitempty"`
+	LockedUntil    time.Time `bun:"locked_until,nullzero" json:"-"` // Fim da reserva do worker que avança o lote (migração 049)
+	CreatedAt      time.Time `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
+	UpdatedAt      time.Time `bun:"updated_at,nullzero,notnull,default:current_timestamp" json:"updated_at"`
+
+	// Relacionamentos
+	Company *Company `bun:"rel:belongs-to,join:company_id=id" json:"company,omitempty"`
+}
+
+// Status do reprocessamento
+const (
+	ReprocessStatusPending   = "pending"
+	ReprocessStatusRunning   = "running"
+	ReprocessStatusCompleted = "completed"
+)
+
+// JobID identifica o lote para os workers que o reivindicam
+func (rb *ReprocessBatch) JobID() int64 {
+	return rb.ID
+}
+
+// BeforeAppendModel hook para definir timestamps
+func (rb *ReprocessBatch) BeforeAppendModel(ctx context.Context, query bun.Query) error {
+	switch query.(type) {
+	case *bun.InsertQuery:
+		rb.CreatedAt = time.Now()
+		rb.UpdatedAt = time.Now()
+	case *bun.UpdateQuery:
+		rb.UpdatedAt = time.Now()
+	}
+	return nil
+}
@@ -2,7 +2,9 @@ package permissions
 
 import (
 	"context"
+	"database/sql"
 	"errors"
+	"fmt"
 
 	"github.com/zoomxml/internal/database"
 	"github.com/zoomxml/internal/models"
@@ -32,9 +34,12 @@ func CanAccessCompany(ctx context.Context, user *models.User, companyID int64) e
 		Where("id = ? AND active = true", companyID).
 		Scan(ctx)
 
-	if err != nil {
+	if errors.Is(err, sql.ErrNoRows) {
 		return ErrCompanyNotFound
 	}
+	if err != nil {
+		return fmt.Errorf("failed to load company: %w", err)
+	}
 
 	// If company is not restricted, any authenticated user can access it
 	if !company.Restricted {
@@ -42,11 +47,7 @@ func CanAccessCompany(ctx context.Context, user *models.User, companyID int64) e
 	}
 
 	// For restricted companies, check if user is a member
-	exists, err := database.DB.NewSelect().
-		Model((*models.CompanyMember)(nil)).
-		Where("user_id = ? AND company_id = ?", user.ID, companyID).
-		Exists(ctx)
-
+	exists, err := isCompanyMember(ctx, user.ID, companyID)
 	if err != nil {
 		return err
 	}
@@ -58,39 +59,74 @@ func CanAccessCompany(ctx context.Context, user *models.User, companyID int64) e
 	return nil
 }
 
+// isCompanyMember checks whether a user is a member of a company
+func isCompanyMember(ctx context.Context, userID, companyID int64) (bool, error) {
+	return database.DB.NewSelect().
+		Model((*models.CompanyMember)(nil)).
+		Where("user_id = ? AND company_id = ?", userID, companyID).
+		Exists(ctx)
+}
+
+// companyAdminFields are the company columns only admins may update
+var companyAdminFields = map[string]bool{
+	"restricted":    true,
+	"active":        true,
+	"debug_capture": true,
+
+	"document_limit":        true,
+	"document_limit_policy": true,
+}
+
+// DeniedCompanyFields returns the columns of an update the user is not allowed to change.
+// Admins may change every field, members every field except the admin-only ones, and
+// other users (including on unrestricted companies) none.
+func DeniedCompanyFields(ctx context.Context, user *models.User, companyID int64, columns []string) ([]string, error) {
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	if user.IsAdmin() {
+		return nil, nil
+	}
+
+	member, err := isCompanyMember(ctx, user.ID, companyID)
+	if err != nil {
+		return nil, err
+	}
+
+	denied := []string{}
+	for _, column := range columns {
+		if !member || companyAdminFields[column] {
+			denied = append(denied, column)
+		}
+	}
+
+	return denied, nil
+}
+
 // CanManageCredentials checks if a user can manage credentials for a company
 func CanManageCredentials(ctx context.Context, user *models.User, companyID int64) error {
-	// For now, credential management has the same permissions as company access
-	// This can be extended in the future for more granular permissions
-	return CanAccessCompany(ctx, user, companyID)
+	return HasScope(ctx, user, companyID, ScopeCredentialsManage)
 }
 
 // CanViewCredentials checks if a user can view credentials for a company
 func CanViewCredentials(ctx context.Context, user *models.User, companyID int64) error {
-	// For now, viewing credentials has the same permissions as company access
-	// This can be extended in the future for more granular permissions
-	return CanAccessCompany(ctx, user, companyID)
+	return HasScope(ctx, user, companyID, ScopeCredentialsManage)
 }
 
 // CanCreateCredentials checks if a user can create credentials for a company
 func CanCreateCredentials(ctx context.Context, user *models.User, companyID int64) error {
-	// For now, creating credentials has the same permissions as company access
-	// This can be extended in the future for more granular permissions
-	return CanAccessCompany(ctx, user, companyID)
+	return HasScope(ctx, user, companyID, ScopeCredentialsManage)
 }
 
 // CanUpdateCredentials checks if a user can update credentials for a company
 func CanUpdateCredentials(ctx context.Context, user *models.User, companyID int64) error {
-	// For now, updating credentials has the same permissions as company access
-	// This can be extended in the future for more granular permissions
-	return CanAccessCompany(ctx, user, companyID)
+	return HasScope(ctx, user, companyID, ScopeCredentialsManage)
 }
 
 // CanDeleteCredentials checks if a user can delete credentials for a company
 func CanDeleteCredentials(ctx context.Context, user *models.User, companyID int64) error {
-	// For now, deleting credentials has the same permissions as company access
-	// This can be extended in the future for more granular permissions
-	return CanAccessCompany(ctx, user, companyID)
+	return HasScope(ctx, user, companyID, ScopeCredentialsManage)
 }
 
 // GetAccessibleCompanies returns a list of company IDs that the user can access
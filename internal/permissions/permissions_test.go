@@ -0,0 +1,56 @@
+package permissions
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/zoomxml/internal/database/databasetest"
+	"github.com/zoomxml/internal/models"
+)
+
+// TestDeniedCompanyFieldsWithoutDatabase covers the users decided without a membership lookup
+func TestDeniedCompanyFieldsWithoutDatabase(t *testing.T) {
+	databasetest.UseClosed(t)
+	ctx := context.Background()
+
+	if _, err := DeniedCompanyFields(ctx, nil, 1, []string{"name"}); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("DeniedCompanyFields(nil) error = %v, want ErrUserNotFound", err)
+	}
+
+	admin := &models.User{ID: 1, Role: "admin"}
+	denied, err := DeniedCompanyFields(ctx, admin, 1, []string{"name", "restricted", "active", "debug_capture"})
+	if err != nil || len(denied) != 0 {
+		t.Errorf("DeniedCompanyFields(admin) = %v, %v, want every field allowed", denied, err)
+	}
+}
+
+func TestDeniedCompanyFields(t *testing.T) {
+	databasetest.Require(t)
+	company := databasetest.CreateCompany(t, nil)
+	member := databasetest.CreateUser(t, "user", company)
+	outsider := databasetest.CreateUser(t, "user")
+	columns := []string{"name", "phone", "auto_fetch", "restricted", "active", "debug_capture"}
+
+	tests := []struct {
+		name string
+		user *models.User
+		want []string
+	}{
+		{"member changes all but the admin fields", member, []string{"restricted", "active", "debug_capture"}},
+		{"outsider changes nothing", outsider, columns},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			denied, err := DeniedCompanyFields(context.Background(), tt.user, company.ID, columns)
+			if err != nil {
+				t.Fatalf("DeniedCompanyFields() error = %v", err)
+			}
+			if !slices.Equal(denied, tt.want) {
+				t.Errorf("DeniedCompanyFields() = %v, want %v", denied, tt.want)
+			}
+		})
+	}
+}
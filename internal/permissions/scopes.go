@@ -0,0 +1,70 @@
+package permissions
+
+import (
+	"context"
+	"strings"
+
+	"github.com/zoomxml/config"
+	"github.com/zoomxml/internal/models"
+)
+
+// Scope is a permission required by a route
+type Scope string
+
+const (
+	ScopeDocumentsRead     Scope = "documents:read"     // list, download and inspect documents
+	ScopeDocumentsWrite    Scope = "documents:write"    // fetch, upload, tag and review documents
+	ScopeCredentialsManage Scope = "credentials:manage" // view and change company credentials
+	ScopeAdmin             Scope = "admin"              // system-wide operations
+)
+
+// userScopeGranted reports whether AUTH_USER_SCOPES grants a scope to non-admin users on
+// the companies they can access. The admin scope is never granted to them.
+func userScopeGranted(scope Scope) bool {
+	if scope == ScopeAdmin {
+		return false
+	}
+	for _, granted := range config.Get().Auth.UserScopes {
+		if Scope(strings.TrimSpace(granted)) == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// HasScope checks whether a user holds a scope, on a company when companyID is not zero.
+// Admins hold every scope. Other users hold the scopes of AUTH_USER_SCOPES on a company:
+// documents:read on the companies they can access (CanAccessCompany), the write scopes
+// only on the companies they are members of, and none outside a company.
+// It returns nil, ErrUserNotFound, ErrAccessDenied, ErrCompanyNotFound or a lookup error.
+func HasScope(ctx context.Context, user *models.User, companyID int64, scope Scope) error {
+	if user == nil {
+		return ErrUserNotFound
+	}
+
+	if user.IsAdmin() {
+		return nil
+	}
+
+	if companyID == 0 || !userScopeGranted(scope) {
+		return ErrAccessDenied
+	}
+
+	if err := CanAccessCompany(ctx, user, companyID); err != nil {
+		return err
+	}
+
+	if scope == ScopeDocumentsRead {
+		return nil
+	}
+
+	member, err := isCompanyMember(ctx, user.ID, companyID)
+	if err != nil {
+		return err
+	}
+	if !member {
+		return ErrAccessDenied
+	}
+
+	return nil
+}
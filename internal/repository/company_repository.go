@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/models"
+)
+
+// CompanyRepository abstracts the company reads used by NFSe fetch and ingest (provider,
+// ingest policies and document limit), so they can run against a fake instead of Postgres
+type CompanyRepository interface {
+	// FindCompany loads the given columns of a company, or all of them without columns.
+	// It returns sql.ErrNoRows when the company does not exist.
+	FindCompany(ctx context.Context, companyID int64, columns ...string) (*models.Company, error)
+}
+
+// bunCompanyRepository implements CompanyRepository on the global database connection
+type bunCompanyRepository struct{}
+
+// NewCompanyRepository returns the Postgres-backed company repository
+func NewCompanyRepository() CompanyRepository {
+	return &bunCompanyRepository{}
+}
+
+// FindCompany implements CompanyRepository
+func (r *bunCompanyRepository) FindCompany(ctx context.Context, companyID int64, columns ...string) (*models.Company, error) {
+	company := &models.Company{}
+	query := database.DB.NewSelect().
+		Model(company).
+		Where("id = ?", companyID)
+	if len(columns) > 0 {
+		query = query.Column(columns...)
+	}
+
+	if err := query.Scan(ctx); err != nil {
+		return nil, err
+	}
+	return company, nil
+}
@@ -0,0 +1,227 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/zoomxml/config"
+	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/models"
+)
+
+// DocumentRepository abstracts the document reads and writes used by NFSe ingest,
+// so the ingest pipeline can run against a fake instead of Postgres
+type DocumentRepository interface {
+	// FindDuplicateCandidates returns the company's documents matching any of the
+	// given access keys, verification codes, numbers, document hashes or content hashes.
+	// Soft-deleted documents are included: a note removed by retention or eviction must
+	// not be ingested again.
+	FindDuplicateCandidates(ctx context.Context, companyID int64, accessKeys, verificationCodes, numbers, documentHashes, contentHashes []string) ([]models.Document, error)
+
+	// InsertDocuments inserts the documents of one company, filling in their IDs, within
+	// the company document limit. Under a rejecting limit only the leading documents that
+	// fit are inserted; under an evicting one the oldest documents past the limit are
+	// soft-deleted afterwards.
+	InsertDocuments(ctx context.Context, documents []*models.Document, limit DocumentLimit) (InsertResult, error)
+
+	// UpdateDocumentIfUnchanged replaces a document's columns (except id, company_id and
+	// created_at) only if its updated_at still equals expectedUpdatedAt. It reports
+	// whether the row was updated.
+	UpdateDocumentIfUnchanged(ctx context.Context, document *models.Document, expectedUpdatedAt time.Time) (bool, error)
+
+	// CountDocuments counts the live (not deleted) NFSe documents of a company
+	CountDocuments(ctx context.Context, companyID int64) (int, error)
+}
+
+// DocumentLimit caps the live NFSe documents of a company on insert
+type DocumentLimit struct {
+	Limit int  // 0 = no limit
+	Evict bool // soft-delete the oldest documents past the limit instead of rejecting new ones
+}
+
+// InsertResult reports what InsertDocuments did
+type InsertResult struct {
+	Inserted int // leading documents inserted; the rest did not fit the limit
+	Evicted  int // documents soft-deleted to stay within the limit
+
+	// EvictedKeys are the storage keys of the evicted documents, whose derived objects
+	// (DANFSE PDFs) the caller may remove; the XML stays for deduplication
+	EvictedKeys []string
+}
+
+// bunDocumentRepository implements DocumentRepository on the global database connection
+type bunDocumentRepository struct {
+	insertChunkSize int
+}
+
+// NewDocumentRepository returns the Postgres-backed document repository
+func NewDocumentRepository() DocumentRepository {
+	return &bunDocumentRepository{
+		insertChunkSize: config.Get().Database.InsertChunkSize,
+	}
+}
+
+// FindDuplicateCandidates implements DocumentRepository
+func (r *bunDocumentRepository) FindDuplicateCandidates(ctx context.Context, companyID int64, accessKeys, verificationCodes, numbers, documentHashes, contentHashes []string) ([]models.Document, error) {
+	documents := []models.Document{}
+	if len(accessKeys) == 0 && len(verificationCodes) == 0 && len(numbers) == 0 && len(documentHashes) == 0 && len(contentHashes) == 0 {
+		return documents, nil
+	}
+
+	err := database.DB.NewSelect().
+		Model(&documents).
+		WhereAllWithDeleted().
+		Where("company_id = ?", companyID).
+		WhereGroup(" AND ", func(q *bun.SelectQuery) *bun.SelectQuery {
+			if len(accessKeys) > 0 {
+				q = q.WhereOr("key IN (?)", bun.In(accessKeys))
+			}
+			if len(verificationCodes) > 0 {
+				q = q.WhereOr("verification_code IN (?)", bun.In(verificationCodes))
+			}
+			if len(numbers) > 0 {
+				q = q.WhereOr("number IN (?)", bun.In(numbers))
+			}
+			if len(documentHashes) > 0 {
+				q = q.WhereOr("document_hash IN (?)", bun.In(documentHashes))
+			}
+			if len(contentHashes) > 0 {
+				q = q.WhereOr("content_hash IN (?)", bun.In(contentHashes))
+			}
+			return q
+		}).
+		Scan(ctx)
+
+	return documents, err
+}
+
+// InsertDocuments implements DocumentRepository. With a limit, the company row is locked
+// for the count, insert and eviction, so concurrent ingests cannot overshoot it.
+func (r *bunDocumentRepository) InsertDocuments(ctx context.Context, documents []*models.Document, limit DocumentLimit) (InsertResult, error) {
+	result := InsertResult{}
+	if len(documents) == 0 {
+		return result, nil
+	}
+	companyID := documents[0].CompanyID
+
+	chunkSize := r.insertChunkSize
+	if chunkSize <= 0 {
+		chunkSize = len(documents)
+	}
+
+	// Chunks share the caller's pointers, so the IDs filled in by each INSERT land on the
+	// same documents and index-based result mapping keeps working
+	err := database.DB.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		result = InsertResult{}
+		admitted := documents
+
+		if limit.Limit > 0 {
+			_, err := tx.NewSelect().
+				Model((*models.Company)(nil)).
+				Column("id").
+				Where("id = ?", companyID).
+				For("UPDATE").
+				Exec(ctx)
+			if err != nil {
+				return err
+			}
+
+			if !limit.Evict {
+				count, err := countLiveDocuments(ctx, tx, companyID)
+				if err != nil {
+					return err
+				}
+				admitted = documents[:min(len(documents), max(limit.Limit-count, 0))]
+			}
+		}
+
+		for start := 0; start < len(admitted); start += chunkSize {
+			chunk := admitted[start:min(start+chunkSize, len(admitted))]
+			if _, err := tx.NewInsert().Model(&chunk).Exec(ctx); err != nil {
+				return err
+			}
+		}
+		result.Inserted = len(admitted)
+
+		if limit.Limit > 0 && limit.Evict {
+			inserted := make([]int64, len(admitted))
+			for i, document := range admitted {
+				inserted[i] = document.ID
+			}
+			evicted, err := evictOldestDocuments(ctx, tx, companyID, limit.Limit, inserted)
+			if err != nil {
+				return err
+			}
+			result.Evicted = len(evicted)
+			result.EvictedKeys = evicted
+		}
+		return nil
+	})
+
+	return result, err
+}
+
+// CountDocuments implements DocumentRepository
+func (r *bunDocumentRepository) CountDocuments(ctx context.Context, companyID int64) (int, error) {
+	return countLiveDocuments(ctx, database.DB, companyID)
+}
+
+// countLiveDocuments counts the NFSe documents of a company that were not deleted
+func countLiveDocuments(ctx context.Context, db bun.IDB, companyID int64) (int, error) {
+	return db.NewSelect().
+		Model((*models.Document)(nil)).
+		Where("company_id = ? AND type = 'nfse'", companyID).
+		Count(ctx)
+}
+
+// evictOldestDocuments soft-deletes the oldest NFSe documents of a company past limit, by
+// issue date or creation date without one, and returns their storage keys. Documents under
+// legal hold are kept, and so are the inserted ones: a back-dated note just stored may be
+// the oldest, and evicting it would report it stored while dropping it for good, since
+// deleted documents still count as duplicates.
+func evictOldestDocuments(ctx context.Context, db bun.IDB, companyID int64, limit int, inserted []int64) ([]string, error) {
+	count, err := countLiveDocuments(ctx, db, companyID)
+	if err != nil || count <= limit {
+		return nil, err
+	}
+
+	oldest := db.NewSelect().
+		Model((*models.Document)(nil)).
+		Column("id").
+		Where("company_id = ? AND type = 'nfse'", companyID).
+		Where("legal_hold = false").
+		Where("id NOT IN (?)", bun.In(inserted)).
+		OrderExpr("COALESCE(NULLIF(issue_date, '0001-01-01'), created_at) ASC, id ASC").
+		Limit(count - limit)
+
+	var keys []string
+	_, err = db.NewDelete().
+		Model((*models.Document)(nil)).
+		Where("id IN (?)", oldest).
+		Where("company_id = ?", companyID).
+		Returning("COALESCE(storage_key, '')").
+		Exec(ctx, &keys)
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// UpdateDocumentIfUnchanged implements DocumentRepository
+func (r *bunDocumentRepository) UpdateDocumentIfUnchanged(ctx context.Context, document *models.Document, expectedUpdatedAt time.Time) (bool, error) {
+	res, err := database.DB.NewUpdate().
+		Model(document).
+		ExcludeColumn("id", "company_id", "created_at", "tags", "legal_hold", "late_arrival"). // tags and legal hold belong to the user, late arrival to the first ingest
+		WherePK().
+		Where("company_id = ?", document.CompanyID).
+		Where("updated_at = ?", expectedUpdatedAt).
+		Exec(ctx)
+
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := res.RowsAffected()
+	return rows > 0, err
+}
@@ -0,0 +1,155 @@
+// Package repositorytest provides in-memory repositories for tests that exercise the
+// ingest pipeline without Postgres.
+package repositorytest
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/zoomxml/internal/models"
+	"github.com/zoomxml/internal/repository"
+)
+
+// DocumentRepository keeps documents in memory. Deleted documents stay in the slice
+// with DeletedAt set, as soft deletes do in Postgres.
+type DocumentRepository struct {
+	Documents []*models.Document
+	InsertErr error // returned by InsertDocuments when set
+
+	nextID int64
+}
+
+// Add stores documents as they are, assigning their IDs
+func (r *DocumentRepository) Add(documents ...*models.Document) {
+	for _, document := range documents {
+		r.nextID++
+		document.ID = r.nextID
+		r.Documents = append(r.Documents, document)
+	}
+}
+
+// FindDuplicateCandidates implements repository.DocumentRepository
+func (r *DocumentRepository) FindDuplicateCandidates(ctx context.Context, companyID int64, accessKeys, verificationCodes, numbers, documentHashes, contentHashes []string) ([]models.Document, error) {
+	documents := []models.Document{}
+	for _, document := range r.Documents {
+		if document.CompanyID != companyID {
+			continue
+		}
+		if slices.Contains(accessKeys, document.Key) ||
+			slices.Contains(verificationCodes, document.VerificationCode) ||
+			slices.Contains(numbers, document.Number) ||
+			slices.Contains(documentHashes, document.DocumentHash) ||
+			slices.Contains(contentHashes, document.ContentHash) {
+			documents = append(documents, *document)
+		}
+	}
+	return documents, nil
+}
+
+// InsertDocuments implements repository.DocumentRepository, enforcing the limit as the
+// Postgres repository does
+func (r *DocumentRepository) InsertDocuments(ctx context.Context, documents []*models.Document, limit repository.DocumentLimit) (repository.InsertResult, error) {
+	result := repository.InsertResult{}
+	if r.InsertErr != nil {
+		return result, r.InsertErr
+	}
+	if len(documents) == 0 {
+		return result, nil
+	}
+	companyID := documents[0].CompanyID
+
+	admitted := documents
+	if limit.Limit > 0 && !limit.Evict {
+		count, _ := r.CountDocuments(ctx, companyID)
+		admitted = documents[:min(len(documents), max(limit.Limit-count, 0))]
+	}
+
+	for _, document := range admitted {
+		document.CreatedAt = time.Now()
+		document.UpdatedAt = document.CreatedAt
+	}
+	r.Add(admitted...)
+	result.Inserted = len(admitted)
+
+	if limit.Limit > 0 && limit.Evict {
+		result.EvictedKeys = r.evictOldest(companyID, limit.Limit, admitted)
+		result.Evicted = len(result.EvictedKeys)
+	}
+	return result, nil
+}
+
+// evictOldest soft-deletes the oldest live documents of a company past limit, keeping
+// the inserted ones as evictOldestDocuments does, and returns their storage keys
+func (r *DocumentRepository) evictOldest(companyID int64, limit int, inserted []*models.Document) []string {
+	live := []*models.Document{}
+	for _, document := range r.Documents {
+		if document.CompanyID == companyID && document.DeletedAt.IsZero() {
+			live = append(live, document)
+		}
+	}
+	sort.SliceStable(live, func(i, j int) bool {
+		if !live[i].IssueDate.Equal(live[j].IssueDate) {
+			return live[i].IssueDate.Before(live[j].IssueDate)
+		}
+		return live[i].ID < live[j].ID
+	})
+
+	keys := []string{}
+	for _, document := range live {
+		if len(live)-len(keys) <= limit {
+			break
+		}
+		if document.LegalHold || slices.Contains(inserted, document) {
+			continue
+		}
+		document.DeletedAt = time.Now()
+		keys = append(keys, document.StorageKey)
+	}
+	return keys
+}
+
+// UpdateDocumentIfUnchanged implements repository.DocumentRepository
+func (r *DocumentRepository) UpdateDocumentIfUnchanged(ctx context.Context, document *models.Document, expectedUpdatedAt time.Time) (bool, error) {
+	for i, stored := range r.Documents {
+		if stored.ID == document.ID && stored.CompanyID == document.CompanyID {
+			if !stored.UpdatedAt.Equal(expectedUpdatedAt) {
+				return false, nil
+			}
+			updated := *document
+			updated.CreatedAt = stored.CreatedAt
+			updated.UpdatedAt = time.Now()
+			r.Documents[i] = &updated
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// CountDocuments implements repository.DocumentRepository
+func (r *DocumentRepository) CountDocuments(ctx context.Context, companyID int64) (int, error) {
+	count := 0
+	for _, document := range r.Documents {
+		if document.CompanyID == companyID && document.Type == models.DocumentTypeNFSe && document.DeletedAt.IsZero() {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// CompanyRepository serves companies from a map, ignoring the column list
+type CompanyRepository struct {
+	Companies map[int64]*models.Company
+}
+
+// FindCompany implements repository.CompanyRepository
+func (r *CompanyRepository) FindCompany(ctx context.Context, companyID int64, columns ...string) (*models.Company, error) {
+	company, ok := r.Companies[companyID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	copied := *company
+	return &copied, nil
+}
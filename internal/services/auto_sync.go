@@ -0,0 +1,78 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/bun"
+	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/models"
+)
+
+// SetCompaniesAutoFetch enables or disables the scheduled fetch of the given companies,
+// or of every company when companyIDs is empty, in one transaction. Pausing records the
+// companies it turned off (auto_fetch_paused); resuming every company restores only
+// those, leaving companies that were off before the pause or whose registration became
+// inactive alone. It returns how many companies changed; companies already in the
+// requested state are not counted.
+func SetCompaniesAutoFetch(ctx context.Context, enabled bool, companyIDs []int64) (int, error) {
+	var affected int64
+
+	err := database.DB.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		query := tx.NewUpdate().
+			Model((*models.Company)(nil)).
+			Set("auto_fetch = ?", enabled).
+			Set("updated_at = current_timestamp")
+
+		switch {
+		case !enabled:
+			query = query.Set("auto_fetch_paused = true").Where("auto_fetch = true")
+		case len(companyIDs) > 0:
+			query = query.Set("auto_fetch_paused = false").Where("auto_fetch = false")
+		default:
+			query = query.Set("auto_fetch_paused = false").Where("auto_fetch_paused = true AND registration_inactive = false")
+		}
+		if len(companyIDs) > 0 {
+			query = query.Where("id IN (?)", bun.In(companyIDs))
+		}
+
+		res, err := query.Exec(ctx)
+		if err != nil {
+			return err
+		}
+		if affected, err = res.RowsAffected(); err != nil {
+			return err
+		}
+
+		if !enabled {
+			return nil
+		}
+
+		// The pause is over for the resumed companies, including the ones left off
+		clear := tx.NewUpdate().
+			Model((*models.Company)(nil)).
+			Set("auto_fetch_paused = false").
+			Where("auto_fetch_paused = true")
+		if len(companyIDs) > 0 {
+			clear = clear.Where("id IN (?)", bun.In(companyIDs))
+		}
+		_, err = clear.Exec(ctx)
+		return err
+	})
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to update auto fetch: %w", err)
+	}
+
+	return int(affected), nil
+}
+
+// companyAutoFetchEnabled reports whether a company is still due for scheduled fetches.
+// Lookup failures keep the company, as when the run started.
+func companyAutoFetchEnabled(ctx context.Context, companyID int64) bool {
+	exists, err := database.DB.NewSelect().
+		Model((*models.Company)(nil)).
+		Where("id = ? AND auto_fetch = true AND active = true", companyID).
+		Exists(ctx)
+	return err != nil || exists
+}
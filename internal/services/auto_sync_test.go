@@ -0,0 +1,56 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/uptrace/bun"
+	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/database/databasetest"
+	"github.com/zoomxml/internal/models"
+)
+
+func TestSetCompaniesAutoFetchResumesOnlyPaused(t *testing.T) {
+	databasetest.Require(t)
+	ctx := context.Background()
+
+	enabled := func(c *models.Company) { c.AutoFetch = true }
+	running := databasetest.CreateCompany(t, enabled)
+	off := databasetest.CreateCompany(t, nil)
+	inactive := databasetest.CreateCompany(t, enabled)
+	ids := []int64{running.ID, off.ID, inactive.ID}
+
+	paused, err := SetCompaniesAutoFetch(ctx, false, ids)
+	if err != nil {
+		t.Fatalf("SetCompaniesAutoFetch(pause) error = %v", err)
+	}
+	if paused != 2 {
+		t.Errorf("SetCompaniesAutoFetch(pause) = %d, want 2", paused)
+	}
+
+	// The registration of one company became inactive during the pause
+	if _, err := database.DB.NewUpdate().Model((*models.Company)(nil)).
+		Set("registration_inactive = true").Where("id = ?", inactive.ID).Exec(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := SetCompaniesAutoFetch(ctx, true, nil); err != nil {
+		t.Fatalf("SetCompaniesAutoFetch(resume) error = %v", err)
+	}
+
+	companies := []models.Company{}
+	if err := database.DB.NewSelect().Model(&companies).Column("id", "auto_fetch", "auto_fetch_paused").
+		Where("id IN (?)", bun.In(ids)).Scan(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[int64]bool{running.ID: true, off.ID: false, inactive.ID: false}
+	for _, company := range companies {
+		if company.AutoFetch != want[company.ID] {
+			t.Errorf("company %d auto_fetch = %v, want %v", company.ID, company.AutoFetch, want[company.ID])
+		}
+		if company.AutoFetchPaused {
+			t.Errorf("company %d is still marked as paused", company.ID)
+		}
+	}
+}
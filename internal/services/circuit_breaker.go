@@ -0,0 +1,209 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/zoomxml/config"
+	"github.com/zoomxml/internal/logger"
+)
+
+// ErrCircuitOpen is returned without calling the provider while its circuit is open
+var ErrCircuitOpen = errors.New("provider circuit open: too many consecutive failures")
+
+// Circuit breaker states
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half_open" // cooldown elapsed; one trial call is let through
+)
+
+// CircuitBreakerState is a snapshot of the breaker of one provider host
+type CircuitBreakerState struct {
+	Provider            string     `json:"provider"`
+	State               string     `json:"state"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	OpenedAt            *time.Time `json:"opened_at,omitempty"`
+	RetryAt             *time.Time `json:"retry_at,omitempty"`
+}
+
+// CircuitBreaker stops calling a provider after consecutive failures. After the cooldown
+// it half-opens: a single trial call decides whether it closes again or reopens.
+type CircuitBreaker struct {
+	mu        sync.Mutex
+	provider  string
+	threshold int // 0 disables the breaker
+	cooldown  time.Duration
+	now       func() time.Time
+
+	state    string
+	failures int
+	openedAt time.Time
+	trial    bool // a half-open trial call is in flight
+}
+
+// NewCircuitBreaker creates a closed breaker; threshold 0 disables it
+func NewCircuitBreaker(provider string, threshold int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		provider:  provider,
+		threshold: threshold,
+		cooldown:  cooldown,
+		now:       time.Now,
+		state:     CircuitClosed,
+	}
+}
+
+// Allow reports whether a call may go to the provider, returning ErrCircuitOpen otherwise
+func (b *CircuitBreaker) Allow() error {
+	if b.threshold <= 0 {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case CircuitOpen:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			return ErrCircuitOpen
+		}
+		b.state = CircuitHalfOpen
+		b.trial = true
+		return nil
+	case CircuitHalfOpen:
+		if b.trial {
+			return ErrCircuitOpen
+		}
+		b.trial = true
+		return nil
+	}
+
+	return nil
+}
+
+// RecordSuccess closes the breaker
+func (b *CircuitBreaker) RecordSuccess() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state != CircuitClosed {
+		logger.InfoWithFields("Provider circuit closed", map[string]any{
+			"operation": "circuit_breaker",
+			"provider":  b.provider,
+		})
+	}
+
+	b.state = CircuitClosed
+	b.failures = 0
+	b.trial = false
+}
+
+// RecordFailure counts a failed call, opening the breaker at the threshold or when the
+// half-open trial fails
+func (b *CircuitBreaker) RecordFailure() {
+	if b.threshold <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.trial = false
+
+	if b.state == CircuitHalfOpen || b.failures >= b.threshold {
+		b.state = CircuitOpen
+		b.openedAt = b.now()
+
+		logger.WarnWithFields("Provider circuit opened", map[string]any{
+			"operation":            "circuit_breaker",
+			"provider":             b.provider,
+			"consecutive_failures": b.failures,
+			"cooldown":             b.cooldown.String(),
+		})
+	}
+}
+
+// Release ends a call whose outcome says nothing about the provider, such as one cancelled
+// by the caller: it counts neither way, but frees the half-open trial slot
+func (b *CircuitBreaker) Release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trial = false
+}
+
+// recordCallError counts a failed call, or only releases the breaker when the caller's
+// context ended the call
+func (b *CircuitBreaker) recordCallError(ctx context.Context) {
+	if ctx.Err() != nil {
+		b.Release()
+		return
+	}
+	b.RecordFailure()
+}
+
+// State returns a snapshot of the breaker
+func (b *CircuitBreaker) State() CircuitBreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	state := CircuitBreakerState{
+		Provider:            b.provider,
+		State:               b.state,
+		ConsecutiveFailures: b.failures,
+	}
+	if b.state != CircuitClosed {
+		openedAt := b.openedAt
+		retryAt := openedAt.Add(b.cooldown)
+		state.OpenedAt = &openedAt
+		state.RetryAt = &retryAt
+	}
+	return state
+}
+
+var (
+	providerBreakersMu sync.Mutex
+	providerBreakers   = make(map[string]*CircuitBreaker)
+)
+
+// providerBreaker returns the breaker shared by every call to the host of baseURL
+func providerBreaker(baseURL string) *CircuitBreaker {
+	provider := baseURL
+	if parsed, err := url.Parse(baseURL); err == nil && parsed.Host != "" {
+		provider = parsed.Host
+	}
+
+	providerBreakersMu.Lock()
+	defer providerBreakersMu.Unlock()
+
+	breaker, ok := providerBreakers[provider]
+	if !ok {
+		cfg := config.Get().NFSeScheduler
+		breaker = NewCircuitBreaker(provider, cfg.BreakerFailureThreshold, cfg.BreakerCooldown)
+		providerBreakers[provider] = breaker
+	}
+	return breaker
+}
+
+// ProviderBreakerStates returns the state of the breaker of every provider called so far
+func ProviderBreakerStates() []CircuitBreakerState {
+	providerBreakersMu.Lock()
+	breakers := make([]*CircuitBreaker, 0, len(providerBreakers))
+	for _, breaker := range providerBreakers {
+		breakers = append(breakers, breaker)
+	}
+	providerBreakersMu.Unlock()
+
+	states := make([]CircuitBreakerState, 0, len(breakers))
+	for _, breaker := range breakers {
+		states = append(states, breaker.State())
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Provider < states[j].Provider })
+
+	return states
+}
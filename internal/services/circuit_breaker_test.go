@@ -0,0 +1,140 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// newTestBreaker returns a breaker with the given threshold and a one minute cooldown,
+// whose clock advance moves forward
+func newTestBreaker(threshold int) (*CircuitBreaker, func(time.Duration)) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	breaker := NewCircuitBreaker("provider", threshold, time.Minute)
+	breaker.now = func() time.Time { return now }
+	return breaker, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestCircuitBreakerOpensAtThreshold(t *testing.T) {
+	breaker, _ := newTestBreaker(3)
+
+	breaker.RecordFailure()
+	breaker.RecordFailure()
+	if err := breaker.Allow(); err != nil {
+		t.Fatalf("Allow() below threshold = %v, want the call", err)
+	}
+
+	// A success in between resets the count of consecutive failures
+	breaker.RecordSuccess()
+	breaker.RecordFailure()
+	breaker.RecordFailure()
+	if state := breaker.State(); state.State != CircuitClosed || state.ConsecutiveFailures != 2 {
+		t.Fatalf("state = %s with %d failures, want %s with 2", state.State, state.ConsecutiveFailures, CircuitClosed)
+	}
+
+	breaker.RecordFailure()
+	state := breaker.State()
+	if state.State != CircuitOpen || state.OpenedAt == nil || state.RetryAt == nil || state.RetryAt.Sub(*state.OpenedAt) != time.Minute {
+		t.Fatalf("state at threshold = %+v, want %s retrying after the cooldown", state, CircuitOpen)
+	}
+	if err := breaker.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Allow() while open = %v, want ErrCircuitOpen", err)
+	}
+}
+
+func TestCircuitBreakerHalfOpenCloses(t *testing.T) {
+	breaker, advance := newTestBreaker(2)
+	breaker.RecordFailure()
+	breaker.RecordFailure()
+
+	advance(time.Minute)
+	if err := breaker.Allow(); err != nil {
+		t.Fatalf("Allow() after cooldown = %v, want the trial call", err)
+	}
+	if state := breaker.State().State; state != CircuitHalfOpen {
+		t.Fatalf("state after cooldown = %s, want %s", state, CircuitHalfOpen)
+	}
+
+	// Only one trial call is in flight at a time
+	if err := breaker.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Allow() during the trial = %v, want ErrCircuitOpen", err)
+	}
+
+	breaker.RecordSuccess()
+	state := breaker.State()
+	if state.State != CircuitClosed || state.ConsecutiveFailures != 0 || state.OpenedAt != nil {
+		t.Errorf("state after successful trial = %+v, want %s without failures", state, CircuitClosed)
+	}
+	if err := breaker.Allow(); err != nil {
+		t.Errorf("Allow() once closed = %v, want the call", err)
+	}
+}
+
+func TestCircuitBreakerHalfOpenReopens(t *testing.T) {
+	breaker, advance := newTestBreaker(2)
+	breaker.RecordFailure()
+	breaker.RecordFailure()
+
+	advance(time.Minute)
+	if err := breaker.Allow(); err != nil {
+		t.Fatalf("Allow() after cooldown = %v, want the trial call", err)
+	}
+
+	// A failed trial reopens the breaker for a new cooldown, below the threshold too
+	breaker.RecordFailure()
+	if state := breaker.State().State; state != CircuitOpen {
+		t.Fatalf("state after failed trial = %s, want %s", state, CircuitOpen)
+	}
+	advance(30 * time.Second)
+	if err := breaker.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Allow() during the new cooldown = %v, want ErrCircuitOpen", err)
+	}
+	advance(30 * time.Second)
+	if err := breaker.Allow(); err != nil {
+		t.Errorf("Allow() after the new cooldown = %v, want a trial call", err)
+	}
+}
+
+func TestCircuitBreakerDisabled(t *testing.T) {
+	breaker, _ := newTestBreaker(0)
+	for range 10 {
+		breaker.RecordFailure()
+	}
+	if err := breaker.Allow(); err != nil {
+		t.Errorf("Allow() with threshold 0 = %v, want the call", err)
+	}
+}
+
+func TestCircuitBreakerCancelledTrial(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	breaker := NewCircuitBreaker("provider", 1, time.Minute)
+	breaker.now = func() time.Time { return now }
+
+	breaker.RecordFailure()
+	if err := breaker.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Allow() during cooldown = %v, want ErrCircuitOpen", err)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if err := breaker.Allow(); err != nil {
+		t.Fatalf("Allow() after cooldown = %v, want the trial call", err)
+	}
+
+	// The trial is cancelled by its caller: it neither reopens the breaker nor keeps the slot
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	breaker.recordCallError(ctx)
+
+	if state := breaker.State().State; state != CircuitHalfOpen {
+		t.Errorf("state after cancelled trial = %s, want %s", state, CircuitHalfOpen)
+	}
+	if err := breaker.Allow(); err != nil {
+		t.Errorf("Allow() after cancelled trial = %v, want a new trial call", err)
+	}
+
+	breaker.recordCallError(context.Background())
+	if state := breaker.State().State; state != CircuitOpen {
+		t.Errorf("state after failed trial = %s, want %s", state, CircuitOpen)
+	}
+}
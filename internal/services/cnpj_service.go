@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"regexp"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/rs/zerolog/log"
@@ -15,6 +16,12 @@ import (
 
 var reDigits = regexp.MustCompile(`\D`)
 
+// NormalizeCNPJ mantém apenas os dígitos do CNPJ, para que "12.345.678/0001-90" e
+// "12345678000190" gerem as mesmas chaves e caminhos de armazenamento
+func NormalizeCNPJ(cnpj string) string {
+	return reDigits.ReplaceAllString(cnpj, "")
+}
+
 // CNPJData representa os dados retornados pela API do CNPJá
 type CNPJData struct {
 	CNPJ                string   `json:"cnpj"`
@@ -37,21 +44,87 @@ type CNPJData struct {
 	RegistrationStatus  string   `json:"registration_status"`
 }
 
+// Consultas de CNPJ são compartilhadas por todas as instâncias do serviço: o resultado fica
+// em cache por cnpjCacheTTL e as requisições à API pública, que limita cada cliente a poucas
+// consultas por minuto, são espaçadas em cnpjRequestInterval
+const (
+	cnpjCacheTTL        = 24 * time.Hour
+	cnpjRequestInterval = 12 * time.Second
+)
+
+type cnpjCacheEntry struct {
+	data    CNPJData
+	expires time.Time
+}
+
+var (
+	cnpjCacheMu     sync.Mutex
+	cnpjCache       = map[string]cnpjCacheEntry{}
+	cnpjLimiterMu   sync.Mutex
+	cnpjNextRequest time.Time
+)
+
+// cachedCNPJ retorna uma cópia da consulta em cache ainda válida
+func cachedCNPJ(cnpj string) (*CNPJData, bool) {
+	cnpjCacheMu.Lock()
+	defer cnpjCacheMu.Unlock()
+
+	entry, ok := cnpjCache[cnpj]
+	if !ok || time.Now().After(entry.expires) {
+		return nil, false
+	}
+	data := entry.data
+	return &data, true
+}
+
+// storeCNPJ guarda uma consulta no cache, descartando as expiradas
+func storeCNPJ(cnpj string, data *CNPJData) {
+	cnpjCacheMu.Lock()
+	defer cnpjCacheMu.Unlock()
+
+	now := time.Now()
+	for key, entry := range cnpjCache {
+		if now.After(entry.expires) {
+			delete(cnpjCache, key)
+		}
+	}
+	cnpjCache[cnpj] = cnpjCacheEntry{data: *data, expires: now.Add(cnpjCacheTTL)}
+}
+
+// waitCNPJRequest reserva a próxima vaga de requisição à API e espera por ela
+func waitCNPJRequest(ctx context.Context) error {
+	cnpjLimiterMu.Lock()
+	now := time.Now()
+	slot := cnpjNextRequest
+	if slot.Before(now) {
+		slot = now
+	}
+	cnpjNextRequest = slot.Add(cnpjRequestInterval)
+	cnpjLimiterMu.Unlock()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(time.Until(slot)):
+		return nil
+	}
+}
+
 type CNPJService struct {
 	client *http.Client
 }
 
-func NewCNPJService() *CNPJService {
+// NewCNPJService cria o serviço de consulta de CNPJ usando o transporte informado
+// (normalmente SharedTransport())
+func NewCNPJService(transport http.RoundTripper) *CNPJService {
 	return &CNPJService{
-		client: &http.Client{
-			Timeout: 15 * time.Second,
-		},
+		client: newHTTPClient(transport, 15*time.Second),
 	}
 }
 
 // limparCNPJ remove todos os caracteres não numéricos
 func (s *CNPJService) limparCNPJ(cnpj string) string {
-	return reDigits.ReplaceAllString(cnpj, "")
+	return NormalizeCNPJ(cnpj)
 }
 
 // validarCNPJ valida se o CNPJ é válido usando o algoritmo oficial
@@ -100,7 +173,8 @@ func (s *CNPJService) validarCNPJ(cnpj string) bool {
 	return d1 == digit12 && d2 == digit13
 }
 
-// ConsultarCNPJ consulta os dados do CNPJ na API do CNPJá
+// ConsultarCNPJ consulta os dados do CNPJ na API do CNPJá, reaproveitando consultas
+// recentes do cache
 func (s *CNPJService) ConsultarCNPJ(ctx context.Context, cnpjRaw string) (*CNPJData, error) {
 	cnpj := s.limparCNPJ(cnpjRaw)
 
@@ -108,6 +182,10 @@ func (s *CNPJService) ConsultarCNPJ(ctx context.Context, cnpjRaw string) (*CNPJD
 		return nil, errors.New("CNPJ inválido")
 	}
 
+	if data, ok := cachedCNPJ(cnpj); ok {
+		return data, nil
+	}
+
 	url := fmt.Sprintf("https://open.cnpja.com/office/%s", cnpj)
 
 	log.Info().
@@ -117,6 +195,10 @@ func (s *CNPJService) ConsultarCNPJ(ctx context.Context, cnpjRaw string) (*CNPJD
 
 	backoff := 300 * time.Millisecond
 	for tentativa := 0; tentativa < 5; tentativa++ {
+		if err := waitCNPJRequest(ctx); err != nil {
+			return nil, err
+		}
+
 		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 		if err != nil {
 			return nil, fmt.Errorf("erro ao criar requisição: %w", err)
@@ -263,6 +345,7 @@ func (s *CNPJService) ConsultarCNPJ(ctx context.Context, cnpjRaw string) (*CNPJD
 			Str("name", cnpjData.Name).
 			Msg("CNPJ consultado com sucesso")
 
+		storeCNPJ(cnpj, cnpjData)
 		return cnpjData, nil
 	}
 
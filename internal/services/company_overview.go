@@ -0,0 +1,231 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/zoomxml/config"
+	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/models"
+)
+
+// CredentialTypeSummary counts the credentials of one type
+type CredentialTypeSummary struct {
+	Type   string `json:"type"`
+	Total  int    `json:"total"`
+	Active int    `json:"active"`
+}
+
+// CredentialsOverview summarizes the credentials of a company. Usable counts the active
+// credentials in the company default environment, the ones scheduled fetches prefer.
+type CredentialsOverview struct {
+	Total      int                     `json:"total"`
+	Active     int                     `json:"active"`
+	Usable     int                     `json:"usable"`
+	ByType     []CredentialTypeSummary `json:"by_type"`
+	LastUsedAt *time.Time              `json:"last_used_at,omitempty"`
+}
+
+// SyncOverview is the automatic sync state of a company. NextRunAt is an estimate (last
+// sync plus the scheduler interval, moved to the company's cron and window) and is
+// omitted when the company is not synced automatically or its schedule never runs.
+type SyncOverview struct {
+	AutoFetch        bool       `json:"auto_fetch"`
+	SchedulerEnabled bool       `json:"scheduler_enabled"`
+	Interval         string     `json:"interval"`
+	LastSyncAt       *time.Time `json:"last_sync_at,omitempty"`
+	LastSyncStatus   string     `json:"last_sync_status,omitempty"`
+	LastSyncError    string     `json:"last_sync_error,omitempty"`
+	NextRunAt        *time.Time `json:"next_run_at,omitempty"`
+}
+
+// DocumentsOverview totals the live NFSe documents of a company
+type DocumentsOverview struct {
+	Total         int        `json:"total"`
+	Cancelled     int        `json:"cancelled"`
+	TotalValue    float64    `json:"total_value"`
+	LastIssueDate *time.Time `json:"last_issue_date,omitempty"`
+}
+
+// PendingWorkOverview counts the background work still queued for a company
+type PendingWorkOverview struct {
+	PendingIngests   int   `json:"pending_ingests"`
+	DeadLetters      int   `json:"dead_letters"`
+	ReprocessBatchID int64 `json:"reprocess_batch_id,omitempty"` // unfinished reprocess batch, if any
+	Total            int   `json:"total"`
+}
+
+// CompanyOverview gathers what a company detail page shows in one response
+type CompanyOverview struct {
+	Company     *models.Company     `json:"company"`
+	Credentials CredentialsOverview `json:"credentials"`
+	Sync        SyncOverview        `json:"sync"`
+	Documents   DocumentsOverview   `json:"documents"`
+	Usage       DocumentUsage       `json:"usage"`
+	PendingWork PendingWorkOverview `json:"pending_work"`
+}
+
+// GetCompanyOverview assembles the overview of a company. Access must be checked by the caller.
+func GetCompanyOverview(ctx context.Context, companyID int64) (*CompanyOverview, error) {
+	company := &models.Company{}
+	if err := database.DB.NewSelect().Model(company).Where("id = ?", companyID).Scan(ctx); err != nil {
+		return nil, err
+	}
+
+	overview := &CompanyOverview{
+		Company: company,
+		Sync:    companySyncOverview(company, config.Get().NFSeScheduler),
+	}
+
+	var err error
+	if overview.Credentials, err = companyCredentialsOverview(ctx, company); err != nil {
+		return nil, err
+	}
+	if overview.Documents, err = companyDocumentsOverview(ctx, companyID); err != nil {
+		return nil, err
+	}
+	if overview.Usage, err = GetDocumentUsage(ctx, company); err != nil {
+		return nil, err
+	}
+	if overview.PendingWork, err = companyPendingWork(ctx, companyID); err != nil {
+		return nil, err
+	}
+
+	return overview, nil
+}
+
+// companyCredentialsOverview counts the credentials of a company by type and state
+func companyCredentialsOverview(ctx context.Context, company *models.Company) (CredentialsOverview, error) {
+	credentials := []models.CompanyCredential{}
+	err := database.DB.NewSelect().
+		Model(&credentials).
+		Column("type", "environment", "active", "last_used_at").
+		Where("company_id = ?", company.ID).
+		Order("type ASC").
+		Scan(ctx)
+	if err != nil {
+		return CredentialsOverview{}, fmt.Errorf("failed to load credentials: %w", err)
+	}
+
+	overview := CredentialsOverview{Total: len(credentials), ByType: []CredentialTypeSummary{}}
+	byType := map[string]int{}
+	for _, credential := range credentials {
+		index, ok := byType[credential.Type]
+		if !ok {
+			index = len(overview.ByType)
+			byType[credential.Type] = index
+			overview.ByType = append(overview.ByType, CredentialTypeSummary{Type: credential.Type})
+		}
+		overview.ByType[index].Total++
+
+		if !credential.Active {
+			continue
+		}
+		overview.Active++
+		overview.ByType[index].Active++
+		if credential.Environment == "" || credential.Environment == company.DefaultEnvironment {
+			overview.Usable++
+		}
+		if credential.LastUsedAt != nil && (overview.LastUsedAt == nil || credential.LastUsedAt.After(*overview.LastUsedAt)) {
+			overview.LastUsedAt = credential.LastUsedAt
+		}
+	}
+
+	return overview, nil
+}
+
+// companySyncOverview describes the automatic sync of a company
+func companySyncOverview(company *models.Company, cfg config.NFSeSchedulerConfig) SyncOverview {
+	overview := SyncOverview{
+		AutoFetch:        company.AutoFetch,
+		SchedulerEnabled: cfg.Enabled,
+		Interval:         cfg.Interval,
+		LastSyncStatus:   company.LastSyncStatus,
+		LastSyncError:    company.LastSyncError,
+	}
+	if !company.LastSyncAt.IsZero() {
+		lastSync := company.LastSyncAt
+		overview.LastSyncAt = &lastSync
+	}
+
+	interval, err := time.ParseDuration(cfg.Interval)
+	if err != nil || !cfg.Enabled || !company.AutoFetch || !company.Active {
+		return overview
+	}
+
+	base := time.Now()
+	if overview.LastSyncAt != nil && overview.LastSyncAt.Add(interval).After(base) {
+		base = overview.LastSyncAt.Add(interval)
+	}
+	if next := NextCompanySync(company, base); !next.IsZero() {
+		overview.NextRunAt = &next
+	}
+
+	return overview
+}
+
+// companyDocumentsOverview totals the live NFSe documents of a company
+func companyDocumentsOverview(ctx context.Context, companyID int64) (DocumentsOverview, error) {
+	var row struct {
+		Total         int        `bun:"total"`
+		Cancelled     int        `bun:"cancelled"`
+		TotalValue    float64    `bun:"total_value"`
+		LastIssueDate *time.Time `bun:"last_issue_date"`
+	}
+
+	err := database.DB.NewSelect().
+		Model((*models.Document)(nil)).
+		ColumnExpr("COUNT(*) AS total").
+		ColumnExpr("COUNT(*) FILTER (WHERE is_cancelled) AS cancelled").
+		ColumnExpr("COALESCE(SUM(service_value) FILTER (WHERE NOT is_cancelled), 0) AS total_value").
+		ColumnExpr("MAX(issue_date) AS last_issue_date").
+		Where("company_id = ? AND type = 'nfse'", companyID).
+		Scan(ctx, &row)
+	if err != nil {
+		return DocumentsOverview{}, fmt.Errorf("failed to total documents: %w", err)
+	}
+
+	return DocumentsOverview{
+		Total:         row.Total,
+		Cancelled:     row.Cancelled,
+		TotalValue:    row.TotalValue,
+		LastIssueDate: row.LastIssueDate,
+	}, nil
+}
+
+// companyPendingWork counts buffered ingests, pending dead letters and the unfinished
+// reprocess batch of a company
+func companyPendingWork(ctx context.Context, companyID int64) (PendingWorkOverview, error) {
+	overview := PendingWorkOverview{}
+
+	var err error
+	overview.PendingIngests, err = database.DB.NewSelect().
+		Model((*models.PendingIngest)(nil)).
+		Where("company_id = ?", companyID).
+		Count(ctx)
+	if err != nil {
+		return overview, fmt.Errorf("failed to count pending ingests: %w", err)
+	}
+
+	overview.DeadLetters, err = database.DB.NewSelect().
+		Model((*models.DeadLetter)(nil)).
+		Where("company_id = ? AND status = ?", companyID, models.DeadLetterStatusPending).
+		Count(ctx)
+	if err != nil {
+		return overview, fmt.Errorf("failed to count dead letters: %w", err)
+	}
+
+	batch, err := activeReprocessBatch(ctx, companyID)
+	if err != nil {
+		return overview, err
+	}
+
+	overview.Total = overview.PendingIngests + overview.DeadLetters
+	if batch != nil {
+		overview.ReprocessBatchID = batch.ID
+		overview.Total++
+	}
+
+	return overview, nil
+}
@@ -0,0 +1,135 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/zoomxml/config"
+	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/database/databasetest"
+	"github.com/zoomxml/internal/models"
+)
+
+func TestCompanySyncOverview(t *testing.T) {
+	enabled := config.NFSeSchedulerConfig{Enabled: true, Interval: "24h"}
+	recent := time.Now().Add(-time.Hour).Truncate(time.Second)
+	old := time.Now().AddDate(0, 0, -2)
+
+	tests := []struct {
+		name     string
+		company  models.Company
+		cfg      config.NFSeSchedulerConfig
+		wantNext string // "", "interval" (last sync plus the interval) or "now"
+	}{
+		{"synced recently", models.Company{Active: true, AutoFetch: true, LastSyncAt: recent}, enabled, "interval"},
+		{"sync overdue", models.Company{Active: true, AutoFetch: true, LastSyncAt: old}, enabled, "now"},
+		{"never synced", models.Company{Active: true, AutoFetch: true}, enabled, "now"},
+		{"auto fetch off", models.Company{Active: true, LastSyncAt: recent}, enabled, ""},
+		{"inactive company", models.Company{AutoFetch: true, LastSyncAt: recent}, enabled, ""},
+		{"scheduler disabled", models.Company{Active: true, AutoFetch: true, LastSyncAt: recent}, config.NFSeSchedulerConfig{Interval: "24h"}, ""},
+		{"invalid interval", models.Company{Active: true, AutoFetch: true, LastSyncAt: recent}, config.NFSeSchedulerConfig{Enabled: true, Interval: "daily"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.company.LastSyncStatus, tt.company.LastSyncError = "failed", "credential rejected"
+			before := time.Now()
+			got := companySyncOverview(&tt.company, tt.cfg)
+			after := time.Now()
+
+			if got.AutoFetch != tt.company.AutoFetch || got.SchedulerEnabled != tt.cfg.Enabled || got.Interval != tt.cfg.Interval ||
+				got.LastSyncStatus != "failed" || got.LastSyncError != "credential rejected" {
+				t.Errorf("companySyncOverview() = %+v, want the company and scheduler settings", got)
+			}
+			if (got.LastSyncAt == nil) != tt.company.LastSyncAt.IsZero() {
+				t.Errorf("LastSyncAt = %v, want %v", got.LastSyncAt, tt.company.LastSyncAt)
+			}
+
+			switch tt.wantNext {
+			case "":
+				if got.NextRunAt != nil {
+					t.Errorf("NextRunAt = %v, want none", got.NextRunAt)
+				}
+			case "interval":
+				if want := recent.Add(24 * time.Hour); got.NextRunAt == nil || !got.NextRunAt.Equal(want) {
+					t.Errorf("NextRunAt = %v, want %v", got.NextRunAt, want)
+				}
+			case "now":
+				if got.NextRunAt == nil || got.NextRunAt.Before(before) || got.NextRunAt.After(after) {
+					t.Errorf("NextRunAt = %v, want now", got.NextRunAt)
+				}
+			}
+		})
+	}
+}
+
+func TestGetCompanyOverview(t *testing.T) {
+	databasetest.Require(t)
+	ctx := context.Background()
+	lastSync := time.Now().Add(-time.Hour).Truncate(time.Second)
+	company := databasetest.CreateCompany(t, func(c *models.Company) {
+		c.AutoFetch, c.DefaultEnvironment = true, "production"
+		c.LastSyncAt, c.LastSyncStatus, c.LastSyncError = lastSync, "partial", "page 3 timed out"
+	})
+
+	databasetest.CreateCredential(t, &models.CompanyCredential{CompanyID: company.ID, Type: "prefeitura_token", Environment: "production"})
+	databasetest.CreateCredential(t, &models.CompanyCredential{CompanyID: company.ID, Type: "prefeitura_token", Environment: "staging"})
+	// active has a database default, so it is only turned off after the insert
+	inactive := databasetest.CreateCredential(t, &models.CompanyCredential{CompanyID: company.ID, Type: "prefeitura_user_pass"})
+	if _, err := database.DB.NewUpdate().Model(inactive).Set("active = false").WherePK().Exec(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	issued := time.Date(2025, 3, 14, 10, 0, 0, 0, time.UTC)
+	databasetest.CreateDocument(t, &models.Document{CompanyID: company.ID, ServiceValue: 100, IssueDate: issued.AddDate(0, -1, 0)})
+	databasetest.CreateDocument(t, &models.Document{CompanyID: company.ID, ServiceValue: 250.5, IssueDate: issued})
+	databasetest.CreateDocument(t, &models.Document{CompanyID: company.ID, ServiceValue: 1000, IssueDate: issued, IsCancelled: true})
+	databasetest.CreateDocument(t, &models.Document{CompanyID: company.ID, ServiceValue: 999, IssueDate: issued, DeletedAt: time.Now()})
+
+	for _, status := range []string{models.DeadLetterStatusPending, models.DeadLetterStatusReprocessed} {
+		deadLetter := &models.DeadLetter{CompanyID: company.ID, FileName: "broken.xml", StorageKey: "dead-letter/broken.xml", Status: status}
+		if _, err := database.DB.NewInsert().Model(deadLetter).Exec(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	overview, err := GetCompanyOverview(ctx, company.ID)
+	if err != nil {
+		t.Fatalf("GetCompanyOverview() error = %v", err)
+	}
+
+	if overview.Company == nil || overview.Company.ID != company.ID {
+		t.Errorf("company = %+v, want %d", overview.Company, company.ID)
+	}
+
+	credentials := overview.Credentials
+	if credentials.Total != 3 || credentials.Active != 2 || credentials.Usable != 1 || len(credentials.ByType) != 2 {
+		t.Errorf("credentials = %+v, want 3 total, 2 active, 1 usable in 2 types", credentials)
+	} else if credentials.ByType[0] != (CredentialTypeSummary{"prefeitura_token", 2, 2}) ||
+		credentials.ByType[1] != (CredentialTypeSummary{"prefeitura_user_pass", 1, 0}) {
+		t.Errorf("credentials by type = %+v", credentials.ByType)
+	}
+
+	sync := overview.Sync
+	if sync.LastSyncAt == nil || !sync.LastSyncAt.Equal(lastSync) || sync.LastSyncStatus != "partial" || sync.LastSyncError != "page 3 timed out" {
+		t.Errorf("sync = %+v, want the last partial sync", sync)
+	}
+	if cfg := config.Get().NFSeScheduler; cfg.Enabled && sync.NextRunAt == nil {
+		t.Error("sync has no next run, want one while the scheduler is enabled")
+	}
+
+	documents := overview.Documents
+	if documents.Total != 3 || documents.Cancelled != 1 || documents.TotalValue != 350.5 ||
+		documents.LastIssueDate == nil || !documents.LastIssueDate.Equal(issued) {
+		t.Errorf("documents = %+v, want 3 live documents, 1 cancelled, 350.50 issued until %v", documents, issued)
+	}
+	if overview.Usage.Documents != 3 {
+		t.Errorf("usage = %+v, want 3 documents", overview.Usage)
+	}
+
+	pending := overview.PendingWork
+	if pending.DeadLetters != 1 || pending.PendingIngests != 0 || pending.ReprocessBatchID != 0 || pending.Total != 1 {
+		t.Errorf("pending work = %+v, want the pending dead letter only", pending)
+	}
+}
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/models"
+)
+
+// competenceLayout is the canonical competência format (YYYY-MM)
+const competenceLayout = "2006-01"
+
+var (
+	competenceDayFirst  = regexp.MustCompile(`^(\d{1,2})/(\d{1,2})/(\d{4})`)
+	competenceMonthYear = regexp.MustCompile(`^(\d{1,2})/(\d{4})$`)
+	competenceISO       = regexp.MustCompile(`^(\d{4})-(\d{2})`)
+	competenceCompact   = regexp.MustCompile(`^(\d{4})(\d{2})$`)
+)
+
+// CompetenceSummary holds the aggregate of a company's documents for one competência
+type CompetenceSummary struct {
+	Competence string  `json:"competence" bun:"competence"`
+	Documents  int     `json:"documents" bun:"documents"`
+	TotalValue float64 `json:"total_value" bun:"total_value"`
+}
+
+// CompetenceComparison is the variance report between two competências
+type CompetenceComparison struct {
+	From                CompetenceSummary `json:"from"`
+	To                  CompetenceSummary `json:"to"`
+	DocumentsDelta      int               `json:"documents_delta"`
+	TotalValueDelta     float64           `json:"total_value_delta"`
+	DocumentsChangePct  *float64          `json:"documents_change_pct"`
+	TotalValueChangePct *float64          `json:"total_value_change_pct"`
+}
+
+// NormalizeCompetence converts the competência formats sent by municipalities
+// (DD/MM/YYYY [HH:MM:SS], MM/YYYY, YYYY-MM[-DD...], YYYYMM) to YYYY-MM
+func NormalizeCompetence(raw string) (string, bool) {
+	var year, month string
+	if m := competenceDayFirst.FindStringSubmatch(raw); m != nil {
+		year, month = m[3], m[2]
+	} else if m := competenceMonthYear.FindStringSubmatch(raw); m != nil {
+		year, month = m[2], m[1]
+	} else if m := competenceISO.FindStringSubmatch(raw); m != nil {
+		year, month = m[1], m[2]
+	} else if m := competenceCompact.FindStringSubmatch(raw); m != nil {
+		year, month = m[1], m[2]
+	} else {
+		return "", false
+	}
+
+	if len(month) == 1 {
+		month = "0" + month
+	}
+
+	parsed, err := time.Parse(competenceLayout, year+"-"+month)
+	if err != nil {
+		return "", false
+	}
+	return parsed.Format(competenceLayout), true
+}
+
+// providerCompetence converts the NrCompetencia integer sent by the provider (YYYYMM,
+// e.g. 202501) to YYYY-MM; it returns "" when the value is missing or invalid
+func providerCompetence(nrCompetencia int) string {
+	if nrCompetencia <= 0 {
+		return ""
+	}
+	competence, ok := NormalizeCompetence(fmt.Sprintf("%06d", nrCompetencia))
+	if !ok {
+		return ""
+	}
+	return competence
+}
+
+// applyCompetenceFallback sets the competência of parsed data to fallback (YYYY-MM) when
+// the one in the XML is missing or unparseable
+func applyCompetenceFallback(parsedData *ParsedNFSeData, fallback string) {
+	if fallback == "" {
+		return
+	}
+	if _, ok := NormalizeCompetence(strings.TrimSpace(parsedData.Competence)); !ok {
+		parsedData.Competence = fallback
+	}
+}
+
+// GetCompetenceSummaries aggregates the non-cancelled NFSe documents of a company for the
+// given competências (YYYY-MM). Competências without documents are returned as zero.
+func GetCompetenceSummaries(ctx context.Context, companyID int64, competences ...string) (map[string]CompetenceSummary, error) {
+	rows := []CompetenceSummary{}
+	err := database.DB.NewSelect().
+		Model((*models.Document)(nil)).
+		ColumnExpr("competence_month AS competence").
+		ColumnExpr("COUNT(*) AS documents").
+		ColumnExpr("COALESCE(SUM(service_value), 0) AS total_value").
+		Where("company_id = ? AND type = 'nfse' AND is_cancelled = false", companyID).
+		Where("competence_month IN (?)", bun.In(competences)).
+		GroupExpr("1").
+		Scan(ctx, &rows)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate documents by competence: %w", err)
+	}
+
+	summaries := make(map[string]CompetenceSummary, len(competences))
+	for _, competence := range competences {
+		summaries[competence] = CompetenceSummary{Competence: competence}
+	}
+	for _, row := range rows {
+		summaries[row.Competence] = row
+	}
+
+	return summaries, nil
+}
+
+// CompareCompetences builds the variance report between two competências (YYYY-MM)
+func CompareCompetences(ctx context.Context, companyID int64, from, to string) (*CompetenceComparison, error) {
+	summaries, err := GetCompetenceSummaries(ctx, companyID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	return compareSummaries(summaries[from], summaries[to]), nil
+}
+
+// compareSummaries computes the deltas between the summaries of two competências
+func compareSummaries(from, to CompetenceSummary) *CompetenceComparison {
+	comparison := &CompetenceComparison{
+		From: from,
+		To:   to,
+	}
+	comparison.DocumentsDelta = comparison.To.Documents - comparison.From.Documents
+	comparison.TotalValueDelta = comparison.To.TotalValue - comparison.From.TotalValue
+	comparison.DocumentsChangePct = percentageChange(float64(comparison.From.Documents), float64(comparison.To.Documents))
+	comparison.TotalValueChangePct = percentageChange(comparison.From.TotalValue, comparison.To.TotalValue)
+
+	return comparison
+}
+
+// percentageChange returns the change from base to value in percent, or nil when the
+// base is zero and the change is undefined
+func percentageChange(base, value float64) *float64 {
+	if base == 0 {
+		return nil
+	}
+	change := (value - base) / base * 100
+	return &change
+}
@@ -0,0 +1,164 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/zoomxml/config"
+	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/logger"
+	"github.com/zoomxml/internal/models"
+)
+
+// Competência completeness statuses
+const (
+	CompetenceStatusOpen   = "open"
+	CompetenceStatusClosed = "closed"
+)
+
+// CompetenceStatus reports whether a competência of a company is closed, how many notes
+// arrived after it was closed and the numbering gaps still open in it
+type CompetenceStatus struct {
+	Competence   string                  `json:"competence"`
+	Status       string                  `json:"status"`
+	ClosedAt     *time.Time              `json:"closed_at,omitempty"`
+	ClosedBy     int64                   `json:"closed_by,omitempty"`
+	LateArrivals int                     `json:"late_arrivals"`
+	Gaps         []ProviderNumberingGaps `json:"gaps"`
+}
+
+// CloseCompetence marks a competência (YYYY-MM) of a company as closed. Closing an
+// already closed competência returns the existing closure and created is false.
+func CloseCompetence(ctx context.Context, companyID int64, competence string, userID int64) (closure *models.CompetenceClosure, created bool, err error) {
+	closure = &models.CompetenceClosure{
+		CompanyID:  companyID,
+		Competence: competence,
+		ClosedBy:   userID,
+	}
+
+	res, err := database.DB.NewInsert().
+		Model(closure).
+		On("CONFLICT (company_id, competence) DO NOTHING").
+		Exec(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to close competence: %w", err)
+	}
+
+	if rows, _ := res.RowsAffected(); rows > 0 {
+		return closure, true, nil
+	}
+
+	existing, err := competenceClosure(ctx, companyID, competence)
+	if err != nil {
+		return nil, false, err
+	}
+	return existing, false, nil
+}
+
+// ReopenCompetence removes the closure of a competência; notes already flagged as late
+// arrivals keep the flag. It reports whether the competência was closed.
+func ReopenCompetence(ctx context.Context, companyID int64, competence string) (bool, error) {
+	res, err := database.DB.NewDelete().
+		Model((*models.CompetenceClosure)(nil)).
+		Where("company_id = ? AND competence = ?", companyID, competence).
+		Exec(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to reopen competence: %w", err)
+	}
+
+	rows, err := res.RowsAffected()
+	return rows > 0, err
+}
+
+// GetCompetenceStatus builds the completeness status of a competência (YYYY-MM)
+func GetCompetenceStatus(ctx context.Context, companyID int64, competence string) (*CompetenceStatus, error) {
+	status := &CompetenceStatus{Competence: competence, Status: CompetenceStatusOpen}
+
+	closure, err := competenceClosure(ctx, companyID, competence)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	if closure != nil {
+		status.Status = CompetenceStatusClosed
+		status.ClosedAt = &closure.CreatedAt
+		status.ClosedBy = closure.ClosedBy
+	}
+
+	status.LateArrivals, err = database.DB.NewSelect().
+		Model((*models.Document)(nil)).
+		Where("company_id = ? AND type = 'nfse' AND late_arrival = true", companyID).
+		Where("competence_month = ?", competence).
+		Count(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count late arrivals: %w", err)
+	}
+
+	status.Gaps, err = FindNumberingGaps(ctx, companyID, competence)
+	if err != nil {
+		return nil, err
+	}
+
+	return status, nil
+}
+
+// competenceClosure loads the closure of a competência, or sql.ErrNoRows when it is open
+func competenceClosure(ctx context.Context, companyID int64, competence string) (*models.CompetenceClosure, error) {
+	closure := &models.CompetenceClosure{}
+	err := database.DB.NewSelect().
+		Model(closure).
+		Where("company_id = ? AND competence = ?", companyID, competence).
+		Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load competence closure: %w", err)
+	}
+	return closure, nil
+}
+
+// closedCompetences loads the closed competências of a company for the ingest policy.
+// It returns nil when NFSE_FLAG_LATE_ARRIVALS is off; lookup failures are logged and
+// flag nothing.
+func closedCompetences(ctx context.Context, companyID int64) map[string]bool {
+	if !config.Get().NFSeScheduler.FlagLateArrivals {
+		return nil
+	}
+
+	var competences []string
+	err := database.DB.NewSelect().
+		Model((*models.CompetenceClosure)(nil)).
+		Column("competence").
+		Where("company_id = ?", companyID).
+		Scan(ctx, &competences)
+	if err != nil {
+		logger.WarnWithFields("Failed to load closed competences, late arrivals will not be flagged", map[string]any{
+			"operation":  "ingest_policy",
+			"company_id": companyID,
+			"error":      err.Error(),
+		})
+		return nil
+	}
+
+	closed := make(map[string]bool, len(competences))
+	for _, competence := range competences {
+		closed[competence] = true
+	}
+	return closed
+}
+
+// documentCompetence returns the competência (YYYY-MM) of parsed data, falling back to
+// the issue date; it is persisted as the competence_month column
+func documentCompetence(parsedData *ParsedNFSeData) string {
+	if competence, ok := NormalizeCompetence(strings.TrimSpace(parsedData.Competence)); ok {
+		return competence
+	}
+	if parsedData.IssueDate.IsZero() {
+		return ""
+	}
+	return parsedData.IssueDate.Format(competenceLayout)
+}
@@ -0,0 +1,105 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/zoomxml/config"
+	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/database/databasetest"
+	"github.com/zoomxml/internal/models"
+	"github.com/zoomxml/internal/repository/repositorytest"
+)
+
+func TestConvertToDocumentFlagsLateArrival(t *testing.T) {
+	manager := NewNFSeXMLManagerWithRepositories(&repositorytest.DocumentRepository{}, &repositorytest.CompanyRepository{})
+	// Competência 03/2025
+	parsed, err := NewNFSeParser().ParseXML(testNFSeXML("4521", "LATE-AAA", "12345678000190", "", "100.00"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		closed map[string]bool
+		want   bool
+	}{
+		{"competência closed", map[string]bool{"2025-03": true}, true},
+		{"another competência closed", map[string]bool{"2025-02": true}, false},
+		{"nothing closed", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			document := manager.convertToDocument(IngestPolicy{ClosedCompetences: tt.closed}, 1, parsed, "", "4521.xml")
+			if document.LateArrival != tt.want {
+				t.Errorf("LateArrival = %v, want %v", document.LateArrival, tt.want)
+			}
+		})
+	}
+}
+
+func TestCloseCompetenceFlagsLateNote(t *testing.T) {
+	databasetest.Require(t)
+	useMemoryStorage(t)
+	cfg := config.Get()
+	storeLogs, lateArrivals := cfg.Logger.StoreProcessingLogs, cfg.NFSeScheduler.FlagLateArrivals
+	cfg.Logger.StoreProcessingLogs = false
+	cfg.NFSeScheduler.FlagLateArrivals = true
+	t.Cleanup(func() {
+		cfg.Logger.StoreProcessingLogs = storeLogs
+		cfg.NFSeScheduler.FlagLateArrivals = lateArrivals
+	})
+
+	ctx := context.Background()
+	company := databasetest.CreateCompany(t, nil)
+	t.Cleanup(func() { ReopenCompetence(ctx, company.ID, "2025-03") })
+	manager := NewNFSeXMLManager()
+
+	// ingest stores a note of competência 03/2025 and reports whether it arrived late
+	ingest := func(number, verificationCode string) bool {
+		t.Helper()
+		result, err := manager.ProcessSingleXML(ctx, company.ID, testNFSeXML(number, verificationCode, company.CNPJ, "", "100.00"), number+".xml")
+		if err != nil || !result.Success {
+			t.Fatalf("ProcessSingleXML(%s) = %+v, %v", number, result, err)
+		}
+		document := &models.Document{}
+		if err := database.DB.NewSelect().Model(document).Where("id = ?", result.DocumentID).Scan(ctx); err != nil {
+			t.Fatal(err)
+		}
+		return document.LateArrival
+	}
+
+	if ingest("1", "LATE-0001") {
+		t.Error("note of an open competência was flagged as a late arrival")
+	}
+
+	if _, created, err := CloseCompetence(ctx, company.ID, "2025-03", 0); err != nil || !created {
+		t.Fatalf("CloseCompetence() created = %v, %v, want the competência closed", created, err)
+	}
+	if _, created, err := CloseCompetence(ctx, company.ID, "2025-03", 0); err != nil || created {
+		t.Errorf("CloseCompetence() again created = %v, %v, want the existing closure", created, err)
+	}
+
+	if !ingest("2", "LATE-0002") {
+		t.Error("note of a closed competência was not flagged as a late arrival")
+	}
+
+	status, err := GetCompetenceStatus(ctx, company.ID, "2025-03")
+	if err != nil {
+		t.Fatalf("GetCompetenceStatus() error = %v", err)
+	}
+	if status.Status != CompetenceStatusClosed || status.ClosedAt == nil || status.LateArrivals != 1 {
+		t.Errorf("status = %+v, want closed with 1 late arrival", status)
+	}
+
+	if reopened, err := ReopenCompetence(ctx, company.ID, "2025-03"); err != nil || !reopened {
+		t.Fatalf("ReopenCompetence() = %v, %v, want true", reopened, err)
+	}
+	if ingest("3", "LATE-0003") {
+		t.Error("note of a reopened competência was flagged as a late arrival")
+	}
+	if status, err := GetCompetenceStatus(ctx, company.ID, "2025-03"); err != nil || status.Status != CompetenceStatusOpen || status.LateArrivals != 1 {
+		t.Errorf("status after reopening = %+v, %v, want open keeping 1 late arrival", status, err)
+	}
+}
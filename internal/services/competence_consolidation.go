@@ -0,0 +1,257 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/zoomxml/config"
+	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/logger"
+	"github.com/zoomxml/internal/models"
+	"github.com/zoomxml/internal/storage"
+)
+
+// ConsolidatedObject describes one stored XML found outside its canonical competência folder
+type ConsolidatedObject struct {
+	DocumentID int64  `json:"document_id"`
+	From       string `json:"from"`
+	To         string `json:"to"`
+	Moved      bool   `json:"moved"`
+	Error      string `json:"error,omitempty"`
+}
+
+// CompetenceConsolidationReport is the outcome of consolidating the split competências of a company
+type CompetenceConsolidationReport struct {
+	CompanyID int64                `json:"company_id"`
+	DryRun    bool                 `json:"dry_run"`
+	Checked   int                  `json:"checked"`
+	Misplaced int                  `json:"misplaced"`
+	Moved     int                  `json:"moved"`
+	Failed    int                  `json:"failed"`
+	Objects   []ConsolidatedObject `json:"objects"`
+}
+
+// canonicalStorageKey returns where a key of the form nfse/<year>/<MMYYYY>/... belongs.
+// Keys used to take the year folder from the issue date, so a competência with notes
+// issued in the following year was split across two year folders; the canonical year
+// folder is the competência year. ok is false when the key is already canonical or
+// does not follow the layout.
+func canonicalStorageKey(key string) (canonical string, ok bool) {
+	parts := strings.SplitN(key, "/", 4)
+	if len(parts) != 4 || parts[0] != "nfse" || len(parts[2]) != 6 {
+		return "", false
+	}
+
+	year := parts[2][2:]
+	if _, valid := NormalizeCompetence(year + "-" + parts[2][:2]); !valid || parts[1] == year {
+		return "", false
+	}
+
+	return strings.Join([]string{"nfse", year, parts[2], parts[3]}, "/"), true
+}
+
+// ConsolidateCompetences moves the stored XML of NFSe documents of a company that sit
+// under a non-canonical year folder to the competência year folder, updating their
+// storage_key. dryRun only reports.
+func ConsolidateCompetences(ctx context.Context, companyID int64, dryRun bool) (*CompetenceConsolidationReport, error) {
+	documents := []models.Document{}
+	err := database.DB.NewSelect().
+		Model(&documents).
+		Column("id", "storage_key").
+		Where("company_id = ? AND type = 'nfse'", companyID).
+		Where("storage_key LIKE 'nfse/%'").
+		Order("id ASC").
+		Scan(ctx)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to load documents: %w", err)
+	}
+
+	report := &CompetenceConsolidationReport{
+		CompanyID: companyID,
+		DryRun:    dryRun,
+		Checked:   len(documents),
+		Objects:   []ConsolidatedObject{},
+	}
+
+	for _, doc := range documents {
+		target, misplaced := canonicalStorageKey(doc.StorageKey)
+		if !misplaced {
+			continue
+		}
+
+		report.Misplaced++
+		object := ConsolidatedObject{DocumentID: doc.ID, From: doc.StorageKey, To: target}
+
+		if !dryRun {
+			if err := moveStoredObject(ctx, doc.StorageKey, target); err != nil {
+				object.Error = err.Error()
+				report.Failed++
+			} else {
+				object.Moved = true
+				report.Moved++
+			}
+		}
+
+		report.Objects = append(report.Objects, object)
+	}
+
+	if report.Misplaced > 0 {
+		logger.InfoWithFields("Consolidated split NFSe competências", map[string]any{
+			"operation":  "consolidate_competences",
+			"company_id": companyID,
+			"dry_run":    dryRun,
+			"misplaced":  report.Misplaced,
+			"moved":      report.Moved,
+			"failed":     report.Failed,
+		})
+	}
+
+	return report, nil
+}
+
+// moveStoredObject copies an XML object to its new key on the storage server, repoints
+// every document row that references it (soft-deleted ones included) in one transaction
+// and only then removes the old object. The same object may back rows of several
+// companies (provider and taker of a note), so all of them move together. An object
+// already at the new key is never replaced; when the rows changed meanwhile the copy is
+// removed.
+func moveStoredObject(ctx context.Context, from, to string) error {
+	exists, err := storage.Storage.FileExists(ctx, nfseBucket, to)
+	if err != nil {
+		return fmt.Errorf("failed to check target object: %w", err)
+	}
+	if exists {
+		return fmt.Errorf("target object %s already exists", to)
+	}
+
+	if err := storage.Storage.CopyFile(ctx, nfseBucket, from, to); err != nil {
+		return fmt.Errorf("failed to copy object: %w", err)
+	}
+
+	err = database.DB.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		res, err := tx.NewUpdate().
+			Model((*models.Document)(nil)).
+			Set("storage_key = ?", to).
+			Set("updated_at = current_timestamp").
+			Where("storage_key = ?", from).
+			WhereAllWithDeleted().
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		if rows, _ := res.RowsAffected(); rows == 0 {
+			return ErrConcurrentModification
+		}
+		return nil
+	})
+	if err != nil {
+		storage.Storage.DeleteFile(ctx, nfseBucket, to)
+		return fmt.Errorf("failed to update storage key: %w", err)
+	}
+
+	if err := storage.Storage.DeleteFile(ctx, nfseBucket, from); err != nil {
+		logger.WarnWithFields("Failed to remove consolidated object", map[string]any{
+			"operation":   "consolidate_competences",
+			"storage_key": from,
+			"error":       err.Error(),
+		})
+	}
+	// PDFs rendered under the old key are rendered again under the new one on request
+	deleteDANFSEs(ctx, from, "")
+
+	return nil
+}
+
+// CompetenceConsolidator periodically consolidates the split competências of every company
+type CompetenceConsolidator struct {
+	ticker   *time.Ticker
+	stopChan chan bool
+	running  bool
+	config   *config.Config
+}
+
+// NewCompetenceConsolidator creates a new competência consolidator
+func NewCompetenceConsolidator() *CompetenceConsolidator {
+	return &CompetenceConsolidator{
+		stopChan: make(chan bool),
+		config:   config.Get(),
+	}
+}
+
+// Start begins consolidating every STORAGE_CONSOLIDATE_COMPETENCES_INTERVAL
+func (r *CompetenceConsolidator) Start() {
+	if !r.config.Storage.ConsolidateCompetencesEnabled || r.running {
+		return
+	}
+
+	interval := r.config.Storage.ConsolidateCompetencesInterval
+	if interval <= 0 {
+		interval = 24 * time.Hour
+	}
+
+	r.ticker = time.NewTicker(interval)
+	r.running = true
+
+	logger.InfoWithFields("Starting competência consolidator", map[string]any{
+		"operation": "start_competence_consolidator",
+		"interval":  interval.String(),
+	})
+
+	go r.run()
+}
+
+// Stop stops the consolidator
+func (r *CompetenceConsolidator) Stop() {
+	if !r.running {
+		return
+	}
+
+	r.stopChan <- true
+	r.ticker.Stop()
+	r.running = false
+}
+
+// run is the consolidator loop
+func (r *CompetenceConsolidator) run() {
+	for {
+		select {
+		case <-r.ticker.C:
+			if err := ConsolidateAllCompetences(context.Background()); err != nil {
+				logger.ErrorWithFields("Competência consolidation failed", err, map[string]any{
+					"operation": "consolidate_competences",
+				})
+			}
+		case <-r.stopChan:
+			return
+		}
+	}
+}
+
+// ConsolidateAllCompetences consolidates the split competências of every company
+func ConsolidateAllCompetences(ctx context.Context) error {
+	var companyIDs []int64
+	err := database.DB.NewSelect().
+		Model((*models.Company)(nil)).
+		Column("id").
+		Order("id ASC").
+		Scan(ctx, &companyIDs)
+
+	if err != nil {
+		return fmt.Errorf("failed to load companies: %w", err)
+	}
+
+	for _, companyID := range companyIDs {
+		if _, err := ConsolidateCompetences(ctx, companyID, false); err != nil {
+			logger.ErrorWithFields("Failed to consolidate competências", err, map[string]any{
+				"operation":  "consolidate_competences",
+				"company_id": companyID,
+			})
+		}
+	}
+
+	return nil
+}
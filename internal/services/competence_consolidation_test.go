@@ -0,0 +1,160 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/database/databasetest"
+	"github.com/zoomxml/internal/models"
+	"github.com/zoomxml/internal/storage"
+)
+
+func TestCanonicalStorageKey(t *testing.T) {
+	tests := []struct {
+		name   string
+		key    string
+		want   string
+		wantOK bool
+	}{
+		{"already canonical", "nfse/2024/122024/12345678000190/1.xml", "", false},
+		{"december competência issued in january", "nfse/2025/122024/12345678000190/1.xml", "nfse/2024/122024/12345678000190/1.xml", true},
+		{"folder year behind the competência", "nfse/2023/012024/12345678000190/1.xml", "nfse/2024/012024/12345678000190/1.xml", true},
+		{"other prefix", "danfse/2025/122024/12345678000190/1.pdf", "", false},
+		{"short competência folder", "nfse/2025/1224/12345678000190/1.xml", "", false},
+		{"invalid competência month", "nfse/2025/132024/12345678000190/1.xml", "", false},
+		{"no file name", "nfse/2025/122024", "", false},
+		{"empty", "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := canonicalStorageKey(tt.key)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("canonicalStorageKey(%q) = %q, %v, want %q, %v", tt.key, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+// failingCopyStorage is a memoryStorage whose copies fail
+type failingCopyStorage struct {
+	*memoryStorage
+}
+
+func (s failingCopyStorage) CopyFile(ctx context.Context, bucketName, sourceName, targetName string) error {
+	return errors.New("copy failed")
+}
+
+// TestMoveStoredObjectFailures checks that a move that fails leaves the old object where
+// it was and no copy behind
+func TestMoveStoredObjectFailures(t *testing.T) {
+	// Failing to update the rows is only possible once the copy succeeded
+	databasetest.UseClosed(t)
+	const from, to = "nfse/2025/122024/12345678000190/1.xml", "nfse/2024/122024/12345678000190/1.xml"
+
+	tests := []struct {
+		name       string
+		failCopy   bool
+		existing   bool
+		wantTarget string
+	}{
+		{"copy fails", true, false, ""},
+		{"target already exists", false, true, "<other/>"},
+		{"rows cannot be updated", false, false, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			memory := useMemoryStorage(t)
+			memory.objects[from] = []byte("<nfse/>")
+			if tt.existing {
+				memory.objects[to] = []byte("<other/>")
+			}
+			if tt.failCopy {
+				// useMemoryStorage restores the original storage when the test ends
+				storage.Storage = failingCopyStorage{memory}
+			}
+
+			if err := moveStoredObject(context.Background(), from, to); err == nil {
+				t.Fatal("moveStoredObject() error = nil, want an error")
+			}
+			if string(memory.objects[from]) != "<nfse/>" {
+				t.Errorf("old object = %q, want it kept", memory.objects[from])
+			}
+			if got := string(memory.objects[to]); got != tt.wantTarget {
+				t.Errorf("target object = %q, want %q", got, tt.wantTarget)
+			}
+		})
+	}
+}
+
+func TestConsolidateCompetences(t *testing.T) {
+	databasetest.Require(t)
+	ctx := context.Background()
+	memory := useMemoryStorage(t)
+
+	company := databasetest.CreateCompany(t, nil)
+	other := databasetest.CreateCompany(t, nil)
+	suffix := fmt.Sprintf("%s/%d.xml", company.CNPJ, time.Now().UnixNano())
+	misplaced := "nfse/2025/122024/" + suffix
+	canonical := "nfse/2024/122024/" + suffix
+	inPlace := "nfse/2024/112024/" + suffix
+	memory.objects[misplaced] = []byte("<nfse>december</nfse>")
+	memory.objects[inPlace] = []byte("<nfse>november</nfse>")
+
+	moved := databasetest.CreateDocument(t, &models.Document{CompanyID: company.ID, StorageKey: misplaced})
+	kept := databasetest.CreateDocument(t, &models.Document{CompanyID: company.ID, StorageKey: inPlace})
+	// The taker's copy of the same note, already deleted, points at the same object
+	shared := databasetest.CreateDocument(t, &models.Document{CompanyID: other.ID, StorageKey: misplaced, DeletedAt: time.Now()})
+
+	storageKey := func(document *models.Document) string {
+		t.Helper()
+		stored := &models.Document{}
+		err := database.DB.NewSelect().Model(stored).Column("storage_key").Where("d.id = ?", document.ID).WhereAllWithDeleted().Scan(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return stored.StorageKey
+	}
+
+	report, err := ConsolidateCompetences(ctx, company.ID, true)
+	if err != nil {
+		t.Fatalf("ConsolidateCompetences(dry run) error = %v", err)
+	}
+	if report.Checked != 2 || report.Misplaced != 1 || report.Moved != 0 {
+		t.Errorf("dry run report = %+v, want 2 checked, 1 misplaced, 0 moved", report)
+	}
+	if got := storageKey(moved); got != misplaced {
+		t.Errorf("storage_key after dry run = %s, want %s", got, misplaced)
+	}
+	if _, ok := memory.objects[canonical]; ok {
+		t.Error("dry run copied the object")
+	}
+
+	report, err = ConsolidateCompetences(ctx, company.ID, false)
+	if err != nil {
+		t.Fatalf("ConsolidateCompetences() error = %v", err)
+	}
+	if report.Misplaced != 1 || report.Moved != 1 || report.Failed != 0 {
+		t.Errorf("report = %+v, want 1 misplaced and moved", report)
+	}
+
+	for _, document := range []*models.Document{moved, shared} {
+		if got := storageKey(document); got != canonical {
+			t.Errorf("document %d storage_key = %s, want %s", document.ID, got, canonical)
+		}
+	}
+	if got := storageKey(kept); got != inPlace {
+		t.Errorf("canonical document storage_key = %s, want %s", got, inPlace)
+	}
+	if string(memory.objects[canonical]) != "<nfse>december</nfse>" {
+		t.Errorf("moved object = %q, want the old content", memory.objects[canonical])
+	}
+	if _, ok := memory.objects[misplaced]; ok {
+		t.Error("old object was not removed after the move")
+	}
+}
@@ -0,0 +1,187 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/models"
+	"github.com/zoomxml/internal/storage"
+)
+
+// CompetenceListingEntry is one document row, one stored object or both for a competência
+type CompetenceListingEntry struct {
+	DocumentID    int64     `json:"document_id,omitempty"`
+	Number        string    `json:"number,omitempty"`
+	IssueDate     time.Time `json:"issue_date,omitempty"`
+	ServiceValue  float64   `json:"service_value,omitempty"`
+	Status        string    `json:"status,omitempty"`
+	StorageKey    string    `json:"storage_key,omitempty"`
+	ObjectMissing bool      `json:"object_missing"` // the row exists but its storage object does not
+	RowMissing    bool      `json:"row_missing"`    // the object exists but no document row points to it
+	XMLNotStored  bool      `json:"xml_not_stored"` // the row has no storage key (metadata-only company)
+}
+
+// CompetenceListing is the reconciled view of the database rows and storage objects
+// of a company for one competência
+type CompetenceListing struct {
+	CompanyID      int64                    `json:"company_id"`
+	Competence     string                   `json:"competence"`
+	Documents      int                      `json:"documents"`
+	Objects        int                      `json:"objects"`
+	MissingObjects int                      `json:"missing_objects"`
+	OrphanObjects  int                      `json:"orphan_objects"`
+	Entries        []CompetenceListingEntry `json:"entries"`
+}
+
+// ListCompetenceDocuments joins the NFSe documents of a company for a competência
+// (YYYY-MM) with the XML objects stored under that competência, flagging rows whose
+// object is missing and objects of the company (stored under its CNPJ) that no row
+// references. With onlyDrift, entries without either flag are left out.
+func ListCompetenceDocuments(ctx context.Context, company *models.Company, competence string, onlyDrift bool) (*CompetenceListing, error) {
+	documents := []models.Document{}
+	err := database.DB.NewSelect().
+		Model(&documents).
+		Column("id", "number", "issue_date", "service_value", "status", "storage_key").
+		Where("company_id = ? AND type = 'nfse'", company.ID).
+		Where("competence_month = ?", competence).
+		Order("issue_date ASC", "id ASC").
+		Scan(ctx)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to load documents: %w", err)
+	}
+
+	objects, err := competenceObjects(ctx, competence)
+	if err != nil {
+		return nil, err
+	}
+
+	listing := &CompetenceListing{
+		CompanyID:  company.ID,
+		Competence: competence,
+		Documents:  len(documents),
+		Entries:    []CompetenceListingEntry{},
+	}
+
+	referenced := make(map[string]bool, len(documents))
+	for _, doc := range documents {
+		entry := CompetenceListingEntry{
+			DocumentID:   doc.ID,
+			Number:       doc.Number,
+			IssueDate:    doc.IssueDate,
+			ServiceValue: doc.ServiceValue,
+			Status:       doc.Status,
+			StorageKey:   doc.StorageKey,
+		}
+
+		if doc.StorageKey == "" {
+			entry.XMLNotStored = true
+		} else {
+			referenced[doc.StorageKey] = true
+			if !objects[doc.StorageKey] {
+				entry.ObjectMissing = true
+				listing.MissingObjects++
+			}
+		}
+
+		if !onlyDrift || entry.ObjectMissing {
+			listing.Entries = append(listing.Entries, entry)
+		}
+	}
+
+	// Objects stored under the company CNPJ are candidates for orphans. Keys referenced by
+	// a row of another competência or another company are not orphans.
+	companySegment := "/" + NormalizeCNPJ(company.CNPJ) + "/"
+	candidates := []string{}
+	for key := range objects {
+		if referenced[key] {
+			listing.Objects++
+		} else if strings.Contains(key, companySegment) {
+			listing.Objects++
+			candidates = append(candidates, key)
+		}
+	}
+
+	orphans, err := unreferencedKeys(ctx, candidates)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, key := range orphans {
+		listing.Entries = append(listing.Entries, CompetenceListingEntry{
+			StorageKey: key,
+			RowMissing: true,
+		})
+	}
+	listing.OrphanObjects = len(orphans)
+
+	return listing, nil
+}
+
+// competenceObjects lists the stored XML keys of a competência. Keys are organized as
+// nfse/<competência year>/<MMYYYY>/..., but older keys took the year from the issue date
+// and notes of a competência may be issued in the year after it (December notes issued
+// in January), so both years are listed until ConsolidateCompetences moves them.
+func competenceObjects(ctx context.Context, competence string) (map[string]bool, error) {
+	year, err := strconv.Atoi(competence[:4])
+	if err != nil {
+		return nil, fmt.Errorf("invalid competence: %s", competence)
+	}
+	segment := competence[5:7] + competence[:4]
+
+	objects := make(map[string]bool)
+	for _, folderYear := range []int{year, year + 1} {
+		keys, err := storage.Storage.ListFiles(ctx, nfseBucket, fmt.Sprintf("nfse/%d/%s/", folderYear, segment))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list stored objects: %w", err)
+		}
+		for _, key := range keys {
+			if !isDANFSEKey(key) {
+				objects[key] = true
+			}
+		}
+	}
+
+	return objects, nil
+}
+
+// unreferencedKeys returns, sorted, the keys that no document of any company points to.
+// Soft-deleted rows still own their object until retention purges them, so they count.
+func unreferencedKeys(ctx context.Context, keys []string) ([]string, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	var referenced []string
+	err := database.DB.NewSelect().
+		Model((*models.Document)(nil)).
+		Column("storage_key").
+		Where("storage_key IN (?)", bun.In(keys)).
+		WhereAllWithDeleted().
+		Scan(ctx, &referenced)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to check object references: %w", err)
+	}
+
+	known := make(map[string]bool, len(referenced))
+	for _, key := range referenced {
+		known[key] = true
+	}
+
+	orphans := []string{}
+	for _, key := range keys {
+		if !known[key] {
+			orphans = append(orphans, key)
+		}
+	}
+	sort.Strings(orphans)
+
+	return orphans, nil
+}
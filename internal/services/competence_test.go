@@ -0,0 +1,252 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zoomxml/internal/models"
+	"github.com/zoomxml/internal/repository/repositorytest"
+)
+
+func TestNormalizeCompetence(t *testing.T) {
+	tests := []struct {
+		name   string
+		raw    string
+		want   string
+		wantOK bool
+	}{
+		{"day first", "15/03/2025", "2025-03", true},
+		{"day first with time", "01/12/2024 00:00:00", "2024-12", true},
+		{"single digit day and month", "1/3/2025", "2025-03", true},
+		{"month and year", "03/2025", "2025-03", true},
+		{"single digit month and year", "3/2025", "2025-03", true},
+		{"iso month", "2025-03", "2025-03", true},
+		{"iso date", "2025-03-01T00:00:00", "2025-03", true},
+		{"compact", "202503", "2025-03", true},
+		{"invalid month", "13/2025", "", false},
+		{"invalid compact month", "202500", "", false},
+		{"empty", "", "", false},
+		{"free text", "março de 2025", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := NormalizeCompetence(tt.raw)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("NormalizeCompetence(%q) = %q, %v, want %q, %v", tt.raw, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestDocumentCompetence(t *testing.T) {
+	issued := time.Date(2025, 4, 10, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		competence string
+		issueDate  time.Time
+		want       string
+	}{
+		{"competence wins over issue date", " 3/2025 ", issued, "2025-03"},
+		{"unknown format falls back to issue date", "março", issued, "2025-04"},
+		{"missing competence falls back to issue date", "", issued, "2025-04"},
+		{"nothing to go on", "", time.Time{}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed := &ParsedNFSeData{Competence: tt.competence, IssueDate: tt.issueDate}
+			if got := documentCompetence(parsed); got != tt.want {
+				t.Errorf("documentCompetence(%q, %v) = %q, want %q", tt.competence, tt.issueDate, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompareSummaries(t *testing.T) {
+	tests := []struct {
+		name          string
+		from          CompetenceSummary
+		to            CompetenceSummary
+		wantDocuments int
+		wantValue     float64
+		wantDocPct    *float64
+		wantValuePct  *float64
+	}{
+		{
+			name:          "both months present",
+			from:          CompetenceSummary{Competence: "2025-02", Documents: 4, TotalValue: 1000},
+			to:            CompetenceSummary{Competence: "2025-03", Documents: 5, TotalValue: 750},
+			wantDocuments: 1,
+			wantValue:     -250,
+			wantDocPct:    ptrFloat(25),
+			wantValuePct:  ptrFloat(-25),
+		},
+		{
+			name:          "later month absent",
+			from:          CompetenceSummary{Competence: "2025-02", Documents: 4, TotalValue: 1000},
+			to:            CompetenceSummary{Competence: "2025-03"},
+			wantDocuments: -4,
+			wantValue:     -1000,
+			wantDocPct:    ptrFloat(-100),
+			wantValuePct:  ptrFloat(-100),
+		},
+		{
+			name:          "earlier month absent has no percentage",
+			from:          CompetenceSummary{Competence: "2025-02"},
+			to:            CompetenceSummary{Competence: "2025-03", Documents: 2, TotalValue: 300},
+			wantDocuments: 2,
+			wantValue:     300,
+		},
+		{
+			name: "both months absent",
+			from: CompetenceSummary{Competence: "2025-02"},
+			to:   CompetenceSummary{Competence: "2025-03"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := compareSummaries(tt.from, tt.to)
+			if got.From != tt.from || got.To != tt.to {
+				t.Errorf("compareSummaries() summaries = %+v / %+v, want %+v / %+v", got.From, got.To, tt.from, tt.to)
+			}
+			if got.DocumentsDelta != tt.wantDocuments || got.TotalValueDelta != tt.wantValue {
+				t.Errorf("compareSummaries() deltas = %d / %v, want %d / %v", got.DocumentsDelta, got.TotalValueDelta, tt.wantDocuments, tt.wantValue)
+			}
+			if !equalPct(got.DocumentsChangePct, tt.wantDocPct) || !equalPct(got.TotalValueChangePct, tt.wantValuePct) {
+				t.Errorf("compareSummaries() change = %v / %v, want %v / %v",
+					formatPct(got.DocumentsChangePct), formatPct(got.TotalValueChangePct), formatPct(tt.wantDocPct), formatPct(tt.wantValuePct))
+			}
+		})
+	}
+}
+
+func TestPercentageChange(t *testing.T) {
+	tests := []struct {
+		name  string
+		base  float64
+		value float64
+		want  *float64
+	}{
+		{"increase", 200, 250, ptrFloat(25)},
+		{"decrease", 200, 50, ptrFloat(-75)},
+		{"unchanged", 10, 10, ptrFloat(0)},
+		{"zero base", 0, 10, nil},
+		{"zero base and value", 0, 0, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := percentageChange(tt.base, tt.value); !equalPct(got, tt.want) {
+				t.Errorf("percentageChange(%v, %v) = %s, want %s", tt.base, tt.value, formatPct(got), formatPct(tt.want))
+			}
+		})
+	}
+}
+
+func ptrFloat(v float64) *float64 { return &v }
+
+// equalPct compares optional percentages
+func equalPct(got, want *float64) bool {
+	if got == nil || want == nil {
+		return got == want
+	}
+	return *got == *want
+}
+
+// formatPct prints an optional percentage for test failures
+func formatPct(v *float64) string {
+	if v == nil {
+		return "nil"
+	}
+	return fmt.Sprint(*v)
+}
+
+func TestProviderCompetence(t *testing.T) {
+	tests := []struct {
+		nrCompetencia int
+		want          string
+	}{
+		{202501, "2025-01"},
+		{202412, "2024-12"},
+		{0, ""},
+		{-202501, ""},
+		{202513, ""},
+		{99, ""},
+	}
+
+	for _, tt := range tests {
+		if got := providerCompetence(tt.nrCompetencia); got != tt.want {
+			t.Errorf("providerCompetence(%d) = %q, want %q", tt.nrCompetencia, got, tt.want)
+		}
+	}
+}
+
+func TestApplyCompetenceFallback(t *testing.T) {
+	tests := []struct {
+		name     string
+		xml      string
+		fallback string
+		want     string
+	}{
+		{"missing in the XML", "", "2025-01", "2025-01"},
+		{"unparseable in the XML", "competência março", "2025-01", "2025-01"},
+		{"XML value wins", "03/2025", "2025-01", "03/2025"},
+		{"no fallback", "", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed := &ParsedNFSeData{Competence: tt.xml}
+			applyCompetenceFallback(parsed, tt.fallback)
+			if parsed.Competence != tt.want {
+				t.Errorf("applyCompetenceFallback() competence = %q, want %q", parsed.Competence, tt.want)
+			}
+		})
+	}
+}
+
+func TestProcessBatchXMLProviderCompetence(t *testing.T) {
+	const companyCNPJ = "12345678000190"
+	useFakeIngest(t)
+
+	withoutCompetence := strings.Replace(testNFSeXML("1", "AAA", companyCNPJ, "", "100.00"), "<Competencia>03/2025</Competencia>", "", 1)
+	tests := []struct {
+		name           string
+		xml            string
+		nrCompetencia  int
+		wantCompetence string
+	}{
+		{"provider supplies a missing competência", withoutCompetence, 202501, "2025-01"},
+		{"XML competência is kept", testNFSeXML("2", "BBB", companyCNPJ, "", "100.00"), 202501, "2025-03"},
+		{"issue month without either", withoutCompetence, 0, "2025-03"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			company := &models.Company{ID: 1, CNPJ: companyCNPJ}
+			documents := &repositorytest.DocumentRepository{}
+			manager := NewNFSeXMLManagerWithRepositories(documents, &repositorytest.CompanyRepository{Companies: map[int64]*models.Company{company.ID: company}})
+
+			batch := []XMLDocument{{FileName: "nota.xml", Content: tt.xml, Competence: providerCompetence(tt.nrCompetencia)}}
+			result, err := manager.ProcessBatchXML(context.Background(), company.ID, BatchOptions{Source: ProcessingSourcePrefeituraAPI}, batch)
+			if err != nil || result.ProcessedDocuments != 1 {
+				t.Fatalf("ProcessBatchXML() = %+v, %v", result, err)
+			}
+
+			document := documents.Documents[0]
+			if document.CompetenceMonth != tt.wantCompetence {
+				t.Errorf("competence_month = %q, want %q", document.CompetenceMonth, tt.wantCompetence)
+			}
+			competence := tt.wantCompetence
+			if want := "/" + competence[:4] + "/" + competence[5:] + competence[:4] + "/"; !strings.Contains(document.StorageKey, want) {
+				t.Errorf("storage key %q not under %q", document.StorageKey, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/zoomxml/config"
+	"github.com/zoomxml/internal/logger"
+	"github.com/zoomxml/internal/models"
+)
+
+// ConsultationTimeout bounds a synchronous competência consultation
+const ConsultationTimeout = 2 * time.Minute
+
+// maxConsultationDetails caps the per-document details returned by a consultation
+const maxConsultationDetails = 200
+
+// ConsultationDetail is the outcome of one document of a consultation
+type ConsultationDetail struct {
+	FileName        string `json:"file_name"`
+	Status          string `json:"status"` // stored, duplicate, overwritten or error
+	DocumentID      int64  `json:"document_id,omitempty"`
+	DuplicateReason string `json:"duplicate_reason,omitempty"`
+	Error           string `json:"error,omitempty"`
+}
+
+// ConsultationResult is the detailed outcome of consulting the provider for one competência
+type ConsultationResult struct {
+	Competence         string               `json:"competence"`
+	StartDate          string               `json:"start_date"`
+	EndDate            string               `json:"end_date"`
+	Pages              int                  `json:"pages"`
+	DocumentsFound     int                  `json:"documents_found"`
+	StoredDocuments    int                  `json:"stored_documents"`
+	DuplicateDocuments int                  `json:"duplicate_documents"`
+	ErrorDocuments     int                  `json:"error_documents"`
+	DurationMs         int64                `json:"duration_ms"`
+	Details            []ConsultationDetail `json:"details"`
+	DetailsTruncated   bool                 `json:"details_truncated"`
+}
+
+// ConsultCompetence fetches every page of the provider for the days of a competência
+// (YYYY-MM), storing the documents of each page as they arrive, and returns the outcome
+// of each one. Details are capped at maxConsultationDetails.
+func (s *NFSeService) ConsultCompetence(ctx context.Context, credential *models.CompanyCredential, competence string) (*ConsultationResult, error) {
+	month, err := time.Parse(competenceLayout, competence)
+	if err != nil {
+		return nil, fmt.Errorf("invalid competência %q, expected YYYY-MM", competence)
+	}
+
+	startTime := time.Now()
+	start := month
+	end := month.AddDate(0, 1, -1)
+
+	result := &ConsultationResult{
+		Competence: competence,
+		StartDate:  start.Format("2006-01-02"),
+		EndDate:    end.Format("2006-01-02"),
+		Details:    []ConsultationDetail{},
+	}
+
+	record := func(documents []NFSeDocument, batch *BatchProcessingResult) {
+		result.DocumentsFound += len(documents)
+		result.StoredDocuments += batch.ProcessedDocuments
+		result.DuplicateDocuments += batch.DuplicateDocuments
+		result.ErrorDocuments += batch.ErrorDocuments
+
+		for i, docResult := range batch.Results {
+			if len(result.Details) >= maxConsultationDetails {
+				result.DetailsTruncated = true
+				return
+			}
+			result.Details = append(result.Details, consultationDetail(documents[i].FileName, docResult))
+		}
+	}
+
+	for page := 1; page <= config.Get().NFSeScheduler.MaxPagesPerRun; page++ {
+		fetched, err := s.fetchAndStorePage(ctx, credential, start, end, page, record)
+		if err != nil {
+			return nil, err
+		}
+		if !fetched.Success {
+			return nil, fmt.Errorf("provider query failed: %s", fetched.Error)
+		}
+
+		result.Pages = page
+		if fetched.DocumentsCount < maxDocumentsPerPage {
+			break
+		}
+	}
+
+	result.DurationMs = time.Since(startTime).Milliseconds()
+
+	logger.InfoWithFields("Competência consultation completed", map[string]any{
+		"operation":       "consult_competence",
+		"company_id":      credential.CompanyID,
+		"competence":      competence,
+		"pages":           result.Pages,
+		"documents_found": result.DocumentsFound,
+		"stored":          result.StoredDocuments,
+		"duplicates":      result.DuplicateDocuments,
+		"errors":          result.ErrorDocuments,
+	})
+
+	return result, nil
+}
+
+// consultationDetail describes the outcome of one stored document
+func consultationDetail(fileName string, docResult ProcessingResult) ConsultationDetail {
+	detail := ConsultationDetail{
+		FileName:   fileName,
+		DocumentID: docResult.DocumentID,
+	}
+	switch {
+	case docResult.Error != nil:
+		detail.Status = "error"
+		detail.Error = docResult.Error.Error()
+	case docResult.IsDuplicate:
+		detail.Status = "duplicate"
+		detail.DuplicateReason = docResult.DuplicateReason
+	case docResult.Overwritten:
+		detail.Status = "overwritten"
+	default:
+		detail.Status = "stored"
+	}
+	return detail
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/zoomxml/config"
+)
+
+func TestConsultCompetence(t *testing.T) {
+	useFakeIngest(t)
+	const cnpj = "12345678000190"
+
+	// Pages are stored two documents at a time, so the repeated note is found stored
+	cfg := &config.Get().NFSeScheduler
+	maxInFlight := cfg.MaxInFlightDocuments
+	cfg.MaxInFlightDocuments = 2
+	t.Cleanup(func() { cfg.MaxInFlightDocuments = maxInFlight })
+	first := testNFSeXML("1", "AAA", "11111111000111", cnpj, "100.00")
+
+	tests := []struct {
+		name          string
+		provider      *streamingProvider
+		wantPages     int
+		wantFound     int
+		wantStored    int
+		wantDuplicate int
+		wantErrors    int
+		wantStatuses  []string // statuses of the first details
+		wantDetails   int
+		wantTruncated bool
+	}{
+		{
+			name:      "stored, duplicate and invalid documents",
+			provider:  &streamingProvider{xmls: []string{first, testNFSeXML("2", "BBB", "11111111000111", cnpj, "50.00"), first, "<not-xml"}},
+			wantPages: 1, wantFound: 4, wantStored: 2, wantDuplicate: 1, wantErrors: 1,
+			wantStatuses: []string{"stored", "stored", "duplicate", "error"}, wantDetails: 4,
+		},
+		{
+			name:      "details are capped",
+			provider:  &streamingProvider{records: maxConsultationDetails + 50},
+			wantPages: 2, wantFound: maxConsultationDetails + 50, wantStored: maxConsultationDetails + 50,
+			wantStatuses: []string{"stored"}, wantDetails: maxConsultationDetails, wantTruncated: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := useStreamingProvider(t, tt.provider)
+			result, err := service.ConsultCompetence(context.Background(), testCredential(), "2025-02")
+			if err != nil {
+				t.Fatalf("ConsultCompetence() error = %v", err)
+			}
+
+			if result.Competence != "2025-02" || result.StartDate != "2025-02-01" || result.EndDate != "2025-02-28" {
+				t.Errorf("ConsultCompetence() period = %s %s..%s, want 2025-02 2025-02-01..2025-02-28", result.Competence, result.StartDate, result.EndDate)
+			}
+			request := tt.provider.requests[0]
+			if got := request.StartDate.Format("2006-01-02") + ".." + request.EndDate.Format("2006-01-02"); got != "2025-02-01..2025-02-28" {
+				t.Errorf("provider queried %s, want the days of the competência", got)
+			}
+			if result.Pages != tt.wantPages || result.DocumentsFound != tt.wantFound || result.StoredDocuments != tt.wantStored ||
+				result.DuplicateDocuments != tt.wantDuplicate || result.ErrorDocuments != tt.wantErrors {
+				t.Errorf("ConsultCompetence() pages %d found %d stored %d duplicates %d errors %d, want %d %d %d %d %d",
+					result.Pages, result.DocumentsFound, result.StoredDocuments, result.DuplicateDocuments, result.ErrorDocuments,
+					tt.wantPages, tt.wantFound, tt.wantStored, tt.wantDuplicate, tt.wantErrors)
+			}
+			if len(result.Details) != tt.wantDetails || result.DetailsTruncated != tt.wantTruncated {
+				t.Errorf("details = %d truncated %v, want %d truncated %v", len(result.Details), result.DetailsTruncated, tt.wantDetails, tt.wantTruncated)
+			}
+
+			statuses := []string{}
+			for _, detail := range result.Details[:len(tt.wantStatuses)] {
+				statuses = append(statuses, detail.Status)
+				if detail.FileName == "" {
+					t.Errorf("detail %+v without file name", detail)
+				}
+				switch detail.Status {
+				case "stored":
+					if detail.DocumentID == 0 {
+						t.Errorf("stored detail %+v without document id", detail)
+					}
+				case "duplicate":
+					if detail.DuplicateReason == "" {
+						t.Errorf("duplicate detail %+v without reason", detail)
+					}
+				case "error":
+					if detail.Error == "" {
+						t.Errorf("error detail %+v without error", detail)
+					}
+				}
+			}
+			if !slices.Equal(statuses, tt.wantStatuses) {
+				t.Errorf("detail statuses = %v, want %v", statuses, tt.wantStatuses)
+			}
+		})
+	}
+}
+
+func TestConsultCompetenceInvalid(t *testing.T) {
+	service := useStreamingProvider(t, &streamingProvider{})
+	_, err := service.ConsultCompetence(context.Background(), testCredential(), "2025-13")
+	if err == nil || !strings.Contains(err.Error(), "invalid competência") {
+		t.Errorf("ConsultCompetence() error = %v, want an invalid competência", err)
+	}
+}
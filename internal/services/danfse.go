@@ -0,0 +1,156 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/logger"
+	"github.com/zoomxml/internal/models"
+	"github.com/zoomxml/internal/storage"
+)
+
+// DANFSE is a rendered DANFSE PDF with the name and version it is served under
+type DANFSE struct {
+	Data     []byte
+	FileName string
+	ETag     string // changes whenever the XML or the cancellation state of the note changes
+}
+
+// GetDocumentDANFSE returns the DANFSE PDF of the company's NFSe with the given number
+// (narrowed by verification code when given). PDFs are stored next to the XML under a
+// key versioned by the XML content hash and the cancellation state, so a stored PDF is
+// served as is and a note that changes gets a new one rendered on its next request,
+// which replaces the renditions of its previous versions.
+func GetDocumentDANFSE(ctx context.Context, companyID int64, number, verificationCode string) (*DANFSE, error) {
+	doc := models.Document{}
+	query := database.DB.NewSelect().
+		Model(&doc).
+		Column("id", "number", "storage_key", "content_hash", "hash", "is_cancelled").
+		Where("company_id = ? AND type = 'nfse'", companyID).
+		Where("number = ?", number).
+		Order("id ASC").
+		Limit(1)
+	if verificationCode != "" {
+		query = query.Where("verification_code = ?", verificationCode)
+	}
+
+	err := query.Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrDocumentNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load document: %w", err)
+	}
+
+	if doc.StorageKey == "" {
+		return nil, ErrXMLNotStored
+	}
+
+	version := danfseVersion(doc)
+	pdf := &DANFSE{
+		FileName: strings.TrimSuffix(archiveEntryName(doc), ".xml") + ".pdf",
+		ETag:     `"` + version + `"`,
+	}
+	pdfKey := danfseKeyPrefix(doc.StorageKey) + version + ".pdf"
+
+	if data, err := storage.Storage.DownloadFile(ctx, nfseBucket, pdfKey); err == nil {
+		pdf.Data = data
+		return pdf, nil
+	}
+
+	xmlData, _, err := ReadDocumentXML(ctx, companyID, doc.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := NewNFSeParser().ParseXML(string(xmlData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse stored XML: %w", err)
+	}
+
+	pdf.Data = RenderDANFSE(parsed, doc.IsCancelled)
+
+	// The PDF can always be rendered again, so failing to keep it does not fail the request
+	if err := storage.Storage.UploadFile(ctx, nfseBucket, pdfKey, pdf.Data, "application/pdf"); err != nil {
+		logger.ErrorWithFields("Failed to store DANFSE PDF", err, map[string]any{
+			"operation":   "render_danfse",
+			"company_id":  companyID,
+			"document_id": doc.ID,
+			"storage_key": pdfKey,
+		})
+		return pdf, nil
+	}
+	deleteDANFSEs(ctx, doc.StorageKey, pdfKey)
+
+	return pdf, nil
+}
+
+// danfseKeyPrefix is the start of the keys of the PDFs rendered for the XML at xmlKey
+func danfseKeyPrefix(xmlKey string) string {
+	return strings.TrimSuffix(xmlKey, ".xml") + "."
+}
+
+// deleteDANFSEs removes the PDFs rendered for the XML at xmlKey, except keep, once that
+// XML is removed, moved or no longer current. A PDF can always be rendered again, so
+// failures only leave it behind and are logged.
+func deleteDANFSEs(ctx context.Context, xmlKey, keep string) {
+	if xmlKey == "" {
+		return
+	}
+
+	prefix := danfseKeyPrefix(xmlKey)
+	keys, err := storage.Storage.ListFiles(ctx, nfseBucket, prefix)
+	if err != nil {
+		logger.WarnWithFields("Failed to list DANFSE PDFs", map[string]any{
+			"operation":   "delete_danfse",
+			"storage_key": xmlKey,
+			"error":       err.Error(),
+		})
+		return
+	}
+
+	for _, key := range keys {
+		// The prefix of 123.xml also starts the keys of 123.<hash>.xml and its PDFs
+		version, ok := strings.CutSuffix(strings.TrimPrefix(key, prefix), ".pdf")
+		if !ok || strings.Contains(version, ".") || key == keep {
+			continue
+		}
+		if err := storage.Storage.DeleteFile(ctx, nfseBucket, key); err != nil {
+			logger.WarnWithFields("Failed to delete DANFSE PDF", map[string]any{
+				"operation":   "delete_danfse",
+				"storage_key": key,
+				"error":       err.Error(),
+			})
+		}
+	}
+}
+
+// isDANFSEKey reports whether a stored object is a rendered DANFSE rather than an XML.
+// Listings that reconcile objects with document rows skip them.
+func isDANFSEKey(key string) bool {
+	return strings.HasSuffix(key, ".pdf")
+}
+
+// danfseVersion identifies the rendition of a note: its XML hash plus a cancelled marker,
+// since a provider check may cancel a note without changing its stored XML
+func danfseVersion(doc models.Document) string {
+	hash := doc.ContentHash
+	if hash == "" {
+		hash = doc.Hash
+	}
+	if hash == "" {
+		hash = "id" + strconv.FormatInt(doc.ID, 10)
+	}
+	if len(hash) > 16 {
+		hash = hash[:16]
+	}
+	if doc.IsCancelled {
+		hash += "-c"
+	}
+	return hash
+}
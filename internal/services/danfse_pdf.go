@@ -0,0 +1,313 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/zoomxml/internal/models"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// A4 page layout of the DANFSE, in PDF points
+const (
+	danfsePageWidth  = 595.0
+	danfsePageHeight = 842.0
+	danfseMargin     = 40.0
+	danfseBottom     = 50.0
+)
+
+// RenderDANFSE renders the DANFSE (human-readable representation of the NFSe) of a parsed
+// note as a PDF. Notes cancelled in the XML or flagged cancelled on the document row get a
+// CANCELADA watermark on every page.
+func RenderDANFSE(parsed *ParsedNFSeData, cancelled bool) []byte {
+	cancelled = cancelled || parsed.IsCancelled
+	w := newDANFSEWriter(cancelled)
+
+	w.text(danfseMargin, 14, true, "DANFSE - Documento Auxiliar da Nota Fiscal de Serviços Eletrônica")
+	w.gap(4)
+	w.field("Número da NFS-e", parsed.Number)
+	if parsed.Series != "" {
+		w.field("Série", parsed.Series)
+	}
+	if !parsed.IssueDate.IsZero() {
+		w.field("Data de emissão", parsed.IssueDate.Format("02/01/2006 15:04"))
+	}
+	if competence, ok := NormalizeCompetence(parsed.Competence); ok {
+		w.field("Competência", competence[5:]+"/"+competence[:4])
+	}
+	w.field("Código de verificação", parsed.VerificationCode)
+	if parsed.RpsNumber != "" {
+		w.field("RPS", strings.TrimSpace(parsed.RpsNumber+" "+parsed.RpsSeries))
+	}
+	if cancelled {
+		w.field("Situação", "CANCELADA")
+	} else if parsed.IsSubstituted {
+		w.field("Situação", "SUBSTITUÍDA")
+	}
+
+	w.section("PRESTADOR DE SERVIÇOS")
+	w.field("Razão social", parsed.ProviderName)
+	if parsed.ProviderTradeName != "" {
+		w.field("Nome fantasia", parsed.ProviderTradeName)
+	}
+	w.field("CPF/CNPJ", formatTaxID(parsed.ProviderCNPJ))
+	if parsed.MunicipalRegistration != "" {
+		w.field("Inscrição municipal", parsed.MunicipalRegistration)
+	}
+	if address := formatAddress(parsed.ProviderAddress); address != "" {
+		w.field("Endereço", address)
+	}
+
+	w.section("TOMADOR DE SERVIÇOS")
+	w.field("Razão social", parsed.TakerName)
+	w.field("CPF/CNPJ", formatTaxID(parsed.TakerCNPJ))
+	if address := formatAddress(parsed.TakerAddress); address != "" {
+		w.field("Endereço", address)
+	}
+
+	w.section("DISCRIMINAÇÃO DOS SERVIÇOS")
+	for _, line := range strings.Split(strings.ReplaceAll(parsed.Discriminacao, "\r", ""), "\n") {
+		w.paragraph(line)
+	}
+
+	w.section("VALORES")
+	w.field("Valor dos serviços", formatBRL(parsed.ServiceValue))
+	w.field("Valor do ISS", formatBRL(parsed.IssValue))
+	if parsed.ServiceCode != "" {
+		w.field("Item da lista de serviços", parsed.ServiceCode)
+	}
+	if parsed.CNAECode != "" {
+		w.field("CNAE", parsed.CNAECode)
+	}
+	if parsed.NaturezaOperacao != "" {
+		w.field("Natureza da operação", parsed.NaturezaOperacao)
+	}
+
+	return w.bytes()
+}
+
+// danfseWriter lays out lines of text top to bottom over as many pages as needed and
+// serializes them as a PDF using the standard Helvetica fonts, so no font is embedded
+type danfseWriter struct {
+	pages     []*bytes.Buffer
+	page      *bytes.Buffer
+	y         float64
+	cancelled bool
+}
+
+func newDANFSEWriter(cancelled bool) *danfseWriter {
+	w := &danfseWriter{cancelled: cancelled}
+	w.newPage()
+	return w
+}
+
+// newPage starts a page, drawing the watermark first so the text stays on top of it
+func (w *danfseWriter) newPage() {
+	w.page = &bytes.Buffer{}
+	w.pages = append(w.pages, w.page)
+	w.y = danfsePageHeight - danfseMargin
+
+	if w.cancelled {
+		// Gray text rotated 45 degrees across the middle of the page
+		fmt.Fprintf(w.page, "q 0.85 g BT /F2 110 Tf 0.7071 0.7071 -0.7071 0.7071 130 220 Tm (%s) Tj ET Q\n", pdfString("CANCELADA"))
+	}
+}
+
+// ensure starts a new page when height does not fit above the bottom margin
+func (w *danfseWriter) ensure(height float64) {
+	if w.y-height < danfseBottom {
+		w.newPage()
+	}
+}
+
+func (w *danfseWriter) gap(height float64) {
+	w.y -= height
+}
+
+// text writes one line at x in the given size, bold or regular
+func (w *danfseWriter) text(x, size float64, bold bool, s string) {
+	w.ensure(size + 4)
+	w.y -= size + 4
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(w.page, "BT /%s %s Tf %s %s Td (%s) Tj ET\n", font, pdfNumber(size), pdfNumber(x), pdfNumber(w.y), pdfString(s))
+}
+
+// section writes a bold heading over a horizontal rule
+func (w *danfseWriter) section(title string) {
+	w.ensure(40)
+	w.gap(10)
+	fmt.Fprintf(w.page, "0.5 w %s %s m %s %s l S\n",
+		pdfNumber(danfseMargin), pdfNumber(w.y), pdfNumber(danfsePageWidth-danfseMargin), pdfNumber(w.y))
+	w.gap(2)
+	w.text(danfseMargin, 10, true, title)
+}
+
+// field writes a "label: value" line, wrapping long values
+func (w *danfseWriter) field(label, value string) {
+	if strings.TrimSpace(value) == "" {
+		value = "-"
+	}
+	w.paragraph(label + ": " + value)
+}
+
+// paragraph writes text wrapped to the page width
+func (w *danfseWriter) paragraph(s string) {
+	const size = 9.0
+	// Helvetica glyphs average about half the font size in width
+	width := danfsePageWidth - 2*danfseMargin
+	maxChars := int(width / (size * 0.5))
+
+	lines := wrapText(strings.TrimRight(s, " \t"), maxChars)
+	if len(lines) == 0 {
+		w.gap(size + 4)
+		return
+	}
+	for _, line := range lines {
+		w.text(danfseMargin, size, false, line)
+	}
+}
+
+// bytes serializes the pages as a PDF 1.4 file
+func (w *danfseWriter) bytes() []byte {
+	var out bytes.Buffer
+	offsets := []int{}
+	object := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// Objects 1-4 are the catalog, the page tree and the fonts; each page then takes
+	// two objects, the page and its content stream
+	kids := make([]string, len(w.pages))
+	for i := range w.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+2*i)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(w.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+
+	for i, page := range w.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			pdfNumber(danfsePageWidth), pdfNumber(danfsePageHeight), 6+2*i))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", page.Len(), page.String()))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	return out.Bytes()
+}
+
+// pdfString encodes s in WinAnsi (the font encoding) and escapes it for a PDF literal
+// string. Characters WinAnsi lacks are replaced by '?'.
+func pdfString(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		c, ok := charmap.Windows1252.EncodeRune(r)
+		if !ok {
+			c = '?'
+		}
+		switch c {
+		case '\\', '(', ')':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case '\n', '\r', '\t':
+			b.WriteByte(' ')
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// pdfNumber formats a coordinate or size without trailing zeros
+func pdfNumber(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// wrapText splits s into lines of at most maxChars characters, breaking at spaces when
+// possible
+func wrapText(s string, maxChars int) []string {
+	lines := []string{}
+	for _, word := range strings.Fields(s) {
+		for len([]rune(word)) > maxChars {
+			runes := []rune(word)
+			lines = append(lines, string(runes[:maxChars]))
+			word = string(runes[maxChars:])
+		}
+
+		last := len(lines) - 1
+		if last >= 0 && len([]rune(lines[last]))+1+len([]rune(word)) <= maxChars {
+			lines[last] += " " + word
+		} else {
+			lines = append(lines, word)
+		}
+	}
+	return lines
+}
+
+// formatTaxID masks a CNPJ (14 digits) or CPF (11 digits); other values are kept as is
+func formatTaxID(raw string) string {
+	digits := NormalizeCNPJ(raw)
+	switch len(digits) {
+	case 14:
+		return digits[:2] + "." + digits[2:5] + "." + digits[5:8] + "/" + digits[8:12] + "-" + digits[12:]
+	case 11:
+		return digits[:3] + "." + digits[3:6] + "." + digits[6:9] + "-" + digits[9:]
+	}
+	return raw
+}
+
+// formatAddress joins the filled parts of an address in one line
+func formatAddress(address *models.Address) string {
+	if address == nil {
+		return ""
+	}
+
+	parts := []string{}
+	street := strings.TrimSpace(strings.Join([]string{address.Street, address.Number}, ", "))
+	street = strings.Trim(street, ", ")
+	for _, part := range []string{street, address.Complement, address.District, address.UF, address.PostalCode} {
+		if strings.TrimSpace(part) != "" {
+			parts = append(parts, strings.TrimSpace(part))
+		}
+	}
+	return strings.Join(parts, " - ")
+}
+
+// formatBRL formats a value as Brazilian currency, e.g. R$ 1.234,56
+func formatBRL(value float64) string {
+	negative := value < 0
+	if negative {
+		value = -value
+	}
+
+	raw := strconv.FormatFloat(value, 'f', 2, 64)
+	integer, cents := raw[:len(raw)-3], raw[len(raw)-2:]
+
+	var grouped strings.Builder
+	for i, digit := range integer {
+		if i > 0 && (len(integer)-i)%3 == 0 {
+			grouped.WriteByte('.')
+		}
+		grouped.WriteRune(digit)
+	}
+
+	result := "R$ " + grouped.String() + "," + cents
+	if negative {
+		result = "-" + result
+	}
+	return result
+}
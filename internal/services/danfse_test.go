@@ -0,0 +1,139 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/zoomxml/internal/storage"
+)
+
+// memoryStorage keeps objects in a map, keyed by object name regardless of bucket
+type memoryStorage struct {
+	objects map[string][]byte
+}
+
+func (s *memoryStorage) Initialize() error { return nil }
+
+func (s *memoryStorage) UploadFile(ctx context.Context, bucketName, objectName string, data []byte, contentType string) error {
+	s.objects[objectName] = data
+	return nil
+}
+
+func (s *memoryStorage) DownloadFile(ctx context.Context, bucketName, objectName string) ([]byte, error) {
+	data, ok := s.objects[objectName]
+	if !ok {
+		return nil, errors.New("object not found")
+	}
+	return data, nil
+}
+
+func (s *memoryStorage) DeleteFile(ctx context.Context, bucketName, objectName string) error {
+	delete(s.objects, objectName)
+	return nil
+}
+
+func (s *memoryStorage) CopyFile(ctx context.Context, bucketName, sourceName, targetName string) error {
+	s.objects[targetName] = s.objects[sourceName]
+	return nil
+}
+
+func (s *memoryStorage) FileExists(ctx context.Context, bucketName, objectName string) (bool, error) {
+	_, ok := s.objects[objectName]
+	return ok, nil
+}
+
+func (s *memoryStorage) ListFiles(ctx context.Context, bucketName, prefix string) ([]string, error) {
+	keys := []string{}
+	for key := range s.objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+// useMemoryStorage replaces the global storage with an empty memoryStorage for the test
+func useMemoryStorage(t *testing.T) *memoryStorage {
+	t.Helper()
+	previous := storage.Storage
+	memory := &memoryStorage{objects: map[string][]byte{}}
+	storage.Storage = memory
+	t.Cleanup(func() { storage.Storage = previous })
+	return memory
+}
+
+func TestDeleteDANFSEs(t *testing.T) {
+	tests := []struct {
+		name   string
+		xmlKey string
+		keep   string
+		want   []string
+	}{
+		{
+			name:   "removes every rendition",
+			xmlKey: "nfse/1/2024/01/123.xml",
+			want: []string{
+				"nfse/1/2024/01/123.abcd.xml",
+				"nfse/1/2024/01/123.abcd.ef01.pdf",
+				"nfse/1/2024/01/123.xml",
+				"nfse/1/2024/01/1234.xml",
+				"nfse/1/2024/01/1234.ef01.pdf",
+			},
+		},
+		{
+			name:   "keeps the current rendition",
+			xmlKey: "nfse/1/2024/01/123.xml",
+			keep:   "nfse/1/2024/01/123.0123456789abcdef.pdf",
+			want: []string{
+				"nfse/1/2024/01/123.0123456789abcdef.pdf",
+				"nfse/1/2024/01/123.abcd.xml",
+				"nfse/1/2024/01/123.abcd.ef01.pdf",
+				"nfse/1/2024/01/123.xml",
+				"nfse/1/2024/01/1234.xml",
+				"nfse/1/2024/01/1234.ef01.pdf",
+			},
+		},
+		{
+			name:   "leaves the PDFs of other XMLs alone",
+			xmlKey: "nfse/1/2024/01/123.abcd.xml",
+			want: []string{
+				"nfse/1/2024/01/123.0123456789abcdef.pdf",
+				"nfse/1/2024/01/123.0123456789abcdef-c.pdf",
+				"nfse/1/2024/01/123.abcd.xml",
+				"nfse/1/2024/01/123.xml",
+				"nfse/1/2024/01/1234.xml",
+				"nfse/1/2024/01/1234.ef01.pdf",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			memory := useMemoryStorage(t)
+			for _, key := range []string{
+				"nfse/1/2024/01/123.xml",
+				"nfse/1/2024/01/123.0123456789abcdef.pdf",
+				"nfse/1/2024/01/123.0123456789abcdef-c.pdf",
+				"nfse/1/2024/01/123.abcd.xml",
+				"nfse/1/2024/01/123.abcd.ef01.pdf",
+				"nfse/1/2024/01/1234.xml",
+				"nfse/1/2024/01/1234.ef01.pdf",
+			} {
+				memory.objects[key] = []byte("x")
+			}
+
+			deleteDANFSEs(context.Background(), tt.xmlKey, tt.keep)
+
+			got, _ := memory.ListFiles(context.Background(), nfseBucket, "")
+			slices.Sort(got)
+			want := slices.Clone(tt.want)
+			slices.Sort(want)
+			if !slices.Equal(got, want) {
+				t.Errorf("objects after deleteDANFSEs() = %v, want %v", got, want)
+			}
+		})
+	}
+}
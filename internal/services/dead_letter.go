@@ -0,0 +1,132 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zoomxml/config"
+	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/logger"
+	"github.com/zoomxml/internal/models"
+	"github.com/zoomxml/internal/storage"
+)
+
+// ProcessingSourceDeadLetter is the source of batches that reprocess dead-lettered XMLs.
+// Failures of those batches update the existing dead letter instead of creating another.
+const ProcessingSourceDeadLetter = "dead_letter_reprocess"
+
+// ErrDeadLetterReprocessed is returned when reprocessing an item that already succeeded
+var ErrDeadLetterReprocessed = errors.New("dead letter already reprocessed")
+
+// deadLetterKey stores dead letters apart from ingested XMLs: dead-letter/<company>/<YYYYMMDD>/<uuid>_<file>
+func deadLetterKey(companyID int64, fileName string, now time.Time) string {
+	return fmt.Sprintf("dead-letter/%d/%s/%s_%s", companyID, now.Format("20060102"), uuid.NewString(), path.Base(fileName))
+}
+
+// deadLetterFailures keeps the XMLs of a batch that failed parsing or validation. Manual
+// uploads are left out: the uploader still has the file and gets the error back. Failures
+// are logged and never interrupt the batch; losing a dead letter only loses the copy.
+func deadLetterFailures(ctx context.Context, companyID int64, source string, documents []XMLDocument, failures map[int]error) {
+	if !config.Get().Storage.DeadLetterEnabled || len(failures) == 0 {
+		return
+	}
+	if source == ProcessingSourceManualUpload || source == ProcessingSourceDeadLetter {
+		return
+	}
+
+	for i, cause := range failures {
+		doc := documents[i]
+		deadLetter := &models.DeadLetter{
+			CompanyID:  companyID,
+			FileName:   doc.FileName,
+			StorageKey: deadLetterKey(companyID, doc.FileName, time.Now()),
+			Source:     source,
+			Competence: doc.Competence,
+			Error:      cause.Error(),
+			Status:     models.DeadLetterStatusPending,
+		}
+
+		if err := storage.Storage.UploadFile(ctx, nfseBucket, deadLetter.StorageKey, []byte(doc.Content), "application/xml"); err != nil {
+			logger.ErrorWithFields("Failed to store dead letter", err, map[string]any{
+				"operation":  "dead_letter",
+				"company_id": companyID,
+				"file_name":  doc.FileName,
+			})
+			continue
+		}
+
+		if _, err := database.DB.NewInsert().Model(deadLetter).Exec(ctx); err != nil {
+			logger.ErrorWithFields("Failed to record dead letter", err, map[string]any{
+				"operation":   "dead_letter",
+				"company_id":  companyID,
+				"storage_key": deadLetter.StorageKey,
+			})
+			continue
+		}
+
+		logger.WarnWithFields("Document dead-lettered", map[string]any{
+			"operation":      "dead_letter",
+			"company_id":     companyID,
+			"dead_letter_id": deadLetter.ID,
+			"file_name":      doc.FileName,
+			"error":          deadLetter.Error,
+		})
+	}
+}
+
+// GetDeadLetter loads a dead letter of a company
+func GetDeadLetter(ctx context.Context, companyID, deadLetterID int64) (*models.DeadLetter, error) {
+	deadLetter := &models.DeadLetter{}
+	err := database.DB.NewSelect().
+		Model(deadLetter).
+		Where("id = ? AND company_id = ?", deadLetterID, companyID).
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return deadLetter, nil
+}
+
+// ReprocessDeadLetter ingests a dead-lettered XML again with the current parser and
+// company policies. On success (stored or found to be a duplicate) the dead letter is
+// marked reprocessed; otherwise its attempts and error are updated and the processing
+// result carries the new error.
+func (m *NFSeXMLManager) ReprocessDeadLetter(ctx context.Context, deadLetter *models.DeadLetter) (*ProcessingResult, error) {
+	if deadLetter.Status == models.DeadLetterStatusReprocessed {
+		return nil, ErrDeadLetterReprocessed
+	}
+
+	content, err := storage.Storage.DownloadFile(ctx, nfseBucket, deadLetter.StorageKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download dead letter: %w", err)
+	}
+
+	batch, err := m.ProcessBatchXML(ctx, deadLetter.CompanyID, BatchOptions{Source: ProcessingSourceDeadLetter}, []XMLDocument{
+		{FileName: deadLetter.FileName, Content: string(content), Competence: deadLetter.Competence},
+	})
+	if err != nil {
+		return nil, err
+	}
+	result := &batch.Results[0]
+
+	deadLetter.Attempts++
+	columns := []string{"attempts", "updated_at"}
+	if result.Error != nil {
+		deadLetter.Error = result.Error.Error()
+		columns = append(columns, "error")
+	} else {
+		deadLetter.Status = models.DeadLetterStatusReprocessed
+		deadLetter.ReprocessedDocumentID = result.DocumentID
+		columns = append(columns, "status", "reprocessed_document_id")
+	}
+
+	if _, err := database.DB.NewUpdate().Model(deadLetter).Column(columns...).WherePK().Exec(ctx); err != nil {
+		return result, fmt.Errorf("failed to update dead letter: %w", err)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,148 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/zoomxml/config"
+	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/database/databasetest"
+	"github.com/zoomxml/internal/models"
+)
+
+// useDeadLetters turns dead-lettering on or off for the test
+func useDeadLetters(t *testing.T, enabled bool) {
+	t.Helper()
+	cfg := &config.Get().Storage
+	previous := cfg.DeadLetterEnabled
+	cfg.DeadLetterEnabled = enabled
+	t.Cleanup(func() { cfg.DeadLetterEnabled = previous })
+}
+
+// TestDeadLetterFailures checks which failures are kept in storage. The dead letter rows
+// cannot be recorded without a database, which only loses the row: the copy is kept.
+func TestDeadLetterFailures(t *testing.T) {
+	databasetest.UseClosed(t)
+	documents := []XMLDocument{
+		{FileName: "ok.xml", Content: "<nfse>ok</nfse>"},
+		{FileName: "provider/broken.xml", Content: "<nfse>broken"},
+	}
+	failures := map[int]error{1: errors.New("failed to parse XML")}
+
+	tests := []struct {
+		name    string
+		enabled bool
+		source  string
+		want    bool
+	}{
+		{"provider ingest", true, ProcessingSourcePrefeituraAPI, true},
+		{"pending ingest retry", true, ProcessingSourcePendingRetry, true},
+		{"disabled", false, ProcessingSourcePrefeituraAPI, false},
+		{"manual upload", true, ProcessingSourceManualUpload, false},
+		{"dead letter reprocess", true, ProcessingSourceDeadLetter, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useDeadLetters(t, tt.enabled)
+			memory := useMemoryStorage(t)
+
+			deadLetterFailures(context.Background(), 42, tt.source, documents, failures)
+
+			if !tt.want {
+				if len(memory.objects) != 0 {
+					t.Errorf("stored %d objects, want none", len(memory.objects))
+				}
+				return
+			}
+			if len(memory.objects) != 1 {
+				t.Fatalf("stored %d objects, want the failed document only", len(memory.objects))
+			}
+			for key, content := range memory.objects {
+				if !strings.HasPrefix(key, "dead-letter/42/") || !strings.HasSuffix(key, "_broken.xml") {
+					t.Errorf("dead letter key = %s, want dead-letter/42/<date>/<uuid>_broken.xml", key)
+				}
+				if string(content) != documents[1].Content {
+					t.Errorf("dead letter content = %q, want %q", content, documents[1].Content)
+				}
+			}
+		})
+	}
+}
+
+func TestReprocessDeadLetter(t *testing.T) {
+	databasetest.Require(t)
+	ctx := context.Background()
+	useDeadLetters(t, true)
+	memory := useMemoryStorage(t)
+	manager := NewNFSeXMLManager()
+	company := databasetest.CreateCompany(t, nil)
+
+	batch, err := manager.ProcessBatchXML(ctx, company.ID, BatchOptions{Source: ProcessingSourcePrefeituraAPI}, []XMLDocument{
+		{FileName: "broken.xml", Content: "<consultarNotaResponse>"},
+	})
+	if err != nil {
+		t.Fatalf("ProcessBatchXML() error = %v", err)
+	}
+	if !batch.Results[0].Rejected {
+		t.Fatalf("ProcessBatchXML() result = %+v, want the XML rejected", batch.Results[0])
+	}
+
+	deadLetters := func() []models.DeadLetter {
+		t.Helper()
+		var rows []models.DeadLetter
+		if err := database.DB.NewSelect().Model(&rows).Where("company_id = ?", company.ID).Scan(ctx); err != nil {
+			t.Fatal(err)
+		}
+		return rows
+	}
+	rows := deadLetters()
+	if len(rows) != 1 {
+		t.Fatalf("dead letters = %d, want 1", len(rows))
+	}
+	deadLetter, err := GetDeadLetter(ctx, company.ID, rows[0].ID)
+	if err != nil {
+		t.Fatalf("GetDeadLetter() error = %v", err)
+	}
+	if deadLetter.Status != models.DeadLetterStatusPending || string(memory.objects[deadLetter.StorageKey]) != "<consultarNotaResponse>" {
+		t.Fatalf("dead letter = %+v with content %q, want the pending failed XML", deadLetter, memory.objects[deadLetter.StorageKey])
+	}
+
+	// Reprocessing the same content fails again without creating another dead letter
+	result, err := manager.ReprocessDeadLetter(ctx, deadLetter)
+	if err != nil {
+		t.Fatalf("ReprocessDeadLetter() error = %v", err)
+	}
+	if result.Error == nil || deadLetter.Attempts != 1 || deadLetter.Status != models.DeadLetterStatusPending {
+		t.Errorf("failed reprocess = %+v, dead letter %+v, want an error and one pending attempt", result, deadLetter)
+	}
+	if rows := deadLetters(); len(rows) != 1 {
+		t.Errorf("dead letters after a failed reprocess = %d, want 1", len(rows))
+	}
+
+	// Once the stored XML is fixed, it is ingested
+	memory.objects[deadLetter.StorageKey] = []byte(testNFSeXML(fmt.Sprint(deadLetter.ID), "DEAD-LETTER", company.CNPJ, "", "100.00"))
+	result, err = manager.ReprocessDeadLetter(ctx, deadLetter)
+	if err != nil || result.Error != nil {
+		t.Fatalf("ReprocessDeadLetter() = %+v, %v, want the document stored", result, err)
+	}
+
+	stored, err := GetDeadLetter(ctx, company.ID, deadLetter.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != models.DeadLetterStatusReprocessed || stored.Attempts != 2 || stored.ReprocessedDocumentID != result.DocumentID {
+		t.Errorf("dead letter = %+v, want reprocessed into document %d after 2 attempts", stored, result.DocumentID)
+	}
+	exists, err := database.DB.NewSelect().Model((*models.Document)(nil)).Where("id = ? AND company_id = ?", result.DocumentID, company.ID).Exists(ctx)
+	if err != nil || !exists {
+		t.Errorf("reprocessed document %d exists = %v, %v, want it stored", result.DocumentID, exists, err)
+	}
+
+	if _, err := manager.ReprocessDeadLetter(ctx, stored); !errors.Is(err, ErrDeadLetterReprocessed) {
+		t.Errorf("ReprocessDeadLetter() of a reprocessed item error = %v, want %v", err, ErrDeadLetterReprocessed)
+	}
+}
@@ -0,0 +1,78 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"strings"
+	"time"
+
+	"github.com/zoomxml/config"
+	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/logger"
+	"github.com/zoomxml/internal/models"
+	"github.com/zoomxml/internal/storage"
+)
+
+// debugCapturePrefix is where raw provider responses are stored; the storage layer
+// expires objects under it after STORAGE_DEBUG_CAPTURE_TTL_DAYS
+const debugCapturePrefix = "debug/"
+
+// captureRawResponse stores the raw provider response when debug capture is enabled
+// in config and on the company. Failures are logged and never affect the fetch.
+func captureRawResponse(ctx context.Context, companyID int64, page int, contentType string, body []byte) {
+	cfg := config.Get()
+	if !cfg.Storage.DebugCaptureEnabled || storage.Storage == nil {
+		return
+	}
+
+	var enabled bool
+	err := database.DB.NewSelect().
+		Model((*models.Company)(nil)).
+		Column("debug_capture").
+		Where("id = ?", companyID).
+		Scan(ctx, &enabled)
+
+	if err != nil || !enabled {
+		return
+	}
+
+	key := fmt.Sprintf("%s%d/%s-page%d%s", debugCapturePrefix, companyID, time.Now().Format("20060102T150405.000"), page, debugCaptureExtension(contentType))
+	if err := storage.Storage.UploadFile(ctx, cfg.Storage.Bucket, key, body, contentType); err != nil {
+		logger.ErrorWithFields("Failed to store raw provider response", err, map[string]any{
+			"operation":  "debug_capture",
+			"company_id": companyID,
+			"key":        key,
+		})
+		return
+	}
+
+	logger.DebugWithFields("Stored raw provider response", map[string]any{
+		"operation":  "debug_capture",
+		"company_id": companyID,
+		"key":        key,
+		"size":       len(body),
+	})
+}
+
+// debugCaptureExtension names a captured response after its Content-Type, so a ZIP or XML
+// response is not stored as .json; unknown or missing types get .bin
+func debugCaptureExtension(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ".bin"
+	}
+
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		return ".json"
+	case mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml"):
+		return ".xml"
+	case mediaType == "application/zip" || mediaType == "application/x-zip-compressed":
+		return ".zip"
+	case strings.HasPrefix(mediaType, "text/"):
+		return ".txt"
+	default:
+		return ".bin"
+	}
+}
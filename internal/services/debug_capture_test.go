@@ -0,0 +1,106 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/zoomxml/config"
+	"github.com/zoomxml/internal/database/databasetest"
+	"github.com/zoomxml/internal/models"
+)
+
+// setDebugCapture sets STORAGE_DEBUG_CAPTURE_ENABLED while the test runs
+func setDebugCapture(t *testing.T, enabled bool) {
+	t.Helper()
+	cfg := &config.Get().Storage
+	previous := cfg.DebugCaptureEnabled
+	cfg.DebugCaptureEnabled = enabled
+	t.Cleanup(func() { cfg.DebugCaptureEnabled = previous })
+}
+
+func TestDebugCaptureExtension(t *testing.T) {
+	tests := []struct {
+		contentType string
+		want        string
+	}{
+		{"application/json", ".json"},
+		{"application/json; charset=utf-8", ".json"},
+		{"application/problem+json", ".json"},
+		{"application/xml", ".xml"},
+		{"text/xml; charset=ISO-8859-1", ".xml"},
+		{"application/soap+xml", ".xml"},
+		{"application/zip", ".zip"},
+		{"application/x-zip-compressed", ".zip"},
+		{"text/plain", ".txt"},
+		{"application/octet-stream", ".bin"},
+		{"", ".bin"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.contentType, func(t *testing.T) {
+			if got := debugCaptureExtension(tt.contentType); got != tt.want {
+				t.Errorf("debugCaptureExtension(%q) = %q, want %q", tt.contentType, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestCaptureRawResponseDisabledInConfig checks that nothing is stored, nor the company
+// looked up, while capture is disabled in config
+func TestCaptureRawResponseDisabledInConfig(t *testing.T) {
+	databasetest.UseClosed(t)
+	memory := useMemoryStorage(t)
+	setDebugCapture(t, false)
+
+	captureRawResponse(context.Background(), 1, 1, "application/json", []byte(`{}`))
+	if len(memory.objects) != 0 {
+		t.Errorf("stored %d objects with capture disabled, want none", len(memory.objects))
+	}
+}
+
+func TestCaptureRawResponse(t *testing.T) {
+	databasetest.Require(t)
+	body := []byte(`{"RecordCount":1}`)
+
+	tests := []struct {
+		name           string
+		configEnabled  bool
+		companyEnabled bool
+		wantStored     bool
+	}{
+		{"enabled in config and on the company", true, true, true},
+		{"disabled on the company", true, false, false},
+		{"disabled in config", false, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			memory := useMemoryStorage(t)
+			setDebugCapture(t, tt.configEnabled)
+			company := databasetest.CreateCompany(t, func(c *models.Company) { c.DebugCapture = tt.companyEnabled })
+
+			captureRawResponse(context.Background(), company.ID, 3, "application/json; charset=utf-8", body)
+
+			if !tt.wantStored {
+				if len(memory.objects) != 0 {
+					t.Errorf("stored %v, want nothing", memory.objects)
+				}
+				return
+			}
+			if len(memory.objects) != 1 {
+				t.Fatalf("stored %d objects, want 1", len(memory.objects))
+			}
+			for key, data := range memory.objects {
+				prefix := fmt.Sprintf("%s%d/", debugCapturePrefix, company.ID)
+				if !strings.HasPrefix(key, prefix) || !strings.HasSuffix(key, "-page3.json") {
+					t.Errorf("capture stored at %q, want under %s ending in -page3.json", key, prefix)
+				}
+				if string(data) != string(body) {
+					t.Errorf("captured %q, want %q", data, body)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,298 @@
+package services
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/models"
+	"github.com/zoomxml/internal/storage"
+)
+
+const (
+	// MaxArchiveDocuments caps how many documents one ZIP download may request
+	MaxArchiveDocuments = 500
+	// MaxArchiveBytes caps the total size of the XML objects written to one ZIP download
+	MaxArchiveBytes = 100 << 20
+	// MaxArchiveRangeDays caps the issue date range of one date range download
+	MaxArchiveRangeDays = 366
+
+	archiveReportName   = "skipped.json"
+	archiveManifestName = "manifest.csv"
+)
+
+// ErrArchiveTooManyDocuments is returned when a date range holds more than MaxArchiveDocuments
+var ErrArchiveTooManyDocuments = fmt.Errorf("more than %d documents in range", MaxArchiveDocuments)
+
+// Reasons a requested document is left out of an archive
+const (
+	ArchiveSkipNotFound      = "not_found"      // no such document for the company
+	ArchiveSkipObjectMissing = "object_missing" // the row exists but its storage object does not
+	ArchiveSkipSizeLimit     = "size_limit"     // adding it would exceed MaxArchiveBytes
+	ArchiveSkipXMLNotStored  = "xml_not_stored" // the company keeps metadata only, so there is no XML
+)
+
+// SkippedArchiveDocument is a requested document that was not written to the archive
+type SkippedArchiveDocument struct {
+	DocumentID int64  `json:"document_id"`
+	Reason     string `json:"reason"`
+	Error      string `json:"error,omitempty"`
+}
+
+// DocumentArchiveReport is the outcome of writing a document archive
+type DocumentArchiveReport struct {
+	CompanyID int64                    `json:"company_id"`
+	Requested int                      `json:"requested"`
+	Written   int                      `json:"written"`
+	Bytes     int64                    `json:"bytes"`
+	Skipped   []SkippedArchiveDocument `json:"skipped"`
+}
+
+// ArchiveRangeDocumentIDs returns the documents (NFSe, NF-e and CT-e) of a company issued
+// between from and to (inclusive dates), oldest first, or ErrArchiveTooManyDocuments past
+// MaxArchiveDocuments
+func ArchiveRangeDocumentIDs(ctx context.Context, companyID int64, from, to time.Time) ([]int64, error) {
+	var ids []int64
+	err := database.DB.NewSelect().
+		Model((*models.Document)(nil)).
+		Column("id").
+		Where("company_id = ?", companyID).
+		Where("issue_date >= ? AND issue_date < ?", from, to.AddDate(0, 0, 1)).
+		Order("issue_date ASC", "id ASC").
+		Limit(MaxArchiveDocuments+1).
+		Scan(ctx, &ids)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to load documents: %w", err)
+	}
+	if len(ids) > MaxArchiveDocuments {
+		return nil, ErrArchiveTooManyDocuments
+	}
+	return ids, nil
+}
+
+// WriteDocumentArchive streams the stored XML of the given documents of a company
+// into a ZIP written to w, one object at a time. Documents that do not belong to the
+// company, whose object is missing or that would push the archive past maxBytes are
+// skipped; when any is skipped, a skipped.json entry listing them closes the archive.
+// With withManifest, a manifest.csv entry describes every note written to the archive.
+func WriteDocumentArchive(ctx context.Context, w io.Writer, companyID int64, documentIDs []int64, maxBytes int64, withManifest bool) (*DocumentArchiveReport, error) {
+	documents := []models.Document{}
+	err := database.DB.NewSelect().
+		Model(&documents).
+		Column("id", "number", "issue_date", "service_value", "status", "storage_key").
+		Where("company_id = ?", companyID).
+		Where("id IN (?)", bun.In(documentIDs)).
+		Scan(ctx)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to load documents: %w", err)
+	}
+
+	byID := make(map[int64]models.Document, len(documents))
+	for _, doc := range documents {
+		byID[doc.ID] = doc
+	}
+
+	report := &DocumentArchiveReport{
+		CompanyID: companyID,
+		Requested: len(documentIDs),
+		Skipped:   []SkippedArchiveDocument{},
+	}
+
+	archive := zip.NewWriter(w)
+	seen := make(map[int64]bool, len(documentIDs))
+	written := []models.Document{}
+
+	for _, id := range documentIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		doc, ok := byID[id]
+		if !ok {
+			report.Skipped = append(report.Skipped, SkippedArchiveDocument{DocumentID: id, Reason: ArchiveSkipNotFound})
+			continue
+		}
+		if doc.StorageKey == "" {
+			report.Skipped = append(report.Skipped, SkippedArchiveDocument{DocumentID: id, Reason: ArchiveSkipXMLNotStored})
+			continue
+		}
+
+		data, err := storage.Storage.DownloadFile(ctx, nfseBucket, doc.StorageKey)
+		if err == nil {
+			data, err = decodeStoredXML(data)
+		}
+		if err != nil {
+			report.Skipped = append(report.Skipped, SkippedArchiveDocument{DocumentID: id, Reason: ArchiveSkipObjectMissing, Error: err.Error()})
+			continue
+		}
+
+		if report.Bytes+int64(len(data)) > maxBytes {
+			report.Skipped = append(report.Skipped, SkippedArchiveDocument{DocumentID: id, Reason: ArchiveSkipSizeLimit})
+			continue
+		}
+
+		entry, err := archive.Create(archiveEntryName(doc))
+		if err != nil {
+			return report, fmt.Errorf("failed to create archive entry: %w", err)
+		}
+		if _, err := entry.Write(data); err != nil {
+			return report, fmt.Errorf("failed to write archive entry: %w", err)
+		}
+
+		report.Written++
+		report.Bytes += int64(len(data))
+		written = append(written, doc)
+	}
+
+	if withManifest {
+		entry, err := archive.Create(archiveManifestName)
+		if err != nil {
+			return report, fmt.Errorf("failed to create archive manifest: %w", err)
+		}
+		if err := writeArchiveManifest(entry, written); err != nil {
+			return report, fmt.Errorf("failed to write archive manifest: %w", err)
+		}
+	}
+
+	if len(report.Skipped) > 0 {
+		entry, err := archive.Create(archiveReportName)
+		if err != nil {
+			return report, fmt.Errorf("failed to create archive report: %w", err)
+		}
+		if err := json.NewEncoder(entry).Encode(report.Skipped); err != nil {
+			return report, fmt.Errorf("failed to write archive report: %w", err)
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		return report, fmt.Errorf("failed to close archive: %w", err)
+	}
+
+	return report, nil
+}
+
+// writeArchiveManifest writes one CSV line per archived note, pointing to its XML entry
+func writeArchiveManifest(w io.Writer, documents []models.Document) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"document_id", "number", "issue_date", "service_value", "status", "file"}); err != nil {
+		return err
+	}
+
+	for _, doc := range documents {
+		record := []string{
+			strconv.FormatInt(doc.ID, 10),
+			doc.Number,
+			doc.IssueDate.Format("2006-01-02"),
+			strconv.FormatFloat(doc.ServiceValue, 'f', 2, 64),
+			doc.Status,
+			archiveEntryName(doc),
+		}
+		if err := writer.Write(CSVSafeRecord(record)); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// archiveEntryName names an archived XML after its document ID and number, which keeps
+// entries unique even when two documents share a number
+func archiveEntryName(doc models.Document) string {
+	name := strconv.FormatInt(doc.ID, 10)
+	if doc.Number != "" {
+		name += "_" + strings.NewReplacer("/", "-", "\\", "-").Replace(doc.Number)
+	}
+	return name + ".xml"
+}
+
+// WriteCompetenceArchive streams the stored XML of every document of a company for
+// a competência (YYYY-MM) into a ZIP written to w. Rows are loaded exportBatchSize at a
+// time and each object is downloaded only when its entry is written, so the archive
+// never holds more than one XML in memory regardless of how many notes the competência
+// has. Rows without stored XML or whose object is missing are listed in skipped.json.
+func WriteCompetenceArchive(ctx context.Context, w io.Writer, companyID int64, competence string) (*DocumentArchiveReport, error) {
+	report := &DocumentArchiveReport{
+		CompanyID: companyID,
+		Skipped:   []SkippedArchiveDocument{},
+	}
+
+	archive := zip.NewWriter(w)
+	var lastID int64
+	for {
+		batch := []models.Document{}
+		err := database.DB.NewSelect().
+			Model(&batch).
+			Column("id", "number", "storage_key").
+			Where("company_id = ?", companyID).
+			Where("competence_month = ?", competence).
+			Where("id > ?", lastID).
+			Order("id ASC").
+			Limit(exportBatchSize).
+			Scan(ctx)
+
+		if err != nil {
+			return report, fmt.Errorf("failed to load documents: %w", err)
+		}
+
+		for _, doc := range batch {
+			report.Requested++
+			if doc.StorageKey == "" {
+				report.Skipped = append(report.Skipped, SkippedArchiveDocument{DocumentID: doc.ID, Reason: ArchiveSkipXMLNotStored})
+				continue
+			}
+
+			data, err := storage.Storage.DownloadFile(ctx, nfseBucket, doc.StorageKey)
+			if err == nil {
+				data, err = decodeStoredXML(data)
+			}
+			if err != nil {
+				report.Skipped = append(report.Skipped, SkippedArchiveDocument{DocumentID: doc.ID, Reason: ArchiveSkipObjectMissing, Error: err.Error()})
+				continue
+			}
+
+			entry, err := archive.Create(archiveEntryName(doc))
+			if err != nil {
+				return report, fmt.Errorf("failed to create archive entry: %w", err)
+			}
+			if _, err := entry.Write(data); err != nil {
+				return report, fmt.Errorf("failed to write archive entry: %w", err)
+			}
+
+			report.Written++
+			report.Bytes += int64(len(data))
+		}
+
+		if len(batch) < exportBatchSize {
+			break
+		}
+		lastID = batch[len(batch)-1].ID
+	}
+
+	if len(report.Skipped) > 0 {
+		entry, err := archive.Create(archiveReportName)
+		if err != nil {
+			return report, fmt.Errorf("failed to create archive report: %w", err)
+		}
+		if err := json.NewEncoder(entry).Encode(report.Skipped); err != nil {
+			return report, fmt.Errorf("failed to write archive report: %w", err)
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		return report, fmt.Errorf("failed to close archive: %w", err)
+	}
+
+	return report, nil
+}
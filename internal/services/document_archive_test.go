@@ -0,0 +1,219 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zoomxml/internal/database/databasetest"
+	"github.com/zoomxml/internal/models"
+)
+
+// readArchive returns the entries of a ZIP by name
+func readArchive(t *testing.T, data []byte) map[string][]byte {
+	t.Helper()
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("archive is not a ZIP: %v", err)
+	}
+
+	entries := map[string][]byte{}
+	for _, file := range reader.File {
+		rc, err := file.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries[file.Name] = content
+	}
+	return entries
+}
+
+func TestArchiveEntryName(t *testing.T) {
+	tests := []struct {
+		doc  models.Document
+		want string
+	}{
+		{models.Document{ID: 7, Number: "4521"}, "7_4521.xml"},
+		{models.Document{ID: 7, Number: "2025/4521"}, "7_2025-4521.xml"},
+		{models.Document{ID: 7}, "7.xml"},
+	}
+
+	for _, tt := range tests {
+		if got := archiveEntryName(tt.doc); got != tt.want {
+			t.Errorf("archiveEntryName(%d, %q) = %s, want %s", tt.doc.ID, tt.doc.Number, got, tt.want)
+		}
+	}
+}
+
+func TestWriteDocumentArchiveMixedIDs(t *testing.T) {
+	databasetest.Require(t)
+	memory := useMemoryStorage(t)
+
+	company := databasetest.CreateCompany(t, nil)
+	other := databasetest.CreateCompany(t, nil)
+	stored := func(number string) *models.Document {
+		key := fmt.Sprintf("nfse/2025/032025/%s/%s.xml", company.CNPJ, number)
+		memory.objects[key] = []byte("<nfse>" + number + "</nfse>")
+		return databasetest.CreateDocument(t, &models.Document{CompanyID: company.ID, Number: number, StorageKey: key})
+	}
+
+	first := stored("1")
+	second := stored("2")
+	overLimit := stored("3")
+	missing := databasetest.CreateDocument(t, &models.Document{CompanyID: company.ID, Number: "4", StorageKey: "nfse/2025/032025/gone.xml"})
+	metadataOnly := databasetest.CreateDocument(t, &models.Document{CompanyID: company.ID, Number: "5"})
+	foreign := databasetest.CreateDocument(t, &models.Document{CompanyID: other.ID, Number: "6", StorageKey: "nfse/2025/032025/foreign.xml"})
+	memory.objects[foreign.StorageKey] = []byte("<nfse>6</nfse>")
+	const unknownID = int64(1) << 40
+
+	// The limit fits the first two notes only
+	maxBytes := int64(len(memory.objects[first.StorageKey]) + len(memory.objects[second.StorageKey]))
+	ids := []int64{first.ID, missing.ID, unknownID, second.ID, first.ID, metadataOnly.ID, foreign.ID, overLimit.ID}
+
+	var buf bytes.Buffer
+	report, err := WriteDocumentArchive(context.Background(), &buf, company.ID, ids, maxBytes, false)
+	if err != nil {
+		t.Fatalf("WriteDocumentArchive() error = %v", err)
+	}
+	if report.Requested != len(ids) || report.Written != 2 || report.Bytes != maxBytes {
+		t.Errorf("report = %+v, want %d requested, 2 written, %d bytes", report, len(ids), maxBytes)
+	}
+
+	entries := readArchive(t, buf.Bytes())
+	for _, doc := range []*models.Document{first, second} {
+		name := archiveEntryName(*doc)
+		if got := string(entries[name]); got != string(memory.objects[doc.StorageKey]) {
+			t.Errorf("entry %s = %q, want %q", name, got, memory.objects[doc.StorageKey])
+		}
+	}
+	if len(entries) != 3 {
+		t.Errorf("archive entries = %d, want the two notes and %s", len(entries), archiveReportName)
+	}
+
+	var skipped []SkippedArchiveDocument
+	if err := json.Unmarshal(entries[archiveReportName], &skipped); err != nil {
+		t.Fatalf("%s is not valid: %v", archiveReportName, err)
+	}
+	reasons := map[int64]string{}
+	for _, s := range skipped {
+		reasons[s.DocumentID] = s.Reason
+	}
+	want := map[int64]string{
+		missing.ID:      ArchiveSkipObjectMissing,
+		unknownID:       ArchiveSkipNotFound,
+		metadataOnly.ID: ArchiveSkipXMLNotStored,
+		foreign.ID:      ArchiveSkipNotFound,
+		overLimit.ID:    ArchiveSkipSizeLimit,
+	}
+	if len(reasons) != len(want) || len(skipped) != len(want) {
+		t.Errorf("skipped = %v, want %v", reasons, want)
+	}
+	for id, reason := range want {
+		if reasons[id] != reason {
+			t.Errorf("document %d skipped as %q, want %q", id, reasons[id], reason)
+		}
+	}
+}
+
+func TestWriteArchiveManifest(t *testing.T) {
+	issued := time.Date(2025, 3, 14, 9, 30, 0, 0, time.UTC)
+	documents := []models.Document{
+		{ID: 7, Number: "4521", IssueDate: issued, ServiceValue: 1500, Status: "active"},
+		{ID: 9, Number: "=4522", IssueDate: issued, ServiceValue: 99.999, Status: "cancelled"},
+	}
+
+	var buf bytes.Buffer
+	if err := writeArchiveManifest(&buf, documents); err != nil {
+		t.Fatalf("writeArchiveManifest() error = %v", err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := [][]string{
+		{"document_id", "number", "issue_date", "service_value", "status", "file"},
+		{"7", "4521", "2025-03-14", "1500.00", "active", "7_4521.xml"},
+		// Values a spreadsheet would evaluate are neutralised
+		{"9", "'=4522", "2025-03-14", "100.00", "cancelled", "9_=4522.xml"},
+	}
+	if !slices.EqualFunc(records, want, slices.Equal) {
+		t.Errorf("manifest = %q, want %q", records, want)
+	}
+}
+
+func TestDateRangeArchiveManifest(t *testing.T) {
+	databasetest.Require(t)
+	ctx := context.Background()
+	memory := useMemoryStorage(t)
+	from := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC)
+
+	company := databasetest.CreateCompany(t, nil)
+	document := func(number string, issued time.Time, stored bool) *models.Document {
+		doc := &models.Document{CompanyID: company.ID, Number: number, IssueDate: issued, ServiceValue: 100, Status: "active"}
+		if stored {
+			doc.StorageKey = fmt.Sprintf("nfse/2025/032025/%s/%s.xml", company.CNPJ, number)
+			memory.objects[doc.StorageKey] = []byte("<nfse>" + number + "</nfse>")
+		}
+		return databasetest.CreateDocument(t, doc)
+	}
+
+	first := document("1", from, true)
+	lastDay := document("2", to.Add(23*time.Hour), true)
+	metadataOnly := document("3", from.AddDate(0, 0, 10), false)
+	document("4", from.Add(-time.Second), true)
+	document("5", to.AddDate(0, 0, 1), true)
+
+	ids, err := ArchiveRangeDocumentIDs(ctx, company.ID, from, to)
+	if err != nil {
+		t.Fatalf("ArchiveRangeDocumentIDs() error = %v", err)
+	}
+	if want := []int64{first.ID, metadataOnly.ID, lastDay.ID}; !slices.Equal(ids, want) {
+		t.Fatalf("ArchiveRangeDocumentIDs() = %v, want %v", ids, want)
+	}
+
+	var buf bytes.Buffer
+	if _, err := WriteDocumentArchive(ctx, &buf, company.ID, ids, MaxArchiveBytes, true); err != nil {
+		t.Fatalf("WriteDocumentArchive() error = %v", err)
+	}
+	entries := readArchive(t, buf.Bytes())
+
+	records, err := csv.NewReader(bytes.NewReader(entries[archiveManifestName])).ReadAll()
+	if err != nil || len(records) == 0 {
+		t.Fatalf("%s = %q, %v, want a CSV with a header", archiveManifestName, entries[archiveManifestName], err)
+	}
+
+	// Every note in the archive is listed, and every listed file is in the archive
+	listed := []string{}
+	for _, record := range records[1:] {
+		if _, ok := entries[record[5]]; !ok {
+			t.Errorf("manifest lists %s, which is not in the archive", record[5])
+		}
+		listed = append(listed, record[1])
+	}
+	if want := []string{first.Number, lastDay.Number}; !slices.Equal(listed, want) {
+		t.Errorf("manifest numbers = %v, want %v", listed, want)
+	}
+	for name := range entries {
+		if strings.HasSuffix(name, ".xml") && !slices.ContainsFunc(records, func(r []string) bool { return r[5] == name }) {
+			t.Errorf("archive entry %s is missing from the manifest", name)
+		}
+	}
+	if !strings.Contains(string(entries[archiveReportName]), fmt.Sprint(metadataOnly.ID)) {
+		t.Errorf("%s = %s, want the document without XML", archiveReportName, entries[archiveReportName])
+	}
+}
@@ -0,0 +1,287 @@
+package services
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/models"
+)
+
+// exportBatchSize is how many documents are loaded per query while streaming an export
+const exportBatchSize = 1000
+
+// Formats accepted by WriteDocumentExport
+const (
+	ExportFormatCSV  = "csv"
+	ExportFormatXLSX = "xlsx"
+)
+
+// exportColumns is the header row of a document export
+var exportColumns = []string{
+	"document_id", "number", "issue_date", "competence", "provider_cnpj", "provider_name",
+	"taker_cnpj", "taker_name", "service_value", "iss_value", "status", "is_cancelled",
+}
+
+// DocumentExportFilter narrows a document export; zero values leave a bound open
+type DocumentExportFilter struct {
+	StartDate  time.Time // issue date, inclusive
+	EndDate    time.Time // issue date, inclusive
+	Competence string    // YYYY-MM
+}
+
+// exportRowWriter receives the rows of an export one by one
+type exportRowWriter interface {
+	WriteRow(doc models.Document) error
+	Close() error
+}
+
+// WriteDocumentExport streams the metadata of the NFSe documents of a company matching
+// filter to w in the given format, loading exportBatchSize documents at a time, and
+// returns how many rows were written
+func WriteDocumentExport(ctx context.Context, w io.Writer, companyID int64, filter DocumentExportFilter, format string) (int, error) {
+	var rows exportRowWriter
+	var err error
+	switch format {
+	case ExportFormatCSV:
+		rows, err = newCSVExportWriter(w)
+	case ExportFormatXLSX:
+		rows, err = newXLSXExportWriter(w)
+	default:
+		return 0, fmt.Errorf("unsupported export format %q", format)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to start export: %w", err)
+	}
+
+	written := 0
+	var lastID int64
+	for {
+		batch := []models.Document{}
+		query := database.DB.NewSelect().
+			Model(&batch).
+			Column("id", "number", "issue_date", "competence", "provider_cnpj", "provider_name",
+				"taker_cnpj", "taker_name", "service_value", "iss_value", "status", "is_cancelled").
+			Where("company_id = ? AND type = 'nfse'", companyID).
+			Where("id > ?", lastID).
+			Order("id ASC").
+			Limit(exportBatchSize)
+		applyDocumentExportFilter(query, filter)
+
+		if err := query.Scan(ctx); err != nil {
+			return written, fmt.Errorf("failed to load documents: %w", err)
+		}
+
+		for _, doc := range batch {
+			if err := rows.WriteRow(doc); err != nil {
+				return written, fmt.Errorf("failed to write export row: %w", err)
+			}
+			written++
+		}
+
+		if len(batch) < exportBatchSize {
+			break
+		}
+		lastID = batch[len(batch)-1].ID
+	}
+
+	if err := rows.Close(); err != nil {
+		return written, fmt.Errorf("failed to finish export: %w", err)
+	}
+	return written, nil
+}
+
+// applyDocumentExportFilter adds the conditions of filter to a document query
+func applyDocumentExportFilter(query *bun.SelectQuery, filter DocumentExportFilter) {
+	if !filter.StartDate.IsZero() {
+		query.Where("issue_date >= ?", filter.StartDate)
+	}
+	if !filter.EndDate.IsZero() {
+		query.Where("issue_date < ?", filter.EndDate.AddDate(0, 0, 1))
+	}
+	if filter.Competence != "" {
+		query.Where("competence_month = ?", filter.Competence)
+	}
+}
+
+// exportRecord renders a document as the text cells of an export row
+func exportRecord(doc models.Document) []string {
+	issueDate := ""
+	if !doc.IssueDate.IsZero() {
+		issueDate = doc.IssueDate.Format("2006-01-02")
+	}
+	competence := doc.Competence
+	if normalized, ok := NormalizeCompetence(doc.Competence); ok {
+		competence = normalized
+	}
+
+	return []string{
+		strconv.FormatInt(doc.ID, 10),
+		doc.Number,
+		issueDate,
+		competence,
+		doc.ProviderCNPJ,
+		doc.ProviderName,
+		doc.TakerCNPJ,
+		doc.TakerName,
+		strconv.FormatFloat(doc.ServiceValue, 'f', 2, 64),
+		strconv.FormatFloat(doc.IssValue, 'f', 2, 64),
+		doc.Status,
+		strconv.FormatBool(doc.IsCancelled),
+	}
+}
+
+// csvExportWriter writes an export as CSV
+type csvExportWriter struct {
+	writer *csv.Writer
+}
+
+func newCSVExportWriter(w io.Writer) (*csvExportWriter, error) {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(exportColumns); err != nil {
+		return nil, err
+	}
+	return &csvExportWriter{writer: writer}, nil
+}
+
+func (e *csvExportWriter) WriteRow(doc models.Document) error {
+	return e.writer.Write(CSVSafeRecord(exportRecord(doc)))
+}
+
+// CSVSafeCell neutralizes a cell a spreadsheet would read as a formula (starting with =,
+// +, -, @, tab or carriage return) by prefixing it with a quote. Numbers are kept as is.
+func CSVSafeCell(value string) string {
+	if value == "" || !strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return value
+	}
+	if _, err := strconv.ParseFloat(value, 64); err == nil {
+		return value
+	}
+	return "'" + value
+}
+
+// CSVSafeRecord applies CSVSafeCell to every cell of a CSV row
+func CSVSafeRecord(record []string) []string {
+	for i, value := range record {
+		record[i] = CSVSafeCell(value)
+	}
+	return record
+}
+
+func (e *csvExportWriter) Close() error {
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+// xlsxExportWriter writes an export as a single sheet XLSX workbook. The sheet is
+// streamed into the ZIP container, so rows are never held in memory; strings are
+// written inline, which spares the shared strings table.
+type xlsxExportWriter struct {
+	archive *zip.Writer
+	sheet   io.Writer
+	row     int
+}
+
+// xlsxNumericColumns are the export columns written as number cells
+var xlsxNumericColumns = map[int]bool{0: true, 8: true, 9: true}
+
+const xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>
+<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>
+</Types>`
+
+const xlsxRootRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>
+</Relationships>`
+
+const xlsxWorkbook = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="NFSe" sheetId="1" r:id="rId1"/></sheets>
+</workbook>`
+
+const xlsxWorkbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
+</Relationships>`
+
+func newXLSXExportWriter(w io.Writer) (*xlsxExportWriter, error) {
+	archive := zip.NewWriter(w)
+	parts := []struct{ name, content string }{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRootRels},
+		{"xl/workbook.xml", xlsxWorkbook},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+	}
+	for _, part := range parts {
+		entry, err := archive.Create(part.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(entry, part.content); err != nil {
+			return nil, err
+		}
+	}
+
+	sheet, err := archive.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(sheet, `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>`+
+		`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`); err != nil {
+		return nil, err
+	}
+
+	e := &xlsxExportWriter{archive: archive, sheet: sheet}
+	if err := e.writeCells(exportColumns, nil); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (e *xlsxExportWriter) WriteRow(doc models.Document) error {
+	return e.writeCells(exportRecord(doc), xlsxNumericColumns)
+}
+
+// writeCells writes one sheet row; columns in numeric become number cells
+func (e *xlsxExportWriter) writeCells(values []string, numeric map[int]bool) error {
+	e.row++
+	if _, err := fmt.Fprintf(e.sheet, `<row r="%d">`, e.row); err != nil {
+		return err
+	}
+	for i, value := range values {
+		var err error
+		if numeric[i] {
+			_, err = fmt.Fprintf(e.sheet, `<c t="n"><v>%s</v></c>`, value)
+		} else {
+			if _, err = io.WriteString(e.sheet, `<c t="inlineStr"><is><t>`); err == nil {
+				if err = xml.EscapeText(e.sheet, []byte(value)); err == nil {
+					_, err = io.WriteString(e.sheet, `</t></is></c>`)
+				}
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(e.sheet, `</row>`)
+	return err
+}
+
+func (e *xlsxExportWriter) Close() error {
+	if _, err := io.WriteString(e.sheet, `</sheetData></worksheet>`); err != nil {
+		return err
+	}
+	return e.archive.Close()
+}
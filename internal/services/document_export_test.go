@@ -0,0 +1,28 @@
+package services
+
+import "testing"
+
+func TestCSVSafeCell(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"plain text", "Empresa LTDA", "Empresa LTDA"},
+		{"formula", "=HYPERLINK(\"http://x\")", "'=HYPERLINK(\"http://x\")"},
+		{"plus", "+55 11 9999", "'+55 11 9999"},
+		{"at sign", "@SUM(A1)", "'@SUM(A1)"},
+		{"dash text", "-cmd", "'-cmd"},
+		{"tab", "\tvalue", "'\tvalue"},
+		{"negative number", "-12.50", "-12.50"},
+		{"empty", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CSVSafeCell(tt.value); got != tt.want {
+				t.Errorf("CSVSafeCell(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
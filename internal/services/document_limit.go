@@ -0,0 +1,123 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/zoomxml/internal/logger"
+	"github.com/zoomxml/internal/models"
+	"github.com/zoomxml/internal/repository"
+)
+
+// ErrDocumentLimitReached is returned for notes rejected because the company reached its
+// document limit under the "reject" policy
+var ErrDocumentLimitReached = errors.New("company document limit reached")
+
+// DocumentUsage is the number of live NFSe documents of a company against its limit
+type DocumentUsage struct {
+	Documents int    `json:"documents"`
+	Limit     int    `json:"limit"` // 0 = no limit
+	Policy    string `json:"policy,omitempty"`
+	Remaining int    `json:"remaining"` // room left below the limit; 0 without a limit
+}
+
+// documentLimit is the document cap of a company at ingest time
+type documentLimit struct {
+	companyID int64
+	limit     int
+	policy    string
+	legalHold bool
+	documents repository.DocumentRepository // counts the company documents for admit
+}
+
+// evicts reports whether notes past the limit make room by evicting the oldest ones.
+// Companies under legal hold never lose documents, so they are treated as "reject".
+func (l documentLimit) evicts() bool {
+	return l.policy == models.DocumentLimitPolicyEvictOldest && !l.legalHold
+}
+
+// companyDocumentLimit loads the document cap of a company. Lookup failures fall back to
+// no limit, which never drops documents.
+func companyDocumentLimit(ctx context.Context, companies repository.CompanyRepository, documents repository.DocumentRepository, companyID int64) documentLimit {
+	company, err := companies.FindCompany(ctx, companyID, "document_limit", "document_limit_policy", "legal_hold")
+	if err != nil {
+		logger.WarnWithFields("Failed to load company document limit, ingesting without it", map[string]any{
+			"operation":  "document_limit",
+			"company_id": companyID,
+			"error":      err.Error(),
+		})
+		return documentLimit{companyID: companyID, documents: documents}
+	}
+
+	return documentLimit{
+		companyID: companyID,
+		limit:     company.DocumentLimit,
+		policy:    company.DocumentLimitPolicy,
+		legalHold: company.LegalHold,
+		documents: documents,
+	}
+}
+
+// admit returns how many of n new notes may be stored. Under "reject" that is the room
+// left below the limit. Under "evict_oldest" every note up to the limit is admitted and
+// the insert makes room once they are stored. The repository checks the limit again
+// when inserting, since concurrent ingests may fill the room left here.
+func (l documentLimit) admit(ctx context.Context, n int) int {
+	if l.limit <= 0 || n == 0 {
+		return n
+	}
+	if l.evicts() {
+		return min(n, l.limit)
+	}
+
+	count, err := l.documents.CountDocuments(ctx, l.companyID)
+	if err != nil {
+		logger.WarnWithFields("Failed to check company document limit, ingesting without it", map[string]any{
+			"operation":  "document_limit",
+			"company_id": l.companyID,
+			"error":      err.Error(),
+		})
+		return n
+	}
+
+	return max(min(n, l.limit-count), 0)
+}
+
+// forInsert is the limit the repository enforces while inserting, with the company row
+// locked; admit only spares storing XMLs that would be rejected anyway
+func (l documentLimit) forInsert() repository.DocumentLimit {
+	return repository.DocumentLimit{Limit: l.limit, Evict: l.evicts()}
+}
+
+// handleEvictions reports documents evicted to keep a company within its limit and
+// removes their DANFSE PDFs
+func (l documentLimit) handleEvictions(ctx context.Context, inserted repository.InsertResult) {
+	if inserted.Evicted == 0 {
+		return
+	}
+	logger.InfoWithFields("Evicted oldest documents over the company limit", map[string]any{
+		"operation":  "document_limit",
+		"company_id": l.companyID,
+		"limit":      l.limit,
+		"evicted":    inserted.Evicted,
+	})
+	for _, key := range inserted.EvictedKeys {
+		deleteDANFSEs(ctx, key, "")
+	}
+}
+
+// GetDocumentUsage returns the NFSe documents of a company against its document limit
+func GetDocumentUsage(ctx context.Context, company *models.Company) (DocumentUsage, error) {
+	count, err := repository.NewDocumentRepository().CountDocuments(ctx, company.ID)
+	if err != nil {
+		return DocumentUsage{}, fmt.Errorf("failed to count documents: %w", err)
+	}
+
+	usage := DocumentUsage{Documents: count, Limit: company.DocumentLimit}
+	if company.DocumentLimit > 0 {
+		usage.Policy = company.DocumentLimitPolicy
+		usage.Remaining = max(company.DocumentLimit-count, 0)
+	}
+	return usage, nil
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/database/databasetest"
+	"github.com/zoomxml/internal/models"
+	"github.com/zoomxml/internal/repository"
+	"github.com/zoomxml/internal/repository/repositorytest"
+)
+
+func TestDocumentLimitAdmit(t *testing.T) {
+	documents := &repositorytest.DocumentRepository{}
+	for range 3 {
+		documents.Add(&models.Document{CompanyID: 1, Type: models.DocumentTypeNFSe})
+	}
+
+	tests := []struct {
+		name      string
+		limit     int
+		policy    string
+		legalHold bool
+		n         int
+		want      int
+	}{
+		{"no limit", 0, models.DocumentLimitPolicyReject, false, 10, 10},
+		{"reject with room", 5, models.DocumentLimitPolicyReject, false, 10, 2},
+		{"reject when full", 3, models.DocumentLimitPolicyReject, false, 10, 0},
+		{"reject past the limit", 2, models.DocumentLimitPolicyReject, false, 10, 0},
+		{"evict oldest admits up to the limit", 4, models.DocumentLimitPolicyEvictOldest, false, 10, 4},
+		{"legal hold rejects instead of evicting", 4, models.DocumentLimitPolicyEvictOldest, true, 10, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limit := documentLimit{companyID: 1, limit: tt.limit, policy: tt.policy, legalHold: tt.legalHold, documents: documents}
+			if got := limit.admit(context.Background(), tt.n); got != tt.want {
+				t.Errorf("admit(%d) = %d, want %d", tt.n, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInsertDocumentsWithLimit(t *testing.T) {
+	databasetest.Require(t)
+	ctx := context.Background()
+	oldest := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	backDated := oldest.AddDate(-1, 0, 0)
+
+	tests := []struct {
+		name         string
+		limit        repository.DocumentLimit
+		legalHold    bool
+		issueDate    time.Time // of the new documents; now when zero
+		wantInserted int
+		wantEvicted  []string
+		wantLive     int
+	}{
+		{"reject on cap", repository.DocumentLimit{Limit: 3}, false, time.Time{}, 1, nil, 3},
+		{"evict oldest", repository.DocumentLimit{Limit: 2, Evict: true}, false, time.Time{}, 2, []string{"limit/1.xml", "limit/2.xml"}, 2},
+		{"evict skips legal hold", repository.DocumentLimit{Limit: 3, Evict: true}, true, time.Time{}, 2, []string{"limit/2.xml"}, 3},
+		{"evict keeps back-dated new documents", repository.DocumentLimit{Limit: 3, Evict: true}, false, backDated, 2, []string{"limit/1.xml"}, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			company := databasetest.CreateCompany(t, nil)
+			t.Cleanup(func() {
+				database.DB.NewDelete().Model((*models.Document)(nil)).Where("company_id = ?", company.ID).ForceDelete().Exec(ctx)
+			})
+			for i, key := range []string{"limit/1.xml", "limit/2.xml"} {
+				databasetest.CreateDocument(t, &models.Document{
+					CompanyID:  company.ID,
+					StorageKey: key,
+					IssueDate:  oldest.AddDate(0, i, 0),
+					LegalHold:  tt.legalHold && i == 0,
+				})
+			}
+
+			issueDate := tt.issueDate
+			if issueDate.IsZero() {
+				issueDate = time.Now()
+			}
+			documents := []*models.Document{
+				{CompanyID: company.ID, Type: models.DocumentTypeNFSe, StorageKey: "limit/3.xml", IssueDate: issueDate},
+				{CompanyID: company.ID, Type: models.DocumentTypeNFSe, StorageKey: "limit/4.xml", IssueDate: issueDate.Add(time.Hour)},
+			}
+			result, err := repository.NewDocumentRepository().InsertDocuments(ctx, documents, tt.limit)
+			if err != nil {
+				t.Fatalf("InsertDocuments() error = %v", err)
+			}
+
+			if result.Inserted != tt.wantInserted {
+				t.Errorf("InsertDocuments() inserted = %d, want %d", result.Inserted, tt.wantInserted)
+			}
+			slices.Sort(result.EvictedKeys)
+			if result.Evicted != len(tt.wantEvicted) || !slices.Equal(result.EvictedKeys, tt.wantEvicted) {
+				t.Errorf("InsertDocuments() evicted = %d %v, want %v", result.Evicted, result.EvictedKeys, tt.wantEvicted)
+			}
+
+			// Documents reported as inserted are stored, never evicted by the same insert
+			for _, document := range documents[:result.Inserted] {
+				live, err := database.DB.NewSelect().Model((*models.Document)(nil)).Where("id = ?", document.ID).Exists(ctx)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !live {
+					t.Errorf("inserted document %s was evicted", document.StorageKey)
+				}
+			}
+
+			usage, err := GetDocumentUsage(ctx, company)
+			if err != nil {
+				t.Fatalf("GetDocumentUsage() error = %v", err)
+			}
+			if usage.Documents != tt.wantLive {
+				t.Errorf("GetDocumentUsage() documents = %d, want %d", usage.Documents, tt.wantLive)
+			}
+		})
+	}
+}
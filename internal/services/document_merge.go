@@ -0,0 +1,175 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/bun"
+	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/logger"
+	"github.com/zoomxml/internal/models"
+	"github.com/zoomxml/internal/storage"
+)
+
+// MergedDuplicateGroup describes one set of duplicate documents and which row was kept
+type MergedDuplicateGroup struct {
+	KeptID     int64   `json:"kept_id"`
+	RemovedIDs []int64 `json:"removed_ids"`
+	MatchedBy  string  `json:"matched_by"` // verification_code or provider_number
+}
+
+// DuplicateMergeReport is the outcome of merging pre-existing duplicates of a company
+type DuplicateMergeReport struct {
+	CompanyID        int64                  `json:"company_id"`
+	DryRun           bool                   `json:"dry_run"`
+	Groups           []MergedDuplicateGroup `json:"groups"`
+	RemovedDocuments int                    `json:"removed_documents"`
+	RemovedObjects   []string               `json:"removed_objects"`
+}
+
+// MergeDuplicateDocuments finds NFSe documents of a company that are duplicates by
+// verification code or by provider CNPJ + number, keeps the oldest row of each group
+// and soft-deletes the others. With removeObjects, stored XML objects no longer
+// referenced by any remaining document are deleted too. dryRun only reports.
+func MergeDuplicateDocuments(ctx context.Context, companyID int64, dryRun, removeObjects bool) (*DuplicateMergeReport, error) {
+	documents := []models.Document{}
+	err := database.DB.NewSelect().
+		Model(&documents).
+		Column("id", "verification_code", "provider_cnpj", "number", "storage_key").
+		Where("company_id = ? AND type = 'nfse'", companyID).
+		Order("id ASC").
+		Scan(ctx)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to load documents: %w", err)
+	}
+
+	report := &DuplicateMergeReport{
+		CompanyID:      companyID,
+		DryRun:         dryRun,
+		Groups:         []MergedDuplicateGroup{},
+		RemovedObjects: []string{},
+	}
+
+	groups := groupDuplicateDocuments(documents)
+	if len(groups) == 0 {
+		return report, nil
+	}
+
+	removedIDs := []int64{}
+	removed := make(map[int64]bool)
+	for _, group := range groups {
+		report.Groups = append(report.Groups, group)
+		removedIDs = append(removedIDs, group.RemovedIDs...)
+		for _, id := range group.RemovedIDs {
+			removed[id] = true
+		}
+	}
+	report.RemovedDocuments = len(removedIDs)
+
+	// Objects referenced only by removed rows become orphans
+	if removeObjects {
+		keptKeys := make(map[string]bool)
+		for _, doc := range documents {
+			if !removed[doc.ID] && doc.StorageKey != "" {
+				keptKeys[doc.StorageKey] = true
+			}
+		}
+		seen := make(map[string]bool)
+		for _, doc := range documents {
+			if removed[doc.ID] && doc.StorageKey != "" && !keptKeys[doc.StorageKey] && !seen[doc.StorageKey] {
+				seen[doc.StorageKey] = true
+				report.RemovedObjects = append(report.RemovedObjects, doc.StorageKey)
+			}
+		}
+	}
+
+	if dryRun {
+		return report, nil
+	}
+
+	_, err = database.DB.NewDelete().
+		Model((*models.Document)(nil)).
+		Where("company_id = ?", companyID).
+		Where("id IN (?)", bun.In(removedIDs)).
+		Exec(ctx)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to soft-delete duplicates: %w", err)
+	}
+
+	for _, key := range report.RemovedObjects {
+		if err := storage.Storage.DeleteFile(ctx, nfseBucket, key); err != nil {
+			logger.ErrorWithFields("Failed to delete orphaned document object", err, map[string]any{
+				"operation":  "merge_duplicates",
+				"company_id": companyID,
+				"key":        key,
+			})
+		}
+		deleteDANFSEs(ctx, key, "")
+	}
+
+	logger.InfoWithFields("Merged duplicate NFSe documents", map[string]any{
+		"operation":         "merge_duplicates",
+		"company_id":        companyID,
+		"groups":            len(report.Groups),
+		"removed_documents": report.RemovedDocuments,
+		"removed_objects":   len(report.RemovedObjects),
+	})
+
+	return report, nil
+}
+
+// groupDuplicateDocuments groups documents (ordered by ID) that share a verification code
+// or a provider CNPJ + number. The first document of each group is the canonical one.
+func groupDuplicateDocuments(documents []models.Document) []MergedDuplicateGroup {
+	canonicalByCode := make(map[string]int)
+	canonicalByNumber := make(map[string]int)
+	groupIndex := make(map[int]int) // canonical document index -> group index
+	groups := []MergedDuplicateGroup{}
+
+	for i, doc := range documents {
+		numberKey := ""
+		if doc.ProviderCNPJ != "" && doc.Number != "" {
+			numberKey = doc.ProviderCNPJ + "|" + doc.Number
+		}
+
+		canonical, matchedBy := -1, ""
+		if idx, ok := canonicalByCode[doc.VerificationCode]; ok && doc.VerificationCode != "" {
+			canonical, matchedBy = idx, "verification_code"
+		} else if idx, ok := canonicalByNumber[numberKey]; ok && numberKey != "" {
+			canonical, matchedBy = idx, "provider_number"
+		}
+
+		if canonical < 0 {
+			if doc.VerificationCode != "" {
+				canonicalByCode[doc.VerificationCode] = i
+			}
+			if numberKey != "" {
+				canonicalByNumber[numberKey] = i
+			}
+			continue
+		}
+
+		// Also index the duplicate's other key so later rows match the same canonical
+		if _, ok := canonicalByCode[doc.VerificationCode]; !ok && doc.VerificationCode != "" {
+			canonicalByCode[doc.VerificationCode] = canonical
+		}
+		if _, ok := canonicalByNumber[numberKey]; !ok && numberKey != "" {
+			canonicalByNumber[numberKey] = canonical
+		}
+
+		g, ok := groupIndex[canonical]
+		if !ok {
+			groups = append(groups, MergedDuplicateGroup{
+				KeptID:    documents[canonical].ID,
+				MatchedBy: matchedBy,
+			})
+			g = len(groups) - 1
+			groupIndex[canonical] = g
+		}
+		groups[g].RemovedIDs = append(groups[g].RemovedIDs, doc.ID)
+	}
+
+	return groups
+}
@@ -0,0 +1,133 @@
+package services
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/database/databasetest"
+	"github.com/zoomxml/internal/models"
+)
+
+func TestGroupDuplicateDocuments(t *testing.T) {
+	tests := []struct {
+		name      string
+		documents []models.Document
+		want      []MergedDuplicateGroup
+	}{
+		{
+			name:      "no duplicates",
+			documents: []models.Document{{ID: 1, VerificationCode: "AAA"}, {ID: 2, VerificationCode: "BBB"}},
+			want:      []MergedDuplicateGroup{},
+		},
+		{
+			name:      "same verification code",
+			documents: []models.Document{{ID: 1, VerificationCode: "AAA"}, {ID: 2, VerificationCode: "AAA"}, {ID: 3, VerificationCode: "AAA"}},
+			want:      []MergedDuplicateGroup{{KeptID: 1, RemovedIDs: []int64{2, 3}, MatchedBy: "verification_code"}},
+		},
+		{
+			name:      "same provider and number",
+			documents: []models.Document{{ID: 1, ProviderCNPJ: "P", Number: "5"}, {ID: 2, ProviderCNPJ: "P", Number: "5", VerificationCode: "X"}, {ID: 3, ProviderCNPJ: "Q", Number: "5"}},
+			want:      []MergedDuplicateGroup{{KeptID: 1, RemovedIDs: []int64{2}, MatchedBy: "provider_number"}},
+		},
+		{
+			name: "chained keys join the same group",
+			documents: []models.Document{
+				{ID: 1, VerificationCode: "AAA"},
+				{ID: 2, VerificationCode: "AAA", ProviderCNPJ: "P", Number: "5"},
+				{ID: 3, ProviderCNPJ: "P", Number: "5"},
+			},
+			want: []MergedDuplicateGroup{{KeptID: 1, RemovedIDs: []int64{2, 3}, MatchedBy: "verification_code"}},
+		},
+		{
+			name:      "empty keys never match",
+			documents: []models.Document{{ID: 1}, {ID: 2}, {ID: 3, Number: "5"}, {ID: 4, Number: "5"}},
+			want:      []MergedDuplicateGroup{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := groupDuplicateDocuments(tt.documents)
+			if len(got) != len(tt.want) {
+				t.Fatalf("groupDuplicateDocuments() = %+v, want %+v", got, tt.want)
+			}
+			for i := range got {
+				if got[i].KeptID != tt.want[i].KeptID || got[i].MatchedBy != tt.want[i].MatchedBy || !slices.Equal(got[i].RemovedIDs, tt.want[i].RemovedIDs) {
+					t.Errorf("group %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestMergeDuplicateDocuments(t *testing.T) {
+	databasetest.Require(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name          string
+		dryRun        bool
+		removeObjects bool
+		wantReported  []string // objects listed for removal
+		wantObjects   []string // objects left in storage
+	}{
+		{"dry run", true, false, []string{}, []string{"k1", "k2", "k3", "k4"}},
+		{"dry run with remove objects", true, true, []string{"k2", "k3"}, []string{"k1", "k2", "k3", "k4"}},
+		{"merge", false, false, []string{}, []string{"k1", "k2", "k3", "k4"}},
+		{"merge with remove objects", false, true, []string{"k2", "k3"}, []string{"k1", "k4"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			memory := useMemoryStorage(t)
+			company := databasetest.CreateCompany(t, nil)
+			seeded := []*models.Document{
+				{CompanyID: company.ID, Number: "1", VerificationCode: "AAA", StorageKey: "k1"},
+				{CompanyID: company.ID, Number: "2", VerificationCode: "AAA", StorageKey: "k2"}, // duplicate of 0 by code
+				{CompanyID: company.ID, Number: "5", ProviderCNPJ: "11111111000111", StorageKey: "k1"},
+				{CompanyID: company.ID, Number: "5", ProviderCNPJ: "11111111000111", VerificationCode: "DDD", StorageKey: "k3"}, // duplicate of 2 by number
+				{CompanyID: company.ID, Number: "9", VerificationCode: "EEE", StorageKey: "k4"},
+			}
+			for _, document := range seeded {
+				databasetest.CreateDocument(t, document)
+				memory.objects[document.StorageKey] = []byte("<xml/>")
+			}
+
+			report, err := MergeDuplicateDocuments(ctx, company.ID, tt.dryRun, tt.removeObjects)
+			if err != nil {
+				t.Fatalf("MergeDuplicateDocuments() error = %v", err)
+			}
+
+			if report.RemovedDocuments != 2 || len(report.Groups) != 2 {
+				t.Errorf("report = %d removed in %d groups, want 2 in 2", report.RemovedDocuments, len(report.Groups))
+			}
+			reported := slices.Sorted(slices.Values(report.RemovedObjects))
+			if !slices.Equal(reported, tt.wantReported) {
+				t.Errorf("removed objects = %v, want %v", reported, tt.wantReported)
+			}
+
+			// Exactly one canonical row of each group remains unless it is a dry run
+			for i, document := range seeded {
+				stored := &models.Document{}
+				if err := database.DB.NewSelect().Model(stored).WhereAllWithDeleted().Where("id = ?", document.ID).Scan(ctx); err != nil {
+					t.Fatal(err)
+				}
+				wantDeleted := !tt.dryRun && (i == 1 || i == 3)
+				if deleted := !stored.DeletedAt.IsZero(); deleted != wantDeleted {
+					t.Errorf("document %d deleted = %v, want %v", i, deleted, wantDeleted)
+				}
+			}
+
+			objects := []string{}
+			for key := range memory.objects {
+				objects = append(objects, key)
+			}
+			slices.Sort(objects)
+			if !slices.Equal(objects, tt.wantObjects) {
+				t.Errorf("objects = %v, want %v", objects, tt.wantObjects)
+			}
+		})
+	}
+}
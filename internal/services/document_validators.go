@@ -0,0 +1,163 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/zoomxml/internal/models"
+)
+
+// ErrDocumentRuleViolation is returned by Validate when a note breaks a company validation rule
+var ErrDocumentRuleViolation = errors.New("NFSe violates a company validation rule")
+
+// DocumentValidator checks a parsed note against a business rule. It runs after the
+// built-in ingest policies, and a non-nil error rejects the note.
+type DocumentValidator interface {
+	Validate(parsedData *ParsedNFSeData) error
+}
+
+// DocumentValidatorFactory builds a validator from the JSON params of a company rule
+type DocumentValidatorFactory func(params json.RawMessage) (DocumentValidator, error)
+
+// NamedDocumentValidator is a validator built from a company rule, named after its validator
+type NamedDocumentValidator struct {
+	DocumentValidator
+	Name string
+}
+
+var (
+	documentValidatorsMu sync.RWMutex
+	documentValidators   = map[string]DocumentValidatorFactory{
+		"min_service_value": newMinServiceValueValidator,
+		"allowed_cnae":      newAllowedCNAEValidator,
+	}
+)
+
+// RegisterDocumentValidator makes a validator available to company rules under name,
+// replacing any validator already registered with it
+func RegisterDocumentValidator(name string, factory DocumentValidatorFactory) {
+	documentValidatorsMu.Lock()
+	defer documentValidatorsMu.Unlock()
+	documentValidators[name] = factory
+}
+
+// DocumentValidatorNames returns the registered validator names, sorted
+func DocumentValidatorNames() []string {
+	documentValidatorsMu.RLock()
+	defer documentValidatorsMu.RUnlock()
+
+	names := make([]string, 0, len(documentValidators))
+	for name := range documentValidators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// BuildDocumentValidator builds the validator of a company rule
+func BuildDocumentValidator(rule models.ValidationRule) (NamedDocumentValidator, error) {
+	documentValidatorsMu.RLock()
+	factory, ok := documentValidators[rule.Validator]
+	documentValidatorsMu.RUnlock()
+
+	if !ok {
+		return NamedDocumentValidator{}, fmt.Errorf("unknown validator %q", rule.Validator)
+	}
+
+	validator, err := factory(rule.Params)
+	if err != nil {
+		return NamedDocumentValidator{}, fmt.Errorf("invalid params for validator %q: %w", rule.Validator, err)
+	}
+
+	return NamedDocumentValidator{DocumentValidator: validator, Name: rule.Validator}, nil
+}
+
+// BuildDocumentValidators builds every rule, failing on the first invalid one. It is
+// used to check rules before saving them on a company.
+func BuildDocumentValidators(rules []models.ValidationRule) ([]NamedDocumentValidator, error) {
+	validators := make([]NamedDocumentValidator, 0, len(rules))
+	for _, rule := range rules {
+		validator, err := BuildDocumentValidator(rule)
+		if err != nil {
+			return nil, err
+		}
+		validators = append(validators, validator)
+	}
+	return validators, nil
+}
+
+// minServiceValueValidator rejects notes below a minimum service value.
+// Params: {"min": 100.0}
+type minServiceValueValidator struct {
+	Min float64 `json:"min"`
+}
+
+func newMinServiceValueValidator(params json.RawMessage) (DocumentValidator, error) {
+	v := &minServiceValueValidator{}
+	if err := json.Unmarshal(params, v); err != nil {
+		return nil, err
+	}
+	if v.Min <= 0 {
+		return nil, errors.New("min must be greater than zero")
+	}
+	return v, nil
+}
+
+func (v *minServiceValueValidator) Validate(parsedData *ParsedNFSeData) error {
+	if parsedData.ServiceValue < v.Min {
+		return fmt.Errorf("service value %.2f is below the minimum of %.2f", parsedData.ServiceValue, v.Min)
+	}
+	return nil
+}
+
+// allowedCNAEValidator rejects notes whose CNAE code is not in a list. Notes without a
+// CNAE code are rejected too, since the rule cannot be checked.
+// Params: {"codes": ["6201501", "6202300"]}
+type allowedCNAEValidator struct {
+	codes map[string]bool
+}
+
+func newAllowedCNAEValidator(params json.RawMessage) (DocumentValidator, error) {
+	var p struct {
+		Codes []string `json:"codes"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+
+	v := &allowedCNAEValidator{codes: make(map[string]bool, len(p.Codes))}
+	for _, code := range p.Codes {
+		if code = normalizeCNAE(code); code != "" {
+			v.codes[code] = true
+		}
+	}
+	if len(v.codes) == 0 {
+		return nil, errors.New("codes must list at least one CNAE code")
+	}
+	return v, nil
+}
+
+func (v *allowedCNAEValidator) Validate(parsedData *ParsedNFSeData) error {
+	code := normalizeCNAE(parsedData.CNAECode)
+	if code == "" {
+		return errors.New("note has no CNAE code")
+	}
+	if !v.codes[code] {
+		return fmt.Errorf("CNAE code %s is not allowed", parsedData.CNAECode)
+	}
+	return nil
+}
+
+// normalizeCNAE keeps only the digits of a CNAE code, so "6201-5/01" matches "6201501"
+func normalizeCNAE(code string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, code)
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/zoomxml/internal/models"
+	"github.com/zoomxml/internal/repository/repositorytest"
+)
+
+func TestBuildDocumentValidator(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    models.ValidationRule
+		wantErr bool
+	}{
+		{"minimum value", models.ValidationRule{Validator: "min_service_value", Params: json.RawMessage(`{"min":100}`)}, false},
+		{"allowed CNAE", models.ValidationRule{Validator: "allowed_cnae", Params: json.RawMessage(`{"codes":["6201-5/01"]}`)}, false},
+		{"unknown validator", models.ValidationRule{Validator: "max_service_value", Params: json.RawMessage(`{}`)}, true},
+		{"minimum not positive", models.ValidationRule{Validator: "min_service_value", Params: json.RawMessage(`{"min":0}`)}, true},
+		{"no CNAE codes", models.ValidationRule{Validator: "allowed_cnae", Params: json.RawMessage(`{"codes":["--"]}`)}, true},
+		{"params are not JSON", models.ValidationRule{Validator: "min_service_value", Params: json.RawMessage(`min=100`)}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validator, err := BuildDocumentValidator(tt.rule)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("BuildDocumentValidator() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && validator.Name != tt.rule.Validator {
+				t.Errorf("validator name = %q, want %q", validator.Name, tt.rule.Validator)
+			}
+		})
+	}
+}
+
+func TestBuiltInDocumentValidators(t *testing.T) {
+	minValue, _ := BuildDocumentValidator(models.ValidationRule{Validator: "min_service_value", Params: json.RawMessage(`{"min":100}`)})
+	allowedCNAE, _ := BuildDocumentValidator(models.ValidationRule{Validator: "allowed_cnae", Params: json.RawMessage(`{"codes":["6201-5/01","6202300"]}`)})
+
+	tests := []struct {
+		name      string
+		validator NamedDocumentValidator
+		data      ParsedNFSeData
+		wantErr   bool
+	}{
+		{"value at the minimum", minValue, ParsedNFSeData{ServiceValue: 100}, false},
+		{"value below the minimum", minValue, ParsedNFSeData{ServiceValue: 99.99}, true},
+		{"allowed CNAE", allowedCNAE, ParsedNFSeData{CNAECode: "6201501"}, false},
+		{"allowed CNAE with punctuation", allowedCNAE, ParsedNFSeData{CNAECode: "6202-3/00"}, false},
+		{"other CNAE", allowedCNAE, ParsedNFSeData{CNAECode: "4751201"}, true},
+		{"no CNAE", allowedCNAE, ParsedNFSeData{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.validator.Validate(&tt.data); (err != nil) != tt.wantErr {
+				t.Errorf("%s.Validate() error = %v, wantErr %v", tt.validator.Name, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// blockedTakerValidator is a custom rule rejecting the notes of one taker
+type blockedTakerValidator struct {
+	CNPJ string `json:"cnpj"`
+}
+
+func (v *blockedTakerValidator) Validate(parsedData *ParsedNFSeData) error {
+	if NormalizeCNPJ(parsedData.TakerCNPJ) == v.CNPJ {
+		return fmt.Errorf("taker %s is blocked", v.CNPJ)
+	}
+	return nil
+}
+
+func TestCustomDocumentValidatorRejectsNote(t *testing.T) {
+	const name, companyCNPJ, blockedCNPJ = "test_blocked_taker", "12345678000190", "98765432000110"
+	RegisterDocumentValidator(name, func(params json.RawMessage) (DocumentValidator, error) {
+		v := &blockedTakerValidator{}
+		return v, json.Unmarshal(params, v)
+	})
+	t.Cleanup(func() {
+		documentValidatorsMu.Lock()
+		delete(documentValidators, name)
+		documentValidatorsMu.Unlock()
+	})
+	if !slices.Contains(DocumentValidatorNames(), name) {
+		t.Fatalf("DocumentValidatorNames() = %v, want %s registered", DocumentValidatorNames(), name)
+	}
+
+	useFakeIngest(t)
+	company := &models.Company{ID: 1, CNPJ: companyCNPJ, ValidationRules: []models.ValidationRule{
+		{Validator: name, Params: json.RawMessage(`{"cnpj":"` + blockedCNPJ + `"}`)},
+		{Validator: "min_service_value", Params: json.RawMessage(`{"min":50}`)},
+	}}
+	documents := &repositorytest.DocumentRepository{}
+	manager := NewNFSeXMLManagerWithRepositories(documents, &repositorytest.CompanyRepository{Companies: map[int64]*models.Company{company.ID: company}})
+
+	blocked := testNFSeXML("1", "AAA", companyCNPJ, blockedCNPJ, "100.00")
+	batch := []XMLDocument{
+		{FileName: "1.xml", Content: blocked},
+		{FileName: "2.xml", Content: testNFSeXML("2", "BBB", companyCNPJ, "11222333000181", "10.00")},
+		{FileName: "3.xml", Content: testNFSeXML("3", "CCC", companyCNPJ, "11222333000181", "100.00")},
+	}
+	result, err := manager.ProcessBatchXML(context.Background(), company.ID, BatchOptions{Source: ProcessingSourcePrefeituraAPI}, batch)
+	if err != nil {
+		t.Fatalf("ProcessBatchXML() error = %v", err)
+	}
+	// The custom rule, then the built-in one, reject the first two notes
+	for i, wantViolation := range []bool{true, true, false} {
+		if got := errors.Is(result.Results[i].Error, ErrDocumentRuleViolation); got != wantViolation {
+			t.Errorf("note %d error = %v, want a rule violation %v", i+1, result.Results[i].Error, wantViolation)
+		}
+	}
+	if len(documents.Documents) != 1 || documents.Documents[0].Number != "3" {
+		t.Errorf("stored %d documents, want only the note passing every rule", len(documents.Documents))
+	}
+
+	// Single uploads apply the same rules
+	single, err := manager.ProcessSingleXML(context.Background(), company.ID, blocked, "1.xml")
+	if err != nil || !errors.Is(single.Error, ErrDocumentRuleViolation) {
+		t.Errorf("ProcessSingleXML() = %v, %v, want a rule violation", single.Error, err)
+	}
+}
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/models"
+	"github.com/zoomxml/internal/storage"
+)
+
+var (
+	// ErrDocumentNotFound is returned when the company has no NFSe with the given ID
+	ErrDocumentNotFound = errors.New("document not found")
+	// ErrXMLNotStored is returned for documents of metadata-only companies
+	ErrXMLNotStored = errors.New("document XML is not stored")
+	// ErrObjectMissing is returned when the document row exists but its object does not
+	ErrObjectMissing = errors.New("stored XML object is missing")
+)
+
+// gzipMagic starts every gzip stream
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// decodeStoredXML returns the XML of a stored object, decompressing it when the object
+// was stored gzipped
+func decodeStoredXML(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, gzipMagic) {
+		return data, nil
+	}
+
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress stored XML: %w", err)
+	}
+	defer reader.Close()
+
+	return io.ReadAll(reader)
+}
+
+// ReadDocumentXML loads the stored XML of a document (NFSe, NF-e or CT-e) of a company
+// along with the file name it is served under
+func ReadDocumentXML(ctx context.Context, companyID, documentID int64) ([]byte, string, error) {
+	doc := models.Document{}
+	err := database.DB.NewSelect().
+		Model(&doc).
+		Column("id", "number", "storage_key").
+		Where("id = ? AND company_id = ?", documentID, companyID).
+		Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, "", ErrDocumentNotFound
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to load document: %w", err)
+	}
+
+	if doc.StorageKey == "" {
+		return nil, "", ErrXMLNotStored
+	}
+
+	data, err := storage.Storage.DownloadFile(ctx, nfseBucket, doc.StorageKey)
+	if err != nil {
+		// Tell a missing object apart from storage being unavailable
+		if exists, existsErr := storage.Storage.FileExists(ctx, nfseBucket, doc.StorageKey); existsErr == nil && !exists {
+			return nil, "", ErrObjectMissing
+		}
+		return nil, "", fmt.Errorf("failed to read stored XML: %w", err)
+	}
+
+	data, err = decodeStoredXML(data)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return data, archiveEntryName(doc), nil
+}
@@ -0,0 +1,41 @@
+package services
+
+import (
+	"bytes"
+	"compress/gzip"
+	"testing"
+)
+
+func TestDecodeStoredXML(t *testing.T) {
+	xml := []byte(`<?xml version="1.0"?><CompNfse><Numero>1</Numero></CompNfse>`)
+
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	writer.Write(xml)
+	writer.Close()
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    []byte
+		wantErr bool
+	}{
+		{"plain XML", xml, xml, false},
+		{"gzipped XML", compressed.Bytes(), xml, false},
+		{"empty", []byte{}, []byte{}, false},
+		{"truncated gzip header", gzipMagic, nil, true},
+		{"truncated gzip body", compressed.Bytes()[:compressed.Len()-8], nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeStoredXML(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decodeStoredXML() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !bytes.Equal(got, tt.want) {
+				t.Errorf("decodeStoredXML() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
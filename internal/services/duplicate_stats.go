@@ -0,0 +1,142 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/zoomxml/config"
+	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/models"
+)
+
+// DuplicateStatsWindow is the range of creation dates, both inclusive, covered by
+// duplicate statistics
+type DuplicateStatsWindow struct {
+	Start time.Time
+	End   time.Time
+}
+
+// DuplicateStatsWindowDays returns the window of the last days, today included
+func DuplicateStatsWindowDays(days int) DuplicateStatsWindow {
+	now := time.Now().UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	return DuplicateStatsWindow{Start: today.AddDate(0, 0, 1-days), End: today}
+}
+
+// key identifies the window in the statistics cache
+func (w DuplicateStatsWindow) key() string {
+	return w.Start.Format("2006-01-02") + "|" + w.End.Format("2006-01-02")
+}
+
+type duplicateStatsEntry struct {
+	stats     map[string]any
+	expiresAt time.Time
+}
+
+// duplicateStatsCache holds recent statistics per company and window. It is shared by
+// every manager so an ingest through any of them invalidates the company.
+var duplicateStatsCache = struct {
+	sync.Mutex
+	companies map[int64]map[string]duplicateStatsEntry
+}{companies: make(map[int64]map[string]duplicateStatsEntry)}
+
+// cachedDuplicateStatistics returns the cached statistics of a window, if still fresh
+func cachedDuplicateStatistics(companyID int64, key string) (map[string]any, bool) {
+	duplicateStatsCache.Lock()
+	defer duplicateStatsCache.Unlock()
+
+	entry, ok := duplicateStatsCache.companies[companyID][key]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return nil, false
+	}
+	return maps.Clone(entry.stats), true
+}
+
+// storeDuplicateStatistics caches the statistics of a window for ttl, dropping the
+// expired entries of the company
+func storeDuplicateStatistics(companyID int64, key string, stats map[string]any, ttl time.Duration) {
+	duplicateStatsCache.Lock()
+	defer duplicateStatsCache.Unlock()
+
+	now := time.Now()
+	entries := duplicateStatsCache.companies[companyID]
+	if entries == nil {
+		entries = make(map[string]duplicateStatsEntry)
+		duplicateStatsCache.companies[companyID] = entries
+	}
+	for k, entry := range entries {
+		if now.After(entry.expiresAt) {
+			delete(entries, k)
+		}
+	}
+	entries[key] = duplicateStatsEntry{stats: maps.Clone(stats), expiresAt: now.Add(ttl)}
+}
+
+// InvalidateDuplicateStatistics drops the cached statistics of a company, so the next
+// call recomputes them. It is called whenever documents of the company are ingested.
+func InvalidateDuplicateStatistics(companyID int64) {
+	duplicateStatsCache.Lock()
+	defer duplicateStatsCache.Unlock()
+	delete(duplicateStatsCache.companies, companyID)
+}
+
+// GetDuplicateStatisticsForWindow returns statistics about duplicate detection for the
+// documents created in a window. Results are cached per company and window for
+// NFSE_DUPLICATE_STATS_CACHE_TTL (0 disables the cache).
+func (d *NFSeDeduplicator) GetDuplicateStatisticsForWindow(ctx context.Context, companyID int64, window DuplicateStatsWindow) (map[string]any, error) {
+	ttl := config.Get().NFSeScheduler.DuplicateStatsCacheTTL
+	key := window.key()
+
+	if ttl > 0 {
+		if stats, ok := cachedDuplicateStatistics(companyID, key); ok {
+			return stats, nil
+		}
+	}
+
+	var stats struct {
+		TotalDocuments       int64 `bun:"total_documents"`
+		UniqueDocuments      int64 `bun:"unique_documents"`
+		CancelledDocuments   int64 `bun:"cancelled_documents"`
+		SubstitutedDocuments int64 `bun:"substituted_documents"`
+	}
+
+	err := database.DB.NewSelect().
+		Model((*models.Document)(nil)).
+		ColumnExpr("COUNT(*) as total_documents").
+		ColumnExpr("COUNT(DISTINCT verification_code) as unique_documents").
+		ColumnExpr("COUNT(*) FILTER (WHERE is_cancelled = true) as cancelled_documents").
+		ColumnExpr("COUNT(*) FILTER (WHERE is_substituted = true) as substituted_documents").
+		Where("company_id = ? AND type = 'nfse'", companyID).
+		Where("created_at >= ? AND created_at < ?", window.Start, window.End.AddDate(0, 0, 1)).
+		Scan(ctx, &stats)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get duplicate statistics: %v", err)
+	}
+
+	result := map[string]any{
+		"total_documents":       stats.TotalDocuments,
+		"unique_documents":      stats.UniqueDocuments,
+		"cancelled_documents":   stats.CancelledDocuments,
+		"substituted_documents": stats.SubstitutedDocuments,
+		"potential_duplicates":  stats.TotalDocuments - stats.UniqueDocuments,
+		"start_date":            window.Start.Format("2006-01-02"),
+		"end_date":              window.End.Format("2006-01-02"),
+		"period_days":           int(window.End.Sub(window.Start).Hours()/24) + 1,
+	}
+
+	if ttl > 0 {
+		storeDuplicateStatistics(companyID, key, result, ttl)
+	}
+
+	return result, nil
+}
+
+// GetDuplicateStatistics returns duplicate detection statistics for a company over a
+// window, through the statistics cache
+func (m *NFSeXMLManager) GetDuplicateStatistics(ctx context.Context, companyID int64, window DuplicateStatsWindow) (map[string]any, error) {
+	return m.deduplicator.GetDuplicateStatisticsForWindow(ctx, companyID, window)
+}
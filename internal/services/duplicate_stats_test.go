@@ -0,0 +1,147 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/zoomxml/config"
+	"github.com/zoomxml/internal/database/databasetest"
+	"github.com/zoomxml/internal/models"
+	"github.com/zoomxml/internal/repository/repositorytest"
+)
+
+// useDuplicateStatsCache sets the statistics cache TTL and empties the cache for the test
+func useDuplicateStatsCache(t *testing.T, ttl time.Duration) {
+	t.Helper()
+	cfg := &config.Get().NFSeScheduler
+	previous := cfg.DuplicateStatsCacheTTL
+	cfg.DuplicateStatsCacheTTL = ttl
+	reset := func() {
+		duplicateStatsCache.Lock()
+		duplicateStatsCache.companies = make(map[int64]map[string]duplicateStatsEntry)
+		duplicateStatsCache.Unlock()
+	}
+	reset()
+	t.Cleanup(func() {
+		cfg.DuplicateStatsCacheTTL = previous
+		reset()
+	})
+}
+
+// TestDuplicateStatisticsCache runs against a closed database, so a call answered
+// without error is a cache hit and a call that fails had to query
+func TestDuplicateStatisticsCache(t *testing.T) {
+	databasetest.UseClosed(t)
+	ctx := context.Background()
+	deduplicator := NewNFSeDeduplicator()
+	window := DuplicateStatsWindowDays(30)
+	cached := map[string]any{"total_documents": int64(7)}
+
+	tests := []struct {
+		name    string
+		ttl     time.Duration
+		prepare func()
+		wantHit bool
+	}{
+		{"fresh entry", time.Minute, func() { storeDuplicateStatistics(1, window.key(), cached, time.Minute) }, true},
+		{"cache disabled", 0, func() { storeDuplicateStatistics(1, window.key(), cached, time.Minute) }, false},
+		{"expired entry", time.Minute, func() { storeDuplicateStatistics(1, window.key(), cached, -time.Second) }, false},
+		{"other window", time.Minute, func() { storeDuplicateStatistics(1, DuplicateStatsWindowDays(7).key(), cached, time.Minute) }, false},
+		{"other company", time.Minute, func() { storeDuplicateStatistics(2, window.key(), cached, time.Minute) }, false},
+		{"company invalidated", time.Minute, func() {
+			storeDuplicateStatistics(1, window.key(), cached, time.Minute)
+			storeDuplicateStatistics(2, window.key(), cached, time.Minute)
+			InvalidateDuplicateStatistics(1)
+		}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useDuplicateStatsCache(t, tt.ttl)
+			tt.prepare()
+
+			stats, err := deduplicator.GetDuplicateStatisticsForWindow(ctx, 1, window)
+			if hit := err == nil; hit != tt.wantHit {
+				t.Fatalf("GetDuplicateStatisticsForWindow() = %v, %v, want cache hit %v", stats, err, tt.wantHit)
+			}
+			if tt.wantHit && stats["total_documents"] != int64(7) {
+				t.Errorf("cached stats = %v, want %v", stats, cached)
+			}
+		})
+	}
+}
+
+func TestIngestInvalidatesDuplicateStatistics(t *testing.T) {
+	databasetest.UseClosed(t)
+	useDuplicateStatsCache(t, time.Minute)
+	useFakeIngest(t)
+	ctx := context.Background()
+	window := DuplicateStatsWindowDays(30)
+
+	const companyCNPJ = "12345678000190"
+	company := &models.Company{ID: 1, CNPJ: companyCNPJ}
+	manager := NewNFSeXMLManagerWithRepositories(&repositorytest.DocumentRepository{}, &repositorytest.CompanyRepository{Companies: map[int64]*models.Company{company.ID: company}})
+	storeDuplicateStatistics(company.ID, window.key(), map[string]any{"total_documents": int64(0)}, time.Minute)
+	storeDuplicateStatistics(2, window.key(), map[string]any{"total_documents": int64(0)}, time.Minute)
+
+	// A batch that stores nothing keeps the cache
+	if _, err := manager.ProcessBatchXML(ctx, company.ID, BatchOptions{Source: ProcessingSourcePrefeituraAPI}, []XMLDocument{
+		{FileName: "broken.xml", Content: "<consultarNotaResponse>"},
+	}); err != nil {
+		t.Fatalf("ProcessBatchXML() error = %v", err)
+	}
+	if _, ok := cachedDuplicateStatistics(company.ID, window.key()); !ok {
+		t.Error("statistics were dropped by a batch that stored nothing")
+	}
+
+	if _, err := manager.ProcessBatchXML(ctx, company.ID, BatchOptions{Source: ProcessingSourcePrefeituraAPI}, []XMLDocument{
+		{FileName: "1.xml", Content: testNFSeXML("1", "AAA", companyCNPJ, "", "100.00")},
+	}); err != nil {
+		t.Fatalf("ProcessBatchXML() error = %v", err)
+	}
+	if _, ok := cachedDuplicateStatistics(company.ID, window.key()); ok {
+		t.Error("statistics are still cached after an ingest")
+	}
+	if _, err := manager.GetDuplicateStatistics(ctx, company.ID, window); err == nil {
+		t.Error("GetDuplicateStatistics() after an ingest did not query the database")
+	}
+	if _, ok := cachedDuplicateStatistics(2, window.key()); !ok {
+		t.Error("statistics of another company were dropped")
+	}
+}
+
+func TestDuplicateStatisticsRecomputeAfterInvalidation(t *testing.T) {
+	databasetest.Require(t)
+	useDuplicateStatsCache(t, time.Minute)
+	ctx := context.Background()
+	deduplicator := NewNFSeDeduplicator()
+	window := DuplicateStatsWindowDays(1)
+
+	company := databasetest.CreateCompany(t, nil)
+	databasetest.CreateDocument(t, &models.Document{CompanyID: company.ID, VerificationCode: "STATS-A"})
+
+	total := func() int64 {
+		t.Helper()
+		stats, err := deduplicator.GetDuplicateStatisticsForWindow(ctx, company.ID, window)
+		if err != nil {
+			t.Fatalf("GetDuplicateStatisticsForWindow() error = %v", err)
+		}
+		return stats["total_documents"].(int64)
+	}
+
+	if got := total(); got != 1 {
+		t.Fatalf("total_documents = %d, want 1", got)
+	}
+
+	// Inserted behind the ingest, the new document is not seen until the cache is dropped
+	databasetest.CreateDocument(t, &models.Document{CompanyID: company.ID, VerificationCode: "STATS-A"})
+	if got := total(); got != 1 {
+		t.Errorf("total_documents from the cache = %d, want 1", got)
+	}
+
+	InvalidateDuplicateStatistics(company.ID)
+	if got := total(); got != 2 {
+		t.Errorf("total_documents after invalidation = %d, want 2", got)
+	}
+}
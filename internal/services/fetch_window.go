@@ -0,0 +1,230 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/zoomxml/config"
+	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/logger"
+	"github.com/zoomxml/internal/models"
+)
+
+// maxFetchRangeDays is the largest date range sent to the provider in a single query
+const maxFetchRangeDays = 30
+
+// maxDocumentsPerPage is the page size of the provider; a shorter page is the last one
+const maxDocumentsPerPage = 100
+
+// DateRange is an inclusive range of days sent to the provider
+type DateRange struct {
+	Start time.Time
+	End   time.Time
+}
+
+// FetchWindow is the date range of a "since last document" fetch
+type FetchWindow struct {
+	Start      time.Time
+	End        time.Time
+	FirstFetch bool // no stored document yet; Start comes from the backfill window
+	Truncated  bool // MaxPagesPerRun stopped a range before its last page
+}
+
+// LastDocumentDate returns the most recent issue date among the stored NFSe documents
+// of a company; ok is false when the company has none
+func LastDocumentDate(ctx context.Context, companyID int64) (time.Time, bool, error) {
+	var last sql.NullTime
+	err := database.DB.NewSelect().
+		Model((*models.Document)(nil)).
+		ColumnExpr("MAX(issue_date)").
+		Where("company_id = ? AND type = 'nfse'", companyID).
+		Scan(ctx, &last)
+
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("failed to load last document date: %w", err)
+	}
+
+	if !last.Valid || last.Time.IsZero() {
+		return time.Time{}, false, nil
+	}
+
+	return last.Time, true, nil
+}
+
+// SinceLastDocumentWindow computes the fetch window from the last stored document up to now.
+// The day of the last document is fetched again, since notes issued later that day may
+// be missing; the deduplicator discards the ones already stored. Without any stored
+// document, or after the watermark was reset, the window falls back to the last
+// backfillDays days.
+func SinceLastDocumentWindow(ctx context.Context, companyID int64, now time.Time, backfillDays int) (FetchWindow, error) {
+	var resyncPending bool
+	err := database.DB.NewSelect().
+		Model((*models.Company)(nil)).
+		Column("resync_pending").
+		Where("id = ?", companyID).
+		Scan(ctx, &resyncPending)
+	if err != nil {
+		return FetchWindow{}, fmt.Errorf("failed to load company: %w", err)
+	}
+	if resyncPending {
+		return sinceLastWindow(time.Time{}, false, now, backfillDays), nil
+	}
+
+	last, ok, err := LastDocumentDate(ctx, companyID)
+	if err != nil {
+		return FetchWindow{}, err
+	}
+
+	return sinceLastWindow(last, ok, now, backfillDays), nil
+}
+
+// sinceLastWindow is the pure part of SinceLastDocumentWindow
+func sinceLastWindow(last time.Time, ok bool, now time.Time, backfillDays int) FetchWindow {
+	end := truncateToDay(now)
+
+	if !ok {
+		return FetchWindow{
+			Start:      end.AddDate(0, 0, -backfillDays),
+			End:        end,
+			FirstFetch: true,
+		}
+	}
+
+	start := truncateToDay(last.In(now.Location()))
+	if start.After(end) {
+		start = end
+	}
+
+	return FetchWindow{Start: start, End: end}
+}
+
+// SplitDateRange splits [start, end] into consecutive ranges of at most maxDays days
+func SplitDateRange(start, end time.Time, maxDays int) []DateRange {
+	start, end = truncateToDay(start), truncateToDay(end)
+	if end.Before(start) {
+		return nil
+	}
+	if maxDays < 1 {
+		return []DateRange{{Start: start, End: end}}
+	}
+
+	ranges := []DateRange{}
+	for current := start; !current.After(end); current = current.AddDate(0, 0, maxDays) {
+		rangeEnd := current.AddDate(0, 0, maxDays-1)
+		if rangeEnd.After(end) {
+			rangeEnd = end
+		}
+		ranges = append(ranges, DateRange{Start: current, End: rangeEnd})
+	}
+
+	return ranges
+}
+
+// truncateToDay drops the time of day, keeping the location
+func truncateToDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// FetchSinceLastDocument fetches every page of the provider from the last stored document
+// of the company up to today, in provider-safe ranges, storing the documents of each page
+// as they arrive. The result carries the counts but not the documents. The returned window
+// tells which dates were queried; the caller passes it to FinishBackfill once every
+// document was stored.
+func (s *NFSeService) FetchSinceLastDocument(ctx context.Context, credential *models.CompanyCredential) (*NFSeProcessResult, FetchWindow, error) {
+	cfg := config.Get().NFSeScheduler
+
+	window, err := SinceLastDocumentWindow(ctx, credential.CompanyID, time.Now(), cfg.FetchDaysBack)
+	if err != nil {
+		return nil, FetchWindow{}, err
+	}
+
+	logger.InfoWithFields("Fetching NFSe documents since last document", map[string]any{
+		"operation":   "fetch_nfse_since_last",
+		"company_id":  credential.CompanyID,
+		"start_date":  window.Start.Format("2006-01-02"),
+		"end_date":    window.End.Format("2006-01-02"),
+		"first_fetch": window.FirstFetch,
+	})
+
+	documentsCount, failedCount := 0, 0
+	for _, dateRange := range SplitDateRange(window.Start, window.End, maxFetchRangeDays) {
+		for page := 1; page <= cfg.MaxPagesPerRun; page++ {
+			result, err := s.FetchAndStoreNFSeDocuments(ctx, credential, dateRange.Start, dateRange.End, page)
+			if err != nil {
+				return nil, window, err
+			}
+
+			if !result.Success {
+				return result, window, nil
+			}
+
+			documentsCount += result.DocumentsCount
+			failedCount += result.FailedCount
+			if result.DocumentsCount < maxDocumentsPerPage {
+				break
+			}
+			if page == cfg.MaxPagesPerRun {
+				window.Truncated = true
+			}
+		}
+	}
+
+	return &NFSeProcessResult{
+		Success:        true,
+		Message:        fmt.Sprintf("Successfully fetched %d documents from %s to %s", documentsCount, window.Start.Format("2006-01-02"), window.End.Format("2006-01-02")),
+		DocumentsCount: documentsCount,
+		FailedCount:    failedCount,
+	}, window, nil
+}
+
+// ResetSyncWatermark clears the sync state of a company so that the next "since last
+// document" fetch backfills the last NFSE_FETCH_DAYS_BACK days instead of starting
+// from the most recent stored document
+func ResetSyncWatermark(ctx context.Context, companyID int64) error {
+	res, err := database.DB.NewUpdate().
+		Model((*models.Company)(nil)).
+		Set("resync_pending = true").
+		Set("last_sync_at = NULL").
+		Set("last_sync_status = NULL").
+		Set("last_sync_error = NULL").
+		Set("updated_at = current_timestamp").
+		Where("id = ?", companyID).
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to reset sync watermark: %w", err)
+	}
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	logger.InfoWithFields("Sync watermark reset", map[string]any{
+		"operation":  "reset_sync_watermark",
+		"company_id": companyID,
+	})
+	return nil
+}
+
+// FinishBackfill marks the backfill requested by ResetSyncWatermark as done after the
+// documents of a "since last document" fetch were stored. A truncated backfill stays
+// pending, so the next fetch queries the whole backfill window again.
+func FinishBackfill(ctx context.Context, companyID int64, window FetchWindow) error {
+	if !window.FirstFetch || window.Truncated {
+		return nil
+	}
+	return clearResyncPending(ctx, companyID)
+}
+
+// clearResyncPending marks the backfill requested by ResetSyncWatermark as done
+func clearResyncPending(ctx context.Context, companyID int64) error {
+	_, err := database.DB.NewUpdate().
+		Model((*models.Company)(nil)).
+		Set("resync_pending = false").
+		Where("id = ? AND resync_pending = true", companyID).
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to clear resync flag: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/database/databasetest"
+	"github.com/zoomxml/internal/models"
+)
+
+func TestFinishBackfill(t *testing.T) {
+	databasetest.Require(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name        string
+		window      FetchWindow
+		wantPending bool
+	}{
+		{"incremental fetch leaves the flag alone", FetchWindow{}, true},
+		{"truncated backfill stays pending", FetchWindow{FirstFetch: true, Truncated: true}, true},
+		{"complete backfill is done", FetchWindow{FirstFetch: true}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			company := databasetest.CreateCompany(t, func(c *models.Company) { c.ResyncPending = true })
+
+			if err := FinishBackfill(ctx, company.ID, tt.window); err != nil {
+				t.Fatalf("FinishBackfill() error = %v", err)
+			}
+
+			stored := &models.Company{}
+			if err := database.DB.NewSelect().Model(stored).Column("resync_pending").Where("id = ?", company.ID).Scan(ctx); err != nil {
+				t.Fatal(err)
+			}
+			if stored.ResyncPending != tt.wantPending {
+				t.Errorf("resync_pending = %v, want %v", stored.ResyncPending, tt.wantPending)
+			}
+		})
+	}
+}
+
+func TestSinceLastWindow(t *testing.T) {
+	now := time.Date(2025, 3, 20, 15, 30, 0, 0, time.UTC)
+	today := time.Date(2025, 3, 20, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		last time.Time
+		ok   bool
+		want FetchWindow
+	}{
+		{"first fetch falls back to the backfill window", time.Time{}, false, FetchWindow{Start: today.AddDate(0, 0, -30), End: today, FirstFetch: true}},
+		{"day of the last document is fetched again", time.Date(2025, 3, 10, 18, 45, 0, 0, time.UTC), true, FetchWindow{Start: time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC), End: today}},
+		{"last document today", time.Date(2025, 3, 20, 9, 0, 0, 0, time.UTC), true, FetchWindow{Start: today, End: today}},
+		{"last document in the future", time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC), true, FetchWindow{Start: today, End: today}},
+		{"last document in another time zone", time.Date(2025, 3, 11, 1, 0, 0, 0, time.FixedZone("UTC+3", 3*3600)), true, FetchWindow{Start: time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC), End: today}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := sinceLastWindow(tt.last, tt.ok, now, 30)
+			if !got.Start.Equal(tt.want.Start) || !got.End.Equal(tt.want.End) || got.FirstFetch != tt.want.FirstFetch {
+				t.Errorf("sinceLastWindow() = %v..%v first %v, want %v..%v first %v",
+					got.Start, got.End, got.FirstFetch, tt.want.Start, tt.want.End, tt.want.FirstFetch)
+			}
+		})
+	}
+}
+
+func TestSplitDateRange(t *testing.T) {
+	day := func(month time.Month, d int) time.Time { return time.Date(2025, month, d, 0, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		name    string
+		start   time.Time
+		end     time.Time
+		maxDays int
+		want    []DateRange
+	}{
+		{"single day", day(3, 1), day(3, 1), 30, []DateRange{{day(3, 1), day(3, 1)}}},
+		{"within the limit", day(3, 1), day(3, 30), 30, []DateRange{{day(3, 1), day(3, 30)}}},
+		{"split at the limit", day(3, 1), day(3, 31), 30, []DateRange{{day(3, 1), day(3, 30)}, {day(3, 31), day(3, 31)}}},
+		{"several ranges", day(1, 1), day(3, 1), 30, []DateRange{{day(1, 1), day(1, 30)}, {day(1, 31), day(3, 1)}}},
+		{"end before start", day(3, 2), day(3, 1), 30, nil},
+		{"no limit", day(1, 1), day(3, 1), 0, []DateRange{{day(1, 1), day(3, 1)}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SplitDateRange(tt.start, tt.end, tt.maxDays)
+			if len(got) != len(tt.want) {
+				t.Fatalf("SplitDateRange() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if !got[i].Start.Equal(tt.want[i].Start) || !got[i].End.Equal(tt.want[i].End) {
+					t.Errorf("range %d = %v..%v, want %v..%v", i, got[i].Start, got[i].End, tt.want[i].Start, tt.want[i].End)
+				}
+			}
+		})
+	}
+}
+
+func TestSinceLastDocumentWindow(t *testing.T) {
+	databasetest.Require(t)
+	ctx := context.Background()
+	now := time.Now()
+	today := truncateToDay(now)
+	lastIssue := today.AddDate(0, 0, -5).Add(14 * time.Hour)
+
+	tests := []struct {
+		name          string
+		resync        bool
+		withDocuments bool
+		wantStart     time.Time
+		wantFirst     bool
+	}{
+		{"first fetch", false, false, today.AddDate(0, 0, -30), true},
+		{"since the last document", false, true, today.AddDate(0, 0, -5), false},
+		{"reset watermark backfills", true, true, today.AddDate(0, 0, -30), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			company := databasetest.CreateCompany(t, func(c *models.Company) { c.ResyncPending = tt.resync })
+			if tt.withDocuments {
+				databasetest.CreateDocument(t, &models.Document{CompanyID: company.ID, Number: "1", IssueDate: lastIssue.AddDate(0, 0, -3)})
+				databasetest.CreateDocument(t, &models.Document{CompanyID: company.ID, Number: "2", IssueDate: lastIssue})
+			}
+
+			window, err := SinceLastDocumentWindow(ctx, company.ID, now, 30)
+			if err != nil {
+				t.Fatalf("SinceLastDocumentWindow() error = %v", err)
+			}
+			if !window.Start.Equal(tt.wantStart) || !window.End.Equal(today) || window.FirstFetch != tt.wantFirst {
+				t.Errorf("SinceLastDocumentWindow() = %v..%v first %v, want %v..%v first %v",
+					window.Start, window.End, window.FirstFetch, tt.wantStart, today, tt.wantFirst)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/zoomxml/config"
+	"github.com/zoomxml/internal/models"
+	"github.com/zoomxml/internal/repository/repositorytest"
+	"github.com/zoomxml/internal/storage"
+)
+
+// useFilesystemStorage replaces the global storage with the filesystem backend rooted
+// in a temporary directory, which it returns
+func useFilesystemStorage(t *testing.T) string {
+	t.Helper()
+	cfg := config.Get()
+	previous, localPath := storage.Storage, cfg.Storage.LocalPath
+	cfg.Storage.LocalPath = t.TempDir()
+	storage.Storage = storage.NewFilesystemService()
+	t.Cleanup(func() {
+		storage.Storage = previous
+		cfg.Storage.LocalPath = localPath
+	})
+	if err := storage.Storage.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+	return cfg.Storage.LocalPath
+}
+
+func TestProcessBatchXMLWithFilesystemStorage(t *testing.T) {
+	const companyCNPJ = "12345678000190"
+	useFakeIngest(t)
+	root := useFilesystemStorage(t)
+	ctx := context.Background()
+
+	company := &models.Company{ID: 1, CNPJ: companyCNPJ}
+	documents := &repositorytest.DocumentRepository{}
+	manager := NewNFSeXMLManagerWithRepositories(documents, &repositorytest.CompanyRepository{Companies: map[int64]*models.Company{company.ID: company}})
+
+	xmls := []string{
+		testNFSeXML("1", "AAA", companyCNPJ, "", "100.00"),
+		testNFSeXML("2", "BBB", companyCNPJ, "", "50.00"),
+	}
+	batch := []XMLDocument{{FileName: "1.xml", Content: xmls[0]}, {FileName: "2.xml", Content: xmls[1]}}
+
+	result, err := manager.ProcessBatchXML(ctx, company.ID, BatchOptions{Source: ProcessingSourceManualUpload}, batch)
+	if err != nil {
+		t.Fatalf("ProcessBatchXML() error = %v", err)
+	}
+	if result.ProcessedDocuments != 2 || result.ErrorDocuments != 0 {
+		t.Fatalf("processed/errors = %d/%d, want 2/0", result.ProcessedDocuments, result.ErrorDocuments)
+	}
+
+	// Every stored document can be read back, through the storage and from disk
+	keys := []string{}
+	for i, document := range documents.Documents {
+		data, err := storage.Storage.DownloadFile(ctx, nfseBucket, document.StorageKey)
+		if err != nil {
+			t.Fatalf("DownloadFile(%s) error = %v", document.StorageKey, err)
+		}
+		if string(data) != xmls[i] {
+			t.Errorf("object %s = %q, want the uploaded XML", document.StorageKey, data)
+		}
+		if _, err := os.Stat(filepath.Join(root, nfseBucket, filepath.FromSlash(document.StorageKey))); err != nil {
+			t.Errorf("object %s not written under the storage root: %v", document.StorageKey, err)
+		}
+		keys = append(keys, document.StorageKey)
+	}
+
+	listed, err := storage.Storage.ListFiles(ctx, nfseBucket, "nfse/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(listed)
+	slices.Sort(keys)
+	if !slices.Equal(listed, keys) {
+		t.Errorf("ListFiles() = %v, want %v", listed, keys)
+	}
+
+	// Ingesting the same batch again stores nothing new
+	result, err = manager.ProcessBatchXML(ctx, company.ID, BatchOptions{Source: ProcessingSourceManualUpload}, batch)
+	if err != nil {
+		t.Fatalf("ProcessBatchXML() again error = %v", err)
+	}
+	if result.DuplicateDocuments != 2 || len(documents.Documents) != 2 {
+		t.Errorf("second ingest = %d duplicates, %d documents, want 2 and 2", result.DuplicateDocuments, len(documents.Documents))
+	}
+}
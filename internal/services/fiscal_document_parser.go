@@ -0,0 +1,378 @@
+package services
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zoomxml/internal/logger"
+	"github.com/zoomxml/internal/models"
+)
+
+// Authorization statuses (cStat) of a cancelled NF-e or CT-e in its protocol
+var cancelledFiscalStatuses = map[string]bool{
+	"101": true, // cancelamento homologado
+	"151": true, // cancelamento homologado fora de prazo
+}
+
+// nfeProcXML is an NF-e (modelo 55) as distributed: the signed note and its authorization
+// protocol. A bare NFe root is decoded into NFe alone.
+type nfeProcXML struct {
+	NFe     nfeXML            `xml:"NFe"`
+	ProtNFe fiscalProtocolXML `xml:"protNFe"`
+}
+
+type nfeXML struct {
+	InfNFe struct {
+		ID  string `xml:"Id,attr"`
+		Ide struct {
+			Mod   string `xml:"mod"`
+			Serie string `xml:"serie"`
+			NNF   string `xml:"nNF"`
+			DhEmi string `xml:"dhEmi"`
+			DEmi  string `xml:"dEmi"` // Layout 2.00
+		} `xml:"ide"`
+		Emit fiscalPartyXML `xml:"emit"`
+		Dest fiscalPartyXML `xml:"dest"`
+		Det  []struct {
+			Prod struct {
+				XProd string `xml:"xProd"`
+			} `xml:"prod"`
+		} `xml:"det"`
+		Total struct {
+			ICMSTot struct {
+				VNF string `xml:"vNF"`
+			} `xml:"ICMSTot"`
+		} `xml:"total"`
+	} `xml:"infNFe"`
+}
+
+// cteProcXML is a CT-e (modelo 57) with its authorization protocol
+type cteProcXML struct {
+	CTe     cteXML            `xml:"CTe"`
+	ProtCTe fiscalProtocolXML `xml:"protCTe"`
+}
+
+type cteXML struct {
+	InfCte struct {
+		ID  string `xml:"Id,attr"`
+		Ide struct {
+			CFOP  string `xml:"CFOP"`
+			Mod   string `xml:"mod"`
+			Serie string `xml:"serie"`
+			NCT   string `xml:"nCT"`
+			DhEmi string `xml:"dhEmi"`
+			Toma3 struct {
+				Toma string `xml:"toma"`
+			} `xml:"toma3"`
+			Toma4 fiscalPartyXML `xml:"toma4"`
+		} `xml:"ide"`
+		Emit   fiscalPartyXML `xml:"emit"`
+		Rem    fiscalPartyXML `xml:"rem"`
+		Exped  fiscalPartyXML `xml:"exped"`
+		Receb  fiscalPartyXML `xml:"receb"`
+		Dest   fiscalPartyXML `xml:"dest"`
+		VPrest struct {
+			VTPrest string `xml:"vTPrest"`
+		} `xml:"vPrest"`
+		InfCTeNorm struct {
+			InfCarga struct {
+				ProPred string `xml:"proPred"`
+			} `xml:"infCarga"`
+		} `xml:"infCTeNorm"`
+	} `xml:"infCte"`
+}
+
+// fiscalProtocolXML is the authorization protocol of an NF-e or CT-e
+type fiscalProtocolXML struct {
+	InfProt struct {
+		ChNFe string `xml:"chNFe"`
+		ChCTe string `xml:"chCTe"`
+		CStat string `xml:"cStat"`
+	} `xml:"infProt"`
+}
+
+// fiscalPartyXML is an issuer, recipient or other party of an NF-e or CT-e. Each layout
+// names the address after the role (enderEmit, enderDest, ...).
+type fiscalPartyXML struct {
+	Toma      string             `xml:"toma"` // Only in toma4
+	CNPJ      string             `xml:"CNPJ"`
+	CPF       string             `xml:"CPF"`
+	XNome     string             `xml:"xNome"`
+	XFant     string             `xml:"xFant"`
+	IM        string             `xml:"IM"`
+	Addresses []fiscalAddressXML `xml:",any"`
+}
+
+type fiscalAddressXML struct {
+	XMLName xml.Name
+	XLgr    string `xml:"xLgr"`
+	Nro     string `xml:"nro"`
+	XCpl    string `xml:"xCpl"`
+	XBairro string `xml:"xBairro"`
+	CMun    string `xml:"cMun"`
+	UF      string `xml:"UF"`
+	CEP     string `xml:"CEP"`
+}
+
+// taxID returns the CNPJ of the party, or its CPF
+func (p fiscalPartyXML) taxID() string {
+	if p.CNPJ != "" {
+		return strings.TrimSpace(p.CNPJ)
+	}
+	return strings.TrimSpace(p.CPF)
+}
+
+// address returns the ender* element of the party, or nil when it has none
+func (p fiscalPartyXML) address() *models.Address {
+	for _, element := range p.Addresses {
+		if !strings.HasPrefix(element.XMLName.Local, "ender") {
+			continue
+		}
+		return Endereco{
+			Endereco:        element.XLgr,
+			Numero:          element.Nro,
+			Complemento:     element.XCpl,
+			Bairro:          element.XBairro,
+			CodigoMunicipio: element.CMun,
+			Uf:              element.UF,
+			Cep:             element.CEP,
+		}.toAddress()
+	}
+	return nil
+}
+
+// DetectDocumentType returns the document type of an XML from its root element: nfeProc
+// or NFe for NF-e, cteProc or CTe for CT-e, and NFSe for anything else
+func (p *NFSeParser) DetectDocumentType(xmlContent string) string {
+	switch rootElement(p.convertEncoding(xmlContent), p.charsetReader) {
+	case "nfeProc", "NFe":
+		return models.DocumentTypeNFe
+	case "cteProc", "CTe":
+		return models.DocumentTypeCTe
+	}
+	return models.DocumentTypeNFSe
+}
+
+// documentTypeOf returns the document type of parsed data, NFSe unless set
+func documentTypeOf(parsedData *ParsedNFSeData) string {
+	if parsedData.DocumentType == "" {
+		return models.DocumentTypeNFSe
+	}
+	return parsedData.DocumentType
+}
+
+// ParseDocumentXML parses an NFSe, NF-e or CT-e, choosing the parser by the root element
+func (p *NFSeParser) ParseDocumentXML(xmlContent string) (*ParsedNFSeData, error) {
+	switch p.DetectDocumentType(xmlContent) {
+	case models.DocumentTypeNFe:
+		return p.ParseNFeXML(xmlContent)
+	case models.DocumentTypeCTe:
+		return p.ParseCTeXML(xmlContent)
+	}
+	return p.ParseXML(xmlContent)
+}
+
+// ParseNFeXML parses an NF-e (nfeProc or bare NFe) into the fields shared with NFSe. The
+// issuer is the provider, the recipient the taker and the note total the service value.
+func (p *NFSeParser) ParseNFeXML(xmlContent string) (*ParsedNFSeData, error) {
+	contentHash := hashContent(xmlContent)
+	xmlContent = p.convertEncoding(xmlContent)
+
+	var proc nfeProcXML
+	if err := p.decodeFiscalXML(xmlContent, "NFe", &proc, &proc.NFe); err != nil {
+		return nil, err
+	}
+
+	inf := proc.NFe.InfNFe
+	if inf.Ide.Mod != "" && inf.Ide.Mod != "55" {
+		return nil, fmt.Errorf("unsupported NF-e model %s, only modelo 55 is supported", inf.Ide.Mod)
+	}
+
+	accessKey := strings.TrimSpace(proc.ProtNFe.InfProt.ChNFe)
+	if accessKey == "" {
+		accessKey = strings.TrimPrefix(strings.TrimSpace(inf.ID), "NFe")
+	}
+
+	rawIssueDate := inf.Ide.DhEmi
+	if rawIssueDate == "" {
+		rawIssueDate = inf.Ide.DEmi
+	}
+
+	products := make([]string, 0, len(inf.Det))
+	for _, item := range inf.Det {
+		if product := strings.TrimSpace(item.Prod.XProd); product != "" {
+			products = append(products, product)
+		}
+	}
+
+	parsedData := &ParsedNFSeData{
+		DocumentType:          models.DocumentTypeNFe,
+		AccessKey:             accessKey,
+		Number:                strings.TrimSpace(inf.Ide.NNF),
+		Series:                strings.TrimSpace(inf.Ide.Serie),
+		ProviderCNPJ:          inf.Emit.taxID(),
+		TakerCNPJ:             inf.Dest.taxID(),
+		ServiceValue:          parseFiscalValue(inf.Total.ICMSTot.VNF),
+		IssueDate:             parseFiscalDate(rawIssueDate),
+		MunicipalRegistration: strings.TrimSpace(inf.Emit.IM),
+		IsCancelled:           cancelledFiscalStatuses[strings.TrimSpace(proc.ProtNFe.InfProt.CStat)],
+		DocumentHash:          p.generateDocumentHash(accessKey, inf.Ide.NNF, inf.Emit.taxID(), rawIssueDate),
+		ContentHash:           contentHash,
+		FullXML:               xmlContent,
+		TakerName:             strings.TrimSpace(inf.Dest.XNome),
+		ProviderName:          strings.TrimSpace(inf.Emit.XNome),
+		ProviderTradeName:     strings.TrimSpace(inf.Emit.XFant),
+		TakerAddress:          inf.Dest.address(),
+		ProviderAddress:       inf.Emit.address(),
+		Discriminacao:         strings.Join(products, "\n"),
+	}
+
+	logger.InfoWithFields("Successfully parsed NF-e XML", map[string]any{
+		"operation":     "parse_nfe_xml",
+		"number":        parsedData.Number,
+		"access_key":    parsedData.AccessKey,
+		"provider_cnpj": parsedData.ProviderCNPJ,
+		"total_value":   parsedData.ServiceValue,
+		"is_cancelled":  parsedData.IsCancelled,
+	})
+
+	return parsedData, nil
+}
+
+// ParseCTeXML parses a CT-e (cteProc or bare CTe) into the fields shared with NFSe. The
+// carrier is the provider, the tomador do serviço the taker and the total service value
+// (vTPrest) the service value.
+func (p *NFSeParser) ParseCTeXML(xmlContent string) (*ParsedNFSeData, error) {
+	contentHash := hashContent(xmlContent)
+	xmlContent = p.convertEncoding(xmlContent)
+
+	var proc cteProcXML
+	if err := p.decodeFiscalXML(xmlContent, "CTe", &proc, &proc.CTe); err != nil {
+		return nil, err
+	}
+
+	inf := proc.CTe.InfCte
+	if inf.Ide.Mod != "" && inf.Ide.Mod != "57" {
+		return nil, fmt.Errorf("unsupported CT-e model %s, only modelo 57 is supported", inf.Ide.Mod)
+	}
+
+	accessKey := strings.TrimSpace(proc.ProtCTe.InfProt.ChCTe)
+	if accessKey == "" {
+		accessKey = strings.TrimPrefix(strings.TrimSpace(inf.ID), "CTe")
+	}
+
+	// The tomador is one of the parties (toma3) or described on its own (toma4)
+	taker := inf.Ide.Toma4
+	if taker.taxID() == "" {
+		switch strings.TrimSpace(inf.Ide.Toma3.Toma) {
+		case "0":
+			taker = inf.Rem
+		case "1":
+			taker = inf.Exped
+		case "2":
+			taker = inf.Receb
+		default:
+			taker = inf.Dest
+		}
+	}
+
+	parsedData := &ParsedNFSeData{
+		DocumentType:      models.DocumentTypeCTe,
+		AccessKey:         accessKey,
+		Number:            strings.TrimSpace(inf.Ide.NCT),
+		Series:            strings.TrimSpace(inf.Ide.Serie),
+		ProviderCNPJ:      inf.Emit.taxID(),
+		TakerCNPJ:         taker.taxID(),
+		ServiceValue:      parseFiscalValue(inf.VPrest.VTPrest),
+		ServiceCode:       strings.TrimSpace(inf.Ide.CFOP),
+		IssueDate:         parseFiscalDate(inf.Ide.DhEmi),
+		IsCancelled:       cancelledFiscalStatuses[strings.TrimSpace(proc.ProtCTe.InfProt.CStat)],
+		DocumentHash:      p.generateDocumentHash(accessKey, inf.Ide.NCT, inf.Emit.taxID(), inf.Ide.DhEmi),
+		ContentHash:       contentHash,
+		FullXML:           xmlContent,
+		TakerName:         strings.TrimSpace(taker.XNome),
+		ProviderName:      strings.TrimSpace(inf.Emit.XNome),
+		ProviderTradeName: strings.TrimSpace(inf.Emit.XFant),
+		TakerAddress:      taker.address(),
+		ProviderAddress:   inf.Emit.address(),
+		Discriminacao:     strings.TrimSpace(inf.InfCTeNorm.InfCarga.ProPred),
+	}
+
+	logger.InfoWithFields("Successfully parsed CT-e XML", map[string]any{
+		"operation":     "parse_cte_xml",
+		"number":        parsedData.Number,
+		"access_key":    parsedData.AccessKey,
+		"provider_cnpj": parsedData.ProviderCNPJ,
+		"service_value": parsedData.ServiceValue,
+		"is_cancelled":  parsedData.IsCancelled,
+	})
+
+	return parsedData, nil
+}
+
+// decodeFiscalXML decodes an NF-e or CT-e into proc, or into bare when the root element is
+// the document itself (bareRoot) rather than the *Proc wrapper
+func (p *NFSeParser) decodeFiscalXML(xmlContent, bareRoot string, proc, bare any) error {
+	if strings.TrimSpace(xmlContent) == "" {
+		return fmt.Errorf("empty XML content")
+	}
+
+	target := proc
+	if rootElement(xmlContent, p.charsetReader) == bareRoot {
+		target = bare
+	}
+
+	decoder := xml.NewDecoder(strings.NewReader(xmlContent))
+	decoder.CharsetReader = p.charsetReader
+	if err := decoder.Decode(target); err != nil {
+		logger.ErrorWithFields("Failed to parse "+bareRoot+" XML", err, map[string]any{
+			"operation": "parse_fiscal_xml",
+		})
+		return fmt.Errorf("failed to parse XML: %v", err)
+	}
+	return nil
+}
+
+// rootElement returns the local name of the first element of an XML
+func rootElement(xmlContent string, charsetReader func(string, io.Reader) (io.Reader, error)) string {
+	decoder := xml.NewDecoder(strings.NewReader(xmlContent))
+	decoder.CharsetReader = charsetReader
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return ""
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			return start.Name.Local
+		}
+	}
+}
+
+// parseFiscalValue parses a decimal value of an NF-e or CT-e; missing or invalid values
+// are zero
+func parseFiscalValue(raw string) float64 {
+	value, _ := strconv.ParseFloat(strings.TrimSpace(raw), 64)
+	return value
+}
+
+// parseFiscalDate parses dhEmi (date and time with UTC offset) or the date-only dEmi of
+// older layouts
+func parseFiscalDate(raw string) time.Time {
+	raw = strings.TrimSpace(raw)
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02"} {
+		if parsed, err := time.Parse(layout, raw); err == nil {
+			return parsed
+		}
+	}
+	if raw != "" {
+		logger.WarnWithFields("Failed to parse issue date", map[string]any{
+			"operation":  "parse_fiscal_xml",
+			"issue_date": raw,
+		})
+	}
+	return time.Time{}
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/logger"
+	"github.com/zoomxml/internal/models"
+)
+
+// cancellationEventType is the tpEvento of an NF-e or CT-e cancellation
+const cancellationEventType = "110111"
+
+// Statuses (cStat) of a registered event in its return: 135 linked to the document, 136
+// registered without the link and 155 a cancellation registered past the deadline
+var registeredEventStatuses = map[string]bool{
+	"135": true,
+	"136": true,
+	"155": true,
+}
+
+// ErrCancelledDocumentNotFound is returned for a cancellation event whose document is not
+// stored yet; the event is kept for retry, since the note may arrive later
+var ErrCancelledDocumentNotFound = errors.New("cancelled document not found")
+
+// FiscalCancellation is an NF-e or CT-e cancellation event, which refers to the cancelled
+// document by its access key
+type FiscalCancellation struct {
+	DocumentType string
+	AccessKey    string
+}
+
+// fiscalEventProcXML is a procEventoNFe or procEventoCTe: the event and its return
+type fiscalEventProcXML struct {
+	Evento    fiscalEventXML `xml:"evento"`
+	EventoCTe fiscalEventXML `xml:"eventoCTe"`
+	Ret       fiscalEventXML `xml:"retEvento"`
+	RetCTe    fiscalEventXML `xml:"retEventoCTe"`
+}
+
+type fiscalEventXML struct {
+	InfEvento struct {
+		TpEvento string `xml:"tpEvento"`
+		ChNFe    string `xml:"chNFe"`
+		ChCTe    string `xml:"chCTe"`
+		CStat    string `xml:"cStat"`
+	} `xml:"infEvento"`
+}
+
+// isFiscalEventRoot reports whether a root element is an NF-e or CT-e event with its return
+func isFiscalEventRoot(root string) bool {
+	return root == "procEventoNFe" || root == "procEventoCTe"
+}
+
+// ParseCancellationEvent parses an NF-e or CT-e event (procEventoNFe or procEventoCTe).
+// It returns nil without error when the XML is not an event, and an error for events
+// other than a registered cancellation.
+func (p *NFSeParser) ParseCancellationEvent(xmlContent string) (*FiscalCancellation, error) {
+	xmlContent = p.convertEncoding(xmlContent)
+	root := rootElement(xmlContent, p.charsetReader)
+	if !isFiscalEventRoot(root) {
+		return nil, nil
+	}
+
+	var proc fiscalEventProcXML
+	decoder := xml.NewDecoder(strings.NewReader(xmlContent))
+	decoder.CharsetReader = p.charsetReader
+	if err := decoder.Decode(&proc); err != nil {
+		return nil, fmt.Errorf("failed to parse event XML: %v", err)
+	}
+
+	cancellation := &FiscalCancellation{DocumentType: models.DocumentTypeNFe}
+	event, ret := proc.Evento.InfEvento, proc.Ret.InfEvento
+	if root == "procEventoCTe" {
+		cancellation.DocumentType = models.DocumentTypeCTe
+		event, ret = proc.EventoCTe.InfEvento, proc.RetCTe.InfEvento
+	}
+
+	if eventType := strings.TrimSpace(event.TpEvento); eventType != cancellationEventType {
+		return nil, fmt.Errorf("unsupported event type %s, only cancellations (%s) are processed", eventType, cancellationEventType)
+	}
+	if status := strings.TrimSpace(ret.CStat); !registeredEventStatuses[status] {
+		return nil, fmt.Errorf("cancellation event not registered (cStat %s)", status)
+	}
+
+	cancellation.AccessKey = strings.TrimSpace(event.ChNFe + event.ChCTe)
+	if cancellation.AccessKey == "" {
+		return nil, fmt.Errorf("cancellation event without access key")
+	}
+
+	return cancellation, nil
+}
+
+// applyCancellationEvent flags the document cancelled by an event XML. handled is false
+// when the XML is not an event, so it is ingested as a document.
+func (m *NFSeXMLManager) applyCancellationEvent(ctx context.Context, companyID int64, xmlContent string) (result ProcessingResult, handled bool) {
+	cancellation, err := m.parser.ParseCancellationEvent(xmlContent)
+	if cancellation == nil && err == nil {
+		return ProcessingResult{}, false
+	}
+	if err != nil {
+		return ProcessingResult{Error: err, Rejected: true}, true
+	}
+
+	var documentID int64
+	err = database.DB.NewUpdate().
+		Model((*models.Document)(nil)).
+		Set("is_cancelled = true").
+		Set("updated_at = current_timestamp").
+		Where("company_id = ? AND type = ? AND key = ?", companyID, cancellation.DocumentType, cancellation.AccessKey).
+		Returning("id").
+		Scan(ctx, &documentID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ProcessingResult{Error: fmt.Errorf("%w: %s", ErrCancelledDocumentNotFound, cancellation.AccessKey)}, true
+		}
+		return ProcessingResult{Error: fmt.Errorf("failed to apply cancellation: %w", err)}, true
+	}
+
+	logger.InfoWithFields("Document cancelled by event", map[string]any{
+		"operation":     "cancellation_event",
+		"company_id":    companyID,
+		"document_id":   documentID,
+		"document_type": cancellation.DocumentType,
+		"access_key":    cancellation.AccessKey,
+	})
+
+	return ProcessingResult{Success: true, DocumentID: documentID, Cancelled: true}, true
+}
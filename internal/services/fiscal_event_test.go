@@ -0,0 +1,97 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/database/databasetest"
+	"github.com/zoomxml/internal/models"
+)
+
+const testNFeAccessKey = "35250312345678000190550010000001231000001234"
+
+// cancellationEventXML builds a procEventoNFe or procEventoCTe with the given event type
+// and return status
+func cancellationEventXML(root, eventType, status string) string {
+	event, ret, keyTag := "evento", "retEvento", "chNFe"
+	if root == "procEventoCTe" {
+		event, ret, keyTag = "eventoCTe", "retEventoCTe", "chCTe"
+	}
+	return `<?xml version="1.0" encoding="UTF-8"?>` +
+		`<` + root + ` xmlns="http://www.portalfiscal.inf.br/nfe" versao="1.00">` +
+		`<` + event + ` versao="1.00"><infEvento Id="ID` + eventType + testNFeAccessKey + `01">` +
+		`<CNPJ>12345678000190</CNPJ><` + keyTag + `>` + testNFeAccessKey + `</` + keyTag + `>` +
+		`<tpEvento>` + eventType + `</tpEvento><nSeqEvento>1</nSeqEvento>` +
+		`<detEvento versao="1.00"><descEvento>Cancelamento</descEvento><nProt>135250000000001</nProt><xJust>Erro na emissao da nota</xJust></detEvento>` +
+		`</infEvento></` + event + `>` +
+		`<` + ret + ` versao="1.00"><infEvento><cStat>` + status + `</cStat><` + keyTag + `>` + testNFeAccessKey + `</` + keyTag + `></infEvento></` + ret + `>` +
+		`</` + root + `>`
+}
+
+func TestParseCancellationEvent(t *testing.T) {
+	parser := NewNFSeParser()
+
+	tests := []struct {
+		name     string
+		xml      string
+		want     *FiscalCancellation
+		wantErr  bool
+		notEvent bool
+	}{
+		{"nf-e cancellation", cancellationEventXML("procEventoNFe", "110111", "135"), &FiscalCancellation{models.DocumentTypeNFe, testNFeAccessKey}, false, false},
+		{"nf-e cancellation past the deadline", cancellationEventXML("procEventoNFe", "110111", "155"), &FiscalCancellation{models.DocumentTypeNFe, testNFeAccessKey}, false, false},
+		{"ct-e cancellation", cancellationEventXML("procEventoCTe", "110111", "135"), &FiscalCancellation{models.DocumentTypeCTe, testNFeAccessKey}, false, false},
+		{"correction letter", cancellationEventXML("procEventoNFe", "110110", "135"), nil, true, false},
+		{"rejected event", cancellationEventXML("procEventoNFe", "110111", "573"), nil, true, false},
+		{"nf-e document", `<nfeProc versao="4.00"><NFe><infNFe Id="NFe` + testNFeAccessKey + `"></infNFe></NFe></nfeProc>`, nil, false, true},
+		{"nfse document", `<CompNfse><Nfse><InfNfse><Numero>1</Numero></InfNfse></Nfse></CompNfse>`, nil, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parser.ParseCancellationEvent(tt.xml)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseCancellationEvent() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.notEvent || tt.wantErr {
+				if got != nil {
+					t.Errorf("ParseCancellationEvent() = %+v, want nil", got)
+				}
+				return
+			}
+			if got == nil || *got != *tt.want {
+				t.Errorf("ParseCancellationEvent() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyCancellationEvent(t *testing.T) {
+	databasetest.Require(t)
+	ctx := context.Background()
+	manager := NewNFSeXMLManager()
+	company := databasetest.CreateCompany(t, nil)
+	event := cancellationEventXML("procEventoNFe", "110111", "135")
+
+	// The note is not stored yet, so the event is kept for retry
+	result, handled := manager.applyCancellationEvent(ctx, company.ID, event)
+	if !handled || !errors.Is(result.Error, ErrCancelledDocumentNotFound) || !result.Retryable() {
+		t.Fatalf("applyCancellationEvent() before the note = %+v, %v; want a retryable not found error", result, handled)
+	}
+
+	document := databasetest.CreateDocument(t, &models.Document{CompanyID: company.ID, Type: models.DocumentTypeNFe, Key: testNFeAccessKey, Number: "123"})
+	result, handled = manager.applyCancellationEvent(ctx, company.ID, event)
+	if !handled || result.Error != nil || !result.Cancelled || result.DocumentID != document.ID {
+		t.Fatalf("applyCancellationEvent() = %+v, %v; want document %d cancelled", result, handled, document.ID)
+	}
+
+	stored := &models.Document{}
+	if err := database.DB.NewSelect().Model(stored).Column("is_cancelled").Where("id = ?", document.ID).Scan(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !stored.IsCancelled {
+		t.Error("document is not flagged as cancelled")
+	}
+}
@@ -0,0 +1,103 @@
+package services
+
+import (
+	"crypto/tls"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/zoomxml/config"
+	"github.com/zoomxml/internal/logger"
+)
+
+var (
+	sharedTransport     *http.Transport
+	sharedTransportOnce sync.Once
+)
+
+// SharedTransport returns the process-wide HTTP transport used for calls to external
+// providers, tuned from HTTPClientConfig so connections stay bounded under concurrency
+func SharedTransport() *http.Transport {
+	sharedTransportOnce.Do(func() {
+		sharedTransport = NewTransport(config.Get().HTTPClient)
+	})
+	return sharedTransport
+}
+
+// NewTransport builds an HTTP transport from the given settings
+func NewTransport(cfg config.HTTPClientConfig) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = cfg.MaxIdleConns
+	transport.MaxIdleConnsPerHost = cfg.MaxIdleConnsPerHost
+	transport.MaxConnsPerHost = cfg.MaxConnsPerHost
+	transport.IdleConnTimeout = cfg.IdleConnTimeout
+	transport.TLSClientConfig = newTLSConfig(cfg)
+	return transport
+}
+
+// defaultCipherSuites are the TLS 1.2 suites used when none are configured: forward
+// secret key exchange with AEAD ciphers only
+var defaultCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+}
+
+// newTLSConfig builds the outbound TLS policy. The minimum version never drops below
+// TLS 1.2, so a misconfigured value cannot downgrade connections to providers.
+func newTLSConfig(cfg config.HTTPClientConfig) *tls.Config {
+	minVersion := uint16(tls.VersionTLS12)
+	switch cfg.TLSMinVersion {
+	case "1.2", "":
+	case "1.3":
+		minVersion = tls.VersionTLS13
+	default:
+		logger.WarnWithFields("Unsupported minimum TLS version, using TLS 1.2", map[string]any{
+			"operation": "http_transport",
+			"version":   cfg.TLSMinVersion,
+		})
+	}
+
+	return &tls.Config{
+		MinVersion:   minVersion,
+		CipherSuites: cipherSuites(cfg.TLSCipherSuites),
+	}
+}
+
+// cipherSuites resolves configured suite names against Go's secure suites. Unknown or
+// insecure names are ignored; if none is left the defaults are used.
+func cipherSuites(names []string) []uint16 {
+	secure := make(map[string]uint16)
+	for _, suite := range tls.CipherSuites() {
+		secure[suite.Name] = suite.ID
+	}
+
+	suites := []uint16{}
+	for _, name := range names {
+		id, ok := secure[name]
+		if !ok {
+			logger.WarnWithFields("Ignoring unknown or insecure TLS cipher suite", map[string]any{
+				"operation": "http_transport",
+				"suite":     name,
+			})
+			continue
+		}
+		suites = append(suites, id)
+	}
+
+	if len(suites) == 0 {
+		return defaultCipherSuites
+	}
+	return suites
+}
+
+// newHTTPClient creates a client with the given timeout on top of the transport
+func newHTTPClient(transport http.RoundTripper, timeout time.Duration) *http.Client {
+	return &http.Client{
+		Transport: transport,
+		Timeout:   timeout,
+	}
+}
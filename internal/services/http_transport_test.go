@@ -0,0 +1,111 @@
+package services
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/zoomxml/config"
+	"github.com/zoomxml/internal/repository/repositorytest"
+)
+
+func TestNewTransport(t *testing.T) {
+	cfg := config.HTTPClientConfig{
+		MaxIdleConns:        50,
+		MaxIdleConnsPerHost: 5,
+		MaxConnsPerHost:     8,
+		IdleConnTimeout:     45 * time.Second,
+	}
+
+	transport := NewTransport(cfg)
+	if transport.MaxIdleConns != 50 || transport.MaxIdleConnsPerHost != 5 || transport.MaxConnsPerHost != 8 || transport.IdleConnTimeout != 45*time.Second {
+		t.Errorf("NewTransport() = idle %d, idle per host %d, per host %d, idle timeout %v, want the configured settings",
+			transport.MaxIdleConns, transport.MaxIdleConnsPerHost, transport.MaxConnsPerHost, transport.IdleConnTimeout)
+	}
+	if transport == http.DefaultTransport {
+		t.Error("NewTransport() changed the default transport")
+	}
+}
+
+func TestNewTransportTLSPolicy(t *testing.T) {
+	tests := []struct {
+		name           string
+		minVersion     string
+		suites         []string
+		wantMinVersion uint16
+		wantSuites     []uint16
+	}{
+		{"defaults", "", nil, tls.VersionTLS12, defaultCipherSuites},
+		{"TLS 1.2", "1.2", nil, tls.VersionTLS12, defaultCipherSuites},
+		{"TLS 1.3", "1.3", nil, tls.VersionTLS13, defaultCipherSuites},
+		{"downgrade to TLS 1.0 falls back to 1.2", "1.0", nil, tls.VersionTLS12, defaultCipherSuites},
+		{"invalid version falls back to 1.2", "ssl3", nil, tls.VersionTLS12, defaultCipherSuites},
+		{"configured suites", "1.2", []string{"TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384"}, tls.VersionTLS12, []uint16{tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384}},
+		{"insecure suites are ignored", "1.2", []string{"TLS_RSA_WITH_RC4_128_SHA", "unknown"}, tls.VersionTLS12, defaultCipherSuites},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport := NewTransport(config.HTTPClientConfig{TLSMinVersion: tt.minVersion, TLSCipherSuites: tt.suites})
+			if transport.TLSClientConfig == nil {
+				t.Fatal("NewTransport() has no TLS config")
+			}
+			if got := transport.TLSClientConfig.MinVersion; got != tt.wantMinVersion {
+				t.Errorf("MinVersion = %#x, want %#x", got, tt.wantMinVersion)
+			}
+			if got := transport.TLSClientConfig.CipherSuites; !slices.Equal(got, tt.wantSuites) {
+				t.Errorf("CipherSuites = %v, want %v", got, tt.wantSuites)
+			}
+		})
+	}
+}
+
+func TestNFSeServiceUsesTransportConnectionLimit(t *testing.T) {
+	var active, peak atomic.Int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+	}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		switch state {
+		case http.StateNew:
+			n := active.Add(1)
+			for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+			}
+		case http.StateClosed, http.StateHijacked:
+			active.Add(-1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	transport := NewTransport(config.HTTPClientConfig{MaxIdleConns: 10, MaxIdleConnsPerHost: 2, MaxConnsPerHost: 2})
+	service := NewNFSeServiceWithRepositories(transport, &repositorytest.DocumentRepository{}, &repositorytest.CompanyRepository{})
+	if service.client.Transport != transport {
+		t.Fatal("NFSeService does not use the given transport")
+	}
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := service.client.Get(server.URL)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			resp.Body.Close()
+		}()
+	}
+	wg.Wait()
+
+	if got := peak.Load(); got > 2 {
+		t.Errorf("peak connections = %d, want at most MaxConnsPerHost (2)", got)
+	}
+}
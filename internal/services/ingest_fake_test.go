@@ -0,0 +1,38 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/zoomxml/config"
+)
+
+// useFakeIngest switches off the parts of ingest that still write to Postgres directly
+// (processing logs, dead letters and closed competences) and stores objects in memory,
+// so ingest runs entirely against the repositorytest fakes
+func useFakeIngest(t *testing.T) *memoryStorage {
+	t.Helper()
+	cfg := config.Get()
+	storeLogs, deadLetters, lateArrivals := cfg.Logger.StoreProcessingLogs, cfg.Storage.DeadLetterEnabled, cfg.NFSeScheduler.FlagLateArrivals
+	cfg.Logger.StoreProcessingLogs = false
+	cfg.Storage.DeadLetterEnabled = false
+	cfg.NFSeScheduler.FlagLateArrivals = false
+	t.Cleanup(func() {
+		cfg.Logger.StoreProcessingLogs = storeLogs
+		cfg.Storage.DeadLetterEnabled = deadLetters
+		cfg.NFSeScheduler.FlagLateArrivals = lateArrivals
+	})
+	return useMemoryStorage(t)
+}
+
+// testNFSeXML builds a minimal NFSe the parser accepts
+func testNFSeXML(number, verificationCode, providerCNPJ, takerCNPJ, value string) string {
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<consultarNotaResponse><ListaNfse><ComplNfse><Nfse><InfNfse>
+<Numero>%s</Numero><CodigoVerificacao>%s</CodigoVerificacao>
+<DataEmissao>2025-03-14 10:00:00</DataEmissao><Competencia>03/2025</Competencia>
+<Servico><Valores><ValorServicos>%s</ValorServicos></Valores></Servico>
+<PrestadorServico><IdentificacaoPrestador><Cnpj>%s</Cnpj></IdentificacaoPrestador></PrestadorServico>
+<TomadorServico><IdentificacaoTomador><CpfCnpj><Cnpj>%s</Cnpj></CpfCnpj></IdentificacaoTomador></TomadorServico>
+</InfNfse></Nfse></ComplNfse></ListaNfse></consultarNotaResponse>`, number, verificationCode, value, providerCNPJ, takerCNPJ)
+}
@@ -0,0 +1,236 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/zoomxml/config"
+	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/logger"
+	"github.com/zoomxml/internal/models"
+	"github.com/zoomxml/internal/storage"
+)
+
+// ErrNoIntegrityCheck is returned when a company was never checked
+var ErrNoIntegrityCheck = errors.New("company has no integrity check")
+
+// CheckCompanyIntegrity compares the NFSe rows of a company with the stored objects (keys
+// listed under nfse/) and records the result. Rows whose object is missing and objects
+// under the company CNPJ that no document of any company references count as drift.
+func CheckCompanyIntegrity(ctx context.Context, company *models.Company, objects map[string]bool, threshold int) (*models.IntegrityCheck, error) {
+	var keys []string
+	err := database.DB.NewSelect().
+		Model((*models.Document)(nil)).
+		Column("storage_key").
+		Where("company_id = ? AND type = 'nfse'", company.ID).
+		Where("storage_key IS NOT NULL AND storage_key != ''").
+		Scan(ctx, &keys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load storage keys: %w", err)
+	}
+
+	check := &models.IntegrityCheck{
+		CompanyID: company.ID,
+		Documents: len(keys),
+		Threshold: threshold,
+	}
+
+	referenced := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		if objects[key] {
+			if !referenced[key] {
+				check.Objects++
+			}
+		} else {
+			check.MissingObjects++
+		}
+		referenced[key] = true
+	}
+
+	candidates := []string{}
+	companySegment := "/" + NormalizeCNPJ(company.CNPJ) + "/"
+	for key := range objects {
+		if !referenced[key] && strings.Contains(key, companySegment) {
+			candidates = append(candidates, key)
+		}
+	}
+
+	orphans, err := unreferencedKeys(ctx, candidates)
+	if err != nil {
+		return nil, err
+	}
+	check.OrphanObjects = len(orphans)
+	check.Objects += len(orphans)
+
+	check.Drift = check.MissingObjects + check.OrphanObjects
+	check.DriftDetected = check.Drift > threshold
+
+	if _, err := database.DB.NewInsert().Model(check).Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to record integrity check: %w", err)
+	}
+
+	if check.DriftDetected {
+		logger.WarnWithFields("Storage integrity drift detected", map[string]any{
+			"operation":       "integrity_check",
+			"company_id":      company.ID,
+			"documents":       check.Documents,
+			"objects":         check.Objects,
+			"missing_objects": check.MissingObjects,
+			"orphan_objects":  check.OrphanObjects,
+			"threshold":       threshold,
+		})
+	}
+
+	return check, nil
+}
+
+// LatestIntegrityCheck loads the most recent integrity check of a company, or
+// ErrNoIntegrityCheck
+func LatestIntegrityCheck(ctx context.Context, companyID int64) (*models.IntegrityCheck, error) {
+	check := &models.IntegrityCheck{}
+	err := database.DB.NewSelect().
+		Model(check).
+		Where("company_id = ?", companyID).
+		Order("id DESC").
+		Limit(1).
+		Scan(ctx)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNoIntegrityCheck
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load integrity check: %w", err)
+	}
+	return check, nil
+}
+
+// IntegrityChecker periodically compares document rows with stored objects for every
+// active company
+type IntegrityChecker struct {
+	ticker   *time.Ticker
+	stopChan chan bool
+	cancel   context.CancelFunc
+	running  bool
+	config   *config.Config
+}
+
+// NewIntegrityChecker creates a new integrity checker
+func NewIntegrityChecker() *IntegrityChecker {
+	return &IntegrityChecker{
+		stopChan: make(chan bool),
+		config:   config.Get(),
+	}
+}
+
+// Start begins checking companies every STORAGE_INTEGRITY_CHECK_INTERVAL
+func (c *IntegrityChecker) Start() {
+	if !c.config.Storage.IntegrityCheckEnabled || c.running {
+		return
+	}
+
+	interval := c.config.Storage.IntegrityCheckInterval
+	if interval <= 0 {
+		interval = 24 * time.Hour
+	}
+
+	c.ticker = time.NewTicker(interval)
+	c.running = true
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+
+	logger.InfoWithFields("Starting storage integrity checker", map[string]any{
+		"operation": "start_integrity_checker",
+		"interval":  interval.String(),
+		"threshold": c.config.Storage.IntegrityDriftThreshold,
+	})
+
+	go c.run(ctx)
+}
+
+// Stop stops the checker, interrupting a check in progress
+func (c *IntegrityChecker) Stop() {
+	if !c.running {
+		return
+	}
+
+	c.cancel()
+	c.stopChan <- true
+	c.ticker.Stop()
+	c.running = false
+}
+
+// run is the checker loop
+func (c *IntegrityChecker) run(ctx context.Context) {
+	for {
+		select {
+		case <-c.ticker.C:
+			if err := c.CheckAllCompanies(ctx); err != nil {
+				logger.ErrorWithFields("Storage integrity check failed", err, map[string]any{
+					"operation": "integrity_check",
+				})
+			}
+		case <-c.stopChan:
+			return
+		}
+	}
+}
+
+// CheckAllCompanies lists the stored objects once and checks every active company
+// against them
+func (c *IntegrityChecker) CheckAllCompanies(ctx context.Context) error {
+	companies := []models.Company{}
+	err := database.DB.NewSelect().
+		Model(&companies).
+		Column("id", "cnpj").
+		Where("active = true").
+		Order("id ASC").
+		Scan(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load companies: %w", err)
+	}
+
+	keys, err := storage.Storage.ListFiles(ctx, nfseBucket, "nfse/")
+	if err != nil {
+		return fmt.Errorf("failed to list stored objects: %w", err)
+	}
+	objects := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		if !isDANFSEKey(key) {
+			objects[key] = true
+		}
+	}
+
+	drifting := 0
+	for i := range companies {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		check, err := CheckCompanyIntegrity(ctx, &companies[i], objects, c.config.Storage.IntegrityDriftThreshold)
+		if err != nil {
+			logger.WarnWithFields("Failed to check company storage integrity", map[string]any{
+				"operation":  "integrity_check",
+				"company_id": companies[i].ID,
+				"error":      err.Error(),
+			})
+			continue
+		}
+		if check.DriftDetected {
+			drifting++
+		}
+	}
+
+	logger.InfoWithFields("Storage integrity check completed", map[string]any{
+		"operation": "integrity_check",
+		"companies": len(companies),
+		"objects":   len(objects),
+		"drifting":  drifting,
+	})
+
+	return nil
+}
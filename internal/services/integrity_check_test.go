@@ -0,0 +1,70 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/zoomxml/internal/database/databasetest"
+	"github.com/zoomxml/internal/models"
+)
+
+func TestCheckCompanyIntegrity(t *testing.T) {
+	databasetest.Require(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name        string
+		extraObject bool // an object under the company CNPJ no document references
+		dropObject  bool // the object of the second document is missing
+		threshold   int
+		want        models.IntegrityCheck
+	}{
+		{"matching", false, false, 0, models.IntegrityCheck{Documents: 2, Objects: 2}},
+		{"drift within threshold", true, false, 1, models.IntegrityCheck{Documents: 2, Objects: 3, OrphanObjects: 1, Drift: 1, Threshold: 1}},
+		{"drifting", true, true, 1, models.IntegrityCheck{Documents: 2, Objects: 2, MissingObjects: 1, OrphanObjects: 1, Drift: 2, Threshold: 1, DriftDetected: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			company := databasetest.CreateCompany(t, nil)
+			prefix := "nfse/2025/012025/" + company.CNPJ + "/"
+			first := databasetest.CreateDocument(t, &models.Document{CompanyID: company.ID, StorageKey: prefix + "1.xml"})
+			second := databasetest.CreateDocument(t, &models.Document{CompanyID: company.ID, StorageKey: prefix + "2.xml"})
+
+			objects := map[string]bool{
+				first.StorageKey:                        true,
+				second.StorageKey:                       true,
+				"nfse/2025/012025/99999999000199/1.xml": true, // another company's object
+			}
+			if tt.extraObject {
+				objects[prefix+"orphan.xml"] = true
+			}
+			if tt.dropObject {
+				delete(objects, second.StorageKey)
+			}
+
+			check, err := CheckCompanyIntegrity(ctx, company, objects, tt.threshold)
+			if err != nil {
+				t.Fatalf("CheckCompanyIntegrity() error = %v", err)
+			}
+
+			got := models.IntegrityCheck{
+				Documents:      check.Documents,
+				Objects:        check.Objects,
+				MissingObjects: check.MissingObjects,
+				OrphanObjects:  check.OrphanObjects,
+				Drift:          check.Drift,
+				Threshold:      check.Threshold,
+				DriftDetected:  check.DriftDetected,
+			}
+			if got != tt.want {
+				t.Errorf("CheckCompanyIntegrity() = %+v, want %+v", got, tt.want)
+			}
+
+			latest, err := LatestIntegrityCheck(ctx, company.ID)
+			if err != nil || latest.ID != check.ID {
+				t.Errorf("LatestIntegrityCheck() = %v, %v, want check %d", latest, err, check.ID)
+			}
+		})
+	}
+}
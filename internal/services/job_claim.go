@@ -0,0 +1,123 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/zoomxml/internal/database"
+)
+
+// jobLease is how long a claimed row stays reserved for the worker advancing it. A row
+// whose worker died mid-chunk is claimed again once its lease expires.
+const jobLease = 15 * time.Minute
+
+// errJobLeaseLost is returned when saving the progress of a job whose lease expired and
+// was taken by another worker; that worker redoes the chunk
+var errJobLeaseLost = errors.New("job lease expired before its progress was saved")
+
+// claimedJob is a row of a background job table (export jobs, reprocess batches)
+type claimedJob interface {
+	JobID() int64
+}
+
+// runClaimedJobs advances every active row of a job table once, on up to concurrency
+// goroutines. Each row is claimed by setting its locked_until lease in a single statement
+// whose SELECT ... FOR UPDATE SKIP LOCKED skips rows being claimed by another instance;
+// the claim commits before advance runs, so no row lock is held during its I/O, and rows
+// with an unexpired lease are left alone. The lease is released once advance returns.
+// The claimed row carries its lease in LockedUntil, and advance saves progress only while
+// the row still holds it. A failed advance is handed to onError.
+func runClaimedJobs[T any, PT interface {
+	*T
+	claimedJob
+}](ctx context.Context, concurrency int, statuses []string, advance func(ctx context.Context, job PT) error, onError func(job PT, err error)) error {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var (
+		mu       sync.Mutex
+		seen     []int64 // rows already advanced in this round, by any goroutine
+		claimErr error
+		wg       sync.WaitGroup
+	)
+
+	claimNext := func() (bool, error) {
+		mu.Lock()
+		exclude := append([]int64(nil), seen...)
+		mu.Unlock()
+
+		now := time.Now()
+		lease := now.Add(jobLease).Truncate(time.Microsecond)
+
+		next := database.DB.NewSelect().
+			Model(PT(nil)).
+			Column("id").
+			Where("status IN (?)", bun.In(statuses)).
+			Where("locked_until IS NULL OR locked_until < ?", now).
+			Order("id ASC").
+			Limit(1).
+			For("UPDATE SKIP LOCKED")
+		if len(exclude) > 0 {
+			next = next.Where("id NOT IN (?)", bun.In(exclude))
+		}
+
+		job := PT(new(T))
+		err := database.DB.NewUpdate().
+			Model(job).
+			Set("locked_until = ?", lease).
+			Where("id = (?)", next).
+			Returning("*").
+			Scan(ctx)
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+
+		mu.Lock()
+		seen = append(seen, job.JobID())
+		mu.Unlock()
+
+		// An advance error is only reported; claim and release failures stop the round
+		if err := advance(ctx, job); err != nil {
+			onError(job, err)
+		}
+
+		// A lease that expired and was claimed by another worker is not ours to release
+		_, err = database.DB.NewUpdate().
+			Model(PT(nil)).
+			Set("locked_until = NULL").
+			Where("id = ?", job.JobID()).
+			Where("locked_until = ?", lease).
+			Exec(ctx)
+		return true, err
+	}
+
+	for range concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				claimed, err := claimNext()
+				if err != nil {
+					mu.Lock()
+					claimErr = err
+					mu.Unlock()
+					return
+				}
+				if !claimed {
+					return
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+	return claimErr
+}
@@ -0,0 +1,166 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/database/databasetest"
+	"github.com/zoomxml/internal/models"
+)
+
+// createReprocessBatch inserts a batch of company in status, leased until lockedUntil when set
+func createReprocessBatch(t *testing.T, companyID int64, status string, lockedUntil time.Time) *models.ReprocessBatch {
+	t.Helper()
+	batch := &models.ReprocessBatch{CompanyID: companyID, Status: status, LockedUntil: lockedUntil}
+	if _, err := database.DB.NewInsert().Model(batch).Exec(context.Background()); err != nil {
+		t.Fatalf("failed to create reprocess batch: %v", err)
+	}
+	return batch
+}
+
+// jobRecorder counts the advances of the batches of one company and records any batch
+// advanced by two goroutines at the same time
+type jobRecorder struct {
+	companyID int64
+	failID    int64
+
+	mu       sync.Mutex
+	active   map[int64]bool
+	advanced map[int64]int
+	failed   []int64
+	overlaps []int64
+}
+
+func newJobRecorder(companyID int64) *jobRecorder {
+	return &jobRecorder{companyID: companyID, active: map[int64]bool{}, advanced: map[int64]int{}}
+}
+
+func (r *jobRecorder) advance(ctx context.Context, batch *models.ReprocessBatch) error {
+	// Batches left by other tests are claimed too, and left alone
+	if batch.CompanyID != r.companyID {
+		return nil
+	}
+	r.mu.Lock()
+	if r.active[batch.ID] {
+		r.overlaps = append(r.overlaps, batch.ID)
+	}
+	r.active[batch.ID] = true
+	r.mu.Unlock()
+
+	time.Sleep(20 * time.Millisecond)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.active[batch.ID] = false
+	r.advanced[batch.ID]++
+	if batch.ID == r.failID {
+		return errors.New("chunk failed")
+	}
+	return nil
+}
+
+func (r *jobRecorder) onError(batch *models.ReprocessBatch, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failed = append(r.failed, batch.ID)
+}
+
+func TestRunClaimedJobs(t *testing.T) {
+	databasetest.Require(t)
+	ctx := context.Background()
+	company := databasetest.CreateCompany(t, nil)
+
+	pending := []*models.ReprocessBatch{}
+	for range 4 {
+		pending = append(pending, createReprocessBatch(t, company.ID, models.ReprocessStatusPending, time.Time{}))
+	}
+	running := createReprocessBatch(t, company.ID, models.ReprocessStatusRunning, time.Time{})
+	expired := createReprocessBatch(t, company.ID, models.ReprocessStatusPending, time.Now().Add(-time.Minute))
+	leased := createReprocessBatch(t, company.ID, models.ReprocessStatusPending, time.Now().Add(time.Hour))
+	completed := createReprocessBatch(t, company.ID, models.ReprocessStatusCompleted, time.Time{})
+
+	recorder := newJobRecorder(company.ID)
+	recorder.failID = pending[0].ID
+	if err := runClaimedJobs(ctx, 3, activeReprocessStatuses, recorder.advance, recorder.onError); err != nil {
+		t.Fatalf("runClaimedJobs() error = %v", err)
+	}
+
+	want := map[int64]int{running.ID: 1, expired.ID: 1}
+	for _, batch := range pending {
+		want[batch.ID] = 1
+	}
+	for id, count := range want {
+		if recorder.advanced[id] != count {
+			t.Errorf("batch %d advanced %d times, want %d", id, recorder.advanced[id], count)
+		}
+	}
+	for _, batch := range []*models.ReprocessBatch{leased, completed} {
+		if recorder.advanced[batch.ID] != 0 {
+			t.Errorf("batch %d (%s) advanced %d times, want it left alone", batch.ID, batch.Status, recorder.advanced[batch.ID])
+		}
+	}
+	if len(recorder.overlaps) != 0 {
+		t.Errorf("batches %v were advanced by two goroutines at once", recorder.overlaps)
+	}
+	if len(recorder.failed) != 1 || recorder.failed[0] != pending[0].ID {
+		t.Errorf("onError received %v, want %d", recorder.failed, pending[0].ID)
+	}
+
+	// Leases are released, failed advances included; the one taken elsewhere is kept
+	var stillLeased []int64
+	err := database.DB.NewSelect().
+		Model((*models.ReprocessBatch)(nil)).
+		Column("id").
+		Where("company_id = ? AND locked_until IS NOT NULL", company.ID).
+		Scan(ctx, &stillLeased)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stillLeased) != 1 || stillLeased[0] != leased.ID {
+		t.Errorf("leased batches = %v, want only %d", stillLeased, leased.ID)
+	}
+}
+
+// TestRunClaimedJobsAcrossInstances runs two rounds at once, as two service instances
+// would, and checks that no batch is advanced by both at the same time
+func TestRunClaimedJobsAcrossInstances(t *testing.T) {
+	databasetest.Require(t)
+	ctx := context.Background()
+	company := databasetest.CreateCompany(t, nil)
+
+	batches := []*models.ReprocessBatch{}
+	for range 6 {
+		batches = append(batches, createReprocessBatch(t, company.ID, models.ReprocessStatusPending, time.Time{}))
+	}
+
+	recorder := newJobRecorder(company.ID)
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = runClaimedJobs(ctx, 2, activeReprocessStatuses, recorder.advance, recorder.onError)
+		}()
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("runClaimedJobs() of instance %d error = %v", i, err)
+		}
+	}
+	if len(recorder.overlaps) != 0 {
+		t.Errorf("batches %v were advanced by both instances at once", recorder.overlaps)
+	}
+	// Each instance advances a batch at most once per round
+	for _, batch := range batches {
+		if count := recorder.advanced[batch.ID]; count < 1 || count > 2 {
+			t.Errorf("batch %d advanced %d times, want once per instance at most", batch.ID, count)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/database/databasetest"
+	"github.com/zoomxml/internal/models"
+	"github.com/zoomxml/internal/repository/repositorytest"
+)
+
+func TestMetadataOnlyIngest(t *testing.T) {
+	const companyCNPJ = "12345678000190"
+
+	for _, metadataOnly := range []bool{true, false} {
+		name := "XML stored"
+		if metadataOnly {
+			name = "metadata only"
+		}
+		t.Run(name, func(t *testing.T) {
+			memory := useFakeIngest(t)
+			company := &models.Company{ID: 1, CNPJ: companyCNPJ, MetadataOnly: metadataOnly}
+			documents := &repositorytest.DocumentRepository{}
+			manager := NewNFSeXMLManagerWithRepositories(documents, &repositorytest.CompanyRepository{Companies: map[int64]*models.Company{company.ID: company}})
+
+			result, err := manager.ProcessBatchXML(context.Background(), company.ID, BatchOptions{Source: ProcessingSourcePrefeituraAPI}, []XMLDocument{
+				{FileName: "4521.xml", Content: testNFSeXML("4521", "META-AAA", companyCNPJ, "", "1500.00")},
+			})
+			if err != nil || result.ProcessedDocuments != 1 {
+				t.Fatalf("ProcessBatchXML() = %+v, %v, want the note stored", result, err)
+			}
+
+			document := documents.Documents[0]
+			if document.Number != "4521" || document.VerificationCode != "META-AAA" || document.ServiceValue != 1500 {
+				t.Errorf("document = %s %s %.2f, want the parsed fields", document.Number, document.VerificationCode, document.ServiceValue)
+			}
+
+			if metadataOnly {
+				if len(memory.objects) != 0 || document.StorageKey != "" || document.Metadata != "" {
+					t.Errorf("uploaded %d objects, storage key %q, metadata %d bytes, want no XML kept",
+						len(memory.objects), document.StorageKey, len(document.Metadata))
+				}
+				return
+			}
+			if _, ok := memory.objects[document.StorageKey]; !ok || document.StorageKey == "" {
+				t.Errorf("object %q was not uploaded", document.StorageKey)
+			}
+		})
+	}
+}
+
+func TestReadMetadataOnlyDocument(t *testing.T) {
+	databasetest.Require(t)
+	ctx := context.Background()
+	useMemoryStorage(t)
+
+	company := databasetest.CreateCompany(t, func(c *models.Company) { c.MetadataOnly = true })
+	document := databasetest.CreateDocument(t, &models.Document{CompanyID: company.ID, Number: "4521", VerificationCode: "META-AAA", ServiceValue: 1500})
+
+	// The parsed fields are still searchable
+	found := &models.Document{}
+	err := database.DB.NewSelect().Model(found).
+		Where("company_id = ? AND verification_code = ?", company.ID, "META-AAA").
+		Scan(ctx)
+	if err != nil || found.ID != document.ID || found.ServiceValue != 1500 {
+		t.Errorf("document by verification code = %d, %v, want %d", found.ID, err, document.ID)
+	}
+
+	if _, _, err := ReadDocumentXML(ctx, company.ID, document.ID); !errors.Is(err, ErrXMLNotStored) {
+		t.Errorf("ReadDocumentXML() error = %v, want %v", err, ErrXMLNotStored)
+	}
+}
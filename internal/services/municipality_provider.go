@@ -0,0 +1,124 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/zoomxml/config"
+	"github.com/zoomxml/internal/models"
+	"github.com/zoomxml/internal/repository"
+)
+
+// ErrUnsupportedMunicipality is returned for municipality codes without a registered provider
+var ErrUnsupportedMunicipality = errors.New("no NFSe provider registered for municipality")
+
+// MunicipalityProvider is the integration with the NFSe API of a municipality. Each city
+// integration is a separate implementation registered under the IBGE codes it serves.
+type MunicipalityProvider interface {
+	// Capabilities describes what the provider supports
+	Capabilities() ProviderCapabilities
+	// Authenticate returns the authorization sent with the requests made for a credential
+	Authenticate(ctx context.Context, credential *models.CompanyCredential) (string, error)
+	// FetchDocuments queries one page of notes and hands the XMLs of each provider record
+	// to handle as soon as they are extracted
+	FetchDocuments(ctx context.Context, request ProviderFetchRequest, handle func([]NFSeDocument) error) (*NFSeProcessResult, error)
+}
+
+// ProviderCapabilities describes a municipality provider
+type ProviderCapabilities struct {
+	Name               string   `json:"name"`
+	CredentialTypes    []string `json:"credential_types"`    // credential types Authenticate accepts
+	Paginated          bool     `json:"paginated"`           // FetchDocuments honors ProviderFetchRequest.Page
+	ProvidesCompetence bool     `json:"provides_competence"` // records carry the competência besides the XML
+}
+
+// ProviderFetchRequest is one page query to a municipality provider
+type ProviderFetchRequest struct {
+	Credential    *models.CompanyCredential
+	Authorization string // returned by Authenticate
+	StartDate     time.Time
+	EndDate       time.Time
+	Page          int
+}
+
+// MunicipalityProviderFactory builds a provider that sends its requests through client
+type MunicipalityProviderFactory func(client *http.Client) MunicipalityProvider
+
+var (
+	municipalityProvidersMu sync.RWMutex
+	municipalityProviders   = map[string]MunicipalityProviderFactory{
+		"2105302": newPrefeituraModernaProvider, // Imperatriz - MA
+	}
+)
+
+// RegisterMunicipalityProvider makes a provider available to companies of the municipality
+// with the given IBGE code, replacing any provider already registered for it
+func RegisterMunicipalityProvider(ibgeCode string, factory MunicipalityProviderFactory) {
+	municipalityProvidersMu.Lock()
+	defer municipalityProvidersMu.Unlock()
+	municipalityProviders[ibgeCode] = factory
+}
+
+// MunicipalityCodes returns the IBGE codes with a registered provider, sorted
+func MunicipalityCodes() []string {
+	municipalityProvidersMu.RLock()
+	defer municipalityProvidersMu.RUnlock()
+
+	codes := make([]string, 0, len(municipalityProviders))
+	for code := range municipalityProviders {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// ValidateMunicipalityCode checks that a provider is registered for an IBGE code
+func ValidateMunicipalityCode(ibgeCode string) error {
+	municipalityProvidersMu.RLock()
+	_, ok := municipalityProviders[ibgeCode]
+	municipalityProvidersMu.RUnlock()
+
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnsupportedMunicipality, ibgeCode)
+	}
+	return nil
+}
+
+// resolveMunicipalityProvider builds the provider of a company: the one registered for
+// its municipality code when set, for NFSE_DEFAULT_MUNICIPALITY otherwise
+func resolveMunicipalityProvider(ctx context.Context, companies repository.CompanyRepository, companyID int64, client *http.Client) (MunicipalityProvider, error) {
+	company, err := companies.FindCompany(ctx, companyID, "municipality_code")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load company municipality: %w", err)
+	}
+
+	code := company.MunicipalityCode
+	if code == "" {
+		code = config.Get().NFSeScheduler.DefaultMunicipality
+	}
+
+	municipalityProvidersMu.RLock()
+	factory, ok := municipalityProviders[code]
+	municipalityProvidersMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedMunicipality, code)
+	}
+	return factory(client), nil
+}
+
+// authenticateProvider checks that the provider accepts the credential type before
+// asking it for the authorization of the credential
+func authenticateProvider(ctx context.Context, provider MunicipalityProvider, credential *models.CompanyCredential) (string, error) {
+	capabilities := provider.Capabilities()
+	if !slices.Contains(capabilities.CredentialTypes, credential.Type) {
+		return "", fmt.Errorf("provider %s does not accept %s credentials", capabilities.Name, credential.Type)
+	}
+	return provider.Authenticate(ctx, credential)
+}
@@ -3,11 +3,14 @@ package services
 import (
 	"context"
 	"fmt"
+	"sort"
 	"time"
 
+	"github.com/zoomxml/config"
 	"github.com/zoomxml/internal/database"
 	"github.com/zoomxml/internal/logger"
 	"github.com/zoomxml/internal/models"
+	"github.com/zoomxml/internal/repository"
 )
 
 // DuplicateCheckResult represents the result of duplicate detection
@@ -19,11 +22,18 @@ type DuplicateCheckResult struct {
 }
 
 // NFSeDeduplicator handles intelligent duplicate detection for NFSe documents
-type NFSeDeduplicator struct{}
+type NFSeDeduplicator struct {
+	documents repository.DocumentRepository
+}
 
 // NewNFSeDeduplicator creates a new NFSe deduplicator instance
 func NewNFSeDeduplicator() *NFSeDeduplicator {
-	return &NFSeDeduplicator{}
+	return NewNFSeDeduplicatorWithRepository(repository.NewDocumentRepository())
+}
+
+// NewNFSeDeduplicatorWithRepository creates a deduplicator that reads documents from the given repository
+func NewNFSeDeduplicatorWithRepository(documents repository.DocumentRepository) *NFSeDeduplicator {
+	return &NFSeDeduplicator{documents: documents}
 }
 
 // CheckForDuplicates performs comprehensive duplicate detection using multiple strategies
@@ -36,6 +46,40 @@ func (d *NFSeDeduplicator) CheckForDuplicates(ctx context.Context, companyID int
 		"provider_cnpj":     parsedData.ProviderCNPJ,
 	})
 
+	// Strategy 0: Exact check by raw content hash (byte-identical XML)
+	if parsedData.ContentHash != "" && config.Get().NFSeScheduler.DedupContentHash {
+		result, err := d.checkByContentHash(ctx, companyID, parsedData.ContentHash)
+		if err != nil {
+			return nil, err
+		}
+		if result.IsDuplicate {
+			logger.InfoWithFields("Duplicate found by content hash", map[string]any{
+				"operation":    "check_duplicates",
+				"company_id":   companyID,
+				"content_hash": parsedData.ContentHash,
+				"existing_id":  result.ExistingDocument.ID,
+			})
+			return result, nil
+		}
+	}
+
+	// Strategy 1a: NF-e and CT-e are identified by their access key (chave de acesso)
+	if parsedData.AccessKey != "" {
+		result, err := d.checkByAccessKey(ctx, companyID, parsedData)
+		if err != nil {
+			return nil, err
+		}
+		if result.IsDuplicate {
+			logger.InfoWithFields("Duplicate found by access key", map[string]any{
+				"operation":   "check_duplicates",
+				"company_id":  companyID,
+				"access_key":  parsedData.AccessKey,
+				"existing_id": result.ExistingDocument.ID,
+			})
+			return result, nil
+		}
+	}
+
 	// Strategy 1: Primary check by verification code (most reliable)
 	if parsedData.VerificationCode != "" {
 		result, err := d.checkByVerificationCode(ctx, companyID, parsedData.VerificationCode)
@@ -101,6 +145,69 @@ func (d *NFSeDeduplicator) CheckForDuplicates(ctx context.Context, companyID int
 	}, nil
 }
 
+// checkByContentHash checks for byte-identical documents using the raw content hash
+func (d *NFSeDeduplicator) checkByContentHash(ctx context.Context, companyID int64, contentHash string) (*DuplicateCheckResult, error) {
+	var existingDoc models.Document
+
+	err := database.DB.NewSelect().
+		Model(&existingDoc).
+		Where("company_id = ? AND content_hash = ?", companyID, contentHash).
+		WhereAllWithDeleted().
+		OrderExpr("deleted_at IS NOT NULL, id").
+		Limit(1).
+		Scan(ctx)
+
+	if err != nil {
+		if err.Error() == "sql: no rows in result set" {
+			return &DuplicateCheckResult{
+				IsDuplicate: false,
+				CheckMethod: "content_hash",
+				Reason:      "no matching content hash",
+			}, nil
+		}
+		return nil, fmt.Errorf("failed to check content hash: %v", err)
+	}
+
+	return &DuplicateCheckResult{
+		IsDuplicate:      true,
+		ExistingDocument: &existingDoc,
+		CheckMethod:      "content_hash",
+		Reason:           fmt.Sprintf("byte-identical content: %s", contentHash),
+	}, nil
+}
+
+// checkByAccessKey checks for NF-e or CT-e duplicates using the access key, which is
+// stored in the key column
+func (d *NFSeDeduplicator) checkByAccessKey(ctx context.Context, companyID int64, parsedData *ParsedNFSeData) (*DuplicateCheckResult, error) {
+	var existingDoc models.Document
+
+	err := database.DB.NewSelect().
+		Model(&existingDoc).
+		Where("company_id = ? AND type = ? AND key = ?", companyID, documentTypeOf(parsedData), parsedData.AccessKey).
+		WhereAllWithDeleted().
+		OrderExpr("deleted_at IS NOT NULL, id").
+		Limit(1).
+		Scan(ctx)
+
+	if err != nil {
+		if err.Error() == "sql: no rows in result set" {
+			return &DuplicateCheckResult{
+				IsDuplicate: false,
+				CheckMethod: "access_key",
+				Reason:      "no matching access key",
+			}, nil
+		}
+		return nil, fmt.Errorf("failed to check access key: %v", err)
+	}
+
+	return &DuplicateCheckResult{
+		IsDuplicate:      true,
+		ExistingDocument: &existingDoc,
+		CheckMethod:      "access_key",
+		Reason:           fmt.Sprintf("matching access key: %s", parsedData.AccessKey),
+	}, nil
+}
+
 // checkByVerificationCode checks for duplicates using verification code (primary key)
 func (d *NFSeDeduplicator) checkByVerificationCode(ctx context.Context, companyID int64, verificationCode string) (*DuplicateCheckResult, error) {
 	var existingDoc models.Document
@@ -108,6 +215,8 @@ func (d *NFSeDeduplicator) checkByVerificationCode(ctx context.Context, companyI
 	err := database.DB.NewSelect().
 		Model(&existingDoc).
 		Where("company_id = ? AND verification_code = ? AND verification_code != ''", companyID, verificationCode).
+		WhereAllWithDeleted().
+		OrderExpr("deleted_at IS NOT NULL, id").
 		Scan(ctx)
 
 	if err != nil {
@@ -129,7 +238,8 @@ func (d *NFSeDeduplicator) checkByVerificationCode(ctx context.Context, companyI
 	}, nil
 }
 
-// checkByCompositeKey checks for duplicates using NFSe number + provider CNPJ + issue date
+// checkByCompositeKey checks for duplicates using document type + number + series + provider
+// CNPJ + issue date. Notes without a series only match rows without one.
 func (d *NFSeDeduplicator) checkByCompositeKey(ctx context.Context, companyID int64, parsedData *ParsedNFSeData) (*DuplicateCheckResult, error) {
 	var existingDoc models.Document
 	
@@ -138,8 +248,10 @@ func (d *NFSeDeduplicator) checkByCompositeKey(ctx context.Context, companyID in
 	
 	err := database.DB.NewSelect().
 		Model(&existingDoc).
-		Where("company_id = ? AND number = ? AND provider_cnpj = ? AND DATE(issue_date) = ?", 
-			companyID, parsedData.Number, parsedData.ProviderCNPJ, issueDate).
+		Where("company_id = ? AND type = ? AND number = ? AND COALESCE(series, '') = ? AND provider_cnpj = ? AND DATE(issue_date) = ?", 
+			companyID, documentTypeOf(parsedData), parsedData.Number, parsedData.Series, parsedData.ProviderCNPJ, issueDate).
+		WhereAllWithDeleted().
+		OrderExpr("deleted_at IS NOT NULL, id").
 		Scan(ctx)
 
 	if err != nil {
@@ -168,6 +280,8 @@ func (d *NFSeDeduplicator) checkByDocumentHash(ctx context.Context, companyID in
 	err := database.DB.NewSelect().
 		Model(&existingDoc).
 		Where("company_id = ? AND document_hash = ? AND document_hash != ''", companyID, documentHash).
+		WhereAllWithDeleted().
+		OrderExpr("deleted_at IS NOT NULL, id").
 		Scan(ctx)
 
 	if err != nil {
@@ -207,8 +321,17 @@ func (d *NFSeDeduplicator) BatchCheckForDuplicates(ctx context.Context, companyI
 	verificationCodes := make([]string, 0, len(parsedDataList))
 	numbers := make([]string, 0, len(parsedDataList))
 	documentHashes := make([]string, 0, len(parsedDataList))
+	contentHashes := make([]string, 0, len(parsedDataList))
+	accessKeys := make([]string, 0, len(parsedDataList))
+	checkContentHash := config.Get().NFSeScheduler.DedupContentHash
 
 	for _, data := range parsedDataList {
+		if checkContentHash && data.ContentHash != "" {
+			contentHashes = append(contentHashes, data.ContentHash)
+		}
+		if data.AccessKey != "" {
+			accessKeys = append(accessKeys, data.AccessKey)
+		}
 		if data.VerificationCode != "" {
 			verificationCodes = append(verificationCodes, data.VerificationCode)
 		}
@@ -221,23 +344,8 @@ func (d *NFSeDeduplicator) BatchCheckForDuplicates(ctx context.Context, companyI
 	}
 
 	// Batch query for existing documents
-	var existingDocs []models.Document
-	query := database.DB.NewSelect().
-		Model(&existingDocs).
-		Where("company_id = ?", companyID)
-
-	if len(verificationCodes) > 0 {
-		query = query.WhereOr("verification_code IN (?)", verificationCodes)
-	}
-	if len(numbers) > 0 {
-		query = query.WhereOr("number IN (?)", numbers)
-	}
-	if len(documentHashes) > 0 {
-		query = query.WhereOr("document_hash IN (?)", documentHashes)
-	}
-
-	err := query.Scan(ctx)
-	if err != nil && err.Error() != "sql: no rows in result set" {
+	existingDocs, err := d.documents.FindDuplicateCandidates(ctx, companyID, accessKeys, verificationCodes, numbers, documentHashes, contentHashes)
+	if err != nil {
 		return nil, fmt.Errorf("failed to batch check duplicates: %v", err)
 	}
 
@@ -245,14 +353,27 @@ func (d *NFSeDeduplicator) BatchCheckForDuplicates(ctx context.Context, companyI
 	verificationCodeMap := make(map[string]*models.Document)
 	compositeKeyMap := make(map[string]*models.Document)
 	documentHashMap := make(map[string]*models.Document)
+	contentHashMap := make(map[string]*models.Document)
+	accessKeyMap := make(map[string]*models.Document)
+
+	// Live documents come first, so a deleted one only answers when no live one matches
+	sort.SliceStable(existingDocs, func(i, j int) bool {
+		return !existingDocs[i].DeletedAt.IsZero() && existingDocs[j].DeletedAt.IsZero()
+	})
 
-	for i := range existingDocs {
+	for i := len(existingDocs) - 1; i >= 0; i-- {
 		doc := &existingDocs[i]
+		if doc.ContentHash != "" {
+			contentHashMap[doc.ContentHash] = doc
+		}
+		if doc.Type != models.DocumentTypeNFSe && doc.Key != "" {
+			accessKeyMap[doc.Type+"|"+doc.Key] = doc
+		}
 		if doc.VerificationCode != "" {
 			verificationCodeMap[doc.VerificationCode] = doc
 		}
 		if doc.Number != "" && doc.ProviderCNPJ != "" {
-			compositeKey := fmt.Sprintf("%s|%s|%s", doc.Number, doc.ProviderCNPJ, doc.IssueDate.Format("2006-01-02"))
+			compositeKey := compositeDedupKey(doc.Type, doc.Number, doc.Series, doc.ProviderCNPJ, doc.IssueDate)
 			compositeKeyMap[compositeKey] = doc
 		}
 		if doc.DocumentHash != "" {
@@ -262,7 +383,33 @@ func (d *NFSeDeduplicator) BatchCheckForDuplicates(ctx context.Context, companyI
 
 	// Check each document for duplicates
 	for i, data := range parsedDataList {
-		// Check by verification code first
+		// Check by raw content hash first
+		if checkContentHash && data.ContentHash != "" {
+			if existingDoc, exists := contentHashMap[data.ContentHash]; exists {
+				results[i] = &DuplicateCheckResult{
+					IsDuplicate:      true,
+					ExistingDocument: existingDoc,
+					CheckMethod:      "content_hash",
+					Reason:           fmt.Sprintf("byte-identical content: %s", data.ContentHash),
+				}
+				continue
+			}
+		}
+
+		// Check by access key (NF-e and CT-e)
+		if data.AccessKey != "" {
+			if existingDoc, exists := accessKeyMap[documentTypeOf(data)+"|"+data.AccessKey]; exists {
+				results[i] = &DuplicateCheckResult{
+					IsDuplicate:      true,
+					ExistingDocument: existingDoc,
+					CheckMethod:      "access_key",
+					Reason:           fmt.Sprintf("matching access key: %s", data.AccessKey),
+				}
+				continue
+			}
+		}
+
+		// Check by verification code
 		if data.VerificationCode != "" {
 			if existingDoc, exists := verificationCodeMap[data.VerificationCode]; exists {
 				results[i] = &DuplicateCheckResult{
@@ -276,7 +423,7 @@ func (d *NFSeDeduplicator) BatchCheckForDuplicates(ctx context.Context, companyI
 		}
 
 		// Check by composite key
-		compositeKey := fmt.Sprintf("%s|%s|%s", data.Number, data.ProviderCNPJ, data.IssueDate.Format("2006-01-02"))
+		compositeKey := compositeDedupKey(documentTypeOf(data), data.Number, data.Series, data.ProviderCNPJ, data.IssueDate)
 		if existingDoc, exists := compositeKeyMap[compositeKey]; exists {
 			results[i] = &DuplicateCheckResult{
 				IsDuplicate:      true,
@@ -325,36 +472,21 @@ func (d *NFSeDeduplicator) BatchCheckForDuplicates(ctx context.Context, companyI
 	return results, nil
 }
 
-// GetDuplicateStatistics returns statistics about duplicate detection
+// GetDuplicateStatistics returns statistics about duplicate detection for the last days
 func (d *NFSeDeduplicator) GetDuplicateStatistics(ctx context.Context, companyID int64, days int) (map[string]any, error) {
-	since := time.Now().AddDate(0, 0, -days)
-	
-	var stats struct {
-		TotalDocuments    int64 `bun:"total_documents"`
-		UniqueDocuments   int64 `bun:"unique_documents"`
-		CancelledDocuments int64 `bun:"cancelled_documents"`
-		SubstitutedDocuments int64 `bun:"substituted_documents"`
-	}
-
-	err := database.DB.NewSelect().
-		Model((*models.Document)(nil)).
-		ColumnExpr("COUNT(*) as total_documents").
-		ColumnExpr("COUNT(DISTINCT verification_code) as unique_documents").
-		ColumnExpr("COUNT(*) FILTER (WHERE is_cancelled = true) as cancelled_documents").
-		ColumnExpr("COUNT(*) FILTER (WHERE is_substituted = true) as substituted_documents").
-		Where("company_id = ? AND type = 'nfse' AND created_at >= ?", companyID, since).
-		Scan(ctx, &stats)
+	return d.GetDuplicateStatisticsForWindow(ctx, companyID, DuplicateStatsWindowDays(days))
+}
 
-	if err != nil {
-		return nil, fmt.Errorf("failed to get duplicate statistics: %v", err)
+// compositeDedupKey builds the batch lookup key of a note. The series is only part of
+// the key when present, so notes without one keep their previous key, and the document
+// type prefixes it for NF-e and CT-e so their numbering never matches an NFSe.
+func compositeDedupKey(documentType, number, series, providerCNPJ string, issueDate time.Time) string {
+	key := fmt.Sprintf("%s|%s|%s", number, providerCNPJ, issueDate.Format("2006-01-02"))
+	if series != "" {
+		key = fmt.Sprintf("%s|%s|%s|%s", number, series, providerCNPJ, issueDate.Format("2006-01-02"))
 	}
-
-	return map[string]any{
-		"total_documents":      stats.TotalDocuments,
-		"unique_documents":     stats.UniqueDocuments,
-		"cancelled_documents":  stats.CancelledDocuments,
-		"substituted_documents": stats.SubstitutedDocuments,
-		"potential_duplicates": stats.TotalDocuments - stats.UniqueDocuments,
-		"period_days":          days,
-	}, nil
+	if documentType != "" && documentType != models.DocumentTypeNFSe {
+		key = documentType + "|" + key
+	}
+	return key
 }
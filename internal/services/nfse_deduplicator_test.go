@@ -0,0 +1,188 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zoomxml/config"
+	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/database/databasetest"
+	"github.com/zoomxml/internal/models"
+	"github.com/zoomxml/internal/repository/repositorytest"
+)
+
+func TestCompositeDedupKey(t *testing.T) {
+	issued := time.Date(2025, 3, 14, 15, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		documentType string
+		series       string
+		want         string
+	}{
+		{"nfse without series keeps the old key", models.DocumentTypeNFSe, "", "123|12345678000190|2025-03-14"},
+		{"untyped is nfse", "", "", "123|12345678000190|2025-03-14"},
+		{"nfse with series", models.DocumentTypeNFSe, "A1", "123|A1|12345678000190|2025-03-14"},
+		{"nf-e is prefixed", models.DocumentTypeNFe, "1", "nfe|123|1|12345678000190|2025-03-14"},
+		{"ct-e is prefixed", models.DocumentTypeCTe, "", "cte|123|12345678000190|2025-03-14"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := compositeDedupKey(tt.documentType, "123", tt.series, "12345678000190", issued); got != tt.want {
+				t.Errorf("compositeDedupKey() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckForDuplicatesByAccessKey(t *testing.T) {
+	databasetest.Require(t)
+	ctx := context.Background()
+	deduplicator := NewNFSeDeduplicator()
+	company := databasetest.CreateCompany(t, nil)
+
+	stored := databasetest.CreateDocument(t, &models.Document{CompanyID: company.ID, Type: models.DocumentTypeNFe, Key: testNFeAccessKey, Number: "123"})
+
+	tests := []struct {
+		name      string
+		parsed    *ParsedNFSeData
+		wantDup   bool
+		wantDocID int64
+	}{
+		{"same access key", &ParsedNFSeData{DocumentType: models.DocumentTypeNFe, AccessKey: testNFeAccessKey, Number: "123"}, true, stored.ID},
+		{"same key on another document type", &ParsedNFSeData{DocumentType: models.DocumentTypeCTe, AccessKey: testNFeAccessKey, Number: "123"}, false, 0},
+		{"another access key", &ParsedNFSeData{DocumentType: models.DocumentTypeNFe, AccessKey: "35250312345678000190550010000001241000001241", Number: "124"}, false, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := deduplicator.CheckForDuplicates(ctx, company.ID, tt.parsed)
+			if err != nil {
+				t.Fatalf("CheckForDuplicates() error = %v", err)
+			}
+			if result.IsDuplicate != tt.wantDup {
+				t.Fatalf("CheckForDuplicates() duplicate = %v (%s), want %v", result.IsDuplicate, result.Reason, tt.wantDup)
+			}
+			if tt.wantDup && result.ExistingDocument.ID != tt.wantDocID {
+				t.Errorf("CheckForDuplicates() matched document %d, want %d", result.ExistingDocument.ID, tt.wantDocID)
+			}
+		})
+	}
+
+	// A soft-deleted note still matches, so it is not ingested again
+	if _, err := database.DB.NewDelete().Model((*models.Document)(nil)).Where("id = ?", stored.ID).Exec(ctx); err != nil {
+		t.Fatal(err)
+	}
+	result, err := deduplicator.CheckForDuplicates(ctx, company.ID, tests[0].parsed)
+	if err != nil {
+		t.Fatalf("CheckForDuplicates() error = %v", err)
+	}
+	if !result.IsDuplicate || result.ExistingDocument.DeletedAt.IsZero() {
+		t.Errorf("CheckForDuplicates() after delete = %+v, want the deleted document", result)
+	}
+}
+
+func TestParseXMLContentHash(t *testing.T) {
+	parser := NewNFSeParser()
+	xmlContent := testNFSeXML("4521", "HASH-AAA", "12345678000190", "", "1500.00")
+	// Same fields, different bytes
+	reformatted := strings.ReplaceAll(xmlContent, "><", ">\n<")
+
+	parsed, err := parser.ParseXML(xmlContent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := parser.ParseXML(xmlContent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := parser.ParseXML(reformatted)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := fmt.Sprintf("%x", sha256.Sum256([]byte(xmlContent))); parsed.ContentHash != want {
+		t.Errorf("ContentHash = %s, want the SHA-256 of the raw XML %s", parsed.ContentHash, want)
+	}
+	if again.ContentHash != parsed.ContentHash {
+		t.Errorf("byte-identical XML hashed to %s and %s", parsed.ContentHash, again.ContentHash)
+	}
+	if other.ContentHash == parsed.ContentHash || other.DocumentHash != parsed.DocumentHash {
+		t.Errorf("reformatted XML content hash %s, document hash %s, want only the content hash to change", other.ContentHash, other.DocumentHash)
+	}
+}
+
+func TestBatchCheckForDuplicatesByContentHash(t *testing.T) {
+	cfg := &config.Get().NFSeScheduler
+	previous := cfg.DedupContentHash
+	t.Cleanup(func() { cfg.DedupContentHash = previous })
+
+	parser := NewNFSeParser()
+	xmlContent := testNFSeXML("4521", "HASH-AAA", "12345678000190", "", "1500.00")
+	parsed, err := parser.ParseXML(xmlContent)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The stored fields no longer match the note, so only the raw bytes can find it
+	documents := &repositorytest.DocumentRepository{}
+	documents.Add(&models.Document{CompanyID: 1, Number: "1", VerificationCode: "REPARSED", ContentHash: parsed.ContentHash})
+	deduplicator := NewNFSeDeduplicatorWithRepository(documents)
+
+	tests := []struct {
+		name             string
+		dedupContentHash bool
+		companyID        int64
+		wantDup          bool
+	}{
+		{"byte-identical content", true, 1, true},
+		{"content hash check disabled", false, 1, false},
+		{"same content in another company", true, 2, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg.DedupContentHash = tt.dedupContentHash
+			results, err := deduplicator.BatchCheckForDuplicates(context.Background(), tt.companyID, []*ParsedNFSeData{parsed})
+			if err != nil {
+				t.Fatalf("BatchCheckForDuplicates() error = %v", err)
+			}
+			result := results[0]
+			if result.IsDuplicate != tt.wantDup {
+				t.Fatalf("BatchCheckForDuplicates() duplicate = %v (%s), want %v", result.IsDuplicate, result.Reason, tt.wantDup)
+			}
+			if tt.wantDup && (result.CheckMethod != "content_hash" || result.ExistingDocument.ID != documents.Documents[0].ID) {
+				t.Errorf("BatchCheckForDuplicates() = %s on document %d, want content_hash on %d", result.CheckMethod, result.ExistingDocument.ID, documents.Documents[0].ID)
+			}
+		})
+	}
+}
+
+func TestCheckForDuplicatesByContentHash(t *testing.T) {
+	databasetest.Require(t)
+	cfg := &config.Get().NFSeScheduler
+	previous := cfg.DedupContentHash
+	cfg.DedupContentHash = true
+	t.Cleanup(func() { cfg.DedupContentHash = previous })
+	ctx := context.Background()
+
+	parsed, err := NewNFSeParser().ParseXML(testNFSeXML("4521", "HASH-AAA", "12345678000190", "", "1500.00"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	company := databasetest.CreateCompany(t, nil)
+	stored := databasetest.CreateDocument(t, &models.Document{CompanyID: company.ID, Number: "1", VerificationCode: "REPARSED", ContentHash: parsed.ContentHash})
+
+	result, err := NewNFSeDeduplicator().CheckForDuplicates(ctx, company.ID, parsed)
+	if err != nil {
+		t.Fatalf("CheckForDuplicates() error = %v", err)
+	}
+	if !result.IsDuplicate || result.CheckMethod != "content_hash" || result.ExistingDocument.ID != stored.ID {
+		t.Errorf("CheckForDuplicates() = %+v, want content_hash on document %d", result, stored.ID)
+	}
+}
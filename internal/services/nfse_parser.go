@@ -3,6 +3,7 @@ package services
 import (
 	"crypto/sha256"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"io"
 	"strconv"
@@ -38,6 +39,7 @@ type Nfse struct {
 
 type InfNfse struct {
 	Numero                     string           `xml:"Numero"`
+	Serie                      string           `xml:"Serie"` // Only some municipal layouts number NFSe by series
 	CodigoVerificacao          string           `xml:"CodigoVerificacao"`
 	AssinaturaPrestadorTomador string           `xml:"AssinaturaPrestadorTomador"`
 	DataEmissao                string           `xml:"DataEmissao"`
@@ -121,9 +123,38 @@ type Endereco struct {
 	CodigoMunicipio string `xml:"CodigoMunicipio"`
 	IBGE            string `xml:"IBGE"`
 	TOM             string `xml:"TOM"`
+	Uf              string `xml:"Uf"`
 	Cep             string `xml:"Cep"`
 }
 
+// toAddress converts a parsed address, or returns nil when the XML has none. The city is
+// identified by its IBGE code; the UF falls back to the one that code belongs to.
+func (e Endereco) toAddress() *models.Address {
+	cityCode := strings.TrimSpace(e.CodigoMunicipio)
+	if cityCode == "" {
+		cityCode = strings.TrimSpace(e.IBGE)
+	}
+
+	uf := strings.ToUpper(strings.TrimSpace(e.Uf))
+	if uf == "" {
+		uf = models.UFFromCityCode(cityCode)
+	}
+
+	address := models.Address{
+		Street:     strings.TrimSpace(e.Endereco),
+		Number:     strings.TrimSpace(e.Numero),
+		Complement: strings.TrimSpace(e.Complemento),
+		District:   strings.TrimSpace(e.Bairro),
+		CityCode:   cityCode,
+		UF:         uf,
+		PostalCode: strings.TrimSpace(e.Cep),
+	}
+	if address.IsEmpty() {
+		return nil
+	}
+	return &address
+}
+
 type NfseCancelamento struct {
 	Confirmacao Confirmacao `xml:"Confirmacao"`
 }
@@ -150,27 +181,43 @@ type NfseSubstituicao struct {
 	SubstituicaoNfse string `xml:"SubstituicaoNfse"`
 }
 
-// ParsedNFSeData represents the extracted and parsed NFSe data
+// ParsedNFSeData represents the extracted and parsed NFSe data. NF-e and CT-e are parsed
+// into the same fields (see ParseDocumentXML).
 type ParsedNFSeData struct {
+	DocumentType          string // models.DocumentType*; empty means NFSe
+	AccessKey             string // 44-digit chave de acesso of NF-e and CT-e
 	Number                string
+	Series                string // NFSe series when the layout has one, else the RPS series
 	VerificationCode      string
 	ProviderCNPJ          string
 	TakerCNPJ             string
 	ServiceValue          float64
+	IssValue              float64
 	ServiceCode           string
+	CNAECode              string
+	NaturezaOperacao      string
 	IssueDate             time.Time
 	MunicipalRegistration string
 	IsCancelled           bool
 	IsSubstituted         bool
 	DocumentHash          string
+	ContentHash           string // SHA-256 of the raw XML, before any encoding conversion
 	FullXML               string
+	ZeroValueFlagged      bool // Set by Validate under the "flag" zero-value policy
+	CNPJMismatchFlagged   bool // Set by Validate under the "flag" CNPJ match policy
 
 	// Additional important fields
 	Competence        string
 	RpsIssueDate      time.Time
+	RpsNumber         string
+	RpsSeries         string
+	RpsType           string
 	TakerName         string
 	ProviderName      string
 	ProviderTradeName string
+	TakerAddress      *models.Address
+	ProviderAddress   *models.Address
+	Discriminacao     string
 }
 
 // NFSeParser handles intelligent parsing and deduplication of NFSe XML documents
@@ -188,6 +235,9 @@ func (p *NFSeParser) ParseXML(xmlContent string) (*ParsedNFSeData, error) {
 		return nil, fmt.Errorf("empty XML content")
 	}
 
+	// Hash the bytes as received, so byte-identical XMLs always match
+	contentHash := hashContent(xmlContent)
+
 	// Handle ISO-8859-1 encoding
 	xmlContent = p.convertEncoding(xmlContent)
 
@@ -216,6 +266,9 @@ func (p *NFSeParser) ParseXML(xmlContent string) (*ParsedNFSeData, error) {
 		serviceValue = 0
 	}
 
+	// ISS value; missing or unparseable values are stored as zero
+	issValue, _ := strconv.ParseFloat(strings.TrimSpace(infNfse.Servico.Valores.ValorIss), 64)
+
 	// Parse issue date
 	issueDate, err := time.Parse("2006-01-02 15:04:05", infNfse.DataEmissao)
 	if err != nil {
@@ -244,29 +297,47 @@ func (p *NFSeParser) ParseXML(xmlContent string) (*ParsedNFSeData, error) {
 		rpsIssueDate, _ = time.Parse("2006-01-02 15:04:05", strings.TrimSpace(infNfse.DataEmissaoRps))
 	}
 
+	// Layouts without an NFSe series number notes per RPS series, so that series
+	// tells apart notes sharing a number
+	series := strings.TrimSpace(infNfse.Serie)
+	if series == "" {
+		series = strings.TrimSpace(infNfse.IdentificacaoRps.Serie)
+	}
+
 	// Generate document hash for additional validation
 	documentHash := p.generateDocumentHash(infNfse.CodigoVerificacao, infNfse.Numero, infNfse.PrestadorServico.IdentificacaoPrestador.Cnpj, infNfse.DataEmissao)
 
 	parsedData := &ParsedNFSeData{
 		Number:                infNfse.Numero,
+		Series:                series,
 		VerificationCode:      infNfse.CodigoVerificacao,
 		ProviderCNPJ:          infNfse.PrestadorServico.IdentificacaoPrestador.Cnpj,
 		TakerCNPJ:             takerCNPJ,
 		ServiceValue:          serviceValue,
-		ServiceCode:           infNfse.Servico.ItemListaServico,
+		IssValue:              issValue,
+		ServiceCode:           strings.TrimSpace(infNfse.Servico.ItemListaServico),
+		CNAECode:              strings.TrimSpace(infNfse.Servico.CodigoCnae),
+		NaturezaOperacao:      strings.TrimSpace(infNfse.NaturezaOperacao),
 		IssueDate:             issueDate,
 		MunicipalRegistration: infNfse.PrestadorServico.IdentificacaoPrestador.InscricaoMunicipal,
 		IsCancelled:           isCancelled,
 		IsSubstituted:         isSubstituted,
 		DocumentHash:          documentHash,
+		ContentHash:           contentHash,
 		FullXML:               xmlContent,
 
 		// Additional important fields
 		Competence:        infNfse.Competencia,
 		RpsIssueDate:      rpsIssueDate,
+		RpsNumber:         strings.TrimSpace(infNfse.IdentificacaoRps.Numero),
+		RpsSeries:         strings.TrimSpace(infNfse.IdentificacaoRps.Serie),
+		RpsType:           strings.TrimSpace(infNfse.IdentificacaoRps.Tipo),
 		TakerName:         infNfse.TomadorServico.RazaoSocial,
 		ProviderName:      infNfse.PrestadorServico.RazaoSocial,
 		ProviderTradeName: infNfse.PrestadorServico.NomeFantasia,
+		TakerAddress:      infNfse.TomadorServico.Endereco.toAddress(),
+		ProviderAddress:   infNfse.PrestadorServico.Endereco.toAddress(),
+		Discriminacao:     strings.TrimSpace(infNfse.Servico.Discriminacao),
 	}
 
 	logger.InfoWithFields("Successfully parsed NFSe XML", map[string]any{
@@ -282,6 +353,72 @@ func (p *NFSeParser) ParseXML(xmlContent string) (*ParsedNFSeData, error) {
 	return parsedData, nil
 }
 
+// ErrZeroValueRejected is returned by Validate for zero-value notes under the "reject" policy
+var ErrZeroValueRejected = errors.New("NFSe has zero service value")
+
+// ErrCNPJMismatch is returned by Validate when neither the provider nor the taker of a
+// note is the company and its CNPJ match policy is "reject"
+var ErrCNPJMismatch = errors.New("NFSe provider and taker CNPJs do not match the company")
+
+// IngestPolicy holds the company settings applied to parsed notes before storing them
+type IngestPolicy struct {
+	ZeroValue   string // models.ZeroValuePolicy*
+	CNPJMatch   string // models.CNPJMatchPolicy*
+	CompanyCNPJ string
+	Validators  []NamedDocumentValidator // Company business rules, run after the built-in policies
+	// MetadataOnly stores only the parsed fields of new notes, without the XML
+	MetadataOnly bool
+	// ClosedCompetences (YYYY-MM) flag new notes of these competências as late arrivals
+	ClosedCompetences map[string]bool
+}
+
+// Validate applies the company policies to parsed data.
+//
+// Zero-value notes are legitimate in some cases (e.g. corrections), so an unknown or
+// empty zero-value policy falls back to flagging them for review.
+//
+// The CNPJ match policy catches notes of another company ingested through a misconfigured
+// credential: the company must be either the provider or the taker of the note.
+//
+// The company validation rules run last; the first violation rejects the note.
+func (p *NFSeParser) Validate(parsedData *ParsedNFSeData, policy IngestPolicy) error {
+	if parsedData.ServiceValue == 0 {
+		switch policy.ZeroValue {
+		case models.ZeroValuePolicyAccept:
+		case models.ZeroValuePolicyReject:
+			return ErrZeroValueRejected
+		default:
+			parsedData.ZeroValueFlagged = true
+		}
+	}
+
+	if policy.CNPJMatch == models.CNPJMatchPolicyFlag || policy.CNPJMatch == models.CNPJMatchPolicyReject {
+		if !belongsToCompany(parsedData, policy.CompanyCNPJ) {
+			if policy.CNPJMatch == models.CNPJMatchPolicyReject {
+				return ErrCNPJMismatch
+			}
+			parsedData.CNPJMismatchFlagged = true
+		}
+	}
+
+	for _, validator := range policy.Validators {
+		if err := validator.Validate(parsedData); err != nil {
+			return fmt.Errorf("%w (%s): %v", ErrDocumentRuleViolation, validator.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// belongsToCompany reports whether the company is the provider or the taker of the note
+func belongsToCompany(parsedData *ParsedNFSeData, companyCNPJ string) bool {
+	company := NormalizeCNPJ(companyCNPJ)
+	if company == "" {
+		return true
+	}
+	return NormalizeCNPJ(parsedData.ProviderCNPJ) == company || NormalizeCNPJ(parsedData.TakerCNPJ) == company
+}
+
 // generateDocumentHash creates a hash of critical fields for additional validation
 func (p *NFSeParser) generateDocumentHash(verificationCode, number, providerCNPJ, issueDate string) string {
 	data := fmt.Sprintf("%s|%s|%s|%s", verificationCode, number, providerCNPJ, issueDate)
@@ -289,13 +426,25 @@ func (p *NFSeParser) generateDocumentHash(verificationCode, number, providerCNPJ
 	return fmt.Sprintf("%x", hash)
 }
 
+// hashContent returns the SHA-256 of raw XML content
+func hashContent(xmlContent string) string {
+	hash := sha256.Sum256([]byte(xmlContent))
+	return fmt.Sprintf("%x", hash)
+}
+
 // ConvertToDocument converts parsed NFSe data to Document model
 func (p *NFSeParser) ConvertToDocument(companyID int64, parsedData *ParsedNFSeData, storageKey string) *models.Document {
+	key := fmt.Sprintf("%s_%s", parsedData.ProviderCNPJ, parsedData.Number)
+	if parsedData.AccessKey != "" {
+		key = parsedData.AccessKey
+	}
+
 	return &models.Document{
 		CompanyID:             companyID,
-		Type:                  "nfse",
-		Key:                   fmt.Sprintf("%s_%s", parsedData.ProviderCNPJ, parsedData.Number),
+		Type:                  documentTypeOf(parsedData),
+		Key:                   key,
 		Number:                parsedData.Number,
+		Series:                parsedData.Series,
 		IssueDate:             parsedData.IssueDate,
 		Amount:                parsedData.ServiceValue,
 		Status:                "processed",
@@ -305,19 +454,31 @@ func (p *NFSeParser) ConvertToDocument(companyID int64, parsedData *ParsedNFSeDa
 		ProviderCNPJ:          parsedData.ProviderCNPJ,
 		TakerCNPJ:             parsedData.TakerCNPJ,
 		ServiceValue:          parsedData.ServiceValue,
+		IssValue:              parsedData.IssValue,
 		ServiceCode:           parsedData.ServiceCode,
+		NaturezaOperacao:      parsedData.NaturezaOperacao,
 		MunicipalRegistration: parsedData.MunicipalRegistration,
 		DocumentHash:          parsedData.DocumentHash,
+		ContentHash:           parsedData.ContentHash,
 		IsCancelled:           parsedData.IsCancelled,
 		IsSubstituted:         parsedData.IsSubstituted,
+		ZeroValueFlagged:      parsedData.ZeroValueFlagged,
+		CNPJMismatchFlagged:   parsedData.CNPJMismatchFlagged,
 		ProcessingDate:        time.Now(),
 
 		// Additional important fields
 		Competence:        parsedData.Competence,
+		CompetenceMonth:   documentCompetence(parsedData),
 		RpsIssueDate:      parsedData.RpsIssueDate,
+		RpsNumber:         parsedData.RpsNumber,
+		RpsSeries:         parsedData.RpsSeries,
+		RpsType:           parsedData.RpsType,
 		TakerName:         parsedData.TakerName,
 		ProviderName:      parsedData.ProviderName,
 		ProviderTradeName: parsedData.ProviderTradeName,
+		TakerAddress:      parsedData.TakerAddress,
+		ProviderAddress:   parsedData.ProviderAddress,
+		Discriminacao:     parsedData.Discriminacao,
 	}
 }
 
@@ -0,0 +1,148 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/zoomxml/internal/models"
+)
+
+func TestEnderecoToAddress(t *testing.T) {
+	tests := []struct {
+		name     string
+		endereco Endereco
+		want     *models.Address
+	}{
+		{
+			name:     "empty",
+			endereco: Endereco{Endereco: "  ", Cep: " "},
+			want:     nil,
+		},
+		{
+			name: "trims and keeps the informed UF",
+			endereco: Endereco{
+				Endereco: " Rua A ", Numero: "10", Complemento: "sala 2", Bairro: "Centro",
+				CodigoMunicipio: "3550308", Uf: "sp", Cep: "01000000",
+			},
+			want: &models.Address{
+				Street: "Rua A", Number: "10", Complement: "sala 2", District: "Centro",
+				CityCode: "3550308", UF: "SP", PostalCode: "01000000",
+			},
+		},
+		{
+			name:     "UF from the city code",
+			endereco: Endereco{Endereco: "Av. B", CodigoMunicipio: "4106902"},
+			want:     &models.Address{Street: "Av. B", CityCode: "4106902", UF: "PR"},
+		},
+		{
+			name:     "IBGE when the city code is missing",
+			endereco: Endereco{IBGE: "5300108"},
+			want:     &models.Address{CityCode: "5300108", UF: "DF"},
+		},
+		{
+			name:     "unknown city code",
+			endereco: Endereco{Endereco: "Rua C", CodigoMunicipio: "123"},
+			want:     &models.Address{Street: "Rua C", CityCode: "123"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.endereco.toAddress()
+			if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+				t.Errorf("toAddress() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateZeroValuePolicy(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      string
+		value       float64
+		wantErr     error
+		wantFlagged bool
+	}{
+		{"accept", models.ZeroValuePolicyAccept, 0, nil, false},
+		{"flag", models.ZeroValuePolicyFlag, 0, nil, true},
+		{"reject", models.ZeroValuePolicyReject, 0, ErrZeroValueRejected, false},
+		{"unset policy flags", "", 0, nil, true},
+		{"non-zero note under reject", models.ZeroValuePolicyReject, 10.5, nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed := &ParsedNFSeData{ServiceValue: tt.value}
+			err := NewNFSeParser().Validate(parsed, IngestPolicy{ZeroValue: tt.policy})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Validate() error = %v, want %v", err, tt.wantErr)
+			}
+			if parsed.ZeroValueFlagged != tt.wantFlagged {
+				t.Errorf("ZeroValueFlagged = %v, want %v", parsed.ZeroValueFlagged, tt.wantFlagged)
+			}
+		})
+	}
+}
+
+func TestValidateCNPJMatchPolicy(t *testing.T) {
+	const company = "12.345.678/0001-90"
+
+	tests := []struct {
+		name        string
+		policy      string
+		provider    string
+		taker       string
+		wantErr     error
+		wantFlagged bool
+	}{
+		{"company is the provider", models.CNPJMatchPolicyReject, "12345678000190", "98765432000110", nil, false},
+		{"company is the taker", models.CNPJMatchPolicyReject, "98765432000110", "12.345.678/0001-90", nil, false},
+		{"mismatch flagged", models.CNPJMatchPolicyFlag, "98765432000110", "11111111000111", nil, true},
+		{"mismatch rejected", models.CNPJMatchPolicyReject, "98765432000110", "11111111000111", ErrCNPJMismatch, false},
+		{"check off", models.CNPJMatchPolicyOff, "98765432000110", "11111111000111", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed := &ParsedNFSeData{ServiceValue: 100, ProviderCNPJ: tt.provider, TakerCNPJ: tt.taker}
+			err := NewNFSeParser().Validate(parsed, IngestPolicy{CNPJMatch: tt.policy, CompanyCNPJ: company})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Validate() error = %v, want %v", err, tt.wantErr)
+			}
+			if parsed.CNPJMismatchFlagged != tt.wantFlagged {
+				t.Errorf("CNPJMismatchFlagged = %v, want %v", parsed.CNPJMismatchFlagged, tt.wantFlagged)
+			}
+		})
+	}
+}
+
+func TestParseXMLRps(t *testing.T) {
+	tests := []struct {
+		name                    string
+		rps                     string
+		number, series, rpsType string
+	}{
+		{"informed", "<IdentificacaoRps><Numero> 77 </Numero><Serie>RPS1</Serie><Tipo>1</Tipo></IdentificacaoRps>", "77", "RPS1", "1"},
+		{"absent", "", "", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			xmlContent := strings.Replace(testNFSeXML("4521", "ABC123", "11111111000111", "12345678000190", "100.00"),
+				"<Competencia>", tt.rps+"<Competencia>", 1)
+
+			parser := NewNFSeParser()
+			parsed, err := parser.ParseXML(xmlContent)
+			if err != nil {
+				t.Fatalf("ParseXML() error = %v", err)
+			}
+			document := parser.ConvertToDocument(1, parsed, "key.xml")
+			if document.RpsNumber != tt.number || document.RpsSeries != tt.series || document.RpsType != tt.rpsType {
+				t.Errorf("RPS = %q/%q/%q, want %q/%q/%q",
+					document.RpsNumber, document.RpsSeries, document.RpsType, tt.number, tt.series, tt.rpsType)
+			}
+		})
+	}
+}
@@ -2,6 +2,9 @@ package services
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sync"
 	"time"
 
 	"github.com/zoomxml/config"
@@ -17,12 +20,77 @@ type NFSeScheduler struct {
 	stopChan    chan bool
 	running     bool
 	config      *config.Config
+
+	mu         sync.Mutex
+	checkpoint *runCheckpoint
+	metrics    SchedulerMetrics
+}
+
+// runCheckpoint records where a budget-limited run stopped. It is kept in memory only:
+// after a restart the next run starts again at the first company, which is safe since
+// stored documents are deduplicated.
+type runCheckpoint struct {
+	CompanyID int64 `json:"company_id"`
+	Page      int   `json:"page"`
+}
+
+// SchedulerMetrics holds counters about scheduled runs
+type SchedulerMetrics struct {
+	Runs                      int64      `json:"runs"`
+	DocumentBudgetExhaustions int64      `json:"document_budget_exhaustions"`
+	DurationBudgetExhaustions int64      `json:"duration_budget_exhaustions"`
+	LastRunDocuments          int        `json:"last_run_documents"`
+	LastRunDuration           string     `json:"last_run_duration"`
+	LastRunAt                 *time.Time `json:"last_run_at,omitempty"`
+}
+
+// Budget exhaustion reasons
+const (
+	budgetReasonDocuments = "max_documents"
+	budgetReasonDuration  = "max_duration"
+)
+
+// runBudget tracks the document and time budget of a single scheduled run
+type runBudget struct {
+	maxDocuments int
+	deadline     time.Time
+	documents    int
+}
+
+// newRunBudget creates a budget from the scheduler configuration
+func newRunBudget(cfg config.NFSeSchedulerConfig, start time.Time) *runBudget {
+	budget := &runBudget{maxDocuments: cfg.MaxDocumentsPerRun}
+	if cfg.MaxRunDuration > 0 {
+		budget.deadline = start.Add(cfg.MaxRunDuration)
+	}
+	return budget
+}
+
+// add records documents fetched during the run
+func (b *runBudget) add(documents int) {
+	if b != nil {
+		b.documents += documents
+	}
+}
+
+// exhausted reports whether the budget was used up and why
+func (b *runBudget) exhausted() (bool, string) {
+	if b == nil {
+		return false, ""
+	}
+	if b.maxDocuments > 0 && b.documents >= b.maxDocuments {
+		return true, budgetReasonDocuments
+	}
+	if !b.deadline.IsZero() && !time.Now().Before(b.deadline) {
+		return true, budgetReasonDuration
+	}
+	return false, ""
 }
 
 // NewNFSeScheduler creates a new NFSe scheduler
 func NewNFSeScheduler() *NFSeScheduler {
 	return &NFSeScheduler{
-		nfseService: NewNFSeService(),
+		nfseService: NewNFSeService(SharedTransport()),
 		stopChan:    make(chan bool),
 		running:     false,
 		config:      config.Get(),
@@ -45,16 +113,17 @@ func (s *NFSeScheduler) Start() error {
 		return nil
 	}
 
-	// Parse interval from config
-	interval, err := time.ParseDuration(s.config.NFSeScheduler.Interval)
-	if err != nil {
-		logger.ErrorWithFields("Invalid scheduler interval", err, map[string]any{
+	if err := s.config.NFSeScheduler.Validate(); err != nil {
+		logger.ErrorWithFields("Invalid scheduler configuration", err, map[string]any{
 			"operation": "start_scheduler",
 			"interval":  s.config.NFSeScheduler.Interval,
 		})
 		return err
 	}
 
+	// Validate guarantees the interval parses
+	interval, _ := time.ParseDuration(s.config.NFSeScheduler.Interval)
+
 	s.ticker = time.NewTicker(interval)
 	s.running = true
 
@@ -87,11 +156,13 @@ func (s *NFSeScheduler) Stop() {
 // run is the main scheduler loop
 func (s *NFSeScheduler) run() {
 	// Run immediately on start
+	s.ensureDocumentPartitions()
 	s.fetchAllCompanies()
 
 	for {
 		select {
 		case <-s.ticker.C:
+			s.ensureDocumentPartitions()
 			s.fetchAllCompanies()
 		case <-s.stopChan:
 			logger.InfoWithFields("NFSe scheduler stopped", map[string]any{
@@ -102,13 +173,28 @@ func (s *NFSeScheduler) run() {
 	}
 }
 
+// ensureDocumentPartitions keeps the monthly documents partitions created ahead of the
+// months being fetched. Without them notes land in the default partition, so a failure
+// is only logged.
+func (s *NFSeScheduler) ensureDocumentPartitions() {
+	if err := database.EnsureDocumentPartitionsAhead(context.Background(), time.Now()); err != nil {
+		logger.ErrorWithFields("Failed to create documents partitions", err, map[string]any{
+			"operation": "ensure_document_partitions",
+		})
+	}
+}
+
 // fetchAllCompanies fetches NFSe documents for all companies with auto_fetch enabled
 func (s *NFSeScheduler) fetchAllCompanies() {
 	ctx := context.Background()
+	startTime := time.Now()
+	budget := newRunBudget(s.config.NFSeScheduler, startTime)
 
 	logger.InfoWithFields("Starting scheduled NFSe fetch for all companies", map[string]any{
 		"operation":       "scheduled_fetch",
 		"fetch_days_back": s.config.NFSeScheduler.FetchDaysBack,
+		"max_documents":   s.config.NFSeScheduler.MaxDocumentsPerRun,
+		"max_duration":    s.config.NFSeScheduler.MaxRunDuration.String(),
 	})
 
 	// Get all companies with auto_fetch enabled
@@ -116,6 +202,7 @@ func (s *NFSeScheduler) fetchAllCompanies() {
 	err := database.DB.NewSelect().
 		Model(&companies).
 		Where("auto_fetch = true AND active = true").
+		Order("id ASC").
 		Scan(ctx)
 
 	if err != nil {
@@ -125,28 +212,138 @@ func (s *NFSeScheduler) fetchAllCompanies() {
 		return
 	}
 
+	// Resume from where the previous run stopped, if it ran out of budget
+	s.mu.Lock()
+	checkpoint := s.checkpoint
+	s.checkpoint = nil
+	s.mu.Unlock()
+
+	companies = rotateCompanies(companies, checkpoint)
+
 	logger.InfoWithFields("Found companies for scheduled fetch", map[string]any{
 		"operation":       "scheduled_fetch",
 		"companies_count": len(companies),
+		"resumed":         checkpoint != nil,
 	})
 
 	// Process each company
 	successCount := 0
+	skippedCount := 0
+	notDueCount := 0
 	for _, company := range companies {
-		if s.fetchCompanyDocuments(ctx, &company) {
+		// Auto fetch may have been paused since the list was loaded (e.g. for provider
+		// maintenance), possibly from another instance, so it is checked again
+		if !companyAutoFetchEnabled(ctx, company.ID) {
+			continue
+		}
+
+		startPage := 1
+		resuming := checkpoint != nil && checkpoint.CompanyID == company.ID
+		if resuming {
+			startPage = checkpoint.Page
+		}
+
+		// Companies with their own schedule are fetched only when it is due; a fetch cut
+		// short by the budget carries on regardless
+		if !resuming && !companySyncDue(&company, startTime) {
+			notDueCount++
+			continue
+		}
+
+		success, nextPage, err := s.fetchCompanyDocuments(ctx, &company, startPage, budget)
+		if errors.Is(err, ErrNoCredentials) {
+			skippedCount++
+		} else if success {
 			successCount++
 		}
+
+		// Only a completed fetch uses up the scheduled time; a failed one is retried on the
+		// next tick and one cut short by the budget resumes from the checkpoint
+		if company.SyncCron != "" && success && nextPage == 0 {
+			if err := markScheduledFetch(ctx, company.ID, startTime); err != nil {
+				logger.ErrorWithFields("Failed to record scheduled fetch", err, map[string]any{
+					"operation":  "scheduled_fetch",
+					"company_id": company.ID,
+				})
+			}
+		}
+
+		if exhausted, reason := budget.exhausted(); exhausted {
+			s.recordBudgetExhaustion(reason, company.ID, nextPage)
+			break
+		}
 	}
 
+	duration := time.Since(startTime)
+	s.mu.Lock()
+	s.metrics.Runs++
+	s.metrics.LastRunDocuments = budget.documents
+	s.metrics.LastRunDuration = duration.String()
+	s.metrics.LastRunAt = &startTime
+	s.mu.Unlock()
+
 	logger.InfoWithFields("Completed scheduled NFSe fetch", map[string]any{
 		"operation":         "scheduled_fetch",
 		"companies_total":   len(companies),
 		"companies_success": successCount,
+		"companies_skipped": skippedCount,
+		"companies_not_due": notDueCount,
+		"documents_total":   budget.documents,
+		"duration_ms":       duration.Milliseconds(),
 	})
 }
 
-// fetchCompanyDocuments fetches NFSe documents for a specific company
-func (s *NFSeScheduler) fetchCompanyDocuments(ctx context.Context, company *models.Company) bool {
+// recordBudgetExhaustion stores the checkpoint for the next tick and updates metrics.
+// nextPage is 0 when the company was fully processed, so the next run starts at the
+// following company.
+func (s *NFSeScheduler) recordBudgetExhaustion(reason string, companyID int64, nextPage int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if nextPage > 0 {
+		s.checkpoint = &runCheckpoint{CompanyID: companyID, Page: nextPage}
+	} else {
+		s.checkpoint = &runCheckpoint{CompanyID: companyID + 1, Page: 1}
+	}
+
+	switch reason {
+	case budgetReasonDocuments:
+		s.metrics.DocumentBudgetExhaustions++
+	case budgetReasonDuration:
+		s.metrics.DurationBudgetExhaustions++
+	}
+
+	logger.WarnWithFields("Scheduled NFSe fetch budget exhausted, yielding until next tick", map[string]any{
+		"operation":       "scheduled_fetch",
+		"metric":          "scheduler_budget_exhausted",
+		"reason":          reason,
+		"resume_company":  s.checkpoint.CompanyID,
+		"resume_page":     s.checkpoint.Page,
+		"exhaustions_doc": s.metrics.DocumentBudgetExhaustions,
+		"exhaustions_dur": s.metrics.DurationBudgetExhaustions,
+	})
+}
+
+// rotateCompanies reorders companies (sorted by ID) so the run starts at the checkpoint
+// company, letting companies that were starved by the previous run go first.
+func rotateCompanies(companies []models.Company, checkpoint *runCheckpoint) []models.Company {
+	if checkpoint == nil {
+		return companies
+	}
+
+	for i, company := range companies {
+		if company.ID >= checkpoint.CompanyID {
+			return append(companies[i:], companies[:i]...)
+		}
+	}
+	return companies
+}
+
+// fetchCompanyDocuments fetches NFSe documents for a specific company starting at startPage.
+// It returns whether documents were stored and, when the budget ran out before the last
+// page, the page to resume from (0 otherwise). A nil budget means no limit.
+// ErrNoCredentials is returned when the company has no usable credential.
+func (s *NFSeScheduler) fetchCompanyDocuments(ctx context.Context, company *models.Company, startPage int, budget *runBudget) (bool, int, error) {
 	logger.InfoWithFields("Fetching NFSe documents for company", map[string]any{
 		"operation":    "fetch_company_documents",
 		"company_id":   company.ID,
@@ -154,28 +351,24 @@ func (s *NFSeScheduler) fetchCompanyDocuments(ctx context.Context, company *mode
 		"company_cnpj": company.CNPJ,
 	})
 
-	// Get company credentials - use only token-based credentials
-	credentials := []models.CompanyCredential{}
-	err := database.DB.NewSelect().
-		Model(&credentials).
-		Where("company_id = ? AND active = true", company.ID).
-		Where("type = 'prefeitura_token'").
-		Scan(ctx)
-
-	if err != nil {
-		logger.ErrorWithFields("Failed to fetch company credentials", err, map[string]any{
+	// Get the company credentials the provider of its municipality accepts
+	credentials, err := GetFetchCredentials(ctx, company.ID)
+	if errors.Is(err, ErrNoCredentials) {
+		logger.InfoWithFields("Skipping company without NFSe credentials", map[string]any{
 			"operation":  "fetch_company_documents",
 			"company_id": company.ID,
 		})
-		return false
+		s.recordSyncResult(ctx, company.ID, models.SyncStatusNoCredentials, err)
+		return false, 0, err
 	}
 
-	if len(credentials) == 0 {
-		logger.WarnWithFields("No NFSe credentials found for company", map[string]any{
+	if err != nil {
+		logger.ErrorWithFields("Failed to fetch company credentials", err, map[string]any{
 			"operation":  "fetch_company_documents",
 			"company_id": company.ID,
 		})
-		return false
+		s.recordSyncResult(ctx, company.ID, models.SyncStatusFailed, err)
+		return false, 0, err
 	}
 
 	logger.InfoWithFields("Found credentials for company", map[string]any{
@@ -185,14 +378,15 @@ func (s *NFSeScheduler) fetchCompanyDocuments(ctx context.Context, company *mode
 		"credential_types":  getCredentialTypes(credentials),
 	})
 
-	// Use the first available credential (now prioritized by token availability)
-	credential := &credentials[0]
+	// Start with the first credential; fetchPage moves on to the next one when the
+	// provider rejects the current one
+	credentialIndex := 0
 
 	logger.InfoWithFields("Selected credential for API call", map[string]any{
 		"operation":       "fetch_company_documents",
 		"company_id":      company.ID,
-		"credential_id":   credential.ID,
-		"credential_type": credential.Type,
+		"credential_id":   credentials[0].ID,
+		"credential_type": credentials[0].Type,
 	})
 
 	// Calculate date range based on config
@@ -211,26 +405,38 @@ func (s *NFSeScheduler) fetchCompanyDocuments(ctx context.Context, company *mode
 		"calculated_days":  daysDiff,
 	})
 
+	if startPage < 1 {
+		startPage = 1
+	}
+
 	totalDocuments := 0
+	failedDocuments := 0
+	nextPage := 0
+	var fetchErr error
+	// stop tells why the run ended; it stays stopIncomplete unless the last page was
+	// reached, so a run cut short at MaxPagesPerRun is not taken as complete
+	stop := stopIncomplete
 	// Try to fetch multiple pages
-	for page := 1; page <= s.config.NFSeScheduler.MaxPagesPerRun; page++ {
+	for page := startPage; page < startPage+s.config.NFSeScheduler.MaxPagesPerRun; page++ {
 		logger.InfoWithFields("Fetching NFSe documents page", map[string]any{
 			"operation":       "fetch_company_documents",
 			"company_id":      company.ID,
 			"page":            page,
-			"credential_id":   credential.ID,
-			"credential_type": credential.Type,
+			"credential_id":   credentials[credentialIndex].ID,
+			"credential_type": credentials[credentialIndex].Type,
 		})
 
-		result, err := s.nfseService.FetchNFSeDocuments(ctx, credential, startDate, endDate, page)
+		// Documents are stored as they are extracted to bound memory on large pages
+		result, err := s.fetchPage(ctx, credentials, &credentialIndex, startDate, endDate, page)
 		if err != nil {
-			logger.ErrorWithFields("Failed to fetch NFSe documents", err, map[string]any{
+			logger.ErrorWithFields("Failed to fetch and store NFSe documents", err, map[string]any{
 				"operation":     "fetch_company_documents",
 				"company_id":    company.ID,
 				"page":          page,
-				"credential_id": credential.ID,
+				"credential_id": credentials[credentialIndex].ID,
 				"error_details": err.Error(),
 			})
+			fetchErr = err
 			break
 		}
 
@@ -241,47 +447,42 @@ func (s *NFSeScheduler) fetchCompanyDocuments(ctx context.Context, company *mode
 				"page":       page,
 				"result":     result,
 			})
+			stop = stopUnsuccessful
 			break
 		}
 
-		if len(result.Documents) == 0 {
+		if result.DocumentsCount == 0 {
 			logger.InfoWithFields("No more documents found", map[string]any{
 				"operation":  "fetch_company_documents",
 				"company_id": company.ID,
 				"page":       page,
 			})
+			stop = stopComplete
 			break
 		}
 
-		// Store documents
-		logger.InfoWithFields("Storing NFSe documents", map[string]any{
+		totalDocuments += result.DocumentsCount
+		failedDocuments += result.FailedCount
+		logger.InfoWithFields("Successfully stored NFSe documents", map[string]any{
 			"operation":       "fetch_company_documents",
 			"company_id":      company.ID,
 			"page":            page,
-			"documents_count": len(result.Documents),
+			"documents_count": result.DocumentsCount,
+			"total_so_far":    totalDocuments,
 		})
 
-		err = s.nfseService.StoreNFSeDocuments(ctx, company.ID, result.Documents)
-		if err != nil {
-			logger.ErrorWithFields("Failed to store NFSe documents", err, map[string]any{
-				"operation":     "fetch_company_documents",
-				"company_id":    company.ID,
-				"page":          page,
-				"error_details": err.Error(),
-			})
-		} else {
-			totalDocuments += len(result.Documents)
-			logger.InfoWithFields("Successfully stored NFSe documents", map[string]any{
-				"operation":       "fetch_company_documents",
-				"company_id":      company.ID,
-				"page":            page,
-				"documents_count": len(result.Documents),
-				"total_so_far":    totalDocuments,
-			})
+		budget.add(result.DocumentsCount)
+
+		// If we got less than a full page, we're done
+		if result.DocumentsCount < maxDocumentsPerPage {
+			stop = stopComplete
+			break
 		}
 
-		// If we got less than 100 documents (max per page), we're done
-		if len(result.Documents) < 100 {
+		// Yield when the run budget is exhausted, remembering the next page
+		if exhausted, _ := budget.exhausted(); exhausted {
+			nextPage = page + 1
+			stop = stopBudget
 			break
 		}
 
@@ -299,9 +500,100 @@ func (s *NFSeScheduler) fetchCompanyDocuments(ctx context.Context, company *mode
 		"company_cnpj":    company.CNPJ,
 		"total_documents": totalDocuments,
 		"success":         success,
+		"next_page":       nextPage,
 	})
 
-	return success
+	status, syncErr := syncOutcome(stop, fetchErr, failedDocuments)
+	s.recordSyncResult(ctx, company.ID, status, syncErr)
+	return success, nextPage, fetchErr
+}
+
+// Reasons a company fetch ended
+const (
+	stopComplete     = "complete"     // the last page, short or empty, was reached
+	stopIncomplete   = "incomplete"   // MaxPagesPerRun was reached with pages still full
+	stopUnsuccessful = "unsuccessful" // the provider reported a page as not successful
+	stopBudget       = "budget"       // the run budget ran out before the last page
+)
+
+// syncOutcome returns the sync status recorded for a company fetch and its error. Only
+// a fetch that reached the last page and stored every document is a success, the one
+// status that advances last_sync_at: any other run is retried by the next one.
+func syncOutcome(stop string, fetchErr error, failedDocuments int) (string, error) {
+	switch {
+	case fetchErr != nil:
+		return models.SyncStatusFailed, fetchErr
+	case stop == stopUnsuccessful:
+		return models.SyncStatusFailed, errors.New("provider reported the fetch as not successful")
+	case failedDocuments > 0:
+		// Documents that failed to store are fetched again on the next run
+		return models.SyncStatusPartial, fmt.Errorf("%d documents could not be stored", failedDocuments)
+	case stop == stopBudget:
+		return models.SyncStatusPartial, errors.New("run budget exhausted before the last page")
+	case stop == stopIncomplete:
+		return models.SyncStatusPartial, errors.New("page limit reached before the last page")
+	}
+	return models.SyncStatusSuccess, nil
+}
+
+// fetchPage fetches and stores one page with credentials[*index]. When the provider
+// rejects that credential, the rejection is recorded and the page is retried with the
+// next credential, so an expired token does not fail the company while another one is
+// valid. *index is left at the credential that served the page, or at the last one
+// tried when all were rejected.
+func (s *NFSeScheduler) fetchPage(ctx context.Context, credentials []models.CompanyCredential, index *int, startDate, endDate time.Time, page int) (*NFSeProcessResult, error) {
+	for {
+		credential := &credentials[*index]
+		result, err := s.nfseService.FetchAndStoreNFSeDocuments(ctx, credential, startDate, endDate, page)
+		if !errors.Is(err, ErrCredentialRejected) {
+			return result, err
+		}
+
+		recordCredentialFailure(ctx, credential)
+		if *index+1 >= len(credentials) {
+			return nil, err
+		}
+
+		*index++
+		logger.WarnWithFields("Credential rejected by provider, trying the next one", map[string]any{
+			"operation":       "fetch_company_documents",
+			"company_id":      credential.CompanyID,
+			"page":            page,
+			"credential_id":   credential.ID,
+			"next_credential": credentials[*index].ID,
+			"failure_count":   credential.FailureCount,
+		})
+	}
+}
+
+// recordSyncResult stores the outcome of the last sync on the company
+func (s *NFSeScheduler) recordSyncResult(ctx context.Context, companyID int64, status string, syncErr error) {
+	errorMessage := ""
+	if syncErr != nil {
+		errorMessage = syncErr.Error()
+	}
+
+	query := database.DB.NewUpdate().
+		Model((*models.Company)(nil)).
+		Set("last_sync_status = ?", status).
+		Set("last_sync_error = ?", errorMessage).
+		Where("id = ?", companyID)
+
+	// last_sync_at only advances on a complete sync; failed and partial runs keep the
+	// previous one so they never look caught up
+	if status == models.SyncStatusSuccess {
+		query = query.Set("last_sync_at = ?", time.Now())
+	}
+
+	_, err := query.Exec(ctx)
+
+	if err != nil {
+		logger.ErrorWithFields("Failed to record company sync status", err, map[string]any{
+			"operation":  "fetch_company_documents",
+			"company_id": companyID,
+			"status":     status,
+		})
+	}
 }
 
 // IsRunning returns whether the scheduler is currently running
@@ -322,20 +614,27 @@ func (s *NFSeScheduler) FetchCompanyNow(ctx context.Context, companyID int64) er
 		return err
 	}
 
-	// Fetch documents
-	s.fetchCompanyDocuments(ctx, company)
-	return nil
+	// Fetch documents (manual fetches are not subject to the run budget)
+	_, _, err = s.fetchCompanyDocuments(ctx, company, 1, nil)
+	return err
 }
 
 // GetStatus returns the current status of the scheduler
 func (s *NFSeScheduler) GetStatus() map[string]any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	return map[string]any{
-		"running":           s.running,
-		"enabled":           s.config.NFSeScheduler.Enabled,
-		"interval":          s.config.NFSeScheduler.Interval,
-		"fetch_days_back":   s.config.NFSeScheduler.FetchDaysBack,
-		"max_pages_per_run": s.config.NFSeScheduler.MaxPagesPerRun,
-		"api_delay_seconds": s.config.NFSeScheduler.APIDelaySeconds,
+		"running":               s.running,
+		"enabled":               s.config.NFSeScheduler.Enabled,
+		"interval":              s.config.NFSeScheduler.Interval,
+		"fetch_days_back":       s.config.NFSeScheduler.FetchDaysBack,
+		"max_pages_per_run":     s.config.NFSeScheduler.MaxPagesPerRun,
+		"max_documents_per_run": s.config.NFSeScheduler.MaxDocumentsPerRun,
+		"max_run_duration":      s.config.NFSeScheduler.MaxRunDuration.String(),
+		"api_delay_seconds":     s.config.NFSeScheduler.APIDelaySeconds,
+		"checkpoint":            s.checkpoint,
+		"metrics":               s.metrics,
 	}
 }
 
@@ -0,0 +1,253 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/zoomxml/config"
+	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/database/databasetest"
+	"github.com/zoomxml/internal/models"
+	"github.com/zoomxml/internal/repository/repositorytest"
+)
+
+func TestRunBudgetExhausted(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name       string
+		budget     *runBudget
+		documents  int
+		want       bool
+		wantReason string
+	}{
+		{"nil budget", nil, 100, false, ""},
+		{"no limits", newRunBudget(config.NFSeSchedulerConfig{}, now), 100, false, ""},
+		{"below document limit", newRunBudget(config.NFSeSchedulerConfig{MaxDocumentsPerRun: 10}, now), 9, false, ""},
+		{"at document limit", newRunBudget(config.NFSeSchedulerConfig{MaxDocumentsPerRun: 10}, now), 10, true, budgetReasonDocuments},
+		{"past document limit", newRunBudget(config.NFSeSchedulerConfig{MaxDocumentsPerRun: 10}, now), 50, true, budgetReasonDocuments},
+		{"before deadline", newRunBudget(config.NFSeSchedulerConfig{MaxRunDuration: time.Hour}, now), 0, false, ""},
+		{"past deadline", newRunBudget(config.NFSeSchedulerConfig{MaxRunDuration: time.Minute}, now.Add(-time.Hour)), 0, true, budgetReasonDuration},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.budget.add(tt.documents)
+			got, reason := tt.budget.exhausted()
+			if got != tt.want || reason != tt.wantReason {
+				t.Errorf("exhausted() = %v, %q, want %v, %q", got, reason, tt.want, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestRotateCompanies(t *testing.T) {
+	tests := []struct {
+		name       string
+		checkpoint *runCheckpoint
+		want       []int64
+	}{
+		{"no checkpoint", nil, []int64{1, 3, 5, 7}},
+		{"first company", &runCheckpoint{CompanyID: 1, Page: 1}, []int64{1, 3, 5, 7}},
+		{"middle company", &runCheckpoint{CompanyID: 5, Page: 3}, []int64{5, 7, 1, 3}},
+		{"company no longer listed", &runCheckpoint{CompanyID: 4, Page: 1}, []int64{5, 7, 1, 3}},
+		{"past the last company", &runCheckpoint{CompanyID: 8, Page: 1}, []int64{1, 3, 5, 7}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			companies := []models.Company{{ID: 1}, {ID: 3}, {ID: 5}, {ID: 7}}
+			ids := []int64{}
+			for _, company := range rotateCompanies(companies, tt.checkpoint) {
+				ids = append(ids, company.ID)
+			}
+			if !slices.Equal(ids, tt.want) {
+				t.Errorf("rotateCompanies() = %v, want %v", ids, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecordBudgetExhaustion(t *testing.T) {
+	tests := []struct {
+		name          string
+		reason        string
+		nextPage      int
+		want          runCheckpoint
+		wantDocuments int64
+		wantDuration  int64
+	}{
+		{"resumes at the next page", budgetReasonDocuments, 4, runCheckpoint{CompanyID: 5, Page: 4}, 1, 0},
+		{"resumes at the next company", budgetReasonDuration, 0, runCheckpoint{CompanyID: 6, Page: 1}, 0, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scheduler := &NFSeScheduler{}
+			scheduler.recordBudgetExhaustion(tt.reason, 5, tt.nextPage)
+
+			if scheduler.checkpoint == nil || *scheduler.checkpoint != tt.want {
+				t.Fatalf("checkpoint = %+v, want %+v", scheduler.checkpoint, tt.want)
+			}
+			if scheduler.metrics.DocumentBudgetExhaustions != tt.wantDocuments || scheduler.metrics.DurationBudgetExhaustions != tt.wantDuration {
+				t.Errorf("exhaustions = %d/%d, want %d/%d", scheduler.metrics.DocumentBudgetExhaustions,
+					scheduler.metrics.DurationBudgetExhaustions, tt.wantDocuments, tt.wantDuration)
+			}
+
+			// The next run starts at the checkpoint company
+			companies := []models.Company{{ID: 1}, {ID: 5}, {ID: 6}, {ID: 9}}
+			if first := rotateCompanies(companies, scheduler.checkpoint)[0].ID; first != tt.want.CompanyID {
+				t.Errorf("next run starts at company %d, want %d", first, tt.want.CompanyID)
+			}
+		})
+	}
+}
+
+func TestFetchCompanyDocumentsWithoutCredentials(t *testing.T) {
+	databasetest.Require(t)
+	ctx := context.Background()
+	company := databasetest.CreateCompany(t, func(c *models.Company) { c.AutoFetch = true })
+	scheduler := &NFSeScheduler{config: config.Get()}
+
+	success, nextPage, err := scheduler.fetchCompanyDocuments(ctx, company, 1, nil)
+	if !errors.Is(err, ErrNoCredentials) {
+		t.Fatalf("fetchCompanyDocuments() error = %v, want %v", err, ErrNoCredentials)
+	}
+	if success || nextPage != 0 {
+		t.Errorf("fetchCompanyDocuments() = %v, %d, want a skipped company", success, nextPage)
+	}
+
+	stored := &models.Company{}
+	if err := database.DB.NewSelect().Model(stored).Where("id = ?", company.ID).Scan(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if stored.LastSyncStatus != models.SyncStatusNoCredentials {
+		t.Errorf("last_sync_status = %q, want %q", stored.LastSyncStatus, models.SyncStatusNoCredentials)
+	}
+	if !stored.LastSyncAt.IsZero() {
+		t.Errorf("last_sync_at = %v, want it left unset", stored.LastSyncAt)
+	}
+}
+
+func TestSyncOutcome(t *testing.T) {
+	fetchErr := errors.New("provider unavailable")
+
+	tests := []struct {
+		name            string
+		stop            string
+		fetchErr        error
+		failedDocuments int
+		want            string
+	}{
+		{"last page reached", stopComplete, nil, 0, models.SyncStatusSuccess},
+		{"fetch failed", stopIncomplete, fetchErr, 0, models.SyncStatusFailed},
+		{"page not successful", stopUnsuccessful, nil, 0, models.SyncStatusFailed},
+		{"documents not stored", stopComplete, nil, 3, models.SyncStatusPartial},
+		{"budget exhausted", stopBudget, nil, 0, models.SyncStatusPartial},
+		{"page limit reached", stopIncomplete, nil, 0, models.SyncStatusPartial},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, err := syncOutcome(tt.stop, tt.fetchErr, tt.failedDocuments)
+			if status != tt.want {
+				t.Errorf("syncOutcome() status = %q, want %q", status, tt.want)
+			}
+			if (err == nil) != (tt.want == models.SyncStatusSuccess) {
+				t.Errorf("syncOutcome() error = %v, want one unless the sync succeeded", err)
+			}
+		})
+	}
+}
+
+// pagedProvider reports each page as full, up to pages of them, and the page after as
+// empty. A page at unsuccessfulPage is reported as not successful.
+type pagedProvider struct {
+	pages            int
+	unsuccessfulPage int
+}
+
+func (p *pagedProvider) Capabilities() ProviderCapabilities {
+	return ProviderCapabilities{Name: "paged", CredentialTypes: []string{"prefeitura_token"}, Paginated: true}
+}
+
+func (p *pagedProvider) Authenticate(ctx context.Context, credential *models.CompanyCredential) (string, error) {
+	return "token", nil
+}
+
+func (p *pagedProvider) FetchDocuments(ctx context.Context, request ProviderFetchRequest, handle func([]NFSeDocument) error) (*NFSeProcessResult, error) {
+	switch {
+	case request.Page == p.unsuccessfulPage:
+		return &NFSeProcessResult{Success: false, Error: "provider error"}, nil
+	case request.Page > p.pages:
+		return &NFSeProcessResult{Success: true}, nil
+	}
+	return &NFSeProcessResult{Success: true, DocumentsCount: maxDocumentsPerPage}, nil
+}
+
+// TestFetchCompanyDocumentsLastSync checks that only a run reaching the last page moves
+// last_sync_at, so partially failed runs are retried by the next one
+func TestFetchCompanyDocumentsLastSync(t *testing.T) {
+	databasetest.Require(t)
+	ctx := context.Background()
+	previousSync := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		provider   *pagedProvider
+		maxPages   int
+		budget     *runBudget
+		wantStatus string
+		wantMoved  bool
+	}{
+		{"last page reached", &pagedProvider{pages: 1}, 5, nil, models.SyncStatusSuccess, true},
+		{"page not successful", &pagedProvider{pages: 3, unsuccessfulPage: 2}, 5, nil, models.SyncStatusFailed, false},
+		{"page limit with full pages", &pagedProvider{pages: 3}, 2, nil, models.SyncStatusPartial, false},
+		{"budget exhausted", &pagedProvider{pages: 3}, 5, newRunBudget(config.NFSeSchedulerConfig{MaxDocumentsPerRun: 1}, time.Now()), models.SyncStatusPartial, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			company := databasetest.CreateCompany(t, func(c *models.Company) {
+				c.MunicipalityCode = testMunicipalityCode
+				c.LastSyncAt = previousSync
+			})
+			databasetest.CreateCredential(t, &models.CompanyCredential{CompanyID: company.ID, Active: true})
+
+			RegisterMunicipalityProvider(testMunicipalityCode, func(*http.Client) MunicipalityProvider { return tt.provider })
+			t.Cleanup(func() {
+				municipalityProvidersMu.Lock()
+				delete(municipalityProviders, testMunicipalityCode)
+				municipalityProvidersMu.Unlock()
+			})
+
+			cfg := *config.Get()
+			cfg.NFSeScheduler.MaxPagesPerRun = tt.maxPages
+			cfg.NFSeScheduler.APIDelaySeconds = 0
+			companies := &repositorytest.CompanyRepository{Companies: map[int64]*models.Company{company.ID: company}}
+			scheduler := &NFSeScheduler{
+				config:      &cfg,
+				nfseService: NewNFSeServiceWithRepositories(nil, &repositorytest.DocumentRepository{}, companies),
+			}
+
+			if _, _, err := scheduler.fetchCompanyDocuments(ctx, company, 1, tt.budget); err != nil {
+				t.Fatalf("fetchCompanyDocuments() error = %v", err)
+			}
+
+			stored := &models.Company{}
+			if err := database.DB.NewSelect().Model(stored).Where("id = ?", company.ID).Scan(ctx); err != nil {
+				t.Fatal(err)
+			}
+			if stored.LastSyncStatus != tt.wantStatus {
+				t.Errorf("last_sync_status = %q, want %q", stored.LastSyncStatus, tt.wantStatus)
+			}
+			if moved := !stored.LastSyncAt.Equal(previousSync); moved != tt.wantMoved {
+				t.Errorf("last_sync_at = %v (was %v), moved = %v, want %v", stored.LastSyncAt, previousSync, moved, tt.wantMoved)
+			}
+		})
+	}
+}
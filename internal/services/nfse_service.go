@@ -1,47 +1,47 @@
 package services
 
 import (
-	"archive/zip"
-	"bytes"
 	"context"
-	"encoding/base64"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"net/http"
 	"time"
 
+	"github.com/uptrace/bun"
+	"github.com/zoomxml/config"
+	"github.com/zoomxml/internal/database"
 	"github.com/zoomxml/internal/logger"
 	"github.com/zoomxml/internal/models"
+	"github.com/zoomxml/internal/repository"
 )
 
+// ErrNoCredentials is returned when a company has no active credential usable for fetching
+var ErrNoCredentials = errors.New("no active NFSe credentials for company")
+
+// ErrEnvironmentMismatch is returned under the "block" environment policy when every
+// active credential targets another environment than the company default. It wraps
+// ErrNoCredentials, since no credential is usable.
+var ErrEnvironmentMismatch = fmt.Errorf("%w: active credentials target another environment", ErrNoCredentials)
+
+// ErrCredentialRejected is returned when the provider refuses the credential of a request
+// (HTTP 401), e.g. an expired token. A 403 is not taken as a rejection, since it may come
+// from a permission or network policy of the provider.
+var ErrCredentialRejected = errors.New("provider rejected the credential")
+
+// NoCredentialsMessage is the actionable message shown to API clients for ErrNoCredentials
+const NoCredentialsMessage = "Add an active prefeitura_token credential to this company to enable NFSe fetching"
+
 // NFSeService handles NFSe API operations
 type NFSeService struct {
 	client     *http.Client
 	xmlManager *NFSeXMLManager
 }
 
-// PrefeituraModernaResponse represents the actual response from Prefeitura Moderna API
-type PrefeituraModernaResponse struct {
-	RecordCount    int                    `json:"RecordCount"`
-	RecordsPerPage int                    `json:"RecordsPerPage"`
-	PageCount      int                    `json:"PageCount"`
-	CurrentPage    int                    `json:"CurrentPage"`
-	Dados          []PrefeituraModernaDoc `json:"Dados"`
-}
-
-// PrefeituraModernaDoc represents a single NFSe document from the API
-type PrefeituraModernaDoc struct {
-	NrNfse        int    `json:"NrNfse"`        // Número da NFSe
-	DtEmissao     string `json:"DtEmissao"`     // Data de emissão
-	NrCompetencia int    `json:"NrCompetencia"` // Competência YYYYMM
-	XmlCompactado string `json:"XmlCompactado"` // ZIP em Base64 contendo o XML
-}
-
 // NFSeDocument represents a processed NFSe document
 type NFSeDocument struct {
-	FileName    string    `json:"file_name"`   // Nome do arquivo XML
-	XMLContent  string    `json:"xml_content"` // Conteúdo XML
+	FileName    string    `json:"file_name"`            // Nome do arquivo XML
+	XMLContent  string    `json:"xml_content"`          // Conteúdo XML
+	Competence  string    `json:"competence,omitempty"` // Competência informada pelo provedor (YYYY-MM)
 	ProcessedAt time.Time `json:"processed_at"`
 }
 
@@ -50,221 +50,331 @@ type NFSeProcessResult struct {
 	Success        bool           `json:"success"`
 	Message        string         `json:"message"`
 	DocumentsCount int            `json:"documents_count"`
+	FailedCount    int            `json:"failed_count,omitempty"` // documents that could not be stored and may succeed on a retry
 	Documents      []NFSeDocument `json:"documents,omitempty"`
 	Error          string         `json:"error,omitempty"`
 }
 
-// NewNFSeService creates a new NFSe service instance
-func NewNFSeService() *NFSeService {
+// NewNFSeService creates a new NFSe service instance using the given transport
+// (usually SharedTransport())
+func NewNFSeService(transport http.RoundTripper) *NFSeService {
+	return NewNFSeServiceWithRepositories(transport, repository.NewDocumentRepository(), repository.NewCompanyRepository())
+}
+
+// NewNFSeServiceWithRepositories creates an NFSe service that stores documents, and reads
+// the company settings, through the given repositories
+func NewNFSeServiceWithRepositories(transport http.RoundTripper, documents repository.DocumentRepository, companies repository.CompanyRepository) *NFSeService {
 	return &NFSeService{
-		client: &http.Client{
-			Timeout: 30 * time.Second,
-		},
-		xmlManager: NewNFSeXMLManager(),
+		client:     newHTTPClient(transport, 30*time.Second),
+		xmlManager: NewNFSeXMLManagerWithRepositories(documents, companies),
 	}
 }
 
-// extractXMLFromZip extracts XML files from a Base64 encoded ZIP
-func (s *NFSeService) extractXMLFromZip(base64Zip string) ([]NFSeDocument, error) {
-	// Decode Base64
-	zipData, err := base64.StdEncoding.DecodeString(base64Zip)
+// GetFetchCredentials returns the active credentials of a company whose types the provider
+// of its municipality accepts, the ones matching the company default environment first.
+// It returns ErrNoCredentials when the company has none and ErrUnsupportedMunicipality
+// when no provider serves it. Using a credential of another environment is logged, or
+// refused with ErrEnvironmentMismatch under COMPANY_ENVIRONMENT_MISMATCH=block.
+func GetFetchCredentials(ctx context.Context, companyID int64) ([]models.CompanyCredential, error) {
+	provider, err := resolveMunicipalityProvider(ctx, repository.NewCompanyRepository(), companyID, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode base64: %w", err)
+		return nil, err
 	}
+	types := provider.Capabilities().CredentialTypes
+
+	credentials := []models.CompanyCredential{}
+	err = database.DB.NewSelect().
+		Model(&credentials).
+		Where("company_id = ? AND active = true", companyID).
+		Where("type IN (?)", bun.In(types)).
+		Order("id ASC").
+		Scan(ctx)
 
-	// Create a reader for the ZIP data
-	zipReader, err := zip.NewReader(bytes.NewReader(zipData), int64(len(zipData)))
 	if err != nil {
-		return nil, fmt.Errorf("failed to create zip reader: %w", err)
+		return nil, fmt.Errorf("failed to fetch company credentials: %w", err)
 	}
 
-	var documents []NFSeDocument
+	if len(credentials) == 0 {
+		return nil, ErrNoCredentials
+	}
 
-	// Extract each file from the ZIP
-	for _, file := range zipReader.File {
-		// Open the file
-		rc, err := file.Open()
-		if err != nil {
-			logger.ErrorWithFields("Failed to open file in ZIP", err, map[string]any{
-				"operation": "extract_xml",
-				"file_name": file.Name,
-			})
-			continue
-		}
+	var environment string
+	err = database.DB.NewSelect().
+		Model((*models.Company)(nil)).
+		Column("default_environment").
+		Where("id = ?", companyID).
+		Scan(ctx, &environment)
 
-		// Read the file content
-		content, err := io.ReadAll(rc)
-		rc.Close()
-		if err != nil {
-			logger.ErrorWithFields("Failed to read file content", err, map[string]any{
-				"operation": "extract_xml",
-				"file_name": file.Name,
-			})
-			continue
-		}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load company environment: %w", err)
+	}
+
+	return selectEnvironmentCredentials(companyID, environment, credentials, config.Get().Company.EnvironmentMismatch)
+}
+
+// selectEnvironmentCredentials orders credentials so the ones for the company environment
+// come first and applies the mismatch policy (warn or block) to the rest. Credentials
+// without an environment are considered to match.
+func selectEnvironmentCredentials(companyID int64, environment string, credentials []models.CompanyCredential, policy string) ([]models.CompanyCredential, error) {
+	matches := func(credential models.CompanyCredential) bool {
+		return environment == "" || credential.Environment == "" || credential.Environment == environment
+	}
 
-		// Create document
-		document := NFSeDocument{
-			FileName:    file.Name,
-			XMLContent:  string(content),
-			ProcessedAt: time.Now(),
+	selected := make([]models.CompanyCredential, 0, len(credentials))
+	mismatched := []models.CompanyCredential{}
+	for _, credential := range credentials {
+		if matches(credential) {
+			selected = append(selected, credential)
+		} else {
+			mismatched = append(mismatched, credential)
 		}
+	}
 
-		documents = append(documents, document)
+	if policy == models.EnvironmentMismatchBlock {
+		if len(selected) == 0 {
+			return nil, ErrEnvironmentMismatch
+		}
+		return selected, nil
+	}
 
-		logger.DebugWithFields("XML file extracted", map[string]any{
-			"operation":    "extract_xml",
-			"file_name":    file.Name,
-			"content_size": len(content),
+	if len(selected) == 0 {
+		logger.WarnWithFields("Fetching with a credential of another environment than the company default", map[string]any{
+			"operation":              "fetch_credentials",
+			"company_id":             companyID,
+			"company_environment":    environment,
+			"credential_id":          mismatched[0].ID,
+			"credential_environment": mismatched[0].Environment,
 		})
 	}
 
-	return documents, nil
+	return append(selected, mismatched...), nil
 }
 
 // FetchNFSeDocuments fetches NFSe documents from the municipal API
 func (s *NFSeService) FetchNFSeDocuments(ctx context.Context, credential *models.CompanyCredential, startDate, endDate time.Time, page int) (*NFSeProcessResult, error) {
-	// Get the API token from encrypted credentials
-	_, _, token, err := credential.GetCredentialData()
-	if err != nil {
-		logger.ErrorWithFields("Failed to decrypt credential data", err, map[string]any{
-			"operation":     "fetch_nfse",
-			"credential_id": credential.ID,
-			"company_id":    credential.CompanyID,
-		})
-		return nil, fmt.Errorf("failed to decrypt credential data: %w", err)
+	var allDocuments []NFSeDocument
+
+	result, err := s.fetchNFSePage(ctx, credential, startDate, endDate, page, func(documents []NFSeDocument) error {
+		allDocuments = append(allDocuments, documents...)
+		return nil
+	})
+	if err != nil || !result.Success {
+		return result, err
 	}
 
-	if token == "" {
-		return nil, fmt.Errorf("API token not found in credentials")
+	result.Documents = allDocuments
+	return result, nil
+}
+
+// FetchAndStoreNFSeDocuments fetches a page from the municipal API and stores its XMLs
+// as they are extracted, holding at most NFSE_MAX_IN_FLIGHT_DOCUMENTS in memory instead
+// of the whole page. The result carries the count but not the documents.
+func (s *NFSeService) FetchAndStoreNFSeDocuments(ctx context.Context, credential *models.CompanyCredential, startDate, endDate time.Time, page int) (*NFSeProcessResult, error) {
+	failedCount := 0
+	result, err := s.fetchAndStorePage(ctx, credential, startDate, endDate, page, func(_ []NFSeDocument, batch *BatchProcessingResult) {
+		failedCount += retryableCount(batch)
+	})
+	if err != nil || !result.Success {
+		return result, err
 	}
 
-	// Build the API URL with pagination
-	baseURL := "https://api-nfse-imperatriz-ma.prefeituramoderna.com.br/ws/services/xmlnfse"
-	url := fmt.Sprintf("%s?dt_inicial=%s&dt_final=%s&nr_page=%d",
-		baseURL,
-		startDate.Format("2006-01-02"),
-		endDate.Format("2006-01-02"),
-		page,
-	)
+	result.FailedCount = failedCount
+	return result, nil
+}
 
-	// Create the request
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	// Set headers
-	req.Header.Set("Authorization", token)
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("User-Agent", "ZoomXML/1.0.0")
-
-	logger.InfoWithFields("Making NFSe API request", map[string]any{
-		"operation":     "fetch_nfse",
-		"url":           url,
-		"company_id":    credential.CompanyID,
-		"credential_id": credential.ID,
-		"start_date":    startDate.Format("2006-01-02"),
-		"end_date":      endDate.Format("2006-01-02"),
+// fetchAndStorePage fetches a page from the municipal API and stores its XMLs in batches
+// of at most NFSE_MAX_IN_FLIGHT_DOCUMENTS, handing each stored batch and its outcome to
+// stored
+func (s *NFSeService) fetchAndStorePage(ctx context.Context, credential *models.CompanyCredential, startDate, endDate time.Time, page int, stored func([]NFSeDocument, *BatchProcessingResult)) (*NFSeProcessResult, error) {
+	maxInFlight := config.Get().NFSeScheduler.MaxInFlightDocuments
+	if maxInFlight < 1 {
+		maxInFlight = 1
+	}
+
+	inFlight := make([]NFSeDocument, 0, maxInFlight)
+	flush := func() error {
+		if len(inFlight) == 0 {
+			return nil
+		}
+		batch, err := s.storeNFSeDocuments(ctx, credential.CompanyID, inFlight)
+		if err != nil {
+			return fmt.Errorf("failed to store NFSe documents: %w", err)
+		}
+		stored(inFlight, batch)
+		inFlight = inFlight[:0]
+		return nil
+	}
+
+	result, err := s.fetchNFSePage(ctx, credential, startDate, endDate, page, func(documents []NFSeDocument) error {
+		for _, document := range documents {
+			inFlight = append(inFlight, document)
+			if len(inFlight) >= maxInFlight {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
 	})
+	if err != nil || !result.Success {
+		return result, err
+	}
 
-	// Make the request
-	resp, err := s.client.Do(req)
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// fetchNFSePage queries one page of the municipality provider of the company and hands
+// the XMLs of each provider record to handle as soon as they are extracted
+func (s *NFSeService) fetchNFSePage(ctx context.Context, credential *models.CompanyCredential, startDate, endDate time.Time, page int, handle func([]NFSeDocument) error) (*NFSeProcessResult, error) {
+	provider, err := resolveMunicipalityProvider(ctx, s.xmlManager.companies, credential.CompanyID, s.client)
 	if err != nil {
-		logger.ErrorWithFields("NFSe API request failed", err, map[string]any{
-			"operation":  "fetch_nfse",
-			"url":        url,
-			"company_id": credential.CompanyID,
-		})
-		return nil, fmt.Errorf("API request failed: %w", err)
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	// Read response body
-	body, err := io.ReadAll(resp.Body)
+	authorization, err := authenticateProvider(ctx, provider, credential)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
+		return nil, err
 	}
 
-	logger.InfoWithFields("NFSe API response received", map[string]any{
-		"operation":     "fetch_nfse",
-		"status_code":   resp.StatusCode,
-		"company_id":    credential.CompanyID,
-		"response_size": len(body),
-	})
+	return provider.FetchDocuments(ctx, ProviderFetchRequest{
+		Credential:    credential,
+		Authorization: authorization,
+		StartDate:     startDate,
+		EndDate:       endDate,
+		Page:          page,
+	}, handle)
+}
 
-	// Check HTTP status
-	if resp.StatusCode != http.StatusOK {
-		logger.ErrorWithFields("NFSe API returned error status", nil, map[string]any{
-			"operation":   "fetch_nfse",
-			"status_code": resp.StatusCode,
-			"response":    string(body),
-			"company_id":  credential.CompanyID,
+// markCredentialUsed records that a credential was used against the provider. Failures
+// are logged and never interrupt the fetch.
+func markCredentialUsed(ctx context.Context, credentialID int64) {
+	_, err := database.DB.NewUpdate().
+		Model((*models.CompanyCredential)(nil)).
+		Set("last_used_at = ?", time.Now()).
+		Where("id = ?", credentialID).
+		Exec(ctx)
+
+	if err != nil {
+		logger.ErrorWithFields("Failed to record credential use", err, map[string]any{
+			"operation":     "fetch_nfse",
+			"credential_id": credentialID,
 		})
-		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
+	}
+}
+
+// recordCredentialFailure counts a provider rejection of a credential and deactivates it
+// once NFSE_CREDENTIAL_FAILURE_THRESHOLD consecutive rejections are reached. It reports
+// whether the credential was deactivated. Failures are logged and never interrupt the fetch.
+func recordCredentialFailure(ctx context.Context, credential *models.CompanyCredential) bool {
+	threshold := config.Get().NFSeScheduler.CredentialFailureThreshold
+
+	credential.FailureCount++
+	deactivate := threshold > 0 && credential.FailureCount >= threshold
+	reason := fmt.Sprintf("rejected by the provider %d consecutive times", credential.FailureCount)
+
+	query := database.DB.NewUpdate().
+		Model((*models.CompanyCredential)(nil)).
+		Set("failure_count = failure_count + 1").
+		Set("updated_at = current_timestamp").
+		Where("id = ?", credential.ID)
+	if deactivate {
+		query = query.Set("active = false").Set("disabled_reason = ?", reason)
 	}
 
-	// Parse JSON response from Prefeitura Moderna
-	var apiResponse PrefeituraModernaResponse
-	if err := json.Unmarshal(body, &apiResponse); err != nil {
-		logger.ErrorWithFields("Failed to parse JSON response", err, map[string]any{
-			"operation":  "fetch_nfse",
-			"company_id": credential.CompanyID,
-			"response":   string(body),
+	if _, err := query.Exec(ctx); err != nil {
+		logger.ErrorWithFields("Failed to record credential failure", err, map[string]any{
+			"operation":     "fetch_nfse",
+			"credential_id": credential.ID,
 		})
-		return &NFSeProcessResult{
-			Success: false,
-			Message: "Failed to parse API response",
-			Error:   err.Error(),
-		}, nil
+		return false
 	}
 
-	var allDocuments []NFSeDocument
+	if deactivate {
+		credential.Active = false
+		credential.DisabledReason = reason
+		logger.WarnWithFields("Credential deactivated after repeated provider rejections", map[string]any{
+			"operation":     "fetch_nfse",
+			"company_id":    credential.CompanyID,
+			"credential_id": credential.ID,
+			"failures":      credential.FailureCount,
+		})
+		logger.LogSecurityEvent(ctx, nil, "credential_auto_deactivated",
+			fmt.Sprintf("company %d credential %d %s", credential.CompanyID, credential.ID, reason))
+	}
+	return deactivate
+}
 
-	// Process each NFSe document
-	for _, nfseDoc := range apiResponse.Dados {
-		if nfseDoc.XmlCompactado == "" {
-			logger.WarnWithFields("Empty XmlCompactado found", map[string]any{
-				"operation":  "fetch_nfse",
-				"company_id": credential.CompanyID,
-				"nfse_nr":    nfseDoc.NrNfse,
-			})
-			continue
-		}
+// resetCredentialFailures clears the rejection count of a credential the provider accepted
+func resetCredentialFailures(ctx context.Context, credential *models.CompanyCredential) {
+	_, err := database.DB.NewUpdate().
+		Model((*models.CompanyCredential)(nil)).
+		Set("failure_count = 0").
+		Where("id = ?", credential.ID).
+		Exec(ctx)
 
-		// Extract XML files from ZIP
-		documents, err := s.extractXMLFromZip(nfseDoc.XmlCompactado)
-		if err != nil {
-			logger.ErrorWithFields("Failed to extract XML from ZIP", err, map[string]any{
-				"operation":  "fetch_nfse",
-				"company_id": credential.CompanyID,
-				"nfse_nr":    nfseDoc.NrNfse,
-			})
-			continue
-		}
+	if err != nil {
+		logger.ErrorWithFields("Failed to reset credential failures", err, map[string]any{
+			"operation":     "fetch_nfse",
+			"credential_id": credential.ID,
+		})
+		return
+	}
+	credential.FailureCount = 0
+}
 
-		allDocuments = append(allDocuments, documents...)
+// WithCredentialFailover runs call with each credential in turn until the provider accepts
+// one. Each rejection is recorded on its credential before the next one is tried, so an
+// expired token does not fail a manual request while another credential is valid. It
+// returns the credential of the last call.
+func WithCredentialFailover(ctx context.Context, credentials []models.CompanyCredential, call func(credential *models.CompanyCredential) error) (*models.CompanyCredential, error) {
+	if len(credentials) == 0 {
+		return nil, ErrNoCredentials
 	}
 
-	logger.InfoWithFields("NFSe documents fetched successfully", map[string]any{
-		"operation":       "fetch_nfse",
-		"company_id":      credential.CompanyID,
-		"documents_count": len(allDocuments),
-		"page":            page,
-		"total_records":   apiResponse.RecordCount,
-	})
+	var err error
+	for i := range credentials {
+		credential := &credentials[i]
+		if err = call(credential); !errors.Is(err, ErrCredentialRejected) {
+			return credential, err
+		}
 
-	return &NFSeProcessResult{
-		Success:        true,
-		Message:        fmt.Sprintf("Successfully fetched %d documents from page %d", len(allDocuments), page),
-		DocumentsCount: len(allDocuments),
-		Documents:      allDocuments,
-	}, nil
+		recordCredentialFailure(ctx, credential)
+		if i+1 < len(credentials) {
+			logger.WarnWithFields("Credential rejected by provider, trying the next one", map[string]any{
+				"operation":       "fetch_nfse",
+				"company_id":      credential.CompanyID,
+				"credential_id":   credential.ID,
+				"next_credential": credentials[i+1].ID,
+				"failure_count":   credential.FailureCount,
+			})
+		}
+	}
+	return &credentials[len(credentials)-1], err
 }
 
 // StoreNFSeDocuments stores NFSe documents using intelligent XML management with deduplication
 func (s *NFSeService) StoreNFSeDocuments(ctx context.Context, companyID int64, documents []NFSeDocument) error {
+	_, err := s.storeNFSeDocuments(ctx, companyID, documents)
+	return err
+}
+
+// retryableCount returns how many documents of a batch failed with an error a later
+// attempt may recover from (see ProcessingResult.Retryable)
+func retryableCount(batch *BatchProcessingResult) int {
+	failed := 0
+	for _, docResult := range batch.Results {
+		if docResult.Retryable() {
+			failed++
+		}
+	}
+	return failed
+}
+
+// storeNFSeDocuments stores NFSe documents and returns the outcome of each one
+func (s *NFSeService) storeNFSeDocuments(ctx context.Context, companyID int64, documents []NFSeDocument) (*BatchProcessingResult, error) {
 	logger.InfoWithFields("Storing NFSe documents with intelligent deduplication", map[string]any{
 		"operation":       "store_nfse_intelligent",
 		"company_id":      companyID,
@@ -272,26 +382,44 @@ func (s *NFSeService) StoreNFSeDocuments(ctx context.Context, companyID int64, d
 	})
 
 	if len(documents) == 0 {
-		return nil
+		return &BatchProcessingResult{}, nil
 	}
 
 	// Convert NFSeDocument to XMLDocument for batch processing
 	xmlDocuments := make([]XMLDocument, len(documents))
 	for i, doc := range documents {
 		xmlDocuments[i] = XMLDocument{
-			FileName: doc.FileName,
-			Content:  doc.XMLContent,
+			FileName:   doc.FileName,
+			Content:    doc.XMLContent,
+			Competence: doc.Competence,
 		}
 	}
 
 	// Use intelligent XML manager for batch processing
-	result, err := s.xmlManager.ProcessBatchXML(ctx, companyID, xmlDocuments)
+	result, err := s.xmlManager.ProcessBatchXML(ctx, companyID, BatchOptions{Source: ProcessingSourcePrefeituraAPI}, xmlDocuments)
 	if err != nil {
 		logger.ErrorWithFields("Failed to process batch XML", err, map[string]any{
 			"operation":  "store_nfse_intelligent",
 			"company_id": companyID,
 		})
-		return err
+		return nil, err
+	}
+
+	// Keep the XMLs that could not be stored so they are not lost while storage is down
+	if config.Get().Storage.PendingIngestEnabled {
+		pending := make([]XMLDocument, 0)
+		for i, docResult := range result.Results {
+			if docResult.StorageFailed {
+				pending = append(pending, xmlDocuments[i])
+			}
+		}
+		if err := bufferPendingIngests(ctx, companyID, ProcessingSourcePrefeituraAPI, pending); err != nil {
+			logger.ErrorWithFields("Failed to buffer documents pending storage", err, map[string]any{
+				"operation":       "store_nfse_intelligent",
+				"company_id":      companyID,
+				"documents_count": len(pending),
+			})
+		}
 	}
 
 	// Log detailed results
@@ -332,5 +460,43 @@ func (s *NFSeService) StoreNFSeDocuments(ctx context.Context, companyID int64, d
 		}
 	}
 
-	return nil
+	return result, nil
+}
+
+// PreviewDuplicateCheck reports whether an XML would be rejected as a duplicate for the
+// company, without storing it
+func (s *NFSeService) PreviewDuplicateCheck(ctx context.Context, companyID int64, xmlContent string) (*DuplicatePreview, error) {
+	return s.xmlManager.PreviewDuplicateCheck(ctx, companyID, xmlContent)
+}
+
+// GetDuplicateStatistics returns the cached duplicate detection statistics of a company
+// over a window
+func (s *NFSeService) GetDuplicateStatistics(ctx context.Context, companyID int64, window DuplicateStatsWindow) (map[string]any, error) {
+	return s.xmlManager.GetDuplicateStatistics(ctx, companyID, window)
+}
+
+// ReprocessDeadLetter ingests a dead-lettered XML again
+func (s *NFSeService) ReprocessDeadLetter(ctx context.Context, deadLetter *models.DeadLetter) (*ProcessingResult, error) {
+	return s.xmlManager.ReprocessDeadLetter(ctx, deadLetter)
+}
+
+// ImportUploadedDocuments stores XML documents uploaded manually by a user.
+// With overwrite, documents that already exist are replaced instead of skipped.
+// The uploaded file names are only recorded on the documents; storage keys come from
+// the notes, so uploads sharing a file name never overwrite each other.
+func (s *NFSeService) ImportUploadedDocuments(ctx context.Context, companyID int64, documents []NFSeDocument, overwrite bool) (*BatchProcessingResult, error) {
+	xmlDocuments := make([]XMLDocument, len(documents))
+	for i, doc := range documents {
+		xmlDocuments[i] = XMLDocument{
+			FileName: doc.FileName,
+			Content:  doc.XMLContent,
+		}
+	}
+
+	opts := BatchOptions{
+		Source:    ProcessingSourceManualUpload,
+		Overwrite: overwrite,
+	}
+
+	return s.xmlManager.ProcessBatchXML(ctx, companyID, opts, xmlDocuments)
 }
@@ -0,0 +1,237 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/zoomxml/config"
+	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/database/databasetest"
+	"github.com/zoomxml/internal/models"
+	"github.com/zoomxml/internal/repository/repositorytest"
+)
+
+func TestSelectEnvironmentCredentials(t *testing.T) {
+	production := models.CompanyCredential{ID: 1, Environment: models.EnvironmentProduction}
+	staging := models.CompanyCredential{ID: 2, Environment: models.EnvironmentStaging}
+	unset := models.CompanyCredential{ID: 3}
+
+	tests := []struct {
+		name        string
+		environment string
+		credentials []models.CompanyCredential
+		policy      string
+		want        []int64
+		wantErr     error
+	}{
+		{"matching first", models.EnvironmentProduction, []models.CompanyCredential{staging, production}, models.EnvironmentMismatchWarn, []int64{1, 2}, nil},
+		{"credential without environment matches", models.EnvironmentProduction, []models.CompanyCredential{staging, unset}, models.EnvironmentMismatchWarn, []int64{3, 2}, nil},
+		{"company without environment matches all", "", []models.CompanyCredential{staging, production}, models.EnvironmentMismatchBlock, []int64{2, 1}, nil},
+		{"warn falls back to mismatched", models.EnvironmentProduction, []models.CompanyCredential{staging}, models.EnvironmentMismatchWarn, []int64{2}, nil},
+		{"block drops mismatched", models.EnvironmentProduction, []models.CompanyCredential{staging, production}, models.EnvironmentMismatchBlock, []int64{1}, nil},
+		{"block without match", models.EnvironmentProduction, []models.CompanyCredential{staging}, models.EnvironmentMismatchBlock, nil, ErrEnvironmentMismatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := selectEnvironmentCredentials(1, tt.environment, tt.credentials, tt.policy)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("selectEnvironmentCredentials() error = %v, want %v", err, tt.wantErr)
+			}
+
+			ids := []int64{}
+			for _, credential := range got {
+				ids = append(ids, credential.ID)
+			}
+			if tt.want == nil {
+				tt.want = []int64{}
+			}
+			if !slices.Equal(ids, tt.want) {
+				t.Errorf("selectEnvironmentCredentials() = %v, want %v", ids, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithCredentialFailover(t *testing.T) {
+	if _, err := WithCredentialFailover(context.Background(), nil, nil); !errors.Is(err, ErrNoCredentials) {
+		t.Errorf("WithCredentialFailover(no credentials) error = %v, want %v", err, ErrNoCredentials)
+	}
+
+	databasetest.Require(t)
+	ctx := context.Background()
+	cfg := config.Get()
+	threshold := cfg.NFSeScheduler.CredentialFailureThreshold
+	cfg.NFSeScheduler.CredentialFailureThreshold = 2
+	t.Cleanup(func() { cfg.NFSeScheduler.CredentialFailureThreshold = threshold })
+
+	tests := []struct {
+		name         string
+		failures     []int // stored failure count of each credential
+		accepted     int   // index of the credential the provider accepts, -1 for none
+		wantErr      error
+		wantFailures []int  // failure count of each credential afterwards
+		wantActive   []bool // whether each credential is still active afterwards
+	}{
+		{"first accepted resets failures", []int{1, 0}, 0, nil, []int{0, 0}, []bool{true, true}},
+		{"rejection below threshold fails over", []int{0, 1}, 1, nil, []int{1, 0}, []bool{true, true}},
+		{"rejection at threshold deactivates", []int{1, 0}, 1, nil, []int{2, 0}, []bool{false, true}},
+		{"every credential rejected", []int{0, 0}, -1, ErrCredentialRejected, []int{1, 1}, []bool{true, true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			company := databasetest.CreateCompany(t, nil)
+			credentials := make([]models.CompanyCredential, len(tt.failures))
+			for i, failures := range tt.failures {
+				credentials[i] = models.CompanyCredential{CompanyID: company.ID, Type: "prefeitura_token", Name: "test", Active: true, FailureCount: failures}
+				if _, err := database.DB.NewInsert().Model(&credentials[i]).Exec(ctx); err != nil {
+					t.Fatalf("failed to create credential: %v", err)
+				}
+			}
+			t.Cleanup(func() {
+				database.DB.NewDelete().Model((*models.CompanyCredential)(nil)).Where("company_id = ?", company.ID).Exec(ctx)
+			})
+
+			// The provider resets the failures of the credential it accepts
+			used, err := WithCredentialFailover(ctx, credentials, func(credential *models.CompanyCredential) error {
+				if tt.accepted < 0 || credential.ID != credentials[tt.accepted].ID {
+					return ErrCredentialRejected
+				}
+				resetCredentialFailures(ctx, credential)
+				return nil
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("WithCredentialFailover() error = %v, want %v", err, tt.wantErr)
+			}
+			wantUsed := len(credentials) - 1
+			if tt.accepted >= 0 {
+				wantUsed = tt.accepted
+			}
+			if used.ID != credentials[wantUsed].ID {
+				t.Errorf("WithCredentialFailover() credential = %d, want %d", used.ID, credentials[wantUsed].ID)
+			}
+
+			for i, credential := range credentials {
+				stored := &models.CompanyCredential{}
+				if err := database.DB.NewSelect().Model(stored).Where("id = ?", credential.ID).Scan(ctx); err != nil {
+					t.Fatal(err)
+				}
+				if stored.FailureCount != tt.wantFailures[i] || stored.Active != tt.wantActive[i] {
+					t.Errorf("credential %d failures/active = %d/%v, want %d/%v",
+						i, stored.FailureCount, stored.Active, tt.wantFailures[i], tt.wantActive[i])
+				}
+				if !stored.Active && stored.DisabledReason == "" {
+					t.Errorf("credential %d deactivated without a reason", i)
+				}
+			}
+		})
+	}
+}
+
+// streamingProvider serves a first page of records, handing the XML of each record to
+// handle separately, as the Prefeitura Moderna provider does, and records how many
+// documents were stored when each record was handed over. Later pages are empty.
+type streamingProvider struct {
+	records   int      // number of generated records, unless xmls is set
+	xmls      []string // XML of each record
+	documents *repositorytest.DocumentRepository
+	stored    []int // stored documents after each record was handled
+	requests  []ProviderFetchRequest
+}
+
+func (p *streamingProvider) Capabilities() ProviderCapabilities {
+	return ProviderCapabilities{Name: "streaming", CredentialTypes: []string{"prefeitura_token"}, Paginated: true}
+}
+
+func (p *streamingProvider) Authenticate(ctx context.Context, credential *models.CompanyCredential) (string, error) {
+	return "token", nil
+}
+
+func (p *streamingProvider) FetchDocuments(ctx context.Context, request ProviderFetchRequest, handle func([]NFSeDocument) error) (*NFSeProcessResult, error) {
+	p.requests = append(p.requests, request)
+	if request.Page > 1 {
+		return &NFSeProcessResult{Success: true}, nil
+	}
+
+	if p.xmls == nil {
+		for i := 1; i <= p.records; i++ {
+			p.xmls = append(p.xmls, testNFSeXML(fmt.Sprint(i), fmt.Sprintf("V%d", i), "11111111000111", "12345678000190", "10.00"))
+		}
+	}
+	for i, content := range p.xmls {
+		document := NFSeDocument{FileName: fmt.Sprintf("nfse_%d.xml", i+1), XMLContent: content}
+		if err := handle([]NFSeDocument{document}); err != nil {
+			return nil, err
+		}
+		p.stored = append(p.stored, len(p.documents.Documents))
+	}
+	return &NFSeProcessResult{Success: true, DocumentsCount: len(p.xmls)}, nil
+}
+
+// testCredential is a token credential of company 1
+func testCredential() *models.CompanyCredential {
+	return &models.CompanyCredential{ID: 1, CompanyID: 1, Type: "prefeitura_token"}
+}
+
+// useStreamingProvider registers provider for testMunicipalityCode while the test runs
+// and returns a service whose company 1 (CNPJ 12345678000190) is served by it
+func useStreamingProvider(t *testing.T, provider *streamingProvider) *NFSeService {
+	t.Helper()
+	if provider.documents == nil {
+		provider.documents = &repositorytest.DocumentRepository{}
+	}
+	RegisterMunicipalityProvider(testMunicipalityCode, func(*http.Client) MunicipalityProvider { return provider })
+	t.Cleanup(func() {
+		municipalityProvidersMu.Lock()
+		delete(municipalityProviders, testMunicipalityCode)
+		municipalityProvidersMu.Unlock()
+	})
+
+	companies := &repositorytest.CompanyRepository{Companies: map[int64]*models.Company{
+		1: {ID: 1, CNPJ: "12345678000190", MunicipalityCode: testMunicipalityCode},
+	}}
+	return NewNFSeServiceWithRepositories(nil, provider.documents, companies)
+}
+
+func TestFetchAndStorePageIncrementally(t *testing.T) {
+	const records, maxInFlight = 23, 5
+	memory := useFakeIngest(t)
+
+	cfg := &config.Get().NFSeScheduler
+	previous := cfg.MaxInFlightDocuments
+	cfg.MaxInFlightDocuments = maxInFlight
+	t.Cleanup(func() { cfg.MaxInFlightDocuments = previous })
+
+	documents := &repositorytest.DocumentRepository{}
+	provider := &streamingProvider{records: records, documents: documents}
+	service := useStreamingProvider(t, provider)
+
+	var batches []int
+	now := time.Now()
+	result, err := service.fetchAndStorePage(context.Background(), testCredential(), now, now, 1, func(stored []NFSeDocument, batch *BatchProcessingResult) {
+		batches = append(batches, len(stored))
+	})
+	if err != nil || !result.Success {
+		t.Fatalf("fetchAndStorePage() = %+v, %v", result, err)
+	}
+
+	// Documents are stored as soon as maxInFlight of them are extracted, never holding more
+	for i, stored := range provider.stored {
+		handled := i + 1
+		if want := handled / maxInFlight * maxInFlight; stored != want {
+			t.Errorf("stored after record %d = %d, want %d", handled, stored, want)
+		}
+	}
+	if want := []int{5, 5, 5, 5, 3}; !slices.Equal(batches, want) {
+		t.Errorf("stored batches = %v, want %v", batches, want)
+	}
+	if len(documents.Documents) != records || len(memory.objects) != records {
+		t.Errorf("stored %d documents and %d objects, want %d", len(documents.Documents), len(memory.objects), records)
+	}
+}
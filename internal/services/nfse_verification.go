@@ -0,0 +1,141 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/zoomxml/config"
+	"github.com/zoomxml/internal/logger"
+	"github.com/zoomxml/internal/models"
+)
+
+// ErrProviderUnavailable is returned when the provider could not be queried
+var ErrProviderUnavailable = errors.New("NFSe provider unavailable")
+
+// Verification outcomes
+const (
+	VerificationMatch    = "match"
+	VerificationMismatch = "mismatch"
+	VerificationNotFound = "not_found" // the provider did not return the note
+)
+
+// FieldMismatch is one field whose stored value differs from the provider record
+type FieldMismatch struct {
+	Field    string `json:"field"`
+	Stored   string `json:"stored"`
+	Provider string `json:"provider"`
+}
+
+// VerificationReport compares a stored document against the provider record
+type VerificationReport struct {
+	DocumentID       int64           `json:"document_id"`
+	Number           string          `json:"number"`
+	VerificationCode string          `json:"verification_code"`
+	Status           string          `json:"status"`
+	Mismatches       []FieldMismatch `json:"mismatches"`
+	CheckedAt        time.Time       `json:"checked_at"`
+}
+
+// VerifyDocument re-queries the provider on the issue date of a stored NFSe and compares
+// its key fields with the provider record. Provider failures wrap ErrProviderUnavailable,
+// and ErrCredentialRejected too when the provider refused the credential.
+func (s *NFSeService) VerifyDocument(ctx context.Context, credential *models.CompanyCredential, document *models.Document) (*VerificationReport, error) {
+	if document.IssueDate.IsZero() {
+		return nil, fmt.Errorf("document %d has no issue date to query the provider", document.ID)
+	}
+
+	report := &VerificationReport{
+		DocumentID:       document.ID,
+		Number:           document.Number,
+		VerificationCode: document.VerificationCode,
+		Mismatches:       []FieldMismatch{},
+		CheckedAt:        time.Now(),
+	}
+
+	day := truncateToDay(document.IssueDate)
+	parser := NewNFSeParser()
+
+	for page := 1; page <= config.Get().NFSeScheduler.MaxPagesPerRun; page++ {
+		result, err := s.FetchNFSeDocuments(ctx, credential, day, day, page)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrProviderUnavailable, err)
+		}
+
+		if !result.Success {
+			return nil, fmt.Errorf("%w: %s", ErrProviderUnavailable, result.Error)
+		}
+
+		for _, fetched := range result.Documents {
+			parsed, err := parser.ParseXML(fetched.XMLContent)
+			if err != nil || !sameNote(document, parsed) {
+				continue
+			}
+
+			report.Mismatches = compareWithProvider(document, parsed)
+			report.Status = VerificationMatch
+			if len(report.Mismatches) > 0 {
+				report.Status = VerificationMismatch
+			}
+
+			logger.InfoWithFields("NFSe verified against provider", map[string]any{
+				"operation":   "verify_nfse",
+				"company_id":  document.CompanyID,
+				"document_id": document.ID,
+				"status":      report.Status,
+				"mismatches":  len(report.Mismatches),
+			})
+
+			return report, nil
+		}
+
+		if len(result.Documents) < maxDocumentsPerPage {
+			break
+		}
+	}
+
+	report.Status = VerificationNotFound
+	return report, nil
+}
+
+// sameNote reports whether a provider record refers to the stored document
+func sameNote(document *models.Document, parsed *ParsedNFSeData) bool {
+	if document.VerificationCode != "" && parsed.VerificationCode != "" {
+		return document.VerificationCode == parsed.VerificationCode
+	}
+	return document.Number == parsed.Number && document.ProviderCNPJ == parsed.ProviderCNPJ
+}
+
+// compareWithProvider lists the key fields that differ between the stored document and
+// the provider record
+func compareWithProvider(document *models.Document, parsed *ParsedNFSeData) []FieldMismatch {
+	mismatches := []FieldMismatch{}
+
+	compare := func(field, stored, provider string) {
+		if stored != provider {
+			mismatches = append(mismatches, FieldMismatch{Field: field, Stored: stored, Provider: provider})
+		}
+	}
+
+	compare("number", document.Number, parsed.Number)
+	compare("verification_code", document.VerificationCode, parsed.VerificationCode)
+	compare("provider_cnpj", document.ProviderCNPJ, parsed.ProviderCNPJ)
+	compare("taker_cnpj", document.TakerCNPJ, parsed.TakerCNPJ)
+	compare("service_code", document.ServiceCode, parsed.ServiceCode)
+	compare("issue_date", document.IssueDate.UTC().Format(time.DateTime), parsed.IssueDate.UTC().Format(time.DateTime))
+	compare("is_cancelled", fmt.Sprint(document.IsCancelled), fmt.Sprint(parsed.IsCancelled))
+	compare("is_substituted", fmt.Sprint(document.IsSubstituted), fmt.Sprint(parsed.IsSubstituted))
+
+	// Values are stored as decimal(15,2); compare in cents
+	if math.Round(document.ServiceValue*100) != math.Round(parsed.ServiceValue*100) {
+		mismatches = append(mismatches, FieldMismatch{
+			Field:    "service_value",
+			Stored:   fmt.Sprintf("%.2f", document.ServiceValue),
+			Provider: fmt.Sprintf("%.2f", parsed.ServiceValue),
+		})
+	}
+
+	return mismatches
+}
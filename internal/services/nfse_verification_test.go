@@ -0,0 +1,135 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/zoomxml/internal/models"
+	"github.com/zoomxml/internal/repository/repositorytest"
+)
+
+// testMunicipalityCode is the IBGE code useFakeProvider registers its provider under
+const testMunicipalityCode = "9999999"
+
+// fakeProvider answers every page with the same XMLs, or fails with err
+type fakeProvider struct {
+	xmls []string
+	err  error
+}
+
+func (p *fakeProvider) Capabilities() ProviderCapabilities {
+	return ProviderCapabilities{Name: "fake", CredentialTypes: []string{"prefeitura_token"}}
+}
+
+func (p *fakeProvider) Authenticate(ctx context.Context, credential *models.CompanyCredential) (string, error) {
+	return "Bearer test", nil
+}
+
+func (p *fakeProvider) FetchDocuments(ctx context.Context, request ProviderFetchRequest, handle func([]NFSeDocument) error) (*NFSeProcessResult, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	documents := make([]NFSeDocument, len(p.xmls))
+	for i, content := range p.xmls {
+		documents[i] = NFSeDocument{FileName: "nota.xml", XMLContent: content}
+	}
+	if err := handle(documents); err != nil {
+		return nil, err
+	}
+	return &NFSeProcessResult{Success: true, DocumentsCount: len(documents)}, nil
+}
+
+// useFakeProvider registers provider for testMunicipalityCode while the test runs and
+// returns a service whose company 1 is served by it
+func useFakeProvider(t *testing.T, provider *fakeProvider) *NFSeService {
+	t.Helper()
+	RegisterMunicipalityProvider(testMunicipalityCode, func(*http.Client) MunicipalityProvider { return provider })
+	t.Cleanup(func() {
+		municipalityProvidersMu.Lock()
+		delete(municipalityProviders, testMunicipalityCode)
+		municipalityProvidersMu.Unlock()
+	})
+
+	companies := &repositorytest.CompanyRepository{Companies: map[int64]*models.Company{
+		1: {ID: 1, MunicipalityCode: testMunicipalityCode},
+	}}
+	return NewNFSeServiceWithRepositories(nil, &repositorytest.DocumentRepository{}, companies)
+}
+
+func TestVerifyDocument(t *testing.T) {
+	stored := &models.Document{
+		ID:               10,
+		CompanyID:        1,
+		Number:           "1",
+		VerificationCode: "AAA",
+		ProviderCNPJ:     "12345678000190",
+		ServiceValue:     100,
+		IssueDate:        time.Date(2025, 3, 14, 10, 0, 0, 0, time.UTC),
+	}
+
+	tests := []struct {
+		name           string
+		provider       *fakeProvider
+		wantStatus     string
+		wantMismatches []string
+		wantErr        error
+	}{
+		{
+			name:       "matching record",
+			provider:   &fakeProvider{xmls: []string{testNFSeXML("2", "BBB", "12345678000190", "", "5.00"), testNFSeXML("1", "AAA", "12345678000190", "", "100.00")}},
+			wantStatus: VerificationMatch,
+		},
+		{
+			name:           "mismatching record",
+			provider:       &fakeProvider{xmls: []string{testNFSeXML("1", "AAA", "12345678000190", "98765432000110", "150.00")}},
+			wantStatus:     VerificationMismatch,
+			wantMismatches: []string{"taker_cnpj", "service_value"},
+		},
+		{
+			name:       "record not returned",
+			provider:   &fakeProvider{xmls: []string{testNFSeXML("2", "BBB", "12345678000190", "", "5.00")}},
+			wantStatus: VerificationNotFound,
+		},
+		{
+			name:     "provider unavailable",
+			provider: &fakeProvider{err: errors.New("connection refused")},
+			wantErr:  ErrProviderUnavailable,
+		},
+		{
+			name:     "credential rejected",
+			provider: &fakeProvider{err: fmt.Errorf("%w: API returned status 401", ErrCredentialRejected)},
+			wantErr:  ErrCredentialRejected,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := useFakeProvider(t, tt.provider)
+			credential := &models.CompanyCredential{ID: 1, CompanyID: 1, Type: "prefeitura_token"}
+
+			report, err := service.VerifyDocument(context.Background(), credential, stored)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("VerifyDocument() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			if report.Status != tt.wantStatus {
+				t.Errorf("VerifyDocument() status = %q, want %q", report.Status, tt.wantStatus)
+			}
+			fields := []string{}
+			for _, mismatch := range report.Mismatches {
+				fields = append(fields, mismatch.Field)
+			}
+			if !slices.Equal(fields, tt.wantMismatches) && len(fields)+len(tt.wantMismatches) > 0 {
+				t.Errorf("VerifyDocument() mismatches = %v, want %v", fields, tt.wantMismatches)
+			}
+		})
+	}
+}
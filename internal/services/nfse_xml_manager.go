@@ -2,14 +2,17 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"regexp"
+	"path"
 	"strings"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/zoomxml/internal/database"
 	"github.com/zoomxml/internal/logger"
 	"github.com/zoomxml/internal/models"
+	"github.com/zoomxml/internal/repository"
 	"github.com/zoomxml/internal/storage"
 )
 
@@ -18,76 +21,116 @@ type ProcessingResult struct {
 	Success         bool
 	DocumentID      int64
 	IsDuplicate     bool
+	Overwritten     bool
 	DuplicateReason string
 	ProcessingTime  time.Duration
 	Error           error
+	StorageFailed   bool // the XML could not be uploaded; retrying later may succeed
+	LimitReached    bool // rejected because the company reached its document limit
+	Rejected        bool // the XML failed parsing or validation; retrying it unchanged fails again
+	Cancelled       bool // the XML was a cancellation event that flagged DocumentID as cancelled
 }
 
+// Retryable reports whether a failed document may be stored by a later attempt, as
+// opposed to one rejected by parsing, validation or the company document limit
+func (r ProcessingResult) Retryable() bool {
+	return r.Error != nil && !r.Rejected && !r.LimitReached
+}
+
+// nfseBucket is the storage bucket that receives NFSe XML files
+const nfseBucket = "nfse-storage"
+
 // BatchProcessingResult represents the result of batch XML processing
 type BatchProcessingResult struct {
-	TotalDocuments     int
-	ProcessedDocuments int
-	DuplicateDocuments int
-	ErrorDocuments     int
-	ProcessingTime     time.Duration
-	Results            []ProcessingResult
-	Statistics         map[string]any
+	BatchID              string
+	TotalDocuments       int
+	ProcessedDocuments   int
+	DuplicateDocuments   int
+	OverwrittenDocuments int
+	ErrorDocuments       int
+	LimitRejected        int // new notes rejected by the company document limit (also in ErrorDocuments)
+	EvictedDocuments     int // oldest documents evicted to stay within the company document limit
+	CancelledDocuments   int // documents flagged by NF-e or CT-e cancellation events
+	ProcessingTime       time.Duration
+	Results              []ProcessingResult
+	Statistics           map[string]any
 }
 
 // NFSeXMLManager handles intelligent XML management with deduplication
 type NFSeXMLManager struct {
 	parser       *NFSeParser
 	deduplicator *NFSeDeduplicator
+	documents    repository.DocumentRepository
+	companies    repository.CompanyRepository
 }
 
 // NewNFSeXMLManager creates a new NFSe XML manager instance
 func NewNFSeXMLManager() *NFSeXMLManager {
+	return NewNFSeXMLManagerWithRepositories(repository.NewDocumentRepository(), repository.NewCompanyRepository())
+}
+
+// NewNFSeXMLManagerWithRepositories creates an XML manager that reads and writes
+// documents, and reads the company ingest settings, through the given repositories
+func NewNFSeXMLManagerWithRepositories(documents repository.DocumentRepository, companies repository.CompanyRepository) *NFSeXMLManager {
 	return &NFSeXMLManager{
 		parser:       NewNFSeParser(),
-		deduplicator: NewNFSeDeduplicator(),
+		deduplicator: NewNFSeDeduplicatorWithRepository(documents),
+		documents:    documents,
+		companies:    companies,
 	}
 }
 
-// generateOrganizedStorageKey creates an organized storage path: year/competence/cnpj/filename
-// Example: 2025/012025/34194865000158/filename.xml
-func (m *NFSeXMLManager) generateOrganizedStorageKey(parsedData *ParsedNFSeData, fileName string) string {
-	// Extract year from issue date
-	year := parsedData.IssueDate.Format("2006")
-
-	// Clean competence (remove spaces, slashes, etc.) and format as MMYYYY
-	competence := strings.ReplaceAll(parsedData.Competence, "/", "")
-	competence = strings.ReplaceAll(competence, " ", "")
-	competence = strings.ReplaceAll(competence, ":", "")
-
-	// If competence is in format "DD/MM/YYYY HH:MM:SS", extract MM and YYYY
-	if len(competence) >= 8 {
-		// Try to parse different formats
-		if strings.Contains(parsedData.Competence, "/") {
-			parts := strings.Split(parsedData.Competence, "/")
-			if len(parts) >= 3 {
-				month := strings.TrimSpace(parts[1])
-				yearPart := strings.TrimSpace(parts[2])
-				if len(yearPart) >= 4 {
-					yearPart = yearPart[:4] // Take first 4 characters as year
-				}
-				if len(month) == 1 {
-					month = "0" + month // Pad month with zero
-				}
-				competence = month + yearPart
+// generateOrganizedStorageKey creates an organized storage path under the document type:
+// type/year/competence/cnpj/object
+// Example: nfse/2025/012025/34194865000158/4521_AB12CD34.xml
+func (m *NFSeXMLManager) generateOrganizedStorageKey(parsedData *ParsedNFSeData) string {
+	// Competence as MMYYYY, the same value persisted as competence_month, so listings by
+	// competence find the folder. The year folder is the competence year, so a competence
+	// never spans two folders.
+	normalized := documentCompetence(parsedData)
+	if normalized == "" {
+		normalized = parsedData.IssueDate.Format(competenceLayout)
+	}
+	year := normalized[:4]
+	competence := normalized[5:7] + normalized[:4]
+
+	// Clean CNPJ (remove dots, slashes, spaces)
+	cleanCNPJ := NormalizeCNPJ(parsedData.ProviderCNPJ)
+
+	// Generate organized path: type/year/competence/cnpj/object
+	return fmt.Sprintf("%s/%s/%s/%s/%s", documentTypeOf(parsedData), year, competence, cleanCNPJ, storageObjectName(parsedData))
+}
+
+// storageObjectName names the stored XML after the note it holds, never after the file it
+// came in, so two notes uploaded under the same file name do not share an object. NF-e
+// and CT-e use their access key, NFSe their number, series and verification code, and a
+// note carrying none of them its content hash.
+func storageObjectName(parsedData *ParsedNFSeData) string {
+	parts := []string{}
+	if parsedData.AccessKey != "" {
+		parts = append(parts, storageKeySegment(parsedData.AccessKey))
+	} else {
+		for _, part := range []string{parsedData.Number, parsedData.Series, NormalizeVerificationCode(parsedData.VerificationCode)} {
+			if segment := storageKeySegment(part); segment != "" {
+				parts = append(parts, segment)
 			}
 		}
 	}
-
-	// If competence is still not in MMYYYY format, use issue date
-	if len(competence) != 6 {
-		competence = parsedData.IssueDate.Format("012006") // MM + YYYY
+	if len(parts) == 0 {
+		parts = append(parts, parsedData.ContentHash)
 	}
+	return strings.Join(parts, "_") + ".xml"
+}
 
-	// Clean CNPJ (remove dots, slashes, spaces)
-	cleanCNPJ := regexp.MustCompile(`[^0-9]`).ReplaceAllString(parsedData.ProviderCNPJ, "")
-
-	// Generate organized path: year/competence/cnpj/filename
-	return fmt.Sprintf("nfse/%s/%s/%s/%s", year, competence, cleanCNPJ, fileName)
+// storageKeySegment keeps the letters, digits and dashes of a note field, so it can be
+// used in an object name
+func storageKeySegment(value string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || (r >= '0' && r <= '9') || (r >= 'A' && r <= 'Z') || (r >= 'a' && r <= 'z') {
+			return r
+		}
+		return -1
+	}, value)
 }
 
 // ProcessSingleXML processes a single NFSe XML document with intelligent deduplication
@@ -100,10 +143,16 @@ func (m *NFSeXMLManager) ProcessSingleXML(ctx context.Context, companyID int64,
 		"file_name":  fileName,
 	})
 
+	// Cancellation events flag the document they refer to instead of being stored
+	if eventResult, handled := m.applyCancellationEvent(ctx, companyID, xmlContent); handled {
+		eventResult.ProcessingTime = time.Since(startTime)
+		return &eventResult, nil
+	}
+
 	result := &ProcessingResult{}
 
 	// Step 1: Parse XML content
-	parsedData, err := m.parser.ParseXML(xmlContent)
+	parsedData, err := m.parser.ParseDocumentXML(xmlContent)
 	if err != nil {
 		result.Error = fmt.Errorf("failed to parse XML: %v", err)
 		result.ProcessingTime = time.Since(startTime)
@@ -115,6 +164,13 @@ func (m *NFSeXMLManager) ProcessSingleXML(ctx context.Context, companyID int64,
 		return result, nil
 	}
 
+	policy := companyIngestPolicy(ctx, m.companies, companyID)
+	if err := m.parser.Validate(parsedData, policy); err != nil {
+		result.Error = err
+		result.ProcessingTime = time.Since(startTime)
+		return result, nil
+	}
+
 	// Step 2: Check for duplicates
 	duplicateCheck, err := m.deduplicator.CheckForDuplicates(ctx, companyID, parsedData)
 	if err != nil {
@@ -145,24 +201,45 @@ func (m *NFSeXMLManager) ProcessSingleXML(ctx context.Context, companyID int64,
 		return result, nil
 	}
 
-	// Step 3: Store XML in MinIO with organized path
-	storageKey := m.generateOrganizedStorageKey(parsedData, fileName)
-	err = storage.Storage.UploadFile(ctx, "nfse-storage", storageKey, []byte(xmlContent), "application/xml")
-	if err != nil {
-		result.Error = fmt.Errorf("failed to store XML: %v", err)
+	// Step 3: Enforce the company document limit
+	limit := companyDocumentLimit(ctx, m.companies, m.documents, companyID)
+	if limit.admit(ctx, 1) == 0 {
+		result.Error = ErrDocumentLimitReached
+		result.LimitReached = true
 		result.ProcessingTime = time.Since(startTime)
-		logger.ErrorWithFields("Failed to store XML in MinIO", err, map[string]any{
-			"operation":   "process_single_xml",
-			"company_id":  companyID,
-			"storage_key": storageKey,
-		})
 		return result, nil
 	}
 
-	// Step 4: Convert to document model and save to database
-	document := m.parser.ConvertToDocument(companyID, parsedData, storageKey)
+	// Step 4: Store XML in MinIO with organized path, unless the company keeps metadata only
+	storageKey := m.storageKeyFor(policy, parsedData)
+	if storageKey != "" {
+		err = storage.Storage.UploadFile(ctx, nfseBucket, storageKey, []byte(xmlContent), "application/xml")
+		if err != nil {
+			result.Error = fmt.Errorf("failed to store XML: %v", err)
+			result.ProcessingTime = time.Since(startTime)
+			logger.ErrorWithFields("Failed to store XML in MinIO", err, map[string]any{
+				"operation":   "process_single_xml",
+				"company_id":  companyID,
+				"storage_key": storageKey,
+			})
+			return result, nil
+		}
+	}
 
-	_, err = database.DB.NewInsert().Model(document).Exec(ctx)
+	// Step 5: Convert to document model and save to database
+	document := m.convertToDocument(policy, companyID, parsedData, storageKey, fileName)
+
+	inserted, err := m.documents.InsertDocuments(ctx, []*models.Document{document}, limit.forInsert())
+	if err == nil && inserted.Inserted == 0 {
+		// A concurrent ingest filled the room left by admit
+		if storageKey != "" {
+			m.deleteUnreferencedObject(ctx, companyID, 0, storageKey)
+		}
+		result.Error = ErrDocumentLimitReached
+		result.LimitReached = true
+		result.ProcessingTime = time.Since(startTime)
+		return result, nil
+	}
 	if err != nil {
 		result.Error = fmt.Errorf("failed to save document: %v", err)
 		result.ProcessingTime = time.Since(startTime)
@@ -174,6 +251,9 @@ func (m *NFSeXMLManager) ProcessSingleXML(ctx context.Context, companyID int64,
 		return result, nil
 	}
 
+	limit.handleEvictions(ctx, inserted)
+	InvalidateDuplicateStatistics(companyID)
+
 	result.Success = true
 	result.DocumentID = document.ID
 	result.ProcessingTime = time.Since(startTime)
@@ -190,43 +270,85 @@ func (m *NFSeXMLManager) ProcessSingleXML(ctx context.Context, companyID int64,
 	return result, nil
 }
 
+// BatchOptions controls how a batch is ingested
+type BatchOptions struct {
+	// Source identifies where the documents came from and is recorded in the processing log
+	Source string
+	// Overwrite replaces duplicates instead of skipping them. Only explicit manual
+	// re-uploads set it; automatic ingest keeps deduplication strict.
+	Overwrite bool
+}
+
+// ErrConcurrentModification is returned when a document changed while it was being overwritten
+var ErrConcurrentModification = errors.New("document was modified concurrently")
+
 // ProcessBatchXML processes multiple NFSe XML documents with optimized batch operations
-func (m *NFSeXMLManager) ProcessBatchXML(ctx context.Context, companyID int64, xmlDocuments []XMLDocument) (*BatchProcessingResult, error) {
+func (m *NFSeXMLManager) ProcessBatchXML(ctx context.Context, companyID int64, opts BatchOptions, xmlDocuments []XMLDocument) (*BatchProcessingResult, error) {
 	startTime := time.Now()
 
+	result := &BatchProcessingResult{
+		BatchID:        uuid.NewString(),
+		TotalDocuments: len(xmlDocuments),
+		Results:        make([]ProcessingResult, len(xmlDocuments)),
+	}
+
 	logger.InfoWithFields("Starting batch XML processing", map[string]any{
 		"operation":       "process_batch_xml",
 		"company_id":      companyID,
+		"batch_id":        result.BatchID,
 		"documents_count": len(xmlDocuments),
 	})
 
-	result := &BatchProcessingResult{
-		TotalDocuments: len(xmlDocuments),
-		Results:        make([]ProcessingResult, len(xmlDocuments)),
-	}
-
 	if len(xmlDocuments) == 0 {
 		result.ProcessingTime = time.Since(startTime)
 		return result, nil
 	}
 
-	// Step 1: Parse all XML documents
+	// Step 1: Parse and validate all XML documents
 	parsedDataList := make([]*ParsedNFSeData, 0, len(xmlDocuments))
 	parseErrors := make(map[int]error)
+	events := make(map[int]bool)
+	ingestPolicy := companyIngestPolicy(ctx, m.companies, companyID)
 
 	for i, xmlDoc := range xmlDocuments {
-		parsedData, err := m.parser.ParseXML(xmlDoc.Content)
+		// Cancellation events flag the document they refer to instead of being stored
+		if eventResult, handled := m.applyCancellationEvent(ctx, companyID, xmlDoc.Content); handled {
+			events[i] = true
+			result.Results[i] = eventResult
+			if eventResult.Error != nil {
+				result.ErrorDocuments++
+				if eventResult.Rejected {
+					parseErrors[i] = eventResult.Error
+				}
+			} else {
+				result.CancelledDocuments++
+			}
+			continue
+		}
+
+		parsedData, err := m.parser.ParseDocumentXML(xmlDoc.Content)
 		if err != nil {
 			parseErrors[i] = err
 			result.Results[i] = ProcessingResult{
-				Error: fmt.Errorf("failed to parse XML: %v", err),
+				Error:    fmt.Errorf("failed to parse XML: %v", err),
+				Rejected: true,
 			}
 			result.ErrorDocuments++
 			continue
 		}
+		applyCompetenceFallback(parsedData, xmlDoc.Competence)
+		if err := m.parser.Validate(parsedData, ingestPolicy); err != nil {
+			parseErrors[i] = err
+			result.Results[i] = ProcessingResult{Error: err, Rejected: true}
+			result.ErrorDocuments++
+			continue
+		}
 		parsedDataList = append(parsedDataList, parsedData)
 	}
 
+	// Keep the XMLs that failed for inspection and reprocessing
+	deadLetterFailures(ctx, companyID, opts.Source, xmlDocuments, parseErrors)
+
 	// Step 2: Batch check for duplicates
 	duplicateResults, err := m.deduplicator.BatchCheckForDuplicates(ctx, companyID, parsedDataList)
 	if err != nil {
@@ -234,17 +356,20 @@ func (m *NFSeXMLManager) ProcessBatchXML(ctx context.Context, companyID int64, x
 			"operation":  "process_batch_xml",
 			"company_id": companyID,
 		})
+		result.ProcessingTime = time.Since(startTime)
+		recordProcessingLog(ctx, companyID, opts.Source, result, err)
 		return nil, err
 	}
 
 	// Step 3: Process non-duplicate documents
 	documentsToInsert := make([]*models.Document, 0)
 	storageOperations := make([]StorageOperation, 0)
+	batchKeys := make(map[string]bool)
 
 	parsedIndex := 0
 	for i, xmlDoc := range xmlDocuments {
-		// Skip documents that failed parsing
-		if _, hasError := parseErrors[i]; hasError {
+		// Skip documents that failed parsing and events, already applied
+		if _, hasError := parseErrors[i]; hasError || events[i] {
 			continue
 		}
 
@@ -252,6 +377,18 @@ func (m *NFSeXMLManager) ProcessBatchXML(ctx context.Context, companyID int64, x
 		duplicateCheck := duplicateResults[parsedIndex]
 		parsedIndex++
 
+		// Deleted documents (retention, eviction, merges) are not brought back by overwrite
+		if duplicateCheck.IsDuplicate && opts.Overwrite && duplicateCheck.ExistingDocument.DeletedAt.IsZero() {
+			overwriteResult := m.overwriteDocument(ctx, companyID, ingestPolicy, parsedData, duplicateCheck.ExistingDocument, xmlDoc)
+			result.Results[i] = overwriteResult
+			if overwriteResult.Error != nil {
+				result.ErrorDocuments++
+			} else {
+				result.OverwrittenDocuments++
+			}
+			continue
+		}
+
 		if duplicateCheck.IsDuplicate {
 			result.Results[i] = ProcessingResult{
 				IsDuplicate:     true,
@@ -262,9 +399,16 @@ func (m *NFSeXMLManager) ProcessBatchXML(ctx context.Context, companyID int64, x
 			continue
 		}
 
-		// Prepare for storage and database insertion with organized path
-		storageKey := m.generateOrganizedStorageKey(parsedData, xmlDoc.FileName)
-		document := m.parser.ConvertToDocument(companyID, parsedData, storageKey)
+		// Prepare for storage and database insertion with organized path. Two notes of
+		// the batch with the same identity but different bytes keep an object each.
+		storageKey := m.storageKeyFor(ingestPolicy, parsedData)
+		if storageKey != "" {
+			if batchKeys[storageKey] {
+				storageKey = versionedStorageKey(storageKey, parsedData.ContentHash)
+			}
+			batchKeys[storageKey] = true
+		}
+		document := m.convertToDocument(ingestPolicy, companyID, parsedData, storageKey, xmlDoc.FileName)
 
 		documentsToInsert = append(documentsToInsert, document)
 		storageOperations = append(storageOperations, StorageOperation{
@@ -274,6 +418,27 @@ func (m *NFSeXMLManager) ProcessBatchXML(ctx context.Context, companyID int64, x
 		})
 	}
 
+	// Notes past the company document limit are rejected before anything is stored
+	limit := companyDocumentLimit(ctx, m.companies, m.documents, companyID)
+	if admitted := limit.admit(ctx, len(documentsToInsert)); admitted < len(documentsToInsert) {
+		for _, op := range storageOperations[admitted:] {
+			result.Results[op.Index] = ProcessingResult{
+				Error:        ErrDocumentLimitReached,
+				LimitReached: true,
+			}
+			result.ErrorDocuments++
+			result.LimitRejected++
+		}
+		logger.WarnWithFields("Company document limit reached, rejecting new documents", map[string]any{
+			"operation":  "process_batch_xml",
+			"company_id": companyID,
+			"limit":      limit.limit,
+			"rejected":   result.LimitRejected,
+		})
+		documentsToInsert = documentsToInsert[:admitted]
+		storageOperations = storageOperations[:admitted]
+	}
+
 	// Step 4: Batch upload to MinIO
 	err = m.batchUploadToStorage(ctx, storageOperations)
 	if err != nil {
@@ -284,14 +449,15 @@ func (m *NFSeXMLManager) ProcessBatchXML(ctx context.Context, companyID int64, x
 		// Mark storage operations as failed
 		for _, op := range storageOperations {
 			result.Results[op.Index] = ProcessingResult{
-				Error: fmt.Errorf("failed to store XML: %v", err),
+				Error:         fmt.Errorf("failed to store XML: %v", err),
+				StorageFailed: true,
 			}
 			result.ErrorDocuments++
 		}
 	} else {
 		// Step 5: Batch insert to database
 		if len(documentsToInsert) > 0 {
-			_, err = database.DB.NewInsert().Model(&documentsToInsert).Exec(ctx)
+			inserted, err := m.documents.InsertDocuments(ctx, documentsToInsert, limit.forInsert())
 			if err != nil {
 				logger.ErrorWithFields("Failed to batch insert documents", err, map[string]any{
 					"operation":       "process_batch_xml",
@@ -306,39 +472,196 @@ func (m *NFSeXMLManager) ProcessBatchXML(ctx context.Context, companyID int64, x
 					result.ErrorDocuments++
 				}
 			} else {
-				// Mark all as successful
+				// Mark the inserted ones as successful; the rest lost the room left by admit
+				// to a concurrent ingest and their objects are removed
 				for i, op := range storageOperations {
+					if i >= inserted.Inserted {
+						if op.Key != "" {
+							m.deleteUnreferencedObject(ctx, companyID, 0, op.Key)
+						}
+						result.Results[op.Index] = ProcessingResult{
+							Error:        ErrDocumentLimitReached,
+							LimitReached: true,
+						}
+						result.ErrorDocuments++
+						result.LimitRejected++
+						continue
+					}
 					result.Results[op.Index] = ProcessingResult{
 						Success:    true,
 						DocumentID: documentsToInsert[i].ID,
 					}
 					result.ProcessedDocuments++
 				}
+				result.EvictedDocuments = inserted.Evicted
+				limit.handleEvictions(ctx, inserted)
 			}
 		}
 	}
 
+	if result.ProcessedDocuments > 0 || result.OverwrittenDocuments > 0 {
+		InvalidateDuplicateStatistics(companyID)
+	}
+
 	result.ProcessingTime = time.Since(startTime)
 
 	// Generate statistics
 	result.Statistics = map[string]any{
-		"total_documents":     result.TotalDocuments,
-		"processed_documents": result.ProcessedDocuments,
-		"duplicate_documents": result.DuplicateDocuments,
-		"error_documents":     result.ErrorDocuments,
-		"processing_time_ms":  result.ProcessingTime.Milliseconds(),
-		"success_rate":        float64(result.ProcessedDocuments) / float64(result.TotalDocuments) * 100,
+		"total_documents":       result.TotalDocuments,
+		"processed_documents":   result.ProcessedDocuments,
+		"duplicate_documents":   result.DuplicateDocuments,
+		"overwritten_documents": result.OverwrittenDocuments,
+		"error_documents":       result.ErrorDocuments,
+		"limit_rejected":        result.LimitRejected,
+		"evicted_documents":     result.EvictedDocuments,
+		"cancelled_documents":   result.CancelledDocuments,
+		"processing_time_ms":    result.ProcessingTime.Milliseconds(),
+		"success_rate":          float64(result.ProcessedDocuments) / float64(result.TotalDocuments) * 100,
 	}
 
 	logger.InfoWithFields("Completed batch XML processing", result.Statistics)
 
+	recordProcessingLog(ctx, companyID, opts.Source, result, nil)
+
 	return result, nil
 }
 
+// overwriteDocument replaces the stored XML and parsed fields of an existing document.
+// The update only applies if the document was not modified since it was read
+// (optimistic locking on updated_at). The new XML is written under a key of its own and
+// the document switches to it only once the update applied, so a lost race leaves both
+// the row and its stored object as they were.
+func (m *NFSeXMLManager) overwriteDocument(ctx context.Context, companyID int64, policy IngestPolicy, parsedData *ParsedNFSeData, existing *models.Document, xmlDoc XMLDocument) ProcessingResult {
+	storageKey := m.storageKeyFor(policy, parsedData)
+	if storageKey != "" && storageKey == existing.StorageKey {
+		storageKey = versionedStorageKey(storageKey, parsedData.ContentHash)
+	}
+
+	uploaded := false
+	if storageKey != "" && storageKey != existing.StorageKey {
+		err := storage.Storage.UploadFile(ctx, nfseBucket, storageKey, []byte(xmlDoc.Content), "application/xml")
+		if err != nil {
+			return ProcessingResult{
+				DocumentID: existing.ID,
+				Error:      fmt.Errorf("failed to store XML: %v", err),
+			}
+		}
+		uploaded = true
+	}
+
+	document := m.convertToDocument(policy, companyID, parsedData, storageKey, xmlDoc.FileName)
+	document.ID = existing.ID
+	// Replacing the XML does not undo a review
+	if existing.Status == models.DocumentStatusReviewed {
+		document.Status = models.DocumentStatusReviewed
+	}
+
+	updated, err := m.documents.UpdateDocumentIfUnchanged(ctx, document, existing.UpdatedAt)
+	if err != nil || !updated {
+		if uploaded {
+			m.deleteUnreferencedObject(ctx, companyID, existing.ID, storageKey)
+		}
+		if err != nil {
+			return ProcessingResult{
+				DocumentID: existing.ID,
+				Error:      fmt.Errorf("failed to overwrite document: %v", err),
+			}
+		}
+		return ProcessingResult{
+			DocumentID: existing.ID,
+			Error:      ErrConcurrentModification,
+		}
+	}
+
+	// The previous object is no longer referenced; metadata-only companies keep it for
+	// the orphan report of the competence listing
+	if existing.StorageKey != "" && existing.StorageKey != storageKey && !policy.MetadataOnly {
+		m.deleteUnreferencedObject(ctx, companyID, existing.ID, existing.StorageKey)
+	}
+
+	logger.InfoWithFields("Overwrote existing NFSe document", map[string]any{
+		"operation":   "process_batch_xml",
+		"company_id":  companyID,
+		"document_id": existing.ID,
+		"storage_key": storageKey,
+	})
+
+	return ProcessingResult{
+		Success:     true,
+		DocumentID:  existing.ID,
+		Overwritten: true,
+	}
+}
+
+// versionedStorageKey returns a key next to storageKey that is unique to the XML content,
+// so a replacement XML never overwrites the object the document still points to
+func versionedStorageKey(storageKey, contentHash string) string {
+	if len(contentHash) > 12 {
+		contentHash = contentHash[:12]
+	}
+	ext := path.Ext(storageKey)
+	base := strings.TrimSuffix(storageKey, ext)
+	// Replacing a versioned object again starts from the unversioned name
+	if dot := strings.LastIndex(base, "."); dot > strings.LastIndex(base, "/") && isHex(base[dot+1:]) && len(base)-dot-1 == 12 {
+		base = base[:dot]
+	}
+	return base + "." + contentHash + ext
+}
+
+// isHex reports whether s is made of lowercase hexadecimal digits only
+func isHex(s string) bool {
+	for _, r := range s {
+		if (r < '0' || r > '9') && (r < 'a' || r > 'f') {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// deleteUnreferencedObject removes a stored XML no document points to (anymore), with its
+// DANFSE PDFs. A failure only leaves an orphan behind, so it is logged and not reported.
+func (m *NFSeXMLManager) deleteUnreferencedObject(ctx context.Context, companyID, documentID int64, storageKey string) {
+	deleteDANFSEs(ctx, storageKey, "")
+	if err := storage.Storage.DeleteFile(ctx, nfseBucket, storageKey); err != nil {
+		logger.WarnWithFields("Failed to delete unreferenced XML object", map[string]any{
+			"operation":   "process_batch_xml",
+			"company_id":  companyID,
+			"document_id": documentID,
+			"storage_key": storageKey,
+			"error":       err.Error(),
+		})
+	}
+}
+
+// storageKeyFor returns where the XML of a note is stored, or "" when the company keeps
+// metadata only
+func (m *NFSeXMLManager) storageKeyFor(policy IngestPolicy, parsedData *ParsedNFSeData) string {
+	if policy.MetadataOnly {
+		return ""
+	}
+	return m.generateOrganizedStorageKey(parsedData)
+}
+
+// convertToDocument converts parsed data to a document, dropping the raw XML from the
+// metadata column when the company keeps metadata only and flagging notes of closed
+// competências as late arrivals. fileName is the name the XML was received under; it is
+// kept for reference only and plays no part in the storage key.
+func (m *NFSeXMLManager) convertToDocument(policy IngestPolicy, companyID int64, parsedData *ParsedNFSeData, storageKey, fileName string) *models.Document {
+	document := m.parser.ConvertToDocument(companyID, parsedData, storageKey)
+	document.OriginalFileName = fileName
+	if policy.MetadataOnly {
+		document.Metadata = ""
+	}
+	document.LateArrival = policy.ClosedCompetences[documentCompetence(parsedData)]
+	return document
+}
+
 // XMLDocument represents an XML document to be processed
 type XMLDocument struct {
 	FileName string
 	Content  string
+	// Competence (YYYY-MM) reported by the provider, used when the XML has none
+	Competence string
 }
 
 // StorageOperation represents a storage operation
@@ -348,10 +671,14 @@ type StorageOperation struct {
 	Index   int
 }
 
-// batchUploadToStorage uploads multiple files to storage efficiently
+// batchUploadToStorage uploads multiple files to storage efficiently. Operations without
+// a key belong to metadata-only companies and are skipped.
 func (m *NFSeXMLManager) batchUploadToStorage(ctx context.Context, operations []StorageOperation) error {
 	for _, op := range operations {
-		err := storage.Storage.UploadFile(ctx, "nfse-storage", op.Key, []byte(op.Content), "application/xml")
+		if op.Key == "" {
+			continue
+		}
+		err := storage.Storage.UploadFile(ctx, nfseBucket, op.Key, []byte(op.Content), "application/xml")
 		if err != nil {
 			return fmt.Errorf("failed to upload %s: %v", op.Key, err)
 		}
@@ -396,3 +723,93 @@ func (m *NFSeXMLManager) GetProcessingStatistics(ctx context.Context, companyID
 
 	return stats, nil
 }
+
+// companyIngestPolicy loads the ingest policies of a company. Lookup failures
+// fall back to the defaults (flag zero-value notes, no CNPJ check), which never drop documents.
+func companyIngestPolicy(ctx context.Context, companies repository.CompanyRepository, companyID int64) IngestPolicy {
+	company, err := companies.FindCompany(ctx, companyID, "cnpj", "zero_value_policy", "cnpj_match_policy", "validation_rules", "metadata_only")
+	if err != nil {
+		logger.WarnWithFields("Failed to load company ingest policy, using defaults", map[string]any{
+			"operation":  "ingest_policy",
+			"company_id": companyID,
+			"error":      err.Error(),
+		})
+		return IngestPolicy{ZeroValue: models.ZeroValuePolicyFlag, CNPJMatch: models.CNPJMatchPolicyOff}
+	}
+
+	// Rules are validated when saved, so a rule failing here was stored before its
+	// validator changed; it is skipped rather than blocking every ingest of the company
+	validators := make([]NamedDocumentValidator, 0, len(company.ValidationRules))
+	for _, rule := range company.ValidationRules {
+		validator, err := BuildDocumentValidator(rule)
+		if err != nil {
+			logger.WarnWithFields("Skipping invalid company validation rule", map[string]any{
+				"operation":  "ingest_policy",
+				"company_id": companyID,
+				"validator":  rule.Validator,
+				"error":      err.Error(),
+			})
+			continue
+		}
+		validators = append(validators, validator)
+	}
+
+	return IngestPolicy{
+		ZeroValue:    company.ZeroValuePolicy,
+		CNPJMatch:    company.CNPJMatchPolicy,
+		CompanyCNPJ:  company.CNPJ,
+		Validators:   validators,
+		MetadataOnly: company.MetadataOnly,
+
+		ClosedCompetences: closedCompetences(ctx, companyID),
+	}
+}
+
+// DuplicatePreview is the deduplication decision for an XML, computed without storing it
+type DuplicatePreview struct {
+	IsDuplicate        bool   `json:"is_duplicate"`
+	CheckMethod        string `json:"check_method,omitempty"`
+	Reason             string `json:"reason,omitempty"`
+	ExistingDocumentID int64  `json:"existing_document_id,omitempty"`
+	ExistingStorageKey string `json:"existing_storage_key,omitempty"`
+	DocumentType       string `json:"document_type"`
+	AccessKey          string `json:"access_key,omitempty"`
+	Number             string `json:"number"`
+	VerificationCode   string `json:"verification_code"`
+	ProviderCNPJ       string `json:"provider_cnpj"`
+}
+
+// ErrDuplicateCheck wraps failures of the duplicate lookup itself (as opposed to invalid XML)
+var ErrDuplicateCheck = errors.New("duplicate check failed")
+
+// PreviewDuplicateCheck parses an XML and runs the duplicate check the ingest would run,
+// without storing anything. Lookup failures wrap ErrDuplicateCheck; any other error
+// means the XML could not be parsed.
+func (m *NFSeXMLManager) PreviewDuplicateCheck(ctx context.Context, companyID int64, xmlContent string) (*DuplicatePreview, error) {
+	parsedData, err := m.parser.ParseDocumentXML(xmlContent)
+	if err != nil {
+		return nil, err
+	}
+
+	check, err := m.deduplicator.CheckForDuplicates(ctx, companyID, parsedData)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDuplicateCheck, err)
+	}
+
+	preview := &DuplicatePreview{
+		IsDuplicate:      check.IsDuplicate,
+		CheckMethod:      check.CheckMethod,
+		Reason:           check.Reason,
+		DocumentType:     documentTypeOf(parsedData),
+		AccessKey:        parsedData.AccessKey,
+		Number:           parsedData.Number,
+		VerificationCode: parsedData.VerificationCode,
+		ProviderCNPJ:     parsedData.ProviderCNPJ,
+	}
+	if check.IsDuplicate && check.ExistingDocument != nil {
+		preview.ExistingDocumentID = check.ExistingDocument.ID
+		preview.ExistingStorageKey = check.ExistingDocument.StorageKey
+	}
+
+	return preview, nil
+}
@@ -0,0 +1,554 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zoomxml/config"
+	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/database/databasetest"
+	"github.com/zoomxml/internal/models"
+	"github.com/zoomxml/internal/repository"
+	"github.com/zoomxml/internal/repository/repositorytest"
+)
+
+func TestVersionedStorageKey(t *testing.T) {
+	hash := "0123456789abcdef0123456789abcdef"
+
+	tests := []struct {
+		name string
+		key  string
+		want string
+	}{
+		{"plain key", "nfse/2025/012025/123/nota.xml", "nfse/2025/012025/123/nota.0123456789ab.xml"},
+		{"already versioned", "nfse/2025/012025/123/nota.fedcba987654.xml", "nfse/2025/012025/123/nota.0123456789ab.xml"},
+		{"dotted file name", "nfse/2025/012025/123/nota.v2.xml", "nfse/2025/012025/123/nota.v2.0123456789ab.xml"},
+		{"no extension", "nfse/2025/012025/123/nota", "nfse/2025/012025/123/nota.0123456789ab"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := versionedStorageKey(tt.key, hash); got != tt.want {
+				t.Errorf("versionedStorageKey(%q) = %q, want %q", tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateOrganizedStorageKeyMatchesCompetence(t *testing.T) {
+	manager := &NFSeXMLManager{}
+	issued := time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		competence string
+		want       string
+	}{
+		{"day first", "01/12/2025", "nfse/2025/122025/12345678000190/4521.xml"},
+		{"single digit month and year", "3/2025", "nfse/2025/032025/12345678000190/4521.xml"},
+		{"unknown format falls back to issue date", "dezembro", "nfse/2026/012026/12345678000190/4521.xml"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed := &ParsedNFSeData{Competence: tt.competence, IssueDate: issued, ProviderCNPJ: "12.345.678/0001-90", Number: "4521"}
+			got := manager.generateOrganizedStorageKey(parsed)
+			if got != tt.want {
+				t.Errorf("generateOrganizedStorageKey(%q) = %q, want %q", tt.competence, got, tt.want)
+			}
+
+			// The folder must be the competence the listing filters on
+			month := documentCompetence(parsed)
+			if folder := month[5:7] + month[:4]; !strings.Contains(got, "/"+folder+"/") {
+				t.Errorf("key %q is not under competence folder %q", got, folder)
+			}
+		})
+	}
+}
+
+func TestProcessBatchXMLWithFakeRepository(t *testing.T) {
+	const companyCNPJ = "12345678000190"
+	older := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		company       models.Company
+		existing      []*models.Document
+		xmls          []string
+		insertErr     error
+		wantProcessed int
+		wantDuplicate int
+		wantErrors    int
+		wantRejected  int // over the document limit
+		wantEvicted   int
+		wantStored    int // live documents of the company afterwards
+		wantObjects   int
+	}{
+		{
+			name:          "new notes are stored",
+			xmls:          []string{testNFSeXML("1", "AAA", companyCNPJ, "", "100.00"), testNFSeXML("2", "BBB", companyCNPJ, "", "50.00")},
+			wantProcessed: 2,
+			wantStored:    2,
+			wantObjects:   2,
+		},
+		{
+			name:          "duplicates are skipped",
+			existing:      []*models.Document{{Number: "9", VerificationCode: "AAA"}},
+			xmls:          []string{testNFSeXML("1", "AAA", companyCNPJ, "", "100.00"), testNFSeXML("2", "BBB", companyCNPJ, "", "50.00")},
+			wantProcessed: 1,
+			wantDuplicate: 1,
+			wantStored:    2,
+			wantObjects:   1,
+		},
+		{
+			name:          "invalid XML is rejected",
+			xmls:          []string{"<consultarNotaResponse>", testNFSeXML("2", "BBB", companyCNPJ, "", "50.00")},
+			wantProcessed: 1,
+			wantErrors:    1,
+			wantStored:    1,
+			wantObjects:   1,
+		},
+		{
+			name:       "company policies apply",
+			company:    models.Company{ZeroValuePolicy: models.ZeroValuePolicyReject, CNPJMatchPolicy: models.CNPJMatchPolicyReject},
+			xmls:       []string{testNFSeXML("1", "AAA", companyCNPJ, "", "0"), testNFSeXML("2", "BBB", "99999999000199", "88888888000188", "50.00")},
+			wantErrors: 2,
+		},
+		{
+			name:          "notes over the limit are rejected",
+			company:       models.Company{DocumentLimit: 2, DocumentLimitPolicy: models.DocumentLimitPolicyReject},
+			existing:      []*models.Document{{Number: "9", VerificationCode: "ZZZ"}},
+			xmls:          []string{testNFSeXML("1", "AAA", companyCNPJ, "", "100.00"), testNFSeXML("2", "BBB", companyCNPJ, "", "50.00")},
+			wantProcessed: 1,
+			wantErrors:    1,
+			wantRejected:  1,
+			wantStored:    2,
+			wantObjects:   1,
+		},
+		{
+			name:    "oldest notes are evicted over the limit",
+			company: models.Company{DocumentLimit: 2, DocumentLimitPolicy: models.DocumentLimitPolicyEvictOldest},
+			existing: []*models.Document{
+				{Number: "8", VerificationCode: "YYY", IssueDate: older},
+				{Number: "9", VerificationCode: "ZZZ", IssueDate: older.AddDate(0, 1, 0)},
+			},
+			xmls:          []string{testNFSeXML("1", "AAA", companyCNPJ, "", "100.00"), testNFSeXML("2", "BBB", companyCNPJ, "", "50.00")},
+			wantProcessed: 2,
+			wantEvicted:   2,
+			wantStored:    2,
+			wantObjects:   2,
+		},
+		{
+			name:        "insert failures fail the notes",
+			xmls:        []string{testNFSeXML("1", "AAA", companyCNPJ, "", "100.00")},
+			insertErr:   errors.New("connection reset"),
+			wantErrors:  1,
+			wantObjects: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			memory := useFakeIngest(t)
+			ctx := context.Background()
+
+			company := tt.company
+			company.ID = 1
+			company.CNPJ = companyCNPJ
+			documents := &repositorytest.DocumentRepository{InsertErr: tt.insertErr}
+			for _, document := range tt.existing {
+				document.CompanyID = company.ID
+				document.Type = models.DocumentTypeNFSe
+				documents.Add(document)
+			}
+			manager := NewNFSeXMLManagerWithRepositories(documents, &repositorytest.CompanyRepository{Companies: map[int64]*models.Company{company.ID: &company}})
+
+			xmlDocuments := make([]XMLDocument, len(tt.xmls))
+			for i, content := range tt.xmls {
+				xmlDocuments[i] = XMLDocument{FileName: fmt.Sprintf("%d.xml", i), Content: content}
+			}
+
+			result, err := manager.ProcessBatchXML(ctx, company.ID, BatchOptions{Source: ProcessingSourceManualUpload}, xmlDocuments)
+			if err != nil {
+				t.Fatalf("ProcessBatchXML() error = %v", err)
+			}
+
+			if result.ProcessedDocuments != tt.wantProcessed || result.DuplicateDocuments != tt.wantDuplicate ||
+				result.ErrorDocuments != tt.wantErrors || result.LimitRejected != tt.wantRejected || result.EvictedDocuments != tt.wantEvicted {
+				t.Errorf("ProcessBatchXML() processed/duplicate/errors/rejected/evicted = %d/%d/%d/%d/%d, want %d/%d/%d/%d/%d",
+					result.ProcessedDocuments, result.DuplicateDocuments, result.ErrorDocuments, result.LimitRejected, result.EvictedDocuments,
+					tt.wantProcessed, tt.wantDuplicate, tt.wantErrors, tt.wantRejected, tt.wantEvicted)
+			}
+
+			// Each successful result points at the stored document of its own XML
+			for i, docResult := range result.Results {
+				if !docResult.Success {
+					continue
+				}
+				found := false
+				for _, document := range documents.Documents {
+					if document.ID == docResult.DocumentID {
+						found = document.OriginalFileName == fmt.Sprintf("%d.xml", i)
+					}
+				}
+				if !found {
+					t.Errorf("result %d points at document %d, which is not the one of its XML", i, docResult.DocumentID)
+				}
+			}
+
+			if stored, _ := documents.CountDocuments(ctx, company.ID); stored != tt.wantStored {
+				t.Errorf("live documents = %d, want %d", stored, tt.wantStored)
+			}
+			if len(memory.objects) != tt.wantObjects {
+				t.Errorf("stored objects = %d, want %d", len(memory.objects), tt.wantObjects)
+			}
+		})
+	}
+}
+
+func TestGenerateOrganizedStorageKeyNormalizesCNPJ(t *testing.T) {
+	manager := &NFSeXMLManager{}
+	issued := time.Date(2025, 3, 14, 0, 0, 0, 0, time.UTC)
+	want := "nfse/2025/032025/12345678000190/4521.xml"
+
+	for _, cnpj := range []string{"12345678000190", "12.345.678/0001-90", " 12 345 678 0001 90 "} {
+		t.Run(cnpj, func(t *testing.T) {
+			parsed := &ParsedNFSeData{IssueDate: issued, ProviderCNPJ: cnpj, Number: "4521"}
+			if got := manager.generateOrganizedStorageKey(parsed); got != want {
+				t.Errorf("generateOrganizedStorageKey(%q) = %q, want %q", cnpj, got, want)
+			}
+		})
+	}
+}
+
+func TestStorageObjectName(t *testing.T) {
+	tests := []struct {
+		name   string
+		parsed ParsedNFSeData
+		want   string
+	}{
+		{"nfse", ParsedNFSeData{Number: "4521", VerificationCode: "ab12-cd34"}, "4521_AB12CD34.xml"},
+		{"nfse with series", ParsedNFSeData{Number: "4521", Series: "A1", VerificationCode: "AB12CD34"}, "4521_A1_AB12CD34.xml"},
+		{"unsafe characters are dropped", ParsedNFSeData{Number: "2025/4521", VerificationCode: "../x"}, "20254521_X.xml"},
+		{"nf-e uses its access key", ParsedNFSeData{AccessKey: testNFeAccessKey, Number: "123", Series: "1"}, testNFeAccessKey + ".xml"},
+		{"no identity falls back to the content hash", ParsedNFSeData{ContentHash: "0123456789abcdef"}, "0123456789abcdef.xml"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := storageObjectName(&tt.parsed); got != tt.want {
+				t.Errorf("storageObjectName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestProcessBatchXMLKeysByNoteIdentity uploads different notes under the same file name
+// and checks that each keeps its own object, with the file name kept as metadata
+func TestProcessBatchXMLKeysByNoteIdentity(t *testing.T) {
+	const companyCNPJ = "12345678000190"
+	memory := useFakeIngest(t)
+	company := &models.Company{ID: 1, CNPJ: companyCNPJ}
+	documents := &repositorytest.DocumentRepository{}
+	manager := NewNFSeXMLManagerWithRepositories(documents, &repositorytest.CompanyRepository{Companies: map[int64]*models.Company{company.ID: company}})
+	opts := BatchOptions{Source: ProcessingSourceManualUpload}
+
+	first := testNFSeXML("1", "AAA", companyCNPJ, "", "100.00")
+	second := testNFSeXML("2", "BBB", companyCNPJ, "", "200.00")
+	// Same number and verification code as the first, other bytes: stored apart all the same
+	sameIdentity := testNFSeXML("1", "AAA", companyCNPJ, "", "100.0")
+
+	result, err := manager.ProcessBatchXML(context.Background(), company.ID, opts, []XMLDocument{
+		{FileName: "a/nota.xml", Content: first},
+		{FileName: "b/nota.xml", Content: second},
+	})
+	if err != nil || result.ProcessedDocuments != 2 {
+		t.Fatalf("ProcessBatchXML() = %+v, %v, want both notes stored", result, err)
+	}
+	result, err = manager.ProcessBatchXML(context.Background(), company.ID, opts, []XMLDocument{
+		{FileName: "nota.xml", Content: sameIdentity},
+	})
+	if err != nil || result.DuplicateDocuments != 1 {
+		t.Fatalf("ProcessBatchXML() = %+v, %v, want the note found as a duplicate", result, err)
+	}
+
+	keys := map[string]bool{}
+	for i, document := range documents.Documents {
+		if keys[document.StorageKey] {
+			t.Errorf("documents share the object %s", document.StorageKey)
+		}
+		keys[document.StorageKey] = true
+		if !strings.HasSuffix(document.StorageKey, "/"+document.Number+"_"+[]string{"AAA", "BBB"}[i]+".xml") {
+			t.Errorf("storage key %s is not named after note %s", document.StorageKey, document.Number)
+		}
+		if want := []string{"a/nota.xml", "b/nota.xml"}[i]; document.OriginalFileName != want {
+			t.Errorf("OriginalFileName = %q, want %q", document.OriginalFileName, want)
+		}
+	}
+	if string(memory.objects[documents.Documents[0].StorageKey]) != first || string(memory.objects[documents.Documents[1].StorageKey]) != second {
+		t.Error("stored objects do not hold the XML of their own note")
+	}
+
+	// Two notes of one batch with the same identity do not overwrite each other either
+	batch := &repositorytest.DocumentRepository{}
+	result, err = NewNFSeXMLManagerWithRepositories(batch, &repositorytest.CompanyRepository{Companies: map[int64]*models.Company{company.ID: company}}).
+		ProcessBatchXML(context.Background(), company.ID, opts, []XMLDocument{
+			{FileName: "nota.xml", Content: first},
+			{FileName: "nota.xml", Content: sameIdentity},
+		})
+	if err != nil || len(batch.Documents) != 2 {
+		t.Fatalf("ProcessBatchXML() = %+v, %v, want both notes stored", result, err)
+	}
+	for i, content := range []string{first, sameIdentity} {
+		if got := string(memory.objects[batch.Documents[i].StorageKey]); got != content {
+			t.Errorf("object %s holds %q, want note %d", batch.Documents[i].StorageKey, got, i+1)
+		}
+	}
+}
+
+func TestPreviewDuplicateCheck(t *testing.T) {
+	databasetest.Require(t)
+	ctx := context.Background()
+	manager := NewNFSeXMLManager()
+	company := databasetest.CreateCompany(t, nil)
+	stored := databasetest.CreateDocument(t, &models.Document{
+		CompanyID:        company.ID,
+		Number:           "1",
+		VerificationCode: "PREVIEW-AAA",
+		ProviderCNPJ:     company.CNPJ,
+		StorageKey:       "nfse/2025/032025/" + company.CNPJ + "/1.xml",
+	})
+
+	tests := []struct {
+		name       string
+		xml        string
+		wantDup    bool
+		wantMethod string
+		wantDocID  int64
+		wantKey    string
+	}{
+		{"duplicate found", testNFSeXML("1", "PREVIEW-AAA", company.CNPJ, "", "100.00"), true, "verification_code", stored.ID, stored.StorageKey},
+		{"not found", testNFSeXML("2", "PREVIEW-BBB", company.CNPJ, "", "100.00"), false, "comprehensive", 0, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			preview, err := manager.PreviewDuplicateCheck(ctx, company.ID, tt.xml)
+			if err != nil {
+				t.Fatalf("PreviewDuplicateCheck() error = %v", err)
+			}
+			if preview.IsDuplicate != tt.wantDup || preview.CheckMethod != tt.wantMethod ||
+				preview.ExistingDocumentID != tt.wantDocID || preview.ExistingStorageKey != tt.wantKey {
+				t.Errorf("PreviewDuplicateCheck() = %+v, want duplicate %v by %q of document %d (%q)",
+					preview, tt.wantDup, tt.wantMethod, tt.wantDocID, tt.wantKey)
+			}
+		})
+	}
+
+	// Unparseable XML is reported as such, not as a failed lookup
+	if _, err := manager.PreviewDuplicateCheck(ctx, company.ID, "<consultarNotaResponse>"); err == nil || errors.Is(err, ErrDuplicateCheck) {
+		t.Errorf("PreviewDuplicateCheck(invalid XML) error = %v, want a parse error", err)
+	}
+}
+
+func TestInsertDocumentsInChunks(t *testing.T) {
+	databasetest.Require(t)
+	ctx := context.Background()
+	company := databasetest.CreateCompany(t, nil)
+	t.Cleanup(func() {
+		database.DB.NewDelete().Model((*models.Document)(nil)).Where("company_id = ?", company.ID).ForceDelete().Exec(ctx)
+	})
+
+	cfg := config.Get()
+	chunkSize := cfg.Database.InsertChunkSize
+	cfg.Database.InsertChunkSize = 2
+	t.Cleanup(func() { cfg.Database.InsertChunkSize = chunkSize })
+
+	documents := make([]*models.Document, 5)
+	for i := range documents {
+		documents[i] = &models.Document{
+			CompanyID:        company.ID,
+			Type:             models.DocumentTypeNFSe,
+			Number:           fmt.Sprint(i + 1),
+			VerificationCode: fmt.Sprintf("CHUNK-%d", i+1),
+			IssueDate:        time.Now(),
+		}
+	}
+
+	result, err := repository.NewDocumentRepository().InsertDocuments(ctx, documents, repository.DocumentLimit{})
+	if err != nil {
+		t.Fatalf("InsertDocuments() error = %v", err)
+	}
+	if result.Inserted != len(documents) {
+		t.Errorf("InsertDocuments() inserted = %d, want %d", result.Inserted, len(documents))
+	}
+
+	// Every chunk must fill in the IDs of its own documents, in order
+	for i, document := range documents {
+		if document.ID == 0 || i > 0 && document.ID <= documents[i-1].ID {
+			t.Fatalf("document %d ID = %d after %d, want increasing IDs across chunks", i, document.ID, documents[max(i-1, 0)].ID)
+		}
+		stored := &models.Document{}
+		if err := database.DB.NewSelect().Model(stored).Where("id = ?", document.ID).Scan(ctx); err != nil {
+			t.Fatalf("document %d not stored: %v", i, err)
+		}
+		if stored.VerificationCode != document.VerificationCode {
+			t.Errorf("document %d verification code = %q, want %q", document.ID, stored.VerificationCode, document.VerificationCode)
+		}
+	}
+}
+
+func TestProcessBatchXMLWritesProcessingLog(t *testing.T) {
+	databasetest.Require(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name       string
+		storeLogs  bool
+		wantLogs   int
+		wantStatus string
+	}{
+		{"stored when enabled", true, 1, models.ProcessingStatusPartial},
+		{"skipped when disabled", false, 0, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useFakeIngest(t)
+			config.Get().Logger.StoreProcessingLogs = tt.storeLogs
+
+			company := databasetest.CreateCompany(t, nil)
+			t.Cleanup(func() {
+				database.DB.NewDelete().Model((*models.ProcessingLog)(nil)).Where("company_id = ?", company.ID).Exec(ctx)
+			})
+			manager := NewNFSeXMLManagerWithRepositories(&repositorytest.DocumentRepository{},
+				&repositorytest.CompanyRepository{Companies: map[int64]*models.Company{company.ID: company}})
+
+			xmlDocuments := []XMLDocument{
+				{FileName: "1.xml", Content: testNFSeXML("1", "AAA", company.CNPJ, "", "100.00")},
+				{FileName: "2.xml", Content: "<consultarNotaResponse>"},
+			}
+			result, err := manager.ProcessBatchXML(ctx, company.ID, BatchOptions{Source: ProcessingSourceManualUpload}, xmlDocuments)
+			if err != nil {
+				t.Fatalf("ProcessBatchXML() error = %v", err)
+			}
+
+			logs := []models.ProcessingLog{}
+			if err := database.DB.NewSelect().Model(&logs).Where("company_id = ?", company.ID).Scan(ctx); err != nil {
+				t.Fatal(err)
+			}
+			if len(logs) != tt.wantLogs {
+				t.Fatalf("processing logs = %d, want %d", len(logs), tt.wantLogs)
+			}
+			if tt.wantLogs == 0 {
+				return
+			}
+
+			entry := logs[0]
+			if entry.BatchID != result.BatchID || entry.Status != tt.wantStatus || entry.Source != ProcessingSourceManualUpload ||
+				entry.TotalDocuments != 2 || entry.ProcessedDocuments != 1 || entry.ErrorDocuments != 1 {
+				t.Errorf("processing log = %+v, want a %s log of batch %s with 1 processed and 1 error", entry, tt.wantStatus, result.BatchID)
+			}
+		})
+	}
+}
+
+// racingDocumentRepository simulates another writer changing every document between
+// the duplicate check and the overwrite
+type racingDocumentRepository struct {
+	*repositorytest.DocumentRepository
+}
+
+func (r racingDocumentRepository) UpdateDocumentIfUnchanged(ctx context.Context, document *models.Document, expectedUpdatedAt time.Time) (bool, error) {
+	for _, stored := range r.Documents {
+		stored.UpdatedAt = stored.UpdatedAt.Add(time.Second)
+	}
+	return r.DocumentRepository.UpdateDocumentIfUnchanged(ctx, document, expectedUpdatedAt)
+}
+
+func TestProcessBatchXMLOverwrite(t *testing.T) {
+	const (
+		companyCNPJ = "12345678000190"
+		oldKey      = "nfse/2025/032025/12345678000190/old.xml"
+	)
+
+	tests := []struct {
+		name            string
+		overwrite       bool
+		racing          bool
+		wantOverwritten int
+		wantDuplicate   int
+		wantErr         error
+		wantValue       float64 // service value of the stored document afterwards
+		wantObjects     int
+	}{
+		{"manual overwrite replaces the XML", true, false, 1, 0, nil, 150, 1},
+		{"automatic ingest skips the duplicate", false, false, 0, 1, nil, 100, 1},
+		{"lost optimistic lock keeps the document", true, true, 0, 0, ErrConcurrentModification, 100, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			memory := useFakeIngest(t)
+			ctx := context.Background()
+			memory.objects[oldKey] = []byte("old XML")
+
+			company := &models.Company{ID: 1, CNPJ: companyCNPJ}
+			documents := &repositorytest.DocumentRepository{}
+			existing := &models.Document{
+				CompanyID:        company.ID,
+				Type:             models.DocumentTypeNFSe,
+				Number:           "1",
+				VerificationCode: "AAA",
+				ServiceValue:     100,
+				StorageKey:       oldKey,
+				UpdatedAt:        time.Date(2025, 3, 15, 0, 0, 0, 0, time.UTC),
+			}
+			documents.Add(existing)
+
+			var repo repository.DocumentRepository = documents
+			if tt.racing {
+				repo = racingDocumentRepository{documents}
+			}
+			manager := NewNFSeXMLManagerWithRepositories(repo, &repositorytest.CompanyRepository{Companies: map[int64]*models.Company{company.ID: company}})
+
+			content := testNFSeXML("1", "AAA", companyCNPJ, "", "150.00")
+			result, err := manager.ProcessBatchXML(ctx, company.ID, BatchOptions{Source: ProcessingSourceManualUpload, Overwrite: tt.overwrite},
+				[]XMLDocument{{FileName: "1.xml", Content: content}})
+			if err != nil {
+				t.Fatalf("ProcessBatchXML() error = %v", err)
+			}
+
+			if result.OverwrittenDocuments != tt.wantOverwritten || result.DuplicateDocuments != tt.wantDuplicate {
+				t.Errorf("overwritten/duplicates = %d/%d, want %d/%d", result.OverwrittenDocuments, result.DuplicateDocuments, tt.wantOverwritten, tt.wantDuplicate)
+			}
+			if !errors.Is(result.Results[0].Error, tt.wantErr) {
+				t.Errorf("result error = %v, want %v", result.Results[0].Error, tt.wantErr)
+			}
+
+			stored := documents.Documents[0]
+			if stored.ServiceValue != tt.wantValue {
+				t.Errorf("stored service value = %v, want %v", stored.ServiceValue, tt.wantValue)
+			}
+			if len(memory.objects) != tt.wantObjects {
+				t.Errorf("objects = %d, want %d", len(memory.objects), tt.wantObjects)
+			}
+
+			// The document points at the one remaining object, holding its XML
+			wantContent := "old XML"
+			if tt.wantOverwritten > 0 {
+				wantContent = content
+				if stored.StorageKey == oldKey {
+					t.Errorf("overwritten document still points at %s", oldKey)
+				}
+			}
+			if got := string(memory.objects[stored.StorageKey]); got != wantContent {
+				t.Errorf("object %s = %q, want %q", stored.StorageKey, got, wantContent)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/models"
+)
+
+// maxReportedGaps caps the missing numbers listed per provider; MissingCount is always exact
+const maxReportedGaps = 1000
+
+// ProviderNumberingGaps reports the missing NFSe numbers of one provider within the
+// observed min–max range of a competência
+type ProviderNumberingGaps struct {
+	ProviderCNPJ      string  `json:"provider_cnpj"`
+	MinNumber         int64   `json:"min_number"`
+	MaxNumber         int64   `json:"max_number"`
+	ObservedDocuments int     `json:"observed_documents"`
+	MissingCount      int64   `json:"missing_count"`
+	Missing           []int64 `json:"missing"`
+	Truncated         bool    `json:"truncated"`
+	NonNumericNumbers int     `json:"non_numeric_numbers"`
+}
+
+// FindNumberingGaps finds, per provider CNPJ, the NFSe numbers missing from the
+// sequence of a company's documents in the given competência (YYYY-MM).
+// Numbers that are not numeric are counted and ignored.
+func FindNumberingGaps(ctx context.Context, companyID int64, competence string) ([]ProviderNumberingGaps, error) {
+	var rows []struct {
+		ProviderCNPJ string `bun:"provider_cnpj"`
+		Number       string `bun:"number"`
+	}
+
+	err := database.DB.NewSelect().
+		Model((*models.Document)(nil)).
+		Column("provider_cnpj", "number").
+		Where("company_id = ? AND type = 'nfse'", companyID).
+		Where("competence_month = ?", competence).
+		Scan(ctx, &rows)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch document numbers: %w", err)
+	}
+
+	numbersByProvider := make(map[string][]int64)
+	nonNumeric := make(map[string]int)
+	for _, row := range rows {
+		number, err := strconv.ParseInt(strings.TrimSpace(row.Number), 10, 64)
+		if err != nil {
+			nonNumeric[row.ProviderCNPJ]++
+			continue
+		}
+		numbersByProvider[row.ProviderCNPJ] = append(numbersByProvider[row.ProviderCNPJ], number)
+	}
+
+	for provider := range nonNumeric {
+		if _, ok := numbersByProvider[provider]; !ok {
+			numbersByProvider[provider] = nil
+		}
+	}
+
+	providers := make([]string, 0, len(numbersByProvider))
+	for provider := range numbersByProvider {
+		providers = append(providers, provider)
+	}
+	sort.Strings(providers)
+
+	report := make([]ProviderNumberingGaps, 0, len(providers))
+	for _, provider := range providers {
+		gaps := numberingGaps(numbersByProvider[provider])
+		gaps.ProviderCNPJ = provider
+		gaps.NonNumericNumbers = nonNumeric[provider]
+		report = append(report, gaps)
+	}
+
+	return report, nil
+}
+
+// numberingGaps computes the missing numbers between the smallest and largest number
+func numberingGaps(numbers []int64) ProviderNumberingGaps {
+	gaps := ProviderNumberingGaps{
+		ObservedDocuments: len(numbers),
+		Missing:           []int64{},
+	}
+	if len(numbers) == 0 {
+		return gaps
+	}
+
+	sort.Slice(numbers, func(i, j int) bool { return numbers[i] < numbers[j] })
+	gaps.MinNumber = numbers[0]
+	gaps.MaxNumber = numbers[len(numbers)-1]
+
+	for i := 1; i < len(numbers); i++ {
+		for missing := numbers[i-1] + 1; missing < numbers[i]; missing++ {
+			gaps.MissingCount++
+			if len(gaps.Missing) < maxReportedGaps {
+				gaps.Missing = append(gaps.Missing, missing)
+			} else {
+				gaps.Truncated = true
+				gaps.MissingCount += numbers[i] - missing - 1
+				break
+			}
+		}
+	}
+
+	return gaps
+}
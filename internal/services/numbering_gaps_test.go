@@ -0,0 +1,94 @@
+package services
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/zoomxml/internal/database/databasetest"
+	"github.com/zoomxml/internal/models"
+)
+
+func TestNumberingGaps(t *testing.T) {
+	tests := []struct {
+		name          string
+		numbers       []int64
+		wantMin       int64
+		wantMax       int64
+		wantCount     int64
+		wantMissing   []int64
+		wantTruncated bool
+	}{
+		{"no numbers", nil, 0, 0, 0, []int64{}, false},
+		{"contiguous", []int64{3, 1, 2}, 1, 3, 0, []int64{}, false},
+		{"gaps", []int64{10, 4, 7, 5}, 4, 10, 3, []int64{6, 8, 9}, false},
+		{"repeated numbers", []int64{1, 1, 3}, 1, 3, 1, []int64{2}, false},
+		{"truncated", []int64{1, 2000}, 1, 2000, 1998, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := numberingGaps(tt.numbers)
+			if got.MinNumber != tt.wantMin || got.MaxNumber != tt.wantMax || got.MissingCount != tt.wantCount || got.Truncated != tt.wantTruncated {
+				t.Errorf("numberingGaps() = min %d, max %d, missing %d, truncated %v, want %d, %d, %d, %v",
+					got.MinNumber, got.MaxNumber, got.MissingCount, got.Truncated, tt.wantMin, tt.wantMax, tt.wantCount, tt.wantTruncated)
+			}
+			if got.ObservedDocuments != len(tt.numbers) {
+				t.Errorf("numberingGaps() observed = %d, want %d", got.ObservedDocuments, len(tt.numbers))
+			}
+			if tt.wantTruncated {
+				if len(got.Missing) != maxReportedGaps || got.Missing[0] != 2 {
+					t.Errorf("numberingGaps() listed %d missing numbers from %v, want the first %d", len(got.Missing), got.Missing[:1], maxReportedGaps)
+				}
+			} else if !slices.Equal(got.Missing, tt.wantMissing) {
+				t.Errorf("numberingGaps() missing = %v, want %v", got.Missing, tt.wantMissing)
+			}
+		})
+	}
+}
+
+func TestFindNumberingGaps(t *testing.T) {
+	databasetest.Require(t)
+	company := databasetest.CreateCompany(t, nil)
+
+	seed := []struct {
+		provider   string
+		number     string
+		competence string
+	}{
+		{"11111111000111", "1", "2025-03"},
+		{"11111111000111", "2", "2025-03"},
+		{"11111111000111", "5", "2025-03"},
+		{"11111111000111", "7", "2025-03"},
+		{"11111111000111", "RPS-A", "2025-03"},
+		{"11111111000111", "3", "2025-04"}, // another competência
+		{"22222222000122", "100", "2025-03"},
+		{"22222222000122", "102", "2025-03"},
+		{"33333333000133", "ABC", "2025-03"},
+	}
+	for _, s := range seed {
+		databasetest.CreateDocument(t, &models.Document{CompanyID: company.ID, ProviderCNPJ: s.provider, Number: s.number, CompetenceMonth: s.competence})
+	}
+
+	report, err := FindNumberingGaps(context.Background(), company.ID, "2025-03")
+	if err != nil {
+		t.Fatalf("FindNumberingGaps() error = %v", err)
+	}
+
+	want := []ProviderNumberingGaps{
+		{ProviderCNPJ: "11111111000111", MinNumber: 1, MaxNumber: 7, ObservedDocuments: 4, MissingCount: 3, Missing: []int64{3, 4, 6}, NonNumericNumbers: 1},
+		{ProviderCNPJ: "22222222000122", MinNumber: 100, MaxNumber: 102, ObservedDocuments: 2, MissingCount: 1, Missing: []int64{101}},
+		{ProviderCNPJ: "33333333000133", Missing: []int64{}, NonNumericNumbers: 1},
+	}
+	if len(report) != len(want) {
+		t.Fatalf("FindNumberingGaps() = %+v, want %+v", report, want)
+	}
+	for i := range want {
+		got := report[i]
+		if got.ProviderCNPJ != want[i].ProviderCNPJ || got.MinNumber != want[i].MinNumber || got.MaxNumber != want[i].MaxNumber ||
+			got.ObservedDocuments != want[i].ObservedDocuments || got.MissingCount != want[i].MissingCount ||
+			!slices.Equal(got.Missing, want[i].Missing) || got.NonNumericNumbers != want[i].NonNumericNumbers {
+			t.Errorf("provider %d = %+v, want %+v", i, got, want[i])
+		}
+	}
+}
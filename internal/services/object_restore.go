@@ -0,0 +1,142 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/logger"
+	"github.com/zoomxml/internal/models"
+	"github.com/zoomxml/internal/storage"
+)
+
+// restoreBatchSize is the number of documents whose objects are checked per query
+const restoreBatchSize = 500
+
+// runningRestores holds the companies with a background restore in progress
+var runningRestores sync.Map
+
+// RestoredObject describes one document whose stored XML object was missing
+type RestoredObject struct {
+	DocumentID int64  `json:"document_id"`
+	StorageKey string `json:"storage_key"`
+	Restored   bool   `json:"restored"`
+	Error      string `json:"error,omitempty"`
+}
+
+// ObjectRestoreReport is the outcome of re-storing missing XML objects of a company
+type ObjectRestoreReport struct {
+	CompanyID int64            `json:"company_id"`
+	DryRun    bool             `json:"dry_run"`
+	Checked   int              `json:"checked"`
+	Missing   int              `json:"missing"`
+	Restored  int              `json:"restored"`
+	Failed    int              `json:"failed"`
+	Objects   []RestoredObject `json:"objects"`
+}
+
+// StartObjectRestore runs RestoreMissingObjects for a company in the background, logging
+// its report when done. It returns false when a restore of the company is already running.
+func StartObjectRestore(companyID int64) bool {
+	if _, running := runningRestores.LoadOrStore(companyID, true); running {
+		return false
+	}
+
+	go func() {
+		defer runningRestores.Delete(companyID)
+		if _, err := RestoreMissingObjects(context.Background(), companyID, false); err != nil {
+			logger.ErrorWithFields("Failed to restore missing NFSe objects", err, map[string]any{
+				"operation":  "restore_missing_objects",
+				"company_id": companyID,
+			})
+		}
+	}()
+	return true
+}
+
+// RestoreMissingObjects re-uploads the XML of documents whose database row exists
+// but whose storage object is missing, using the full XML kept in the metadata column.
+// Documents are checked in batches by id; the XML is loaded only for missing objects.
+// Only storage uploads are retried; rows are never changed. dryRun only reports.
+func RestoreMissingObjects(ctx context.Context, companyID int64, dryRun bool) (*ObjectRestoreReport, error) {
+	report := &ObjectRestoreReport{
+		CompanyID: companyID,
+		DryRun:    dryRun,
+		Objects:   []RestoredObject{},
+	}
+
+	var lastID int64
+	for {
+		documents := []models.Document{}
+		err := database.DB.NewSelect().
+			Model(&documents).
+			Column("id", "storage_key").
+			Where("company_id = ?", companyID).
+			Where("storage_key IS NOT NULL AND storage_key != ''").
+			Where("id > ?", lastID).
+			Order("id ASC").
+			Limit(restoreBatchSize).
+			Scan(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load documents: %w", err)
+		}
+		if len(documents) == 0 {
+			break
+		}
+
+		for _, doc := range documents {
+			lastID = doc.ID
+			report.Checked++
+
+			exists, err := storage.Storage.FileExists(ctx, nfseBucket, doc.StorageKey)
+			if err != nil {
+				return nil, fmt.Errorf("failed to check object %s: %w", doc.StorageKey, err)
+			}
+			if exists {
+				continue
+			}
+
+			report.Missing++
+			object := RestoredObject{DocumentID: doc.ID, StorageKey: doc.StorageKey}
+			if !dryRun {
+				if err := restoreObject(ctx, doc); err != nil {
+					object.Error = err.Error()
+					report.Failed++
+				} else {
+					object.Restored = true
+					report.Restored++
+				}
+			}
+			report.Objects = append(report.Objects, object)
+		}
+	}
+
+	logger.InfoWithFields("Checked NFSe storage objects", map[string]any{
+		"operation":  "restore_missing_objects",
+		"company_id": companyID,
+		"dry_run":    dryRun,
+		"checked":    report.Checked,
+		"missing":    report.Missing,
+		"restored":   report.Restored,
+		"failed":     report.Failed,
+	})
+
+	return report, nil
+}
+
+// restoreObject uploads the XML kept in the metadata of a document to its storage key
+func restoreObject(ctx context.Context, doc models.Document) error {
+	err := database.DB.NewSelect().
+		Model(&doc).
+		Column("metadata").
+		Where("id = ?", doc.ID).
+		Scan(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load stored XML: %w", err)
+	}
+	if doc.Metadata == "" {
+		return fmt.Errorf("document has no stored XML to restore from")
+	}
+	return storage.Storage.UploadFile(ctx, nfseBucket, doc.StorageKey, []byte(doc.Metadata), "application/xml")
+}
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/zoomxml/internal/database/databasetest"
+	"github.com/zoomxml/internal/models"
+	"github.com/zoomxml/internal/storage"
+)
+
+func TestRestoreMissingObjects(t *testing.T) {
+	databasetest.Require(t)
+	useFilesystemStorage(t)
+	ctx := context.Background()
+
+	company := databasetest.CreateCompany(t, nil)
+	key := func(number string) string { return fmt.Sprintf("nfse/%d/2025/03/%s.xml", company.ID, number) }
+	stored := databasetest.CreateDocument(t, &models.Document{CompanyID: company.ID, Number: "1", StorageKey: key("1"), Metadata: testNFSeXML("1", "A1", "11111111000111", "12345678000190", "10.00")})
+	missing := databasetest.CreateDocument(t, &models.Document{CompanyID: company.ID, Number: "2", StorageKey: key("2"), Metadata: testNFSeXML("2", "A2", "11111111000111", "12345678000190", "20.00")})
+	noXML := databasetest.CreateDocument(t, &models.Document{CompanyID: company.ID, Number: "3", StorageKey: key("3")})
+	databasetest.CreateDocument(t, &models.Document{CompanyID: company.ID, Number: "4"})
+
+	if err := storage.Storage.UploadFile(ctx, nfseBucket, stored.StorageKey, []byte("original"), "application/xml"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		dryRun       bool
+		wantMissing  int
+		wantRestored int
+		wantFailed   int
+	}{
+		{"dry run only reports", true, 2, 0, 0},
+		{"restores from metadata", false, 2, 1, 1},
+		{"second run finds only the row without XML", false, 1, 0, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := RestoreMissingObjects(ctx, company.ID, tt.dryRun)
+			if err != nil {
+				t.Fatalf("RestoreMissingObjects() error = %v", err)
+			}
+			if report.Checked != 3 || report.Missing != tt.wantMissing || report.Restored != tt.wantRestored || report.Failed != tt.wantFailed {
+				t.Errorf("RestoreMissingObjects() checked %d missing %d restored %d failed %d, want 3/%d/%d/%d",
+					report.Checked, report.Missing, report.Restored, report.Failed, tt.wantMissing, tt.wantRestored, tt.wantFailed)
+			}
+			for _, object := range report.Objects {
+				if object.DocumentID == stored.ID {
+					t.Errorf("document %d with an existing object reported as missing", stored.ID)
+				}
+				if object.DocumentID == noXML.ID && !tt.dryRun && object.Error == "" {
+					t.Errorf("document %d without XML restored without error", noXML.ID)
+				}
+			}
+		})
+	}
+
+	content, err := storage.Storage.DownloadFile(ctx, nfseBucket, missing.StorageKey)
+	if err != nil {
+		t.Fatalf("restored object: %v", err)
+	}
+	if string(content) != missing.Metadata {
+		t.Errorf("restored object = %q, want the XML kept in metadata", content)
+	}
+	if content, _ := storage.Storage.DownloadFile(ctx, nfseBucket, stored.StorageKey); string(content) != "original" {
+		t.Errorf("existing object = %q, want it untouched", content)
+	}
+}
@@ -0,0 +1,232 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/zoomxml/config"
+	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/logger"
+	"github.com/zoomxml/internal/models"
+)
+
+// pendingIngestBatchSize is the number of buffered XMLs retried per company on each run
+const pendingIngestBatchSize = 100
+
+// bufferPendingIngests saves fetched XMLs that could not be stored, to be ingested again
+// by the PendingIngestRetrier once storage is back
+func bufferPendingIngests(ctx context.Context, companyID int64, source string, documents []XMLDocument) error {
+	if len(documents) == 0 {
+		return nil
+	}
+
+	pending := make([]models.PendingIngest, len(documents))
+	for i, doc := range documents {
+		pending[i] = models.PendingIngest{
+			CompanyID:  companyID,
+			FileName:   doc.FileName,
+			XMLContent: doc.Content,
+			Source:     source,
+			Competence: doc.Competence,
+		}
+	}
+
+	if _, err := database.DB.NewInsert().Model(&pending).Exec(ctx); err != nil {
+		return fmt.Errorf("failed to buffer pending ingests: %w", err)
+	}
+
+	logger.WarnWithFields("Storage unavailable, documents buffered for retry", map[string]any{
+		"operation":       "buffer_pending_ingest",
+		"company_id":      companyID,
+		"documents_count": len(documents),
+	})
+
+	return nil
+}
+
+// PendingIngestRetrier periodically re-ingests XMLs buffered while storage was down
+type PendingIngestRetrier struct {
+	xmlManager *NFSeXMLManager
+	ticker     *time.Ticker
+	stopChan   chan bool
+	running    bool
+	config     *config.Config
+}
+
+// NewPendingIngestRetrier creates a new retrier of buffered ingests
+func NewPendingIngestRetrier() *PendingIngestRetrier {
+	return &PendingIngestRetrier{
+		xmlManager: NewNFSeXMLManager(),
+		stopChan:   make(chan bool),
+		config:     config.Get(),
+	}
+}
+
+// Start begins retrying buffered ingests every STORAGE_PENDING_INGEST_RETRY_INTERVAL
+func (r *PendingIngestRetrier) Start() {
+	if !r.config.Storage.PendingIngestEnabled || r.running {
+		return
+	}
+
+	interval := r.config.Storage.PendingIngestRetryInterval
+	if interval <= 0 {
+		interval = 5 * time.Minute
+	}
+
+	r.ticker = time.NewTicker(interval)
+	r.running = true
+
+	logger.InfoWithFields("Starting pending ingest retrier", map[string]any{
+		"operation": "start_pending_ingest_retrier",
+		"interval":  interval.String(),
+	})
+
+	go r.run()
+}
+
+// Stop stops the retrier
+func (r *PendingIngestRetrier) Stop() {
+	if !r.running {
+		return
+	}
+
+	r.stopChan <- true
+	r.ticker.Stop()
+	r.running = false
+}
+
+// run is the retrier loop
+func (r *PendingIngestRetrier) run() {
+	for {
+		select {
+		case <-r.ticker.C:
+			r.RetryPendingIngests(context.Background())
+		case <-r.stopChan:
+			return
+		}
+	}
+}
+
+// RetryPendingIngests re-processes the buffered XMLs of every company. Documents that are
+// stored, turn out to be duplicates or are rejected as invalid leave the buffer; any other
+// failure (storage or database) keeps them with their attempt count increased, until
+// PendingIngestMaxAttempts is reached.
+func (r *PendingIngestRetrier) RetryPendingIngests(ctx context.Context) {
+	var companyIDs []int64
+	err := database.DB.NewSelect().
+		Model((*models.PendingIngest)(nil)).
+		ColumnExpr("DISTINCT company_id").
+		Where("attempts < ?", r.maxAttempts()).
+		Scan(ctx, &companyIDs)
+
+	if err != nil {
+		logger.ErrorWithFields("Failed to load pending ingests", err, map[string]any{
+			"operation": "retry_pending_ingests",
+		})
+		return
+	}
+
+	for _, companyID := range companyIDs {
+		if err := r.retryCompany(ctx, companyID); err != nil {
+			logger.ErrorWithFields("Failed to retry pending ingests", err, map[string]any{
+				"operation":  "retry_pending_ingests",
+				"company_id": companyID,
+			})
+		}
+	}
+}
+
+// retryCompany re-processes one batch of buffered XMLs of a company
+func (r *PendingIngestRetrier) retryCompany(ctx context.Context, companyID int64) error {
+	pending := []models.PendingIngest{}
+	err := database.DB.NewSelect().
+		Model(&pending).
+		Where("company_id = ? AND attempts < ?", companyID, r.maxAttempts()).
+		Order("id ASC").
+		Limit(pendingIngestBatchSize).
+		Scan(ctx)
+
+	if err != nil || len(pending) == 0 {
+		return err
+	}
+
+	xmlDocuments := make([]XMLDocument, len(pending))
+	for i, p := range pending {
+		xmlDocuments[i] = XMLDocument{FileName: p.FileName, Content: p.XMLContent, Competence: p.Competence}
+	}
+
+	result, err := r.xmlManager.ProcessBatchXML(ctx, companyID, BatchOptions{Source: ProcessingSourcePendingRetry}, xmlDocuments)
+	if err != nil {
+		return err
+	}
+
+	done := []int64{}
+	failed := []int64{}
+	exhausted := 0
+	lastError := ""
+	for i, docResult := range result.Results {
+		if docResult.Success || docResult.IsDuplicate || docResult.Rejected {
+			done = append(done, pending[i].ID)
+			continue
+		}
+		failed = append(failed, pending[i].ID)
+		if docResult.Error != nil {
+			lastError = docResult.Error.Error()
+		}
+		if pending[i].Attempts+1 >= r.maxAttempts() {
+			exhausted++
+		}
+	}
+
+	if len(done) > 0 {
+		_, err = database.DB.NewDelete().
+			Model((*models.PendingIngest)(nil)).
+			Where("id IN (?)", bun.In(done)).
+			Exec(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to remove retried ingests: %w", err)
+		}
+	}
+
+	if len(failed) > 0 {
+		_, err = database.DB.NewUpdate().
+			Model((*models.PendingIngest)(nil)).
+			Set("attempts = attempts + 1").
+			Set("last_error = ?", lastError).
+			Set("updated_at = ?", time.Now()).
+			Where("id IN (?)", bun.In(failed)).
+			Exec(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to update pending ingests: %w", err)
+		}
+	}
+
+	if exhausted > 0 {
+		logger.WarnWithFields("Pending ingests reached the retry limit and will not be retried", map[string]any{
+			"operation":    "retry_pending_ingests",
+			"company_id":   companyID,
+			"documents":    exhausted,
+			"max_attempts": r.maxAttempts(),
+			"last_error":   lastError,
+		})
+	}
+
+	logger.InfoWithFields("Retried pending ingests", map[string]any{
+		"operation":  "retry_pending_ingests",
+		"company_id": companyID,
+		"ingested":   len(done),
+		"pending":    len(failed),
+	})
+
+	return nil
+}
+
+// maxAttempts is the number of retries after which a buffered XML is left alone
+func (r *PendingIngestRetrier) maxAttempts() int {
+	if r.config.Storage.PendingIngestMaxAttempts <= 0 {
+		return 20
+	}
+	return r.config.Storage.PendingIngestMaxAttempts
+}
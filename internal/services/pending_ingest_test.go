@@ -0,0 +1,154 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/zoomxml/config"
+	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/database/databasetest"
+	"github.com/zoomxml/internal/models"
+	"github.com/zoomxml/internal/storage"
+)
+
+// unavailableStorage is a memoryStorage whose uploads fail while down is set
+type unavailableStorage struct {
+	*memoryStorage
+	down bool
+}
+
+func (s *unavailableStorage) UploadFile(ctx context.Context, bucketName, objectName string, data []byte, contentType string) error {
+	if s.down {
+		return errors.New("storage unavailable")
+	}
+	return s.memoryStorage.UploadFile(ctx, bucketName, objectName, data, contentType)
+}
+
+// usePendingIngest buffers failed uploads with the given retry limit and returns a storage
+// that starts down, plus a company whose buffered and stored documents are removed at the end
+func usePendingIngest(t *testing.T, maxAttempts int) (*unavailableStorage, *models.Company) {
+	t.Helper()
+	cfg := config.Get()
+	enabled, attempts := cfg.Storage.PendingIngestEnabled, cfg.Storage.PendingIngestMaxAttempts
+	cfg.Storage.PendingIngestEnabled = true
+	cfg.Storage.PendingIngestMaxAttempts = maxAttempts
+	t.Cleanup(func() {
+		cfg.Storage.PendingIngestEnabled = enabled
+		cfg.Storage.PendingIngestMaxAttempts = attempts
+	})
+
+	unavailable := &unavailableStorage{memoryStorage: useFakeIngest(t), down: true}
+	storage.Storage = unavailable
+
+	company := databasetest.CreateCompany(t, nil)
+	t.Cleanup(func() {
+		ctx := context.Background()
+		database.DB.NewDelete().Model((*models.PendingIngest)(nil)).Where("company_id = ?", company.ID).Exec(ctx)
+		database.DB.NewDelete().Model((*models.Document)(nil)).Where("company_id = ?", company.ID).ForceDelete().Exec(ctx)
+	})
+	return unavailable, company
+}
+
+// pendingIngestsOf returns the buffered XMLs of a company
+func pendingIngestsOf(t *testing.T, companyID int64) []models.PendingIngest {
+	t.Helper()
+	pending := []models.PendingIngest{}
+	err := database.DB.NewSelect().Model(&pending).Where("company_id = ?", companyID).Order("id ASC").Scan(context.Background())
+	if err != nil {
+		t.Fatalf("failed to load pending ingests: %v", err)
+	}
+	return pending
+}
+
+// documentCountOf returns the number of stored documents of a company
+func documentCountOf(t *testing.T, companyID int64) int {
+	t.Helper()
+	count, err := database.DB.NewSelect().Model((*models.Document)(nil)).Where("company_id = ?", companyID).Count(context.Background())
+	if err != nil {
+		t.Fatalf("failed to count documents: %v", err)
+	}
+	return count
+}
+
+// storeWhileDown fetches two notes of company into the service while storage is down
+func storeWhileDown(t *testing.T, company *models.Company) {
+	t.Helper()
+	documents := []NFSeDocument{
+		{FileName: "nfse_1.xml", XMLContent: testNFSeXML("1", "A1", "11111111000111", company.CNPJ, "100.00")},
+		{FileName: "nfse_2.xml", XMLContent: testNFSeXML("2", "A2", "11111111000111", company.CNPJ, "200.00")},
+	}
+	// The batch fails, but the XMLs are kept instead of being lost
+	NewNFSeService(nil).StoreNFSeDocuments(context.Background(), company.ID, documents)
+}
+
+func TestPendingIngestRecovery(t *testing.T) {
+	databasetest.Require(t)
+	unavailable, company := usePendingIngest(t, 5)
+	retrier := NewPendingIngestRetrier()
+	ctx := context.Background()
+
+	storeWhileDown(t, company)
+	if pending := pendingIngestsOf(t, company.ID); len(pending) != 2 {
+		t.Fatalf("pending ingests while storage is down = %d, want 2", len(pending))
+	}
+	if count := documentCountOf(t, company.ID); count != 0 {
+		t.Fatalf("documents while storage is down = %d, want 0", count)
+	}
+
+	// A retry while storage is still down keeps the XMLs and counts the attempt
+	if err := retrier.retryCompany(ctx, company.ID); err != nil {
+		t.Fatalf("retryCompany() error = %v", err)
+	}
+	for _, pending := range pendingIngestsOf(t, company.ID) {
+		if pending.Attempts != 1 || pending.LastError == "" {
+			t.Errorf("pending ingest %s attempts %d error %q, want 1 attempt with an error", pending.FileName, pending.Attempts, pending.LastError)
+		}
+	}
+
+	// Once storage is back the retry completes the ingest and empties the buffer
+	unavailable.down = false
+	if err := retrier.retryCompany(ctx, company.ID); err != nil {
+		t.Fatalf("retryCompany() error = %v", err)
+	}
+	if pending := pendingIngestsOf(t, company.ID); len(pending) != 0 {
+		t.Errorf("pending ingests after recovery = %d, want 0", len(pending))
+	}
+	if count := documentCountOf(t, company.ID); count != 2 {
+		t.Errorf("documents after recovery = %d, want 2", count)
+	}
+	if len(unavailable.objects) != 2 {
+		t.Errorf("stored objects after recovery = %d, want 2", len(unavailable.objects))
+	}
+}
+
+func TestPendingIngestRetryLimit(t *testing.T) {
+	databasetest.Require(t)
+	unavailable, company := usePendingIngest(t, 2)
+	retrier := NewPendingIngestRetrier()
+	ctx := context.Background()
+
+	storeWhileDown(t, company)
+	for range 3 {
+		if err := retrier.retryCompany(ctx, company.ID); err != nil {
+			t.Fatalf("retryCompany() error = %v", err)
+		}
+	}
+	for _, pending := range pendingIngestsOf(t, company.ID) {
+		if pending.Attempts != 2 {
+			t.Errorf("pending ingest %s attempts = %d, want the limit of 2", pending.FileName, pending.Attempts)
+		}
+	}
+
+	// XMLs past the limit are kept for inspection but no longer retried
+	unavailable.down = false
+	if err := retrier.retryCompany(ctx, company.ID); err != nil {
+		t.Fatalf("retryCompany() error = %v", err)
+	}
+	if pending := pendingIngestsOf(t, company.ID); len(pending) != 2 {
+		t.Errorf("pending ingests past the limit = %d, want 2", len(pending))
+	}
+	if count := documentCountOf(t, company.ID); count != 0 {
+		t.Errorf("documents from ingests past the limit = %d, want 0", count)
+	}
+}
@@ -0,0 +1,64 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/zoomxml/config"
+	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/logger"
+	"github.com/zoomxml/internal/models"
+)
+
+// Sources recorded in processing logs
+const (
+	ProcessingSourcePrefeituraAPI = "prefeitura_api"
+	ProcessingSourceManualUpload  = "manual_upload"
+	ProcessingSourcePendingRetry  = "pending_ingest_retry"
+)
+
+// recordProcessingLog persists a processing log row for an ingest batch.
+// Failures are logged and never interrupt the batch.
+func recordProcessingLog(ctx context.Context, companyID int64, source string, result *BatchProcessingResult, batchErr error) {
+	if !config.Get().Logger.StoreProcessingLogs {
+		return
+	}
+
+	entry := &models.ProcessingLog{
+		CompanyID:          companyID,
+		BatchID:            result.BatchID,
+		Operation:          "process_batch_xml",
+		Source:             source,
+		Target:             nfseBucket,
+		TotalDocuments:     result.TotalDocuments,
+		ProcessedDocuments: result.ProcessedDocuments,
+		DuplicateDocuments: result.DuplicateDocuments,
+		ErrorDocuments:     result.ErrorDocuments,
+		DurationMs:         result.ProcessingTime.Milliseconds(),
+	}
+
+	switch {
+	case batchErr != nil:
+		entry.Status = models.ProcessingStatusFailed
+		entry.Message = batchErr.Error()
+	case result.ErrorDocuments == 0:
+		entry.Status = models.ProcessingStatusSuccess
+	case result.ErrorDocuments < result.TotalDocuments:
+		entry.Status = models.ProcessingStatusPartial
+	default:
+		entry.Status = models.ProcessingStatusFailed
+	}
+
+	if batchErr == nil {
+		entry.Message = fmt.Sprintf("%d processed, %d duplicates, %d overwritten, %d errors",
+			result.ProcessedDocuments, result.DuplicateDocuments, result.OverwrittenDocuments, result.ErrorDocuments)
+	}
+
+	if _, err := database.DB.NewInsert().Model(entry).Exec(ctx); err != nil {
+		logger.ErrorWithFields("Failed to store processing log", err, map[string]any{
+			"operation":  "process_batch_xml",
+			"company_id": companyID,
+			"batch_id":   result.BatchID,
+		})
+	}
+}
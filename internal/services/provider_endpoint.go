@@ -0,0 +1,97 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/zoomxml/config"
+	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/models"
+)
+
+// ErrProviderURLNotAllowed is returned for provider base URLs outside the configured allowlist
+var ErrProviderURLNotAllowed = errors.New("provider base URL is not allowed")
+
+// ValidateProviderBaseURL checks that raw is an absolute https URL whose host is in
+// NFSE_PROVIDER_ALLOWED_HOSTS
+func ValidateProviderBaseURL(raw string) error {
+	return validateProviderBaseURL(raw, config.Get().NFSeScheduler.AllowedHosts)
+}
+
+// validateProviderBaseURL is ValidateProviderBaseURL against an explicit allowlist
+func validateProviderBaseURL(raw string, allowedHosts []string) error {
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid provider base URL: %w", err)
+	}
+
+	if parsed.Scheme != "https" || parsed.Host == "" {
+		return fmt.Errorf("provider base URL must be an absolute https URL")
+	}
+
+	if parsed.User != nil || parsed.RawQuery != "" || parsed.Fragment != "" {
+		return fmt.Errorf("provider base URL must not contain credentials, query or fragment")
+	}
+
+	if !hostAllowed(parsed.Hostname(), allowedHosts) {
+		return fmt.Errorf("%w: %s", ErrProviderURLNotAllowed, parsed.Hostname())
+	}
+
+	return nil
+}
+
+// hostAllowed reports whether host matches an allowlist entry. "*.example.com" matches
+// any subdomain of example.com, other entries must match exactly.
+func hostAllowed(host string, allowedHosts []string) bool {
+	host = strings.ToLower(host)
+	for _, allowed := range allowedHosts {
+		allowed = strings.ToLower(strings.TrimSpace(allowed))
+		if allowed == "" {
+			continue
+		}
+		if suffix, ok := strings.CutPrefix(allowed, "*"); ok {
+			if strings.HasPrefix(suffix, ".") && strings.HasSuffix(host, suffix) && len(host) > len(suffix) {
+				return true
+			}
+			continue
+		}
+		if host == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveProviderBaseURL returns the provider endpoint of a company: its own override
+// when set, the configured default otherwise. The override is validated again so that
+// tightening the allowlist also applies to companies configured earlier.
+func resolveProviderBaseURL(ctx context.Context, companyID int64) (string, error) {
+	var override string
+	err := database.DB.NewSelect().
+		Model((*models.Company)(nil)).
+		ColumnExpr("COALESCE(provider_base_url, '')").
+		Where("id = ?", companyID).
+		Scan(ctx, &override)
+
+	if err != nil {
+		return "", fmt.Errorf("failed to load company provider base URL: %w", err)
+	}
+
+	return providerBaseURL(override, config.Get().NFSeScheduler)
+}
+
+// providerBaseURL picks between the company override and the configured default
+func providerBaseURL(override string, cfg config.NFSeSchedulerConfig) (string, error) {
+	if override == "" {
+		return cfg.DefaultBaseURL, nil
+	}
+
+	if err := validateProviderBaseURL(override, cfg.AllowedHosts); err != nil {
+		return "", err
+	}
+
+	return strings.TrimSuffix(override, "/"), nil
+}
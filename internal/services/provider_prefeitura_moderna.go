@@ -0,0 +1,251 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/zoomxml/internal/logger"
+	"github.com/zoomxml/internal/models"
+)
+
+// PrefeituraModernaResponse represents the actual response from Prefeitura Moderna API
+type PrefeituraModernaResponse struct {
+	RecordCount    int                    `json:"RecordCount"`
+	RecordsPerPage int                    `json:"RecordsPerPage"`
+	PageCount      int                    `json:"PageCount"`
+	CurrentPage    int                    `json:"CurrentPage"`
+	Dados          []PrefeituraModernaDoc `json:"Dados"`
+}
+
+// PrefeituraModernaDoc represents a single NFSe document from the API
+type PrefeituraModernaDoc struct {
+	NrNfse        int    `json:"NrNfse"`        // Número da NFSe
+	DtEmissao     string `json:"DtEmissao"`     // Data de emissão
+	NrCompetencia int    `json:"NrCompetencia"` // Competência YYYYMM
+	XmlCompactado string `json:"XmlCompactado"` // ZIP em Base64 contendo o XML
+}
+
+// prefeituraModernaProvider fetches notes from the Prefeitura Moderna API, which serves
+// Imperatriz (MA). The endpoint is the company provider_base_url override when set,
+// NFSE_PROVIDER_BASE_URL otherwise.
+type prefeituraModernaProvider struct {
+	client *http.Client
+}
+
+func newPrefeituraModernaProvider(client *http.Client) MunicipalityProvider {
+	return &prefeituraModernaProvider{client: client}
+}
+
+func (p *prefeituraModernaProvider) Capabilities() ProviderCapabilities {
+	return ProviderCapabilities{
+		Name:               "prefeitura_moderna",
+		CredentialTypes:    []string{"prefeitura_token", "prefeitura_mixed"},
+		Paginated:          true,
+		ProvidesCompetence: true,
+	}
+}
+
+// Authenticate returns the API token of the credential, sent as is in the Authorization header
+func (p *prefeituraModernaProvider) Authenticate(ctx context.Context, credential *models.CompanyCredential) (string, error) {
+	// Get the API token from encrypted credentials
+	_, _, token, err := credential.GetCredentialData()
+	if err != nil {
+		logger.ErrorWithFields("Failed to decrypt credential data", err, map[string]any{
+			"operation":     "fetch_nfse",
+			"credential_id": credential.ID,
+			"company_id":    credential.CompanyID,
+		})
+		return "", fmt.Errorf("failed to decrypt credential data: %w", err)
+	}
+
+	if token == "" {
+		return "", fmt.Errorf("API token not found in credentials")
+	}
+
+	return token, nil
+}
+
+func (p *prefeituraModernaProvider) FetchDocuments(ctx context.Context, request ProviderFetchRequest, handle func([]NFSeDocument) error) (*NFSeProcessResult, error) {
+	credential := request.Credential
+	token := request.Authorization
+	startDate, endDate, page := request.StartDate, request.EndDate, request.Page
+
+	// Build the API URL with pagination
+	baseURL, err := resolveProviderBaseURL(ctx, credential.CompanyID)
+	if err != nil {
+		return nil, err
+	}
+
+	url := fmt.Sprintf("%s?dt_inicial=%s&dt_final=%s&nr_page=%d",
+		baseURL,
+		startDate.Format("2006-01-02"),
+		endDate.Format("2006-01-02"),
+		page,
+	)
+
+	// Create the request
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	// Set headers
+	req.Header.Set("Authorization", token)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "ZoomXML/1.0.0")
+
+	// Checked once the request is ready, so a half-open trial slot always reaches the provider
+	breaker := providerBreaker(baseURL)
+	if err := breaker.Allow(); err != nil {
+		return nil, err
+	}
+
+	logger.InfoWithFields("Making NFSe API request", map[string]any{
+		"operation":     "fetch_nfse",
+		"url":           url,
+		"company_id":    credential.CompanyID,
+		"credential_id": credential.ID,
+		"start_date":    startDate.Format("2006-01-02"),
+		"end_date":      endDate.Format("2006-01-02"),
+	})
+
+	// Make the request
+	resp, err := p.client.Do(req)
+	if err != nil {
+		breaker.recordCallError(ctx)
+		logger.ErrorWithFields("NFSe API request failed", err, map[string]any{
+			"operation":  "fetch_nfse",
+			"url":        url,
+			"company_id": credential.CompanyID,
+		})
+		return nil, fmt.Errorf("API request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Read response body
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		breaker.recordCallError(ctx)
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	// Client errors mean the provider is up; only server errors count against it
+	if resp.StatusCode >= http.StatusInternalServerError {
+		breaker.RecordFailure()
+	} else {
+		breaker.RecordSuccess()
+	}
+
+	captureRawResponse(ctx, credential.CompanyID, page, resp.Header.Get("Content-Type"), body)
+
+	logger.InfoWithFields("NFSe API response received", map[string]any{
+		"operation":     "fetch_nfse",
+		"status_code":   resp.StatusCode,
+		"company_id":    credential.CompanyID,
+		"response_size": len(body),
+	})
+
+	// Check HTTP status
+	if resp.StatusCode != http.StatusOK {
+		logger.ErrorWithFields("NFSe API returned error status", nil, map[string]any{
+			"operation":   "fetch_nfse",
+			"status_code": resp.StatusCode,
+			"response":    string(body),
+			"company_id":  credential.CompanyID,
+		})
+		// Only 401 means the credential itself was refused; a 403 may be a permission or
+		// network policy of the provider and must not count towards deactivating it
+		if resp.StatusCode == http.StatusUnauthorized {
+			return nil, fmt.Errorf("%w: API returned status %d: %s", ErrCredentialRejected, resp.StatusCode, string(body))
+		}
+		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	// Only an accepted credential counts as used
+	markCredentialUsed(ctx, credential.ID)
+	if credential.FailureCount > 0 {
+		resetCredentialFailures(ctx, credential)
+	}
+
+	// Parse JSON response from Prefeitura Moderna
+	var apiResponse PrefeituraModernaResponse
+	if err := json.Unmarshal(body, &apiResponse); err != nil {
+		logger.ErrorWithFields("Failed to parse JSON response", err, map[string]any{
+			"operation":  "fetch_nfse",
+			"company_id": credential.CompanyID,
+			"response":   string(body),
+		})
+		return &NFSeProcessResult{
+			Success: false,
+			Message: "Failed to parse API response",
+			Error:   err.Error(),
+		}, nil
+	}
+
+	documentsCount := 0
+
+	// Process each NFSe document
+	for _, nfseDoc := range apiResponse.Dados {
+		if nfseDoc.XmlCompactado == "" {
+			logger.WarnWithFields("Empty XmlCompactado found", map[string]any{
+				"operation":  "fetch_nfse",
+				"company_id": credential.CompanyID,
+				"nfse_nr":    nfseDoc.NrNfse,
+			})
+			continue
+		}
+
+		// Extract XML files from ZIP
+		documents, err := extractXMLFromZip(nfseDoc.XmlCompactado)
+		if err != nil {
+			logger.ErrorWithFields("Failed to extract XML from ZIP", err, map[string]any{
+				"operation":  "fetch_nfse",
+				"company_id": credential.CompanyID,
+				"nfse_nr":    nfseDoc.NrNfse,
+			})
+			continue
+		}
+
+		// The provider's competência backs up a missing or unparseable one in the XML
+		if competence := providerCompetence(nfseDoc.NrCompetencia); competence != "" {
+			for i := range documents {
+				documents[i].Competence = competence
+			}
+		}
+
+		if err := handle(documents); err != nil {
+			return nil, err
+		}
+		documentsCount += len(documents)
+	}
+
+	logger.InfoWithFields("NFSe documents fetched successfully", map[string]any{
+		"operation":       "fetch_nfse",
+		"company_id":      credential.CompanyID,
+		"documents_count": documentsCount,
+		"page":            page,
+		"total_records":   apiResponse.RecordCount,
+	})
+
+	return &NFSeProcessResult{
+		Success:        true,
+		Message:        fmt.Sprintf("Successfully fetched %d documents from page %d", documentsCount, page),
+		DocumentsCount: documentsCount,
+	}, nil
+}
+
+// extractXMLFromZip extracts XML files from a Base64 encoded ZIP, within the ZIP limits
+func extractXMLFromZip(base64Zip string) ([]NFSeDocument, error) {
+	// Decode Base64
+	zipData, err := base64.StdEncoding.DecodeString(base64Zip)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode base64: %w", err)
+	}
+
+	return ExtractZipDocuments(zipData, DefaultZipLimits(), false)
+}
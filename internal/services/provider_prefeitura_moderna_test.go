@@ -0,0 +1,74 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/zoomxml/config"
+	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/database/databasetest"
+	"github.com/zoomxml/internal/models"
+)
+
+// TestPrefeituraModernaMarksOnlyAcceptedCredentialsUsed checks that a credential the
+// provider refuses is not recorded as used
+func TestPrefeituraModernaMarksOnlyAcceptedCredentialsUsed(t *testing.T) {
+	databasetest.Require(t)
+	ctx := context.Background()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "rejected" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"RecordCount":0,"Dados":[]}`))
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := &config.Get().NFSeScheduler
+	previous := cfg.DefaultBaseURL
+	cfg.DefaultBaseURL = server.URL
+	t.Cleanup(func() { cfg.DefaultBaseURL = previous })
+
+	tests := []struct {
+		name      string
+		token     string
+		wantErr   error
+		wantMoved bool
+	}{
+		{"rejected", "rejected", ErrCredentialRejected, false},
+		{"accepted", "accepted", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			company := databasetest.CreateCompany(t, nil)
+			credential := databasetest.CreateCredential(t, &models.CompanyCredential{CompanyID: company.ID, Active: true})
+
+			provider := newPrefeituraModernaProvider(server.Client())
+			now := time.Now()
+			_, err := provider.FetchDocuments(ctx, ProviderFetchRequest{
+				Credential:    credential,
+				Authorization: tt.token,
+				StartDate:     now.AddDate(0, 0, -1),
+				EndDate:       now,
+				Page:          1,
+			}, func([]NFSeDocument) error { return nil })
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("FetchDocuments() error = %v, want %v", err, tt.wantErr)
+			}
+
+			stored := &models.CompanyCredential{}
+			if err := database.DB.NewSelect().Model(stored).Where("id = ?", credential.ID).Scan(ctx); err != nil {
+				t.Fatal(err)
+			}
+			if used := stored.LastUsedAt != nil; used != tt.wantMoved {
+				t.Errorf("last_used_at = %v, want set %v", stored.LastUsedAt, tt.wantMoved)
+			}
+		})
+	}
+}
@@ -0,0 +1,236 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/zoomxml/config"
+	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/models"
+)
+
+var (
+	// ErrInvalidRefreshToken is returned for unknown, expired or revoked refresh tokens and
+	// for tokens of inactive users
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	// ErrRefreshTokenReused is returned when an already rotated token is presented again;
+	// every session of the user is ended, since one of the two holders is not the user
+	ErrRefreshTokenReused = errors.New("refresh token reused")
+)
+
+// IssuedRefreshToken is a refresh token handed to the client with its short-lived access
+// token; only their hashes are stored
+type IssuedRefreshToken struct {
+	Token           string
+	ExpiresAt       time.Time
+	AccessToken     string
+	AccessExpiresAt time.Time
+	record          *models.RefreshToken
+}
+
+// IssueRefreshToken creates a refresh token for the user valid for REFRESH_TOKEN_EXPIRY,
+// with an access token valid for ACCESS_TOKEN_EXPIRY
+func IssueRefreshToken(ctx context.Context, db bun.IDB, userID int64) (*IssuedRefreshToken, error) {
+	token, err := randomToken(32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+	accessToken, err := randomToken(32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate access token: %w", err)
+	}
+
+	now := time.Now()
+	cfg := config.Get().Auth
+	record := &models.RefreshToken{
+		UserID:          userID,
+		TokenHash:       hashRefreshToken(token),
+		ExpiresAt:       now.Add(cfg.RefreshTokenExpiry),
+		AccessTokenHash: hashRefreshToken(accessToken),
+		AccessExpiresAt: now.Add(cfg.AccessTokenExpiry),
+	}
+	if _, err := db.NewInsert().Model(record).Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to store refresh token: %w", err)
+	}
+
+	return &IssuedRefreshToken{
+		Token:           token,
+		ExpiresAt:       record.ExpiresAt,
+		AccessToken:     accessToken,
+		AccessExpiresAt: record.AccessExpiresAt,
+		record:          record,
+	}, nil
+}
+
+// UserForAccessToken returns the active user an unexpired access token was issued to. The
+// token stops working as soon as its refresh token is rotated or revoked.
+func UserForAccessToken(ctx context.Context, accessToken string) (*models.User, error) {
+	user := &models.User{}
+	err := database.DB.NewSelect().
+		Model(user).
+		Where("active = true").
+		Where("id = (SELECT user_id FROM refresh_tokens WHERE access_token_hash = ? AND revoked_at IS NULL AND access_expires_at > ?)",
+			hashRefreshToken(accessToken), time.Now()).
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// refreshTokenState is what presenting a stored refresh token amounts to
+type refreshTokenState int
+
+const (
+	refreshTokenUsable refreshTokenState = iota
+	refreshTokenInvalid
+	refreshTokenReused
+)
+
+// classifyRefreshToken tells a token that can be rotated from an expired or revoked one,
+// and a revoked one that was already rotated (reuse) from one revoked by logout
+func classifyRefreshToken(token *models.RefreshToken, now time.Time) refreshTokenState {
+	if !token.RevokedAt.IsZero() {
+		if token.ReplacedByID != 0 {
+			return refreshTokenReused
+		}
+		return refreshTokenInvalid
+	}
+	if !token.IsActive(now) {
+		return refreshTokenInvalid
+	}
+	return refreshTokenUsable
+}
+
+// RotateRefreshToken exchanges a refresh token for a new one, revoking the one presented,
+// and returns the user it belongs to. The token row is locked for the exchange so a token
+// sent twice at the same time is rotated only once.
+func RotateRefreshToken(ctx context.Context, token string) (*models.User, *IssuedRefreshToken, error) {
+	var (
+		user   *models.User
+		issued *IssuedRefreshToken
+		reused bool
+	)
+
+	err := database.DB.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		current := &models.RefreshToken{}
+		err := tx.NewSelect().
+			Model(current).
+			Where("token_hash = ?", hashRefreshToken(token)).
+			For("UPDATE").
+			Scan(ctx)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInvalidRefreshToken
+		}
+		if err != nil {
+			return fmt.Errorf("failed to load refresh token: %w", err)
+		}
+
+		now := time.Now()
+		switch classifyRefreshToken(current, now) {
+		case refreshTokenReused:
+			// The permanent token is replaced too, as on logout
+			reused = true
+			if err := revokeRefreshTokens(ctx, tx, current.UserID); err != nil {
+				return err
+			}
+			return rotateUserToken(ctx, tx, current.UserID)
+		case refreshTokenInvalid:
+			return ErrInvalidRefreshToken
+		}
+
+		user = &models.User{}
+		err = tx.NewSelect().
+			Model(user).
+			Where("id = ? AND active = true", current.UserID).
+			Scan(ctx)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInvalidRefreshToken
+		}
+		if err != nil {
+			return fmt.Errorf("failed to load user: %w", err)
+		}
+
+		issued, err = IssueRefreshToken(ctx, tx, current.UserID)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.NewUpdate().
+			Model((*models.RefreshToken)(nil)).
+			Set("revoked_at = ?", now).
+			Set("replaced_by_id = ?", issued.record.ID).
+			Where("id = ?", current.ID).
+			Exec(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to revoke refresh token: %w", err)
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, nil, err
+	}
+	// The revocation of a reused token's family has to be committed before reporting it
+	if reused {
+		return nil, nil, ErrRefreshTokenReused
+	}
+	return user, issued, nil
+}
+
+// RevokeUserRefreshTokens revokes every active refresh token of a user
+func RevokeUserRefreshTokens(ctx context.Context, userID int64) error {
+	return revokeRefreshTokens(ctx, database.DB, userID)
+}
+
+func revokeRefreshTokens(ctx context.Context, db bun.IDB, userID int64) error {
+	_, err := db.NewUpdate().
+		Model((*models.RefreshToken)(nil)).
+		Set("revoked_at = ?", time.Now()).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+	return nil
+}
+
+// rotateUserToken replaces the permanent token of a user, ending every session opened with it
+func rotateUserToken(ctx context.Context, db bun.IDB, userID int64) error {
+	token, err := randomToken(16)
+	if err != nil {
+		return fmt.Errorf("failed to generate user token: %w", err)
+	}
+	_, err = db.NewUpdate().
+		Model((*models.User)(nil)).
+		Set("token = ?", token).
+		Set("updated_at = ?", time.Now()).
+		Where("id = ?", userID).
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to rotate user token: %w", err)
+	}
+	return nil
+}
+
+// randomToken returns size random bytes, hex encoded
+func randomToken(size int) (string, error) {
+	raw := make([]byte, size)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(raw), nil
+}
+
+// hashRefreshToken is the form refresh and access tokens are stored and looked up in
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,141 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/database/databasetest"
+	"github.com/zoomxml/internal/models"
+)
+
+func TestClassifyRefreshToken(t *testing.T) {
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		token models.RefreshToken
+		want  refreshTokenState
+	}{
+		{"active", models.RefreshToken{ExpiresAt: now.Add(time.Hour)}, refreshTokenUsable},
+		{"expired", models.RefreshToken{ExpiresAt: now.Add(-time.Second)}, refreshTokenInvalid},
+		{"revoked by logout", models.RefreshToken{ExpiresAt: now.Add(time.Hour), RevokedAt: now.Add(-time.Minute)}, refreshTokenInvalid},
+		{"rotated", models.RefreshToken{ExpiresAt: now.Add(time.Hour), RevokedAt: now.Add(-time.Minute), ReplacedByID: 7}, refreshTokenReused},
+		{"rotated and expired", models.RefreshToken{ExpiresAt: now.Add(-time.Hour), RevokedAt: now.Add(-2 * time.Hour), ReplacedByID: 7}, refreshTokenReused},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifyRefreshToken(&tt.token, now); got != tt.want {
+				t.Errorf("classifyRefreshToken() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRotateRefreshToken(t *testing.T) {
+	databasetest.Require(t)
+	ctx := context.Background()
+	user := databasetest.CreateUser(t, "user")
+
+	first, err := IssueRefreshToken(ctx, database.DB, user.ID)
+	if err != nil {
+		t.Fatalf("IssueRefreshToken() error = %v", err)
+	}
+	if got, err := UserForAccessToken(ctx, first.AccessToken); err != nil || got.ID != user.ID {
+		t.Fatalf("UserForAccessToken(first) = %v, %v; want user %d", got, err, user.ID)
+	}
+
+	rotatedUser, second, err := RotateRefreshToken(ctx, first.Token)
+	if err != nil {
+		t.Fatalf("RotateRefreshToken() error = %v", err)
+	}
+	if rotatedUser.ID != user.ID {
+		t.Errorf("RotateRefreshToken() user = %d, want %d", rotatedUser.ID, user.ID)
+	}
+	if second.Token == first.Token || second.AccessToken == first.AccessToken {
+		t.Error("RotateRefreshToken() returned the tokens it was given")
+	}
+
+	// Rotation ends the access token of the rotated refresh token
+	if _, err := UserForAccessToken(ctx, first.AccessToken); err == nil {
+		t.Error("access token of a rotated refresh token still authenticates")
+	}
+	if got, err := UserForAccessToken(ctx, second.AccessToken); err != nil || got.ID != user.ID {
+		t.Errorf("UserForAccessToken(second) = %v, %v; want user %d", got, err, user.ID)
+	}
+
+	if _, _, err := RotateRefreshToken(ctx, "unknown"); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("RotateRefreshToken(unknown) error = %v, want %v", err, ErrInvalidRefreshToken)
+	}
+}
+
+func TestRotateRefreshTokenReuse(t *testing.T) {
+	databasetest.Require(t)
+	ctx := context.Background()
+	user := databasetest.CreateUser(t, "user")
+
+	first, err := IssueRefreshToken(ctx, database.DB, user.ID)
+	if err != nil {
+		t.Fatalf("IssueRefreshToken() error = %v", err)
+	}
+	_, second, err := RotateRefreshToken(ctx, first.Token)
+	if err != nil {
+		t.Fatalf("RotateRefreshToken() error = %v", err)
+	}
+
+	// Presenting the rotated token again ends every session of the user
+	if _, _, err := RotateRefreshToken(ctx, first.Token); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("RotateRefreshToken(reused) error = %v, want %v", err, ErrRefreshTokenReused)
+	}
+
+	if _, _, err := RotateRefreshToken(ctx, second.Token); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("RotateRefreshToken(second) after reuse error = %v, want %v", err, ErrInvalidRefreshToken)
+	}
+	if _, err := UserForAccessToken(ctx, second.AccessToken); err == nil {
+		t.Error("access token still authenticates after reuse")
+	}
+
+	stored := &models.User{}
+	if err := database.DB.NewSelect().Model(stored).Where("id = ?", user.ID).Scan(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if stored.Token == user.Token {
+		t.Error("user token was not rotated after reuse")
+	}
+}
+
+func TestRevokeUserRefreshTokens(t *testing.T) {
+	databasetest.Require(t)
+	ctx := context.Background()
+	user := databasetest.CreateUser(t, "user")
+	other := databasetest.CreateUser(t, "user")
+
+	issued, err := IssueRefreshToken(ctx, database.DB, user.ID)
+	if err != nil {
+		t.Fatalf("IssueRefreshToken() error = %v", err)
+	}
+	kept, err := IssueRefreshToken(ctx, database.DB, other.ID)
+	if err != nil {
+		t.Fatalf("IssueRefreshToken() error = %v", err)
+	}
+
+	if err := RevokeUserRefreshTokens(ctx, user.ID); err != nil {
+		t.Fatalf("RevokeUserRefreshTokens() error = %v", err)
+	}
+
+	// A revoked token is invalid, not reused: it was never rotated
+	if _, _, err := RotateRefreshToken(ctx, issued.Token); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("RotateRefreshToken(revoked) error = %v, want %v", err, ErrInvalidRefreshToken)
+	}
+	if _, err := UserForAccessToken(ctx, issued.AccessToken); err == nil {
+		t.Error("access token of a revoked refresh token still authenticates")
+	}
+
+	// Tokens of other users are left alone
+	if got, err := UserForAccessToken(ctx, kept.AccessToken); err != nil || got.ID != other.ID {
+		t.Errorf("UserForAccessToken(other) = %v, %v; want user %d", got, err, other.ID)
+	}
+}
@@ -0,0 +1,278 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/zoomxml/config"
+	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/logger"
+	"github.com/zoomxml/internal/models"
+)
+
+// registrationRefreshTick is how often the refresher looks for companies whose
+// registration data is older than COMPANY_REGISTRATION_REFRESH_INTERVAL. Checking due
+// companies on a short tick keeps the weekly cadence across restarts.
+const registrationRefreshTick = time.Hour
+
+// registrationRetryBase is the wait before retrying a company whose CNPJ lookup failed; it
+// doubles on each consecutive failure, up to the refresh interval
+const registrationRetryBase = time.Hour
+
+// registrationStatusActive is the situação cadastral of a company in good standing
+const registrationStatusActive = "ativa"
+
+// RegistrationChange is the outcome of refreshing the registration data of one company
+type RegistrationChange struct {
+	CompanyID      int64  `json:"company_id"`
+	PreviousStatus string `json:"previous_status"`
+	Status         string `json:"status"`
+	Changed        bool   `json:"changed"`
+	BecameInactive bool   `json:"became_inactive"`
+	AutoSyncPaused bool   `json:"auto_sync_paused"`
+	UpdatedFields  int    `json:"updated_fields"`
+}
+
+// IsRegistrationActive reports whether a situação cadastral means the company is active.
+// An empty status (unknown) is treated as active so missing data never pauses a company.
+func IsRegistrationActive(status string) bool {
+	status = strings.TrimSpace(status)
+	return status == "" || strings.EqualFold(status, registrationStatusActive)
+}
+
+// applyRegistrationData compares fresh CNPJ data with the company and returns the columns
+// to update. Only registration fields are refreshed; address and contact data edited by
+// users are left alone.
+func applyRegistrationData(company *models.Company, data *CNPJData, pauseAutoSync bool) (map[string]any, RegistrationChange) {
+	change := RegistrationChange{
+		CompanyID:      company.ID,
+		PreviousStatus: company.RegistrationStatus,
+		Status:         data.RegistrationStatus,
+	}
+
+	columns := map[string]any{}
+	setIfChanged := func(column, current, fresh string) {
+		if fresh != "" && fresh != current {
+			columns[column] = fresh
+		}
+	}
+
+	setIfChanged("registration_status", company.RegistrationStatus, data.RegistrationStatus)
+	setIfChanged("company_size", company.CompanySize, data.CompanySize)
+	setIfChanged("legal_nature", company.LegalNature, data.LegalNature)
+	setIfChanged("main_activity", company.MainActivity, data.MainActivity)
+	change.UpdatedFields = len(columns)
+	change.Changed = change.UpdatedFields > 0
+
+	inactive := !IsRegistrationActive(data.RegistrationStatus)
+	if inactive != company.RegistrationInactive {
+		columns["registration_inactive"] = inactive
+	}
+	change.BecameInactive = inactive && !company.RegistrationInactive
+
+	return columns, change
+}
+
+// RefreshCompanyRegistration re-queries the CNPJ of a company and stores what changed. A
+// failed lookup is recorded too, so the company is retried with backoff instead of on
+// every tick.
+func RefreshCompanyRegistration(ctx context.Context, cnpjService *CNPJService, company *models.Company, pauseAutoSync bool) (*RegistrationChange, error) {
+	data, err := cnpjService.ConsultarCNPJ(ctx, company.CNPJ)
+	if err != nil {
+		if ctx.Err() == nil {
+			_, _ = database.DB.NewUpdate().
+				Model((*models.Company)(nil)).
+				Set("registration_checked_at = current_timestamp").
+				Set("registration_failures = registration_failures + 1").
+				Where("id = ?", company.ID).
+				Exec(ctx)
+		}
+		return nil, fmt.Errorf("failed to query CNPJ: %w", err)
+	}
+
+	columns, change := applyRegistrationData(company, data, pauseAutoSync)
+
+	query := database.DB.NewUpdate().
+		Model((*models.Company)(nil)).
+		Set("registration_checked_at = current_timestamp").
+		Set("registration_failures = 0").
+		Where("id = ?", company.ID)
+	for column, value := range columns {
+		query = query.Set("? = ?", bun.Ident(column), value)
+	}
+	if len(columns) > 0 {
+		query = query.Set("updated_at = current_timestamp")
+	}
+
+	if _, err := query.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to update company: %w", err)
+	}
+
+	// The pause checks auto_fetch in the update itself, since it may have changed since the
+	// company was loaded
+	if change.BecameInactive && pauseAutoSync {
+		res, err := database.DB.NewUpdate().
+			Model((*models.Company)(nil)).
+			Set("auto_fetch = false").
+			Set("updated_at = current_timestamp").
+			Where("id = ? AND auto_fetch = true", company.ID).
+			Exec(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to pause auto sync: %w", err)
+		}
+		if rows, _ := res.RowsAffected(); rows > 0 {
+			change.AutoSyncPaused = true
+		}
+	}
+
+	if change.BecameInactive {
+		logger.WarnWithFields("Company registration is no longer active", map[string]any{
+			"operation":        "refresh_registration",
+			"company_id":       company.ID,
+			"status":           change.Status,
+			"auto_sync_paused": change.AutoSyncPaused,
+		})
+	}
+
+	return &change, nil
+}
+
+// RegistrationRefresher periodically refreshes the registration data of active companies
+type RegistrationRefresher struct {
+	cnpjService *CNPJService
+	ticker      *time.Ticker
+	stopChan    chan bool
+	cancel      context.CancelFunc
+	running     bool
+	config      *config.Config
+}
+
+// NewRegistrationRefresher creates a new registration refresher
+func NewRegistrationRefresher() *RegistrationRefresher {
+	return &RegistrationRefresher{
+		cnpjService: NewCNPJService(SharedTransport()),
+		stopChan:    make(chan bool),
+		config:      config.Get(),
+	}
+}
+
+// Start begins refreshing companies whose data is older than COMPANY_REGISTRATION_REFRESH_INTERVAL
+func (r *RegistrationRefresher) Start() {
+	if !r.config.Company.RegistrationRefreshEnabled || r.running {
+		return
+	}
+
+	r.ticker = time.NewTicker(registrationRefreshTick)
+	r.running = true
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+
+	logger.InfoWithFields("Starting registration refresher", map[string]any{
+		"operation": "start_registration_refresher",
+		"interval":  r.interval().String(),
+	})
+
+	go r.run(ctx)
+}
+
+// Stop stops the refresher, interrupting a refresh in progress
+func (r *RegistrationRefresher) Stop() {
+	if !r.running {
+		return
+	}
+
+	r.cancel()
+	r.stopChan <- true
+	r.ticker.Stop()
+	r.running = false
+}
+
+// run is the refresher loop
+func (r *RegistrationRefresher) run(ctx context.Context) {
+	for {
+		select {
+		case <-r.ticker.C:
+			if err := r.RefreshDueCompanies(ctx); err != nil {
+				logger.ErrorWithFields("Registration refresh failed", err, map[string]any{
+					"operation": "refresh_registration",
+				})
+			}
+		case <-r.stopChan:
+			return
+		}
+	}
+}
+
+// interval returns the configured refresh interval, defaulting to a week
+func (r *RegistrationRefresher) interval() time.Duration {
+	if interval := r.config.Company.RegistrationRefreshInterval; interval > 0 {
+		return interval
+	}
+	return 7 * 24 * time.Hour
+}
+
+// RefreshDueCompanies refreshes active companies not checked within the interval, one
+// CNPJ lookup at a time with COMPANY_REGISTRATION_REFRESH_DELAY between them. Companies
+// whose last lookup failed are retried after registrationRetryBase, doubled per failure.
+func (r *RegistrationRefresher) RefreshDueCompanies(ctx context.Context) error {
+	now := time.Now()
+	companies := []models.Company{}
+	err := database.DB.NewSelect().
+		Model(&companies).
+		Where("active = true").
+		WhereGroup(" AND ", func(q *bun.SelectQuery) *bun.SelectQuery {
+			return q.
+				Where("registration_checked_at IS NULL OR registration_checked_at < ?", now.Add(-r.interval())).
+				WhereOr("registration_failures > 0 AND registration_checked_at + make_interval(secs => LEAST(? * power(2, LEAST(registration_failures - 1, 16)), ?)) < ?",
+					registrationRetryBase.Seconds(), r.interval().Seconds(), now)
+		}).
+		Order("registration_checked_at ASC NULLS FIRST", "id ASC").
+		Scan(ctx)
+
+	if err != nil {
+		return fmt.Errorf("failed to load companies: %w", err)
+	}
+
+	changed, inactive := 0, 0
+	for i := range companies {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(r.config.Company.RegistrationRefreshDelay):
+			}
+		}
+
+		change, err := RefreshCompanyRegistration(ctx, r.cnpjService, &companies[i], r.config.Company.RegistrationPauseAutoSync)
+		if err != nil {
+			logger.WarnWithFields("Failed to refresh company registration", map[string]any{
+				"operation":  "refresh_registration",
+				"company_id": companies[i].ID,
+				"error":      err.Error(),
+			})
+			continue
+		}
+
+		if change.Changed {
+			changed++
+		}
+		if change.BecameInactive {
+			inactive++
+		}
+	}
+
+	if len(companies) > 0 {
+		logger.InfoWithFields("Company registrations refreshed", map[string]any{
+			"operation":       "refresh_registration",
+			"companies":       len(companies),
+			"changed":         changed,
+			"became_inactive": inactive,
+		})
+	}
+
+	return nil
+}
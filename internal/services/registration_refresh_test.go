@@ -0,0 +1,189 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/database/databasetest"
+	"github.com/zoomxml/internal/models"
+)
+
+func TestIsRegistrationActive(t *testing.T) {
+	tests := []struct {
+		status string
+		want   bool
+	}{
+		{"Ativa", true},
+		{" ATIVA ", true},
+		{"", true},
+		{"Baixada", false},
+		{"Suspensa", false},
+		{"Inapta", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsRegistrationActive(tt.status); got != tt.want {
+			t.Errorf("IsRegistrationActive(%q) = %v, want %v", tt.status, got, tt.want)
+		}
+	}
+}
+
+func TestApplyRegistrationData(t *testing.T) {
+	active := models.Company{ID: 1, RegistrationStatus: "Ativa", CompanySize: "ME", LegalNature: "Sociedade Limitada"}
+	inactive := models.Company{ID: 1, RegistrationStatus: "Baixada", RegistrationInactive: true}
+
+	tests := []struct {
+		name               string
+		company            models.Company
+		data               CNPJData
+		wantColumns        map[string]any
+		wantChanged        bool
+		wantBecameInactive bool
+	}{
+		{
+			name:        "unchanged",
+			company:     active,
+			data:        CNPJData{RegistrationStatus: "Ativa", CompanySize: "ME"},
+			wantColumns: map[string]any{},
+		},
+		{
+			name:               "closed down",
+			company:            active,
+			data:               CNPJData{RegistrationStatus: "Baixada"},
+			wantColumns:        map[string]any{"registration_status": "Baixada", "registration_inactive": true},
+			wantChanged:        true,
+			wantBecameInactive: true,
+		},
+		{
+			name:        "still inactive",
+			company:     inactive,
+			data:        CNPJData{RegistrationStatus: "Baixada"},
+			wantColumns: map[string]any{},
+		},
+		{
+			name:        "active again",
+			company:     inactive,
+			data:        CNPJData{RegistrationStatus: "Ativa"},
+			wantColumns: map[string]any{"registration_status": "Ativa", "registration_inactive": false},
+			wantChanged: true,
+		},
+		{
+			name:        "registration fields only",
+			company:     active,
+			data:        CNPJData{RegistrationStatus: "Ativa", CompanySize: "EPP", Phone: "(99) 3524-0000"},
+			wantColumns: map[string]any{"company_size": "EPP"},
+			wantChanged: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			columns, change := applyRegistrationData(&tt.company, &tt.data, true)
+			if fmt.Sprint(columns) != fmt.Sprint(tt.wantColumns) {
+				t.Errorf("columns = %v, want %v", columns, tt.wantColumns)
+			}
+			if change.Changed != tt.wantChanged || change.BecameInactive != tt.wantBecameInactive {
+				t.Errorf("change = %+v, want changed %v, became inactive %v", change, tt.wantChanged, tt.wantBecameInactive)
+			}
+		})
+	}
+}
+
+// testValidCNPJ returns a CNPJ with valid check digits for the first 12 digits of n
+func testValidCNPJ(n int64) string {
+	cnpj := fmt.Sprintf("%012d", n%1000000000000)
+	for _, length := range []int{12, 13} {
+		sum, weight := 0, length-7
+		for i := 0; i < length; i++ {
+			sum += int(cnpj[i]-'0') * weight
+			weight--
+			if weight < 2 {
+				weight = 9
+			}
+		}
+		digit := 0
+		if r := sum % 11; r >= 2 {
+			digit = 11 - r
+		}
+		cnpj += fmt.Sprint(digit)
+	}
+	return cnpj
+}
+
+func TestRefreshCompanyRegistration(t *testing.T) {
+	databasetest.Require(t)
+	ctx := context.Background()
+	service := NewCNPJService(SharedTransport())
+
+	tests := []struct {
+		name          string
+		status        string
+		pause         bool
+		wantInactive  bool
+		wantAutoFetch bool
+	}{
+		{"closed down and paused", "Baixada", true, true, false},
+		{"closed down without pausing", "Baixada", false, true, true},
+		{"still active", "Ativa", true, false, true},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cnpj := testValidCNPJ(time.Now().UnixNano() + int64(i))
+			company := databasetest.CreateCompany(t, func(c *models.Company) {
+				c.CNPJ, c.AutoFetch, c.RegistrationStatus = cnpj, true, "Ativa"
+			})
+			// The cached lookup stands in for the CNPJ API
+			storeCNPJ(cnpj, &CNPJData{CNPJ: cnpj, RegistrationStatus: tt.status})
+
+			change, err := RefreshCompanyRegistration(ctx, service, company, tt.pause)
+			if err != nil {
+				t.Fatalf("RefreshCompanyRegistration() error = %v", err)
+			}
+			if change.BecameInactive != tt.wantInactive || change.AutoSyncPaused != (tt.wantInactive && tt.pause) {
+				t.Errorf("change = %+v, want became inactive %v", change, tt.wantInactive)
+			}
+
+			stored := &models.Company{}
+			if err := database.DB.NewSelect().Model(stored).Where("id = ?", company.ID).Scan(ctx); err != nil {
+				t.Fatal(err)
+			}
+			if stored.RegistrationStatus != tt.status || stored.RegistrationInactive != tt.wantInactive || stored.AutoFetch != tt.wantAutoFetch {
+				t.Errorf("company = status %q inactive %v auto_fetch %v, want %q %v %v",
+					stored.RegistrationStatus, stored.RegistrationInactive, stored.AutoFetch, tt.status, tt.wantInactive, tt.wantAutoFetch)
+			}
+			if stored.RegistrationCheckedAt.IsZero() || stored.RegistrationFailures != 0 {
+				t.Errorf("registration checked at %v with %d failures, want checked without failures",
+					stored.RegistrationCheckedAt, stored.RegistrationFailures)
+			}
+		})
+	}
+}
+
+func TestRefreshCompanyRegistrationFailure(t *testing.T) {
+	databasetest.Require(t)
+	ctx := context.Background()
+
+	// A CNPJ with wrong check digits fails before reaching the API
+	valid := testValidCNPJ(time.Now().UnixNano())
+	invalid := valid[:13] + fmt.Sprint((valid[13]-'0'+1)%10)
+	company := databasetest.CreateCompany(t, func(c *models.Company) { c.CNPJ, c.AutoFetch = invalid, true })
+
+	for range 2 {
+		if _, err := RefreshCompanyRegistration(ctx, NewCNPJService(SharedTransport()), company, true); err == nil {
+			t.Fatal("RefreshCompanyRegistration() error = nil, want the lookup failure")
+		}
+	}
+
+	stored := &models.Company{}
+	if err := database.DB.NewSelect().Model(stored).Where("id = ?", company.ID).Scan(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if stored.RegistrationFailures != 2 || stored.RegistrationCheckedAt.IsZero() || !stored.AutoFetch {
+		t.Errorf("company = %d failures, checked at %v, auto_fetch %v, want 2 failures recorded and auto sync kept",
+			stored.RegistrationFailures, stored.RegistrationCheckedAt, stored.AutoFetch)
+	}
+}
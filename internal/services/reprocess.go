@@ -0,0 +1,294 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/zoomxml/config"
+	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/logger"
+	"github.com/zoomxml/internal/models"
+	"github.com/zoomxml/internal/repository"
+)
+
+// reprocessChunkSize is the number of documents a batch advances on each run
+const reprocessChunkSize = 100
+
+// activeReprocessStatuses are the statuses of a batch that still has documents to go
+var activeReprocessStatuses = []string{models.ReprocessStatusPending, models.ReprocessStatusRunning}
+
+// StartReprocess creates a batch that re-parses every document of a company from
+// its stored XML. It is idempotent: while the company has an unfinished batch, that
+// batch is returned instead and created is false.
+func StartReprocess(ctx context.Context, companyID, userID int64) (batch *models.ReprocessBatch, created bool, err error) {
+	if active, err := activeReprocessBatch(ctx, companyID); err != nil || active != nil {
+		return active, false, err
+	}
+
+	total, err := database.DB.NewSelect().
+		Model((*models.Document)(nil)).
+		Where("company_id = ?", companyID).
+		Count(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to count documents: %w", err)
+	}
+
+	batch = &models.ReprocessBatch{
+		CompanyID:   companyID,
+		RequestedBy: userID,
+		Status:      models.ReprocessStatusPending,
+		Total:       total,
+	}
+
+	// The partial unique index allows a single unfinished batch per company, so a
+	// concurrent request that won the race is returned instead
+	res, err := database.DB.NewInsert().
+		Model(batch).
+		On("CONFLICT DO NOTHING").
+		Exec(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create reprocess batch: %w", err)
+	}
+
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		active, err := activeReprocessBatch(ctx, companyID)
+		return active, false, err
+	}
+
+	return batch, true, nil
+}
+
+// GetReprocessBatch loads a reprocess batch of a company
+func GetReprocessBatch(ctx context.Context, companyID, batchID int64) (*models.ReprocessBatch, error) {
+	batch := &models.ReprocessBatch{}
+	err := database.DB.NewSelect().
+		Model(batch).
+		Where("id = ? AND company_id = ?", batchID, companyID).
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return batch, nil
+}
+
+// activeReprocessBatch returns the unfinished batch of a company, or nil
+func activeReprocessBatch(ctx context.Context, companyID int64) (*models.ReprocessBatch, error) {
+	batch := &models.ReprocessBatch{}
+	err := database.DB.NewSelect().
+		Model(batch).
+		Where("company_id = ?", companyID).
+		Where("status IN (?)", bun.In(activeReprocessStatuses)).
+		Limit(1).
+		Scan(ctx)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load reprocess batch: %w", err)
+	}
+	return batch, nil
+}
+
+// ReprocessWorker advances unfinished reprocess batches in the background. Progress is
+// saved after every chunk, so batches resume where they stopped after a restart.
+type ReprocessWorker struct {
+	parser    *NFSeParser
+	documents repository.DocumentRepository
+	companies repository.CompanyRepository
+	ticker    *time.Ticker
+	stopChan  chan bool
+	running   bool
+	config    *config.Config
+}
+
+// NewReprocessWorker creates a new reprocess worker
+func NewReprocessWorker() *ReprocessWorker {
+	return &ReprocessWorker{
+		parser:    NewNFSeParser(),
+		documents: repository.NewDocumentRepository(),
+		companies: repository.NewCompanyRepository(),
+		stopChan:  make(chan bool),
+		config:    config.Get(),
+	}
+}
+
+// Start begins advancing batches every NFSE_REPROCESS_INTERVAL
+func (w *ReprocessWorker) Start() {
+	if w.running {
+		return
+	}
+
+	interval := w.config.NFSeScheduler.ReprocessInterval
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+
+	w.ticker = time.NewTicker(interval)
+	w.running = true
+
+	logger.InfoWithFields("Starting reprocess worker", map[string]any{
+		"operation": "start_reprocess_worker",
+		"interval":  interval.String(),
+	})
+
+	go w.run()
+}
+
+// Stop stops the worker
+func (w *ReprocessWorker) Stop() {
+	if !w.running {
+		return
+	}
+
+	w.stopChan <- true
+	w.ticker.Stop()
+	w.running = false
+}
+
+// run is the worker loop
+func (w *ReprocessWorker) run() {
+	for {
+		select {
+		case <-w.ticker.C:
+			w.ProcessPendingBatches(context.Background())
+		case <-w.stopChan:
+			return
+		}
+	}
+}
+
+// ProcessPendingBatches advances every unfinished batch by one chunk, on up to
+// JOB_WORKER_CONCURRENCY batches at a time. Batches advanced by another instance are skipped.
+func (w *ReprocessWorker) ProcessPendingBatches(ctx context.Context) {
+	err := runClaimedJobs(ctx, w.config.App.JobWorkerConcurrency, activeReprocessStatuses, w.advance,
+		func(batch *models.ReprocessBatch, err error) {
+			logger.ErrorWithFields("Failed to advance reprocess batch", err, map[string]any{
+				"operation":  "reprocess_documents",
+				"batch_id":   batch.ID,
+				"company_id": batch.CompanyID,
+			})
+		})
+
+	if err != nil {
+		logger.ErrorWithFields("Failed to claim reprocess batches", err, map[string]any{
+			"operation": "reprocess_documents",
+		})
+	}
+}
+
+// advance re-parses the next chunk of documents of a batch and saves its progress
+func (w *ReprocessWorker) advance(ctx context.Context, batch *models.ReprocessBatch) error {
+	documents := []models.Document{}
+	err := database.DB.NewSelect().
+		Model(&documents).
+		Where("company_id = ?", batch.CompanyID).
+		Where("id > ?", batch.LastDocumentID).
+		Order("id ASC").
+		Limit(reprocessChunkSize).
+		Scan(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load documents: %w", err)
+	}
+
+	policy := reprocessPolicy(companyIngestPolicy(ctx, w.companies, batch.CompanyID))
+
+	for i := range documents {
+		if err := w.reprocessDocument(ctx, &documents[i], policy); err != nil {
+			batch.Failed++
+			batch.LastError = fmt.Sprintf("document %d: %v", documents[i].ID, err)
+		}
+		batch.Processed++
+		batch.LastDocumentID = documents[i].ID
+	}
+
+	batch.Status = models.ReprocessStatusRunning
+	if len(documents) < reprocessChunkSize {
+		batch.Status = models.ReprocessStatusCompleted
+		batch.FinishedAt = time.Now()
+	}
+	// Documents stored after the batch started are reprocessed too
+	if batch.Processed > batch.Total {
+		batch.Total = batch.Processed
+	}
+
+	res, err := database.DB.NewUpdate().
+		Model(batch).
+		Column("status", "total", "processed", "failed", "last_document_id", "last_error", "finished_at", "updated_at").
+		WherePK().
+		Where("locked_until = ?", batch.LockedUntil).
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to save progress: %w", err)
+	}
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		return errJobLeaseLost
+	}
+
+	if batch.Status == models.ReprocessStatusCompleted {
+		logger.InfoWithFields("Reprocess batch completed", map[string]any{
+			"operation":  "reprocess_documents",
+			"batch_id":   batch.ID,
+			"company_id": batch.CompanyID,
+			"processed":  batch.Processed,
+			"failed":     batch.Failed,
+		})
+	}
+
+	return nil
+}
+
+// reprocessDocument re-parses the XML kept in the metadata column and rewrites the
+// parsed fields. Status, storage key, original file name and user fields (tags, legal
+// hold) are kept.
+func (w *ReprocessWorker) reprocessDocument(ctx context.Context, existing *models.Document, policy IngestPolicy) error {
+	if existing.Metadata == "" {
+		return errors.New("no stored XML")
+	}
+
+	parsedData, err := w.parser.ParseDocumentXML(existing.Metadata)
+	if err != nil {
+		return err
+	}
+	applyCompetenceFallback(parsedData, existing.Competence)
+
+	if err := w.parser.Validate(parsedData, policy); err != nil {
+		return err
+	}
+
+	document := w.parser.ConvertToDocument(existing.CompanyID, parsedData, existing.StorageKey)
+	document.ID = existing.ID
+	document.Status = existing.Status
+	document.OriginalFileName = existing.OriginalFileName
+	if existing.ContentHash != "" {
+		// The metadata column holds the XML after encoding conversion, so its hash
+		// would no longer match the bytes originally received
+		document.ContentHash = existing.ContentHash
+	}
+
+	updated, err := w.documents.UpdateDocumentIfUnchanged(ctx, document, existing.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	if !updated {
+		return ErrConcurrentModification
+	}
+	return nil
+}
+
+// reprocessPolicy turns rejections into flags and skips the company validation rules:
+// reprocessing never drops documents that were already accepted
+func reprocessPolicy(policy IngestPolicy) IngestPolicy {
+	policy.Validators = nil
+	if policy.ZeroValue == models.ZeroValuePolicyReject {
+		policy.ZeroValue = models.ZeroValuePolicyFlag
+	}
+	if policy.CNPJMatch == models.CNPJMatchPolicyReject {
+		policy.CNPJMatch = models.CNPJMatchPolicyFlag
+	}
+	return policy
+}
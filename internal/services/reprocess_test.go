@@ -0,0 +1,86 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/zoomxml/internal/database/databasetest"
+	"github.com/zoomxml/internal/models"
+)
+
+func TestReprocessPolicy(t *testing.T) {
+	validators, err := BuildDocumentValidators(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy := reprocessPolicy(IngestPolicy{
+		ZeroValue:  models.ZeroValuePolicyReject,
+		CNPJMatch:  models.CNPJMatchPolicyReject,
+		Validators: validators,
+	})
+
+	if policy.ZeroValue != models.ZeroValuePolicyFlag || policy.CNPJMatch != models.CNPJMatchPolicyFlag {
+		t.Errorf("reprocessPolicy() = %s/%s, want rejections turned into flags", policy.ZeroValue, policy.CNPJMatch)
+	}
+	if policy.Validators != nil {
+		t.Error("reprocessPolicy() kept the company validation rules")
+	}
+}
+
+func TestReprocessBatch(t *testing.T) {
+	databasetest.Require(t)
+	ctx := context.Background()
+	company := databasetest.CreateCompany(t, nil)
+	user := databasetest.CreateUser(t, "admin")
+
+	// One chunk and one more document, so the batch takes two runs
+	total := reprocessChunkSize + 1
+	for i := 1; i < total; i++ {
+		xml := testNFSeXML(fmt.Sprint(i), fmt.Sprintf("CODE%d", i), company.CNPJ, "12345678000190", "100.00")
+		databasetest.CreateDocument(t, &models.Document{CompanyID: company.ID, Number: fmt.Sprint(i), Metadata: xml})
+	}
+	// Without stored XML the last document cannot be reprocessed
+	databasetest.CreateDocument(t, &models.Document{CompanyID: company.ID, Number: fmt.Sprint(total)})
+
+	batch, created, err := StartReprocess(ctx, company.ID, user.ID)
+	if err != nil || !created {
+		t.Fatalf("StartReprocess() = %v, %v, want a new batch", created, err)
+	}
+	if batch.Total != total || batch.Status != models.ReprocessStatusPending {
+		t.Errorf("batch = %s of %d documents, want pending of %d", batch.Status, batch.Total, total)
+	}
+
+	// While it is unfinished, starting again returns the same batch
+	again, created, err := StartReprocess(ctx, company.ID, user.ID)
+	if err != nil || created || again.ID != batch.ID {
+		t.Fatalf("StartReprocess() again = batch %d created %v, %v, want batch %d", again.ID, created, err, batch.ID)
+	}
+
+	worker := NewReprocessWorker()
+	steps := []struct {
+		status    string
+		processed int
+		failed    int
+	}{
+		{models.ReprocessStatusRunning, reprocessChunkSize, 0},
+		{models.ReprocessStatusCompleted, total, 1},
+	}
+	for i, step := range steps {
+		worker.ProcessPendingBatches(ctx)
+
+		progress, err := GetReprocessBatch(ctx, company.ID, batch.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if progress.Status != step.status || progress.Processed != step.processed || progress.Failed != step.failed {
+			t.Errorf("run %d: batch = %s processed %d failed %d, want %s processed %d failed %d",
+				i+1, progress.Status, progress.Processed, progress.Failed, step.status, step.processed, step.failed)
+		}
+	}
+
+	// A finished batch does not block a new one
+	if _, created, err := StartReprocess(ctx, company.ID, user.ID); err != nil || !created {
+		t.Errorf("StartReprocess() after completion = %v, %v, want a new batch", created, err)
+	}
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/zoomxml/config"
+	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/logger"
+	"github.com/zoomxml/internal/models"
+)
+
+// RetentionResult is the outcome of applying the retention policy of one company
+type RetentionResult struct {
+	CompanyID        int64     `json:"company_id"`
+	RetentionMonths  int       `json:"retention_months"`
+	Cutoff           time.Time `json:"cutoff"`
+	ExpiredDocuments int       `json:"expired_documents"`
+}
+
+// retentionCutoff returns the instant before which documents expire
+func retentionCutoff(now time.Time, months int) time.Time {
+	return now.AddDate(0, -months, 0)
+}
+
+// ApplyRetentionPolicy soft-deletes the documents of a company issued (or, without issue
+// date, created) before its retention period. Companies without a retention period or
+// under legal hold are skipped, and so are documents under legal hold. Notes without an
+// issue date are stored with the zero date, which counts as no issue date. Expired
+// notes stay visible to deduplication, so a later fetch does not ingest them again.
+func ApplyRetentionPolicy(ctx context.Context, company *models.Company, now time.Time) (*RetentionResult, error) {
+	result := &RetentionResult{
+		CompanyID:       company.ID,
+		RetentionMonths: company.RetentionMonths,
+	}
+
+	if company.RetentionMonths <= 0 || company.LegalHold {
+		return result, nil
+	}
+
+	result.Cutoff = retentionCutoff(now, company.RetentionMonths)
+
+	var keys []string
+	_, err := database.DB.NewDelete().
+		Model((*models.Document)(nil)).
+		Where("company_id = ?", company.ID).
+		Where("legal_hold = false").
+		Where("COALESCE(NULLIF(issue_date, '0001-01-01'), created_at) < ?", result.Cutoff).
+		Returning("COALESCE(storage_key, '')").
+		Exec(ctx, &keys)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to expire documents: %w", err)
+	}
+
+	result.ExpiredDocuments = len(keys)
+	// The XML stays for deduplication; only its rendered PDFs go
+	for _, key := range keys {
+		deleteDANFSEs(ctx, key, "")
+	}
+
+	if result.ExpiredDocuments > 0 {
+		logger.InfoWithFields("Expired documents past retention", map[string]any{
+			"operation":        "apply_retention",
+			"company_id":       company.ID,
+			"retention_months": company.RetentionMonths,
+			"cutoff":           result.Cutoff.Format(time.DateOnly),
+			"expired":          result.ExpiredDocuments,
+		})
+	}
+
+	return result, nil
+}
+
+// ApplyRetentionPolicies applies the retention policy of every company that has one
+func ApplyRetentionPolicies(ctx context.Context) error {
+	companies := []models.Company{}
+	err := database.DB.NewSelect().
+		Model(&companies).
+		Column("id", "retention_months", "legal_hold").
+		Where("retention_months > 0 AND legal_hold = false").
+		Scan(ctx)
+
+	if err != nil {
+		return fmt.Errorf("failed to load retention policies: %w", err)
+	}
+
+	now := time.Now()
+	for i := range companies {
+		if _, err := ApplyRetentionPolicy(ctx, &companies[i], now); err != nil {
+			logger.ErrorWithFields("Failed to apply retention policy", err, map[string]any{
+				"operation":  "apply_retention",
+				"company_id": companies[i].ID,
+			})
+		}
+	}
+
+	return nil
+}
+
+// RetentionScheduler periodically enforces the retention policies
+type RetentionScheduler struct {
+	ticker   *time.Ticker
+	stopChan chan bool
+	running  bool
+	config   *config.Config
+}
+
+// NewRetentionScheduler creates a new retention scheduler
+func NewRetentionScheduler() *RetentionScheduler {
+	return &RetentionScheduler{
+		stopChan: make(chan bool),
+		config:   config.Get(),
+	}
+}
+
+// Start begins enforcing the retention policies every RETENTION_CLEANUP_INTERVAL
+func (r *RetentionScheduler) Start() {
+	if !r.config.Storage.RetentionCleanupEnabled || r.running {
+		return
+	}
+
+	interval := r.config.Storage.RetentionCleanupInterval
+	if interval <= 0 {
+		interval = 24 * time.Hour
+	}
+
+	r.ticker = time.NewTicker(interval)
+	r.running = true
+
+	logger.InfoWithFields("Starting retention scheduler", map[string]any{
+		"operation": "start_retention_scheduler",
+		"interval":  interval.String(),
+	})
+
+	go r.run()
+}
+
+// Stop stops the scheduler
+func (r *RetentionScheduler) Stop() {
+	if !r.running {
+		return
+	}
+
+	r.stopChan <- true
+	r.ticker.Stop()
+	r.running = false
+}
+
+// run is the scheduler loop
+func (r *RetentionScheduler) run() {
+	for {
+		select {
+		case <-r.ticker.C:
+			if err := ApplyRetentionPolicies(context.Background()); err != nil {
+				logger.ErrorWithFields("Retention cleanup failed", err, map[string]any{
+					"operation": "apply_retention",
+				})
+			}
+		case <-r.stopChan:
+			return
+		}
+	}
+}
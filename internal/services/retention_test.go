@@ -0,0 +1,116 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/database/databasetest"
+	"github.com/zoomxml/internal/models"
+)
+
+func TestRetentionCutoff(t *testing.T) {
+	now := time.Date(2025, 6, 15, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		months int
+		want   time.Time
+	}{
+		{1, time.Date(2025, 5, 15, 10, 0, 0, 0, time.UTC)},
+		{6, time.Date(2024, 12, 15, 10, 0, 0, 0, time.UTC)},
+		{60, time.Date(2020, 6, 15, 10, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		if got := retentionCutoff(now, tt.months); !got.Equal(tt.want) {
+			t.Errorf("retentionCutoff(%d) = %v, want %v", tt.months, got, tt.want)
+		}
+	}
+}
+
+// TestApplyRetentionPolicySkipsWithoutQuery checks that companies without a retention
+// period or under legal hold are skipped before the database is queried
+func TestApplyRetentionPolicySkipsWithoutQuery(t *testing.T) {
+	databasetest.UseClosed(t)
+
+	tests := []struct {
+		name    string
+		company *models.Company
+	}{
+		{"no retention period", &models.Company{ID: 1}},
+		{"company under legal hold", &models.Company{ID: 1, RetentionMonths: 12, LegalHold: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ApplyRetentionPolicy(context.Background(), tt.company, time.Now())
+			if err != nil {
+				t.Fatalf("ApplyRetentionPolicy() error = %v", err)
+			}
+			if result.ExpiredDocuments != 0 || !result.Cutoff.IsZero() {
+				t.Errorf("ApplyRetentionPolicy() = %+v, want nothing expired", result)
+			}
+		})
+	}
+}
+
+func TestApplyRetentionPolicy(t *testing.T) {
+	databasetest.Require(t)
+	ctx := context.Background()
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	company := databasetest.CreateCompany(t, nil)
+	old := databasetest.CreateDocument(t, &models.Document{CompanyID: company.ID, IssueDate: now.AddDate(-2, 0, 0)})
+	held := databasetest.CreateDocument(t, &models.Document{CompanyID: company.ID, IssueDate: now.AddDate(-2, 0, 0), LegalHold: true})
+	recent := databasetest.CreateDocument(t, &models.Document{CompanyID: company.ID, IssueDate: now.AddDate(0, -1, 0)})
+	// Without an issue date the note is as old as its row
+	undated := databasetest.CreateDocument(t, &models.Document{CompanyID: company.ID})
+	if _, err := database.DB.NewUpdate().Model(undated).Set("issue_date = '0001-01-01'").WherePK().Exec(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	expired := func(document *models.Document) bool {
+		t.Helper()
+		stored := &models.Document{}
+		err := database.DB.NewSelect().Model(stored).Column("deleted_at").Where("d.id = ?", document.ID).WhereAllWithDeleted().Scan(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return !stored.DeletedAt.IsZero()
+	}
+
+	// Each step changes the policy of the company and applies it
+	steps := []struct {
+		name        string
+		months      int
+		legalHold   bool
+		wantExpired int
+	}{
+		{"retention longer than the documents", 36, false, 0},
+		{"company under legal hold", 12, true, 0},
+		{"retention shortened", 12, false, 1},
+		{"applied again", 12, false, 0},
+	}
+
+	for _, step := range steps {
+		company.RetentionMonths = step.months
+		company.LegalHold = step.legalHold
+		result, err := ApplyRetentionPolicy(ctx, company, now)
+		if err != nil {
+			t.Fatalf("%s: ApplyRetentionPolicy() error = %v", step.name, err)
+		}
+		if result.ExpiredDocuments != step.wantExpired {
+			t.Errorf("%s: expired %d documents, want %d", step.name, result.ExpiredDocuments, step.wantExpired)
+		}
+	}
+
+	if !expired(old) {
+		t.Error("document past retention was not expired")
+	}
+	for name, document := range map[string]*models.Document{"under legal hold": held, "recent": recent, "without issue date": undated} {
+		if expired(document) {
+			t.Errorf("document %s was expired", name)
+		}
+	}
+}
@@ -0,0 +1,429 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/uptrace/bun"
+	"github.com/zoomxml/config"
+	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/logger"
+	"github.com/zoomxml/internal/models"
+	"github.com/zoomxml/internal/storage"
+)
+
+// exportChunkSize is the number of documents an export job copies on each run
+const exportChunkSize = 100
+
+var (
+	// ErrExportTargetNotFound is returned when a company has no export target configured
+	ErrExportTargetNotFound = errors.New("company has no export target")
+	// ErrExportTargetInvalid is returned when the target bucket cannot be reached with its
+	// credentials
+	ErrExportTargetInvalid = errors.New("export target is not accessible")
+	// ErrExportEndpointNotAllowed is returned for export endpoints outside the configured allowlist
+	ErrExportEndpointNotAllowed = errors.New("export target endpoint is not allowed")
+)
+
+// ValidateExportEndpoint checks that endpoint is a host, with an optional port, in
+// STORAGE_EXPORT_ALLOWED_HOSTS
+func ValidateExportEndpoint(endpoint string) error {
+	return validateExportEndpoint(endpoint, config.Get().Storage.ExportAllowedHosts)
+}
+
+// validateExportEndpoint is ValidateExportEndpoint against an explicit allowlist
+func validateExportEndpoint(endpoint string, allowedHosts []string) error {
+	if endpoint == "" || strings.ContainsAny(endpoint, "/?#@") {
+		return fmt.Errorf("export target endpoint must be a host with an optional port, without scheme or path")
+	}
+
+	host := endpoint
+	if h, port, err := net.SplitHostPort(endpoint); err == nil {
+		if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+			return fmt.Errorf("invalid export target endpoint port: %s", port)
+		}
+		host = h
+	}
+
+	if !hostAllowed(host, allowedHosts) {
+		return fmt.Errorf("%w: %s", ErrExportEndpointNotAllowed, host)
+	}
+	return nil
+}
+
+// activeExportStatuses are the statuses of a job that still has documents to copy
+var activeExportStatuses = []string{models.ExportStatusPending, models.ExportStatusRunning}
+
+// exportTargetClient connects to the bucket of an export target. The endpoint is validated
+// again so that tightening the allowlist also applies to targets configured earlier.
+func exportTargetClient(target *models.CompanyExportTarget) (*minio.Client, error) {
+	if err := ValidateExportEndpoint(target.Endpoint); err != nil {
+		return nil, err
+	}
+
+	secretKey, err := target.GetSecretKey()
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt export target secret: %w", err)
+	}
+
+	client, err := minio.New(target.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(target.AccessKey, secretKey, ""),
+		Secure: target.UseSSL,
+		Region: target.Region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrExportTargetInvalid, err)
+	}
+	return client, nil
+}
+
+// ValidateExportTarget checks that the bucket of a target exists and its credentials
+// can reach it
+func ValidateExportTarget(ctx context.Context, target *models.CompanyExportTarget) error {
+	client, err := exportTargetClient(target)
+	if err != nil {
+		return err
+	}
+
+	exists, err := client.BucketExists(ctx, target.Bucket)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrExportTargetInvalid, err)
+	}
+	if !exists {
+		return fmt.Errorf("%w: bucket %s does not exist", ErrExportTargetInvalid, target.Bucket)
+	}
+	return nil
+}
+
+// SaveExportTarget validates a target and stores it as the export target of its company,
+// replacing the previous one
+func SaveExportTarget(ctx context.Context, target *models.CompanyExportTarget) error {
+	if err := ValidateExportTarget(ctx, target); err != nil {
+		return err
+	}
+
+	_, err := database.DB.NewInsert().
+		Model(target).
+		On("CONFLICT (company_id) DO UPDATE").
+		Set("endpoint = EXCLUDED.endpoint").
+		Set("region = EXCLUDED.region").
+		Set("bucket = EXCLUDED.bucket").
+		Set("prefix = EXCLUDED.prefix").
+		Set("use_ssl = EXCLUDED.use_ssl").
+		Set("access_key = EXCLUDED.access_key").
+		Set("encrypted_secret_key = EXCLUDED.encrypted_secret_key").
+		Set("updated_at = EXCLUDED.updated_at").
+		Returning("id, created_at").
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to save export target: %w", err)
+	}
+	return nil
+}
+
+// GetExportTarget loads the export target of a company, or ErrExportTargetNotFound
+func GetExportTarget(ctx context.Context, companyID int64) (*models.CompanyExportTarget, error) {
+	target := &models.CompanyExportTarget{}
+	err := database.DB.NewSelect().
+		Model(target).
+		Where("company_id = ?", companyID).
+		Scan(ctx)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrExportTargetNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load export target: %w", err)
+	}
+	return target, nil
+}
+
+// StartExport validates the export target of a company and creates a job that copies its
+// stored XMLs there. It is idempotent: while the company has an unfinished job, that job
+// is returned instead and created is false.
+func StartExport(ctx context.Context, companyID, userID int64) (job *models.ExportJob, created bool, err error) {
+	if active, err := activeExportJob(ctx, companyID); err != nil || active != nil {
+		return active, false, err
+	}
+
+	target, err := GetExportTarget(ctx, companyID)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := ValidateExportTarget(ctx, target); err != nil {
+		return nil, false, err
+	}
+
+	total, err := database.DB.NewSelect().
+		Model((*models.Document)(nil)).
+		Where("company_id = ?", companyID).
+		Where("storage_key IS NOT NULL AND storage_key != ''").
+		Count(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to count documents: %w", err)
+	}
+
+	job = &models.ExportJob{
+		CompanyID:   companyID,
+		RequestedBy: userID,
+		Status:      models.ExportStatusPending,
+		Total:       total,
+	}
+
+	// The partial unique index allows a single unfinished job per company, so a
+	// concurrent request that won the race is returned instead
+	res, err := database.DB.NewInsert().
+		Model(job).
+		On("CONFLICT DO NOTHING").
+		Exec(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create export job: %w", err)
+	}
+
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		active, err := activeExportJob(ctx, companyID)
+		return active, false, err
+	}
+
+	return job, true, nil
+}
+
+// GetExportJob loads an export job of a company
+func GetExportJob(ctx context.Context, companyID, jobID int64) (*models.ExportJob, error) {
+	job := &models.ExportJob{}
+	err := database.DB.NewSelect().
+		Model(job).
+		Where("id = ? AND company_id = ?", jobID, companyID).
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// activeExportJob returns the unfinished export job of a company, or nil
+func activeExportJob(ctx context.Context, companyID int64) (*models.ExportJob, error) {
+	job := &models.ExportJob{}
+	err := database.DB.NewSelect().
+		Model(job).
+		Where("company_id = ?", companyID).
+		Where("status IN (?)", bun.In(activeExportStatuses)).
+		Limit(1).
+		Scan(ctx)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load export job: %w", err)
+	}
+	return job, nil
+}
+
+// ExportWorker advances unfinished export jobs in the background. Progress is saved after
+// every chunk, so jobs resume where they stopped after a restart.
+type ExportWorker struct {
+	ticker   *time.Ticker
+	stopChan chan bool
+	running  bool
+	config   *config.Config
+}
+
+// NewExportWorker creates a new export worker
+func NewExportWorker() *ExportWorker {
+	return &ExportWorker{
+		stopChan: make(chan bool),
+		config:   config.Get(),
+	}
+}
+
+// Start begins advancing jobs every STORAGE_EXPORT_INTERVAL
+func (w *ExportWorker) Start() {
+	if w.running {
+		return
+	}
+
+	interval := w.config.Storage.ExportInterval
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+
+	w.ticker = time.NewTicker(interval)
+	w.running = true
+
+	logger.InfoWithFields("Starting export worker", map[string]any{
+		"operation": "start_export_worker",
+		"interval":  interval.String(),
+	})
+
+	go w.run()
+}
+
+// Stop stops the worker
+func (w *ExportWorker) Stop() {
+	if !w.running {
+		return
+	}
+
+	w.stopChan <- true
+	w.ticker.Stop()
+	w.running = false
+}
+
+// run is the worker loop
+func (w *ExportWorker) run() {
+	for {
+		select {
+		case <-w.ticker.C:
+			w.ProcessPendingJobs(context.Background())
+		case <-w.stopChan:
+			return
+		}
+	}
+}
+
+// ProcessPendingJobs advances every unfinished job by one chunk, on up to
+// JOB_WORKER_CONCURRENCY jobs at a time. Jobs advanced by another instance are skipped.
+func (w *ExportWorker) ProcessPendingJobs(ctx context.Context) {
+	err := runClaimedJobs(ctx, w.config.App.JobWorkerConcurrency, activeExportStatuses, w.advance,
+		func(job *models.ExportJob, err error) {
+			logger.ErrorWithFields("Failed to advance export job", err, map[string]any{
+				"operation":  "export_documents",
+				"job_id":     job.ID,
+				"company_id": job.CompanyID,
+			})
+		})
+
+	if err != nil {
+		logger.ErrorWithFields("Failed to claim export jobs", err, map[string]any{
+			"operation": "export_documents",
+		})
+	}
+}
+
+// advance copies the next chunk of documents of a job and saves its progress. A target
+// that can no longer be reached fails the job; a new export resumes it, skipping what
+// was already copied.
+func (w *ExportWorker) advance(ctx context.Context, job *models.ExportJob) error {
+	target, err := GetExportTarget(ctx, job.CompanyID)
+	var client *minio.Client
+	if err == nil {
+		client, err = exportTargetClient(target)
+	}
+	if err != nil {
+		job.Status = models.ExportStatusFailed
+		job.LastError = err.Error()
+		job.FinishedAt = time.Now()
+		return w.saveProgress(ctx, job)
+	}
+
+	documents := []models.Document{}
+	err = database.DB.NewSelect().
+		Model(&documents).
+		Column("id", "storage_key").
+		Where("company_id = ?", job.CompanyID).
+		Where("storage_key IS NOT NULL AND storage_key != ''").
+		Where("id > ?", job.LastDocumentID).
+		Order("id ASC").
+		Limit(exportChunkSize).
+		Scan(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load documents: %w", err)
+	}
+
+	for i := range documents {
+		copied, err := copyToExportTarget(ctx, client, target, documents[i].StorageKey)
+		switch {
+		case err != nil:
+			job.Failed++
+			job.LastError = fmt.Sprintf("document %d: %v", documents[i].ID, err)
+		case copied:
+			job.Copied++
+		default:
+			job.Skipped++
+		}
+		job.LastDocumentID = documents[i].ID
+	}
+
+	job.Status = models.ExportStatusRunning
+	if len(documents) < exportChunkSize {
+		job.Status = models.ExportStatusCompleted
+		job.FinishedAt = time.Now()
+	}
+	// Documents stored after the job started are copied too
+	if done := job.Copied + job.Skipped + job.Failed; done > job.Total {
+		job.Total = done
+	}
+
+	if err := w.saveProgress(ctx, job); err != nil {
+		return err
+	}
+
+	if job.Status == models.ExportStatusCompleted {
+		logger.InfoWithFields("Export job completed", map[string]any{
+			"operation":  "export_documents",
+			"job_id":     job.ID,
+			"company_id": job.CompanyID,
+			"copied":     job.Copied,
+			"skipped":    job.Skipped,
+			"failed":     job.Failed,
+		})
+	}
+
+	return nil
+}
+
+// saveProgress stores the counters and status of a job while it still holds its lease
+func (w *ExportWorker) saveProgress(ctx context.Context, job *models.ExportJob) error {
+	res, err := database.DB.NewUpdate().
+		Model(job).
+		Column("status", "total", "copied", "skipped", "failed", "last_document_id", "last_error", "finished_at", "updated_at").
+		WherePK().
+		Where("locked_until = ?", job.LockedUntil).
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to save progress: %w", err)
+	}
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		return errJobLeaseLost
+	}
+	return nil
+}
+
+// copyToExportTarget copies one stored XML to the target bucket under its prefix. Objects
+// already in the bucket are skipped, which makes re-running an export idempotent. It
+// reports whether the object was copied.
+func copyToExportTarget(ctx context.Context, client *minio.Client, target *models.CompanyExportTarget, storageKey string) (bool, error) {
+	objectName := path.Join(target.Prefix, storageKey)
+
+	_, err := client.StatObject(ctx, target.Bucket, objectName, minio.StatObjectOptions{})
+	if err == nil {
+		return false, nil
+	}
+	if minio.ToErrorResponse(err).Code != "NoSuchKey" {
+		return false, fmt.Errorf("failed to check target object: %w", err)
+	}
+
+	data, err := storage.Storage.DownloadFile(ctx, nfseBucket, storageKey)
+	if err != nil {
+		return false, fmt.Errorf("failed to read stored XML: %w", err)
+	}
+
+	_, err = client.PutObject(ctx, target.Bucket, objectName, bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{
+		ContentType: "application/xml",
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to copy XML: %w", err)
+	}
+	return true, nil
+}
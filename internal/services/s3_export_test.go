@@ -0,0 +1,240 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/zoomxml/config"
+	"github.com/zoomxml/internal/database/databasetest"
+	"github.com/zoomxml/internal/models"
+)
+
+func TestValidateExportEndpoint(t *testing.T) {
+	allowed := []string{"*.amazonaws.com", "minio.example.com"}
+
+	tests := []struct {
+		name       string
+		endpoint   string
+		wantErr    bool
+		notAllowed bool
+	}{
+		{name: "allowed subdomain", endpoint: "s3.sa-east-1.amazonaws.com"},
+		{name: "exact host with port", endpoint: "minio.example.com:9000"},
+		{name: "host case", endpoint: "S3.AMAZONAWS.COM"},
+		{name: "empty", endpoint: "", wantErr: true},
+		{name: "scheme", endpoint: "https://s3.amazonaws.com", wantErr: true},
+		{name: "path", endpoint: "s3.amazonaws.com/bucket", wantErr: true},
+		{name: "userinfo", endpoint: "user@s3.amazonaws.com", wantErr: true},
+		{name: "bad port", endpoint: "minio.example.com:99999", wantErr: true},
+		{name: "bare suffix", endpoint: "amazonaws.com", wantErr: true, notAllowed: true},
+		{name: "lookalike", endpoint: "s3.amazonaws.com.evil.net", wantErr: true, notAllowed: true},
+		{name: "internal address", endpoint: "169.254.169.254", wantErr: true, notAllowed: true},
+		{name: "loopback with port", endpoint: "localhost:9000", wantErr: true, notAllowed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateExportEndpoint(tt.endpoint, allowed)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validateExportEndpoint(%q) error = %v, wantErr %v", tt.endpoint, err, tt.wantErr)
+			}
+			if tt.notAllowed && !errors.Is(err, ErrExportEndpointNotAllowed) {
+				t.Errorf("validateExportEndpoint(%q) error = %v, want ErrExportEndpointNotAllowed", tt.endpoint, err)
+			}
+		})
+	}
+}
+
+// fakeS3 is an S3 endpoint that keeps the objects of one bucket in memory
+type fakeS3 struct {
+	mu      sync.Mutex
+	bucket  string
+	objects map[string][]byte
+	puts    int
+}
+
+func (s *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != s.bucket {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if key == "" {
+		// BucketExists
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodHead:
+		data, ok := s.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("ETag", `"etag"`)
+		w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.WriteHeader(http.StatusOK)
+	case http.MethodPut:
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+			body = decodeAWSChunked(body)
+		}
+		s.objects[key] = body
+		s.puts++
+		w.Header().Set("ETag", `"etag"`)
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// decodeAWSChunked returns the payload of a body sent with a streaming signature, made of
+// "size;chunk-signature=...\r\n<data>\r\n" chunks ending with an empty one
+func decodeAWSChunked(body []byte) []byte {
+	var payload []byte
+	for {
+		header, rest, ok := bytes.Cut(body, []byte("\r\n"))
+		if !ok {
+			return payload
+		}
+		sizeHex, _, _ := bytes.Cut(header, []byte(";"))
+		size, err := strconv.ParseInt(string(sizeHex), 16, 64)
+		if err != nil || size == 0 || int64(len(rest)) < size {
+			return payload
+		}
+		payload = append(payload, rest[:size]...)
+		body = bytes.TrimPrefix(rest[size:], []byte("\r\n"))
+	}
+}
+
+// useFakeS3 starts a fakeS3 for bucket and returns it with its host:port
+func useFakeS3(t *testing.T, bucket string) (*fakeS3, string) {
+	t.Helper()
+	s3 := &fakeS3{bucket: bucket, objects: map[string][]byte{}}
+	server := httptest.NewServer(s3)
+	t.Cleanup(server.Close)
+	return s3, strings.TrimPrefix(server.URL, "http://")
+}
+
+func TestCopyToExportTarget(t *testing.T) {
+	ctx := context.Background()
+	memory := useMemoryStorage(t)
+	memory.objects["12345678000190/2025/03/1.xml"] = []byte("<nfse>1</nfse>")
+	memory.objects["12345678000190/2025/03/2.xml"] = []byte("<nfse>2</nfse>")
+
+	s3, endpoint := useFakeS3(t, "exports")
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4("access", "secret", ""),
+		Region: "us-east-1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := &models.CompanyExportTarget{Bucket: "exports", Prefix: "zoomxml"}
+	keys := []string{"12345678000190/2025/03/1.xml", "12345678000190/2025/03/2.xml"}
+
+	// The first run copies every object under the prefix; the second finds them all there
+	for run, wantCopied := range []bool{true, false} {
+		for _, key := range keys {
+			copied, err := copyToExportTarget(ctx, client, target, key)
+			if err != nil {
+				t.Fatalf("run %d: copyToExportTarget(%s) error = %v", run+1, key, err)
+			}
+			if copied != wantCopied {
+				t.Errorf("run %d: copyToExportTarget(%s) = %v, want %v", run+1, key, copied, wantCopied)
+			}
+		}
+	}
+
+	if s3.puts != len(keys) {
+		t.Errorf("target received %d uploads, want %d", s3.puts, len(keys))
+	}
+	for _, key := range keys {
+		if got := string(s3.objects["zoomxml/"+key]); got != string(memory.objects[key]) {
+			t.Errorf("target object %s = %q, want %q", key, got, memory.objects[key])
+		}
+	}
+
+	if _, err := copyToExportTarget(ctx, client, target, "12345678000190/2025/03/missing.xml"); err == nil {
+		t.Error("copyToExportTarget() of an object missing from storage error = nil, want an error")
+	}
+}
+
+func TestExportJobCopiesAndResumes(t *testing.T) {
+	databasetest.Require(t)
+	ctx := context.Background()
+	memory := useMemoryStorage(t)
+
+	s3, endpoint := useFakeS3(t, "exports")
+	cfg := &config.Get().Storage
+	previous := cfg.ExportAllowedHosts
+	cfg.ExportAllowedHosts = []string{"127.0.0.1"}
+	t.Cleanup(func() { cfg.ExportAllowedHosts = previous })
+
+	company := databasetest.CreateCompany(t, nil)
+	user := databasetest.CreateUser(t, "admin")
+	keys := []string{}
+	for i := 1; i <= 3; i++ {
+		key := fmt.Sprintf("%s/2025/03/%d.xml", company.CNPJ, i)
+		memory.objects[key] = []byte(fmt.Sprintf("<nfse>%d</nfse>", i))
+		databasetest.CreateDocument(t, &models.Document{CompanyID: company.ID, StorageKey: key})
+		keys = append(keys, key)
+	}
+
+	target := &models.CompanyExportTarget{CompanyID: company.ID, Endpoint: endpoint, Region: "us-east-1", Bucket: "exports", AccessKey: "access"}
+	if err := target.SetSecretKey("secret"); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveExportTarget(ctx, target); err != nil {
+		t.Fatalf("SaveExportTarget() error = %v", err)
+	}
+
+	// Running the export twice copies each object once and skips it the second time
+	worker := NewExportWorker()
+	for _, want := range []struct{ copied, skipped int }{{3, 0}, {0, 3}} {
+		job, created, err := StartExport(ctx, company.ID, user.ID)
+		if err != nil || !created {
+			t.Fatalf("StartExport() = %v, %v, want a new job", created, err)
+		}
+		worker.ProcessPendingJobs(ctx)
+
+		job, err = GetExportJob(ctx, company.ID, job.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if job.Status != models.ExportStatusCompleted || job.Copied != want.copied || job.Skipped != want.skipped || job.Failed != 0 {
+			t.Errorf("job = %s copied %d skipped %d failed %d, want completed copied %d skipped %d failed 0",
+				job.Status, job.Copied, job.Skipped, job.Failed, want.copied, want.skipped)
+		}
+	}
+
+	if s3.puts != len(keys) {
+		t.Errorf("target received %d uploads, want %d", s3.puts, len(keys))
+	}
+	for _, key := range keys {
+		if got := string(s3.objects[key]); got != string(memory.objects[key]) {
+			t.Errorf("target object %s = %q, want %q", key, got, memory.objects[key])
+		}
+	}
+}
@@ -0,0 +1,350 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/models"
+)
+
+// CronSchedule is a parsed five-field cron expression (minute hour day-of-month month
+// day-of-week). Fields accept *, values, ranges (1-5), lists (1,15) and steps (*/15, 8-18/2);
+// day-of-week runs 0-7 with both 0 and 7 as Sunday.
+type CronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	domAny, dowAny                bool
+}
+
+// cronField bounds one field of a cron expression
+type cronField struct {
+	name     string
+	min, max int
+}
+
+var cronFields = []cronField{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+// ParseCronExpression parses a five-field cron expression
+func ParseCronExpression(expr string) (*CronSchedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("cron expression must have 5 fields (minute hour day month weekday), got %d", len(fields))
+	}
+
+	bits := make([]uint64, len(fields))
+	for i, field := range fields {
+		set, err := parseCronField(field, cronFields[i])
+		if err != nil {
+			return nil, err
+		}
+		bits[i] = set
+	}
+
+	schedule := &CronSchedule{
+		minute: bits[0],
+		hour:   bits[1],
+		dom:    bits[2],
+		month:  bits[3],
+		dow:    bits[4],
+		domAny: fields[2] == "*",
+		dowAny: fields[4] == "*",
+	}
+	// Sunday is both 0 and 7
+	if schedule.dow&(1<<7) != 0 {
+		schedule.dow |= 1
+	}
+	return schedule, nil
+}
+
+// parseCronField returns the values of one field as a bit set
+func parseCronField(field string, bounds cronField) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+		if before, after, ok := strings.Cut(part, "/"); ok {
+			n, err := strconv.Atoi(after)
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("invalid step %q in %s field", after, bounds.name)
+			}
+			rangePart, step = before, n
+		}
+
+		low, high := bounds.min, bounds.max
+		if rangePart != "*" {
+			from, to, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if low, err = strconv.Atoi(from); err != nil {
+				return 0, fmt.Errorf("invalid value %q in %s field", from, bounds.name)
+			}
+			high = low
+			if isRange {
+				if high, err = strconv.Atoi(to); err != nil {
+					return 0, fmt.Errorf("invalid value %q in %s field", to, bounds.name)
+				}
+			} else if step > 1 {
+				// "5/15" means from 5 to the end of the field
+				high = bounds.max
+			}
+		}
+
+		if low < bounds.min || high > bounds.max || low > high {
+			return 0, fmt.Errorf("%s field %q out of range %d-%d", bounds.name, part, bounds.min, bounds.max)
+		}
+		for v := low; v <= high; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+	return set, nil
+}
+
+// dayMatches applies the cron rule for days: when both day of month and day of week are
+// restricted, a day matching either one matches
+func (s *CronSchedule) dayMatches(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+	switch {
+	case s.domAny && s.dowAny:
+		return true
+	case s.domAny:
+		return dowMatch
+	case s.dowAny:
+		return domMatch
+	}
+	return domMatch || dowMatch
+}
+
+// Next returns the first scheduled minute strictly after t, or the zero time when the
+// expression never matches (e.g. 30 February) within five years
+func (s *CronSchedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// SyncWindow is a daily time-of-day window, in server time, during which a company may be
+// fetched. An end before the start wraps past midnight (22:00-04:00).
+type SyncWindow struct {
+	Start, End int // minutes since midnight
+}
+
+// ParseSyncWindow parses a window given as HH:MM start and end times
+func ParseSyncWindow(start, end string) (SyncWindow, error) {
+	var window SyncWindow
+	var err error
+	if window.Start, err = parseClock(start); err != nil {
+		return window, fmt.Errorf("invalid sync window start: %w", err)
+	}
+	if window.End, err = parseClock(end); err != nil {
+		return window, fmt.Errorf("invalid sync window end: %w", err)
+	}
+	if window.Start == window.End {
+		return window, fmt.Errorf("sync window start and end must differ")
+	}
+	return window, nil
+}
+
+// parseClock converts HH:MM to minutes since midnight
+func parseClock(value string) (int, error) {
+	parsed, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a HH:MM time", value)
+	}
+	return parsed.Hour()*60 + parsed.Minute(), nil
+}
+
+// Contains reports whether t falls inside the window (start inclusive, end exclusive)
+func (w SyncWindow) Contains(t time.Time) bool {
+	return w.containsMinute(t.Hour()*60 + t.Minute())
+}
+
+// containsMinute reports whether a minute since midnight falls inside the window
+func (w SyncWindow) containsMinute(minute int) bool {
+	if w.Start < w.End {
+		return minute >= w.Start && minute < w.End
+	}
+	return minute >= w.Start || minute < w.End
+}
+
+// fitsWindow reports whether the expression has a time of day inside the window. The
+// window repeats every day, so one that contains none of its hours and minutes never
+// lets it run.
+func (s *CronSchedule) fitsWindow(w SyncWindow) bool {
+	for minute := 0; minute < 24*60; minute++ {
+		if s.hour&(1<<uint(minute/60)) == 0 || s.minute&(1<<uint(minute%60)) == 0 {
+			continue
+		}
+		if w.containsMinute(minute) {
+			return true
+		}
+	}
+	return false
+}
+
+// nextStart returns the next opening of the window at or after t
+func (w SyncWindow) nextStart(t time.Time) time.Time {
+	start := time.Date(t.Year(), t.Month(), t.Day(), w.Start/60, w.Start%60, 0, 0, t.Location())
+	if start.Before(t) {
+		start = start.AddDate(0, 0, 1)
+	}
+	return start
+}
+
+// ValidateSyncSchedule checks the schedule fields of a company: an optional cron
+// expression and an optional window, whose start and end must be given together. A cron
+// expression with no time inside the window is rejected, since it would never run.
+func ValidateSyncSchedule(cron, windowStart, windowEnd string) error {
+	var schedule *CronSchedule
+	if cron != "" {
+		var err error
+		if schedule, err = ParseCronExpression(cron); err != nil {
+			return err
+		}
+	}
+	if (windowStart == "") != (windowEnd == "") {
+		return fmt.Errorf("sync window start and end must be set together")
+	}
+	if windowStart != "" {
+		window, err := ParseSyncWindow(windowStart, windowEnd)
+		if err != nil {
+			return err
+		}
+		if schedule != nil && !schedule.fitsWindow(window) {
+			return fmt.Errorf("cron expression %q never runs inside the sync window %s-%s", cron, windowStart, windowEnd)
+		}
+	}
+	return nil
+}
+
+// companySyncDue reports whether the scheduler should fetch a company at now. Companies
+// without a schedule follow the global interval. With a window, they are fetched only
+// inside it; with a cron expression, only when a scheduled time passed since their last
+// scheduled fetch. Invalid stored schedules are ignored.
+func companySyncDue(company *models.Company, now time.Time) bool {
+	if company.SyncWindowStart != "" {
+		if window, err := ParseSyncWindow(company.SyncWindowStart, company.SyncWindowEnd); err == nil && !window.Contains(now) {
+			return false
+		}
+	}
+
+	if company.SyncCron != "" && !company.LastScheduledFetchAt.IsZero() {
+		if schedule, err := ParseCronExpression(company.SyncCron); err == nil {
+			next := schedule.Next(company.LastScheduledFetchAt)
+			return !next.IsZero() && !next.After(now)
+		}
+	}
+	return true
+}
+
+// NextCompanySync estimates when the scheduler will next fetch a company: the first
+// scheduled time (or now) that falls inside its window. The fetch itself happens on the
+// first scheduler tick at or after that time. The zero time means the schedule never runs.
+func NextCompanySync(company *models.Company, now time.Time) time.Time {
+	var window *SyncWindow
+	if company.SyncWindowStart != "" {
+		if parsed, err := ParseSyncWindow(company.SyncWindowStart, company.SyncWindowEnd); err == nil {
+			window = &parsed
+		}
+	}
+
+	var schedule *CronSchedule
+	if company.SyncCron != "" {
+		schedule, _ = ParseCronExpression(company.SyncCron)
+	}
+
+	candidate := now
+	if schedule != nil && !company.LastScheduledFetchAt.IsZero() {
+		if next := schedule.Next(company.LastScheduledFetchAt); next.After(now) || next.IsZero() {
+			candidate = next
+		}
+	}
+
+	if schedule != nil && window != nil && !schedule.fitsWindow(*window) {
+		return time.Time{}
+	}
+
+	// Move to the first cron time inside the window, looking at most a year ahead. Each
+	// step jumps to the next opening of the window, so the loop runs about once a day.
+	limit := now.AddDate(1, 0, 0)
+	for !candidate.IsZero() && candidate.Before(limit) {
+		if window == nil || window.Contains(candidate) {
+			return candidate
+		}
+		opening := window.nextStart(candidate)
+		if schedule == nil {
+			return opening
+		}
+		candidate = schedule.Next(opening.Add(-time.Minute))
+	}
+	return time.Time{}
+}
+
+// UpdateCompanySyncSchedule stores the schedule of a company. Setting a cron expression
+// starts counting from now, so the first fetch happens at its next scheduled time.
+func UpdateCompanySyncSchedule(ctx context.Context, companyID int64, cron, windowStart, windowEnd string) error {
+	if err := ValidateSyncSchedule(cron, windowStart, windowEnd); err != nil {
+		return err
+	}
+
+	query := database.DB.NewUpdate().
+		Model((*models.Company)(nil)).
+		Set("sync_cron = NULLIF(?, '')", cron).
+		Set("sync_window_start = NULLIF(?, '')", windowStart).
+		Set("sync_window_end = NULLIF(?, '')", windowEnd).
+		Set("updated_at = current_timestamp").
+		Where("id = ?", companyID)
+
+	if cron != "" {
+		query = query.Set("last_scheduled_fetch_at = ?", time.Now())
+	}
+
+	res, err := query.Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to update sync schedule: %w", err)
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// markScheduledFetch records when the scheduler started a successful fetch of a company,
+// the point its cron expression is evaluated from. A failed fetch leaves it alone, so the
+// company is retried on the next tick instead of waiting for the next scheduled time.
+func markScheduledFetch(ctx context.Context, companyID int64, at time.Time) error {
+	_, err := database.DB.NewUpdate().
+		Model((*models.Company)(nil)).
+		Set("last_scheduled_fetch_at = ?", at).
+		Where("id = ?", companyID).
+		Exec(ctx)
+	return err
+}
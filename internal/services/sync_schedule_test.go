@@ -0,0 +1,144 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/zoomxml/internal/models"
+)
+
+func TestCronScheduleNext(t *testing.T) {
+	from := time.Date(2025, 3, 14, 10, 7, 30, 0, time.UTC) // Friday
+
+	tests := []struct {
+		name string
+		expr string
+		want time.Time
+	}{
+		{"every minute", "* * * * *", time.Date(2025, 3, 14, 10, 8, 0, 0, time.UTC)},
+		{"step", "*/15 * * * *", time.Date(2025, 3, 14, 10, 15, 0, 0, time.UTC)},
+		{"daily at three", "0 3 * * *", time.Date(2025, 3, 15, 3, 0, 0, 0, time.UTC)},
+		{"hour range with step", "30 8-18/2 * * *", time.Date(2025, 3, 14, 10, 30, 0, 0, time.UTC)},
+		{"weekdays only", "0 9 * * 1-5", time.Date(2025, 3, 17, 9, 0, 0, 0, time.UTC)},
+		{"sunday as seven", "0 0 * * 7", time.Date(2025, 3, 16, 0, 0, 0, 0, time.UTC)},
+		{"day of month or weekday", "0 0 1 * 6", time.Date(2025, 3, 15, 0, 0, 0, 0, time.UTC)},
+		{"month list", "0 0 1 1,6 *", time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)},
+		{"never matches", "0 0 30 2 *", time.Time{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := ParseCronExpression(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseCronExpression(%q) error = %v", tt.expr, err)
+			}
+			if got := schedule.Next(from); !got.Equal(tt.want) {
+				t.Errorf("Next(%q) = %v, want %v", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCronExpressionErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+	} {
+		if _, err := ParseCronExpression(expr); err == nil {
+			t.Errorf("ParseCronExpression(%q) error = nil, want an error", expr)
+		}
+	}
+}
+
+func TestSyncWindowContains(t *testing.T) {
+	at := func(hour, minute int) time.Time { return time.Date(2025, 3, 14, hour, minute, 0, 0, time.UTC) }
+
+	tests := []struct {
+		name       string
+		start, end string
+		t          time.Time
+		want       bool
+	}{
+		{"inside", "08:00", "18:00", at(12, 0), true},
+		{"start is inclusive", "08:00", "18:00", at(8, 0), true},
+		{"end is exclusive", "08:00", "18:00", at(18, 0), false},
+		{"before", "08:00", "18:00", at(7, 59), false},
+		{"wraps past midnight, late", "22:00", "04:00", at(23, 30), true},
+		{"wraps past midnight, early", "22:00", "04:00", at(3, 59), true},
+		{"wraps past midnight, outside", "22:00", "04:00", at(12, 0), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			window, err := ParseSyncWindow(tt.start, tt.end)
+			if err != nil {
+				t.Fatalf("ParseSyncWindow() error = %v", err)
+			}
+			if got := window.Contains(tt.t); got != tt.want {
+				t.Errorf("Contains(%v) = %v, want %v", tt.t, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateSyncSchedule(t *testing.T) {
+	tests := []struct {
+		name                   string
+		cron, windowStart, end string
+		wantErr                bool
+	}{
+		{"empty", "", "", "", false},
+		{"cron only", "0 3 * * *", "", "", false},
+		{"window only", "", "22:00", "04:00", false},
+		{"cron inside window", "0 3 * * *", "02:00", "05:00", false},
+		{"cron inside wrapped window", "30 23 * * *", "22:00", "04:00", false},
+		{"cron outside window", "0 3 * * *", "04:00", "05:00", true},
+		{"minutes outside a short window", "0 * * * *", "04:15", "04:45", true},
+		{"window missing its end", "", "22:00", "", true},
+		{"invalid cron", "0 25 * * *", "", "", true},
+		{"empty window", "", "04:00", "04:00", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateSyncSchedule(tt.cron, tt.windowStart, tt.end)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateSyncSchedule(%q, %q, %q) error = %v, wantErr %v", tt.cron, tt.windowStart, tt.end, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNextCompanySync(t *testing.T) {
+	now := time.Date(2025, 3, 14, 10, 0, 0, 0, time.Local)
+	at := func(day, hour, minute int) time.Time { return time.Date(2025, 3, day, hour, minute, 0, 0, time.Local) }
+
+	tests := []struct {
+		name    string
+		company models.Company
+		want    time.Time
+	}{
+		{"no schedule", models.Company{}, now},
+		{"inside the window", models.Company{SyncWindowStart: "08:00", SyncWindowEnd: "18:00"}, now},
+		{"waits for the window", models.Company{SyncWindowStart: "22:00", SyncWindowEnd: "04:00"}, at(14, 22, 0)},
+		{"next cron time", models.Company{SyncCron: "0 3 * * *", LastScheduledFetchAt: at(14, 3, 0)}, at(15, 3, 0)},
+		{"cron time inside the window", models.Company{SyncCron: "0 * * * *", LastScheduledFetchAt: at(14, 9, 0), SyncWindowStart: "20:00", SyncWindowEnd: "21:00"}, at(14, 20, 0)},
+		{"cron never inside the window", models.Company{SyncCron: "0 3 * * *", LastScheduledFetchAt: at(14, 3, 0), SyncWindowStart: "04:00", SyncWindowEnd: "05:00"}, time.Time{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NextCompanySync(&tt.company, now); !got.Equal(tt.want) {
+				t.Errorf("NextCompanySync() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
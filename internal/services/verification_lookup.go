@@ -0,0 +1,52 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"unicode"
+
+	"github.com/zoomxml/internal/database"
+	"github.com/zoomxml/internal/models"
+)
+
+// VerificationCodeSQL is the SQL counterpart of NormalizeVerificationCode applied to the
+// stored verification code, for comparisons that ignore its punctuation and case. It is
+// the expression of idx_documents_verification_code_normalized, so it must not change
+// without that index.
+const VerificationCodeSQL = "upper(regexp_replace(verification_code, '[^[:alnum:]]', '', 'g'))"
+
+// NormalizeVerificationCode reduces a verification code to its upper-case letters and
+// digits, so codes typed with spaces, dashes or dots match the stored ones
+func NormalizeVerificationCode(code string) string {
+	var b strings.Builder
+	for _, r := range code {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(unicode.ToUpper(r))
+		}
+	}
+	return b.String()
+}
+
+// FindDocumentByVerificationCode returns the NFSe of a company with the given verification
+// code, or sql.ErrNoRows. Codes are compared in normalized form, through
+// idx_documents_verification_code_normalized.
+func FindDocumentByVerificationCode(ctx context.Context, companyID int64, code string) (*models.Document, error) {
+	normalized := NormalizeVerificationCode(code)
+	if normalized == "" {
+		return nil, sql.ErrNoRows
+	}
+
+	document := &models.Document{}
+	err := database.DB.NewSelect().
+		Model(document).
+		Where("company_id = ? AND type = 'nfse'", companyID).
+		Where(VerificationCodeSQL+" = ?", normalized).
+		Order("id ASC").
+		Limit(1).
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return document, nil
+}
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/zoomxml/internal/database/databasetest"
+	"github.com/zoomxml/internal/models"
+)
+
+func TestNormalizeVerificationCode(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		want string
+	}{
+		{"already normalized", "ABC123", "ABC123"},
+		{"lower case", "abc123", "ABC123"},
+		{"dashes and dots", "ab-c1.23", "ABC123"},
+		{"spaces", " AB C 123 ", "ABC123"},
+		{"punctuation only", "-.-", ""},
+		{"empty", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NormalizeVerificationCode(tt.code); got != tt.want {
+				t.Errorf("NormalizeVerificationCode(%q) = %q, want %q", tt.code, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindDocumentByVerificationCode(t *testing.T) {
+	databasetest.Require(t)
+	ctx := context.Background()
+	company := databasetest.CreateCompany(t, nil)
+	other := databasetest.CreateCompany(t, nil)
+
+	stored := databasetest.CreateDocument(t, &models.Document{CompanyID: company.ID, Number: "4521", VerificationCode: "AB12-CD34"})
+	databasetest.CreateDocument(t, &models.Document{CompanyID: other.ID, Number: "7", VerificationCode: "ZZ99-ZZ99"})
+
+	tests := []struct {
+		name   string
+		code   string
+		wantID int64 // 0 when no document should be found
+	}{
+		{"as stored", "AB12-CD34", stored.ID},
+		{"normalized form", "ab12cd34", stored.ID},
+		{"other punctuation", " AB.12 CD.34 ", stored.ID},
+		{"unknown code", "AB12-CD35", 0},
+		{"code of another company", "ZZ99ZZ99", 0},
+		{"punctuation only", "--", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			document, err := FindDocumentByVerificationCode(ctx, company.ID, tt.code)
+			if tt.wantID == 0 {
+				if !errors.Is(err, sql.ErrNoRows) {
+					t.Errorf("FindDocumentByVerificationCode(%q) = %v, %v, want %v", tt.code, document, err, sql.ErrNoRows)
+				}
+				return
+			}
+			if err != nil || document.ID != tt.wantID {
+				t.Errorf("FindDocumentByVerificationCode(%q) = %v, %v, want document %d", tt.code, document, err, tt.wantID)
+			}
+		})
+	}
+}
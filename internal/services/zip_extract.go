@@ -0,0 +1,132 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/zoomxml/config"
+	"github.com/zoomxml/internal/logger"
+)
+
+var (
+	// ErrInvalidZip is returned when the data is not a readable ZIP archive
+	ErrInvalidZip = errors.New("invalid ZIP archive")
+	// ErrZipLimitExceeded is returned when a ZIP has too many entries or expands past the
+	// configured sizes, which guards against zip bombs
+	ErrZipLimitExceeded = errors.New("ZIP archive exceeds the extraction limits")
+)
+
+// ZipLimits bounds what is extracted from a ZIP archive (0 = unlimited)
+type ZipLimits struct {
+	MaxEntries    int
+	MaxEntryBytes int64
+	MaxTotalBytes int64
+}
+
+// DefaultZipLimits returns the limits of NFSE_ZIP_MAX_ENTRIES, NFSE_ZIP_MAX_ENTRY_BYTES
+// and NFSE_ZIP_MAX_TOTAL_BYTES
+func DefaultZipLimits() ZipLimits {
+	cfg := config.Get().NFSeScheduler
+	return ZipLimits{
+		MaxEntries:    cfg.ZipMaxEntries,
+		MaxEntryBytes: int64(cfg.ZipMaxEntryBytes),
+		MaxTotalBytes: int64(cfg.ZipMaxTotalBytes),
+	}
+}
+
+// ExtractZipDocuments extracts the files of a ZIP archive as NFSe documents. Directories
+// are skipped, and with xmlOnly so are files without the .xml extension. Documents are
+// named by their path inside the archive. Entry sizes are
+// checked against the header and again while reading, since headers can lie, so the
+// archive never expands past the limits in memory.
+func ExtractZipDocuments(data []byte, limits ZipLimits, xmlOnly bool) ([]NFSeDocument, error) {
+	zipReader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidZip, err)
+	}
+
+	if limits.MaxEntries > 0 && len(zipReader.File) > limits.MaxEntries {
+		return nil, fmt.Errorf("%w: %d entries, at most %d allowed", ErrZipLimitExceeded, len(zipReader.File), limits.MaxEntries)
+	}
+
+	var documents []NFSeDocument
+	var total int64
+
+	for _, file := range zipReader.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+		if xmlOnly && !strings.EqualFold(path.Ext(file.Name), ".xml") {
+			continue
+		}
+
+		if limits.MaxEntryBytes > 0 && file.UncompressedSize64 > uint64(limits.MaxEntryBytes) {
+			return nil, fmt.Errorf("%w: %s is larger than %d bytes", ErrZipLimitExceeded, file.Name, limits.MaxEntryBytes)
+		}
+
+		content, err := readZipEntry(file, limits.MaxEntryBytes)
+		if errors.Is(err, ErrZipLimitExceeded) {
+			return nil, err
+		}
+		if err != nil {
+			logger.ErrorWithFields("Failed to read file in ZIP", err, map[string]any{
+				"operation": "extract_xml",
+				"file_name": file.Name,
+			})
+			continue
+		}
+
+		total += int64(len(content))
+		if limits.MaxTotalBytes > 0 && total > limits.MaxTotalBytes {
+			return nil, fmt.Errorf("%w: archive expands past %d bytes", ErrZipLimitExceeded, limits.MaxTotalBytes)
+		}
+
+		documents = append(documents, NFSeDocument{
+			FileName:    zipEntryName(file.Name),
+			XMLContent:  string(content),
+			ProcessedAt: time.Now(),
+		})
+
+		logger.DebugWithFields("XML file extracted", map[string]any{
+			"operation":    "extract_xml",
+			"file_name":    file.Name,
+			"content_size": len(content),
+		})
+	}
+
+	return documents, nil
+}
+
+// zipEntryName is the path of an entry inside its archive, without leading slashes or dot
+// segments. Folders are kept, so same-named files of two folders stay apart in results.
+func zipEntryName(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
+
+// readZipEntry reads one entry, failing with ErrZipLimitExceeded past maxBytes (0 = unlimited)
+func readZipEntry(file *zip.File, maxBytes int64) ([]byte, error) {
+	rc, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	if maxBytes <= 0 {
+		return io.ReadAll(rc)
+	}
+
+	content, err := io.ReadAll(io.LimitReader(rc, maxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(content)) > maxBytes {
+		return nil, fmt.Errorf("%w: %s is larger than %d bytes", ErrZipLimitExceeded, file.Name, maxBytes)
+	}
+	return content, nil
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// buildZip writes an archive with the given entries, in order; names ending in "/" are directories
+func buildZip(t *testing.T, entries ...[2]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	writer := zip.NewWriter(&buf)
+	for _, entry := range entries {
+		w, err := writer.Create(entry[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(entry[1])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestExtractZipDocuments(t *testing.T) {
+	archive := buildZip(t,
+		[2]string{"notas/", ""},
+		[2]string{"notas/1.xml", strings.Repeat("a", 100)},
+		[2]string{"notas/2.XML", strings.Repeat("b", 100)},
+		[2]string{"leia-me.txt", "texto"},
+	)
+
+	tests := []struct {
+		name      string
+		data      []byte
+		limits    ZipLimits
+		xmlOnly   bool
+		wantFiles []string
+		wantErr   error
+	}{
+		{"unlimited", archive, ZipLimits{}, false, []string{"notas/1.xml", "notas/2.XML", "leia-me.txt"}, nil},
+		{"XML only", archive, ZipLimits{}, true, []string{"notas/1.xml", "notas/2.XML"}, nil},
+		{"within limits", archive, ZipLimits{MaxEntries: 4, MaxEntryBytes: 100, MaxTotalBytes: 200}, true, []string{"notas/1.xml", "notas/2.XML"}, nil},
+		{"too many entries", archive, ZipLimits{MaxEntries: 3}, true, nil, ErrZipLimitExceeded},
+		{"entry too large", archive, ZipLimits{MaxEntryBytes: 99}, true, nil, ErrZipLimitExceeded},
+		{"archive expands too much", archive, ZipLimits{MaxTotalBytes: 199}, true, nil, ErrZipLimitExceeded},
+		{"skipped files do not count", archive, ZipLimits{MaxEntryBytes: 100, MaxTotalBytes: 200}, true, []string{"notas/1.xml", "notas/2.XML"}, nil},
+		{"not a ZIP", []byte("<xml/>"), ZipLimits{}, false, nil, ErrInvalidZip},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			documents, err := ExtractZipDocuments(tt.data, tt.limits, tt.xmlOnly)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ExtractZipDocuments() error = %v, want %v", err, tt.wantErr)
+			}
+
+			var files []string
+			for _, document := range documents {
+				files = append(files, document.FileName)
+			}
+			if !slices.Equal(files, tt.wantFiles) {
+				t.Errorf("ExtractZipDocuments() files = %v, want %v", files, tt.wantFiles)
+			}
+		})
+	}
+}
+
+func TestZipEntryName(t *testing.T) {
+	tests := []struct {
+		name  string
+		entry string
+		want  string
+	}{
+		{"top level", "nota.xml", "nota.xml"},
+		{"folder", "janeiro/nota.xml", "janeiro/nota.xml"},
+		{"leading slash", "/janeiro/nota.xml", "janeiro/nota.xml"},
+		{"dot segments", "../janeiro/./nota.xml", "janeiro/nota.xml"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := zipEntryName(tt.entry); got != tt.want {
+				t.Errorf("zipEntryName(%q) = %q, want %q", tt.entry, got, tt.want)
+			}
+		})
+	}
+}
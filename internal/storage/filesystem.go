@@ -0,0 +1,183 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/zoomxml/config"
+	"github.com/zoomxml/internal/logger"
+)
+
+// FilesystemService implementa StorageService gravando arquivos em um diretório local.
+// Destinado a desenvolvimento e testes sem MinIO; cada bucket é um subdiretório da raiz.
+type FilesystemService struct {
+	root string
+}
+
+// NewFilesystemService cria uma nova instância do storage em sistema de arquivos
+func NewFilesystemService() *FilesystemService {
+	cfg := config.Get()
+	return &FilesystemService{
+		root: cfg.Storage.LocalPath,
+	}
+}
+
+// Initialize cria o diretório raiz se necessário
+func (s *FilesystemService) Initialize() error {
+	logger.Printf("Initializing filesystem storage service at %s", s.root)
+
+	if err := os.MkdirAll(s.root, 0755); err != nil {
+		return fmt.Errorf("failed to create storage directory: %v", err)
+	}
+
+	logger.Println("Filesystem storage service initialized successfully")
+	return nil
+}
+
+// UploadFile grava um arquivo
+func (s *FilesystemService) UploadFile(ctx context.Context, bucketName, objectName string, data []byte, contentType string) error {
+	path, err := s.objectPath(bucketName, objectName)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %v", err)
+	}
+
+	return os.WriteFile(path, data, 0644)
+}
+
+// DownloadFile lê um arquivo
+func (s *FilesystemService) DownloadFile(ctx context.Context, bucketName, objectName string) ([]byte, error) {
+	path, err := s.objectPath(bucketName, objectName)
+	if err != nil {
+		return nil, err
+	}
+
+	return os.ReadFile(path)
+}
+
+// DeleteFile remove um arquivo (remover um arquivo inexistente não é erro)
+func (s *FilesystemService) DeleteFile(ctx context.Context, bucketName, objectName string) error {
+	path, err := s.objectPath(bucketName, objectName)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// CopyFile copia um arquivo em streaming; o destino só aparece completo
+func (s *FilesystemService) CopyFile(ctx context.Context, bucketName, sourceName, targetName string) error {
+	source, err := s.objectPath(bucketName, sourceName)
+	if err != nil {
+		return err
+	}
+	target, err := s.objectPath(bucketName, targetName)
+	if err != nil {
+		return err
+	}
+
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %v", err)
+	}
+	out, err := os.CreateTemp(filepath.Dir(target), ".copy-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(out.Name())
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(out.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(out.Name(), target)
+}
+
+// FileExists verifica se um arquivo existe
+func (s *FilesystemService) FileExists(ctx context.Context, bucketName, objectName string) (bool, error) {
+	path, err := s.objectPath(bucketName, objectName)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// ListFiles lista as chaves dos arquivos com o prefixo informado, recursivamente
+func (s *FilesystemService) ListFiles(ctx context.Context, bucketName, prefix string) ([]string, error) {
+	bucket, err := s.objectPath(bucketName, "")
+	if err != nil {
+		return nil, err
+	}
+
+	// Percorre apenas o diretório do prefixo; o prefixo pode terminar no meio de um nome
+	dir, err := s.objectPath(bucketName, path.Dir(prefix+"x"))
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []string{}
+	err = filepath.WalkDir(dir, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(bucket, file)
+		if err != nil {
+			return err
+		}
+		if key := filepath.ToSlash(rel); strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files: %w", err)
+	}
+	return keys, nil
+}
+
+// objectPath resolve o caminho do objeto garantindo que ele fique dentro da raiz
+func (s *FilesystemService) objectPath(bucketName, objectName string) (string, error) {
+	root := filepath.Clean(s.root)
+	path := filepath.Join(root, bucketName, filepath.FromSlash(objectName))
+	if path != root && !strings.HasPrefix(path, root+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid object path: %s/%s", bucketName, objectName)
+	}
+	return path, nil
+}
@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"testing"
+)
+
+func TestFilesystemCopyFile(t *testing.T) {
+	ctx := context.Background()
+	service := &FilesystemService{root: t.TempDir()}
+
+	if err := service.UploadFile(ctx, "bucket", "a/nota.xml", []byte("<xml/>"), "application/xml"); err != nil {
+		t.Fatal(err)
+	}
+	if err := service.CopyFile(ctx, "bucket", "a/nota.xml", "b/c/nota.xml"); err != nil {
+		t.Fatalf("CopyFile() error = %v", err)
+	}
+
+	data, err := service.DownloadFile(ctx, "bucket", "b/c/nota.xml")
+	if err != nil || string(data) != "<xml/>" {
+		t.Fatalf("copied object = %q, %v; want %q", data, err, "<xml/>")
+	}
+	if exists, _ := service.FileExists(ctx, "bucket", "a/nota.xml"); !exists {
+		t.Error("CopyFile() removed the source object")
+	}
+
+	// No temporary file is left next to the copy
+	entries, err := os.ReadDir(service.root + "/bucket/b/c")
+	if err != nil || len(entries) != 1 {
+		t.Errorf("target directory has %d entries, want 1 (%v)", len(entries), err)
+	}
+
+	if err := service.CopyFile(ctx, "bucket", "missing.xml", "b/missing.xml"); err == nil {
+		t.Error("CopyFile() of a missing object succeeded")
+	}
+}
+
+func TestFilesystemObjectPath(t *testing.T) {
+	service := &FilesystemService{root: "/data/storage"}
+
+	tests := []struct {
+		name    string
+		bucket  string
+		object  string
+		want    string
+		wantErr bool
+	}{
+		{"object in bucket", "nfse-storage", "nfse/2025/nota.xml", "/data/storage/nfse-storage/nfse/2025/nota.xml", false},
+		{"bucket root", "nfse-storage", "", "/data/storage/nfse-storage", false},
+		{"dot segments inside the root", "nfse-storage", "nfse/../nota.xml", "/data/storage/nfse-storage/nota.xml", false},
+		{"escaping the root", "nfse-storage", "../../../etc/passwd", "", true},
+		{"sibling directory with the root as prefix", "../storage-other", "nota.xml", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := service.objectPath(tt.bucket, tt.object)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("objectPath() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("objectPath() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
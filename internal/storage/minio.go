@@ -4,9 +4,11 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io"
 
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/minio/minio-go/v7/pkg/lifecycle"
 	"github.com/zoomxml/internal/logger"
 
 	"github.com/zoomxml/config"
@@ -18,7 +20,9 @@ type StorageService interface {
 	UploadFile(ctx context.Context, bucketName, objectName string, data []byte, contentType string) error
 	DownloadFile(ctx context.Context, bucketName, objectName string) ([]byte, error)
 	DeleteFile(ctx context.Context, bucketName, objectName string) error
+	CopyFile(ctx context.Context, bucketName, sourceName, targetName string) error
 	FileExists(ctx context.Context, bucketName, objectName string) (bool, error)
+	ListFiles(ctx context.Context, bucketName, prefix string) ([]string, error)
 }
 
 // MinIOService implementa StorageService usando MinIO
@@ -66,11 +70,66 @@ func (s *MinIOService) Initialize() error {
 		logger.Printf("Created MinIO bucket '%s'", s.config.Bucket)
 	}
 
+	if err := s.ApplyLifecycle(ctx); err != nil {
+		logger.Printf("Failed to set debug capture lifecycle on bucket '%s': %v", s.config.Bucket, err)
+	}
+
 	logger.Printf("MinIO bucket '%s' ready", s.config.Bucket)
 	logger.Println("MinIO storage service initialized successfully")
 	return nil
 }
 
+// debugCaptureRuleID identifica a regra de ciclo de vida das capturas de depuração
+const debugCaptureRuleID = "expire-debug-captures"
+
+// ApplyLifecycle aplica as regras de ciclo de vida configuradas ao bucket: capturas de
+// depuração (debug/) expiram após o TTL configurado. A regra é mesclada às já existentes
+// no bucket, que são preservadas; com a captura desligada, apenas ela é removida.
+func (s *MinIOService) ApplyLifecycle(ctx context.Context) error {
+	var rule *lifecycle.Rule
+	if s.config.DebugCaptureEnabled && s.config.DebugCaptureTTLDays > 0 {
+		rule = &lifecycle.Rule{
+			ID:         debugCaptureRuleID,
+			Status:     "Enabled",
+			RuleFilter: lifecycle.Filter{Prefix: "debug/"},
+			Expiration: lifecycle.Expiration{Days: lifecycle.ExpirationDays(s.config.DebugCaptureTTLDays)},
+		}
+	}
+
+	current, err := s.client.GetBucketLifecycle(ctx, s.config.Bucket)
+	if err != nil {
+		if minio.ToErrorResponse(err).Code != "NoSuchLifecycleConfiguration" {
+			return fmt.Errorf("failed to get bucket lifecycle: %w", err)
+		}
+		current = lifecycle.NewConfiguration()
+	}
+
+	merged, changed := mergeLifecycleRule(current, debugCaptureRuleID, rule)
+	if !changed {
+		return nil
+	}
+	return s.client.SetBucketLifecycle(ctx, s.config.Bucket, merged)
+}
+
+// mergeLifecycleRule substitui a regra id da configuração por rule, ou a remove quando
+// rule é nil, mantendo as demais. changed é falso quando não há o que gravar.
+func mergeLifecycleRule(current *lifecycle.Configuration, id string, rule *lifecycle.Rule) (*lifecycle.Configuration, bool) {
+	merged := lifecycle.NewConfiguration()
+	removed := false
+	for _, existing := range current.Rules {
+		if existing.ID == id {
+			removed = true
+			continue
+		}
+		merged.Rules = append(merged.Rules, existing)
+	}
+
+	if rule != nil {
+		merged.Rules = append(merged.Rules, *rule)
+	}
+	return merged, rule != nil || removed
+}
+
 // UploadFile faz upload de um arquivo
 func (s *MinIOService) UploadFile(ctx context.Context, bucketName, objectName string, data []byte, contentType string) error {
 	logger.Printf("Uploading file: %s/%s (%d bytes)", bucketName, objectName, len(data))
@@ -92,30 +151,83 @@ func (s *MinIOService) UploadFile(ctx context.Context, bucketName, objectName st
 
 // DownloadFile faz download de um arquivo
 func (s *MinIOService) DownloadFile(ctx context.Context, bucketName, objectName string) ([]byte, error) {
-	// TODO: Implementar download real
 	logger.Printf("Downloading file: %s/%s", bucketName, objectName)
-	return nil, fmt.Errorf("not implemented yet")
+
+	object, err := s.client.GetObject(ctx, bucketName, objectName, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get object: %w", err)
+	}
+	defer object.Close()
+
+	// GetObject só contata o servidor na primeira leitura; objeto ausente aparece aqui
+	data, err := io.ReadAll(object)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read object: %w", err)
+	}
+	return data, nil
 }
 
 // DeleteFile remove um arquivo
 func (s *MinIOService) DeleteFile(ctx context.Context, bucketName, objectName string) error {
-	// TODO: Implementar remoção real
 	logger.Printf("Deleting file: %s/%s", bucketName, objectName)
+
+	err := s.client.RemoveObject(ctx, bucketName, objectName, minio.RemoveObjectOptions{})
+	if err != nil {
+		logger.Printf("Failed to delete file from MinIO: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// CopyFile copia um arquivo dentro do bucket sem trafegar o conteúdo (cópia no servidor)
+func (s *MinIOService) CopyFile(ctx context.Context, bucketName, sourceName, targetName string) error {
+	_, err := s.client.CopyObject(ctx,
+		minio.CopyDestOptions{Bucket: bucketName, Object: targetName},
+		minio.CopySrcOptions{Bucket: bucketName, Object: sourceName},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to copy object: %w", err)
+	}
 	return nil
 }
 
 // FileExists verifica se um arquivo existe
 func (s *MinIOService) FileExists(ctx context.Context, bucketName, objectName string) (bool, error) {
-	// TODO: Implementar verificação real
-	logger.Printf("Checking if file exists: %s/%s", bucketName, objectName)
-	return false, nil
+	_, err := s.client.StatObject(ctx, bucketName, objectName, minio.StatObjectOptions{})
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to stat object: %w", err)
+	}
+	return true, nil
+}
+
+// ListFiles lista as chaves dos objetos com o prefixo informado, recursivamente
+func (s *MinIOService) ListFiles(ctx context.Context, bucketName, prefix string) ([]string, error) {
+	keys := []string{}
+	for object := range s.client.ListObjects(ctx, bucketName, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if object.Err != nil {
+			return nil, fmt.Errorf("failed to list objects: %w", object.Err)
+		}
+		keys = append(keys, object.Key)
+	}
+	return keys, nil
 }
 
 // Global storage service instance
 var Storage StorageService
 
-// InitializeStorage inicializa o serviço de storage global
+// InitializeStorage inicializa o serviço de storage global conforme STORAGE_BACKEND
 func InitializeStorage() error {
-	Storage = NewMinIOService()
+	switch backend := config.Get().Storage.Backend; backend {
+	case "minio", "":
+		Storage = NewMinIOService()
+	case "filesystem":
+		Storage = NewFilesystemService()
+	default:
+		return fmt.Errorf("unknown storage backend: %s", backend)
+	}
 	return Storage.Initialize()
 }
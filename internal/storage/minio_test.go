@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/minio/minio-go/v7/pkg/lifecycle"
+)
+
+func TestMergeLifecycleRule(t *testing.T) {
+	existing := lifecycle.Rule{ID: "archive-old", Status: "Enabled", RuleFilter: lifecycle.Filter{Prefix: "archive/"}}
+	stale := lifecycle.Rule{ID: debugCaptureRuleID, Status: "Enabled", Expiration: lifecycle.Expiration{Days: 3}}
+	fresh := lifecycle.Rule{ID: debugCaptureRuleID, Status: "Enabled", Expiration: lifecycle.Expiration{Days: 7}}
+
+	tests := []struct {
+		name        string
+		current     []lifecycle.Rule
+		rule        *lifecycle.Rule
+		wantIDs     []string
+		wantChanged bool
+	}{
+		{"adds to other rules", []lifecycle.Rule{existing}, &fresh, []string{"archive-old", debugCaptureRuleID}, true},
+		{"replaces own rule", []lifecycle.Rule{stale, existing}, &fresh, []string{"archive-old", debugCaptureRuleID}, true},
+		{"removes own rule", []lifecycle.Rule{existing, stale}, nil, []string{"archive-old"}, true},
+		{"nothing to remove", []lifecycle.Rule{existing}, nil, []string{"archive-old"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			current := lifecycle.NewConfiguration()
+			current.Rules = tt.current
+
+			merged, changed := mergeLifecycleRule(current, debugCaptureRuleID, tt.rule)
+			if changed != tt.wantChanged {
+				t.Errorf("changed = %v, want %v", changed, tt.wantChanged)
+			}
+
+			ids := []string{}
+			for _, rule := range merged.Rules {
+				ids = append(ids, rule.ID)
+			}
+			if len(ids) != len(tt.wantIDs) {
+				t.Fatalf("rules = %v, want %v", ids, tt.wantIDs)
+			}
+			for i := range ids {
+				if ids[i] != tt.wantIDs[i] {
+					t.Errorf("rules = %v, want %v", ids, tt.wantIDs)
+				}
+			}
+			if tt.rule != nil && merged.Rules[len(merged.Rules)-1].Expiration.Days != tt.rule.Expiration.Days {
+				t.Errorf("own rule expiration = %d, want %d", merged.Rules[len(merged.Rules)-1].Expiration.Days, tt.rule.Expiration.Days)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/zoomxml/config"
+	"github.com/zoomxml/internal/logger"
+)
+
+// lifecycleApplier é implementado pelos backends com regras de ciclo de vida de bucket
+type lifecycleApplier interface {
+	ApplyLifecycle(ctx context.Context) error
+}
+
+// Warmup prepara o storage na inicialização: aplica o ciclo de vida configurado do bucket
+// e verifica o roundtrip gravando, lendo e removendo um objeto de sonda. Qualquer falha é
+// retornada para que a inicialização aborte em vez de falhar na primeira ingestão.
+func Warmup(ctx context.Context) error {
+	if Storage == nil {
+		return fmt.Errorf("storage not initialized")
+	}
+
+	if applier, ok := Storage.(lifecycleApplier); ok {
+		if err := applier.ApplyLifecycle(ctx); err != nil {
+			return fmt.Errorf("failed to apply bucket lifecycle: %w", err)
+		}
+	}
+
+	return probeRoundtrip(ctx, Storage, config.Get().Storage.Bucket)
+}
+
+// probeRoundtrip grava um objeto mínimo em warmup/, confere o conteúdo lido de volta e o remove
+func probeRoundtrip(ctx context.Context, service StorageService, bucketName string) error {
+	objectName := fmt.Sprintf("warmup/probe-%d", time.Now().UnixNano())
+	payload := []byte("zoomxml warmup probe")
+
+	if err := service.UploadFile(ctx, bucketName, objectName, payload, "text/plain"); err != nil {
+		return fmt.Errorf("storage probe upload failed: %w", err)
+	}
+
+	data, err := service.DownloadFile(ctx, bucketName, objectName)
+	if err == nil && !bytes.Equal(data, payload) {
+		err = fmt.Errorf("content mismatch (%d bytes read)", len(data))
+	}
+	if err != nil {
+		service.DeleteFile(ctx, bucketName, objectName)
+		return fmt.Errorf("storage probe download failed: %w", err)
+	}
+
+	if err := service.DeleteFile(ctx, bucketName, objectName); err != nil {
+		return fmt.Errorf("storage probe delete failed: %w", err)
+	}
+
+	logger.Printf("Storage probe succeeded on bucket '%s'", bucketName)
+	return nil
+}
@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// corruptingStorage is a filesystem storage whose downloads fail or return other content
+type corruptingStorage struct {
+	*FilesystemService
+	downloadErr error
+}
+
+func (s *corruptingStorage) DownloadFile(ctx context.Context, bucketName, objectName string) ([]byte, error) {
+	if s.downloadErr != nil {
+		return nil, s.downloadErr
+	}
+	return []byte("other content"), nil
+}
+
+func TestProbeRoundtrip(t *testing.T) {
+	ctx := context.Background()
+
+	if err := probeRoundtrip(ctx, &FilesystemService{root: t.TempDir()}, "bucket"); err != nil {
+		t.Fatalf("probeRoundtrip() error = %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		downloadErr error
+	}{
+		{"download fails", errors.New("connection reset")},
+		{"content mismatch", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			service := &corruptingStorage{FilesystemService: &FilesystemService{root: root}, downloadErr: tt.downloadErr}
+
+			if err := probeRoundtrip(ctx, service, "bucket"); err == nil {
+				t.Fatal("probeRoundtrip() succeeded, want an error")
+			}
+
+			// The probe object is removed even when the roundtrip fails
+			entries, _ := os.ReadDir(filepath.Join(root, "bucket", "warmup"))
+			if len(entries) != 0 {
+				t.Errorf("probe left %d objects behind", len(entries))
+			}
+		})
+	}
+}